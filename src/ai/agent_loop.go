@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"mogenius-operator/src/ai/aisdk"
+	"mogenius-operator/src/crds/v1alpha1"
 )
 
 // runAgentLoop executes the unattended tool-call loop using a provider-neutral
@@ -174,14 +175,25 @@ func buildAgentMessages(systemPrompt, userPrompt string) []aisdk.Message {
 }
 
 // buildAgentTools assembles the tool list for an unattended agent run from
-// MCP sessions and the built-in kubernetes/helm tool sets.
+// MCP sessions and the built-in kubernetes/helm tool sets. Built-in tools the
+// agent's tool policy denies are not offered to the model.
 func buildAgentTools(mcpManager *mcpClientManager, mcpSessions []string, toolCtx *ToolContext) []aisdk.Tool {
 	tools := mcpManager.GetAiSDKToolsForSessions(mcpSessions)
 	if toolCtx == nil || !toolCtx.DisableKubernetes {
-		tools = append(tools, kubernetesAiSDKTools...)
+		tools = appendAllowedBuiltinTools(tools, kubernetesAiSDKTools, toolCtx)
 	}
 	if toolCtx == nil || !toolCtx.DisableHelm {
-		tools = append(tools, helmAiSDKTools...)
+		tools = appendAllowedBuiltinTools(tools, helmAiSDKTools, toolCtx)
+	}
+	return tools
+}
+
+func appendAllowedBuiltinTools(tools []aisdk.Tool, builtin []aisdk.Tool, toolCtx *ToolContext) []aisdk.Tool {
+	for _, t := range builtin {
+		if toolCtx.BuiltinToolPolicy(t.Name) == v1alpha1.MCPToolPolicyDeny {
+			continue
+		}
+		tools = append(tools, t)
 	}
 	return tools
 }
@@ -20,9 +20,11 @@ import (
 
 // task trigger kinds
 const (
-	AI_TASK_TRIGGER_EVENT  = "event"
-	AI_TASK_TRIGGER_CRON   = "cron"
-	AI_TASK_TRIGGER_MANUAL = "manual"
+	AI_TASK_TRIGGER_EVENT         = "event"
+	AI_TASK_TRIGGER_CRON          = "cron"
+	AI_TASK_TRIGGER_MANUAL        = "manual"
+	AI_TASK_TRIGGER_METRIC        = "metric"
+	AI_TASK_TRIGGER_VULNERABILITY = "vulnerability"
 )
 
 // validChangeEventTypes are the change signals a change trigger may react to.
@@ -42,7 +44,8 @@ const agentCacheTTL = 30 * time.Second
 // ValidateAgentSpec checks an agent spec for the invariants the pipeline
 // relies on: a non-empty scope (an agent without scope restrictions must not
 // exist — empty allow-maps would disable namespace checks entirely), a
-// parseable cron expression and a well-formed change trigger.
+// parseable cron expression, well-formed change, metric and vulnerability
+// triggers and tool policies that name existing built-in tools.
 func ValidateAgentSpec(spec v1alpha1.AgentSpec) error {
 	if spec.Scope.WorkspaceRef == "" && len(spec.Scope.Namespaces) == 0 {
 		return fmt.Errorf("agent scope must reference a workspace or list at least one namespace")
@@ -64,6 +67,42 @@ func ValidateAgentSpec(spec v1alpha1.AgentSpec) error {
 			}
 		}
 	}
+	if mt := spec.Triggers.OnMetric; mt != nil {
+		for _, metric := range mt.Metrics {
+			if !validAgentMetrics[metric] {
+				return fmt.Errorf("onMetric.metrics contains invalid metric %q (allowed: cpu, memory, traffic)", metric)
+			}
+		}
+		if _, ok := metricSensitivityZScores[mt.Sensitivity]; mt.Sensitivity != "" && !ok {
+			return fmt.Errorf("onMetric.sensitivity %q is invalid (allowed: low, medium, high)", mt.Sensitivity)
+		}
+	}
+	if vt := spec.Triggers.OnVulnerability; vt != nil && vt.MinSeverity != "" && !validVulnerabilitySeverities[vt.MinSeverity] {
+		return fmt.Errorf("onVulnerability.minSeverity %q is invalid (allowed: critical, high)", vt.MinSeverity)
+	}
+	if b := spec.Tools.Builtin; b != nil {
+		seen := make(map[string]bool, len(b.ToolPolicies))
+		for _, tp := range b.ToolPolicies {
+			if _, ok := toolDefinitions[tp.Name]; !ok {
+				return fmt.Errorf("tools.builtin.toolPolicies references unknown built-in tool %q", tp.Name)
+			}
+			if seen[tp.Name] {
+				return fmt.Errorf("tools.builtin.toolPolicies lists tool %q more than once", tp.Name)
+			}
+			seen[tp.Name] = true
+			switch tp.Policy {
+			case v1alpha1.MCPToolPolicyDeny, v1alpha1.MCPToolPolicyNeedsApprove, v1alpha1.MCPToolPolicyAutoApprove:
+			default:
+				return fmt.Errorf("tools.builtin.toolPolicies has invalid policy %q for tool %q (allowed: deny, needsApprove, autoApprove)", tp.Policy, tp.Name)
+			}
+			if tp.Policy == v1alpha1.MCPToolPolicyAutoApprove && unscopedBuiltinTools[tp.Name] {
+				return fmt.Errorf("tools.builtin.toolPolicies cannot autoApprove %q: it changes cluster-wide Helm repositories outside the agent's scope", tp.Name)
+			}
+			if tp.Policy == v1alpha1.MCPToolPolicyAutoApprove && namespacedBuiltinTools[tp.Name] && slices.Contains(spec.Scope.Namespaces, "*") {
+				return fmt.Errorf("tools.builtin.toolPolicies cannot autoApprove %q: the agent's scope includes all namespaces", tp.Name)
+			}
+		}
+	}
 	return nil
 }
 
@@ -481,6 +520,18 @@ func (ai *aiManager) buildAgentTaskContext(task *AiTask) (*v1alpha1.Agent, *Tool
 	if b := agent.Spec.Tools.Builtin; b != nil {
 		toolCtx.DisableKubernetes = !b.Kubernetes
 		toolCtx.DisableHelm = !b.Helm
+		if len(b.ToolPolicies) > 0 {
+			toolCtx.BuiltinToolPolicies = make(map[string]v1alpha1.MCPToolPolicyType, len(b.ToolPolicies))
+			allNamespaces := slices.Contains(agent.Spec.Scope.Namespaces, "*")
+			for _, tp := range b.ToolPolicies {
+				policy := tp.Policy
+				// agents stored before autoApprove was rejected for them
+				if policy == v1alpha1.MCPToolPolicyAutoApprove && namespacedBuiltinTools[tp.Name] && allNamespaces {
+					policy = v1alpha1.MCPToolPolicyNeedsApprove
+				}
+				toolCtx.BuiltinToolPolicies[tp.Name] = policy
+			}
+		}
 	}
 
 	// Wire the approval-request callback. When a mutating tool call is
@@ -496,7 +547,11 @@ func (ai *aiManager) buildAgentTaskContext(task *AiTask) (*v1alpha1.Agent, *Tool
 		if err != nil || runTask == nil {
 			return nil, fmt.Errorf("failed to load run task for approval: %w", err)
 		}
+		// Snapshot the target's version so a change between proposal and
+		// approval is caught before the call executes.
+		baseVersion := builtinToolTargetVersion(toolName, args)
 		runTask.State = AI_TASK_STATE_PROPOSED
+		runTask.BaseResourceVersion = baseVersion
 		runTask.Response = &AiResponse{
 			ToolRequests: []ToolRequest{{
 				Name:     toolName,
@@ -546,12 +601,25 @@ func (ai *aiManager) buildAgentTaskContext(task *AiTask) (*v1alpha1.Agent, *Tool
 			}
 			current.State = AI_TASK_STATE_IN_PROGRESS
 			current.Response = nil
+			current.BaseResourceVersion = ""
 			if saveErr := ai.createOrUpdateAiTask(current, runTaskID); saveErr != nil {
 				ai.logger.Warn("Failed to reset run task to in-progress after approval", "taskID", runTaskID, "error", saveErr)
 			}
 			ai.notifyTaskChanged(current)
 		}
 
+		// approved continues the run after an approval, unless the target
+		// changed since the proposal — the approver decided on a state that
+		// no longer exists, so the model has to re-inspect and propose again.
+		approved := func(approver structs.User) (*ToolContext, error) {
+			resetToInProgress()
+			if err := checkBuiltinToolTargetVersion(toolName, args, baseVersion); err != nil {
+				ai.logger.Warn("Approved tool call refused", "taskID", runTaskID, "tool", toolName, "error", err)
+				return nil, err
+			}
+			return buildApproverCtx(approver), nil
+		}
+
 		ai.logger.Info("Agent run waiting for approval", "taskID", runTaskID, "tool", toolName)
 		pollTicker := time.NewTicker(3 * time.Second)
 		defer pollTicker.Stop()
@@ -565,8 +633,7 @@ func (ai *aiManager) buildAgentTaskContext(task *AiTask) (*v1alpha1.Agent, *Tool
 				if res.err != nil {
 					return nil, res.err
 				}
-				resetToInProgress()
-				return buildApproverCtx(res.approver), nil
+				return approved(res.approver)
 
 			case <-pollTicker.C:
 				// Fallback: read Valkey to catch approvals that arrived via
@@ -583,8 +650,7 @@ func (ai *aiManager) buildAgentTaskContext(task *AiTask) (*v1alpha1.Agent, *Tool
 					if current.Approval != nil {
 						approver = current.Approval.User
 					}
-					resetToInProgress()
-					return buildApproverCtx(approver), nil
+					return approved(approver)
 				}
 				// Any other non-proposed state is treated as a rejection.
 				reason := string(current.State)
@@ -700,11 +766,14 @@ func (ai *aiManager) pruneAgentRunsToLimit(agentName string) {
 				ai.logger.Error("Error deleting task of pruned agent run", "agent", agentName, "key", key, "error", delErr)
 				continue
 			}
-			// Only the primary run task owns a step timeline; deleting the
-			// steps key of a finding task is a no-op.
+			// Only the primary run task owns a step timeline and a
+			// recording; deleting those keys of a finding task is a no-op.
 			if stepsErr := ai.valkeyClient.DeleteSingle(runStepsKey(key)); stepsErr != nil {
 				ai.logger.Warn("Failed to delete AI run steps", "key", key, "error", stepsErr)
 			}
+			if recErr := ai.valkeyClient.DeleteSingle(runRecordingKey(key)); recErr != nil {
+				ai.logger.Warn("Failed to delete AI run recording", "key", key, "error", recErr)
+			}
 			ai.sendAiDeleteEvent(key)
 			pruned = true
 		}
@@ -733,7 +802,7 @@ func (ai *aiManager) triggerChangeAgents(obj *unstructured.Unstructured, changeT
 		if !namespaceSelected(namespaces, obj.GetNamespace()) {
 			continue
 		}
-		if !ai.changeCooldownElapsed(agent.Name, oc) {
+		if !ai.agentCooldownElapsed(agent.Name, changeCooldown(oc)) {
 			continue
 		}
 		agentCopy := agent
@@ -746,16 +815,16 @@ func (ai *aiManager) triggerChangeAgents(obj *unstructured.Unstructured, changeT
 	}
 }
 
-// changeCooldownElapsed reports whether enough time passed since the agent's
-// last run for a change trigger to fire again.
-func (ai *aiManager) changeCooldownElapsed(agentName string, oc *v1alpha1.AgentChangeTrigger) bool {
+// agentCooldownElapsed reports whether enough time passed since the agent's
+// last run for a change or metric trigger to fire again.
+func (ai *aiManager) agentCooldownElapsed(agentName string, cooldown time.Duration) bool {
 	ai.cronStateLock.Lock()
 	last, seen := ai.lastAgentRun[agentName]
 	ai.cronStateLock.Unlock()
 	if !seen {
 		return true
 	}
-	return time.Since(last) >= changeCooldown(oc)
+	return time.Since(last) >= cooldown
 }
 
 // changeTypeSelected reports whether changeType is selected; an empty list
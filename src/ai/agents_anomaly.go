@@ -0,0 +1,407 @@
+package ai
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/valkeyclient"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric names an onMetric trigger may watch.
+const (
+	AGENT_METRIC_CPU     = "cpu"
+	AGENT_METRIC_MEMORY  = "memory"
+	AGENT_METRIC_TRAFFIC = "traffic"
+)
+
+// Rollup streams the per-minute workload stats are read from. They are
+// written by the stats db (AddPodStatsToDb in the operator,
+// AddInterfaceStatsToDb in the nodemetrics DaemonSet) as
+// "<bucket>:1m:<namespace>:<controller>", with a trailing ":<node>" for
+// traffic, and hold the sum over all replicas of a workload. The raw streams
+// keep only one pod per minute.
+const (
+	statsPodStatsRollupBucket = "pod-stats-rollup"
+	statsTrafficRollupBucket  = "traffic-stats-rollup"
+	statsRollupTier           = "1m"
+)
+
+var validAgentMetrics = map[string]bool{AGENT_METRIC_CPU: true, AGENT_METRIC_MEMORY: true, AGENT_METRIC_TRAFFIC: true}
+
+// metricSensitivityZScores maps an onMetric sensitivity to the z-score two
+// consecutive samples must exceed.
+var metricSensitivityZScores = map[string]float64{"low": 4, "medium": 3, "high": 2}
+
+const defaultMetricSensitivity = "medium"
+
+// metricMinStdDev is the smallest standard deviation a baseline is scored
+// against, in the unit of the metric (millicores, bytes, bytes per minute).
+// Without it a workload that sat perfectly flat — idle traffic, constant
+// memory — would report every tiny wobble as an anomaly.
+var metricMinStdDev = map[string]float64{
+	AGENT_METRIC_CPU:     10,
+	AGENT_METRIC_MEMORY:  16 * 1024 * 1024,
+	AGENT_METRIC_TRAFFIC: 64 * 1024,
+}
+
+const (
+	// anomalyEwmaAlpha weights a new sample into the baseline; with one
+	// sample per minute the baseline reflects roughly the last 20 minutes.
+	anomalyEwmaAlpha = 0.05
+	// anomalyWarmupSamples is how many samples a baseline needs before it
+	// may report anomalies.
+	anomalyWarmupSamples = 30
+	// anomalyMinRelativeStdDev is the smallest standard deviation relative
+	// to the mean, so steady workloads are not flagged for a few percent.
+	anomalyMinRelativeStdDev = 0.05
+	// anomalyUpdateClampZ limits how far one sample moves the baseline.
+	// Spikes barely shift it, so the sample after a spike is still scored
+	// against normal behaviour; a lasting level shift is learned gradually.
+	anomalyUpdateClampZ = 3
+	// anomalySampleMaxAge skips a workload whose newest sample is older —
+	// it stopped reporting, which is not a fresh observation.
+	anomalySampleMaxAge = 5 * time.Minute
+	// anomalySeriesTTL drops the baselines of workloads that stopped
+	// reporting.
+	anomalySeriesTTL = time.Hour
+	// maxAnomaliesInPrompt caps the anomalies listed in a run's prompt.
+	maxAnomaliesInPrompt = 10
+)
+
+type metricSeries struct {
+	namespace string
+	workload  string
+	metric    string
+}
+
+type metricBaseline struct {
+	mean         float64
+	variance     float64
+	samples      int
+	lastZ        float64
+	lastSampleAt time.Time
+}
+
+// metricObservation is one sample scored against the baseline as it was
+// before the sample.
+type metricObservation struct {
+	series    metricSeries
+	value     float64
+	mean      float64
+	stdDev    float64
+	z         float64
+	previousZ float64
+	at        time.Time
+}
+
+// anomalous reports whether this and the previous sample deviate by at least
+// threshold standard deviations in the same direction. Requiring two samples
+// filters single-sample glitches such as the zero delta written after the
+// traffic collector restarts.
+func (self metricObservation) anomalous(threshold float64) bool {
+	if math.Abs(self.z) < threshold || math.Abs(self.previousZ) < threshold {
+		return false
+	}
+	return (self.z > 0) == (self.previousZ > 0)
+}
+
+// anomalyDetector keeps an exponentially weighted mean and variance per
+// workload and metric and scores each new sample as a z-score against them.
+type anomalyDetector struct {
+	lock      sync.Mutex
+	baselines map[metricSeries]*metricBaseline
+}
+
+func newAnomalyDetector() *anomalyDetector {
+	return &anomalyDetector{baselines: map[metricSeries]*metricBaseline{}}
+}
+
+// observe feeds one sample into the series' baseline. ok is false while the
+// baseline warms up and for samples that are not newer than the last one.
+func (self *anomalyDetector) observe(series metricSeries, value float64, at time.Time) (metricObservation, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	baseline, exists := self.baselines[series]
+	if !exists {
+		self.baselines[series] = &metricBaseline{mean: value, samples: 1, lastSampleAt: at}
+		return metricObservation{}, false
+	}
+	if !at.After(baseline.lastSampleAt) {
+		return metricObservation{}, false
+	}
+
+	stdDev := max(math.Sqrt(baseline.variance), anomalyMinRelativeStdDev*math.Abs(baseline.mean), metricMinStdDev[series.metric])
+	observation := metricObservation{
+		series:    series,
+		value:     value,
+		mean:      baseline.mean,
+		stdDev:    stdDev,
+		z:         (value - baseline.mean) / stdDev,
+		previousZ: baseline.lastZ,
+		at:        at,
+	}
+	warm := baseline.samples >= anomalyWarmupSamples
+
+	diff := max(-anomalyUpdateClampZ*stdDev, min(value-baseline.mean, anomalyUpdateClampZ*stdDev))
+	increment := anomalyEwmaAlpha * diff
+	baseline.mean += increment
+	baseline.variance = (1 - anomalyEwmaAlpha) * (baseline.variance + diff*increment)
+	baseline.samples++
+	baseline.lastZ = observation.z
+	baseline.lastSampleAt = at
+
+	return observation, warm
+}
+
+// prune drops the baselines of series without a sample since before.
+func (self *anomalyDetector) prune(before time.Time) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for series, baseline := range self.baselines {
+		if baseline.lastSampleAt.Before(before) {
+			delete(self.baselines, series)
+		}
+	}
+}
+
+// metricCooldown returns the effective cooldown for an agent's metric trigger.
+func metricCooldown(mt *v1alpha1.AgentMetricTrigger) time.Duration {
+	if mt != nil && mt.MinInterval.Duration > 0 {
+		return mt.MinInterval.Duration
+	}
+	return defaultChangeCooldown
+}
+
+// metricSensitivityZScore returns the z-score threshold of an agent's metric
+// trigger.
+func metricSensitivityZScore(mt *v1alpha1.AgentMetricTrigger) float64 {
+	return metricSensitivityZScores[cmp.Or(mt.Sensitivity, defaultMetricSensitivity)]
+}
+
+// metricSelected reports whether metric is selected; an empty list means all
+// metrics.
+func metricSelected(metrics []string, metric string) bool {
+	if len(metrics) == 0 {
+		return true
+	}
+	return slices.Contains(metrics, metric)
+}
+
+// processAgentMetricTriggers feeds the newest workload stats into the anomaly
+// detector and enqueues a run for every agent with an onMetric trigger that
+// sees an anomaly in its scope. Called from the minute ticker on the leading
+// replica only; while no agent has a metric trigger nothing is read.
+func (ai *aiManager) processAgentMetricTriggers() {
+	type metricAgent struct {
+		agent      v1alpha1.Agent
+		namespaces []string
+	}
+	var agents []metricAgent
+	for _, agent := range ai.getEnabledAgents() {
+		if agent.Spec.Triggers.OnMetric == nil {
+			continue
+		}
+		agents = append(agents, metricAgent{agent: agent, namespaces: ai.resolveAgentScope(&agent)})
+	}
+	if len(agents) == 0 {
+		return
+	}
+
+	now := time.Now()
+	watched := func(namespace string) bool {
+		return slices.ContainsFunc(agents, func(a metricAgent) bool { return namespaceSelected(a.namespaces, namespace) })
+	}
+	var observations []metricObservation
+	for _, sample := range ai.readLatestWorkloadMetrics(watched) {
+		if now.Sub(sample.at) > anomalySampleMaxAge {
+			continue
+		}
+		if observation, ok := ai.metricAnomalies.observe(sample.series, sample.value, sample.at); ok {
+			observations = append(observations, observation)
+		}
+	}
+	ai.metricAnomalies.prune(now.Add(-anomalySeriesTTL))
+
+	for _, a := range agents {
+		mt := a.agent.Spec.Triggers.OnMetric
+		threshold := metricSensitivityZScore(mt)
+		var anomalies []metricObservation
+		for _, observation := range observations {
+			if !metricSelected(mt.Metrics, observation.series.metric) || !namespaceSelected(a.namespaces, observation.series.namespace) {
+				continue
+			}
+			if observation.anomalous(threshold) {
+				anomalies = append(anomalies, observation)
+			}
+		}
+		if len(anomalies) == 0 {
+			continue
+		}
+		if !ai.agentCooldownElapsed(a.agent.Name, metricCooldown(mt)) {
+			continue
+		}
+		agentCopy := a.agent
+		if _, err := ai.createAgentMetricTask(&agentCopy, anomalies); err != nil {
+			// An already-open run or empty scope is expected/benign here.
+			ai.logger.Info("Metric trigger did not enqueue a run", "agent", a.agent.Name, "reason", err.Error())
+			continue
+		}
+		ai.logger.Info("Metric trigger enqueued a run", "agent", a.agent.Name, "anomalies", len(anomalies))
+	}
+}
+
+type workloadMetricSample struct {
+	series metricSeries
+	value  float64
+	at     time.Time
+}
+
+// readLatestWorkloadMetrics returns the newest cpu, memory and traffic sample
+// of every workload in a watched namespace.
+func (ai *aiManager) readLatestWorkloadMetrics(watched func(namespace string) bool) []workloadMetricSample {
+	var samples []workloadMetricSample
+	for _, rollup := range ai.readLatestStatsRollups(statsPodStatsRollupBucket, 1, watched) {
+		entry := rollup.entries[0]
+		samples = append(samples,
+			workloadMetricSample{metricSeries{rollup.namespace, rollup.workload, AGENT_METRIC_CPU}, entry.Metrics["cpu"].Avg, entry.Start},
+			workloadMetricSample{metricSeries{rollup.namespace, rollup.workload, AGENT_METRIC_MEMORY}, entry.Metrics["memory"].Avg, entry.Start},
+		)
+	}
+
+	// the newest two windows per node, so a node that has not flushed the
+	// newest window yet still contributes the one before
+	trafficByWorkload := map[metricSeries][][]structs.StatsRollupEntry{}
+	for _, rollup := range ai.readLatestStatsRollups(statsTrafficRollupBucket, 2, watched) {
+		series := metricSeries{rollup.namespace, rollup.workload, AGENT_METRIC_TRAFFIC}
+		trafficByWorkload[series] = append(trafficByWorkload[series], rollup.entries)
+	}
+	for series, nodes := range trafficByWorkload {
+		if value, at, ok := sumTrafficRollups(nodes); ok {
+			samples = append(samples, workloadMetricSample{series, value, at})
+		}
+	}
+	return samples
+}
+
+// workloadStatsRollups are the newest rollup entries of one workload series,
+// newest first.
+type workloadStatsRollups struct {
+	namespace string
+	workload  string
+	entries   []structs.StatsRollupEntry
+}
+
+// readLatestStatsRollups returns the newest count entries of every 1m rollup
+// series of bucket in a watched namespace.
+func (ai *aiManager) readLatestStatsRollups(bucket string, count int64, watched func(namespace string) bool) []workloadStatsRollups {
+	keys, err := ai.valkeyClient.Keys(bucket + ":" + statsRollupTier + ":*")
+	if err != nil {
+		ai.logger.Warn("Failed to list workload stats for metric triggers", "bucket", bucket, "error", err)
+		return nil
+	}
+	keys = slices.DeleteFunc(keys, func(key string) bool {
+		parts := strings.Split(key, ":")
+		return len(parts) < 4 || !watched(parts[2])
+	})
+
+	var result []workloadStatsRollups
+	for chunk := range slices.Chunk(keys, valkeyBatchSize) {
+		rollups, err := valkeyclient.GetLastKeyedObjectsFromSortedLists[structs.StatsRollupEntry](ai.valkeyClient, count, chunk)
+		if err != nil {
+			ai.logger.Warn("Failed to read workload stats for metric triggers", "bucket", bucket, "error", err)
+		}
+		for _, rollup := range rollups {
+			if len(rollup.Object) == 0 {
+				continue
+			}
+			parts := strings.Split(rollup.Key, ":")
+			result = append(result, workloadStatsRollups{namespace: parts[2], workload: parts[3], entries: rollup.Object})
+		}
+	}
+	return result
+}
+
+// sumTrafficRollups adds up the per-node traffic of a workload (received plus
+// transmitted bytes) in the newest window every node has written. Nodes flush
+// their rollups independently, so one may lag a window behind; a node whose
+// newest window is older than that no longer runs the workload and is left
+// out.
+func sumTrafficRollups(nodes [][]structs.StatsRollupEntry) (float64, time.Time, bool) {
+	var newest time.Time
+	for _, entries := range nodes {
+		if len(entries) > 0 && entries[0].Start.After(newest) {
+			newest = entries[0].Start
+		}
+	}
+	if newest.IsZero() {
+		return 0, time.Time{}, false
+	}
+	window := newest
+	for _, entries := range nodes {
+		if len(entries) > 0 && !entries[0].Start.Before(newest.Add(-time.Minute)) && entries[0].Start.Before(window) {
+			window = entries[0].Start
+		}
+	}
+
+	total := 0.0
+	for _, entries := range nodes {
+		for _, entry := range entries {
+			if entry.Start.Equal(window) {
+				total += entry.Metrics["receivedBytes"].Avg + entry.Metrics["transmitBytes"].Avg
+			}
+		}
+	}
+	return total, window, true
+}
+
+// createAgentMetricTask enqueues a run triggered by metric anomalies. The
+// anomalies are described in the prompt so the model knows where to look.
+func (ai *aiManager) createAgentMetricTask(agent *v1alpha1.Agent, anomalies []metricObservation) (*AiTask, error) {
+	namespaces := ai.resolveAgentScope(agent)
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("agent %q has no resolvable scope namespaces", agent.Name)
+	}
+	return ai.enqueueAgentRun(agent, namespaces, AI_TASK_TRIGGER_METRIC, nil, buildAgentMetricPrompt(agent, namespaces, anomalies))
+}
+
+// buildAgentMetricPrompt is the user prompt for metric-triggered runs: the
+// strongest anomalies first, each with its value and baseline.
+func buildAgentMetricPrompt(agent *v1alpha1.Agent, namespaces []string, anomalies []metricObservation) string {
+	nsStr := strings.Join(namespaces, ", ")
+
+	anomalies = slices.Clone(anomalies)
+	slices.SortFunc(anomalies, func(a, b metricObservation) int {
+		return cmp.Compare(math.Abs(b.z), math.Abs(a.z))
+	})
+
+	var sb strings.Builder
+	sb.WriteString("Your scope for this run includes the following Kubernetes namespaces: ")
+	sb.WriteString(nsStr)
+	sb.WriteString(". You operate in read-only mode by default; any mutation requires explicit approval.")
+
+	sb.WriteString("\n\nThis run was triggered by metrics that left their usual range (baseline is the recent moving average ± one standard deviation):\n")
+	for _, anomaly := range anomalies[:min(len(anomalies), maxAnomaliesInPrompt)] {
+		unit := map[string]string{AGENT_METRIC_CPU: "millicores", AGENT_METRIC_MEMORY: "bytes", AGENT_METRIC_TRAFFIC: "bytes/min"}[anomaly.series.metric]
+		fmt.Fprintf(&sb, "\n- %s of workload %q in namespace %q: %.0f %s at %s, baseline %.0f ± %.0f (z-score %+.1f)",
+			anomaly.series.metric, anomaly.series.workload, anomaly.series.namespace,
+			anomaly.value, unit, anomaly.at.UTC().Format(time.RFC3339), anomaly.mean, anomaly.stdDev, anomaly.z)
+	}
+	if len(anomalies) > maxAnomaliesInPrompt {
+		fmt.Fprintf(&sb, "\n- … and %d more", len(anomalies)-maxAnomaliesInPrompt)
+	}
+
+	if agent.Spec.Instruction != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(agent.Spec.Instruction)
+		return sb.String()
+	}
+
+	return sb.String()
+}
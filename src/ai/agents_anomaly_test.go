@@ -0,0 +1,161 @@
+package ai
+
+import (
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/structs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// feed observes values one minute apart and returns the last observation.
+func feed(detector *anomalyDetector, series metricSeries, start time.Time, values ...float64) (metricObservation, bool) {
+	var observation metricObservation
+	var ok bool
+	for i, value := range values {
+		observation, ok = detector.observe(series, value, start.Add(time.Duration(i)*time.Minute))
+	}
+	return observation, ok
+}
+
+func steadyTraffic(n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		// ~10 MiB/min with some jitter
+		values[i] = 10*1024*1024 + float64(i%5-2)*200*1024
+	}
+	return values
+}
+
+func TestAnomalyDetectorWarmup(t *testing.T) {
+	detector := newAnomalyDetector()
+	series := metricSeries{"prod", "api", AGENT_METRIC_TRAFFIC}
+	start := time.Now()
+
+	_, ok := feed(detector, series, start, steadyTraffic(anomalyWarmupSamples)...)
+	assert.False(t, ok, "baseline must not report before it is warm")
+
+	_, ok = detector.observe(series, 10*1024*1024, start.Add(time.Duration(anomalyWarmupSamples)*time.Minute))
+	assert.True(t, ok)
+}
+
+func TestAnomalyDetectorTrafficDropToZero(t *testing.T) {
+	detector := newAnomalyDetector()
+	series := metricSeries{"prod", "api", AGENT_METRIC_TRAFFIC}
+	start := time.Now()
+	values := steadyTraffic(60)
+
+	observation, ok := feed(detector, series, start, values...)
+	require.True(t, ok)
+	assert.False(t, observation.anomalous(metricSensitivityZScores["high"]), "jitter is not an anomaly")
+
+	// a single zero sample (collector restart) is not enough
+	observation, ok = detector.observe(series, 0, start.Add(60*time.Minute))
+	require.True(t, ok)
+	assert.Less(t, observation.z, -4.0)
+	assert.False(t, observation.anomalous(metricSensitivityZScores["low"]))
+
+	// the second one is
+	observation, ok = detector.observe(series, 0, start.Add(61*time.Minute))
+	require.True(t, ok)
+	assert.Less(t, observation.z, -4.0)
+	assert.True(t, observation.anomalous(metricSensitivityZScores["low"]))
+}
+
+func TestAnomalyDetectorIgnoresAlternatingSpikes(t *testing.T) {
+	detector := newAnomalyDetector()
+	series := metricSeries{"prod", "api", AGENT_METRIC_CPU}
+	start := time.Now()
+	values := make([]float64, 60)
+	for i := range values {
+		values[i] = 500
+	}
+	_, ok := feed(detector, series, start, values...)
+	require.True(t, ok)
+
+	up, _ := detector.observe(series, 2000, start.Add(60*time.Minute))
+	down, _ := detector.observe(series, 0, start.Add(61*time.Minute))
+	assert.Greater(t, up.z, 3.0)
+	assert.Less(t, down.z, -3.0)
+	assert.False(t, down.anomalous(metricSensitivityZScores["medium"]), "deviations in opposite directions do not confirm each other")
+}
+
+func TestAnomalyDetectorSkipsRepeatedSamples(t *testing.T) {
+	detector := newAnomalyDetector()
+	series := metricSeries{"prod", "api", AGENT_METRIC_MEMORY}
+	start := time.Now()
+	_, ok := feed(detector, series, start, steadyTraffic(40)...)
+	require.True(t, ok)
+
+	_, ok = detector.observe(series, 1, start.Add(39*time.Minute))
+	assert.False(t, ok, "a sample that is not newer than the last one was already observed")
+}
+
+func TestAnomalyDetectorPrune(t *testing.T) {
+	detector := newAnomalyDetector()
+	start := time.Now()
+	detector.observe(metricSeries{"prod", "old", AGENT_METRIC_CPU}, 1, start)
+	detector.observe(metricSeries{"prod", "new", AGENT_METRIC_CPU}, 1, start.Add(time.Hour))
+
+	detector.prune(start.Add(time.Minute))
+	assert.Len(t, detector.baselines, 1)
+	assert.Contains(t, detector.baselines, metricSeries{"prod", "new", AGENT_METRIC_CPU})
+}
+
+func TestSumTrafficRollups(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	entry := func(minute int, bytes float64) structs.StatsRollupEntry {
+		return structs.StatsRollupEntry{
+			Start: start.Add(time.Duration(minute) * time.Minute),
+			Metrics: map[string]structs.StatsRollup{
+				"receivedBytes": {Avg: bytes},
+				"transmitBytes": {Avg: bytes},
+			},
+		}
+	}
+
+	_, _, ok := sumTrafficRollups(nil)
+	assert.False(t, ok)
+
+	value, at, ok := sumTrafficRollups([][]structs.StatsRollupEntry{
+		{entry(5, 100), entry(4, 10)},
+		// has not flushed minute 5 yet
+		{entry(4, 20), entry(3, 30)},
+		// the workload left this node
+		{entry(1, 1000)},
+	})
+	require.True(t, ok)
+	assert.Equal(t, start.Add(4*time.Minute), at, "the newest window every current node has written")
+	assert.Equal(t, float64(2*10+2*20), value)
+}
+
+func TestMetricTriggerDefaults(t *testing.T) {
+	assert.Equal(t, defaultChangeCooldown, metricCooldown(&v1alpha1.AgentMetricTrigger{}))
+	assert.Equal(t, 30*time.Minute, metricCooldown(&v1alpha1.AgentMetricTrigger{MinInterval: metav1.Duration{Duration: 30 * time.Minute}}))
+	assert.Equal(t, 3.0, metricSensitivityZScore(&v1alpha1.AgentMetricTrigger{}))
+	assert.Equal(t, 2.0, metricSensitivityZScore(&v1alpha1.AgentMetricTrigger{Sensitivity: "high"}))
+
+	assert.True(t, metricSelected(nil, AGENT_METRIC_MEMORY))
+	assert.False(t, metricSelected([]string{AGENT_METRIC_CPU}, AGENT_METRIC_MEMORY))
+}
+
+func TestBuildAgentMetricPrompt(t *testing.T) {
+	agent := &v1alpha1.Agent{
+		ObjectMeta: metav1.ObjectMeta{Name: "sre-agent"},
+		Spec:       v1alpha1.AgentSpec{Instruction: "find the root cause"},
+	}
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	prompt := buildAgentMetricPrompt(agent, []string{"prod"}, []metricObservation{
+		{series: metricSeries{"prod", "worker", AGENT_METRIC_CPU}, value: 900, mean: 300, stdDev: 100, z: 6, at: at},
+		{series: metricSeries{"prod", "api", AGENT_METRIC_TRAFFIC}, value: 0, mean: 1000000, stdDev: 50000, z: -20, at: at},
+	})
+
+	assert.Contains(t, prompt, "prod")
+	assert.Contains(t, prompt, `traffic of workload "api" in namespace "prod": 0 bytes/min at 2026-03-01T12:00:00Z, baseline 1000000 ± 50000 (z-score -20.0)`)
+	assert.Less(t, strings.Index(prompt, `"api"`), strings.Index(prompt, `"worker"`), "strongest anomaly first")
+	assert.Contains(t, prompt, "find the root cause")
+}
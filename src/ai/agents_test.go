@@ -2,6 +2,7 @@ package ai
 
 import (
 	"context"
+	"log/slog"
 	"mogenius-operator/src/crds/v1alpha1"
 	"mogenius-operator/src/structs"
 	"mogenius-operator/src/utils"
@@ -61,10 +62,108 @@ func TestValidateAgentSpec(t *testing.T) {
 				spec.Triggers.OnChange = &v1alpha1.AgentChangeTrigger{}
 			},
 		},
+		{
+			name: "onMetric with metrics and sensitivity",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Triggers.OnMetric = &v1alpha1.AgentMetricTrigger{Metrics: []string{"cpu", "traffic"}, Sensitivity: "high"}
+			},
+		},
+		{
+			name: "invalid metric",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Triggers.OnMetric = &v1alpha1.AgentMetricTrigger{Metrics: []string{"latency"}}
+			},
+			wantErr: "invalid metric",
+		},
+		{
+			name: "invalid sensitivity",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Triggers.OnMetric = &v1alpha1.AgentMetricTrigger{Sensitivity: "paranoid"}
+			},
+			wantErr: "sensitivity",
+		},
+		{
+			name: "onVulnerability with high severity",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Triggers.OnVulnerability = &v1alpha1.AgentVulnerabilityTrigger{MinSeverity: "high"}
+			},
+		},
+		{
+			name: "invalid vulnerability severity",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Triggers.OnVulnerability = &v1alpha1.AgentVulnerabilityTrigger{MinSeverity: "low"}
+			},
+			wantErr: "minSeverity",
+		},
 		{
 			name:   "workspace ref only is a valid scope",
 			mutate: func(spec *v1alpha1.AgentSpec) { spec.Scope = v1alpha1.AgentScope{WorkspaceRef: "team-a"} },
 		},
+		{
+			name: "builtin tool policies for known tools",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Tools.Builtin = &v1alpha1.AgentBuiltinTools{Kubernetes: true, Helm: true, ToolPolicies: []v1alpha1.MCPToolPolicy{
+					{Name: "update_kubernetes_resource", Policy: v1alpha1.MCPToolPolicyNeedsApprove},
+					{Name: "helm_release_rollback", Policy: v1alpha1.MCPToolPolicyAutoApprove},
+					{Name: "delete_kubernetes_resource", Policy: v1alpha1.MCPToolPolicyDeny},
+				}}
+			},
+		},
+		{
+			name: "builtin tool policy for unknown tool",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Tools.Builtin = &v1alpha1.AgentBuiltinTools{ToolPolicies: []v1alpha1.MCPToolPolicy{
+					{Name: "drop_cluster", Policy: v1alpha1.MCPToolPolicyDeny},
+				}}
+			},
+			wantErr: "unknown built-in tool",
+		},
+		{
+			name: "duplicate builtin tool policy",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Tools.Builtin = &v1alpha1.AgentBuiltinTools{ToolPolicies: []v1alpha1.MCPToolPolicy{
+					{Name: "helm_release_rollback", Policy: v1alpha1.MCPToolPolicyDeny},
+					{Name: "helm_release_rollback", Policy: v1alpha1.MCPToolPolicyAutoApprove},
+				}}
+			},
+			wantErr: "more than once",
+		},
+		{
+			name: "invalid builtin tool policy",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Tools.Builtin = &v1alpha1.AgentBuiltinTools{ToolPolicies: []v1alpha1.MCPToolPolicy{
+					{Name: "helm_release_rollback", Policy: "sometimes"},
+				}}
+			},
+			wantErr: "invalid policy",
+		},
+		{
+			name: "autoApprove for unscoped builtin tool",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Tools.Builtin = &v1alpha1.AgentBuiltinTools{Helm: true, ToolPolicies: []v1alpha1.MCPToolPolicy{
+					{Name: "helm_repo_add", Policy: v1alpha1.MCPToolPolicyAutoApprove},
+				}}
+			},
+			wantErr: "cannot autoApprove",
+		},
+		{
+			name: "autoApprove for kubernetes writes in a named scope",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Tools.Builtin = &v1alpha1.AgentBuiltinTools{Kubernetes: true, ToolPolicies: []v1alpha1.MCPToolPolicy{
+					{Name: "update_kubernetes_resource", Policy: v1alpha1.MCPToolPolicyAutoApprove},
+				}}
+			},
+		},
+		{
+			name: "autoApprove for kubernetes writes in all namespaces",
+			mutate: func(spec *v1alpha1.AgentSpec) {
+				spec.Scope = v1alpha1.AgentScope{Namespaces: []string{"*"}}
+				spec.Tools.Builtin = &v1alpha1.AgentBuiltinTools{Kubernetes: true, ToolPolicies: []v1alpha1.MCPToolPolicy{
+					{Name: "create_kubernetes_resource", Policy: v1alpha1.MCPToolPolicyAutoApprove},
+				}}
+			},
+			wantErr: "all namespaces",
+		},
 	}
 
 	for _, tt := range tests {
@@ -127,6 +226,103 @@ func TestNewToolContextFromAgent(t *testing.T) {
 	assert.Equal(t, "team-a", tc.Workspace)
 }
 
+func TestBuiltinToolPolicy(t *testing.T) {
+	var nilCtx *ToolContext
+	assert.Equal(t, v1alpha1.MCPToolPolicyAutoApprove, nilCtx.BuiltinToolPolicy("get_kubernetes_resources"))
+	assert.Equal(t, v1alpha1.MCPToolPolicyNeedsApprove, nilCtx.BuiltinToolPolicy("update_kubernetes_resource"))
+	assert.Equal(t, v1alpha1.MCPToolPolicyNeedsApprove, nilCtx.BuiltinToolPolicy("helm_release_rollback"))
+
+	tc := &ToolContext{BuiltinToolPolicies: map[string]v1alpha1.MCPToolPolicyType{
+		"delete_kubernetes_resource": v1alpha1.MCPToolPolicyDeny,
+		"helm_release_rollback":      v1alpha1.MCPToolPolicyAutoApprove,
+		"get_pod_logs":               v1alpha1.MCPToolPolicyNeedsApprove,
+		"helm_repo_remove":           v1alpha1.MCPToolPolicyAutoApprove,
+	}}
+	assert.Equal(t, v1alpha1.MCPToolPolicyDeny, tc.BuiltinToolPolicy("delete_kubernetes_resource"))
+	assert.Equal(t, v1alpha1.MCPToolPolicyAutoApprove, tc.BuiltinToolPolicy("helm_release_rollback"))
+	assert.Equal(t, v1alpha1.MCPToolPolicyNeedsApprove, tc.BuiltinToolPolicy("get_pod_logs"))
+	assert.Equal(t, v1alpha1.MCPToolPolicyNeedsApprove, tc.BuiltinToolPolicy("update_kubernetes_resource"), "unlisted mutating tools need approval")
+	assert.Equal(t, v1alpha1.MCPToolPolicyNeedsApprove, tc.BuiltinToolPolicy("helm_repo_remove"), "unscoped tools are never auto-approved")
+
+	// autoApprove runs with editor permissions but keeps the agent's scope.
+	agentCtx := newToolContextFromAgent(&v1alpha1.Agent{ObjectMeta: metav1.ObjectMeta{Name: "fixer"}}, []string{"prod"})
+	agentCtx.CreateApprovalRequest = func(context.Context, string, map[string]any) (*ToolContext, error) { return nil, nil }
+	auto := agentCtx.autoApprovedContext()
+	assert.True(t, auto.IsEditor())
+	assert.Nil(t, auto.CreateApprovalRequest)
+	assert.False(t, auto.IsNamespaceAllowed("kube-system"))
+	assert.Equal(t, "viewer", agentCtx.Role, "the agent context itself must stay read-only")
+	assert.Equal(t, "ai-agent", agentCtx.AuditSource)
+}
+
+func TestCheckNamespacedTarget(t *testing.T) {
+	tc := newToolContextFromAgent(&v1alpha1.Agent{ObjectMeta: metav1.ObjectMeta{Name: "fixer"}}, []string{"prod"}).autoApprovedContext()
+	tc.services.AvailableResources = func() ([]utils.ResourceDescriptor, error) {
+		return []utils.ResourceDescriptor{
+			{Kind: "ConfigMap", Plural: "configmaps", ApiVersion: "v1", Namespaced: true},
+			{Kind: "ClusterRoleBinding", Plural: "clusterrolebindings", ApiVersion: "rbac.authorization.k8s.io/v1"},
+		}, nil
+	}
+
+	assert.Empty(t, checkNamespacedTarget(tc, "v1", "configmaps", true))
+	assert.Contains(t, checkNamespacedTarget(tc, "v1", "configmaps", false), "cluster-scoped")
+	assert.Contains(t, checkNamespacedTarget(tc, "rbac.authorization.k8s.io/v1", "clusterrolebindings", true), "cluster-scoped")
+	assert.Contains(t, checkNamespacedTarget(tc, "example.com/v1", "widgets", true), "unknown resource")
+	assert.Empty(t, checkNamespacedTarget(&ToolContext{}, "rbac.authorization.k8s.io/v1", "clusterrolebindings", false), "unrestricted contexts may write cluster-scoped resources")
+
+	// the namespace in the manifest must not let a cluster-scoped write through
+	result := createKubernetesResourceTool(map[string]any{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"plural":     "clusterrolebindings",
+		"namespaced": false,
+		"yamlData":   "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRoleBinding\nmetadata:\n  name: takeover\n  namespace: prod\n",
+	}, tc, nil, slog.New(slog.DiscardHandler))
+	assert.Contains(t, result, "cluster-scoped")
+}
+
+func TestBuiltinToolTargetVersion(t *testing.T) {
+	prevGet, prevHelm := K8sGetUnstructuredResource, helmReleaseRevision
+	defer func() { K8sGetUnstructuredResource, helmReleaseRevision = prevGet, prevHelm }()
+
+	versions := map[string]string{"apps/v1|deployments|prod|web": "100"}
+	K8sGetUnstructuredResource = func(apiVersion, plural, namespace, name string) (*unstructured.Unstructured, error) {
+		rv, ok := versions[apiVersion+"|"+plural+"|"+namespace+"|"+name]
+		if !ok {
+			return nil, assert.AnError
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetResourceVersion(rv)
+		return obj, nil
+	}
+	revision := 3
+	helmReleaseRevision = func(namespace, release string) (int, error) { return revision, nil }
+
+	updateArgs := map[string]any{
+		"apiVersion": "apps/v1",
+		"plural":     "deployments",
+		"namespaced": true,
+		"yamlData":   "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: prod\n",
+	}
+	deleteArgs := map[string]any{"apiVersion": "apps/v1", "plural": "deployments", "namespace": "prod", "name": "web"}
+	rollbackArgs := map[string]any{"namespace": "prod", "release": "shop", "revision": float64(2)}
+
+	assert.Equal(t, "100", builtinToolTargetVersion("update_kubernetes_resource", updateArgs))
+	assert.Equal(t, "100", builtinToolTargetVersion("delete_kubernetes_resource", deleteArgs))
+	assert.Equal(t, "revision 3", builtinToolTargetVersion("helm_release_rollback", rollbackArgs))
+	assert.Empty(t, builtinToolTargetVersion("create_kubernetes_resource", updateArgs), "creates have no existing target")
+
+	assert.NoError(t, checkBuiltinToolTargetVersion("update_kubernetes_resource", updateArgs, "100"))
+	assert.NoError(t, checkBuiltinToolTargetVersion("create_kubernetes_resource", updateArgs, ""))
+
+	versions["apps/v1|deployments|prod|web"] = "101"
+	assert.ErrorContains(t, checkBuiltinToolTargetVersion("update_kubernetes_resource", updateArgs, "100"), "changed since the proposal")
+	delete(versions, "apps/v1|deployments|prod|web")
+	assert.ErrorContains(t, checkBuiltinToolTargetVersion("delete_kubernetes_resource", deleteArgs, "100"), "no longer exists")
+
+	revision = 4
+	assert.ErrorContains(t, checkBuiltinToolTargetVersion("helm_release_rollback", rollbackArgs, "revision 3"), "revision 4")
+}
+
 func TestUpdateTaskStateWhitelist(t *testing.T) {
 	ai := &aiManager{}
 
@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"cmp"
+	"fmt"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/security"
+	"slices"
+	"strings"
+	"time"
+)
+
+// validVulnerabilitySeverities are the severities an onVulnerability trigger
+// may start at.
+var validVulnerabilitySeverities = map[string]bool{security.SeverityCritical: true, security.SeverityHigh: true}
+
+// maxVulnerabilitiesInPrompt caps the vulnerabilities listed in a run's prompt.
+const maxVulnerabilitiesInPrompt = 20
+
+// ProcessImageVulnerabilities enqueues a run for every enabled agent with a
+// vulnerability trigger when one of the images holds a vulnerability of at
+// least the trigger's severity and runs in the agent's scope. images carry
+// only the workloads and vulnerabilities the image scan found there for the
+// first time. Called by the image scanner on the leader.
+func (ai *aiManager) ProcessImageVulnerabilities(images []security.Image) {
+	for _, agent := range ai.getEnabledAgents() {
+		vt := agent.Spec.Triggers.OnVulnerability
+		if vt == nil {
+			continue
+		}
+		namespaces := ai.resolveAgentScope(&agent)
+		affected := vulnerableImagesInScope(images, namespaces, vulnerabilityMinSeverity(vt))
+		if len(affected) == 0 {
+			continue
+		}
+		if !ai.agentCooldownElapsed(agent.Name, vulnerabilityCooldown(vt)) {
+			continue
+		}
+		agentCopy := agent
+		if _, err := ai.enqueueAgentRun(&agentCopy, namespaces, AI_TASK_TRIGGER_VULNERABILITY, nil, buildAgentVulnerabilityPrompt(&agentCopy, namespaces, affected)); err != nil {
+			// An already-open run or empty scope is expected/benign here.
+			ai.logger.Info("Vulnerability trigger did not enqueue a run", "agent", agent.Name, "reason", err.Error())
+			continue
+		}
+		ai.logger.Info("Vulnerability trigger enqueued a run", "agent", agent.Name, "images", len(affected))
+	}
+}
+
+// vulnerabilityMinSeverity returns the lowest severity an agent's
+// vulnerability trigger fires at.
+func vulnerabilityMinSeverity(vt *v1alpha1.AgentVulnerabilityTrigger) string {
+	return cmp.Or(vt.MinSeverity, security.SeverityCritical)
+}
+
+// vulnerabilityCooldown returns the effective cooldown of an agent's
+// vulnerability trigger.
+func vulnerabilityCooldown(vt *v1alpha1.AgentVulnerabilityTrigger) time.Duration {
+	if vt != nil && vt.MinInterval.Duration > 0 {
+		return vt.MinInterval.Duration
+	}
+	return defaultChangeCooldown
+}
+
+// vulnerableImagesInScope keeps the images running in the scope, reduced to
+// their in-scope workloads and their vulnerabilities of at least minSeverity.
+func vulnerableImagesInScope(images []security.Image, scope []string, minSeverity string) []security.Image {
+	minRank := security.SeverityRank(minSeverity)
+	result := []security.Image{}
+	for _, image := range images {
+		image.Workloads = slices.DeleteFunc(slices.Clone(image.Workloads), func(workload security.ImageWorkload) bool {
+			return !namespaceSelected(scope, workload.Namespace)
+		})
+		image.Vulnerabilities = slices.DeleteFunc(slices.Clone(image.Vulnerabilities), func(vulnerability security.ImageVulnerability) bool {
+			return security.SeverityRank(vulnerability.Severity) < minRank
+		})
+		if len(image.Workloads) == 0 || len(image.Vulnerabilities) == 0 {
+			continue
+		}
+		result = append(result, image)
+	}
+	return result
+}
+
+// buildAgentVulnerabilityPrompt is the user prompt for vulnerability-triggered
+// runs: per image the new vulnerabilities (worst first) and the workloads
+// running it.
+func buildAgentVulnerabilityPrompt(agent *v1alpha1.Agent, namespaces []string, images []security.Image) string {
+	var sb strings.Builder
+	sb.WriteString("Your scope for this run includes the following Kubernetes namespaces: ")
+	sb.WriteString(strings.Join(namespaces, ", "))
+	sb.WriteString(". You operate in read-only mode by default; any mutation requires explicit approval.")
+
+	sb.WriteString("\n\nThis run was triggered by newly found vulnerabilities in container images running in your scope:\n")
+	listed := 0
+	total := 0
+	for _, image := range images {
+		total += len(image.Vulnerabilities)
+		if listed >= maxVulnerabilitiesInPrompt {
+			continue
+		}
+		workloads := make([]string, 0, len(image.Workloads))
+		for _, workload := range image.Workloads {
+			workloads = append(workloads, fmt.Sprintf("%s %s/%s", workload.Kind, workload.Namespace, workload.Name))
+		}
+		fmt.Fprintf(&sb, "\nImage %s (used by %s):", image.Image, strings.Join(workloads, ", "))
+
+		vulnerabilities := slices.Clone(image.Vulnerabilities)
+		security.SortVulnerabilities(vulnerabilities)
+		for _, vulnerability := range vulnerabilities[:min(len(vulnerabilities), maxVulnerabilitiesInPrompt-listed)] {
+			fixed := "no fix available"
+			if vulnerability.FixedVersion != "" {
+				fixed = "fixed in " + vulnerability.FixedVersion
+			}
+			fmt.Fprintf(&sb, "\n- %s (%s) in %s %s, %s", vulnerability.Id, vulnerability.Severity, vulnerability.Package, vulnerability.Version, fixed)
+			if vulnerability.Summary != "" {
+				fmt.Fprintf(&sb, ": %s", vulnerability.Summary)
+			}
+			listed++
+		}
+	}
+	if total > listed {
+		fmt.Fprintf(&sb, "\n- … and %d more", total-listed)
+	}
+
+	if agent.Spec.Instruction != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(agent.Spec.Instruction)
+	}
+	return sb.String()
+}
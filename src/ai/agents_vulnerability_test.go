@@ -0,0 +1,73 @@
+package ai
+
+import (
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/security"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVulnerableImagesInScope(t *testing.T) {
+	images := []security.Image{{
+		Key:   "sha256:1",
+		Image: "registry.example.com/shop/api:1.2",
+		Workloads: []security.ImageWorkload{
+			{Namespace: "shop", Kind: "Deployment", Name: "api"},
+			{Namespace: "blog", Kind: "Deployment", Name: "api"},
+		},
+		Vulnerabilities: []security.ImageVulnerability{
+			{Id: "CVE-2024-0001", Severity: security.SeverityCritical, Package: "openssl"},
+			{Id: "CVE-2024-0002", Severity: security.SeverityHigh, Package: "zlib"},
+		},
+	}, {
+		Key:             "sha256:2",
+		Image:           "nginx:1.25",
+		Workloads:       []security.ImageWorkload{{Namespace: "blog", Kind: "Deployment", Name: "web"}},
+		Vulnerabilities: []security.ImageVulnerability{{Id: "CVE-2024-0003", Severity: security.SeverityHigh}},
+	}}
+
+	result := vulnerableImagesInScope(images, []string{"shop"}, vulnerabilityMinSeverity(&v1alpha1.AgentVulnerabilityTrigger{}))
+	require.Len(t, result, 1)
+	assert.Equal(t, []security.ImageWorkload{{Namespace: "shop", Kind: "Deployment", Name: "api"}}, result[0].Workloads)
+	assert.Equal(t, []security.ImageVulnerability{{Id: "CVE-2024-0001", Severity: security.SeverityCritical, Package: "openssl"}}, result[0].Vulnerabilities)
+	assert.Len(t, images[0].Workloads, 2, "the input is not modified")
+
+	assert.Len(t, vulnerableImagesInScope(images, []string{"*"}, security.SeverityHigh), 2)
+	assert.Len(t, vulnerableImagesInScope(images, []string{"blog"}, security.SeverityCritical), 1)
+	assert.Empty(t, vulnerableImagesInScope(images, []string{"default"}, security.SeverityHigh))
+}
+
+func TestVulnerabilityTriggerDefaults(t *testing.T) {
+	assert.Equal(t, defaultChangeCooldown, vulnerabilityCooldown(&v1alpha1.AgentVulnerabilityTrigger{}))
+	assert.Equal(t, security.SeverityHigh, vulnerabilityMinSeverity(&v1alpha1.AgentVulnerabilityTrigger{MinSeverity: "high"}))
+}
+
+func TestBuildAgentVulnerabilityPrompt(t *testing.T) {
+	agent := &v1alpha1.Agent{
+		ObjectMeta: metav1.ObjectMeta{Name: "sec-agent"},
+		Spec:       v1alpha1.AgentSpec{Instruction: "propose an image update"},
+	}
+	vulnerabilities := []security.ImageVulnerability{
+		{Id: "CVE-2024-0002", Severity: security.SeverityHigh, Package: "zlib", Version: "1.2.13"},
+		{Id: "CVE-2024-0001", Severity: security.SeverityCritical, Package: "openssl", Version: "3.0.11", FixedVersion: "3.0.13", Summary: "remote code execution"},
+	}
+	for i := range maxVulnerabilitiesInPrompt {
+		vulnerabilities = append(vulnerabilities, security.ImageVulnerability{Id: "CVE-2023-" + strings.Repeat("9", i+1), Severity: security.SeverityLow})
+	}
+	prompt := buildAgentVulnerabilityPrompt(agent, []string{"shop"}, []security.Image{{
+		Image:           "registry.example.com/shop/api:1.2",
+		Workloads:       []security.ImageWorkload{{Namespace: "shop", Kind: "Deployment", Name: "api"}},
+		Vulnerabilities: vulnerabilities,
+	}})
+
+	assert.Contains(t, prompt, "Image registry.example.com/shop/api:1.2 (used by Deployment shop/api)")
+	assert.Contains(t, prompt, "- CVE-2024-0001 (critical) in openssl 3.0.11, fixed in 3.0.13: remote code execution")
+	assert.Contains(t, prompt, "- CVE-2024-0002 (high) in zlib 1.2.13, no fix available")
+	assert.Less(t, strings.Index(prompt, "CVE-2024-0001"), strings.Index(prompt, "CVE-2024-0002"), "worst first")
+	assert.Contains(t, prompt, "… and 2 more")
+	assert.Contains(t, prompt, "propose an image update")
+}
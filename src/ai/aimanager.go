@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"mogenius-operator/src/assert"
 	cfg "mogenius-operator/src/config"
 	"mogenius-operator/src/crds/v1alpha1"
 	"mogenius-operator/src/metrics"
+	"mogenius-operator/src/security"
 	"mogenius-operator/src/store"
 	"mogenius-operator/src/structs"
 	"mogenius-operator/src/utils"
@@ -85,7 +87,7 @@ type AiTask struct {
 	Error               string                      `json:"error"`
 
 	AgentRef        string        `json:"agentRef,omitempty"`        // name of the Agent CR this task belongs to
-	Trigger         string        `json:"trigger,omitempty"`         // "event", "cron" or "manual"
+	Trigger         string        `json:"trigger,omitempty"`         // "event", "cron", "manual", "metric" or "vulnerability"
 	TriggeredByUser *structs.User `json:"triggeredByUser,omitempty"` // set for manual triggers
 
 	// ScopeNamespaces snapshots the agent's resolved scope at enqueue time.
@@ -97,8 +99,9 @@ type AiTask struct {
 	// the run was enqueued.
 	ScopeAllNamespaces bool `json:"scopeAllNamespaces,omitempty"`
 
-	// BaseResourceVersion is the target resource's resourceVersion at proposal
-	// time; approval refuses to execute when the resource changed since.
+	// BaseResourceVersion is the target resource's resourceVersion (a Helm
+	// release's revision for release tools) at proposal time; approval refuses
+	// to execute when the target changed since.
 	BaseResourceVersion string          `json:"baseResourceVersion,omitempty"`
 	Approval            *ApprovalRecord `json:"approval,omitempty"`
 	ExecutionResult     string          `json:"executionResult,omitempty"`
@@ -258,6 +261,9 @@ type ModelsRequest struct {
 }
 type AiManager interface {
 	ProcessObject(obj *unstructured.Unstructured, eventType string, resource utils.ResourceDescriptor) // eventType can be "add", "update", "delete"
+	// Link wires the services the built-in tools call into and the callback
+	// run with every task pushed to the event server.
+	Link(toolServices ToolServices, onTaskEvent func(task AiTask))
 	Run()
 	UpdateTaskState(taskID string, newState AiTaskState) error
 	UpdateTaskReadState(taskID string, user *structs.User) error
@@ -268,6 +274,11 @@ type AiManager interface {
 	// GetRun assembles one agent run (metadata from the primary task, the
 	// recorded ReAct steps and the IDs of all finding tasks of the run).
 	GetRun(runID string) (*AiRun, error)
+	// ExportRun returns the replayable bundle of a finished run.
+	ExportRun(runID string) (*AiRunBundle, error)
+	// ReplayRun replays a bundle (see ReplayRunBundle), optionally with the
+	// system prompt currently injected into this operator.
+	ReplayRun(ctx context.Context, bundle AiRunBundle, opts AiRunReplayOptions, useCurrentSystemPrompt bool) (*AiRunReplayReport, error)
 	InjectAiPromptConfig(prompt AiPromptConfig, aiPrompts *AiPrompts)
 	GetStatus(workspace *string) AiManagerStatus
 	// ResetTokenUsageForModel zeroes today's recorded token usage of one
@@ -311,6 +322,11 @@ type AiManager interface {
 	// with its effective execution policy. Used by the reconciler to populate
 	// McpServerStatus.ToolsWithPolicies. Returns nil when no session exists.
 	GetMcpToolsWithPolicies(serverName string) []v1alpha1.MCPToolWithPolicy
+
+	// ProcessImageVulnerabilities starts the runs of agents with a
+	// vulnerability trigger for vulnerabilities the image scan found for the
+	// first time. Called by the image scanner.
+	ProcessImageVulnerabilities(images []security.Image)
 }
 
 type SecretGetter func(namespace, name string) (*coreV1.Secret, error)
@@ -337,10 +353,20 @@ type aiManager struct {
 	// lastAgentRun: when a run was last enqueued per agent (any trigger),
 	// used as the change-trigger cooldown base. In-memory (leader only).
 	lastAgentRun map[string]time.Time
+	// metricAnomalies holds the per-workload metric baselines of the
+	// onMetric triggers. In-memory (leader only) — baselines are re-learned
+	// after a restart or leader change.
+	metricAnomalies *anomalyDetector
 	// isLeading gates cron evaluation to the leading replica; event-triggered
 	// tasks are already deduplicated via their Valkey key.
 	isLeading func() bool
 
+	// toolServices are handed to every built-in tool call.
+	toolServices ToolServices
+	// onTaskEvent is called with every task pushed to the event server as an
+	// AiProcessEvent. The same state can be reported more than once.
+	onTaskEvent func(task AiTask)
+
 	// taskQueueKick wakes the queue loop as soon as a task lands in pending
 	// state, so new reports start immediately instead of waiting for the next
 	// 1-minute tick. Buffered(1): kicks during a running pass coalesce into
@@ -364,6 +390,10 @@ type aiManager struct {
 	// Runs waiting for approval hold a slot but do not block the queue loop.
 	runSem chan struct{}
 
+	// runReplay is only set on the throwaway manager of ReplayRunBundle; tool
+	// calls are then answered from the recorded bundle.
+	runReplay *runReplay
+
 	// prompts
 	chatPromptMu sync.RWMutex
 	aiPrompts    AiPrompts
@@ -484,6 +514,7 @@ func NewAiManager(logger *slog.Logger, valkeyClient valkeyclient.ValkeyClient, c
 	self.mcpManager = newMCPClientManager(logger)
 	self.lastCronRun = make(map[string]time.Time)
 	self.lastAgentRun = make(map[string]time.Time)
+	self.metricAnomalies = newAnomalyDetector()
 	self.isLeading = isLeading
 	self.taskQueueKick = make(chan struct{}, 1)
 	self.runCancels = make(map[string]context.CancelFunc)
@@ -533,6 +564,17 @@ func (ai *aiManager) ProcessObject(obj *unstructured.Unstructured, eventType str
 }
 
 // BACKGROUND PROCESSING
+func (ai *aiManager) Link(toolServices ToolServices, onTaskEvent func(task AiTask)) {
+	assert.Assert(toolServices.Policies != nil)
+	assert.Assert(toolServices.ListSecurityFindings != nil)
+	assert.Assert(toolServices.GetUpgradeReadiness != nil)
+	assert.Assert(toolServices.AvailableResources != nil)
+	assert.Assert(onTaskEvent != nil)
+
+	ai.toolServices = toolServices
+	ai.onTaskEvent = onTaskEvent
+}
+
 func (ai *aiManager) Run() {
 	// On startup, reset any potentially orphaned in-progress tasks back to pending
 	if err := ai.resetInProgressTasksOnStartup(); err != nil {
@@ -578,6 +620,7 @@ func (ai *aiManager) runQueuePass(includeCron bool) {
 	// their per-run keys are not deduplicated across replicas.
 	if includeCron && ai.isLeading != nil && ai.isLeading() {
 		ai.processAgentCronTriggers()
+		ai.processAgentMetricTriggers()
 	}
 
 	ai.setError("")
@@ -1188,8 +1231,12 @@ func (ai *aiManager) runOneTask(ctx context.Context, task AiTask, key string, rc
 	// Steps are keyed by the run id (== primary task ID) so the timeline
 	// survives even when the run later spawns finding tasks.
 	recordStep := ai.newStepRecorder(task.ID)
+	// The full transcript for export/replay; a retry replaces the recording
+	// of the failed attempt.
+	recording := ai.newRunRecording()
 
-	tokensUsed, timeUsedInMs, modelUsed, err := ai.processPrompt(taskCtx, rc, task.Prompt, toolCtx, &agent.Spec, onProgress, recordStep)
+	tokensUsed, timeUsedInMs, modelUsed, err := ai.processPrompt(taskCtx, rc, task.Prompt, toolCtx, &agent.Spec, onProgress, recordStep, recording)
+	ai.saveRunRecording(task.ID, recording, err)
 	ai.unregisterRunCancel(task.ID)
 	cancelTask()
 	task.CurrentActivity = ""
@@ -1347,7 +1394,8 @@ func latestTaskNamespaces(task *AiTask, key string) []string {
 // MCP tools with needsApprove policy are intercepted and turned into PROPOSED
 // tasks instead of being executed directly. The primary run always completes
 // without findings — proposals arrive as independent tasks via CreateApprovalRequest.
-func (ai *aiManager) processPrompt(ctx context.Context, rc *ResolvedModelConfig, prompt string, toolCtx *ToolContext, _ *v1alpha1.AgentSpec, onProgress func(tokensUsed int64, activity string), recordStep StepRecorder) (tokensUsed int64, timeUsedInMs int, modelUsed string, err error) {
+// A non-nil recording captures the full transcript for export and replay.
+func (ai *aiManager) processPrompt(ctx context.Context, rc *ResolvedModelConfig, prompt string, toolCtx *ToolContext, _ *v1alpha1.AgentSpec, onProgress func(tokensUsed int64, activity string), recordStep StepRecorder, recording *aiRunRecording) (tokensUsed int64, timeUsedInMs int, modelUsed string, err error) {
 	startTime := time.Now()
 	systemPrompt := ai.getSystemPrompt()
 	model := rc.Model
@@ -1363,8 +1411,9 @@ func (ai *aiManager) processPrompt(ctx context.Context, rc *ResolvedModelConfig,
 	}
 	tools := buildAgentTools(ai.mcpManager, mcpSessions, toolCtx)
 	messages := buildAgentMessages(systemPrompt, prompt)
+	recording.start(model, messages, tools, rc.MaxToolCalls, rc.MaxTokensPerRun)
 
-	tokens, runErr := ai.runAgentLoop(ctx, provider, model, messages, tools, toolCtx, mcpSessions, rc.MaxToolCalls, rc.MaxTokensPerRun, onProgress, recordStep)
+	tokens, runErr := ai.runAgentLoop(ctx, recording.wrapProvider(provider), model, messages, tools, toolCtx, mcpSessions, rc.MaxToolCalls, rc.MaxTokensPerRun, onProgress, recording.wrapSteps(recordStep))
 	return tokens, int(time.Since(startTime).Milliseconds()), model, runErr
 }
 
@@ -1583,7 +1632,13 @@ func (ai *aiManager) sendAiEvent(task *AiTaskLatest) {
 		},
 		CreatedAt: time.Now(),
 	}
+	if task.Task != nil {
+		datagram = datagram.WithCoalesceKey("ai:" + task.Task.ID)
+	}
 	structs.ReportEventToServer(ai.eventClient, datagram)
+	if cb := ai.onTaskEvent; cb != nil && task.Task != nil {
+		cb(*task.Task)
+	}
 }
 
 func (ai *aiManager) sendAiDeleteEvent(taskId string) {
@@ -516,6 +516,7 @@ func (ai *aiManager) DeleteAllAiData() error {
 		DB_AI_BUCKET_TOKENS + ":*",
 		DB_AI_BUCKET_TASKS_LATEST + ":*",
 		DB_AI_BUCKET_RUN_STEPS + ":*",
+		DB_AI_BUCKET_RUN_RECORDINGS + ":*",
 	}
 	ai.resetCache()
 	err := ai.valkeyClient.DeleteMultiple(prefixes...)
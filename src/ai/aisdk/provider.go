@@ -13,44 +13,44 @@ const (
 
 // Tool is a provider-neutral tool definition.
 type Tool struct {
-	Name        string
-	Description string
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
 	// InputSchema is the properties map (JSON-schema format): {"propName": {"type": "string", "description": "..."}}
-	InputSchema map[string]any
-	Required    []string
+	InputSchema map[string]any `json:"inputSchema,omitempty"`
+	Required    []string       `json:"required,omitempty"`
 }
 
 // Message is a provider-agnostic chat message.
 type Message struct {
-	Role    Role
-	Content string
+	Role    Role   `json:"role"`
+	Content string `json:"content,omitempty"`
 	// ToolCallID and ToolName are populated for RoleTool messages.
-	ToolCallID string
-	ToolName   string
+	ToolCallID string `json:"toolCallId,omitempty"`
+	ToolName   string `json:"toolName,omitempty"`
 	// ToolCalls is populated for RoleAssistant messages that request tool execution.
-	ToolCalls []ToolCall
+	ToolCalls []ToolCall `json:"toolCalls,omitempty"`
 	// CacheControl marks this message for prompt caching. Used by the Anthropic
 	// provider only; ignored by other providers.
-	CacheControl bool
+	CacheControl bool `json:"cacheControl,omitempty"`
 }
 
 // ToolCall is a tool invocation requested by the model in an assistant message.
 type ToolCall struct {
-	ID        string
-	Name      string
-	Arguments string // JSON-encoded arguments
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
 }
 
 // Response is the result of a non-streaming chat call.
 type Response struct {
-	Content      string
-	ToolCalls    []ToolCall
-	InputTokens  int64
-	OutputTokens int64
-	FinishReason string
+	Content      string     `json:"content,omitempty"`
+	ToolCalls    []ToolCall `json:"toolCalls,omitempty"`
+	InputTokens  int64      `json:"inputTokens"`
+	OutputTokens int64      `json:"outputTokens"`
+	FinishReason string     `json:"finishReason,omitempty"`
 	// CacheReadTokens is populated by the Anthropic provider for prompt-cache
 	// reads; these do not count against the regular token budget.
-	CacheReadTokens int64
+	CacheReadTokens int64 `json:"cacheReadTokens,omitempty"`
 }
 
 // StreamChunk is one event delivered during a streaming chat call.
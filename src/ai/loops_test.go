@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"mogenius-operator/src/ai/aisdk"
+	"mogenius-operator/src/crds/v1alpha1"
 
 	valkeyclient "github.com/valkey-io/valkey-go"
 	"github.com/stretchr/testify/assert"
@@ -65,6 +66,9 @@ func (noopValkeyClient) Close()                            {}
 func (noopValkeyClient) Set(_ string, _ time.Duration, _ ...string) error {
 	return nil
 }
+func (noopValkeyClient) SetIfNotExists(_ string, _ time.Duration, _ ...string) (bool, error) {
+	return true, nil
+}
 func (noopValkeyClient) SetObject(_ any, _ time.Duration, _ ...string) error { return nil }
 func (noopValkeyClient) SetObjectWithAutoincrementLimit(_ any, _ int64, _ time.Duration, _ ...string) (string, error) {
 	return "", nil
@@ -76,6 +80,9 @@ func (noopValkeyClient) DeleteFromSortedListWithNsAndReleaseName(_ string, _ str
 	return nil
 }
 func (noopValkeyClient) StoreSortedListEntry(_ any, _ int64, _ ...string) error { return nil }
+func (noopValkeyClient) StoreSortedListEntryWithRetention(_ any, _ int64, _ time.Duration, _ ...string) error {
+	return nil
+}
 func (noopValkeyClient) ClearNonEssentialKeys(_, _ bool, _ bool) (string, error) {
 	return "", nil
 }
@@ -146,6 +153,19 @@ func TestBuildAgentTools_DisableHelm(t *testing.T) {
 	assert.Contains(t, names, "get_kubernetes_resources")
 }
 
+func TestBuildAgentTools_DeniedToolsExcluded(t *testing.T) {
+	tools := buildAgentTools(&mcpClientManager{}, nil, &ToolContext{BuiltinToolPolicies: map[string]v1alpha1.MCPToolPolicyType{
+		"delete_kubernetes_resource": v1alpha1.MCPToolPolicyDeny,
+		"helm_release_uninstall":     v1alpha1.MCPToolPolicyDeny,
+		"helm_release_rollback":      v1alpha1.MCPToolPolicyNeedsApprove,
+	}})
+	names := toolNames(tools)
+	assert.NotContains(t, names, "delete_kubernetes_resource")
+	assert.NotContains(t, names, "helm_release_uninstall")
+	assert.Contains(t, names, "helm_release_rollback")
+	assert.Contains(t, names, "update_kubernetes_resource")
+}
+
 func TestBuildAgentTools_BothDisabledYieldsEmpty(t *testing.T) {
 	tools := buildAgentTools(&mcpClientManager{}, nil, &ToolContext{DisableKubernetes: true, DisableHelm: true})
 	assert.Empty(t, tools)
@@ -0,0 +1,278 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"mogenius-operator/src/ai/aisdk"
+)
+
+const DB_AI_BUCKET_RUN_RECORDINGS = "ai_run_recordings"
+
+// AiRunBundleVersion is bumped on incompatible changes of AiRunBundle so a
+// replay never misreads a bundle exported by another operator version.
+const AiRunBundleVersion = 1
+
+// defaultRunRecordingMaxBytes applies when MO_AI_RUN_RECORDING_MAX_BYTES is
+// not declared (tests).
+const defaultRunRecordingMaxBytes = 4 << 20
+
+// AiRunBundle is the complete, untruncated record of one agent run: the
+// initial conversation, the offered tools, every model response in call order
+// and every tool result. Unlike the step timeline it holds enough to replay
+// the run without a model or a cluster (see ReplayRunBundle).
+type AiRunBundle struct {
+	Version    int   `json:"version"`
+	ExportedAt int64 `json:"exportedAt,omitempty"`
+	// Run is the run view (metadata and step timeline) at export time.
+	Run *AiRun `json:"run,omitempty"`
+
+	Model string `json:"model"`
+	// Messages is the initial conversation: system prompt and user prompt.
+	Messages []aisdk.Message `json:"messages"`
+	Tools    []aisdk.Tool    `json:"tools"`
+
+	MaxToolCalls    int   `json:"maxToolCalls"`
+	MaxTokensPerRun int64 `json:"maxTokensPerRun"`
+	// ContextWindowTokens as reported by the provider; 0 when it could not
+	// be determined (compaction was disabled for the run).
+	ContextWindowTokens int64 `json:"contextWindowTokens,omitempty"`
+
+	Exchanges []AiRunExchange `json:"exchanges"`
+	ToolCalls []AiRunToolCall `json:"toolCalls"`
+
+	// Error is the error the run ended with; empty for a completed run.
+	Error string `json:"error,omitempty"`
+	// Truncated is set when the run outgrew MO_AI_RUN_RECORDING_MAX_BYTES;
+	// exchanges and tool calls after that point are missing.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// AiRunExchange is one model call of a run and its response.
+type AiRunExchange struct {
+	// Compaction marks a conversation-compaction call rather than a turn of
+	// the agent loop.
+	Compaction bool `json:"compaction,omitempty"`
+	// RequestMessages and RequestBytes describe the request the response
+	// answered (bytes as estimated by estimateAiSDKMessageBytes).
+	RequestMessages int            `json:"requestMessages"`
+	RequestBytes    int            `json:"requestBytes"`
+	Response        aisdk.Response `json:"response"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// AiRunToolCall is one executed tool call with its full result.
+type AiRunToolCall struct {
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Result    string          `json:"result"`
+	Status    AiRunStepStatus `json:"status,omitempty"`
+}
+
+func runRecordingKey(runID string) string {
+	return DB_AI_BUCKET_RUN_RECORDINGS + ":" + runID
+}
+
+// isCompactionRequest reports whether a model request is the summarization
+// call of compactMessagesWithAI.
+func isCompactionRequest(messages []aisdk.Message) bool {
+	return len(messages) > 0 && messages[0].Role == aisdk.RoleSystem && messages[0].Content == compactionSystemPrompt
+}
+
+// aiRunRecording collects the bundle of one run attempt in memory; it is
+// written to Valkey once when the attempt ends (saveRunRecording).
+type aiRunRecording struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	bundle   AiRunBundle
+}
+
+// newRunRecording returns nil when recording is disabled
+// (MO_AI_RUN_RECORDING_MAX_BYTES=0). All methods accept a nil recording.
+func (ai *aiManager) newRunRecording() *aiRunRecording {
+	maxBytes := int64(defaultRunRecordingMaxBytes)
+	if ai.config != nil {
+		if value, err := ai.config.TryGetInt("MO_AI_RUN_RECORDING_MAX_BYTES"); err == nil {
+			maxBytes = int64(value)
+		}
+	}
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &aiRunRecording{maxBytes: int(maxBytes), bundle: AiRunBundle{Version: AiRunBundleVersion}}
+}
+
+// start records the run setup. Called once before the agent loop.
+func (r *aiRunRecording) start(model string, messages []aisdk.Message, tools []aisdk.Tool, maxToolCalls int, maxTokensPerRun int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bundle.Model = model
+	r.bundle.Messages = append([]aisdk.Message(nil), messages...)
+	r.bundle.Tools = tools
+	r.bundle.MaxToolCalls = maxToolCalls
+	r.bundle.MaxTokensPerRun = maxTokensPerRun
+	r.bytes = estimateAiSDKMessageBytes(messages)
+}
+
+// reserve accounts size bytes against the cap; once exceeded the recording
+// is marked truncated and takes nothing more.
+func (r *aiRunRecording) reserve(size int) bool {
+	if r.bundle.Truncated {
+		return false
+	}
+	if r.bytes+size > r.maxBytes {
+		r.bundle.Truncated = true
+		return false
+	}
+	r.bytes += size
+	return true
+}
+
+func (r *aiRunRecording) addExchange(messages []aisdk.Message, resp aisdk.Response, err error) {
+	if r == nil {
+		return
+	}
+	exchange := AiRunExchange{
+		Compaction:      isCompactionRequest(messages),
+		RequestMessages: len(messages),
+		RequestBytes:    estimateAiSDKMessageBytes(messages),
+		Response:        resp,
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reserve(len(resp.Content) + estimateAiSDKMessageBytes([]aisdk.Message{{ToolCalls: resp.ToolCalls}}) + len(exchange.Error)) {
+		r.bundle.Exchanges = append(r.bundle.Exchanges, exchange)
+	}
+}
+
+func (r *aiRunRecording) addToolCall(call AiRunToolCall) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reserve(len(call.Arguments) + len(call.Result)) {
+		r.bundle.ToolCalls = append(r.bundle.ToolCalls, call)
+	}
+}
+
+func (r *aiRunRecording) setContextWindow(tokens int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bundle.ContextWindowTokens = tokens
+}
+
+// wrapProvider returns provider with every Chat call recorded. The cache
+// breakpoint capability of the Anthropic provider is preserved.
+func (r *aiRunRecording) wrapProvider(provider aisdk.Provider) aisdk.Provider {
+	if r == nil {
+		return provider
+	}
+	wrapped := &recordingProvider{Provider: provider, recording: r}
+	if mover, ok := provider.(aisdk.CacheBreakpointMover); ok {
+		return &recordingMoverProvider{recordingProvider: wrapped, CacheBreakpointMover: mover}
+	}
+	return wrapped
+}
+
+// wrapSteps returns recordStep with every finished tool call recorded.
+func (r *aiRunRecording) wrapSteps(recordStep StepRecorder) StepRecorder {
+	if r == nil {
+		return recordStep
+	}
+	return &recordingStepRecorder{StepRecorder: recordStep, recording: r}
+}
+
+type recordingProvider struct {
+	aisdk.Provider
+	recording *aiRunRecording
+}
+
+func (p *recordingProvider) Chat(ctx context.Context, model string, messages []aisdk.Message, tools []aisdk.Tool) (aisdk.Response, error) {
+	resp, err := p.Provider.Chat(ctx, model, messages, tools)
+	p.recording.addExchange(messages, resp, err)
+	return resp, err
+}
+
+func (p *recordingProvider) ContextWindowTokens(ctx context.Context, model string) (int64, error) {
+	tokens, err := p.Provider.ContextWindowTokens(ctx, model)
+	if err == nil {
+		p.recording.setContextWindow(tokens)
+	}
+	return tokens, err
+}
+
+type recordingMoverProvider struct {
+	*recordingProvider
+	aisdk.CacheBreakpointMover
+}
+
+type recordingStepRecorder struct {
+	StepRecorder
+	recording *aiRunRecording
+}
+
+func (s *recordingStepRecorder) ToolCall(tool string, args string) StepFinalizer {
+	finalize := s.StepRecorder.ToolCall(tool, args)
+	return func(status AiRunStepStatus, label string, result string) {
+		s.recording.addToolCall(AiRunToolCall{Name: tool, Arguments: args, Result: result, Status: status})
+		finalize(status, label, result)
+	}
+}
+
+// saveRunRecording persists the recording of a finished run attempt under
+// the run id, replacing the recording of an earlier attempt.
+func (ai *aiManager) saveRunRecording(runID string, r *aiRunRecording, runErr error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	if runErr != nil {
+		r.bundle.Error = runErr.Error()
+	}
+	payload, err := json.Marshal(r.bundle)
+	r.mu.Unlock()
+	if err != nil {
+		ai.logger.Warn("Failed to marshal AI run recording", "runID", runID, "error", err)
+		return
+	}
+	if err := ai.valkeyClient.Set(string(payload), ValkeyAiTTL, runRecordingKey(runID)); err != nil {
+		ai.logger.Warn("Failed to persist AI run recording", "runID", runID, "error", err)
+	}
+}
+
+// ExportRun returns the replayable bundle of a finished run together with
+// its run view.
+func (ai *aiManager) ExportRun(runID string) (*AiRunBundle, error) {
+	run, err := ai.GetRun(runID)
+	if err != nil {
+		return nil, err
+	}
+	item, err := ai.valkeyClient.Get(runRecordingKey(runID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recording of run %s: %w", runID, err)
+	}
+	if item == "" {
+		return nil, fmt.Errorf("run %s has no recording (still in progress, recording disabled, or expired)", runID)
+	}
+	var bundle AiRunBundle
+	if err := json.Unmarshal([]byte(item), &bundle); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recording of run %s: %w", runID, err)
+	}
+	bundle.Run = run
+	bundle.ExportedAt = time.Now().UnixMilli()
+	return &bundle, nil
+}
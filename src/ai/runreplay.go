@@ -0,0 +1,244 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+
+	"mogenius-operator/src/ai/aisdk"
+)
+
+// replay outcomes
+const (
+	AI_RUN_REPLAY_OUTCOME_COMPLETED        = "completed"
+	AI_RUN_REPLAY_OUTCOME_BUDGET_EXHAUSTED = "budgetExhausted"
+	AI_RUN_REPLAY_OUTCOME_FAILED           = "failed"
+)
+
+// AiRunReplayOptions changes the conditions a bundle is replayed under. Zero
+// values keep what was recorded.
+type AiRunReplayOptions struct {
+	// SystemPrompt replaces the recorded system prompt.
+	SystemPrompt string `json:"systemPrompt,omitempty"`
+	// ContextWindowTokens replaces the recorded context window, e.g. to force
+	// compaction earlier.
+	ContextWindowTokens int64 `json:"contextWindowTokens,omitempty"`
+	MaxToolCalls        int   `json:"maxToolCalls,omitempty"`
+	MaxTokensPerRun     int64 `json:"maxTokensPerRun,omitempty"`
+}
+
+// AiRunReplayRequest compares one model request of the replay with the
+// recorded request its response was taken from.
+type AiRunReplayRequest struct {
+	Compaction       bool `json:"compaction,omitempty"`
+	Messages         int  `json:"messages"`
+	Bytes            int  `json:"bytes"`
+	RecordedMessages int  `json:"recordedMessages"`
+	RecordedBytes    int  `json:"recordedBytes"`
+}
+
+// AiRunReplayReport is the result of replaying a bundle. Divergences lists
+// every point where the replay no longer matched the recording; an empty
+// list means the agent loop behaved exactly as in the recorded run.
+type AiRunReplayReport struct {
+	RunID         string               `json:"runId,omitempty"`
+	Outcome       string               `json:"outcome"`
+	Error         string               `json:"error,omitempty"`
+	RecordedError string               `json:"recordedError,omitempty"`
+	TokensUsed    int64                `json:"tokensUsed"`
+	ToolCalls     int                  `json:"toolCalls"`
+	Requests      []AiRunReplayRequest `json:"requests"`
+	FinalContent  string               `json:"finalContent,omitempty"`
+	Divergences   []string             `json:"divergences"`
+}
+
+// ReplayRunBundle re-runs the agent loop of a recorded run deterministically:
+// model responses are served from the bundle in call order (compaction calls
+// from their own queue) and tool results from the recorded tool calls. No
+// model, cluster or Valkey is involved, so it runs in CI.
+func ReplayRunBundle(ctx context.Context, logger *slog.Logger, bundle AiRunBundle, opts AiRunReplayOptions) (*AiRunReplayReport, error) {
+	if bundle.Version != AiRunBundleVersion {
+		return nil, fmt.Errorf("unsupported run bundle version %d (expected %d)", bundle.Version, AiRunBundleVersion)
+	}
+	if len(bundle.Messages) < 2 || bundle.Messages[0].Role != aisdk.RoleSystem {
+		return nil, fmt.Errorf("run bundle must start with a system and a user message")
+	}
+
+	replay := newRunReplay(bundle, opts)
+	if bundle.Run != nil {
+		replay.report.RunID = bundle.Run.ID
+	}
+	messages := append([]aisdk.Message(nil), bundle.Messages...)
+	if opts.SystemPrompt != "" {
+		messages[0].Content = opts.SystemPrompt
+	}
+	maxToolCalls := bundle.MaxToolCalls
+	if opts.MaxToolCalls > 0 {
+		maxToolCalls = opts.MaxToolCalls
+	}
+	maxTokensPerRun := bundle.MaxTokensPerRun
+	if opts.MaxTokensPerRun > 0 {
+		maxTokensPerRun = opts.MaxTokensPerRun
+	}
+
+	replayer := &aiManager{logger: logger, runReplay: replay}
+	tokensUsed, err := replayer.runAgentLoop(ctx, replay, bundle.Model, messages, bundle.Tools, nil, nil, maxToolCalls, maxTokensPerRun, nil, NoopStepRecorder())
+	replay.finish(tokensUsed, err)
+	return &replay.report, nil
+}
+
+func (ai *aiManager) ReplayRun(ctx context.Context, bundle AiRunBundle, opts AiRunReplayOptions, useCurrentSystemPrompt bool) (*AiRunReplayReport, error) {
+	if useCurrentSystemPrompt {
+		opts.SystemPrompt = ai.getSystemPrompt()
+	}
+	return ReplayRunBundle(ctx, ai.logger, bundle, opts)
+}
+
+// runReplay is the aisdk.Provider and tool result source of one replay.
+type runReplay struct {
+	bundle      AiRunBundle
+	opts        AiRunReplayOptions
+	turns       []AiRunExchange
+	compactions []AiRunExchange
+	toolUsed    []bool
+	report      AiRunReplayReport
+}
+
+func newRunReplay(bundle AiRunBundle, opts AiRunReplayOptions) *runReplay {
+	r := &runReplay{
+		bundle:   bundle,
+		opts:     opts,
+		toolUsed: make([]bool, len(bundle.ToolCalls)),
+		report: AiRunReplayReport{
+			RecordedError: bundle.Error,
+			Requests:      []AiRunReplayRequest{},
+			Divergences:   []string{},
+		},
+	}
+	for _, exchange := range bundle.Exchanges {
+		if exchange.Compaction {
+			r.compactions = append(r.compactions, exchange)
+		} else {
+			r.turns = append(r.turns, exchange)
+		}
+	}
+	return r
+}
+
+func (r *runReplay) diverge(format string, args ...any) {
+	r.report.Divergences = append(r.report.Divergences, fmt.Sprintf(format, args...))
+}
+
+func (r *runReplay) Chat(ctx context.Context, _ string, messages []aisdk.Message, _ []aisdk.Tool) (aisdk.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return aisdk.Response{}, err
+	}
+	request := AiRunReplayRequest{
+		Compaction: isCompactionRequest(messages),
+		Messages:   len(messages),
+		Bytes:      estimateAiSDKMessageBytes(messages),
+	}
+	queue, kind := &r.turns, "model"
+	if request.Compaction {
+		queue, kind = &r.compactions, "compaction"
+	}
+	if len(*queue) == 0 {
+		r.report.Requests = append(r.report.Requests, request)
+		r.diverge("request %d: no recorded %s response left", len(r.report.Requests), kind)
+		return aisdk.Response{}, fmt.Errorf("replay diverged: no recorded %s response left", kind)
+	}
+	exchange := (*queue)[0]
+	*queue = (*queue)[1:]
+	request.RecordedMessages = exchange.RequestMessages
+	request.RecordedBytes = exchange.RequestBytes
+	r.report.Requests = append(r.report.Requests, request)
+
+	if exchange.Error != "" {
+		return aisdk.Response{}, errors.New(exchange.Error)
+	}
+	if !request.Compaction {
+		r.report.FinalContent = exchange.Response.Content
+	}
+	return exchange.Response, nil
+}
+
+func (r *runReplay) ChatStream(context.Context, string, []aisdk.Message, []aisdk.Tool) (<-chan aisdk.StreamChunk, error) {
+	return nil, fmt.Errorf("streaming is not supported in replay")
+}
+
+func (r *runReplay) ContextWindowTokens(context.Context, string) (int64, error) {
+	if r.opts.ContextWindowTokens > 0 {
+		return r.opts.ContextWindowTokens, nil
+	}
+	if r.bundle.ContextWindowTokens > 0 {
+		return r.bundle.ContextWindowTokens, nil
+	}
+	return 0, fmt.Errorf("context window was not recorded")
+}
+
+// toolResult returns the recorded result of the first unused tool call with
+// the same name and arguments.
+func (r *runReplay) toolResult(name, rawArgs string) string {
+	r.report.ToolCalls++
+	for i, call := range r.bundle.ToolCalls {
+		if r.toolUsed[i] || call.Name != name || !sameJsonArguments(call.Arguments, rawArgs) {
+			continue
+		}
+		r.toolUsed[i] = true
+		return call.Result
+	}
+	r.diverge("tool call %s(%s) has no recorded result", name, truncateStepText(rawArgs, maxStepArgsLen))
+	return fmt.Sprintf("Tool %q has no recorded result for these arguments in this replay.", name)
+}
+
+func sameJsonArguments(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// finish fills in the outcome and reports recorded data the replay did not
+// consume.
+func (r *runReplay) finish(tokensUsed int64, err error) {
+	r.report.TokensUsed = tokensUsed
+	switch {
+	case err == nil:
+		r.report.Outcome = AI_RUN_REPLAY_OUTCOME_COMPLETED
+	case errors.As(err, new(*BudgetExhaustedError)):
+		r.report.Outcome = AI_RUN_REPLAY_OUTCOME_BUDGET_EXHAUSTED
+		r.report.Error = err.Error()
+	default:
+		r.report.Outcome = AI_RUN_REPLAY_OUTCOME_FAILED
+		r.report.Error = err.Error()
+	}
+
+	if r.bundle.Truncated {
+		r.diverge("the recording is truncated; the replay ends where the recording stopped")
+	}
+	if len(r.turns) > 0 {
+		r.diverge("%d recorded model responses were not replayed", len(r.turns))
+	}
+	if len(r.compactions) > 0 {
+		r.diverge("%d recorded compactions were not replayed", len(r.compactions))
+	}
+	unused := 0
+	for _, used := range r.toolUsed {
+		if !used {
+			unused++
+		}
+	}
+	if unused > 0 {
+		r.diverge("%d recorded tool calls were not replayed", unused)
+	}
+	if r.report.Error != r.bundle.Error {
+		r.diverge("run ended with %q, recorded run ended with %q", r.report.Error, r.bundle.Error)
+	}
+}
@@ -0,0 +1,133 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mogenius-operator/src/ai/aisdk"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadRunBundle(t *testing.T, path string) AiRunBundle {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var bundle AiRunBundle
+	require.NoError(t, json.Unmarshal(data, &bundle))
+	return bundle
+}
+
+// TestReplayRunBundles replays every recorded run in testdata/runs. A change
+// of the agent loop, the compaction or the tool dispatch that alters how a
+// recorded run plays out shows up as a divergence here.
+func TestReplayRunBundles(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "runs", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			bundle := loadRunBundle(t, path)
+			report, err := ReplayRunBundle(context.Background(), slog.New(slog.DiscardHandler), bundle, AiRunReplayOptions{})
+			require.NoError(t, err)
+			assert.Empty(t, report.Divergences)
+			assert.Equal(t, AI_RUN_REPLAY_OUTCOME_COMPLETED, report.Outcome)
+			assert.Equal(t, len(bundle.ToolCalls), report.ToolCalls)
+			assert.Equal(t, bundle.Exchanges[len(bundle.Exchanges)-1].Response.Content, report.FinalContent)
+		})
+	}
+}
+
+func TestReplayRunBundle_SystemPromptOverride(t *testing.T) {
+	bundle := loadRunBundle(t, filepath.Join("testdata", "runs", "list-pods.json"))
+	logger := slog.New(slog.DiscardHandler)
+
+	recorded, err := ReplayRunBundle(context.Background(), logger, bundle, AiRunReplayOptions{})
+	require.NoError(t, err)
+	longer, err := ReplayRunBundle(context.Background(), logger, bundle, AiRunReplayOptions{SystemPrompt: strings.Repeat("x", 1000)})
+	require.NoError(t, err)
+
+	require.Len(t, longer.Requests, len(recorded.Requests))
+	assert.Greater(t, longer.Requests[0].Bytes, recorded.Requests[0].Bytes)
+	assert.Empty(t, longer.Divergences)
+	// The override must not leak into the bundle.
+	assert.NotEqual(t, strings.Repeat("x", 1000), bundle.Messages[0].Content)
+}
+
+func TestReplayRunBundle_UnrecordedCompactionDiverges(t *testing.T) {
+	bundle := loadRunBundle(t, filepath.Join("testdata", "runs", "list-pods.json"))
+
+	// A tiny context window makes the loop compact after the first turn,
+	// which the recording never did.
+	report, err := ReplayRunBundle(context.Background(), slog.New(slog.DiscardHandler), bundle, AiRunReplayOptions{ContextWindowTokens: 10})
+	require.NoError(t, err)
+	assert.NotEmpty(t, report.Divergences)
+	assert.Contains(t, report.Divergences[0], "no recorded compaction response left")
+}
+
+func TestReplayRunBundle_ChangedToolArgumentsDiverge(t *testing.T) {
+	bundle := loadRunBundle(t, filepath.Join("testdata", "runs", "list-pods.json"))
+	bundle.Exchanges[0].Response.ToolCalls[0].Arguments = `{"apiVersion":"v1","plural":"pods","namespace":"kube-system"}`
+
+	report, err := ReplayRunBundle(context.Background(), slog.New(slog.DiscardHandler), bundle, AiRunReplayOptions{})
+	require.NoError(t, err)
+	assert.Len(t, report.Divergences, 2) // no result for the new args, recorded call unused
+}
+
+func TestReplayRunBundle_RejectsUnknownVersion(t *testing.T) {
+	bundle := loadRunBundle(t, filepath.Join("testdata", "runs", "list-pods.json"))
+	bundle.Version = AiRunBundleVersion + 1
+
+	_, err := ReplayRunBundle(context.Background(), slog.New(slog.DiscardHandler), bundle, AiRunReplayOptions{})
+	assert.Error(t, err)
+}
+
+func TestRunRecording_RoundTrip(t *testing.T) {
+	ai, fake := newStepTestManager(t)
+	provider := &mockProvider{chatFn: func(context.Context, string, []aisdk.Message, []aisdk.Tool) (aisdk.Response, error) {
+		return aisdk.Response{Content: "All pods are running.", InputTokens: 100, OutputTokens: 10, FinishReason: "end_turn"}, nil
+	}}
+	messages := buildAgentMessages("system prompt", "check the pods")
+
+	recording := ai.newRunRecording()
+	require.NotNil(t, recording)
+	recording.start("test-model", messages, nil, 5, 1000)
+	steps := recording.wrapSteps(ai.newStepRecorder("run-1"))
+	_, runErr := ai.runAgentLoop(context.Background(), recording.wrapProvider(provider), "test-model", messages, nil, nil, nil, 5, 1000, nil, steps)
+	require.NoError(t, runErr)
+	// Tool calls reach the recording through the step recorder.
+	steps.ToolCall("list_kubernetes_resources", `{"plural":"pods"}`)(AiRunStepStatusFinished, "", "no pods")
+	ai.saveRunRecording("run-1", recording, runErr)
+
+	var bundle AiRunBundle
+	require.NoError(t, json.Unmarshal([]byte(fake.store[runRecordingKey("run-1")]), &bundle))
+	assert.Equal(t, AiRunBundleVersion, bundle.Version)
+	assert.Equal(t, int64(200_000), bundle.ContextWindowTokens)
+	require.Len(t, bundle.Exchanges, 1)
+	assert.Equal(t, "All pods are running.", bundle.Exchanges[0].Response.Content)
+	require.Len(t, bundle.ToolCalls, 1)
+	assert.Equal(t, "no pods", bundle.ToolCalls[0].Result)
+
+	bundle.ToolCalls = nil
+	report, err := ReplayRunBundle(context.Background(), ai.logger, bundle, AiRunReplayOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Divergences)
+	assert.Equal(t, int64(110), report.TokensUsed)
+}
+
+func TestRunRecording_Truncates(t *testing.T) {
+	recording := &aiRunRecording{maxBytes: 10, bundle: AiRunBundle{Version: AiRunBundleVersion}}
+	recording.addToolCall(AiRunToolCall{Name: "t", Result: "short"})
+	recording.addToolCall(AiRunToolCall{Name: "t", Result: "much too long"})
+	recording.addToolCall(AiRunToolCall{Name: "t", Result: "x"})
+
+	assert.Len(t, recording.bundle.ToolCalls, 1)
+	assert.True(t, recording.bundle.Truncated)
+}
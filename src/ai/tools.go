@@ -56,6 +56,11 @@ type ToolContext struct {
 	// without needing elevated RBAC — the approving user supplies the permissions.
 	InterceptMutatingTools bool
 
+	// BuiltinToolPolicies holds the agent's explicit per-tool policies
+	// (spec.tools.builtin.toolPolicies). Unlisted tools fall back to the
+	// defaults of BuiltinToolPolicy. Only consulted for agent runs.
+	BuiltinToolPolicies map[string]v1alpha1.MCPToolPolicyType
+
 	// CreateApprovalRequest is populated for agent runs. When a tool call is
 	// intercepted (mutating built-in or MCP needsApprove), this callback
 	// persists a PROPOSED task and blocks until the user approves or rejects
@@ -63,15 +68,87 @@ type ToolContext struct {
 	// agent's namespace scope but carrying the approver's role and identity),
 	// or an error when rejected or the context is cancelled.
 	CreateApprovalRequest func(ctx context.Context, toolName string, args map[string]any) (*ToolContext, error)
+
+	// services are the operator services linked into the AI manager; set by
+	// dispatchToolCall for every built-in tool call.
+	services ToolServices
 }
 
 // mutatingBuiltinTools is the set of built-in tool names that mutate Kubernetes
-// resources. When InterceptMutatingTools is true these are offered to the model
-// but intercepted before execution via CreateApprovalRequest.
+// resources or Helm state. When InterceptMutatingTools is true these are
+// offered to the model but intercepted before execution via
+// CreateApprovalRequest, unless the agent's tool policy says otherwise.
 var mutatingBuiltinTools = map[string]bool{
 	"update_kubernetes_resource": true,
 	"create_kubernetes_resource": true,
 	"delete_kubernetes_resource": true,
+	// helm tools
+	"helm_repo_add":          true,
+	"helm_repo_patch":        true,
+	"helm_repo_update":       true,
+	"helm_repo_remove":       true,
+	"helm_chart_install":     true,
+	"helm_oci_install":       true,
+	"helm_release_upgrade":   true,
+	"helm_release_uninstall": true,
+	"helm_release_rollback":  true,
+	"helm_release_link":      true,
+}
+
+// unscopedBuiltinTools are the mutating built-in tools the agent's scope
+// cannot restrict: Helm repositories are shared by the whole cluster. They
+// always need approval, autoApprove is rejected for them.
+var unscopedBuiltinTools = map[string]bool{
+	"helm_repo_add":    true,
+	"helm_repo_patch":  true,
+	"helm_repo_update": true,
+	"helm_repo_remove": true,
+}
+
+// namespacedBuiltinTools are the mutating built-in tools only an agent scoped
+// to named namespaces may autoApprove: with "*" in the scope they could write
+// into every namespace, kube-system included.
+var namespacedBuiltinTools = map[string]bool{
+	"create_kubernetes_resource": true,
+	"update_kubernetes_resource": true,
+	"delete_kubernetes_resource": true,
+}
+
+// BuiltinToolPolicy returns the effective policy of a built-in tool: the
+// explicit entry from BuiltinToolPolicies, otherwise needsApprove for
+// mutating tools and autoApprove for read-only ones. Unscoped tools never
+// resolve to autoApprove.
+func (tc *ToolContext) BuiltinToolPolicy(name string) v1alpha1.MCPToolPolicyType {
+	if tc != nil {
+		if policy, ok := tc.BuiltinToolPolicies[name]; ok {
+			if policy == v1alpha1.MCPToolPolicyAutoApprove && unscopedBuiltinTools[name] {
+				return v1alpha1.MCPToolPolicyNeedsApprove
+			}
+			return policy
+		}
+	}
+	if mutatingBuiltinTools[name] {
+		return v1alpha1.MCPToolPolicyNeedsApprove
+	}
+	return v1alpha1.MCPToolPolicyAutoApprove
+}
+
+// autoApprovedContext returns the context an autoApprove mutating tool runs
+// with: the agent's scope and identity, but with editor permissions so the
+// role check in the tool passes. The approval callback is dropped.
+func (tc *ToolContext) autoApprovedContext() *ToolContext {
+	c := *tc
+	c.Role = "editor"
+	c.CreateApprovalRequest = nil
+	return &c
+}
+
+// toolServices returns the linked services; none for a nil context.
+func (tc *ToolContext) toolServices() ToolServices {
+	if tc == nil {
+		return ToolServices{}
+	}
+	return tc.services
 }
 
 // aiResourceKey is the canonical identity used for ExcludeResources lookups.
@@ -275,9 +352,10 @@ func newToolContextFromUserGrant(user *structs.User, workspace string, isAdmin b
 	return tc
 }
 
-// newToolContextFromAgent builds the ToolContext for agent runs. No role is set
-// because mutating tools are gated by InterceptMutatingTools + CreateApprovalRequest
-// (wired in buildAgentTaskContext), not by the role check in the tools themselves.
+// newToolContextFromAgent builds the ToolContext for agent runs. The role is
+// "viewer" because mutating tools are gated by InterceptMutatingTools +
+// CreateApprovalRequest (wired in buildAgentTaskContext) and the agent's tool
+// policies, not by the role check in the tools themselves.
 // The namespace allow-map must be non-empty — callers must not run an agent
 // whose scope resolved to zero namespaces.
 func newToolContextFromAgent(agent *v1alpha1.Agent, resolvedNamespaces []string) *ToolContext {
@@ -291,6 +369,7 @@ func newToolContextFromAgent(agent *v1alpha1.Agent, resolvedNamespaces []string)
 		Role:                   "viewer",
 		AllowedNamespaces:      allowed,
 		InterceptMutatingTools: true,
+		AuditSource:            "ai-agent",
 		User: &structs.User{
 			FirstName: "Agent",
 			LastName:  agent.Name,
@@ -355,6 +434,8 @@ var viewerAllowedTools = map[string]bool{
 	"check_kubernetes_resource": true,
 	"get_pod_logs":              true,
 	"get_pod_events":            true,
+	"list_security_findings":    true,
+	"get_upgrade_readiness":     true,
 	// helm tools
 	"helm_chart_search":    true,
 	"helm_chart_show":      true,
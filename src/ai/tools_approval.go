@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"fmt"
+	"mogenius-operator/src/helm"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// helmReleaseRevision returns the current revision of a Helm release. A
+// variable so tests can run without a Helm backend.
+var helmReleaseRevision = func(namespace, release string) (int, error) {
+	status, err := helm.HelmReleaseStatus(helm.HelmReleaseStatusRequest{Namespace: namespace, Release: release})
+	if err != nil {
+		return 0, err
+	}
+	return status.Version, nil
+}
+
+// builtinToolTargetVersion returns the version of the existing object a
+// built-in mutating tool call acts on: the live resourceVersion for Kubernetes
+// updates and deletes, the release revision for Helm release changes. It is
+// empty when the tool has no existing target (creates, installs, repository
+// changes) or the target cannot be read.
+func builtinToolTargetVersion(toolName string, args map[string]any) string {
+	switch toolName {
+	case "update_kubernetes_resource", "delete_kubernetes_resource":
+		if K8sGetUnstructuredResource == nil {
+			return ""
+		}
+		apiVersion, plural, namespace, name := kubernetesToolTarget(toolName, args)
+		if name == "" {
+			return ""
+		}
+		obj, err := K8sGetUnstructuredResource(apiVersion, plural, namespace, name)
+		if err != nil || obj == nil {
+			return ""
+		}
+		return obj.GetResourceVersion()
+	case "helm_release_upgrade", "helm_release_uninstall", "helm_release_rollback":
+		namespace, _ := args["namespace"].(string)
+		release, _ := args["release"].(string)
+		if release == "" {
+			return ""
+		}
+		revision, err := helmReleaseRevision(namespace, release)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("revision %d", revision)
+	}
+	return ""
+}
+
+// kubernetesToolTarget extracts the identity of the resource an update or
+// delete tool call targets.
+func kubernetesToolTarget(toolName string, args map[string]any) (apiVersion, plural, namespace, name string) {
+	apiVersion, _ = args["apiVersion"].(string)
+	plural, _ = args["plural"].(string)
+	if toolName == "delete_kubernetes_resource" {
+		namespace, _ = args["namespace"].(string)
+		name, _ = args["name"].(string)
+		return apiVersion, plural, namespace, name
+	}
+	yamlData, _ := args["yamlData"].(string)
+	var obj unstructured.Unstructured
+	if err := yaml.Unmarshal([]byte(yamlData), &obj.Object); err != nil {
+		return apiVersion, plural, "", ""
+	}
+	if namespaced, _ := args["namespaced"].(bool); namespaced {
+		namespace = obj.GetNamespace()
+	}
+	return apiVersion, plural, namespace, obj.GetName()
+}
+
+// checkBuiltinToolTargetVersion refuses an approved tool call whose target
+// changed after the proposal was made. An empty base version (nothing to
+// compare against) always passes.
+func checkBuiltinToolTargetVersion(toolName string, args map[string]any, baseVersion string) error {
+	if baseVersion == "" {
+		return nil
+	}
+	current := builtinToolTargetVersion(toolName, args)
+	switch current {
+	case baseVersion:
+		return nil
+	case "":
+		return fmt.Errorf("the target of %q no longer exists or cannot be read (proposed against %s); re-inspect it before proposing again", toolName, baseVersion)
+	default:
+		return fmt.Errorf("the target of %q changed since the proposal (proposed against %s, now %s); re-inspect it before proposing again", toolName, baseVersion, current)
+	}
+}
@@ -100,6 +100,8 @@ var toolCategoryMap = map[string]toolCategory{
 	"check_kubernetes_resource": categoryKubernetesRead,
 	"get_pod_logs":              categoryKubernetesRead,
 	"get_pod_events":            categoryKubernetesRead,
+	"list_security_findings":    categoryKubernetesRead,
+	"get_upgrade_readiness":     categoryKubernetesRead,
 	// Kubernetes Write
 	"update_kubernetes_resource": categoryKubernetesWrite,
 	"delete_kubernetes_resource": categoryKubernetesWrite,
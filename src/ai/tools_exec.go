@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"mogenius-operator/src/crds/v1alpha1"
 )
 
 type toolExec struct {
@@ -34,22 +35,38 @@ func (ai *aiManager) dispatchToolCall(ctx context.Context, name string, args map
 		return toolOutcome{Result: result}
 	}
 
+	// Replay: every tool result comes from the recorded run.
+	if ai.runReplay != nil {
+		return toolOutcome{Result: ai.runReplay.toolResult(name, rawArgs)}
+	}
+
 	// Built-in Kubernetes/Helm tools.
 	if tool, ok := toolDefinitions[name]; ok {
 		finalize := e.RecordStep.ToolCall(name, rawArgs)
-		execCtx := e.ToolCtx
+		execCtx := ai.withToolServices(e.ToolCtx)
+		policy := v1alpha1.MCPToolPolicyAutoApprove
+		if e.InterceptMutating {
+			policy = e.ToolCtx.BuiltinToolPolicy(name)
+		}
 		var result string
 		switch {
-		case e.InterceptMutating && mutatingBuiltinTools[name] && e.ToolCtx != nil && e.ToolCtx.CreateApprovalRequest != nil:
+		case policy == v1alpha1.MCPToolPolicyDeny:
+			result = fmt.Sprintf("Tool call %q is denied by the agent's tool policy. The call was blocked.", name)
+		case policy == v1alpha1.MCPToolPolicyNeedsApprove && e.ToolCtx != nil && e.ToolCtx.CreateApprovalRequest != nil:
 			approverCtx, approvalErr := e.ToolCtx.CreateApprovalRequest(ctx, name, args)
 			if approvalErr != nil {
 				result = fmt.Sprintf("Tool call %q was not executed: %v", name, approvalErr)
 			} else {
-				execCtx = approverCtx
+				execCtx = ai.withToolServices(approverCtx)
 				result = tool(args, execCtx, ai.valkeyClient, ai.logger)
 			}
-		case e.InterceptMutating && mutatingBuiltinTools[name]:
+		case policy == v1alpha1.MCPToolPolicyNeedsApprove:
 			result = fmt.Sprintf("Tool call %q requires human approval but no approval mechanism is configured for this run. The call was blocked.", name)
+		case e.InterceptMutating && mutatingBuiltinTools[name] && e.ToolCtx != nil:
+			// autoApprove on a mutating tool: run unattended with editor
+			// permissions, still restricted to the agent's scope.
+			execCtx = execCtx.autoApprovedContext()
+			result = tool(args, execCtx, ai.valkeyClient, ai.logger)
 		default:
 			result = tool(args, execCtx, ai.valkeyClient, ai.logger)
 		}
@@ -150,3 +167,14 @@ func runBudgetExhausted(logger *slog.Logger, maxToolCalls, toolCallCount int, ma
 	}
 	return ""
 }
+
+// withToolServices returns a copy of tc carrying the services linked into the
+// manager. A nil tc becomes an empty context, which is just as unrestricted.
+func (ai *aiManager) withToolServices(tc *ToolContext) *ToolContext {
+	c := ToolContext{}
+	if tc != nil {
+		c = *tc
+	}
+	c.services = ai.toolServices
+	return &c
+}
@@ -1,10 +1,13 @@
 package ai
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"mogenius-operator/src/crds/v1alpha1"
 	"mogenius-operator/src/helm"
+	"mogenius-operator/src/policy"
 	"mogenius-operator/src/structs"
 	"mogenius-operator/src/valkeyclient"
 
@@ -160,17 +163,22 @@ func helmChartInstallTool(args map[string]any, tc *ToolContext, _ valkeyclient.V
 	dryRun, _ := args["dryRun"].(bool)
 
 	logger.Info("Installing Helm chart", "chart", chart, "release", release, "namespace", namespace)
-	result, err := helm.HelmChartInstall(helm.HelmChartInstallUpgradeRequest{
+	var violations []policy.Violation
+	result, err := helm.HelmChartInstall(context.Background(), helm.HelmChartInstallUpgradeRequest{
 		Namespace: namespace, Chart: chart, Release: release,
 		Version: version, Values: values, DryRun: dryRun,
+		ManifestCheck: toolManifestCheck(tc, "helm_chart_install", v1alpha1.PolicyOperationCreate, namespace, &violations),
 	})
 	if !dryRun {
 		auditAiToolMutation(tc, logger, "helm_chart_install", args, result, err, nil, nil)
 	}
+	if policy.ViolationsFromError(err) != nil {
+		return policyRejection(err)
+	}
 	if err != nil {
 		return fmt.Sprintf("Error installing Helm chart: %v", err)
 	}
-	return result
+	return withPolicyAudit(result, violations)
 }
 
 func helmOciInstallTool(args map[string]any, tc *ToolContext, _ valkeyclient.ValkeyClient, logger *slog.Logger) string {
@@ -194,18 +202,23 @@ func helmOciInstallTool(args map[string]any, tc *ToolContext, _ valkeyclient.Val
 	password, _ := args["password"].(string)
 
 	logger.Info("Installing Helm OCI chart", "ociChartUrl", ociChartUrl, "release", release, "namespace", namespace)
-	result, err := helm.HelmOciInstall(helm.HelmChartOciInstallUpgradeRequest{
+	var violations []policy.Violation
+	result, err := helm.HelmOciInstall(context.Background(), helm.HelmChartOciInstallUpgradeRequest{
 		OCIChartUrl: ociChartUrl, Namespace: namespace, Release: release,
 		Version: version, Values: values, DryRun: dryRun,
 		AuthHost: authHost, Username: username, Password: password,
+		ManifestCheck: toolManifestCheck(tc, "helm_oci_install", v1alpha1.PolicyOperationCreate, namespace, &violations),
 	})
 	if !dryRun {
 		auditAiToolMutation(tc, logger, "helm_oci_install", args, result, err, nil, nil)
 	}
+	if policy.ViolationsFromError(err) != nil {
+		return policyRejection(err)
+	}
 	if err != nil {
 		return fmt.Sprintf("Error installing OCI Helm chart: %v", err)
 	}
-	return result
+	return withPolicyAudit(result, violations)
 }
 
 func helmChartShowTool(args map[string]any, _ *ToolContext, _ valkeyclient.ValkeyClient, logger *slog.Logger) string {
@@ -254,17 +267,22 @@ func helmReleaseUpgradeTool(args map[string]any, tc *ToolContext, _ valkeyclient
 	dryRun, _ := args["dryRun"].(bool)
 
 	logger.Info("Upgrading Helm release", "release", release, "chart", chart, "namespace", namespace)
-	result, err := helm.HelmReleaseUpgrade(helm.HelmChartInstallUpgradeRequest{
+	var violations []policy.Violation
+	result, err := helm.HelmReleaseUpgrade(context.Background(), helm.HelmChartInstallUpgradeRequest{
 		Namespace: namespace, Chart: chart, Release: release,
 		Version: version, Values: values, DryRun: dryRun,
+		ManifestCheck: toolManifestCheck(tc, "helm_release_upgrade", v1alpha1.PolicyOperationUpdate, namespace, &violations),
 	})
 	if !dryRun {
 		auditAiToolMutation(tc, logger, "helm_release_upgrade", args, result, err, nil, nil)
 	}
+	if policy.ViolationsFromError(err) != nil {
+		return policyRejection(err)
+	}
 	if err != nil {
 		return fmt.Sprintf("Error upgrading Helm release: %v", err)
 	}
-	return result
+	return withPolicyAudit(result, violations)
 }
 
 func helmReleaseUninstallTool(args map[string]any, tc *ToolContext, _ valkeyclient.ValkeyClient, logger *slog.Logger) string {
@@ -282,7 +300,7 @@ func helmReleaseUninstallTool(args map[string]any, tc *ToolContext, _ valkeyclie
 	dryRun, _ := args["dryRun"].(bool)
 
 	logger.Info("Uninstalling Helm release", "release", release, "namespace", namespace)
-	result, err := helm.HelmReleaseUninstall(helm.HelmReleaseUninstallRequest{
+	result, err := helm.HelmReleaseUninstall(context.Background(), helm.HelmReleaseUninstallRequest{
 		Namespace: namespace, Release: release, DryRun: dryRun,
 	})
 	if !dryRun {
@@ -361,7 +379,7 @@ func helmReleaseRollbackTool(args map[string]any, tc *ToolContext, _ valkeyclien
 	revision := int(revisionFloat)
 
 	logger.Info("Rolling back Helm release", "release", release, "namespace", namespace, "revision", revision)
-	result, err := helm.HelmReleaseRollback(helm.HelmReleaseRollbackRequest{
+	result, err := helm.HelmReleaseRollback(context.Background(), helm.HelmReleaseRollbackRequest{
 		Namespace: namespace, Release: release, Revision: revision,
 	})
 	auditAiToolMutation(tc, logger, "helm_release_rollback", args, result, err, nil, nil)
@@ -1,10 +1,16 @@
 package ai
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/deprecations"
+	"mogenius-operator/src/policy"
+	"mogenius-operator/src/security"
 	"mogenius-operator/src/store"
+	"mogenius-operator/src/utils"
 	"mogenius-operator/src/valkeyclient"
 	"slices"
 	"sort"
@@ -19,13 +25,38 @@ import (
 
 // Function variables to avoid cyclic imports - set these from cmd package
 var (
-	K8sUpdateUnstructuredResource       func(apiVersion, plural string, namespaced bool, yamlData string) (*unstructured.Unstructured, error)
+	K8sUpdateUnstructuredResource       func(ctx context.Context, apiVersion, plural string, namespaced bool, yamlData string) (*unstructured.Unstructured, error)
 	K8sDeleteUnstructuredResource       func(apiVersion, plural, namespace, resourceName string) error
 	K8sCreateUnstructuredResource       func(apiVersion, plural string, namespaced bool, yamlData string) (*unstructured.Unstructured, error)
 	K8sGetUnstructuredResourceFromStore func(apiVersion, kind, namespace, resourceName string) (*unstructured.Unstructured, error)
+	K8sGetUnstructuredResource          func(apiVersion, plural, namespace, resourceName string) (*unstructured.Unstructured, error)
 	K8sGetPodLogs                       func(namespace, podName, container string, tailLines int64, previous bool) (string, error)
 )
 
+// ToolServices are the operator services the built-in tools call into. A nil
+// field skips the policy checks or disables the tool that needs it.
+type ToolServices struct {
+	// Policies evaluates the Policies for a change a tool is about to write.
+	Policies PolicyChecker
+	// ListSecurityFindings returns the findings of the last security scan.
+	ListSecurityFindings func() ([]security.ResourceFindings, error)
+	// GetUpgradeReadiness reports the objects using APIs deprecated or
+	// removed within the next minors Kubernetes versions.
+	GetUpgradeReadiness func(minors int) (*deprecations.Report, error)
+	// AvailableResources lists the resource kinds the cluster serves.
+	AvailableResources func() ([]utils.ResourceDescriptor, error)
+}
+
+// PolicyChecker evaluates the Policies of the operator's namespace. Both
+// checks return every violation found, audit ones included; enforced ones
+// also reject the change with a policy.DeniedError.
+type PolicyChecker interface {
+	// Check evaluates one resource; oldObject is nil on creates.
+	Check(request policy.Request, object, oldObject *unstructured.Unstructured) ([]policy.Violation, error)
+	// CheckManifest evaluates every resource of a rendered Helm chart.
+	CheckManifest(request policy.Request, namespace string, manifest string) ([]policy.Violation, error)
+}
+
 var kubernetesToolDefinitions = map[string]func(map[string]any, *ToolContext, valkeyclient.ValkeyClient, *slog.Logger) string{
 	"get_kubernetes_resources":   getKubernetesResourcesTool,
 	"list_kubernetes_resources":  listKubernetesResourcesTool,
@@ -35,6 +66,8 @@ var kubernetesToolDefinitions = map[string]func(map[string]any, *ToolContext, va
 	"create_kubernetes_resource": createKubernetesResourceTool,
 	"get_pod_logs":               getPodLogsTool,
 	"get_pod_events":             getPodEventsTool,
+	"list_security_findings":     listSecurityFindingsTool,
+	"get_upgrade_readiness":      getUpgradeReadinessTool,
 }
 
 // Summaries are ~30 tokens each; a bigger page is far cheaper than the extra
@@ -394,6 +427,9 @@ func updateKubernetesResourceTool(args map[string]any, tc *ToolContext, valkeyCl
 		return fmt.Sprintf("Error: failed to unmarshal YAML data: %v", err)
 	}
 
+	if refusal := checkNamespacedTarget(tc, apiVersion, plural, namespaced); refusal != "" {
+		return refusal
+	}
 	meta := mergeAnnotationsAndLabels(updatedObj.GetAnnotations(), updatedObj.GetLabels())
 	if !tc.IsResourceAllowed(updatedObj.GetNamespace(), meta) {
 		return fmt.Sprintf("Error: access to resource %q in namespace %q is not allowed", updatedObj.GetName(), updatedObj.GetNamespace())
@@ -402,10 +438,16 @@ func updateKubernetesResourceTool(args map[string]any, tc *ToolContext, valkeyCl
 	// Get old object for comparison
 	oldObj, _ := K8sGetUnstructuredResourceFromStore(apiVersion, updatedObj.GetKind(), updatedObj.GetNamespace(), updatedObj.GetName())
 
+	violations, rejection, err := checkToolPolicies(tc, "update_kubernetes_resource", v1alpha1.PolicyOperationUpdate, updatedObj, oldObj)
+	if err != nil {
+		auditAiToolMutation(tc, logger, "update_kubernetes_resource", args, nil, err, oldObj, updatedObj)
+		return rejection
+	}
+
 	logger.Info("Updating Kubernetes resource", "apiVersion", apiVersion, "kind", updatedObj.GetKind(), "namespace", updatedObj.GetNamespace(), "name", updatedObj.GetName())
 
 	// Perform the update
-	updatedRes, err := K8sUpdateUnstructuredResource(apiVersion, plural, namespaced, yamlData)
+	updatedRes, err := K8sUpdateUnstructuredResource(context.Background(), apiVersion, plural, namespaced, yamlData)
 	newObj := updatedRes
 	if newObj == nil {
 		// Failed update: audit the intended target state from the request.
@@ -426,7 +468,7 @@ func updateKubernetesResourceTool(args map[string]any, tc *ToolContext, valkeyCl
 	if rv := updatedRes.GetResourceVersion(); rv != "" {
 		result += fmt.Sprintf(" (rv=%s)", rv)
 	}
-	return result
+	return withPolicyAudit(result, violations)
 }
 
 func deleteKubernetesResourceTool(args map[string]any, tc *ToolContext, valkeyClient valkeyclient.ValkeyClient, logger *slog.Logger) string {
@@ -443,6 +485,9 @@ func deleteKubernetesResourceTool(args map[string]any, tc *ToolContext, valkeyCl
 		return "Error: name is required for delete operation"
 	}
 
+	if refusal := checkNamespacedTarget(tc, apiVersion, plural, namespace != ""); refusal != "" {
+		return refusal
+	}
 	if !tc.IsNamespaceAllowed(namespace) && !tc.hasOwnershipRestrictions() {
 		return fmt.Sprintf("Error: access to namespace %q is not allowed", namespace)
 	}
@@ -480,10 +525,19 @@ func createKubernetesResourceTool(args map[string]any, tc *ToolContext, valkeyCl
 		return fmt.Sprintf("Error: failed to unmarshal YAML data: %v", err)
 	}
 
+	if refusal := checkNamespacedTarget(tc, apiVersion, plural, namespaced); refusal != "" {
+		return refusal
+	}
 	if !tc.IsNamespaceAllowed(obj.GetNamespace()) && !tc.hasOwnershipRestrictions() {
 		return fmt.Sprintf("Error: access to namespace %q is not allowed", obj.GetNamespace())
 	}
 
+	violations, rejection, err := checkToolPolicies(tc, "create_kubernetes_resource", v1alpha1.PolicyOperationCreate, obj, nil)
+	if err != nil {
+		auditAiToolMutation(tc, logger, "create_kubernetes_resource", args, nil, err, nil, obj)
+		return rejection
+	}
+
 	logger.Info("Creating Kubernetes resource", "apiVersion", apiVersion, "kind", obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName())
 
 	// Perform the create
@@ -503,7 +557,95 @@ func createKubernetesResourceTool(args map[string]any, tc *ToolContext, valkeyCl
 	if rv := createdRes.GetResourceVersion(); rv != "" {
 		result += fmt.Sprintf(" (rv=%s)", rv)
 	}
-	return result
+	return withPolicyAudit(result, violations)
+}
+
+// checkNamespacedTarget refuses writes of a scoped context that would reach
+// a cluster-scoped resource: the scope checks only see the namespace in the
+// manifest, which the API server drops on the cluster-scoped path. Kinds the
+// cluster does not list as namespaced are refused as well.
+func checkNamespacedTarget(tc *ToolContext, apiVersion string, plural string, namespaced bool) string {
+	if !tc.hasRestrictions() {
+		return ""
+	}
+	if !namespaced {
+		return "Error: cluster-scoped resources cannot be changed in a namespace-scoped context"
+	}
+	available := tc.toolServices().AvailableResources
+	if available == nil {
+		return "Error: the resource kinds of the cluster are not available"
+	}
+	resources, err := available()
+	if err != nil {
+		return fmt.Sprintf("Error: failed to list the resource kinds of the cluster: %v", err)
+	}
+	for _, resource := range resources {
+		if resource.ApiVersion == apiVersion && resource.Plural == plural {
+			if !resource.Namespaced {
+				return fmt.Sprintf("Error: %s %s is cluster-scoped and cannot be changed in a namespace-scoped context", apiVersion, plural)
+			}
+			return ""
+		}
+	}
+	return fmt.Sprintf("Error: unknown resource %s %s", apiVersion, plural)
+}
+
+// checkToolPolicies evaluates the Policies for a resource a tool is about to
+// write. On rejection it returns the tool result listing the violations, so
+// the model can fix the manifest, and the error to audit. Otherwise it returns
+// the audit-only violations for withPolicyAudit.
+func checkToolPolicies(tc *ToolContext, toolName string, operation string, object, oldObject *unstructured.Unstructured) ([]policy.Violation, string, error) {
+	policies := tc.toolServices().Policies
+	if policies == nil {
+		return nil, "", nil
+	}
+	violations, err := policies.Check(toolPolicyRequest(tc, toolName, operation), object, oldObject)
+	if err != nil {
+		return violations, policyRejection(err), err
+	}
+	return violations, "", nil
+}
+
+// toolManifestCheck is the helm ManifestCheck of an install or upgrade a tool
+// runs; the violations found are stored in violations.
+func toolManifestCheck(tc *ToolContext, toolName string, operation string, namespace string, violations *[]policy.Violation) func(manifest string) error {
+	policies := tc.toolServices().Policies
+	if policies == nil {
+		return nil
+	}
+	return func(manifest string) error {
+		found, err := policies.CheckManifest(toolPolicyRequest(tc, toolName, operation), namespace, manifest)
+		*violations = found
+		return err
+	}
+}
+
+func toolPolicyRequest(tc *ToolContext, toolName string, operation string) policy.Request {
+	request := policy.Request{Operation: operation, Source: "ai/tool/" + toolName}
+	if tc != nil && tc.User != nil {
+		request.User = tc.User.Email
+	}
+	return request
+}
+
+// policyRejection is the tool result of a change the Policies rejected.
+func policyRejection(err error) string {
+	violations := policy.ViolationsFromError(err)
+	if len(violations) == 0 {
+		return fmt.Sprintf("Error: policy check failed: %v", err)
+	}
+	data, _ := json.Marshal(violations)
+	return fmt.Sprintf("Error: the change was rejected by policy, nothing was written. Fix every violation with mode \"enforce\" before retrying.\nviolations: %s", data)
+}
+
+// withPolicyAudit appends the audit-only violations of a change that was
+// written to the tool result, so the model can report or fix them.
+func withPolicyAudit(result string, violations []policy.Violation) string {
+	if len(violations) == 0 {
+		return result
+	}
+	data, _ := json.Marshal(violations)
+	return fmt.Sprintf("%s\nThe change violates policies in audit mode; it was written, but should be fixed.\nviolations: %s", result, data)
 }
 
 const defaultMaxChars = 5000
@@ -644,3 +786,77 @@ func getPodEventsTool(args map[string]any, tc *ToolContext, valkeyClient valkeyc
 	logger.Info("Pod events result", "eventCount", includedCount, "totalEvents", totalEvents)
 	return result
 }
+
+func listSecurityFindingsTool(args map[string]any, tc *ToolContext, _ valkeyclient.ValkeyClient, logger *slog.Logger) string {
+	listFindings := tc.toolServices().ListSecurityFindings
+	if listFindings == nil {
+		return "Error: the security scan is not available"
+	}
+	namespace, _ := args["namespace"].(string)
+	minSeverity, _ := args["minSeverity"].(string)
+	rule, _ := args["rule"].(string)
+
+	if namespace != "" && !tc.IsNamespaceAllowed(namespace) {
+		return fmt.Sprintf("Error: access to namespace %q is not allowed", namespace)
+	}
+
+	entries, err := listFindings()
+	if err != nil {
+		logger.Error("Failed to list security findings", "error", err)
+		return fmt.Sprintf("Error listing security findings: %v", err)
+	}
+
+	minRank := security.SeverityRank(minSeverity)
+	result := []security.ResourceFindings{}
+	for _, entry := range entries {
+		// cluster-scoped resources are only visible without namespace scope
+		if (namespace != "" && entry.Namespace != namespace) || !tc.IsNamespaceAllowed(entry.Namespace) {
+			continue
+		}
+		entry.Findings = slices.DeleteFunc(entry.Findings, func(finding security.Finding) bool {
+			return security.SeverityRank(finding.Severity) < minRank || (rule != "" && finding.Rule != rule)
+		})
+		if len(entry.Findings) > 0 {
+			result = append(result, entry)
+		}
+	}
+	if len(result) == 0 {
+		return "No security findings."
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Sprintf("Error marshaling result: %v", err)
+	}
+	return truncateResult(string(data), getMaxChars(args))
+}
+
+func getUpgradeReadinessTool(args map[string]any, tc *ToolContext, _ valkeyclient.ValkeyClient, logger *slog.Logger) string {
+	getReadiness := tc.toolServices().GetUpgradeReadiness
+	if getReadiness == nil {
+		return "Error: the upgrade readiness report is not available"
+	}
+	minors := 1
+	if value, ok := args["minors"].(float64); ok && value > 0 {
+		minors = int(value)
+	}
+	status, _ := args["status"].(string)
+
+	report, err := getReadiness(minors)
+	if err != nil {
+		logger.Error("Failed to get upgrade readiness", "error", err)
+		return fmt.Sprintf("Error getting upgrade readiness: %v", err)
+	}
+
+	// cluster-scoped objects are only visible without namespace scope
+	report.Findings = slices.DeleteFunc(report.Findings, func(finding deprecations.Finding) bool {
+		return !tc.IsNamespaceAllowed(finding.ScopeNamespace()) || (status != "" && finding.Status != status)
+	})
+	report.Summarize()
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Sprintf("Error marshaling result: %v", err)
+	}
+	return truncateResult(string(data), getMaxChars(args))
+}
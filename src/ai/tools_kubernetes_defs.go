@@ -95,4 +95,23 @@ var kubernetesAiSDKTools = []aisdk.Tool{
 		},
 		Required: []string{"namespace", "podName"},
 	},
+	{
+		Name:        "list_security_findings",
+		Description: "List the findings of the periodic security scan: privileged containers, missing runAsNonRoot, hostNetwork/hostPID, writable root filesystems, Secrets as env, open LoadBalancers, cluster-admin bindings and images without digest. One entry per resource, worst first. Use it instead of inspecting manifests one by one for security reviews.",
+		InputSchema: map[string]any{
+			"namespace":   prop("string", "Namespace filter. Omit for all namespaces you may access (including cluster-scoped bindings)"),
+			"minSeverity": prop("string", "Drop findings below this severity", "critical", "high", "medium", "low"),
+			"rule":        prop("string", "Only this rule, e.g. 'privileged-container'"),
+			"maxChars":    prop("integer", "Maximum characters in response (default 5000, max 30000). Use lower values to save tokens."),
+		},
+	},
+	{
+		Name:        "get_upgrade_readiness",
+		Description: "Check whether the cluster can be upgraded: lists live objects, Helm release manifests and ArgoCD/Flux inventories that use Kubernetes APIs deprecated or removed within the next minor versions, with the replacement apiVersion. Status 'removed' (no longer served) and 'blocking' (removed by the target version) make the cluster not ready. Use it before planning a Kubernetes upgrade.",
+		InputSchema: map[string]any{
+			"minors":   prop("integer", "Number of minor versions to upgrade by (default 1, max 5)"),
+			"status":   prop("string", "Only findings with this status", "removed", "blocking", "deprecated"),
+			"maxChars": prop("integer", "Maximum characters in response (default 5000, max 30000). Use lower values to save tokens."),
+		},
+	},
 }
@@ -29,7 +29,7 @@ func TestAiSDKToolsHaveRequiredFields(t *testing.T) {
 	}
 
 	t.Run("kubernetes tools", func(t *testing.T) {
-		assert.Equal(t, 8, len(kubernetesAiSDKTools))
+		assert.Equal(t, 10, len(kubernetesAiSDKTools))
 		for _, tool := range kubernetesAiSDKTools {
 			assert.NotEmpty(t, tool.Name, "tool Name must be set")
 			assert.NotEmpty(t, tool.Description, "tool Description must be set for %s", tool.Name)
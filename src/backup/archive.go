@@ -0,0 +1,285 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/utils"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// FormatVersion is the layout version written into every archive manifest.
+// Readers refuse archives with a newer version instead of guessing.
+const FormatVersion = 1
+
+const manifestFile = "manifest.json"
+
+// Manifest describes the content of a backup archive.
+type Manifest struct {
+	FormatVersion   int                             `json:"formatVersion"`
+	Backup          string                          `json:"backup"`
+	Workspace       string                          `json:"workspace"`
+	CreatedAt       time.Time                       `json:"createdAt"`
+	OperatorVersion string                          `json:"operatorVersion"`
+	Namespaces      []string                        `json:"namespaces"`
+	Resources       []ManifestEntry                 `json:"resources"`
+	VolumeSnapshots []v1alpha1.BackupVolumeSnapshot `json:"volumeSnapshots,omitempty"`
+}
+
+// ManifestEntry points to one resource file of the archive. Plural is kept so
+// a restore can address the resource without API discovery.
+type ManifestEntry struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Plural     string `json:"plural"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+}
+
+// Resource is a resource exported into a backup together with its descriptor.
+type Resource struct {
+	Descriptor utils.ResourceDescriptor
+	Object     unstructured.Unstructured
+}
+
+// Archive is a backup read back into memory.
+type Archive struct {
+	Manifest  Manifest
+	Resources []Resource
+}
+
+// skippedKinds are never exported: they are recreated by their controllers,
+// bound to a single cluster or only describe runtime state.
+var skippedKinds = map[string]struct{}{
+	"Event":              {},
+	"Endpoints":          {},
+	"EndpointSlice":      {},
+	"Lease":              {},
+	"ControllerRevision": {},
+	"PodMetrics":         {},
+	// snapshots of the workspace are recorded in the manifest instead
+	"VolumeSnapshot": {},
+	// cert-manager issues these again for every Certificate
+	"CertificateRequest": {},
+	"Order":              {},
+	"Challenge":          {},
+}
+
+// strippedAnnotations are written by controllers for a specific cluster and
+// would confuse them after a restore.
+var strippedAnnotations = []string{
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/selected-node",
+	"deployment.kubernetes.io/revision",
+}
+
+// Exportable reports whether a stored resource belongs into a backup. Objects
+// with a controlling owner (pods of a ReplicaSet, ReplicaSets of a Deployment,
+// ...) are recreated by that owner and left out, as are the objects Kubernetes
+// creates in every namespace.
+func Exportable(obj *unstructured.Unstructured, excludedKinds []string) bool {
+	kind := obj.GetKind()
+	if _, skip := skippedKinds[kind]; skip || slices.Contains(excludedKinds, kind) {
+		return false
+	}
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.Controller != nil && *owner.Controller {
+			return false
+		}
+	}
+	switch {
+	case kind == "ConfigMap" && obj.GetName() == "kube-root-ca.crt":
+		return false
+	case kind == "ServiceAccount" && obj.GetName() == "default":
+		return false
+	case kind == "Secret":
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		return secretType != "kubernetes.io/service-account-token"
+	}
+	return true
+}
+
+// Sanitize returns a copy of obj without the server-populated fields, so it can
+// be created again in the same or another cluster.
+func Sanitize(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	clean := obj.DeepCopy()
+	for _, field := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp", "deletionGracePeriodSeconds", "managedFields", "selfLink", "ownerReferences"} {
+		unstructured.RemoveNestedField(clean.Object, "metadata", field)
+	}
+	delete(clean.Object, "status")
+
+	if annotations := clean.GetAnnotations(); len(annotations) > 0 {
+		for _, key := range strippedAnnotations {
+			delete(annotations, key)
+		}
+		clean.SetAnnotations(annotations)
+	}
+
+	switch clean.GetKind() {
+	case "Service":
+		unstructured.RemoveNestedField(clean.Object, "spec", "clusterIP")
+		unstructured.RemoveNestedField(clean.Object, "spec", "clusterIPs")
+		unstructured.RemoveNestedField(clean.Object, "spec", "healthCheckNodePort")
+	case "PersistentVolumeClaim":
+		unstructured.RemoveNestedField(clean.Object, "spec", "volumeName")
+	case "Pod":
+		unstructured.RemoveNestedField(clean.Object, "spec", "nodeName")
+	case "Job":
+		// The selector is generated from the job's uid unless set manually.
+		if manual, _, _ := unstructured.NestedBool(clean.Object, "spec", "manualSelector"); !manual {
+			unstructured.RemoveNestedField(clean.Object, "spec", "selector")
+			for _, label := range []string{"controller-uid", "batch.kubernetes.io/controller-uid"} {
+				unstructured.RemoveNestedField(clean.Object, "spec", "template", "metadata", "labels", label)
+			}
+		}
+	}
+	return clean
+}
+
+// resourcePath is the location of a resource inside the archive:
+// resources/<namespace>/<kind>.<group>/<name>.yaml
+func resourcePath(apiVersion string, kind string, namespace string, name string) string {
+	group := "core"
+	if gv, err := schema.ParseGroupVersion(apiVersion); err == nil && gv.Group != "" {
+		group = gv.Group
+	}
+	return path.Join("resources", namespace, strings.ToLower(kind)+"."+group, name+".yaml")
+}
+
+// WriteArchive writes a gzip compressed tarball with the manifest and one YAML
+// file per resource. The resource entries of the manifest are filled in here.
+func WriteArchive(w io.Writer, manifest Manifest, resources []Resource) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest.FormatVersion = FormatVersion
+	manifest.Resources = make([]ManifestEntry, 0, len(resources))
+	files := make([][]byte, 0, len(resources))
+	for _, resource := range resources {
+		data, err := yaml.Marshal(resource.Object.Object)
+		if err != nil {
+			return fmt.Errorf("marshal %s %s/%s: %w", resource.Object.GetKind(), resource.Object.GetNamespace(), resource.Object.GetName(), err)
+		}
+		files = append(files, data)
+		manifest.Resources = append(manifest.Resources, ManifestEntry{
+			ApiVersion: resource.Descriptor.ApiVersion,
+			Kind:       resource.Descriptor.Kind,
+			Plural:     resource.Descriptor.Plural,
+			Namespace:  resource.Object.GetNamespace(),
+			Name:       resource.Object.GetName(),
+			Path:       resourcePath(resource.Descriptor.ApiVersion, resource.Descriptor.Kind, resource.Object.GetNamespace(), resource.Object.GetName()),
+		})
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	if err := writeTarFile(tw, manifestFile, manifestData, manifest.CreatedAt); err != nil {
+		return err
+	}
+	for i, entry := range manifest.Resources {
+		if err := writeTarFile(tw, entry.Path, files[i], manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close tar: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("close gzip: %w", err)
+	}
+	return nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("write header of %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// ReadArchive parses an archive written by WriteArchive.
+func ReadArchive(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("open gzip: %w", err)
+	}
+	defer gz.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar: %w", err)
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			return nil, fmt.Errorf("read %s: %w", header.Name, err)
+		}
+		files[header.Name] = buf.Bytes()
+	}
+
+	manifestData, ok := files[manifestFile]
+	if !ok {
+		return nil, fmt.Errorf("archive has no %s", manifestFile)
+	}
+	archive := &Archive{}
+	if err := json.Unmarshal(manifestData, &archive.Manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	if archive.Manifest.FormatVersion < 1 || archive.Manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d (supported: 1-%d)", archive.Manifest.FormatVersion, FormatVersion)
+	}
+
+	for _, entry := range archive.Manifest.Resources {
+		data, ok := files[entry.Path]
+		if !ok {
+			return nil, fmt.Errorf("archive is missing %s", entry.Path)
+		}
+		object := unstructured.Unstructured{}
+		if err := yaml.Unmarshal(data, &object.Object); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", entry.Path, err)
+		}
+		archive.Resources = append(archive.Resources, Resource{
+			Descriptor: utils.ResourceDescriptor{
+				Kind:       entry.Kind,
+				Plural:     entry.Plural,
+				ApiVersion: entry.ApiVersion,
+				Namespaced: true,
+			},
+			Object: object,
+		})
+	}
+	return archive, nil
+}
@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"bytes"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func object(apiVersion string, kind string, namespace string, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{Object: map[string]any{}}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func TestExportable(t *testing.T) {
+	deployment := object("apps/v1", "Deployment", "shop", "api")
+	assert.True(t, Exportable(&deployment, nil))
+	assert.False(t, Exportable(&deployment, []string{"Deployment"}))
+
+	replicaSet := object("apps/v1", "ReplicaSet", "shop", "api-7d9f")
+	replicaSet.Object["metadata"].(map[string]any)["ownerReferences"] = []any{
+		map[string]any{"apiVersion": "apps/v1", "kind": "Deployment", "name": "api", "uid": "1", "controller": true},
+	}
+	assert.False(t, Exportable(&replicaSet, nil))
+
+	event := object("v1", "Event", "shop", "api.1")
+	assert.False(t, Exportable(&event, nil))
+
+	rootCA := object("v1", "ConfigMap", "shop", "kube-root-ca.crt")
+	assert.False(t, Exportable(&rootCA, nil))
+
+	token := object("v1", "Secret", "shop", "api-token")
+	token.Object["type"] = "kubernetes.io/service-account-token"
+	assert.False(t, Exportable(&token, nil))
+
+	secret := object("v1", "Secret", "shop", "db")
+	secret.Object["type"] = "Opaque"
+	assert.True(t, Exportable(&secret, nil))
+}
+
+func TestSanitize(t *testing.T) {
+	service := object("v1", "Service", "shop", "api")
+	service.SetUID("1234")
+	service.SetResourceVersion("42")
+	service.SetAnnotations(map[string]string{"team": "shop", "deployment.kubernetes.io/revision": "3"})
+	service.Object["spec"] = map[string]any{"clusterIP": "10.0.0.1", "clusterIPs": []any{"10.0.0.1"}, "type": "ClusterIP"}
+	service.Object["status"] = map[string]any{"loadBalancer": map[string]any{}}
+
+	clean := Sanitize(&service)
+
+	assert.Empty(t, clean.GetUID())
+	assert.Empty(t, clean.GetResourceVersion())
+	assert.Equal(t, map[string]string{"team": "shop"}, clean.GetAnnotations())
+	assert.NotContains(t, clean.Object, "status")
+	assert.Equal(t, map[string]any{"type": "ClusterIP"}, clean.Object["spec"])
+	// the original is untouched
+	assert.Equal(t, "42", service.GetResourceVersion())
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	configMap := object("v1", "ConfigMap", "shop", "settings")
+	configMap.Object["data"] = map[string]any{"mode": "production"}
+	deployment := object("apps/v1", "Deployment", "shop", "api")
+
+	manifest := Manifest{
+		Backup:          "nightly",
+		Workspace:       "shop",
+		CreatedAt:       time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC),
+		Namespaces:      []string{"shop"},
+		VolumeSnapshots: []v1alpha1.BackupVolumeSnapshot{{Namespace: "shop", PersistentVolumeClaim: "data", Name: "nightly-data"}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, WriteArchive(&buf, manifest, []Resource{
+		{Descriptor: utils.ConfigMapResource, Object: configMap},
+		{Descriptor: utils.ResourceDescriptor{Kind: "Deployment", Plural: "deployments", ApiVersion: "apps/v1", Namespaced: true}, Object: deployment},
+	}))
+
+	archive, err := ReadArchive(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, archive.Manifest.FormatVersion)
+	assert.Equal(t, "shop", archive.Manifest.Workspace)
+	assert.Equal(t, manifest.VolumeSnapshots, archive.Manifest.VolumeSnapshots)
+	require.Len(t, archive.Resources, 2)
+	assert.Equal(t, "resources/shop/configmap.core/settings.yaml", archive.Manifest.Resources[0].Path)
+	assert.Equal(t, "resources/shop/deployment.apps/api.yaml", archive.Manifest.Resources[1].Path)
+	assert.Equal(t, "configmaps", archive.Resources[0].Descriptor.Plural)
+	assert.Equal(t, "production", archive.Resources[0].Object.Object["data"].(map[string]any)["mode"])
+}
+
+func TestReadArchiveRejectsNewerFormat(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteArchive(&buf, Manifest{}, nil))
+	archive, err := ReadArchive(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Empty(t, archive.Resources)
+
+	_, err = ReadArchive(bytes.NewReader([]byte("not a tarball")))
+	assert.Error(t, err)
+}
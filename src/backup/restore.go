@@ -0,0 +1,299 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/pmezard/go-difflib/difflib"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// RestoreOptions control how an archive is applied to the cluster.
+type RestoreOptions struct {
+	// NamespaceMapping maps namespaces of the archive to the namespaces the
+	// resources are restored into. Unmapped namespaces are restored as is.
+	NamespaceMapping map[string]string `json:"namespaceMapping,omitempty"`
+	// DryRun only computes what would change, including a diff for every
+	// existing resource, and writes nothing.
+	DryRun bool `json:"dryRun"`
+	// Overwrite replaces existing resources that differ from the archive.
+	// Without it they are left untouched and reported as skipped.
+	Overwrite bool `json:"overwrite"`
+	// RestoreVolumeData provisions PersistentVolumeClaims from the volume
+	// snapshots of the backup. Only possible for claims restored into their
+	// original namespace, as snapshots cannot be used across namespaces.
+	RestoreVolumeData bool `json:"restoreVolumeData"`
+}
+
+type RestoreAction string
+
+const (
+	RestoreActionCreate    RestoreAction = "create"
+	RestoreActionUpdate    RestoreAction = "update"
+	RestoreActionUnchanged RestoreAction = "unchanged"
+	RestoreActionSkip      RestoreAction = "skip"
+	RestoreActionFailed    RestoreAction = "failed"
+)
+
+// RestoreItem is the outcome for one resource of the archive.
+type RestoreItem struct {
+	ApiVersion      string        `json:"apiVersion"`
+	Kind            string        `json:"kind"`
+	Name            string        `json:"name"`
+	Namespace       string        `json:"namespace"`
+	SourceNamespace string        `json:"sourceNamespace"`
+	Action          RestoreAction `json:"action"`
+	Diff            string        `json:"diff,omitempty"`
+	Error           string        `json:"error,omitempty"`
+}
+
+type RestoreResult struct {
+	DryRun            bool          `json:"dryRun"`
+	CreatedNamespaces []string      `json:"createdNamespaces,omitempty"`
+	Items             []RestoreItem `json:"items"`
+	Warnings          []string      `json:"warnings,omitempty"`
+}
+
+// restoreOrder creates the resources others depend on first. Kinds not listed
+// follow in archive order.
+var restoreOrder = []string{
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"PersistentVolumeClaim",
+	"Role",
+	"RoleBinding",
+	"Service",
+}
+
+// PlannedResource is an archive resource moved into its target namespace.
+type PlannedResource struct {
+	Resource
+	SourceNamespace string
+}
+
+func restoreRank(kind string) int {
+	if index := slices.Index(restoreOrder, kind); index >= 0 {
+		return index
+	}
+	return len(restoreOrder)
+}
+
+// PlanRestore remaps the archive resources into their target namespaces and
+// returns them in restore order, together with warnings about data that
+// cannot be restored.
+func PlanRestore(archive *Archive, options RestoreOptions) ([]PlannedResource, []string) {
+	targetNamespace := func(namespace string) string {
+		if mapped, ok := options.NamespaceMapping[namespace]; ok && mapped != "" {
+			return mapped
+		}
+		return namespace
+	}
+
+	snapshots := map[string]string{}
+	for _, snapshot := range archive.Manifest.VolumeSnapshots {
+		snapshots[snapshot.Namespace+"/"+snapshot.PersistentVolumeClaim] = snapshot.Name
+	}
+
+	warnings := []string{}
+	planned := make([]PlannedResource, 0, len(archive.Resources))
+	for _, resource := range archive.Resources {
+		object := resource.Object.DeepCopy()
+		source := object.GetNamespace()
+		target := targetNamespace(source)
+		object.SetNamespace(target)
+
+		switch object.GetKind() {
+		case "RoleBinding":
+			// Service account subjects follow their namespace.
+			subjects, _, _ := unstructured.NestedSlice(object.Object, "subjects")
+			for i, subject := range subjects {
+				if entry, ok := subject.(map[string]any); ok {
+					if namespace, ok := entry["namespace"].(string); ok && namespace != "" {
+						entry["namespace"] = targetNamespace(namespace)
+						subjects[i] = entry
+					}
+				}
+			}
+			if subjects != nil {
+				_ = unstructured.SetNestedSlice(object.Object, subjects, "subjects")
+			}
+		case "PersistentVolumeClaim":
+			snapshot, ok := snapshots[source+"/"+object.GetName()]
+			if !ok || !options.RestoreVolumeData {
+				break
+			}
+			if source != target {
+				warnings = append(warnings, fmt.Sprintf("PersistentVolumeClaim %s/%s is restored empty: snapshot %q cannot be used from namespace %q", source, object.GetName(), snapshot, target))
+				break
+			}
+			_ = unstructured.SetNestedMap(object.Object, map[string]any{
+				"apiGroup": "snapshot.storage.k8s.io",
+				"kind":     "VolumeSnapshot",
+				"name":     snapshot,
+			}, "spec", "dataSource")
+		}
+
+		planned = append(planned, PlannedResource{
+			Resource:        Resource{Descriptor: resource.Descriptor, Object: *object},
+			SourceNamespace: source,
+		})
+	}
+
+	slices.SortStableFunc(planned, func(a, b PlannedResource) int {
+		return restoreRank(a.Object.GetKind()) - restoreRank(b.Object.GetKind())
+	})
+	return planned, warnings
+}
+
+// Restore applies an archive through the dynamic client. Missing target
+// namespaces are created first; every resource is then created, updated or
+// left alone depending on the live state and the options. Cancelling ctx
+// stops the restore before the next resource.
+func Restore(ctx context.Context, client dynamic.Interface, archive *Archive, options RestoreOptions) (*RestoreResult, error) {
+	planned, warnings := PlanRestore(archive, options)
+	result := &RestoreResult{DryRun: options.DryRun, Items: []RestoreItem{}, Warnings: warnings}
+
+	namespaces := map[string]struct{}{}
+	for _, resource := range planned {
+		namespaces[resource.Object.GetNamespace()] = struct{}{}
+	}
+	namespaceClient := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"})
+	for _, namespace := range slices.Sorted(maps.Keys(namespaces)) {
+		_, err := namespaceClient.Get(ctx, namespace, metav1.GetOptions{})
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("look up namespace %q: %w", namespace, err)
+		}
+		result.CreatedNamespaces = append(result.CreatedNamespaces, namespace)
+		if options.DryRun {
+			continue
+		}
+		object := &unstructured.Unstructured{}
+		object.SetAPIVersion("v1")
+		object.SetKind("Namespace")
+		object.SetName(namespace)
+		if _, err := namespaceClient.Create(ctx, object, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("create namespace %q: %w", namespace, err)
+		}
+	}
+
+	for index, resource := range planned {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("restore stopped after %d of %d resources: %w", index, len(planned), err)
+		}
+		result.Items = append(result.Items, restoreResource(ctx, client, resource, options))
+	}
+	return result, nil
+}
+
+func restoreResource(ctx context.Context, client dynamic.Interface, resource PlannedResource, options RestoreOptions) RestoreItem {
+	desired := &resource.Object
+	item := RestoreItem{
+		ApiVersion:      resource.Descriptor.ApiVersion,
+		Kind:            resource.Descriptor.Kind,
+		Name:            desired.GetName(),
+		Namespace:       desired.GetNamespace(),
+		SourceNamespace: resource.SourceNamespace,
+	}
+	fail := func(err error) RestoreItem {
+		item.Action = RestoreActionFailed
+		item.Error = err.Error()
+		return item
+	}
+
+	gv, err := schema.ParseGroupVersion(resource.Descriptor.ApiVersion)
+	if err != nil {
+		return fail(err)
+	}
+	resourceClient := client.Resource(gv.WithResource(resource.Descriptor.Plural)).Namespace(desired.GetNamespace())
+
+	live, err := resourceClient.Get(ctx, desired.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		item.Action = RestoreActionCreate
+		if !options.DryRun {
+			if _, err := resourceClient.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+				return fail(err)
+			}
+		}
+		return item
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	diff, err := resourceDiff(Sanitize(live), desired)
+	if err != nil {
+		return fail(err)
+	}
+	if diff == "" {
+		item.Action = RestoreActionUnchanged
+		return item
+	}
+	item.Diff = diff
+	if !options.Overwrite {
+		item.Action = RestoreActionSkip
+		return item
+	}
+
+	item.Action = RestoreActionUpdate
+	if !options.DryRun {
+		update := desired.DeepCopy()
+		update.SetResourceVersion(live.GetResourceVersion())
+		if _, err := resourceClient.Update(ctx, update, metav1.UpdateOptions{}); err != nil {
+			return fail(err)
+		}
+	}
+	return item
+}
+
+// resourceDiff returns a unified diff from the live to the archived YAML, or ""
+// when they are equal. Fields the API server defaults but the archive omits
+// are ignored, so an untouched resource does not show up as changed.
+func resourceDiff(live *unstructured.Unstructured, desired *unstructured.Unstructured) (string, error) {
+	pruneToDesired(live.Object, desired.Object)
+	a, err := yaml.Marshal(live.Object)
+	if err != nil {
+		return "", err
+	}
+	b, err := yaml.Marshal(desired.Object)
+	if err != nil {
+		return "", err
+	}
+	if string(a) == string(b) {
+		return "", nil
+	}
+	label := desired.GetNamespace() + "/" + desired.GetName()
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(a)),
+		B:        difflib.SplitLines(string(b)),
+		FromFile: "live " + label,
+		ToFile:   "backup " + label,
+		Context:  3,
+	})
+}
+
+// pruneToDesired drops map keys from live that desired does not have at all.
+func pruneToDesired(live map[string]any, desired map[string]any) {
+	for key, value := range live {
+		desiredValue, ok := desired[key]
+		if !ok {
+			delete(live, key)
+			continue
+		}
+		liveMap, liveIsMap := value.(map[string]any)
+		desiredMap, desiredIsMap := desiredValue.(map[string]any)
+		if liveIsMap && desiredIsMap {
+			pruneToDesired(liveMap, desiredMap)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"context"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testArchive() *Archive {
+	claim := object("v1", "PersistentVolumeClaim", "shop", "data")
+	binding := object("rbac.authorization.k8s.io/v1", "RoleBinding", "shop", "api")
+	binding.Object["subjects"] = []any{map[string]any{"kind": "ServiceAccount", "name": "api", "namespace": "shop"}}
+	configMap := object("v1", "ConfigMap", "shop", "settings")
+	configMap.Object["data"] = map[string]any{"mode": "production"}
+
+	return &Archive{
+		Manifest: Manifest{
+			FormatVersion:   FormatVersion,
+			VolumeSnapshots: []v1alpha1.BackupVolumeSnapshot{{Namespace: "shop", PersistentVolumeClaim: "data", Name: "nightly-data"}},
+		},
+		Resources: []Resource{
+			{Descriptor: utils.ResourceDescriptor{Kind: "RoleBinding", Plural: "rolebindings", ApiVersion: "rbac.authorization.k8s.io/v1", Namespaced: true}, Object: binding},
+			{Descriptor: utils.ResourceDescriptor{Kind: "PersistentVolumeClaim", Plural: "persistentvolumeclaims", ApiVersion: "v1", Namespaced: true}, Object: claim},
+			{Descriptor: utils.ConfigMapResource, Object: configMap},
+		},
+	}
+}
+
+func TestPlanRestore(t *testing.T) {
+	planned, warnings := PlanRestore(testArchive(), RestoreOptions{RestoreVolumeData: true})
+	require.Len(t, planned, 3)
+	assert.Empty(t, warnings)
+	// dependencies first
+	assert.Equal(t, "ConfigMap", planned[0].Object.GetKind())
+	assert.Equal(t, "PersistentVolumeClaim", planned[1].Object.GetKind())
+	assert.Equal(t, "RoleBinding", planned[2].Object.GetKind())
+	dataSource, _, _ := unstructured.NestedString(planned[1].Object.Object, "spec", "dataSource", "name")
+	assert.Equal(t, "nightly-data", dataSource)
+
+	planned, warnings = PlanRestore(testArchive(), RestoreOptions{NamespaceMapping: map[string]string{"shop": "shop-copy"}, RestoreVolumeData: true})
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "restored empty")
+	for _, resource := range planned {
+		assert.Equal(t, "shop-copy", resource.Object.GetNamespace())
+		assert.Equal(t, "shop", resource.SourceNamespace)
+	}
+	_, found, _ := unstructured.NestedMap(planned[1].Object.Object, "spec", "dataSource")
+	assert.False(t, found)
+	subjects, _, _ := unstructured.NestedSlice(planned[2].Object.Object, "subjects")
+	assert.Equal(t, "shop-copy", subjects[0].(map[string]any)["namespace"])
+}
+
+func TestRestore(t *testing.T) {
+	live := object("v1", "ConfigMap", "shop", "settings")
+	live.SetResourceVersion("7")
+	live.Object["data"] = map[string]any{"mode": "staging"}
+	namespace := object("v1", "Namespace", "", "shop")
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &live, &namespace)
+	ctx := context.Background()
+
+	result, err := Restore(ctx, client, testArchive(), RestoreOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Empty(t, result.CreatedNamespaces)
+	require.Len(t, result.Items, 3)
+	assert.Equal(t, RestoreActionSkip, result.Items[0].Action)
+	assert.Contains(t, result.Items[0].Diff, "-  mode: staging")
+	assert.Contains(t, result.Items[0].Diff, "+  mode: production")
+	assert.Equal(t, RestoreActionCreate, result.Items[1].Action)
+
+	// a dry run writes nothing
+	_, err = client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}).Namespace("shop").Get(ctx, "data", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	result, err = Restore(ctx, client, testArchive(), RestoreOptions{Overwrite: true, NamespaceMapping: map[string]string{"shop": "shop-copy"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shop-copy"}, result.CreatedNamespaces)
+	for _, item := range result.Items {
+		assert.Equal(t, RestoreActionCreate, item.Action, item.Error)
+	}
+
+	result, err = Restore(ctx, client, testArchive(), RestoreOptions{Overwrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, RestoreActionUpdate, result.Items[0].Action, result.Items[0].Error)
+	updated, err := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}).Namespace("shop").Get(ctx, "settings", metav1.GetOptions{})
+	require.NoError(t, err)
+	mode, _, _ := unstructured.NestedString(updated.Object, "data", "mode")
+	assert.Equal(t, "production", mode)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = Restore(cancelled, client, testArchive(), RestoreOptions{Overwrite: true})
+	assert.ErrorIs(t, err, context.Canceled, "a cancelled request stops the restore")
+}
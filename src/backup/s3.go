@@ -0,0 +1,204 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mogenius-operator/src/crds/v1alpha1"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// s3Storage talks to S3-compatible object stores with plain HTTP requests
+// signed with AWS Signature Version 4. Buckets are addressed path-style, which
+// every S3 implementation (AWS, MinIO, Ceph, ...) understands.
+type s3Storage struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	prefix    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+func NewS3Storage(spec v1alpha1.BackupS3Storage, accessKey string, secretKey string) (Storage, error) {
+	endpoint, err := url.Parse(spec.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("storage.s3.endpoint %q is not a valid http(s) URL", spec.Endpoint)
+	}
+	if spec.Bucket == "" {
+		return nil, fmt.Errorf("storage.s3.bucket must not be empty")
+	}
+	region := spec.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &s3Storage{
+		endpoint:  endpoint,
+		bucket:    spec.Bucket,
+		region:    region,
+		prefix:    strings.Trim(spec.Prefix, "/"),
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 10 * time.Minute},
+		now:       time.Now,
+	}, nil
+}
+
+func (self *s3Storage) objectKey(key string) string {
+	if self.prefix == "" {
+		return key
+	}
+	return self.prefix + "/" + key
+}
+
+func (self *s3Storage) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := self.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp, "upload", self.Describe(key))
+	}
+	return nil
+}
+
+func (self *s3Storage) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := self.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, self.Describe(key))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(resp, "download", self.Describe(key))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (self *s3Storage) Delete(ctx context.Context, key string) error {
+	resp, err := self.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// S3 answers 204 whether or not the object existed.
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp, "delete", self.Describe(key))
+	}
+	return nil
+}
+
+func (self *s3Storage) Describe(key string) string {
+	return fmt.Sprintf("s3://%s/%s", self.bucket, self.objectKey(key))
+}
+
+func (self *s3Storage) do(ctx context.Context, method string, key string, body []byte) (*http.Response, error) {
+	target := *self.endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + self.bucket + "/" + self.objectKey(key)
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	signS3Request(req, body, self.accessKey, self.secretKey, self.region, self.now().UTC())
+
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, self.Describe(key), err)
+	}
+	return resp, nil
+}
+
+func s3Error(resp *http.Response, action string, location string) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("%s %s failed with %s: %s", action, location, resp.Status, strings.TrimSpace(string(message)))
+}
+
+// signS3Request adds the AWS Signature Version 4 headers to req. Every header
+// already set on req is signed together with host, x-amz-date and
+// x-amz-content-sha256.
+func signS3Request(req *http.Request, body []byte, accessKey string, secretKey string, region string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	parts := []string{}
+	for _, key := range keys {
+		values := slices.Clone(query[key])
+		slices.Sort(values)
+		for _, value := range values {
+			parts = append(parts, url.QueryEscape(key)+"="+strings.ReplaceAll(url.QueryEscape(value), "+", "%20"))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
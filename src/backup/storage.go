@@ -0,0 +1,120 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mogenius-operator/src/crds/v1alpha1"
+	"os"
+	"path/filepath"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Storage keeps backup archives under object keys like
+// "<workspace>/<backup>-<timestamp>.tar.gz".
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	// Describe returns a human readable location of key, e.g. for status messages.
+	Describe(key string) string
+}
+
+// ErrNotFound is returned by Storage.Get for a key that does not exist.
+var ErrNotFound = errors.New("backup archive not found")
+
+// NewStorage resolves the storage target of a WorkspaceBackup. volumeRoot is
+// the mount path of the operator's backup volume (MO_BACKUP_PATH); S3
+// credentials are read from a Secret in namespace.
+func NewStorage(ctx context.Context, clientset kubernetes.Interface, namespace string, spec v1alpha1.BackupStorage, volumeRoot string) (Storage, error) {
+	switch {
+	case spec.PVC != nil && spec.S3 != nil:
+		return nil, fmt.Errorf("storage.pvc and storage.s3 are mutually exclusive")
+	case spec.PVC != nil:
+		if volumeRoot == "" {
+			return nil, fmt.Errorf("storage.pvc needs a backup volume, but MO_BACKUP_PATH is not set (chart value features.backups.persistence.enabled)")
+		}
+		return newVolumeStorage(volumeRoot, spec.PVC.Path)
+	case spec.S3 != nil:
+		secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, spec.S3.CredentialsSecret, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("read credentials secret %q: %w", spec.S3.CredentialsSecret, err)
+		}
+		accessKey := string(secret.Data["AWS_ACCESS_KEY_ID"])
+		secretKey := string(secret.Data["AWS_SECRET_ACCESS_KEY"])
+		if accessKey == "" || secretKey == "" {
+			return nil, fmt.Errorf("secret %q must contain AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", spec.S3.CredentialsSecret)
+		}
+		return NewS3Storage(*spec.S3, accessKey, secretKey)
+	default:
+		return nil, fmt.Errorf("either storage.pvc or storage.s3 must be set")
+	}
+}
+
+type volumeStorage struct {
+	root string
+}
+
+func newVolumeStorage(volumeRoot string, subPath string) (*volumeStorage, error) {
+	if subPath != "" && !filepath.IsLocal(subPath) {
+		return nil, fmt.Errorf("storage.pvc.path %q must be a relative path inside the backup volume", subPath)
+	}
+	return &volumeStorage{root: filepath.Join(volumeRoot, subPath)}, nil
+}
+
+func (self *volumeStorage) path(key string) (string, error) {
+	if !filepath.IsLocal(key) {
+		return "", fmt.Errorf("invalid archive key %q", key)
+	}
+	return filepath.Join(self.root, key), nil
+}
+
+// Put writes to a temporary file first so a crash never leaves a truncated
+// archive under the final name.
+func (self *volumeStorage) Put(ctx context.Context, key string, data []byte) error {
+	target, err := self.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("create backup directory: %w", err)
+	}
+	tmp := target + ".partial"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("write archive: %w", err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("write archive: %w", err)
+	}
+	return nil
+}
+
+func (self *volumeStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	target, err := self.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(target)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, self.Describe(key))
+	}
+	return data, err
+}
+
+func (self *volumeStorage) Delete(ctx context.Context, key string) error {
+	target, err := self.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (self *volumeStorage) Describe(key string) string {
+	return "pvc:" + filepath.Join(self.root, key)
+}
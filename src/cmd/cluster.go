@@ -11,13 +11,16 @@ import (
 	"mogenius-operator/src/containerenumerator"
 	"mogenius-operator/src/core"
 	"mogenius-operator/src/cpumonitor"
+	"mogenius-operator/src/deprecations"
 	"mogenius-operator/src/flux"
 	"mogenius-operator/src/helm"
+	"mogenius-operator/src/iomonitor"
 	mokubernetes "mogenius-operator/src/kubernetes"
 	"mogenius-operator/src/logging"
 	"mogenius-operator/src/networkmonitor"
 	"mogenius-operator/src/rammonitor"
 	moreconciler "mogenius-operator/src/reconciler"
+	"mogenius-operator/src/security"
 	"mogenius-operator/src/services"
 	"mogenius-operator/src/shell"
 	"mogenius-operator/src/shutdown"
@@ -38,28 +41,37 @@ import (
 // It embeds baseSystems so that fields like versionModule and logger are promoted.
 type clusterSystems struct {
 	baseSystems
-	valkeyClient          valkeyclient.ValkeyClient
-	watcherModule         watcher.WatcherModule
-	jobClients            []websocket.WebsocketClient
-	eventConnectionClient websocket.WebsocketClient
-	networkmonitor        networkmonitor.NetworkMonitor
-	mocore                core.Core
-	moKubernetes          core.MoKubernetes
-	workspaceManager      core.WorkspaceManager
-	apiModule             core.Api
-	socketApi             core.SocketApi
-	httpApi               core.HttpService
-	xtermService          core.XtermService
-	aiWebsocketConnection ai.AiWebsocketConnection
-	valkeyLoggerService   core.ValkeyLogger
-	podStatsCollector     core.PodStatsCollector
-	nodeMetricsCollector  core.NodeMetricsCollector
-	dbstatsService        core.ValkeyStatsDb
-	leaderElector         core.LeaderElector
-	reconciler            moreconciler.Reconciler
-	sealedSecret          core.SealedSecretManager
-	argocd                argocd.Argocd
-	aiManager             ai.AiManager
+	valkeyClient             valkeyclient.ValkeyClient
+	watcherModule            watcher.WatcherModule
+	jobClients               []websocket.WebsocketClient
+	eventConnectionClient    websocket.WebsocketClient
+	eventOutbox              core.EventOutbox
+	networkmonitor           networkmonitor.NetworkMonitor
+	mocore                   core.Core
+	moKubernetes             core.MoKubernetes
+	workspaceManager         core.WorkspaceManager
+	apiModule                core.Api
+	socketApi                core.SocketApi
+	httpApi                  core.HttpService
+	localApi                 core.LocalApiService
+	xtermService             core.XtermService
+	aiWebsocketConnection    ai.AiWebsocketConnection
+	valkeyLoggerService      core.ValkeyLogger
+	podStatsCollector        core.PodStatsCollector
+	nodeMetricsCollector     core.NodeMetricsCollector
+	dbstatsService           core.ValkeyStatsDb
+	costEngine               core.CostEngine
+	gitOpsDriftDetector      core.GitOpsDriftDetector
+	securityScanner          core.SecurityScanner
+	imageScanner             core.ImageScanner
+	watchSubscriptionService core.WatchSubscriptionService
+	fileTransferService      core.FileTransferService
+	notificationService      core.NotificationService
+	leaderElector            core.LeaderElector
+	reconciler               moreconciler.Reconciler
+	sealedSecret             core.SealedSecretManager
+	argocd                   argocd.Argocd
+	aiManager                ai.AiManager
 }
 
 // initializeClusterSystems layers all cluster-mode services on top of the shared base.
@@ -91,11 +103,15 @@ func initializeClusterSystems(
 		)
 		shutdown.Add(jobClients[i].Terminate)
 	}
-	eventConnectionClient := websocket.NewWebsocketClient(
+	eventsWebsocketClient := websocket.NewWebsocketClient(
 		logManagerModule.CreateLogger("websocket-events-client"),
 		"events",
 	)
-	shutdown.Add(eventConnectionClient.Terminate)
+	shutdown.Add(eventsWebsocketClient.Terminate)
+	// Events written while the events connection is down are queued in
+	// Valkey and sent once it is back.
+	eventOutbox := core.NewEventOutbox(logManagerModule.CreateLogger("events-outbox"), configModule, base.valkeyClient, eventsWebsocketClient)
+	eventConnectionClient := websocket.WebsocketClient(eventOutbox)
 
 	// Emit real-time audit log events to the frontend via WebSocket.
 	// Called from the store's single dispatcher goroutine (write order
@@ -120,6 +136,7 @@ func initializeClusterSystems(
 	cpuMonitor := cpumonitor.NewCpuMonitor(logManagerModule.CreateLogger("cpu-monitor"), configModule, base.clientProvider, containerEnumerator)
 	ramMonitor := rammonitor.NewRamMonitor(logManagerModule.CreateLogger("ram-monitor"), configModule, base.clientProvider, containerEnumerator)
 	networkMonitor := networkmonitor.NewNetworkMonitor(logManagerModule.CreateLogger("network-monitor"), configModule, containerEnumerator, configModule.Get("MO_HOST_PROC_PATH"))
+	ioMonitor := iomonitor.NewIoMonitor(logManagerModule.CreateLogger("io-monitor"), configModule, containerEnumerator)
 
 	ownerCacheService := store.NewOwnerCacheService(logManagerModule.CreateLogger("owner-cache"), configModule)
 	// The leader elector is constructed before the AI manager because agent
@@ -132,6 +149,7 @@ func initializeClusterSystems(
 	ai.K8sDeleteUnstructuredResource = mokubernetes.DeleteUnstructuredResource
 	ai.K8sCreateUnstructuredResource = mokubernetes.CreateUnstructuredResource
 	ai.K8sGetUnstructuredResourceFromStore = mokubernetes.GetUnstructuredResourceFromStore
+	ai.K8sGetUnstructuredResource = mokubernetes.GetUnstructuredResource
 	ai.K8sGetPodLogs = mokubernetes.GetPodLogs
 
 	// Package-level setups for subsystems that are cluster-mode-only.
@@ -158,9 +176,21 @@ func initializeClusterSystems(
 	apiModule := core.NewApi(logManagerModule.CreateLogger("api"), base.valkeyClient, configModule)
 	aiApi := core.NewAiApi(logManagerModule.CreateLogger("apApi"), aiManager)
 	httpApi := core.NewHttpApi(logManagerModule, configModule)
+	localApi := core.NewLocalApiService(logManagerModule.CreateLogger("local-api"), configModule, base.clientProvider)
 	alertmanager := core.NewAlertmanagerService(logManagerModule.CreateLogger("alertmanager"), configModule)
 	socketApi := core.NewSocketApi(logManagerModule.CreateLogger("socketapi"), configModule, jobClients, eventConnectionClient, base.valkeyClient, argocdModule, fluxModule, alertmanager)
 	dbstatsService := core.NewValkeyStatsModule(logManagerModule.CreateLogger("db-stats"), configModule, base.valkeyClient, ownerCacheService)
+	costEngine := core.NewCostEngine(logManagerModule.CreateLogger("cost-engine"), configModule, base.valkeyClient, ownerCacheService)
+	workloadRecommender := core.NewWorkloadRecommender(logManagerModule.CreateLogger("workload-recommender"), configModule, base.valkeyClient, ownerCacheService)
+	policyEngine := core.NewPolicyEngine(logManagerModule.CreateLogger("policy-engine"), configModule)
+	gitOpsDriftDetector := core.NewGitOpsDriftDetector(logManagerModule.CreateLogger("gitops-drift"), configModule, base.valkeyClient, eventConnectionClient)
+	securityScanner := core.NewSecurityScanner(logManagerModule.CreateLogger("security-scanner"), configModule, base.valkeyClient)
+	imageScanner := core.NewImageScanner(logManagerModule.CreateLogger("image-scanner"), configModule, base.valkeyClient, ownerCacheService, aiManager)
+	upgradeReadinessChecker := core.NewUpgradeReadinessChecker(logManagerModule.CreateLogger("upgrade-readiness"), base.valkeyClient)
+	fileTransferService := core.NewFileTransferService(logManagerModule.CreateLogger("file-transfers"), configModule)
+	watchSubscriptionService := core.NewWatchSubscriptionService(logManagerModule.CreateLogger("watch-subscriptions"), configModule, base.valkeyClient, eventConnectionClient)
+	gitOpsWriter := core.NewGitOpsWriter(logManagerModule.CreateLogger("gitops-writer"), configModule, base.valkeyClient, base.clientProvider)
+	notificationService := core.NewNotificationService(logManagerModule.CreateLogger("notifications"), configModule, base.valkeyClient, base.clientProvider, alertmanager)
 	xtermService := core.NewXtermService(logManagerModule.CreateLogger("xterm-service"), dbstatsService)
 	aiWebsocketConnection := ai.NewAiWebsocketConnection(logManagerModule.CreateLogger("ai-websocket-connection"), aiManager)
 	valkeyLoggerService := core.NewValkeyLogger(base.valkeyClient, valkeyLogChannel)
@@ -172,45 +202,74 @@ func initializeClusterSystems(
 		cpuMonitor,
 		ramMonitor,
 		networkMonitor,
+		ioMonitor,
 	)
 	moKubernetes := core.NewMoKubernetes(logManagerModule.CreateLogger("mokubernetes"), configModule, base.clientProvider)
 	mocore := core.NewCore(logManagerModule.CreateLogger("core"), configModule, base.clientProvider, base.valkeyClient, eventConnectionClient, jobClients)
-	reconciler := moreconciler.NewReconcilerFactory(logManagerModule.CreateLogger("reconciler"), base.clientProvider, configModule, base.valkeyClient, aiManager).Build()
+	reconciler := moreconciler.NewReconcilerFactory(logManagerModule.CreateLogger("reconciler"), base.clientProvider, configModule, base.valkeyClient, aiManager, core.NewWorkspaceUtilizationProvider(apiModule, dbstatsService)).Build()
 	sealedSecret := core.NewSealedSecretManager(logManagerModule.CreateLogger("sealed-secret"), configModule, base.clientProvider)
 
 	// Link phase: wire service dependencies.
 	mocore.Link(moKubernetes)
 	podStatsCollector.Link(dbstatsService)
 	nodeMetricsCollector.Link(dbstatsService, leaderElector)
-	socketApi.Link(httpApi, xtermService, dbstatsService, apiModule, moKubernetes, sealedSecret, aiApi, aiWebsocketConnection)
+	socketApi.Link(httpApi, xtermService, dbstatsService, apiModule, moKubernetes, sealedSecret, aiApi, aiWebsocketConnection, costEngine, gitOpsDriftDetector, gitOpsWriter, notificationService, workloadRecommender, policyEngine, securityScanner, imageScanner, upgradeReadinessChecker, watchSubscriptionService, fileTransferService)
 	moKubernetes.Link(dbstatsService)
 	httpApi.Link(socketApi, dbstatsService, apiModule, reconciler)
+	localApi.Link(socketApi)
 	apiModule.Link(workspaceManager)
+	costEngine.Link(apiModule, leaderElector)
+	workloadRecommender.Link(apiModule)
+	upgradeReadinessChecker.Link(apiModule)
+	watchSubscriptionService.Link(apiModule)
+	gitOpsDriftDetector.Link(apiModule, leaderElector)
+	securityScanner.Link(apiModule, leaderElector)
+	imageScanner.Link(apiModule, leaderElector)
+	notificationService.Link(apiModule, leaderElector)
+	aiManager.Link(ai.ToolServices{
+		Policies: policyEngine,
+		ListSecurityFindings: func() ([]security.ResourceFindings, error) {
+			return securityScanner.ListFindings(core.SecurityFindingsRequest{})
+		},
+		GetUpgradeReadiness: func(minors int) (*deprecations.Report, error) {
+			return upgradeReadinessChecker.GetUpgradeReadiness(core.UpgradeReadinessRequest{Minors: minors})
+		},
+		AvailableResources: mokubernetes.GetAvailableResources,
+	}, notificationService.NotifyAiTask)
 
 	return clusterSystems{
-		baseSystems:           base,
-		valkeyClient:          base.valkeyClient,
-		watcherModule:         watcherModule,
-		jobClients:            jobClients,
-		eventConnectionClient: eventConnectionClient,
-		networkmonitor:        networkMonitor,
-		mocore:                mocore,
-		moKubernetes:          moKubernetes,
-		workspaceManager:      workspaceManager,
-		apiModule:             apiModule,
-		socketApi:             socketApi,
-		httpApi:               httpApi,
-		xtermService:          xtermService,
-		aiWebsocketConnection: aiWebsocketConnection,
-		valkeyLoggerService:   valkeyLoggerService,
-		podStatsCollector:     podStatsCollector,
-		nodeMetricsCollector:  nodeMetricsCollector,
-		dbstatsService:        dbstatsService,
-		leaderElector:         leaderElector,
-		reconciler:            reconciler,
-		sealedSecret:          sealedSecret,
-		argocd:                argocdModule,
-		aiManager:             aiManager,
+		baseSystems:              base,
+		valkeyClient:             base.valkeyClient,
+		watcherModule:            watcherModule,
+		jobClients:               jobClients,
+		eventConnectionClient:    eventConnectionClient,
+		eventOutbox:              eventOutbox,
+		networkmonitor:           networkMonitor,
+		mocore:                   mocore,
+		moKubernetes:             moKubernetes,
+		workspaceManager:         workspaceManager,
+		apiModule:                apiModule,
+		socketApi:                socketApi,
+		httpApi:                  httpApi,
+		localApi:                 localApi,
+		xtermService:             xtermService,
+		aiWebsocketConnection:    aiWebsocketConnection,
+		valkeyLoggerService:      valkeyLoggerService,
+		podStatsCollector:        podStatsCollector,
+		nodeMetricsCollector:     nodeMetricsCollector,
+		dbstatsService:           dbstatsService,
+		costEngine:               costEngine,
+		gitOpsDriftDetector:      gitOpsDriftDetector,
+		securityScanner:          securityScanner,
+		imageScanner:             imageScanner,
+		watchSubscriptionService: watchSubscriptionService,
+		fileTransferService:      fileTransferService,
+		notificationService:      notificationService,
+		leaderElector:            leaderElector,
+		reconciler:               reconciler,
+		sealedSecret:             sealedSecret,
+		argocd:                   argocdModule,
+		aiManager:                aiManager,
 	}
 }
 
@@ -253,12 +312,18 @@ func startClusterSystems(logManagerModule logging.SlogManager, configModule *con
 	}
 	logStep("Core initialized (valkey, cluster secret, CRDs)")
 
+	systems.eventOutbox.Run()
+	logStep("Events outbox started")
+
 	systems.httpApi.Run()
 	logStep("HTTP API server started on " + configModule.Get("MO_HTTP_ADDR"))
 
 	systems.socketApi.Run()
 	logStep("Socket API started")
 
+	systems.localApi.Run()
+	logStep("Local API started")
+
 	systems.podStatsCollector.Run()
 	logStep("Pod stats collector started")
 
@@ -271,6 +336,27 @@ func startClusterSystems(logManagerModule logging.SlogManager, configModule *con
 	systems.dbstatsService.Run()
 	logStep("DB stats service started")
 
+	systems.costEngine.Run()
+	logStep("Cost engine started")
+
+	systems.gitOpsDriftDetector.Run()
+	logStep("GitOps drift detector started")
+
+	systems.securityScanner.Run()
+	logStep("Security scanner started")
+
+	systems.imageScanner.Run()
+	logStep("Image scanner started")
+
+	systems.notificationService.Run()
+	logStep("Notification service started")
+
+	systems.watchSubscriptionService.Run()
+	logStep("Watch subscriptions started")
+
+	systems.fileTransferService.Run()
+	logStep("File transfers started")
+
 	systems.leaderElector.OnLeading(func() {
 		systems.reconciler.Start()
 		logStep("Reconciler started")
@@ -297,7 +383,7 @@ func startClusterSystems(logManagerModule logging.SlogManager, configModule *con
 	// the first registration failure. That is not fatal — the other kinds are
 	// watched — so log it and continue instead of aborting startup and
 	// leaving the operator without a websocket connection.
-	err := mokubernetes.WatchStoreResources(systems.watcherModule, systems.aiManager, systems.eventConnectionClient)
+	err := mokubernetes.WatchStoreResources(systems.watcherModule, systems.aiManager, systems.eventConnectionClient, systems.watchSubscriptionService.OnResourceEvent)
 	if err != nil {
 		cmdLogger.Error("some resources could not be watched, continuing with the rest", "error", err)
 	}
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"mogenius-operator/src/assert"
 	"mogenius-operator/src/config"
+	"mogenius-operator/src/core"
 	"mogenius-operator/src/helm"
 	"mogenius-operator/src/logging"
 	"mogenius-operator/src/secrets"
@@ -178,6 +179,29 @@ func LoadConfigDeclarations(configModule *config.Config) {
 		DefaultValue: new(":1337"),
 		Description:  new("address of the controllers http api server"),
 	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_LOCAL_API_ENABLED",
+		DefaultValue: new("false"),
+		Description:  new("serve every socket pattern as `POST /api/v1/<pattern>` for in-cluster callers authenticated by a Kubernetes token mapped to a mogenius User"),
+		Type:         new(config.ConfigVariableTypeBool),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_LOCAL_API_ADDR",
+		DefaultValue: new(":1338"),
+		Description:  new("address of the local pattern api server (only used when MO_LOCAL_API_ENABLED is set)"),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_PATTERN_AUTHORIZATION",
+		DefaultValue: new("enforce"),
+		Description:  new(`check every pattern request against the caller's Grants: "disabled", "audit" (log denials only) or "enforce" (reject with 403)`),
+		Validate: func(value string) error {
+			allowedValues := []string{"disabled", "audit", "enforce"}
+			if !slices.Contains(allowedValues, value) {
+				return fmt.Errorf("'MO_PATTERN_AUTHORIZATION' needs to be one of: %#v", allowedValues)
+			}
+			return nil
+		},
+	})
 	configModule.Declare(config.ConfigDeclaration{
 		Key:          "CLUSTER_DOMAIN",
 		DefaultValue: new("cluster.local"),
@@ -328,6 +352,76 @@ func LoadConfigDeclarations(configModule *config.Config) {
 			return nil
 		},
 	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_STATS_ROLLUP_RETENTION_1M",
+		DefaultValue: new("24h"),
+		Description:  new("retention of the 1-minute pod-/traffic-/node-stats rollups (min/avg/max/p95) as Go duration"),
+		Validate:     validateStatsRollupRetention("MO_STATS_ROLLUP_RETENTION_1M", "24h"),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_STATS_ROLLUP_RETENTION_15M",
+		DefaultValue: new("336h"),
+		Description:  new("retention of the 15-minute pod-/traffic-/node-stats rollups (min/avg/max/p95) as Go duration"),
+		Validate:     validateStatsRollupRetention("MO_STATS_ROLLUP_RETENTION_15M", "336h"),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_STATS_ROLLUP_RETENTION_1H",
+		DefaultValue: new("1080h"),
+		Description:  new("retention of the 1-hour pod-/traffic-/node-stats rollups (min/avg/max/p95) as Go duration"),
+		Validate:     validateStatsRollupRetention("MO_STATS_ROLLUP_RETENTION_1H", "1080h"),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_RECOMMENDATION_WINDOW",
+		DefaultValue: new("168h"),
+		Description:  new("default usage history `get/workload-recommendations` bases its request/limit proposals on, as Go duration"),
+		Validate: func(value string) error {
+			window, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("'MO_RECOMMENDATION_WINDOW' needs to be a Go duration (e.g. 168h): %s", err.Error())
+			}
+			if window < time.Hour {
+				return fmt.Errorf("'MO_RECOMMENDATION_WINDOW' must be at least 1h")
+			}
+			return nil
+		},
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_COST_NODE_PRICES",
+		DefaultValue: new(""),
+		Description:  new("hourly node prices for cost allocation as `<instance-type>[@<region>]=<price>` rules separated by commas, `*` matches any instance type (e.g. `m5.large=0.096,*@eu-central-1=0.12,*=0.05`); empty disables cost allocation"),
+		Validate: func(value string) error {
+			_, err := core.ParseNodePriceTable(value)
+			if err != nil {
+				return fmt.Errorf("'MO_COST_NODE_PRICES' is invalid: %s", err.Error())
+			}
+			return nil
+		},
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_COST_CPU_SHARE",
+		DefaultValue: new("0.65"),
+		Description:  new("share of a node's price attributed to its CPU, the rest is attributed to its memory"),
+		Validate: func(value string) error {
+			share, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("'MO_COST_CPU_SHARE' needs to be a number: %s", err.Error())
+			}
+			if share < 0 || share > 1 {
+				return fmt.Errorf("'MO_COST_CPU_SHARE' must be between 0 and 1, got %s", value)
+			}
+			return nil
+		},
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_COST_CURRENCY",
+		DefaultValue: new("USD"),
+		Description:  new("currency of the prices in MO_COST_NODE_PRICES, only used as label in cost reports"),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_BACKUP_PATH",
+		DefaultValue: new(""),
+		Description:  new("mount path of the backup volume used by WorkspaceBackups with storage.pvc, empty disables it"),
+	})
 	configModule.Declare(config.ConfigDeclaration{
 		Key:          "MO_HELM_DATA_PATH",
 		DefaultValue: new(filepath.Join(workDir, "helm-data")),
@@ -346,6 +440,117 @@ func LoadConfigDeclarations(configModule *config.Config) {
 		Description:  new("email address which is used when interacting with git"),
 		Envs:         []string{"git_user_email"},
 	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_GITOPS_DRIFT_INTERVAL",
+		DefaultValue: new("5m"),
+		Description:  new("interval of the drift check between ArgoCD/Flux managed resources and their desired state as Go duration, 0 disables it"),
+		Validate: func(value string) error {
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("'MO_GITOPS_DRIFT_INTERVAL' needs to be a Go duration (e.g. 5m): %s", err.Error())
+			}
+			if interval < 0 {
+				return fmt.Errorf("'MO_GITOPS_DRIFT_INTERVAL' must not be negative")
+			}
+			return nil
+		},
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_SECURITY_SCAN_INTERVAL",
+		DefaultValue: new("1h"),
+		Description:  new("interval of the security posture scan over the resources in the store as Go duration, 0 disables it"),
+		Validate: func(value string) error {
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("'MO_SECURITY_SCAN_INTERVAL' needs to be a Go duration (e.g. 1h): %s", err.Error())
+			}
+			if interval < 0 {
+				return fmt.Errorf("'MO_SECURITY_SCAN_INTERVAL' must not be negative")
+			}
+			return nil
+		},
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_IMAGE_SCAN_INTERVAL",
+		DefaultValue: new("1h"),
+		Description:  new("interval of the image inventory and vulnerability scan as Go duration, 0 disables it"),
+		Validate: func(value string) error {
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("'MO_IMAGE_SCAN_INTERVAL' needs to be a Go duration (e.g. 1h): %s", err.Error())
+			}
+			if interval < 0 {
+				return fmt.Errorf("'MO_IMAGE_SCAN_INTERVAL' must not be negative")
+			}
+			return nil
+		},
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_IMAGE_SBOM_PATH",
+		DefaultValue: new(filepath.Join(workDir, "sboms")),
+		Description:  new("directory of CycloneDX JSON SBOMs of the running images, matched by the image digest in the file name or SBOM metadata"),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_VULN_DB_SOURCE",
+		DefaultValue: new(""),
+		Description:  new("vulnerability database the image packages are matched against: path of a Trivy DB or OSV JSON/zip dump, or `oci://` reference of a Trivy DB artifact; empty disables matching"),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_VULN_DB_CACHE_PATH",
+		DefaultValue: new(filepath.Join(workDir, "vulndb")),
+		Description:  new("directory vulnerability databases pulled from a registry or extracted from an archive are stored in"),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_NOTIFICATION_ALERT_INTERVAL",
+		DefaultValue: new("1m"),
+		Description:  new("interval in which Alertmanager is polled for alerts to deliver to NotificationChannels as Go duration, 0 disables it"),
+		Validate: func(value string) error {
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("'MO_NOTIFICATION_ALERT_INTERVAL' needs to be a Go duration (e.g. 1m): %s", err.Error())
+			}
+			if interval < 0 {
+				return fmt.Errorf("'MO_NOTIFICATION_ALERT_INTERVAL' must not be negative")
+			}
+			return nil
+		},
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_WATCH_HISTORY_SIZE",
+		DefaultValue: new("10000"),
+		Description:  new("number of resource changes kept in memory for watch subscriptions to resume from after a reconnect"),
+		Type:         new(config.ConfigVariableTypeInt),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_EVENTS_OUTBOX_SIZE",
+		DefaultValue: new("10000"),
+		Description:  new("maximum number of events queued in Valkey while the events websocket is disconnected; the oldest are dropped when full, 0 disables the outbox"),
+		Type:         new(config.ConfigVariableTypeInt),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_FILE_TRANSFER_MAX_SIZE",
+		DefaultValue: new("10737418240"),
+		Description:  new("maximum size in bytes of a single chunked file upload or download (default 10 GiB)"),
+		Type:         new(config.ConfigVariableTypeInt),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_FILE_TRANSFER_MAX_OPEN_PER_USER",
+		DefaultValue: new("4"),
+		Description:  new("maximum number of chunked file uploads and downloads a user can have open at once"),
+		Type:         new(config.ConfigVariableTypeInt),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_FILE_TRANSFER_MAX_OPEN",
+		DefaultValue: new("32"),
+		Description:  new("maximum number of chunked file uploads and downloads open at once"),
+		Type:         new(config.ConfigVariableTypeInt),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_FILE_TRANSFER_MAX_DISK_SIZE",
+		DefaultValue: new("21474836480"),
+		Description:  new("maximum number of bytes all open uploads and directory downloads stage in the temporary directory (default 20 GiB)"),
+		Type:         new(config.ConfigVariableTypeInt),
+	})
 	configModule.Declare(config.ConfigDeclaration{
 		Key:          "MO_AUDIT_LOG_LIMIT",
 		DefaultValue: new("1000"),
@@ -369,6 +574,21 @@ func LoadConfigDeclarations(configModule *config.Config) {
 			return nil
 		},
 	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_IDEMPOTENCY_TTL",
+		DefaultValue: new("24h"),
+		Description:  new("how long the responses of mutating requests with an idempotencyKey are kept to answer resent requests, as Go duration, 0 disables it"),
+		Validate: func(value string) error {
+			ttl, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("'MO_IDEMPOTENCY_TTL' needs to be a Go duration (e.g. 24h): %s", err.Error())
+			}
+			if ttl < 0 {
+				return fmt.Errorf("'MO_IDEMPOTENCY_TTL' must not be negative")
+			}
+			return nil
+		},
+	})
 	configModule.Declare(config.ConfigDeclaration{
 		Key:          "MO_ENABLE_POD_STATS_COLLECTOR",
 		DefaultValue: new("true"),
@@ -381,6 +601,38 @@ func LoadConfigDeclarations(configModule *config.Config) {
 		Description:  new("enable collection of network stats"),
 		Type:         new(config.ConfigVariableTypeBool),
 	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_METRICS_EXPORTER_ADDR",
+		DefaultValue: new(""),
+		Description:  new("listen address of the OpenMetrics /metrics endpoint of the nodemetrics DaemonSet (e.g. :9464), empty disables it"),
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_METRICS_REMOTE_WRITE_URL",
+		DefaultValue: new(""),
+		Description:  new("Prometheus remote-write endpoint the nodemetrics DaemonSet pushes its series to, user info in the URL is sent as basic auth, empty disables it"),
+		IsSecret:     true,
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_METRICS_REMOTE_WRITE_BEARER_TOKEN",
+		DefaultValue: new(""),
+		Description:  new("bearer token sent to the Prometheus remote-write endpoint"),
+		IsSecret:     true,
+	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_METRICS_REMOTE_WRITE_INTERVAL",
+		DefaultValue: new("30s"),
+		Description:  new("interval in which series are pushed to the Prometheus remote-write endpoint as Go duration"),
+		Validate: func(value string) error {
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("'MO_METRICS_REMOTE_WRITE_INTERVAL' needs to be a Go duration (e.g. 30s): %s", err.Error())
+			}
+			if interval <= 0 {
+				return fmt.Errorf("'MO_METRICS_REMOTE_WRITE_INTERVAL' must be positive")
+			}
+			return nil
+		},
+	})
 	configModule.Declare(config.ConfigDeclaration{
 		Key:          "MO_ENABLE_AUTO_UPGRADE",
 		DefaultValue: new("true"),
@@ -414,6 +666,11 @@ func LoadConfigDeclarations(configModule *config.Config) {
 		DefaultValue: new("/proc"),
 		Description:  new("mountpath of /proc"),
 	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_HOST_CGROUP_PATH",
+		DefaultValue: new("/sys/fs/cgroup"),
+		Description:  new("mountpath of the host cgroup v2 hierarchy, used for per-container io.stat"),
+	})
 	configModule.Declare(config.ConfigDeclaration{
 		Key:          "MO_LOG_LEVEL",
 		DefaultValue: new("info"),
@@ -449,4 +706,25 @@ func LoadConfigDeclarations(configModule *config.Config) {
 		Description:  new(`maximum length of the AI response to be stored in characters`),
 		Type:         new(config.ConfigVariableTypeInt),
 	})
+	configModule.Declare(config.ConfigDeclaration{
+		Key:          "MO_AI_RUN_RECORDING_MAX_BYTES",
+		DefaultValue: new("4194304"),
+		Description:  new(`maximum size of the replayable transcript recorded per agent run in bytes, 0 disables recording`),
+		Type:         new(config.ConfigVariableTypeInt),
+	})
+}
+
+// validateStatsRollupRetention validates a MO_STATS_ROLLUP_RETENTION_* value:
+// a positive Go duration such as example.
+func validateStatsRollupRetention(key string, example string) func(value string) error {
+	return func(value string) error {
+		retention, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("'%s' needs to be a Go duration (e.g. %s): %s", key, example, err.Error())
+		}
+		if retention <= 0 {
+			return fmt.Errorf("'%s' must be positive", key)
+		}
+		return nil
+	}
 }
@@ -7,6 +7,7 @@ import (
 	"mogenius-operator/src/containerenumerator"
 	"mogenius-operator/src/core"
 	"mogenius-operator/src/cpumonitor"
+	"mogenius-operator/src/iomonitor"
 	"mogenius-operator/src/logging"
 	"mogenius-operator/src/networkmonitor"
 	"mogenius-operator/src/rammonitor"
@@ -25,6 +26,7 @@ type nodeMetricsSystems struct {
 	core                 core.Core
 	networkmonitor       networkmonitor.NetworkMonitor
 	nodeMetricsCollector core.NodeMetricsCollector
+	metricsExporter      core.MetricsExporter
 }
 
 // initializeNodeMetricsSystems layers the metrics-specific services on top of the shared base.
@@ -43,6 +45,7 @@ func initializeNodeMetricsSystems(
 	cpuMonitor := cpumonitor.NewCpuMonitor(logManagerModule.CreateLogger("cpu-monitor"), configModule, base.clientProvider, containerEnumerator)
 	ramMonitor := rammonitor.NewRamMonitor(logManagerModule.CreateLogger("ram-monitor"), configModule, base.clientProvider, containerEnumerator)
 	networkMonitor := networkmonitor.NewNetworkMonitor(logManagerModule.CreateLogger("network-monitor"), configModule, containerEnumerator, configModule.Get("MO_HOST_PROC_PATH"))
+	ioMonitor := iomonitor.NewIoMonitor(logManagerModule.CreateLogger("io-monitor"), configModule, containerEnumerator)
 
 	ownerCacheService := store.NewOwnerCacheService(logManagerModule.CreateLogger("owner-cache"), configModule)
 	dbstatsService := core.NewValkeyStatsModule(logManagerModule.CreateLogger("db-stats"), configModule, base.valkeyClient, ownerCacheService)
@@ -63,13 +66,24 @@ func initializeNodeMetricsSystems(
 		cpuMonitor,
 		ramMonitor,
 		networkMonitor,
+		ioMonitor,
 	)
 	nodeMetricsCollector.Link(dbstatsService, leaderElector)
 
+	metricsExporter := core.NewMetricsExporter(
+		logManagerModule.CreateLogger("metrics-exporter"),
+		configModule,
+		cpuMonitor,
+		ramMonitor,
+		networkMonitor,
+		ownerCacheService,
+	)
+
 	return nodeMetricsSystems{
 		core:                 mocore,
 		networkmonitor:       networkMonitor,
 		nodeMetricsCollector: nodeMetricsCollector,
+		metricsExporter:      metricsExporter,
 	}
 }
 
@@ -93,6 +107,7 @@ func RunNodeMetrics(args *nodeMetricsArgs, logManagerModule logging.SlogManager,
 			MetricsRate: args.MetricsRate,
 		})
 		systems.nodeMetricsCollector.Run()
+		systems.metricsExporter.Run()
 
 		select {}
 	}()
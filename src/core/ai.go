@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"log/slog"
 	"mogenius-operator/src/ai"
 	"mogenius-operator/src/crds/v1alpha1"
@@ -16,6 +17,8 @@ type AiApi interface {
 	GetAiTasksForResource(resourceReq utils.WorkloadSingleRequest) ([]ai.AiTask, error)
 	GetLatestTask(workspace *string) (*ai.AiTaskLatest, error)
 	GetRun(runID string) (*ai.AiRun, error)
+	ExportRun(runID string) (*ai.AiRunBundle, error)
+	ReplayRun(ctx context.Context, bundle ai.AiRunBundle, opts ai.AiRunReplayOptions, useCurrentSystemPrompt bool) (*ai.AiRunReplayReport, error)
 	InjectAiPromptConfig(prompt ai.AiPromptConfig, aiPrompts *ai.AiPrompts)
 	GetStatus(workspace *string) ai.AiManagerStatus
 	DeleteAllAiData() error
@@ -60,6 +63,14 @@ func (self *aiApi) GetRun(runID string) (*ai.AiRun, error) {
 	return self.aiManager.GetRun(runID)
 }
 
+func (self *aiApi) ExportRun(runID string) (*ai.AiRunBundle, error) {
+	return self.aiManager.ExportRun(runID)
+}
+
+func (self *aiApi) ReplayRun(ctx context.Context, bundle ai.AiRunBundle, opts ai.AiRunReplayOptions, useCurrentSystemPrompt bool) (*ai.AiRunReplayReport, error) {
+	return self.aiManager.ReplayRun(ctx, bundle, opts, useCurrentSystemPrompt)
+}
+
 func (ai *aiApi) InjectAiPromptConfig(prompt ai.AiPromptConfig, aiPrompts *ai.AiPrompts) {
 	ai.aiManager.InjectAiPromptConfig(prompt, aiPrompts)
 }
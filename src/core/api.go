@@ -1,10 +1,12 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"mogenius-operator/src/ai"
 	"mogenius-operator/src/assert"
+	"mogenius-operator/src/backup"
 	cfg "mogenius-operator/src/config"
 	"mogenius-operator/src/crds/v1alpha1"
 	"mogenius-operator/src/helm"
@@ -88,6 +90,11 @@ type Api interface {
 	UpdateAiModel(name string, spec v1alpha1.AiModelSpec, apiKey string) (string, error)
 	DeleteAiModel(name string) (string, error)
 
+	GetAllWorkspaceBackups(workspace *string) ([]v1alpha1.WorkspaceBackup, error)
+	CreateWorkspaceBackup(name string, spec v1alpha1.WorkspaceBackupSpec) (string, error)
+	DeleteWorkspaceBackup(name string) (string, error)
+	RestoreWorkspaceBackup(ctx context.Context, name string, options backup.RestoreOptions) (*backup.RestoreResult, error)
+
 	GetWorkspaceResources(workspaceName string, whitelist []*utils.ResourceDescriptor, blacklist []*utils.ResourceDescriptor, namespaceWhitelist []string) ([]unstructured.Unstructured, error)
 	GetResourceListByWhitelistPaginated(req ResourcesPaginatedRequest) (ResourcesPaginatedResponse, error)
 	GetWorkspaceResourcesPaginated(workspaceName string, req WorkspaceResourcesPaginatedRequest) (WorkspaceResourcesPaginatedResponse, error)
@@ -127,14 +134,20 @@ type GetWorkspaceResult struct {
 	CreationTimestamp v1.Time                                `json:"creationTimestamp"`
 	Resources         []v1alpha1.WorkspaceResourceIdentifier `json:"resources" validate:"required"`
 	DashboardRef      string                                 `json:"dashboardRef,omitempty"`
+	Quota             *v1alpha1.WorkspaceQuota               `json:"quota,omitempty"`
+	// Conditions carries the quota usage (`QuotaWithinBudget`) next to the
+	// integrity checks of the reconciler.
+	Conditions []v1.Condition `json:"conditions,omitempty"`
 }
 
-func NewGetWorkspaceResult(name string, creationTimestamp v1.Time, resources []v1alpha1.WorkspaceResourceIdentifier, dashboardRef string) GetWorkspaceResult {
+func NewGetWorkspaceResult(name string, creationTimestamp v1.Time, resources []v1alpha1.WorkspaceResourceIdentifier, dashboardRef string, quota *v1alpha1.WorkspaceQuota, conditions []v1.Condition) GetWorkspaceResult {
 	return GetWorkspaceResult{
 		Name:              name,
 		CreationTimestamp: creationTimestamp,
 		Resources:         resources,
 		DashboardRef:      dashboardRef,
+		Quota:             quota,
+		Conditions:        conditions,
 	}
 }
 
@@ -152,6 +165,8 @@ func (self *api) GetAllWorkspaces() ([]GetWorkspaceResult, error) {
 			resource.CreationTimestamp,
 			resource.Spec.Resources,
 			resource.Spec.DashboardRef,
+			resource.Spec.Quota,
+			resource.Status.Conditions,
 		))
 	}
 
@@ -170,6 +185,8 @@ func (self *api) GetWorkspace(name string) (*GetWorkspaceResult, error) {
 		resource.CreationTimestamp,
 		resource.Spec.Resources,
 		resource.Spec.DashboardRef,
+		resource.Spec.Quota,
+		resource.Status.Conditions,
 	)
 
 	return &result, nil
@@ -502,6 +519,45 @@ func (self *api) DeleteAiModel(name string) (string, error) {
 	return "Resource deleted successfully", nil
 }
 
+func (self *api) GetAllWorkspaceBackups(workspace *string) ([]v1alpha1.WorkspaceBackup, error) {
+	resources, err := self.workspaceManager.GetAllWorkspaceBackups()
+	if err != nil {
+		return []v1alpha1.WorkspaceBackup{}, err
+	}
+	if workspace != nil {
+		resources = slices.DeleteFunc(resources, func(resource v1alpha1.WorkspaceBackup) bool {
+			return resource.Spec.Workspace != *workspace
+		})
+	}
+
+	return resources, nil
+}
+
+func (self *api) CreateWorkspaceBackup(name string, spec v1alpha1.WorkspaceBackupSpec) (string, error) {
+	if _, err := self.workspaceManager.GetWorkspace(spec.Workspace); err != nil {
+		return "", fmt.Errorf("workspace %q: %w", spec.Workspace, err)
+	}
+	_, err := self.workspaceManager.CreateWorkspaceBackup(name, spec)
+	if err != nil {
+		return "", err
+	}
+
+	return "Resource created successfully", nil
+}
+
+func (self *api) DeleteWorkspaceBackup(name string) (string, error) {
+	err := self.workspaceManager.DeleteWorkspaceBackup(name)
+	if err != nil {
+		return "", err
+	}
+
+	return "Resource deleted successfully", nil
+}
+
+func (self *api) RestoreWorkspaceBackup(ctx context.Context, name string, options backup.RestoreOptions) (*backup.RestoreResult, error) {
+	return self.workspaceManager.RestoreWorkspaceBackup(ctx, name, options)
+}
+
 type ResourcesPaginatedRequest struct {
 	Whitelist          []*utils.ResourceDescriptor `json:"whitelist"`
 	Blacklist          []*utils.ResourceDescriptor `json:"blacklist"`
@@ -0,0 +1,399 @@
+package core
+
+import (
+	"fmt"
+	"log/slog"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/crds/v1alpha1"
+	moMetrics "mogenius-operator/src/metrics"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/utils"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// PatternRole is the minimum Grant role a caller needs to execute a pattern.
+// The values are the hardcoded roles of the `Grant` CRD.
+type PatternRole string
+
+const (
+	PatternRoleViewer PatternRole = "viewer"
+	PatternRoleEditor PatternRole = "editor"
+	PatternRoleAdmin  PatternRole = "admin"
+)
+
+func (self PatternRole) rank() int {
+	switch self {
+	case PatternRoleViewer:
+		return 1
+	case PatternRoleEditor:
+		return 2
+	case PatternRoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// PatternScope decides which grants can authorize a pattern.
+type PatternScope string
+
+const (
+	// The pattern acts on resources of a workspace. A grant on the workspace
+	// named in `Datagram.Workspace` or a cluster grant authorizes it. Every
+	// namespace referenced by the payload has to belong to that workspace, and
+	// a payload that references neither a namespace nor the workspace acts
+	// cluster-wide, which needs a cluster grant.
+	PatternScopeWorkspace PatternScope = "workspace"
+	// Like PatternScopeWorkspace, but the handler falls back to
+	// `Datagram.Workspace` (or to objects the caller created before, like an
+	// upload) when the payload names no namespace, so such a payload needs no
+	// cluster grant.
+	PatternScopeDatagramWorkspace PatternScope = "datagram-workspace"
+	// The pattern acts on the operator or the cluster as a whole. Only a
+	// cluster grant authorizes it.
+	PatternScopeCluster PatternScope = "cluster"
+)
+
+// GrantTargetTypeCluster marks a Grant whose role applies to every workspace
+// and to cluster-scoped patterns. `targetName` is ignored for those grants.
+const GrantTargetTypeCluster = "cluster"
+
+const GrantTargetTypeWorkspace = "workspace"
+
+// Authorization modes of MO_PATTERN_AUTHORIZATION.
+const (
+	PatternAuthorizationDisabled = "disabled"
+	PatternAuthorizationAudit    = "audit"
+	PatternAuthorizationEnforce  = "enforce"
+)
+
+// mutatingPatternTokens are the words in a pattern name that mark it as a
+// change. Pattern names are matched token by token (split on "/" and "-"),
+// so "cluster/helm-release-list" stays a viewer pattern while
+// "cluster/helm-release-uninstall" needs an editor.
+var mutatingPatternTokens = []string{
+	"create", "update", "delete", "remove", "patch", "add", "install", "uninstall",
+	"upgrade", "rollback", "link", "trigger", "reset", "sync", "refresh",
+	"terminate", "action", "force", "suspend", "resume", "reconcile", "chmod",
+	"chown", "rename", "clean", "approve", "reject", "cancel", "inject",
+}
+
+// defaultPatternRole derives the required role from the pattern name for
+// patterns that do not set PatternConfig.RequiredRole explicitly.
+func defaultPatternRole(pattern string) PatternRole {
+	tokens := strings.FieldsFunc(strings.ToLower(pattern), func(r rune) bool {
+		return r == '/' || r == '-'
+	})
+	for _, token := range tokens {
+		if slices.Contains(mutatingPatternTokens, token) {
+			return PatternRoleEditor
+		}
+	}
+	return PatternRoleViewer
+}
+
+// payloadNamespaceKeys are the request fields that name a namespace the
+// pattern is going to touch.
+var payloadNamespaceKeys = []string{"namespace", "namespaceName", "volumeNamespace", "podNamespace"}
+
+// payloadScope is what a request payload says about where the pattern acts.
+type payloadScope struct {
+	// namespaces referenced by the payload, including the `metadata.namespace`
+	// of a manifest in `yamlData`
+	namespaces []string
+	// workspaces named by a `workspaceName` field
+	workspaces []string
+	// clusterScoped is set by `namespaced: false`, the pattern acts on a
+	// cluster-scoped resource
+	clusterScoped bool
+}
+
+// payloadScopeMaxDepth is how deep payloadScopeOf looks into nested objects.
+const payloadScopeMaxDepth = 3
+
+// payloadScopeOf collects every namespace and workspace a request payload
+// references, including those of nested objects (e.g. `file.volumeNamespace`).
+// Keys are matched case-insensitively like encoding/json does when the
+// handler decodes the payload. It fails on payloads it cannot fully account
+// for: case variants of a key with different values (the handler would use
+// either) and objects nested deeper than payloadScopeMaxDepth.
+func payloadScopeOf(payload map[string]any) (payloadScope, error) {
+	scope := payloadScope{namespaces: []string{}, workspaces: []string{}}
+	var walk func(value any, depth int) error
+	walk = func(value any, depth int) error {
+		switch v := value.(type) {
+		case map[string]any:
+			if len(v) == 0 {
+				return nil
+			}
+			if depth > payloadScopeMaxDepth {
+				return fmt.Errorf("payload nests objects deeper than %d levels", payloadScopeMaxDepth)
+			}
+			if err := checkPayloadKeyVariants(v); err != nil {
+				return err
+			}
+			for key, item := range v {
+				if s, ok := item.(string); ok {
+					switch {
+					case s == "":
+					case slices.ContainsFunc(payloadNamespaceKeys, func(namespaceKey string) bool { return strings.EqualFold(namespaceKey, key) }):
+						scope.namespaces = append(scope.namespaces, s)
+					case strings.EqualFold(key, "workspaceName"):
+						scope.workspaces = append(scope.workspaces, s)
+					case strings.EqualFold(key, "yamlData"):
+						scope.namespaces = append(scope.namespaces, manifestNamespace(s))
+					}
+					continue
+				}
+				if namespaced, ok := item.(bool); ok && strings.EqualFold(key, "namespaced") && !namespaced {
+					scope.clusterScoped = true
+					continue
+				}
+				if list, ok := item.([]any); ok && strings.EqualFold(key, "namespaces") {
+					for _, entry := range list {
+						if s, ok := entry.(string); ok && s != "" {
+							scope.namespaces = append(scope.namespaces, s)
+						}
+					}
+					continue
+				}
+				if err := walk(item, depth+1); err != nil {
+					return err
+				}
+			}
+		case []any:
+			if len(v) == 0 {
+				return nil
+			}
+			if depth > payloadScopeMaxDepth {
+				return fmt.Errorf("payload nests objects deeper than %d levels", payloadScopeMaxDepth)
+			}
+			for _, item := range v {
+				if err := walk(item, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(payload, 0); err != nil {
+		return payloadScope{}, err
+	}
+	scope.namespaces = slices.DeleteFunc(scope.namespaces, func(namespace string) bool { return namespace == "" })
+	slices.Sort(scope.namespaces)
+	scope.namespaces = slices.Compact(scope.namespaces)
+	slices.Sort(scope.workspaces)
+	scope.workspaces = slices.Compact(scope.workspaces)
+	return scope, nil
+}
+
+// checkPayloadKeyVariants rejects objects holding case variants of one key
+// with different values, e.g. "namespace" and "Namespace".
+func checkPayloadKeyVariants(object map[string]any) error {
+	seen := make(map[string]string, len(object))
+	for key := range object {
+		folded := strings.ToLower(key)
+		other, ok := seen[folded]
+		if !ok {
+			seen[folded] = key
+			continue
+		}
+		if !reflect.DeepEqual(object[key], object[other]) {
+			return fmt.Errorf("payload sets '%s' and '%s' to different values", other, key)
+		}
+	}
+	return nil
+}
+
+// manifestNamespace returns the `metadata.namespace` of a YAML manifest, or
+// "" when it has none or does not parse (the handler rejects it then).
+func manifestNamespace(data string) string {
+	var manifest struct {
+		Metadata struct {
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(data), &manifest); err != nil {
+		return ""
+	}
+	return manifest.Metadata.Namespace
+}
+
+// workspaceNamespaces lists the namespaces a workspace owns: namespace
+// resources directly, helm and flux resources through the namespace they are
+// installed in.
+func workspaceNamespaces(spec v1alpha1.WorkspaceSpec) []string {
+	namespaces := []string{}
+	for _, resource := range spec.Resources {
+		switch resource.Type {
+		case "namespace":
+			if resource.Id != "" {
+				namespaces = append(namespaces, resource.Id)
+			}
+		case "helm", "flux":
+			if resource.Namespace != "" {
+				namespaces = append(namespaces, resource.Namespace)
+			}
+		}
+	}
+	slices.Sort(namespaces)
+	return slices.Compact(namespaces)
+}
+
+type authorizationSnapshot struct {
+	users      []v1alpha1.User
+	grants     []v1alpha1.Grant
+	workspaces []v1alpha1.Workspace
+}
+
+// patternAuthorizer checks a datagram against the caller's Grants before its
+// pattern runs. The platform already filters what a user may do, this is the
+// operator enforcing the same rules for itself.
+type patternAuthorizer struct {
+	logger   *slog.Logger
+	config   cfg.ConfigModule
+	snapshot *utils.TTLCache[authorizationSnapshot]
+}
+
+func newPatternAuthorizer(logger *slog.Logger, configModule cfg.ConfigModule) *patternAuthorizer {
+	self := &patternAuthorizer{}
+	self.logger = logger
+	self.config = configModule
+	// Users, grants and workspaces change rarely compared to the request rate,
+	// so one store read every few seconds serves every request in between.
+	self.snapshot = utils.NewTTLCache(5*time.Second, func() authorizationSnapshot {
+		namespace := self.config.Get("MO_OWN_NAMESPACE")
+		users, _ := store.GetAllUsers(namespace)
+		grants, _ := store.GetAllGrants(namespace)
+		workspaces, _ := store.GetAllWorkspaces(namespace)
+		return authorizationSnapshot{users: users, grants: grants, workspaces: workspaces}
+	})
+
+	return self
+}
+
+// Authorize returns a Forbidden error when the datagram's user may not
+// execute the pattern. In audit mode denials are only logged and counted in
+// `mogenius_operator_pattern_authorization_denied_total`, so clusters can
+// verify nothing legitimate would be rejected before switching to enforce.
+func (self *patternAuthorizer) Authorize(datagram structs.Datagram, config PatternConfig) error {
+	mode, _ := self.config.TryGet("MO_PATTERN_AUTHORIZATION")
+	if mode == "" || mode == PatternAuthorizationDisabled {
+		return nil
+	}
+
+	err := self.check(datagram, config, self.snapshot.Get())
+	if err == nil {
+		return nil
+	}
+
+	moMetrics.IncPatternAuthorizationDenied(datagram.Pattern, mode)
+	if mode == PatternAuthorizationAudit {
+		self.logger.Warn("pattern request would be denied",
+			"pattern", datagram.Pattern,
+			"user", datagram.User.Email,
+			"workspace", datagram.Workspace,
+			"reason", err.Error(),
+		)
+		return nil
+	}
+
+	return apierrors.NewForbidden(schema.GroupResource{Group: v1alpha1.GroupVersion.Group, Resource: "patterns"}, datagram.Pattern, err)
+}
+
+func (self *patternAuthorizer) check(datagram structs.Datagram, config PatternConfig, snapshot authorizationSnapshot) error {
+	required := config.RequiredRole
+	if required == "" {
+		required = defaultPatternRole(datagram.Pattern)
+	}
+
+	if datagram.User.Email == "" {
+		return fmt.Errorf("request carries no user")
+	}
+
+	userName := ""
+	for _, user := range snapshot.users {
+		if strings.EqualFold(user.Spec.Email, datagram.User.Email) {
+			userName = user.Name
+			break
+		}
+	}
+	if userName == "" {
+		return fmt.Errorf("no User resource exists for '%s'", datagram.User.Email)
+	}
+
+	var clusterRole, workspaceRole PatternRole
+	for _, grant := range snapshot.grants {
+		if grant.Spec.Grantee != userName {
+			continue
+		}
+		role := PatternRole(grant.Spec.Role)
+		switch grant.Spec.TargetType {
+		case GrantTargetTypeCluster:
+			if role.rank() > clusterRole.rank() {
+				clusterRole = role
+			}
+		case GrantTargetTypeWorkspace, "":
+			if datagram.Workspace != "" && grant.Spec.TargetName == datagram.Workspace && role.rank() > workspaceRole.rank() {
+				workspaceRole = role
+			}
+		}
+	}
+
+	if clusterRole.rank() >= required.rank() {
+		return nil
+	}
+
+	if config.Scope == PatternScopeCluster {
+		return fmt.Errorf("pattern needs a cluster grant with role '%s'", required)
+	}
+	if datagram.Workspace == "" {
+		return fmt.Errorf("pattern needs a workspace or a cluster grant with role '%s'", required)
+	}
+	if workspaceRole.rank() < required.rank() {
+		return fmt.Errorf("pattern needs role '%s' in workspace '%s'", required, datagram.Workspace)
+	}
+
+	scope, err := payloadScopeOf(datagram.PayloadMap())
+	if err != nil {
+		return fmt.Errorf("%s, the request needs a cluster grant with role '%s'", err.Error(), required)
+	}
+	if scope.clusterScoped {
+		return fmt.Errorf("cluster-scoped resources need a cluster grant with role '%s'", required)
+	}
+	for _, workspaceName := range scope.workspaces {
+		if workspaceName != datagram.Workspace {
+			return fmt.Errorf("workspace '%s' does not match the request's workspace '%s'", workspaceName, datagram.Workspace)
+		}
+	}
+	if len(scope.namespaces) == 0 && len(scope.workspaces) == 0 && config.Scope != PatternScopeDatagramWorkspace {
+		return fmt.Errorf("requests without a namespace act cluster-wide and need a cluster grant with role '%s'", required)
+	}
+
+	var workspace *v1alpha1.Workspace
+	for i := range snapshot.workspaces {
+		if snapshot.workspaces[i].Name == datagram.Workspace {
+			workspace = &snapshot.workspaces[i]
+			break
+		}
+	}
+	if workspace == nil {
+		return fmt.Errorf("workspace '%s' does not exist", datagram.Workspace)
+	}
+
+	allowed := workspaceNamespaces(workspace.Spec)
+	for _, namespace := range scope.namespaces {
+		if !slices.Contains(allowed, namespace) {
+			return fmt.Errorf("namespace '%s' is not part of workspace '%s'", namespace, datagram.Workspace)
+		}
+	}
+
+	return nil
+}
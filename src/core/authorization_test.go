@@ -0,0 +1,169 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/structs"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDefaultPatternRole(t *testing.T) {
+	assert.Equal(t, PatternRoleViewer, defaultPatternRole("get/workload-list-paginated"))
+	assert.Equal(t, PatternRoleViewer, defaultPatternRole("cluster/helm-release-list"))
+	assert.Equal(t, PatternRoleViewer, defaultPatternRole("files/download"))
+	assert.Equal(t, PatternRoleEditor, defaultPatternRole("update/workload"))
+	assert.Equal(t, PatternRoleEditor, defaultPatternRole("files/delete"))
+	assert.Equal(t, PatternRoleEditor, defaultPatternRole("cluster/helm-release-uninstall"))
+	assert.Equal(t, PatternRoleEditor, defaultPatternRole("cluster/argo-cd-application-sync"))
+	assert.Equal(t, PatternRoleEditor, defaultPatternRole("files/create-folder"))
+}
+
+func TestPayloadScope(t *testing.T) {
+	scope, err := payloadScopeOf(map[string]any{
+		"namespace": "a",
+		"file": map[string]any{
+			"volumeNamespace": "b",
+			"path":            "/",
+		},
+		"namespaces":    []any{"c", "a"},
+		"name":          "ignored",
+		"workspaceName": "shop",
+		"yamlData":      "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: x\n  namespace: d\n",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, scope.namespaces)
+	assert.Equal(t, []string{"shop"}, scope.workspaces)
+	assert.False(t, scope.clusterScoped)
+
+	scope, err = payloadScopeOf(map[string]any{"namespaced": false, "yamlData": "kind: ClusterRoleBinding\nmetadata:\n  name: x\n"})
+	require.NoError(t, err)
+	assert.True(t, scope.clusterScoped)
+	assert.Empty(t, scope.namespaces)
+	scope, err = payloadScopeOf(nil)
+	require.NoError(t, err)
+	assert.Empty(t, scope.namespaces)
+
+	// encoding/json matches keys case-insensitively
+	scope, err = payloadScopeOf(map[string]any{"Namespace": "a", "NAMESPACED": false})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, scope.namespaces)
+	assert.True(t, scope.clusterScoped)
+
+	_, err = payloadScopeOf(map[string]any{"namespace": "own-ns", "Namespace": "kube-system"})
+	assert.ErrorContains(t, err, "different values")
+	_, err = payloadScopeOf(map[string]any{"namespace": "own-ns", "Namespace": "own-ns"})
+	assert.NoError(t, err)
+
+	nested := map[string]any{"namespace": "kube-system"}
+	for range payloadScopeMaxDepth + 1 {
+		nested = map[string]any{"spec": nested}
+	}
+	_, err = payloadScopeOf(nested)
+	assert.ErrorContains(t, err, "deeper than")
+}
+
+func authorizationFixture() authorizationSnapshot {
+	return authorizationSnapshot{
+		users: []v1alpha1.User{
+			{ObjectMeta: metav1.ObjectMeta{Name: "jane"}, Spec: v1alpha1.NewUserSpec("Jane", "Doe", "jane@example.com", nil)},
+			{ObjectMeta: metav1.ObjectMeta{Name: "root"}, Spec: v1alpha1.NewUserSpec("Root", "", "root@example.com", nil)},
+		},
+		grants: []v1alpha1.Grant{
+			{ObjectMeta: metav1.ObjectMeta{Name: "jane-shop"}, Spec: v1alpha1.NewGrantSpec("jane", "workspace", "shop", "editor")},
+			{ObjectMeta: metav1.ObjectMeta{Name: "jane-blog"}, Spec: v1alpha1.NewGrantSpec("jane", "workspace", "blog", "viewer")},
+			{ObjectMeta: metav1.ObjectMeta{Name: "root"}, Spec: v1alpha1.NewGrantSpec("root", GrantTargetTypeCluster, "", "admin")},
+		},
+		workspaces: []v1alpha1.Workspace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "shop"}, Spec: v1alpha1.NewWorkspaceSpec("Shop", []v1alpha1.WorkspaceResourceIdentifier{
+				{Id: "shop-prod", Type: "namespace"},
+				{Id: "redis", Type: "helm", Namespace: "shop-cache"},
+			}, "")},
+			{ObjectMeta: metav1.ObjectMeta{Name: "blog"}, Spec: v1alpha1.NewWorkspaceSpec("Blog", []v1alpha1.WorkspaceResourceIdentifier{
+				{Id: "blog", Type: "namespace"},
+			}, "")},
+		},
+	}
+}
+
+func authorizationDatagram(pattern string, email string, workspace string, payload map[string]any) structs.Datagram {
+	datagram := structs.CreateEmptyDatagram()
+	datagram.Pattern = pattern
+	datagram.User = structs.User{Email: email}
+	datagram.Workspace = workspace
+	datagram.Payload = payload
+	return datagram
+}
+
+func TestPatternAuthorizerCheck(t *testing.T) {
+	authorizer := &patternAuthorizer{}
+	snapshot := authorizationFixture()
+	workloadConfig := PatternConfig{RequiredRole: PatternRoleEditor, Scope: PatternScopeWorkspace}
+	clusterConfig := PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster}
+
+	// editor inside the own workspace
+	err := authorizer.check(authorizationDatagram("update/workload", "jane@example.com", "shop", map[string]any{"namespace": "shop-prod"}), workloadConfig, snapshot)
+	assert.NoError(t, err)
+
+	// helm releases contribute their namespace
+	err = authorizer.check(authorizationDatagram("update/workload", "jane@example.com", "shop", map[string]any{"namespace": "shop-cache"}), workloadConfig, snapshot)
+	assert.NoError(t, err)
+
+	// namespace outside the workspace
+	err = authorizer.check(authorizationDatagram("update/workload", "jane@example.com", "shop", map[string]any{"namespace": "blog"}), workloadConfig, snapshot)
+	assert.ErrorContains(t, err, "not part of workspace")
+
+	// viewer may not edit
+	err = authorizer.check(authorizationDatagram("update/workload", "jane@example.com", "blog", map[string]any{"namespace": "blog"}), workloadConfig, snapshot)
+	assert.ErrorContains(t, err, "needs role 'editor'")
+
+	// no workspace and no cluster grant
+	err = authorizer.check(authorizationDatagram("update/workload", "jane@example.com", "", map[string]any{"namespace": "shop-prod"}), workloadConfig, snapshot)
+	assert.Error(t, err)
+
+	// workspace grants never authorize cluster patterns
+	err = authorizer.check(authorizationDatagram("create/user", "jane@example.com", "shop", nil), clusterConfig, snapshot)
+	assert.ErrorContains(t, err, "cluster grant")
+
+	// the namespace of a manifest counts, not only the request fields
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: x\n  namespace: %s\n"
+	err = authorizer.check(authorizationDatagram("create/new-workload", "jane@example.com", "shop", map[string]any{"namespaced": true, "yamlData": fmt.Sprintf(manifest, "shop-prod")}), workloadConfig, snapshot)
+	assert.NoError(t, err)
+	err = authorizer.check(authorizationDatagram("create/new-workload", "jane@example.com", "shop", map[string]any{"namespace": "shop-prod", "namespaced": true, "yamlData": fmt.Sprintf(manifest, "kube-system")}), workloadConfig, snapshot)
+	assert.ErrorContains(t, err, "not part of workspace")
+
+	// cluster-scoped resources and payloads without a namespace act beyond the workspace
+	err = authorizer.check(authorizationDatagram("create/new-workload", "jane@example.com", "shop", map[string]any{"namespaced": false, "yamlData": "kind: ClusterRoleBinding\nmetadata:\n  name: x\n"}), workloadConfig, snapshot)
+	assert.ErrorContains(t, err, "cluster grant")
+	err = authorizer.check(authorizationDatagram("get/workload-list", "jane@example.com", "shop", map[string]any{"namespaced": true, "namespace": nil}), PatternConfig{Scope: PatternScopeWorkspace}, snapshot)
+	assert.ErrorContains(t, err, "cluster grant")
+
+	// workspaceName has to be the request's workspace
+	err = authorizer.check(authorizationDatagram("security/findings/list", "jane@example.com", "shop", map[string]any{"workspaceName": "shop"}), PatternConfig{Scope: PatternScopeWorkspace}, snapshot)
+	assert.NoError(t, err)
+	err = authorizer.check(authorizationDatagram("security/findings/list", "jane@example.com", "shop", map[string]any{"workspaceName": "blog"}), PatternConfig{Scope: PatternScopeDatagramWorkspace}, snapshot)
+	assert.ErrorContains(t, err, "does not match")
+	// unless the handler falls back to Datagram.Workspace
+	err = authorizer.check(authorizationDatagram("security/findings/list", "jane@example.com", "shop", nil), PatternConfig{Scope: PatternScopeDatagramWorkspace}, snapshot)
+	assert.NoError(t, err)
+
+	// cluster admins may do everything, everywhere
+	err = authorizer.check(authorizationDatagram("create/user", "root@example.com", "", nil), clusterConfig, snapshot)
+	assert.NoError(t, err)
+	err = authorizer.check(authorizationDatagram("update/workload", "root@example.com", "blog", map[string]any{"namespace": "kube-system"}), workloadConfig, snapshot)
+	assert.NoError(t, err)
+	err = authorizer.check(authorizationDatagram("security/findings/list", "root@example.com", "", map[string]any{"workspaceName": "blog"}), PatternConfig{Scope: PatternScopeDatagramWorkspace}, snapshot)
+	assert.NoError(t, err)
+	err = authorizer.check(authorizationDatagram("get/workload-list", "root@example.com", "", map[string]any{"namespaced": false}), PatternConfig{Scope: PatternScopeWorkspace}, snapshot)
+	assert.NoError(t, err)
+
+	// unknown and anonymous callers
+	err = authorizer.check(authorizationDatagram("get/workspaces", "mallory@example.com", "shop", nil), PatternConfig{}, snapshot)
+	assert.ErrorContains(t, err, "no User resource")
+	err = authorizer.check(authorizationDatagram("get/workspaces", "", "shop", nil), PatternConfig{}, snapshot)
+	assert.ErrorContains(t, err, "no user")
+}
@@ -0,0 +1,710 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/shutdown"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/utils"
+	"mogenius-operator/src/valkeyclient"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valkey-io/valkey-go"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	DB_STATS_COST_BUCKET_NAME = "cost-stats"
+	DB_STATS_COST_DAILY_NAME  = "daily"
+)
+
+// costDayTTL keeps a bit more than a year of daily documents so monthly
+// rollups can compare against the same month last year.
+const costDayTTL = 400 * 24 * time.Hour
+
+// costMaxDays bounds how far back a cost query may reach.
+const costMaxDays = 400
+
+const (
+	CostRollupDaily   = "daily"
+	CostRollupMonthly = "monthly"
+)
+
+const costBytesPerGiB = 1 << 30
+
+// CostEngine splits the hourly price of every node across the pods running on
+// it and keeps daily rollups per workload in Valkey. A pod is charged for
+// whatever is larger of its requests and its measured usage; on an
+// overcommitted node the price is split by that demand instead of the
+// capacity. Node capacity nobody requested or used is tracked as idle cost and
+// attributed to workspaces, namespaces and workloads proportionally to their
+// own cost when queried.
+type CostEngine interface {
+	Run()
+	Link(apiService Api, leaderElector LeaderElector)
+	GetWorkspaceCost(workspaceName string, query CostQuery) (*CostReport, error)
+	GetNamespaceCost(namespace string, query CostQuery) (*CostReport, error)
+	GetWorkloadCost(namespace string, kind string, name string, query CostQuery) (*CostReport, error)
+}
+
+type CostQuery struct {
+	// Rollup is "daily" (default) or "monthly".
+	Rollup string `json:"rollup"`
+	// Days is the number of days to report, including today. Defaults to 30.
+	Days int `json:"days"`
+}
+
+// CostAllocation is the resource consumption and cost booked for one
+// workload on one day.
+type CostAllocation struct {
+	Namespace      string  `json:"namespace"`
+	Kind           string  `json:"kind"`
+	Name           string  `json:"name"`
+	CpuCoreHours   float64 `json:"cpuCoreHours"`
+	MemoryGiBHours float64 `json:"memoryGiBHours"`
+	CpuCost        float64 `json:"cpuCost"`
+	MemoryCost     float64 `json:"memoryCost"`
+}
+
+func (self *CostAllocation) cost() float64 {
+	return self.CpuCost + self.MemoryCost
+}
+
+func (self *CostAllocation) add(other CostAllocation) {
+	self.CpuCoreHours += other.CpuCoreHours
+	self.MemoryGiBHours += other.MemoryGiBHours
+	self.CpuCost += other.CpuCost
+	self.MemoryCost += other.MemoryCost
+}
+
+// costDay is the Valkey document holding everything booked for one UTC day.
+type costDay struct {
+	Date      string                     `json:"date"`
+	Currency  string                     `json:"currency"`
+	Workloads map[string]*CostAllocation `json:"workloads"`
+	IdleCost  float64                    `json:"idleCost"`
+	// Hours lists the UTC hours already booked, so a restart or a new leader
+	// never books the same hour twice.
+	Hours []int `json:"hours"`
+	// MissedHours lists the UTC hours no replica was leading for. The pods
+	// and nodes of back then are not stored, so their cost is unknown and
+	// not booked.
+	MissedHours []int `json:"missedHours,omitempty"`
+}
+
+func (self *costDay) allocatedCost() float64 {
+	total := 0.0
+	for _, allocation := range self.Workloads {
+		total += allocation.cost()
+	}
+	return total
+}
+
+type CostReport struct {
+	Currency string            `json:"currency"`
+	Rollup   string            `json:"rollup"`
+	Entries  []CostReportEntry `json:"entries"`
+	Total    CostReportEntry   `json:"total"`
+	// Workloads breaks the total down per workload, most expensive first.
+	// Empty for workload queries.
+	Workloads []CostWorkloadEntry `json:"workloads,omitempty"`
+}
+
+type CostReportEntry struct {
+	// Period is "2006-01-02" for daily and "2006-01" for monthly rollups.
+	Period         string  `json:"period"`
+	CpuCoreHours   float64 `json:"cpuCoreHours"`
+	MemoryGiBHours float64 `json:"memoryGiBHours"`
+	CpuCost        float64 `json:"cpuCost"`
+	MemoryCost     float64 `json:"memoryCost"`
+	// IdleCost is the share of unused node capacity attributed to the entry.
+	IdleCost  float64 `json:"idleCost"`
+	TotalCost float64 `json:"totalCost"`
+	// MissedHours counts the hours of the period whose cost is unknown
+	// because no replica was leading, so the costs are lower than they were.
+	MissedHours int `json:"missedHours"`
+}
+
+func (self *CostReportEntry) add(other CostReportEntry) {
+	self.CpuCoreHours += other.CpuCoreHours
+	self.MemoryGiBHours += other.MemoryGiBHours
+	self.CpuCost += other.CpuCost
+	self.MemoryCost += other.MemoryCost
+	self.IdleCost += other.IdleCost
+	self.TotalCost += other.TotalCost
+	self.MissedHours += other.MissedHours
+}
+
+type CostWorkloadEntry struct {
+	Namespace string  `json:"namespace"`
+	Kind      string  `json:"kind"`
+	Name      string  `json:"name"`
+	TotalCost float64 `json:"totalCost"`
+}
+
+type costEngine struct {
+	logger            *slog.Logger
+	config            cfg.ConfigModule
+	valkey            valkeyclient.ValkeyClient
+	ownerCacheService store.OwnerCacheService
+	apiService        Api
+	leaderElector     LeaderElector
+
+	// bookLock serialises the read-modify-write of the daily documents.
+	bookLock sync.Mutex
+}
+
+func NewCostEngine(logger *slog.Logger, configModule cfg.ConfigModule, valkey valkeyclient.ValkeyClient, ownerCacheService store.OwnerCacheService) CostEngine {
+	self := &costEngine{}
+	self.logger = logger
+	self.config = configModule
+	self.valkey = valkey
+	self.ownerCacheService = ownerCacheService
+
+	return self
+}
+
+func (self *costEngine) Link(apiService Api, leaderElector LeaderElector) {
+	assert.Assert(apiService != nil)
+	assert.Assert(leaderElector != nil)
+
+	self.apiService = apiService
+	self.leaderElector = leaderElector
+}
+
+func (self *costEngine) Run() {
+	assert.Assert(self.apiService != nil)
+	assert.Assert(self.leaderElector != nil)
+
+	prices, err := ParseNodePriceTable(self.config.Get("MO_COST_NODE_PRICES"))
+	assert.Assert(err == nil, err)
+	if len(prices) == 0 {
+		self.logger.Debug("cost engine is disabled, MO_COST_NODE_PRICES is empty")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown.Add(cancel)
+
+	go func() {
+		for {
+			// Book every full hour a minute after it ended, so the last pod
+			// stats of that hour have been written.
+			now := time.Now().UTC()
+			next := now.Truncate(time.Hour).Add(time.Hour + time.Minute)
+			if !sleepCtx(ctx, next.Sub(now)) {
+				return
+			}
+			if !self.leaderElector.IsLeading() {
+				continue
+			}
+			self.bookLastHour(prices, next.Truncate(time.Hour))
+		}
+	}()
+}
+
+// bookLastHour books the hour before end. The hours the engine missed while
+// no replica was leading, e.g. during a restart or a leader change, are
+// recorded as missed instead of booked with the pods and nodes of today.
+// Gaps are only looked for as far back as the raw pod stats reach.
+func (self *costEngine) bookLastHour(prices NodePriceTable, end time.Time) {
+	limit := rawStatsRetention(valkeyclient.MAX_RETENTION_TIME, valkeyclient.MAX_RETENTION_SIZE)
+	days := map[string]*costDay{}
+	hours, err := missedCostHours(end, limit, func(hour time.Time) (bool, error) {
+		date := hour.Format(time.DateOnly)
+		day, ok := days[date]
+		if !ok {
+			loaded, err := self.loadDay(date)
+			if err != nil {
+				return false, err
+			}
+			day, days[date] = loaded, loaded
+		}
+		return day != nil && (slices.Contains(day.Hours, hour.Hour()) || slices.Contains(day.MissedHours, hour.Hour())), nil
+	})
+	last := end.Add(-time.Hour)
+	if err != nil {
+		self.logger.Error("failed to find unbooked cost hours", "error", err)
+		hours = []time.Time{last}
+	}
+
+	missed := slices.DeleteFunc(slices.Clone(hours), func(hour time.Time) bool { return hour.Equal(last) })
+	if len(missed) > 0 {
+		self.logger.Warn("cost of hours without a leading replica is not booked", "from", missed[0], "hours", len(missed))
+		if err := self.recordMissedHours(missed); err != nil {
+			self.logger.Error("failed to record missed cost hours", "error", err)
+		}
+	}
+	if slices.ContainsFunc(hours, func(hour time.Time) bool { return hour.Equal(last) }) {
+		if err := self.bookHour(prices, last, end); err != nil {
+			self.logger.Error("failed to book hourly cost", "hour", last, "error", err)
+		}
+	}
+}
+
+// recordMissedHours adds hours to the missed hours of their days.
+func (self *costEngine) recordMissedHours(hours []time.Time) error {
+	self.bookLock.Lock()
+	defer self.bookLock.Unlock()
+
+	days := map[string]*costDay{}
+	for _, hour := range hours {
+		date := hour.Format(time.DateOnly)
+		day, ok := days[date]
+		if !ok {
+			loaded, err := self.loadDay(date)
+			if err != nil {
+				return err
+			}
+			if loaded == nil {
+				loaded = &costDay{Date: date, Currency: self.config.Get("MO_COST_CURRENCY"), Workloads: map[string]*CostAllocation{}}
+			}
+			day, days[date] = loaded, loaded
+		}
+		if !slices.Contains(day.Hours, hour.Hour()) && !slices.Contains(day.MissedHours, hour.Hour()) {
+			day.MissedHours = append(day.MissedHours, hour.Hour())
+		}
+	}
+	for date, day := range days {
+		if err := self.valkey.SetObject(day, costDayTTL, DB_STATS_COST_BUCKET_NAME, DB_STATS_COST_DAILY_NAME, date); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// missedCostHours returns the start of every unbooked hour in [end-limit, end),
+// oldest first; isBooked also reports hours already recorded as missed. Hours before the first booked one are left out: they predate
+// the cost engine or an outage longer than limit, so there is no booking to
+// catch up with. The hour before end is returned whenever it is unbooked.
+func missedCostHours(end time.Time, limit time.Duration, isBooked func(hour time.Time) (bool, error)) ([]time.Time, error) {
+	first := end.Add(-limit).Truncate(time.Hour)
+	if first.Before(end.Add(-limit)) {
+		first = first.Add(time.Hour)
+	}
+	last := end.Add(-time.Hour)
+	hours := []time.Time{}
+	seenBooked := false
+	for hour := first; !hour.After(last); hour = hour.Add(time.Hour) {
+		booked, err := isBooked(hour)
+		if err != nil {
+			return nil, err
+		}
+		if booked {
+			seenBooked = true
+			continue
+		}
+		if seenBooked || hour.Equal(last) {
+			hours = append(hours, hour)
+		}
+	}
+	return hours, nil
+}
+
+// bookHour allocates the cost of [start, end) and merges it into the daily
+// document of start. Pods are taken from the store at booking time, so a pod
+// that finished during the hour is not charged; its share ends up as idle
+// cost.
+func (self *costEngine) bookHour(prices NodePriceTable, start time.Time, end time.Time) error {
+	currency := self.config.Get("MO_COST_CURRENCY")
+	cpuShare, err := strconv.ParseFloat(self.config.Get("MO_COST_CPU_SHARE"), 64)
+	if err != nil {
+		return fmt.Errorf("invalid MO_COST_CPU_SHARE: %s", err.Error())
+	}
+
+	pods := store.GetPods("*")
+	usage := self.podUsage(pods, start, end)
+	allocations, idle := allocateNodeCost(store.GetNodes(), pods, usage, prices, cpuShare, start, end, self.workloadForPod)
+
+	self.bookLock.Lock()
+	defer self.bookLock.Unlock()
+
+	date := start.Format(time.DateOnly)
+	day, err := self.loadDay(date)
+	if err != nil {
+		return err
+	}
+	if day == nil {
+		day = &costDay{Date: date, Workloads: map[string]*CostAllocation{}}
+	}
+	if slices.Contains(day.Hours, start.Hour()) {
+		return nil
+	}
+	day.Currency = currency
+	day.Hours = append(day.Hours, start.Hour())
+	day.IdleCost += idle
+	for key, allocation := range allocations {
+		if existing, ok := day.Workloads[key]; ok {
+			existing.add(allocation)
+			continue
+		}
+		day.Workloads[key] = &allocation
+	}
+
+	return self.valkey.SetObject(day, costDayTTL, DB_STATS_COST_BUCKET_NAME, DB_STATS_COST_DAILY_NAME, date)
+}
+
+func (self *costEngine) loadDay(date string) (*costDay, error) {
+	day, err := valkeyclient.GetObjectForKey[costDay](self.valkey, DB_STATS_COST_BUCKET_NAME, DB_STATS_COST_DAILY_NAME, date)
+	if err != nil {
+		if valkey.IsValkeyNil(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load cost of %s: %w", date, err)
+	}
+	if day.Workloads == nil {
+		day.Workloads = map[string]*CostAllocation{}
+	}
+	return day, nil
+}
+
+func (self *costEngine) workloadForPod(pod v1.Pod) (string, string) {
+	controller := self.ownerCacheService.ControllerForPod(pod.Namespace, pod.Name)
+	if controller == nil {
+		return utils.PodResource.Kind, pod.Name
+	}
+	return controller.Kind, controller.ResourceName
+}
+
+// podUsage averages the pod stats samples of [start, end) per pod. The
+// samples are stored per controller, so every controller is read once.
+func (self *costEngine) podUsage(pods []v1.Pod, start time.Time, end time.Time) map[string]podCostUsage {
+	result := map[string]podCostUsage{}
+	read := map[string]bool{}
+	for _, pod := range pods {
+		_, name := self.workloadForPod(pod)
+		streamKey := pod.Namespace + "/" + name
+		if read[streamKey] {
+			continue
+		}
+		read[streamKey] = true
+
+		samples, err := valkeyclient.GetObjectsFromSortedListWithRange[structs.PodStats](self.valkey, start, end, DB_STATS_POD_STATS_BUCKET_NAME, pod.Namespace, name)
+		if err != nil {
+			self.logger.Debug("failed to read pod stats for cost", "namespace", pod.Namespace, "name", name, "error", err)
+			continue
+		}
+		for _, sample := range samples {
+			key := pod.Namespace + "/" + sample.PodName
+			entry := result[key]
+			entry.cpuMillicores += float64(sample.Cpu)
+			entry.memoryBytes += float64(sample.Memory)
+			entry.samples++
+			result[key] = entry
+		}
+	}
+	for key, entry := range result {
+		entry.cpuMillicores /= float64(entry.samples)
+		entry.memoryBytes /= float64(entry.samples)
+		result[key] = entry
+	}
+	return result
+}
+
+func (self *costEngine) GetWorkspaceCost(workspaceName string, query CostQuery) (*CostReport, error) {
+	controllers, err := self.apiService.GetWorkspaceControllers(workspaceName)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for _, controller := range controllers {
+		keys[costWorkloadKey(controller.GetNamespace(), controller.GetKind(), controller.GetName())] = true
+	}
+	return self.report(query, true, func(key string, _ *CostAllocation) bool { return keys[key] })
+}
+
+func (self *costEngine) GetNamespaceCost(namespace string, query CostQuery) (*CostReport, error) {
+	return self.report(query, true, func(_ string, allocation *CostAllocation) bool {
+		return allocation.Namespace == namespace
+	})
+}
+
+func (self *costEngine) GetWorkloadCost(namespace string, kind string, name string, query CostQuery) (*CostReport, error) {
+	key := costWorkloadKey(namespace, kind, name)
+	return self.report(query, false, func(candidate string, _ *CostAllocation) bool { return candidate == key })
+}
+
+func (self *costEngine) report(query CostQuery, breakdown bool, match func(key string, allocation *CostAllocation) bool) (*CostReport, error) {
+	rollup := query.Rollup
+	if rollup == "" {
+		rollup = CostRollupDaily
+	}
+	if rollup != CostRollupDaily && rollup != CostRollupMonthly {
+		return nil, fmt.Errorf("rollup must be '%s' or '%s'", CostRollupDaily, CostRollupMonthly)
+	}
+	days := query.Days
+	if days <= 0 {
+		days = 30
+	}
+	days = min(days, costMaxDays)
+
+	loaded := make([]*costDay, 0, days)
+	today := time.Now().UTC()
+	for i := days - 1; i >= 0; i-- {
+		day, err := self.loadDay(today.AddDate(0, 0, -i).Format(time.DateOnly))
+		if err != nil {
+			return nil, err
+		}
+		if day != nil {
+			loaded = append(loaded, day)
+		}
+	}
+
+	report := buildCostReport(loaded, rollup, breakdown, match)
+	if report.Currency == "" {
+		report.Currency = self.config.Get("MO_COST_CURRENCY")
+	}
+	return report, nil
+}
+
+type podCostUsage struct {
+	cpuMillicores float64
+	memoryBytes   float64
+	samples       int
+}
+
+func costWorkloadKey(namespace string, kind string, name string) string {
+	return namespace + "/" + kind + "/" + name
+}
+
+// allocateNodeCost books the price of every node for [start, end) to the pods
+// scheduled on it and returns the allocations per workload together with the
+// idle cost nobody was charged for. Nodes without a price are skipped. The CPU
+// and memory price of a node is split by max(demand, capacity), so the pods of
+// an overcommitted node never pay more than the node costs.
+func allocateNodeCost(
+	nodes []v1.Node,
+	pods []v1.Pod,
+	usage map[string]podCostUsage,
+	prices NodePriceTable,
+	cpuShare float64,
+	start time.Time,
+	end time.Time,
+	workloadForPod func(pod v1.Pod) (string, string),
+) (map[string]CostAllocation, float64) {
+	podsByNode := map[string][]v1.Pod{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
+	}
+
+	allocations := map[string]CostAllocation{}
+	idle := 0.0
+	windowHours := end.Sub(start).Hours()
+	for _, node := range nodes {
+		hourlyPrice, ok := prices.PriceFor(node)
+		if !ok || hourlyPrice <= 0 {
+			continue
+		}
+		cores := float64(node.Status.Capacity.Cpu().MilliValue()) / 1000
+		memoryGiB := float64(node.Status.Capacity.Memory().Value()) / costBytesPerGiB
+		if cores <= 0 || memoryGiB <= 0 {
+			continue
+		}
+		nodeCost := hourlyPrice * windowHours
+
+		type podDemand struct {
+			pod       v1.Pod
+			coreHours float64
+			gibHours  float64
+		}
+		demands := []podDemand{}
+		demandCoreHours, demandGiBHours := 0.0, 0.0
+		for _, pod := range podsByNode[node.Name] {
+			hours := windowHours
+			if pod.Status.StartTime != nil && pod.Status.StartTime.After(start) {
+				hours = max(0, end.Sub(pod.Status.StartTime.Time).Hours())
+			}
+			if hours == 0 {
+				continue
+			}
+
+			requestMillicores, requestBytes := podRequests(pod)
+			measured := usage[pod.Namespace+"/"+pod.Name]
+			demand := podDemand{
+				pod:       pod,
+				coreHours: max(requestMillicores, measured.cpuMillicores) / 1000 * hours,
+				gibHours:  max(requestBytes, measured.memoryBytes) / costBytesPerGiB * hours,
+			}
+			demands = append(demands, demand)
+			demandCoreHours += demand.coreHours
+			demandGiBHours += demand.gibHours
+		}
+
+		cpuRate := hourlyPrice * cpuShare * windowHours / max(cores*windowHours, demandCoreHours)
+		memoryRate := hourlyPrice * (1 - cpuShare) * windowHours / max(memoryGiB*windowHours, demandGiBHours)
+		charged := 0.0
+		for _, demand := range demands {
+			pod, coreHours, gibHours := demand.pod, demand.coreHours, demand.gibHours
+			kind, name := workloadForPod(pod)
+			key := costWorkloadKey(pod.Namespace, kind, name)
+			allocation := allocations[key]
+			allocation.Namespace, allocation.Kind, allocation.Name = pod.Namespace, kind, name
+			allocation.add(CostAllocation{
+				CpuCoreHours:   coreHours,
+				MemoryGiBHours: gibHours,
+				CpuCost:        coreHours * cpuRate,
+				MemoryCost:     gibHours * memoryRate,
+			})
+			allocations[key] = allocation
+			charged += coreHours*cpuRate + gibHours*memoryRate
+		}
+
+		// max guards against rounding, charged never exceeds the node cost
+		idle += max(0, nodeCost-charged)
+	}
+	return allocations, idle
+}
+
+func podRequests(pod v1.Pod) (float64, float64) {
+	millicores, bytes := 0.0, 0.0
+	for _, container := range pod.Spec.Containers {
+		millicores += float64(container.Resources.Requests.Cpu().MilliValue())
+		bytes += float64(container.Resources.Requests.Memory().Value())
+	}
+	return millicores, bytes
+}
+
+// buildCostReport sums the matching allocations of each day into the rollup
+// periods. The idle cost of a day is attributed in proportion to the share
+// the matching workloads have in that day's allocated cost.
+func buildCostReport(days []*costDay, rollup string, breakdown bool, match func(key string, allocation *CostAllocation) bool) *CostReport {
+	report := &CostReport{Rollup: rollup, Entries: []CostReportEntry{}}
+	periods := map[string]*CostReportEntry{}
+	workloads := map[string]*CostWorkloadEntry{}
+
+	for _, day := range days {
+		if report.Currency == "" {
+			report.Currency = day.Currency
+		}
+		period := day.Date
+		if rollup == CostRollupMonthly && len(period) >= 7 {
+			period = period[:7]
+		}
+		entry, ok := periods[period]
+		if !ok {
+			entry = &CostReportEntry{Period: period}
+			periods[period] = entry
+		}
+		entry.MissedHours += len(day.MissedHours)
+
+		allocated := day.allocatedCost()
+		for key, allocation := range day.Workloads {
+			if !match(key, allocation) {
+				continue
+			}
+			idleShare := 0.0
+			if allocated > 0 {
+				idleShare = day.IdleCost * allocation.cost() / allocated
+			}
+			entry.add(CostReportEntry{
+				CpuCoreHours:   allocation.CpuCoreHours,
+				MemoryGiBHours: allocation.MemoryGiBHours,
+				CpuCost:        allocation.CpuCost,
+				MemoryCost:     allocation.MemoryCost,
+				IdleCost:       idleShare,
+				TotalCost:      allocation.cost() + idleShare,
+			})
+
+			if breakdown {
+				workload, ok := workloads[key]
+				if !ok {
+					workload = &CostWorkloadEntry{Namespace: allocation.Namespace, Kind: allocation.Kind, Name: allocation.Name}
+					workloads[key] = workload
+				}
+				workload.TotalCost += allocation.cost() + idleShare
+			}
+		}
+	}
+
+	for _, period := range slices.Sorted(maps.Keys(periods)) {
+		report.Entries = append(report.Entries, *periods[period])
+		report.Total.add(*periods[period])
+	}
+	for _, workload := range workloads {
+		report.Workloads = append(report.Workloads, *workload)
+	}
+	slices.SortFunc(report.Workloads, func(a, b CostWorkloadEntry) int {
+		if a.TotalCost != b.TotalCost {
+			if a.TotalCost > b.TotalCost {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(costWorkloadKey(a.Namespace, a.Kind, a.Name), costWorkloadKey(b.Namespace, b.Kind, b.Name))
+	})
+	return report
+}
+
+// NodePriceTable maps nodes to their hourly price. Rules are matched from the
+// most to the least specific: instance type and region, instance type,
+// region, catch-all.
+type NodePriceTable map[string]float64
+
+const nodePriceWildcard = "*"
+
+// ParseNodePriceTable parses MO_COST_NODE_PRICES: a comma separated list of
+// `<instance-type>[@<region>]=<hourly price>` rules, where `*` matches any
+// instance type, e.g. `m5.large=0.096,m5.large@eu-central-1=0.115,*=0.05`.
+func ParseNodePriceTable(value string) (NodePriceTable, error) {
+	table := NodePriceTable{}
+	for rule := range strings.SplitSeq(value, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		selector, price, found := strings.Cut(rule, "=")
+		if !found {
+			return nil, fmt.Errorf("price rule '%s' needs the form <instance-type>[@<region>]=<price>", rule)
+		}
+		selector = strings.TrimSpace(selector)
+		instanceType, _, _ := strings.Cut(selector, "@")
+		if instanceType == "" {
+			return nil, fmt.Errorf("price rule '%s' has no instance type, use '*' to match any", rule)
+		}
+		hourly, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+		if err != nil || hourly < 0 {
+			return nil, fmt.Errorf("price rule '%s' needs a non-negative number as price", rule)
+		}
+		table[selector] = hourly
+	}
+	return table, nil
+}
+
+func (self NodePriceTable) PriceFor(node v1.Node) (float64, bool) {
+	instanceType := node.Labels[v1.LabelInstanceTypeStable]
+	if instanceType == "" {
+		instanceType = node.Labels[v1.LabelInstanceType]
+	}
+	region := nodeRegion(node)
+
+	candidates := []string{}
+	if instanceType != "" && region != "" {
+		candidates = append(candidates, instanceType+"@"+region)
+	}
+	if instanceType != "" {
+		candidates = append(candidates, instanceType)
+	}
+	if region != "" {
+		candidates = append(candidates, nodePriceWildcard+"@"+region)
+	}
+	candidates = append(candidates, nodePriceWildcard)
+
+	for _, candidate := range candidates {
+		if price, ok := self[candidate]; ok {
+			return price, true
+		}
+	}
+	return 0, false
+}
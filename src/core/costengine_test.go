@@ -0,0 +1,262 @@
+package core
+
+import (
+	"io"
+	"log/slog"
+	"mogenius-operator/src/config"
+	"mogenius-operator/src/valkeyclient"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseNodePriceTable(t *testing.T) {
+	table, err := ParseNodePriceTable(" m5.large=0.096, m5.large@eu-central-1=0.115,*@eu-central-1=0.2,*=0.05 ")
+	require.NoError(t, err)
+	assert.Len(t, table, 4)
+
+	empty, err := ParseNodePriceTable("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	for _, invalid := range []string{"m5.large", "m5.large=cheap", "@eu-central-1=0.1", "*=-1"} {
+		_, err := ParseNodePriceTable(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestNodePriceTablePriceFor(t *testing.T) {
+	table, err := ParseNodePriceTable("m5.large=0.096,m5.large@eu-central-1=0.115,*@us-east-1=0.2")
+	require.NoError(t, err)
+
+	node := func(instanceType string, region string) v1.Node {
+		labels := map[string]string{}
+		if instanceType != "" {
+			labels[v1.LabelInstanceTypeStable] = instanceType
+		}
+		if region != "" {
+			labels[v1.LabelTopologyRegion] = region
+		}
+		return v1.Node{ObjectMeta: metav1.ObjectMeta{Labels: labels}}
+	}
+
+	price, ok := table.PriceFor(node("m5.large", "eu-central-1"))
+	assert.True(t, ok)
+	assert.Equal(t, 0.115, price)
+
+	price, ok = table.PriceFor(node("m5.large", "eu-west-1"))
+	assert.True(t, ok)
+	assert.Equal(t, 0.096, price)
+
+	price, ok = table.PriceFor(node("c5.xlarge", "us-east-1"))
+	assert.True(t, ok)
+	assert.Equal(t, 0.2, price)
+
+	_, ok = table.PriceFor(node("c5.xlarge", "eu-west-1"))
+	assert.False(t, ok)
+}
+
+func TestAllocateNodeCost(t *testing.T) {
+	start := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	nodes := []v1.Node{{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{v1.LabelInstanceTypeStable: "m5.large"}},
+		Status: v1.NodeStatus{Capacity: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("2"),
+			v1.ResourceMemory: resource.MustParse("8Gi"),
+		}},
+	}}
+	pod := func(name string, cpu string, memory string, started time.Time) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+			Spec: v1.PodSpec{NodeName: "node-a", Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse(cpu),
+					v1.ResourceMemory: resource.MustParse(memory),
+				}},
+			}}},
+			Status: v1.PodStatus{Phase: v1.PodRunning, StartTime: &metav1.Time{Time: started}},
+		}
+	}
+	pods := []v1.Pod{
+		pod("api-1", "500m", "2Gi", start.Add(-time.Hour)),
+		// started half way through the hour
+		pod("api-2", "500m", "2Gi", start.Add(30*time.Minute)),
+	}
+	// api-1 used more CPU than it requested and is charged for the usage
+	usage := map[string]podCostUsage{"shop/api-1": {cpuMillicores: 1000, memoryBytes: 1 << 30, samples: 60}}
+	prices := NodePriceTable{"m5.large": 1.0}
+
+	allocations, idle := allocateNodeCost(nodes, pods, usage, prices, 0.5, start, end, func(pod v1.Pod) (string, string) {
+		return "Deployment", "api"
+	})
+
+	require.Len(t, allocations, 1)
+	api := allocations["shop/Deployment/api"]
+	assert.InDelta(t, 1.25, api.CpuCoreHours, 1e-9)
+	assert.InDelta(t, 3.0, api.MemoryGiBHours, 1e-9)
+	// 0.25 per core hour, 0.0625 per GiB hour
+	assert.InDelta(t, 0.3125, api.CpuCost, 1e-9)
+	assert.InDelta(t, 0.1875, api.MemoryCost, 1e-9)
+	assert.InDelta(t, 0.5, idle, 1e-9)
+}
+
+func TestAllocateNodeCostOvercommitted(t *testing.T) {
+	start := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	nodes := []v1.Node{{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+		Status: v1.NodeStatus{Capacity: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("2"),
+			v1.ResourceMemory: resource.MustParse("4Gi"),
+		}},
+	}}
+	pods := []v1.Pod{}
+	for _, name := range []string{"api-1", "api-2"} {
+		pods = append(pods, v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+			Spec:       v1.PodSpec{NodeName: "node-a"},
+			Status:     v1.PodStatus{Phase: v1.PodRunning},
+		})
+	}
+	// both pods use the whole node, twice its capacity together
+	usage := map[string]podCostUsage{
+		"shop/api-1": {cpuMillicores: 2000, memoryBytes: 4 << 30, samples: 60},
+		"shop/api-2": {cpuMillicores: 2000, memoryBytes: 4 << 30, samples: 60},
+	}
+
+	allocations, idle := allocateNodeCost(nodes, pods, usage, NodePriceTable{"*": 1.0}, 0.5, start, end, func(pod v1.Pod) (string, string) {
+		return "Pod", pod.Name
+	})
+
+	require.Len(t, allocations, 2)
+	for _, allocation := range allocations {
+		assert.InDelta(t, 2.0, allocation.CpuCoreHours, 1e-9)
+		assert.InDelta(t, 0.25, allocation.CpuCost, 1e-9)
+		assert.InDelta(t, 0.25, allocation.MemoryCost, 1e-9)
+	}
+	assert.InDelta(t, 0.0, idle, 1e-9)
+}
+
+func TestMissedCostHours(t *testing.T) {
+	end := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	hour := func(h int) time.Time { return time.Date(2026, 10, 17, h, 0, 0, 0, time.UTC) }
+	bookedHours := func(booked ...int) func(time.Time) (bool, error) {
+		return func(candidate time.Time) (bool, error) {
+			return candidate.Day() == 17 && slices.Contains(booked, candidate.Hour()), nil
+		}
+	}
+
+	// nothing booked yet: only the hour that just ended
+	hours, err := missedCostHours(end, 24*time.Hour, bookedHours())
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{hour(9)}, hours)
+
+	// the operator was down from 6 to 9
+	hours, err = missedCostHours(end, 24*time.Hour, bookedHours(4, 5))
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{hour(6), hour(7), hour(8), hour(9)}, hours)
+
+	// hours older than the stats are not caught up
+	hours, err = missedCostHours(end, 3*time.Hour, bookedHours(4, 5))
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{hour(9)}, hours)
+
+	hours, err = missedCostHours(end, 24*time.Hour, bookedHours(4, 5, 6, 7, 8, 9))
+	require.NoError(t, err)
+	assert.Empty(t, hours)
+}
+
+func TestBuildCostReport(t *testing.T) {
+	days := []*costDay{
+		{
+			Date:     "2026-09-30",
+			Currency: "EUR",
+			IdleCost: 3,
+			Workloads: map[string]*CostAllocation{
+				"shop/Deployment/api": {Namespace: "shop", Kind: "Deployment", Name: "api", CpuCost: 2, MemoryCost: 1},
+				"blog/Deployment/web": {Namespace: "blog", Kind: "Deployment", Name: "web", CpuCost: 3, MemoryCost: 3},
+			},
+		},
+		{
+			Date:        "2026-10-01",
+			Currency:    "EUR",
+			MissedHours: []int{6, 7},
+			Workloads: map[string]*CostAllocation{
+				"shop/Deployment/api": {Namespace: "shop", Kind: "Deployment", Name: "api", CpuCost: 1},
+				"shop/StatefulSet/db": {Namespace: "shop", Kind: "StatefulSet", Name: "db", CpuCost: 4},
+			},
+		},
+	}
+	inShop := func(_ string, allocation *CostAllocation) bool { return allocation.Namespace == "shop" }
+
+	daily := buildCostReport(days, CostRollupDaily, true, inShop)
+	assert.Equal(t, "EUR", daily.Currency)
+	require.Len(t, daily.Entries, 2)
+	assert.Equal(t, "2026-09-30", daily.Entries[0].Period)
+	// shop caused a third of the allocated cost of the first day
+	assert.InDelta(t, 1.0, daily.Entries[0].IdleCost, 1e-9)
+	assert.InDelta(t, 4.0, daily.Entries[0].TotalCost, 1e-9)
+	assert.InDelta(t, 9.0, daily.Total.TotalCost, 1e-9)
+	require.Len(t, daily.Workloads, 2)
+	// api: 3 + 1 idle on the first day, 1 on the second
+	assert.Equal(t, "api", daily.Workloads[0].Name)
+	assert.InDelta(t, 5.0, daily.Workloads[0].TotalCost, 1e-9)
+	assert.Equal(t, "db", daily.Workloads[1].Name)
+	assert.Equal(t, 0, daily.Entries[0].MissedHours)
+	assert.Equal(t, 2, daily.Entries[1].MissedHours, "reports show the hours whose cost is unknown")
+	assert.Equal(t, 2, daily.Total.MissedHours)
+
+	monthly := buildCostReport(days, CostRollupMonthly, false, inShop)
+	require.Len(t, monthly.Entries, 2)
+	assert.Equal(t, "2026-09", monthly.Entries[0].Period)
+	assert.Equal(t, "2026-10", monthly.Entries[1].Period)
+	assert.Empty(t, monthly.Workloads)
+}
+
+func TestRecordMissedHours(t *testing.T) {
+	mr := miniredis.RunT(t)
+	configModule := config.NewConfig()
+	for key, value := range map[string]string{
+		"MO_VALKEY_ADDR":                 mr.Addr(),
+		"MO_VALKEY_USERNAME":             "",
+		"MO_VALKEY_PASSWORD":             "",
+		"MO_STATS_RETENTION_MAX_ENTRIES": "",
+		"MO_STATS_RETENTION_HOURS":       "",
+		"MO_COST_CURRENCY":               "EUR",
+	} {
+		configModule.Declare(config.ConfigDeclaration{Key: key, DefaultValue: &value})
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	valkey := valkeyclient.NewValkeyClient(logger, configModule)
+	require.NoError(t, valkey.Connect())
+	t.Cleanup(valkey.Close)
+	engine := NewCostEngine(logger, configModule, valkey, nil).(*costEngine)
+
+	booked := &costDay{Date: "2026-10-17", Currency: "EUR", Workloads: map[string]*CostAllocation{}, Hours: []int{5}}
+	require.NoError(t, valkey.SetObject(booked, costDayTTL, DB_STATS_COST_BUCKET_NAME, DB_STATS_COST_DAILY_NAME, booked.Date))
+
+	hour := func(day int, h int) time.Time { return time.Date(2026, 10, day, h, 0, 0, 0, time.UTC) }
+	require.NoError(t, engine.recordMissedHours([]time.Time{hour(16, 23), hour(17, 5), hour(17, 6), hour(17, 7)}))
+	require.NoError(t, engine.recordMissedHours([]time.Time{hour(17, 7)}))
+
+	day, err := engine.loadDay("2026-10-17")
+	require.NoError(t, err)
+	assert.Equal(t, []int{5}, day.Hours)
+	assert.Equal(t, []int{6, 7}, day.MissedHours, "booked hours are not missed and hours are recorded once")
+	assert.Empty(t, day.Workloads, "missed hours book no cost")
+
+	day, err = engine.loadDay("2026-10-16")
+	require.NoError(t, err)
+	assert.Equal(t, []int{23}, day.MissedHours)
+	assert.Equal(t, "EUR", day.Currency)
+}
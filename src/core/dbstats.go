@@ -6,7 +6,9 @@ import (
 	"log/slog"
 	"maps"
 	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/iomonitor"
 	"mogenius-operator/src/networkmonitor"
+	"mogenius-operator/src/podstatscollector"
 	"mogenius-operator/src/store"
 	"mogenius-operator/src/structs"
 	"mogenius-operator/src/utils"
@@ -40,7 +42,7 @@ var liveStatsTTL = 10 * time.Minute // TTL for live-stats snapshot keys (refresh
 
 type ValkeyStatsDb interface {
 	Run()
-	AddInterfaceStatsToDb(stats []networkmonitor.PodNetworkStats)
+	AddInterfaceStatsToDb(nodeName string, stats []networkmonitor.PodNetworkStats)
 	AddNodeStatsToDb(stats []structs.NodeStats) error
 	AddMachineStatsToDb(nodeName string, stats structs.MachineStats) error
 	AddPodStatsToDb(stats []structs.PodStats) error
@@ -50,6 +52,10 @@ type ValkeyStatsDb interface {
 	AddNodeCpuProcessMetricsToDb(nodeName string, data any) error
 	AddNodeTrafficMetricsToDb(nodeName string, data any) error
 	AddSnoopyStatusToDb(nodeName string, data networkmonitor.SnoopyStatus) error
+	AddPodNetworkFlowsToDb(nodeName string, flows []networkmonitor.PodNetworkFlow) error
+	AddNodeIoProcessMetricsToDb(nodeName string, data any) error
+	AddPodIoStatsToDb(nodeName string, stats []iomonitor.PodIoStats) error
+	AddPodEphemeralStorageToDb(nodemetrics []podstatscollector.NodeMetrics) error
 	GetCniData() ([]structs.CniData, error)
 	GetLatestNodeStatsForNode(nodeName string) (*structs.NodeStats, error)
 	GetMachineStatsForNode(nodeName string) (*structs.MachineStats, error)
@@ -59,6 +65,8 @@ type ValkeyStatsDb interface {
 	GetWorkspaceStatsCpuUtilization(timeOffsetInMinutes int, resources []unstructured.Unstructured) ([]GenericChartEntry, error)
 	GetWorkspaceStatsMemoryUtilization(timeOffsetInMinutes int, resources []unstructured.Unstructured) ([]GenericChartEntry, error)
 	GetWorkspaceStatsTrafficUtilization(timeOffsetInMinutes int, resources []unstructured.Unstructured) ([]GenericChartEntry, error)
+	GetWorkspaceStatsIoUtilization(timeOffsetInMinutes int, resources []unstructured.Unstructured) ([]WorkspaceIoChartEntry, error)
+	GetTrafficFlowGraph(namespaces []string, timeOffsetMinutes int) (*TrafficFlowGraph, error)
 	GetClusterDashboardStats(workspaceNames []string, getControllers func(string) ([]unstructured.Unstructured, error)) (ClusterDashboardStats, error)
 	ReplaceCniData(data []structs.CniData)
 }
@@ -71,18 +79,26 @@ type valkeyStatsDb struct {
 
 	lastPodNetworkStats     []networkmonitor.PodNetworkStats
 	lastPodNetworkStatsLock sync.RWMutex
+
+	// cumulative I/O counters per container id from the previous AddPodIoStatsToDb
+	lastPodIoCounters     map[string]ioCounters
+	lastPodIoCountersLock sync.Mutex
+
+	// downsampled tiers of the pod, traffic and node stats
+	rollups *statsRollups
 }
 
 func NewValkeyStatsModule(logger *slog.Logger, config cfg.ConfigModule, valkey valkeyclient.ValkeyClient, ownerCacheService store.OwnerCacheService) ValkeyStatsDb {
-	dbStatsModule := valkeyStatsDb{
+	dbStatsModule := &valkeyStatsDb{
 		config:              config,
 		logger:              logger,
 		valkey:              valkey,
 		ownerCacheService:   ownerCacheService,
 		lastPodNetworkStats: []networkmonitor.PodNetworkStats{},
 	}
+	dbStatsModule.rollups = newStatsRollups(statsRollupTiersFromConfig(config), dbStatsModule.storeStatsRollup)
 
-	return &dbStatsModule
+	return dbStatsModule
 }
 
 func (self *valkeyStatsDb) Run() {
@@ -117,7 +133,7 @@ func (self *valkeyStatsDb) GetMachineStatsForNode(nodeName string) (*structs.Mac
 	return machineStats, nil
 }
 
-func (self *valkeyStatsDb) AddInterfaceStatsToDb(currentStats []networkmonitor.PodNetworkStats) {
+func (self *valkeyStatsDb) AddInterfaceStatsToDb(nodeName string, currentStats []networkmonitor.PodNetworkStats) {
 	self.lastPodNetworkStatsLock.Lock()
 	lastStats := self.lastPodNetworkStats
 	self.lastPodNetworkStats = currentStats
@@ -129,6 +145,9 @@ func (self *valkeyStatsDb) AddInterfaceStatsToDb(currentStats []networkmonitor.P
 		lastStatsMap[e.Pod] = e
 	}
 
+	type workloadKey struct{ namespace, name string }
+	rollupSamples := map[workloadKey]map[string]float64{}
+	now := time.Now()
 	for _, currentStat := range currentStats {
 		controller := self.ownerCacheService.ControllerForPod(currentStat.Namespace, currentStat.Pod)
 		if controller == nil {
@@ -191,7 +210,24 @@ func (self *valkeyStatsDb) AddInterfaceStatsToDb(currentStats []networkmonitor.P
 		if err != nil {
 			self.logger.Error("Error adding interface stats", "namespace", currentStat.Namespace, "podName", currentStat.Pod, "error", err)
 		}
+
+		key := workloadKey{currentStat.Namespace, controller.ResourceName}
+		sample, ok := rollupSamples[key]
+		if !ok {
+			sample = map[string]float64{}
+			rollupSamples[key] = sample
+		}
+		sample["receivedBytes"] += float64(deltaStat.ReceivedBytes)
+		sample["transmitBytes"] += float64(deltaStat.TransmitBytes)
+		sample["receivedPackets"] += float64(deltaStat.ReceivedPackets)
+		sample["transmitPackets"] += float64(deltaStat.TransmitPackets)
+	}
+
+	// Each node only sees its own pods, so traffic rollups are kept per node.
+	for key, sample := range rollupSamples {
+		self.rollups.add(DB_STATS_TRAFFIC_ROLLUP_BUCKET_NAME, []string{key.namespace, key.name, nodeName}, now, sample)
 	}
+	self.flushStatsRollups()
 }
 
 func (self *valkeyStatsDb) ReplaceCniData(data []structs.CniData) {
@@ -213,6 +249,18 @@ func (self *valkeyStatsDb) GetCniData() ([]structs.CniData, error) {
 }
 
 func (self *valkeyStatsDb) GetPodStatsEntriesForController(kind string, name string, namespace string, timeOffsetMinutes int64) *[]structs.PodStats {
+	if tier := self.statsRollupTierFor(timeOffsetMinutes); tier != nil {
+		entries, err := self.getStatsRollupEntries(tier, timeOffsetMinutes, DB_STATS_POD_STATS_ROLLUP_BUCKET_NAME, namespace, name)
+		if err != nil {
+			self.logger.Error("failed to GetPodStatsEntriesForController", "tier", tier.name, "error", err)
+		}
+		result := make([]structs.PodStats, 0, len(entries))
+		for _, entry := range entries {
+			result = append(result, podStatsFromRollup(entry, namespace, name))
+		}
+		return &result
+	}
+
 	result, err := valkeyclient.GetObjectsFromSortedListWithDuration[structs.PodStats](
 		self.valkey,
 		timeOffsetMinutes,
@@ -231,6 +279,20 @@ func (self *valkeyStatsDb) GetPodStatsEntriesForController(kind string, name str
 }
 
 func (self *valkeyStatsDb) GetTrafficStatsEntriesForController(kind string, name string, namespace string, timeOffsetMinutes int64) *[]networkmonitor.PodNetworkStats {
+	if tier := self.statsRollupTierFor(timeOffsetMinutes); tier != nil {
+		var entries []structs.StatsRollupEntry
+		for _, node := range store.GetNodes() {
+			nodeEntries, err := self.getStatsRollupEntries(tier, timeOffsetMinutes, DB_STATS_TRAFFIC_ROLLUP_BUCKET_NAME, namespace, name, node.Name)
+			if err != nil {
+				self.logger.Error("failed to GetTrafficStatsEntriesForController", "tier", tier.name, "node", node.Name, "error", err)
+				continue
+			}
+			entries = append(entries, nodeEntries...)
+		}
+		result := trafficStatsFromRollups(entries, namespace, name)
+		return &result
+	}
+
 	result, err := valkeyclient.GetObjectsFromSortedListWithDuration[networkmonitor.PodNetworkStats](
 		self.valkey,
 		timeOffsetMinutes,
@@ -257,8 +319,11 @@ func (self *valkeyStatsDb) GetWorkspaceStatsCpuUtilization(
 	if timeOffsetInMinutes < 5 {
 		timeOffsetInMinutes = 5
 	}
-	if timeOffsetInMinutes > 60*24*7 {
-		timeOffsetInMinutes = 60 * 24 * 7 // 7 days
+	if timeOffsetInMinutes > statsMaxTimeOffsetMinutes {
+		timeOffsetInMinutes = statsMaxTimeOffsetMinutes
+	}
+	if tier := self.statsRollupTierFor(int64(timeOffsetInMinutes)); tier != nil {
+		return self.getWorkspaceRollupChart(tier, timeOffsetInMinutes, resources, DB_STATS_POD_STATS_ROLLUP_BUCKET_NAME, false, "cpu"), nil
 	}
 
 	result := make(map[time.Time]GenericChartEntry)
@@ -371,8 +436,11 @@ func (self *valkeyStatsDb) GetWorkspaceStatsMemoryUtilization(
 	if timeOffsetInMinutes < 5 {
 		timeOffsetInMinutes = 5
 	}
-	if timeOffsetInMinutes > 60*24*7 {
-		timeOffsetInMinutes = 60 * 24 * 7 // 7 days
+	if timeOffsetInMinutes > statsMaxTimeOffsetMinutes {
+		timeOffsetInMinutes = statsMaxTimeOffsetMinutes
+	}
+	if tier := self.statsRollupTierFor(int64(timeOffsetInMinutes)); tier != nil {
+		return self.getWorkspaceRollupChart(tier, timeOffsetInMinutes, resources, DB_STATS_POD_STATS_ROLLUP_BUCKET_NAME, false, "memory"), nil
 	}
 
 	result := make(map[time.Time]GenericChartEntry)
@@ -463,13 +531,16 @@ func (self *valkeyStatsDb) GetWorkspaceStatsMemoryUtilization(
 func (self *valkeyStatsDb) GetWorkspaceStatsTrafficUtilization(timeOffsetInMinutes int, resources []unstructured.Unstructured) ([]GenericChartEntry, error) {
 	// Clamp to valid range
 	minOffset := 5
-	maxOffset := 60 * 24 * 7
+	maxOffset := statsMaxTimeOffsetMinutes
 	if timeOffsetInMinutes < minOffset {
 		timeOffsetInMinutes = minOffset
 	}
 	if timeOffsetInMinutes > maxOffset {
 		timeOffsetInMinutes = maxOffset
 	}
+	if tier := self.statsRollupTierFor(int64(timeOffsetInMinutes)); tier != nil {
+		return self.getWorkspaceRollupChart(tier, timeOffsetInMinutes, resources, DB_STATS_TRAFFIC_ROLLUP_BUCKET_NAME, true, "receivedBytes", "transmitBytes"), nil
+	}
 
 	// Aggregate all traffic by minute
 	trafficByMinute := make(map[time.Time]GenericChartEntry)
@@ -574,6 +645,8 @@ func (self *valkeyStatsDb) GetWorkspaceStatsTrafficUtilization(timeOffsetInMinut
 }
 
 func (self *valkeyStatsDb) AddPodStatsToDb(stats []structs.PodStats) error {
+	self.addPodStatsRollups(stats)
+
 	for _, stat := range stats {
 		controller := self.ownerCacheService.ControllerForPod(stat.Namespace, stat.PodName)
 		if controller == nil {
@@ -636,7 +709,16 @@ func (self *valkeyStatsDb) AddNodeStatsToDb(stats []structs.NodeStats) error {
 		}
 		// Also store as latest snapshot for O(1) current-value lookups (used by GetNodeStats)
 		_ = self.valkey.SetObject(stat, liveStatsTTL, DB_STATS_NODE_STATS_LATEST_BUCKET_NAME, stat.Name)
+
+		self.rollups.add(DB_STATS_NODE_STATS_ROLLUP_BUCKET_NAME, []string{stat.Name}, time.Now(), map[string]float64{
+			"podCount":              float64(stat.PodCount),
+			"cpuUsageNanoCores":     float64(stat.CpuUsageNanoCores),
+			"memoryUsageBytes":      float64(stat.MemoryUsageBytes),
+			"memoryWorkingSetBytes": float64(stat.MemoryWorkingSetBytes),
+			"fsUsedBytes":           float64(stat.FsUsedBytes),
+		})
 	}
+	self.flushStatsRollups()
 	return nil
 }
 
@@ -0,0 +1,296 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	moMetrics "mogenius-operator/src/metrics"
+	"mogenius-operator/src/shutdown"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/valkeyclient"
+	"mogenius-operator/src/websocket"
+	"strconv"
+	"sync"
+	"time"
+
+	vgo "github.com/valkey-io/valkey-go"
+)
+
+const (
+	eventOutboxKeyPrefix = "events-outbox"
+	// eventOutboxBatchSize is how many entries a drain reads at once.
+	eventOutboxBatchSize = 100
+	// eventOutboxPollInterval is how often a non-empty outbox checks whether
+	// the events client is connected again.
+	eventOutboxPollInterval = time.Second
+)
+
+// EventOutbox wraps the events client. While the client is disconnected,
+// frames are queued in Valkey instead of being lost, and sent in order once
+// it is connected again. The outbox is bounded by MO_EVENTS_OUTBOX_SIZE; when
+// it is full the oldest entries are dropped.
+//
+// Datagrams with a CoalesceKey replace the queued datagram with the same key
+// and move to the end of the queue, so only the latest state of a job or
+// resource is sent, after everything that happened before it.
+//
+// Once the outbox is drained, frames go straight to the client again; a
+// frame the client fails to send is queued instead.
+//
+// The keys are scoped to the cluster and the namespace of the operator, so
+// operators sharing a Valkey never send each other's events.
+type EventOutbox interface {
+	websocket.WebsocketClient
+	Run()
+}
+
+type eventOutbox struct {
+	websocket.WebsocketClient
+	logger  *slog.Logger
+	valkey  valkeyclient.ValkeyClient
+	maxSize int
+	// orderKey is a sorted set of the queued entries, scored by the
+	// sequence number of their latest write.
+	orderKey string
+	// dataKey is a hash of the queued entries to their frames.
+	dataKey string
+	seqKey  string
+
+	// lock orders direct writes after the queued ones
+	lock sync.Mutex
+	// running is set by Run, once Valkey is connected; until then frames go
+	// straight to the client
+	running bool
+	depth   int
+	wake    chan struct{}
+}
+
+func NewEventOutbox(logger *slog.Logger, configModule cfg.ConfigModule, valkey valkeyclient.ValkeyClient, client websocket.WebsocketClient) EventOutbox {
+	maxSize, err := strconv.Atoi(configModule.Get("MO_EVENTS_OUTBOX_SIZE"))
+	assert.Assert(err == nil, "MO_EVENTS_OUTBOX_SIZE must be a valid integer", err)
+
+	self := &eventOutbox{}
+	self.WebsocketClient = client
+	self.logger = logger
+	self.valkey = valkey
+	self.maxSize = maxSize
+	identity := configModule.Get("MO_CLUSTER_NAME") + "/" + configModule.Get("MO_OWN_NAMESPACE")
+	self.orderKey = eventOutboxKeyPrefix + ":" + identity + ":order"
+	self.dataKey = eventOutboxKeyPrefix + ":" + identity + ":data"
+	self.seqKey = eventOutboxKeyPrefix + ":" + identity + ":seq"
+	self.wake = make(chan struct{}, 1)
+
+	return self
+}
+
+func (self *eventOutbox) Run() {
+	if self.maxSize <= 0 {
+		self.logger.Info("events outbox disabled")
+		return
+	}
+
+	// entries left over from an earlier process are sent after connecting
+	client := self.valkey.GetValkeyClient()
+	depth, err := client.Do(self.valkey.GetContext(), client.B().Zcard().Key(self.orderKey).Build()).AsInt64()
+	if err != nil {
+		self.logger.Error("failed to read events outbox depth", "error", err)
+	}
+	self.lock.Lock()
+	self.running = true
+	self.setDepth(int(depth))
+	self.lock.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown.Add(cancel)
+
+	go func() {
+		ticker := time.NewTicker(eventOutboxPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-self.wake:
+			case <-ticker.C:
+			}
+			self.drain()
+		}
+	}()
+}
+
+func (self *eventOutbox) WriteJSON(data any) error {
+	frame, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	key := ""
+	if datagram, ok := data.(structs.Datagram); ok {
+		key = datagram.CoalesceKey()
+	}
+	return self.write(key, frame)
+}
+
+func (self *eventOutbox) WriteRaw(data []byte) error {
+	return self.write("", data)
+}
+
+func (self *eventOutbox) write(key string, frame []byte) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if !self.running {
+		return self.WebsocketClient.WriteRaw(frame)
+	}
+	if self.depth == 0 && self.IsConnected() {
+		err := self.WebsocketClient.WriteRaw(frame)
+		if err == nil {
+			return nil
+		}
+		self.logger.Warn("failed to send event, queueing it in the outbox", "error", err)
+	}
+	err := self.push(key, frame)
+	if err != nil {
+		moMetrics.IncEventsOutboxDropped(moMetrics.EventsOutboxDroppedError, 1)
+		return fmt.Errorf("failed to queue event in outbox: %w", err)
+	}
+	select {
+	case self.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// push queues a frame; the caller holds the lock.
+func (self *eventOutbox) push(key string, frame []byte) error {
+	client := self.valkey.GetValkeyClient()
+	ctx := self.valkey.GetContext()
+
+	seq, err := client.Do(ctx, client.B().Incr().Key(self.seqKey).Build()).AsInt64()
+	if err != nil {
+		return err
+	}
+	member := key
+	if member == "" {
+		member = "#" + strconv.FormatInt(seq, 10)
+	}
+	results := client.DoMulti(ctx,
+		client.B().Hset().Key(self.dataKey).FieldValue().FieldValue(member, string(frame)).Build(),
+		client.B().Zadd().Key(self.orderKey).ScoreMember().ScoreMember(float64(seq), member).Build(),
+		client.B().Zcard().Key(self.orderKey).Build(),
+	)
+	for _, result := range results {
+		if err := result.Error(); err != nil {
+			return err
+		}
+	}
+	if added, _ := results[1].AsInt64(); added == 0 {
+		moMetrics.IncEventsOutboxDropped(moMetrics.EventsOutboxDroppedCoalesced, 1)
+	}
+	depth, _ := results[2].AsInt64()
+
+	if overflow := int(depth) - self.maxSize; overflow > 0 {
+		dropped, err := client.Do(ctx, client.B().Zpopmin().Key(self.orderKey).Count(int64(overflow)).Build()).AsZScores()
+		if err != nil {
+			return err
+		}
+		members := make([]string, 0, len(dropped))
+		for _, entry := range dropped {
+			members = append(members, entry.Member)
+		}
+		if len(members) > 0 {
+			if err := client.Do(ctx, client.B().Hdel().Key(self.dataKey).Field(members...).Build()).Error(); err != nil {
+				return err
+			}
+		}
+		depth -= int64(len(members))
+		moMetrics.IncEventsOutboxDropped(moMetrics.EventsOutboxDroppedOverflow, len(members))
+		self.logger.Warn("events outbox is full, dropped the oldest events", "dropped", len(members))
+	}
+	self.setDepth(int(depth))
+	return nil
+}
+
+// drain sends the queued frames in order while the client is connected.
+func (self *eventOutbox) drain() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for self.depth > 0 && self.IsConnected() {
+		sent, err := self.drainBatch()
+		if err != nil {
+			self.logger.Error("failed to drain events outbox", "error", err)
+			return
+		}
+		if sent == 0 {
+			return
+		}
+	}
+}
+
+// drainBatch sends the oldest queued frames and removes them from the
+// outbox; the caller holds the lock.
+func (self *eventOutbox) drainBatch() (int, error) {
+	client := self.valkey.GetValkeyClient()
+	ctx := self.valkey.GetContext()
+
+	members, err := client.Do(ctx, client.B().Zrange().Key(self.orderKey).Min("0").Max(strconv.Itoa(eventOutboxBatchSize-1)).Build()).AsStrSlice()
+	if err != nil {
+		return 0, err
+	}
+	if len(members) == 0 {
+		self.setDepth(0)
+		return 0, nil
+	}
+	frames, err := client.Do(ctx, client.B().Hmget().Key(self.dataKey).Field(members...).Build()).ToArray()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var writeErr error
+	for i, frame := range frames {
+		// frames written while disconnected would wait in the client's
+		// bounded write queue and block the outbox once it is full
+		if !self.IsConnected() {
+			break
+		}
+		if data, err := frame.ToString(); err == nil {
+			if writeErr = self.WebsocketClient.WriteRaw([]byte(data)); writeErr != nil {
+				break
+			}
+		}
+		sent = i + 1
+	}
+
+	if sent > 0 {
+		err = self.remove(client, ctx, members[:sent])
+		if err != nil {
+			return 0, err
+		}
+	}
+	return sent, writeErr
+}
+
+func (self *eventOutbox) remove(client vgo.Client, ctx context.Context, members []string) error {
+	results := client.DoMulti(ctx,
+		client.B().Zrem().Key(self.orderKey).Member(members...).Build(),
+		client.B().Hdel().Key(self.dataKey).Field(members...).Build(),
+		client.B().Zcard().Key(self.orderKey).Build(),
+	)
+	for _, result := range results {
+		if err := result.Error(); err != nil {
+			return err
+		}
+	}
+	depth, _ := results[2].AsInt64()
+	self.setDepth(int(depth))
+	return nil
+}
+
+func (self *eventOutbox) setDepth(depth int) {
+	self.depth = depth
+	moMetrics.SetEventsOutboxDepth(depth)
+}
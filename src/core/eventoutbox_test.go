@@ -0,0 +1,162 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mogenius-operator/src/config"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/valkeyclient"
+	"mogenius-operator/src/websocket"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOutboxClient records the frames it is asked to send.
+type fakeOutboxClient struct {
+	websocket.WebsocketClient
+	connected atomic.Bool
+	failing   atomic.Bool
+	frames    []string
+}
+
+func (self *fakeOutboxClient) IsConnected() bool {
+	return self.connected.Load()
+}
+
+func (self *fakeOutboxClient) WriteRaw(data []byte) error {
+	if self.failing.Load() {
+		return errors.New("connection reset")
+	}
+	self.frames = append(self.frames, string(data))
+	return nil
+}
+
+func (self *fakeOutboxClient) sent() []string {
+	frames := self.frames
+	self.frames = nil
+	return frames
+}
+
+func newOutboxTest(t *testing.T, size string) (*eventOutbox, *fakeOutboxClient) {
+	t.Helper()
+	return newOutboxTestWithValkey(t, miniredis.RunT(t), size, "dev")
+}
+
+func newOutboxTestWithValkey(t *testing.T, mr *miniredis.Miniredis, size string, clusterName string) (*eventOutbox, *fakeOutboxClient) {
+	t.Helper()
+
+	configModule := config.NewConfig()
+	for key, value := range map[string]string{
+		"MO_VALKEY_ADDR":                 mr.Addr(),
+		"MO_VALKEY_USERNAME":             "",
+		"MO_VALKEY_PASSWORD":             "",
+		"MO_STATS_RETENTION_MAX_ENTRIES": "",
+		"MO_STATS_RETENTION_HOURS":       "",
+		"MO_EVENTS_OUTBOX_SIZE":          size,
+		"MO_CLUSTER_NAME":                clusterName,
+		"MO_OWN_NAMESPACE":               "mogenius",
+	} {
+		configModule.Declare(config.ConfigDeclaration{Key: key, DefaultValue: &value})
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	valkey := valkeyclient.NewValkeyClient(logger, configModule)
+	require.NoError(t, valkey.Connect())
+	t.Cleanup(valkey.Close)
+
+	client := &fakeOutboxClient{}
+	outbox := NewEventOutbox(logger, configModule, valkey, client).(*eventOutbox)
+	outbox.running = true
+	return outbox, client
+}
+
+func outboxJobDatagram(jobId string, state string) structs.Datagram {
+	return structs.Datagram{Id: state, Pattern: "K8sNotificationDto", Payload: state}.WithCoalesceKey("job:" + jobId)
+}
+
+func outboxDatagramIds(t *testing.T, frames []string) []string {
+	ids := []string{}
+	for _, frame := range frames {
+		var datagram structs.Datagram
+		require.NoError(t, json.Unmarshal([]byte(frame), &datagram))
+		ids = append(ids, datagram.Id)
+	}
+	return ids
+}
+
+func TestEventOutbox(t *testing.T) {
+	outbox, client := newOutboxTest(t, "100")
+
+	client.connected.Store(true)
+	require.NoError(t, outbox.WriteJSON(outboxJobDatagram("1", "started")))
+	assert.Equal(t, []string{"started"}, outboxDatagramIds(t, client.sent()), "connected clients are written to directly")
+
+	client.connected.Store(false)
+	require.NoError(t, outbox.WriteJSON(outboxJobDatagram("1", "running")))
+	require.NoError(t, outbox.WriteJSON(structs.Datagram{Id: "audit"}))
+	require.NoError(t, outbox.WriteJSON(outboxJobDatagram("2", "other")))
+	require.NoError(t, outbox.WriteJSON(outboxJobDatagram("1", "succeeded")))
+	assert.Empty(t, client.sent())
+	assert.Equal(t, 3, outbox.depth, "only the latest state of job 1 is kept")
+
+	outbox.drain()
+	assert.Empty(t, client.sent(), "nothing is sent while disconnected")
+
+	client.connected.Store(true)
+	require.NoError(t, outbox.WriteJSON(structs.Datagram{Id: "after"}))
+	assert.Empty(t, client.sent(), "new events wait behind the queued ones")
+	outbox.drain()
+	assert.Equal(t, []string{"audit", "other", "succeeded", "after"}, outboxDatagramIds(t, client.sent()))
+	assert.Equal(t, 0, outbox.depth)
+
+	require.NoError(t, outbox.WriteJSON(structs.Datagram{Id: "direct"}))
+	assert.Equal(t, []string{"direct"}, outboxDatagramIds(t, client.sent()))
+}
+
+func TestEventOutboxOverflow(t *testing.T) {
+	outbox, client := newOutboxTest(t, "2")
+
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, outbox.WriteJSON(structs.Datagram{Id: id}))
+	}
+	assert.Equal(t, 2, outbox.depth)
+
+	client.connected.Store(true)
+	outbox.drain()
+	assert.Equal(t, []string{"b", "c"}, outboxDatagramIds(t, client.sent()), "the oldest events are dropped")
+}
+
+func TestEventOutboxFailedWrite(t *testing.T) {
+	outbox, client := newOutboxTest(t, "100")
+
+	client.connected.Store(true)
+	client.failing.Store(true)
+	require.NoError(t, outbox.WriteJSON(structs.Datagram{Id: "lost"}))
+	assert.Equal(t, 1, outbox.depth, "a failed direct write is queued")
+
+	client.failing.Store(false)
+	require.NoError(t, outbox.WriteJSON(structs.Datagram{Id: "next"}))
+	outbox.drain()
+	assert.Equal(t, []string{"lost", "next"}, outboxDatagramIds(t, client.sent()))
+}
+
+func TestEventOutboxSharedValkey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	dev, devClient := newOutboxTestWithValkey(t, mr, "100", "dev")
+	prod, prodClient := newOutboxTestWithValkey(t, mr, "100", "prod")
+
+	require.NoError(t, dev.WriteJSON(structs.Datagram{Id: "dev"}))
+	require.NoError(t, prod.WriteJSON(structs.Datagram{Id: "prod"}))
+
+	devClient.connected.Store(true)
+	prodClient.connected.Store(true)
+	dev.drain()
+	prod.drain()
+	assert.Equal(t, []string{"dev"}, outboxDatagramIds(t, devClient.sent()))
+	assert.Equal(t, []string{"prod"}, outboxDatagramIds(t, prodClient.sent()), "operators sharing a Valkey keep their own outbox")
+}
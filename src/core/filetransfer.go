@@ -0,0 +1,679 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/dtos"
+	"mogenius-operator/src/services"
+	"mogenius-operator/src/shutdown"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/utils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// fileTransferMaxChunkSize bounds the data of a single chunk datagram;
+	// base64 makes the datagram a third larger.
+	fileTransferMaxChunkSize = 4 * 1024 * 1024
+	// fileTransferIdleTimeout is how long an upload or download is kept
+	// without any request for it, e.g. while the client reconnects.
+	fileTransferIdleTimeout     = time.Hour
+	fileTransferCleanupInterval = time.Minute
+)
+
+var (
+	fileUploadResource   = schema.GroupResource{Resource: "uploads"}
+	fileDownloadResource = schema.GroupResource{Resource: "downloads"}
+)
+
+// FileTransferService moves files between clients and PVCs in chunks over
+// the pattern API, so several transfers can run at once on one connection
+// and an interrupted transfer continues where it stopped.
+//
+// An upload is started with its size, then sent chunk by chunk, each at the
+// offset the previous answer reported and with its SHA-256. Chunks of one
+// upload are sent one after another; a chunk that was already received is
+// acknowledged again, so a chunk whose answer was lost can simply be resent.
+// After a reconnect, the status of the upload reports the offset to continue
+// from. Finishing the upload checks the SHA-256 of the whole
+// archive and extracts it into the volume.
+//
+// A download is read chunk by chunk, in any order. Chunks of a file are read
+// straight from the NFS pod; a directory is archived into a temporary file of
+// the operator first.
+//
+// Uploads and directory downloads are kept in temporary files of this
+// process; they are lost when the operator restarts. Transfers are removed
+// after fileTransferIdleTimeout without requests. The number of open
+// transfers per user and in total, and the bytes staged on disk, are limited
+// by MO_FILE_TRANSFER_MAX_OPEN_PER_USER, MO_FILE_TRANSFER_MAX_OPEN and
+// MO_FILE_TRANSFER_MAX_DISK_SIZE.
+type FileTransferService interface {
+	Run()
+	StartUpload(user structs.User, request FileUploadStartRequest) (*FileUploadStatus, error)
+	UploadStatus(user structs.User, uploadId string) (*FileUploadStatus, error)
+	UploadChunk(user structs.User, request FileUploadChunkRequest) (*FileUploadStatus, error)
+	FinishUpload(user structs.User, uploadId string) (*FileUploadStatus, error)
+	AbortUpload(user structs.User, uploadId string) error
+	StartDownload(ctx context.Context, user structs.User, request FileDownloadStartRequest) (*FileDownloadStatus, error)
+	DownloadChunk(ctx context.Context, user structs.User, request FileDownloadChunkRequest) (*FileDownloadChunk, error)
+	FinishDownload(user structs.User, downloadId string) error
+}
+
+type FileUploadStartRequest struct {
+	File dtos.PersistentFileRequestDto `json:"file"`
+	// SizeInBytes is the size of the zip archive.
+	SizeInBytes int64 `json:"sizeInBytes"`
+	// Sha256 is the hex encoded SHA-256 of the zip archive, checked when the
+	// upload is finished. Optional.
+	Sha256 string `json:"sha256,omitempty"`
+}
+
+type FileUploadChunkRequest struct {
+	UploadId string `json:"uploadId" validate:"required"`
+	Offset   int64  `json:"offset"`
+	// Data is base64 encoded.
+	Data []byte `json:"data"`
+	// Sha256 is the hex encoded SHA-256 of Data.
+	Sha256 string `json:"sha256" validate:"required"`
+}
+
+type FileUploadStatus struct {
+	UploadId string `json:"uploadId"`
+	// Offset is the number of bytes received; the next chunk starts here.
+	Offset       int64 `json:"offset"`
+	SizeInBytes  int64 `json:"sizeInBytes"`
+	MaxChunkSize int   `json:"maxChunkSize"`
+	Done         bool  `json:"done"`
+}
+
+type FileDownloadStartRequest struct {
+	File dtos.PersistentFileRequestDto `json:"file"`
+}
+
+type FileDownloadStatus struct {
+	DownloadId string `json:"downloadId"`
+	// FileName is the name of the file, or of the tar.gz archive of a
+	// directory.
+	FileName     string `json:"fileName"`
+	SizeInBytes  int64  `json:"sizeInBytes"`
+	Sha256       string `json:"sha256"`
+	MaxChunkSize int    `json:"maxChunkSize"`
+}
+
+type FileDownloadChunkRequest struct {
+	DownloadId string `json:"downloadId" validate:"required"`
+	Offset     int64  `json:"offset"`
+	// Length defaults to, and is capped at, the maxChunkSize.
+	Length int `json:"length,omitempty"`
+}
+
+type FileDownloadChunk struct {
+	DownloadId string `json:"downloadId"`
+	Offset     int64  `json:"offset"`
+	// Data is base64 encoded.
+	Data []byte `json:"data"`
+	// Sha256 is the hex encoded SHA-256 of Data.
+	Sha256 string `json:"sha256"`
+	// Eof is set on the chunk that reaches the end of the download.
+	Eof bool `json:"eof"`
+}
+
+type fileUpload struct {
+	// lock serializes the requests of an upload; held while it is finished
+	lock         sync.Mutex
+	id           string
+	user         string
+	request      services.FilesUploadRequest
+	sha256       string
+	file         *os.File
+	hash         hash.Hash
+	received     int64
+	done         bool
+	lastActivity time.Time
+}
+
+type fileDownload struct {
+	id   string
+	user string
+	// source is the file read in ranges from the NFS pod, unless file holds
+	// the archive of a directory
+	source       dtos.PersistentFileRequestDto
+	file         *os.File
+	size         int64
+	lastActivity time.Time
+}
+
+type fileTransferService struct {
+	logger         *slog.Logger
+	maxSize        int64
+	maxOpenPerUser int
+	maxOpen        int
+	maxDiskSize    int64
+	dir            string
+
+	// uploaded, downloadTo, info, sha256 and readRange are the functions of
+	// the same name in services
+	uploaded   func(tempZipFileSrc string, request services.FilesUploadRequest) error
+	downloadTo func(ctx context.Context, file dtos.PersistentFileRequestDto, open func(filename string) (io.Writer, error)) (string, error)
+	info       func(file dtos.PersistentFileRequestDto) (dtos.PersistentFileDto, error)
+	sha256     func(ctx context.Context, file dtos.PersistentFileRequestDto) (string, error)
+	readRange  func(ctx context.Context, file dtos.PersistentFileRequestDto, offset int64, length int) ([]byte, error)
+
+	lock      sync.Mutex
+	uploads   map[string]*fileUpload
+	downloads map[string]*fileDownload
+	// staged is the number of bytes reserved in dir by uploads and directory
+	// downloads
+	staged int64
+}
+
+func NewFileTransferService(logger *slog.Logger, configModule cfg.ConfigModule) FileTransferService {
+	maxSize, err := strconv.ParseInt(configModule.Get("MO_FILE_TRANSFER_MAX_SIZE"), 10, 64)
+	assert.Assert(err == nil, "MO_FILE_TRANSFER_MAX_SIZE must be a valid integer", err)
+	maxOpenPerUser, err := strconv.Atoi(configModule.Get("MO_FILE_TRANSFER_MAX_OPEN_PER_USER"))
+	assert.Assert(err == nil, "MO_FILE_TRANSFER_MAX_OPEN_PER_USER must be a valid integer", err)
+	maxOpen, err := strconv.Atoi(configModule.Get("MO_FILE_TRANSFER_MAX_OPEN"))
+	assert.Assert(err == nil, "MO_FILE_TRANSFER_MAX_OPEN must be a valid integer", err)
+	maxDiskSize, err := strconv.ParseInt(configModule.Get("MO_FILE_TRANSFER_MAX_DISK_SIZE"), 10, 64)
+	assert.Assert(err == nil, "MO_FILE_TRANSFER_MAX_DISK_SIZE must be a valid integer", err)
+
+	self := &fileTransferService{}
+	self.logger = logger
+	self.maxSize = maxSize
+	self.maxOpenPerUser = maxOpenPerUser
+	self.maxOpen = maxOpen
+	self.maxDiskSize = maxDiskSize
+	self.dir = filepath.Join(os.TempDir(), "mogenius-file-transfers")
+	self.uploaded = services.Uploaded
+	self.downloadTo = services.DownloadTo
+	self.info = services.Info
+	self.sha256 = services.Sha256
+	self.readRange = services.ReadRange
+	self.uploads = map[string]*fileUpload{}
+	self.downloads = map[string]*fileDownload{}
+
+	return self
+}
+
+func (self *fileTransferService) Run() {
+	// transfers of an earlier process cannot be resumed
+	if err := os.RemoveAll(self.dir); err != nil {
+		self.logger.Error("failed to remove file transfers of an earlier process", "dir", self.dir, "error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown.Add(cancel)
+
+	go func() {
+		for sleepCtx(ctx, fileTransferCleanupInterval) {
+			self.removeIdle(time.Now())
+		}
+	}()
+}
+
+func (self *fileTransferService) StartUpload(user structs.User, request FileUploadStartRequest) (*FileUploadStatus, error) {
+	if request.SizeInBytes <= 0 {
+		return nil, fmt.Errorf("sizeInBytes must be greater than 0")
+	}
+	if request.SizeInBytes > self.maxSize {
+		return nil, fmt.Errorf("sizeInBytes must not exceed %d", self.maxSize)
+	}
+	if err := services.ValidatePath(request.File); err != nil {
+		return nil, err
+	}
+	if err := self.checkOpen(user); err != nil {
+		return nil, err
+	}
+
+	file, err := self.createTempFile("upload-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	upload := &fileUpload{
+		id:   utils.NanoId(),
+		user: user.Email,
+		request: services.FilesUploadRequest{
+			File:        request.File,
+			SizeInBytes: request.SizeInBytes,
+		},
+		sha256:       strings.ToLower(request.Sha256),
+		file:         file,
+		hash:         sha256.New(),
+		lastActivity: time.Now(),
+	}
+	upload.request.Id = upload.id
+
+	self.lock.Lock()
+	err = self.checkOpenLocked(user)
+	if err == nil {
+		err = self.reserveLocked(request.SizeInBytes)
+	}
+	if err != nil {
+		self.lock.Unlock()
+		removeTempFile(file)
+		return nil, err
+	}
+	self.uploads[upload.id] = upload
+	self.lock.Unlock()
+
+	self.logger.Info("upload started", "uploadId", upload.id, "user", user.Email, "volume", request.File.VolumeName, "path", request.File.Path, "size", request.SizeInBytes)
+	return upload.status(), nil
+}
+
+func (self *fileTransferService) UploadStatus(user structs.User, uploadId string) (*FileUploadStatus, error) {
+	upload, err := self.getUpload(user, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	upload.lock.Lock()
+	defer upload.lock.Unlock()
+	upload.lastActivity = time.Now()
+	return upload.status(), nil
+}
+
+func (self *fileTransferService) UploadChunk(user structs.User, request FileUploadChunkRequest) (*FileUploadStatus, error) {
+	if len(request.Data) > fileTransferMaxChunkSize {
+		return nil, fmt.Errorf("chunks must not exceed %d bytes", fileTransferMaxChunkSize)
+	}
+	if sha256Hex(request.Data) != strings.ToLower(request.Sha256) {
+		return nil, fmt.Errorf("the sha256 of the chunk at offset %d does not match its data", request.Offset)
+	}
+
+	upload, err := self.getUpload(user, request.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	upload.lock.Lock()
+	defer upload.lock.Unlock()
+	upload.lastActivity = time.Now()
+
+	if upload.done {
+		return nil, apierrors.NewConflict(fileUploadResource, upload.id, fmt.Errorf("the upload is already finished"))
+	}
+	end := request.Offset + int64(len(request.Data))
+	if request.Offset < upload.received && end <= upload.received {
+		// resent after its answer was lost
+		return upload.status(), nil
+	}
+	if request.Offset != upload.received {
+		return nil, apierrors.NewConflict(fileUploadResource, upload.id, fmt.Errorf("expected the chunk at offset %d", upload.received))
+	}
+	if end > upload.request.SizeInBytes {
+		return nil, fmt.Errorf("the chunk exceeds the sizeInBytes of the upload (%d)", upload.request.SizeInBytes)
+	}
+
+	if _, err := upload.file.Write(request.Data); err != nil {
+		// the partial write is overwritten by the resent chunk
+		if _, seekErr := upload.file.Seek(upload.received, io.SeekStart); seekErr != nil {
+			self.logger.Error("failed to rewind upload", "uploadId", upload.id, "error", seekErr)
+		}
+		return nil, fmt.Errorf("failed to store chunk: %w", err)
+	}
+	upload.hash.Write(request.Data)
+	upload.received = end
+
+	return upload.status(), nil
+}
+
+func (self *fileTransferService) FinishUpload(user structs.User, uploadId string) (*FileUploadStatus, error) {
+	upload, err := self.getUpload(user, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	upload.lock.Lock()
+	defer upload.lock.Unlock()
+	upload.lastActivity = time.Now()
+
+	if upload.received != upload.request.SizeInBytes {
+		return nil, fmt.Errorf("received %d of %d bytes", upload.received, upload.request.SizeInBytes)
+	}
+	if sum := hex.EncodeToString(upload.hash.Sum(nil)); upload.sha256 != "" && sum != upload.sha256 {
+		self.removeUpload(upload)
+		return nil, fmt.Errorf("the sha256 of the upload is %s, expected %s; start the upload again", sum, upload.sha256)
+	}
+
+	if err := upload.file.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+	upload.done = true
+	err = self.uploaded(upload.file.Name(), upload.request)
+	self.removeUpload(upload)
+	if err != nil {
+		self.logger.Error("failed to extract upload", "uploadId", upload.id, "error", err)
+		return nil, err
+	}
+
+	self.logger.Info("upload finished", "uploadId", upload.id, "user", user.Email, "volume", upload.request.File.VolumeName, "path", upload.request.File.Path)
+	return upload.status(), nil
+}
+
+func (self *fileTransferService) AbortUpload(user structs.User, uploadId string) error {
+	upload, err := self.getUpload(user, uploadId)
+	if err != nil {
+		return err
+	}
+	upload.lock.Lock()
+	defer upload.lock.Unlock()
+	self.removeUpload(upload)
+	return nil
+}
+
+func (self *fileTransferService) StartDownload(ctx context.Context, user structs.User, request FileDownloadStartRequest) (*FileDownloadStatus, error) {
+	if err := self.checkOpen(user); err != nil {
+		return nil, err
+	}
+	info, err := self.info(request.File)
+	if err != nil {
+		return nil, err
+	}
+
+	download := &fileDownload{
+		id:           utils.NanoId(),
+		user:         user.Email,
+		source:       request.File,
+		lastActivity: time.Now(),
+	}
+	status := &FileDownloadStatus{
+		DownloadId:   download.id,
+		FileName:     info.Name,
+		MaxChunkSize: fileTransferMaxChunkSize,
+	}
+	if info.Type == "directory" {
+		status.FileName, status.Sha256, err = self.stageDownload(ctx, download)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if info.SizeInBytes > self.maxSize {
+			return nil, fmt.Errorf("the download exceeds MO_FILE_TRANSFER_MAX_SIZE")
+		}
+		download.size = info.SizeInBytes
+		status.Sha256, err = self.sha256(ctx, request.File)
+		if err != nil {
+			return nil, err
+		}
+	}
+	status.SizeInBytes = download.size
+
+	self.lock.Lock()
+	err = self.checkOpenLocked(user)
+	if err == nil {
+		self.downloads[download.id] = download
+	}
+	self.lock.Unlock()
+	if err != nil {
+		self.removeDownload(download)
+		return nil, err
+	}
+
+	self.logger.Info("download started", "downloadId", download.id, "user", user.Email, "volume", request.File.VolumeName, "path", request.File.Path, "size", download.size)
+	return status, nil
+}
+
+// stageDownload archives a directory into a temporary file, which cannot be
+// read in ranges from the NFS pod because every archive comes out different.
+func (self *fileTransferService) stageDownload(ctx context.Context, download *fileDownload) (string, string, error) {
+	file, err := self.createTempFile("download-*")
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.New()
+	limited := &fileTransferLimitWriter{writer: io.MultiWriter(file, sum), service: self}
+	filename, err := self.downloadTo(ctx, download.source, func(string) (io.Writer, error) {
+		return limited, nil
+	})
+	if err != nil {
+		self.release(limited.reserved)
+		removeTempFile(file)
+		return "", "", err
+	}
+	download.file = file
+	download.size = limited.written
+	return filename, hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func (self *fileTransferService) DownloadChunk(ctx context.Context, user structs.User, request FileDownloadChunkRequest) (*FileDownloadChunk, error) {
+	self.lock.Lock()
+	download, ok := self.downloads[request.DownloadId]
+	if ok && strings.EqualFold(download.user, user.Email) {
+		download.lastActivity = time.Now()
+	}
+	self.lock.Unlock()
+	if !ok || !strings.EqualFold(download.user, user.Email) {
+		return nil, apierrors.NewNotFound(fileDownloadResource, request.DownloadId)
+	}
+
+	if request.Offset < 0 || request.Offset > download.size {
+		return nil, fmt.Errorf("offset must be between 0 and %d", download.size)
+	}
+	length := request.Length
+	if length <= 0 || length > fileTransferMaxChunkSize {
+		length = fileTransferMaxChunkSize
+	}
+	length = int(min(int64(length), download.size-request.Offset))
+
+	var data []byte
+	if download.file != nil {
+		data = make([]byte, length)
+		// ReadAt does not move the file offset, so chunks are read in parallel
+		if _, err := download.file.ReadAt(data, request.Offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read download: %w", err)
+		}
+	} else {
+		var err error
+		data, err = self.readRange(ctx, download.source, request.Offset, length)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read download: %w", err)
+		}
+		if len(data) != length {
+			return nil, apierrors.NewConflict(fileDownloadResource, download.id, fmt.Errorf("the file changed since the download started; start it again"))
+		}
+	}
+	return &FileDownloadChunk{
+		DownloadId: download.id,
+		Offset:     request.Offset,
+		Data:       data,
+		Sha256:     sha256Hex(data),
+		Eof:        request.Offset+int64(length) == download.size,
+	}, nil
+}
+
+func (self *fileTransferService) FinishDownload(user structs.User, downloadId string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	download, ok := self.downloads[downloadId]
+	if !ok || !strings.EqualFold(download.user, user.Email) {
+		return apierrors.NewNotFound(fileDownloadResource, downloadId)
+	}
+	delete(self.downloads, downloadId)
+	self.removeDownloadFileLocked(download)
+	return nil
+}
+
+// getUpload returns the upload of the user with the id. Uploads of other
+// users are reported as missing.
+func (self *fileTransferService) getUpload(user structs.User, uploadId string) (*fileUpload, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	upload, ok := self.uploads[uploadId]
+	if !ok || !strings.EqualFold(upload.user, user.Email) {
+		return nil, apierrors.NewNotFound(fileUploadResource, uploadId)
+	}
+	return upload, nil
+}
+
+// removeUpload deletes an upload; the caller holds its lock.
+func (self *fileTransferService) removeUpload(upload *fileUpload) {
+	self.lock.Lock()
+	if _, ok := self.uploads[upload.id]; ok {
+		delete(self.uploads, upload.id)
+		self.staged -= upload.request.SizeInBytes
+	}
+	self.lock.Unlock()
+	removeTempFile(upload.file)
+}
+
+// removeDownload deletes the staged archive of a download that was never
+// registered.
+func (self *fileTransferService) removeDownload(download *fileDownload) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.removeDownloadFileLocked(download)
+}
+
+func (self *fileTransferService) removeDownloadFileLocked(download *fileDownload) {
+	if download.file == nil {
+		return
+	}
+	self.staged -= download.size
+	removeTempFile(download.file)
+	download.file = nil
+}
+
+// checkOpen rejects a new transfer while the user or the operator has the
+// maximum number of transfers open.
+func (self *fileTransferService) checkOpen(user structs.User) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.checkOpenLocked(user)
+}
+
+func (self *fileTransferService) checkOpenLocked(user structs.User) error {
+	if len(self.uploads)+len(self.downloads) >= self.maxOpen {
+		return apierrors.NewTooManyRequests(fmt.Sprintf("%d file transfers are open, finish or abort one first (MO_FILE_TRANSFER_MAX_OPEN)", self.maxOpen), 0)
+	}
+	open := 0
+	for _, upload := range self.uploads {
+		if strings.EqualFold(upload.user, user.Email) {
+			open++
+		}
+	}
+	for _, download := range self.downloads {
+		if strings.EqualFold(download.user, user.Email) {
+			open++
+		}
+	}
+	if open >= self.maxOpenPerUser {
+		return apierrors.NewTooManyRequests(fmt.Sprintf("you have %d file transfers open, finish or abort one first (MO_FILE_TRANSFER_MAX_OPEN_PER_USER)", open), 0)
+	}
+	return nil
+}
+
+// reserveLocked claims size bytes of MO_FILE_TRANSFER_MAX_DISK_SIZE.
+func (self *fileTransferService) reserveLocked(size int64) error {
+	if self.staged+size > self.maxDiskSize {
+		return apierrors.NewTooManyRequests("the operator has no room for more file transfers, retry later (MO_FILE_TRANSFER_MAX_DISK_SIZE)", 0)
+	}
+	self.staged += size
+	return nil
+}
+
+func (self *fileTransferService) reserve(size int64) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.reserveLocked(size)
+}
+
+func (self *fileTransferService) release(size int64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.staged -= size
+}
+
+// removeIdle deletes the transfers without requests for
+// fileTransferIdleTimeout.
+func (self *fileTransferService) removeIdle(now time.Time) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for id, upload := range self.uploads {
+		// busy uploads are not idle
+		if !upload.lock.TryLock() {
+			continue
+		}
+		if now.Sub(upload.lastActivity) > fileTransferIdleTimeout {
+			self.logger.Info("removing idle upload", "uploadId", id, "received", upload.received, "size", upload.request.SizeInBytes)
+			delete(self.uploads, id)
+			self.staged -= upload.request.SizeInBytes
+			removeTempFile(upload.file)
+		}
+		upload.lock.Unlock()
+	}
+	for id, download := range self.downloads {
+		if now.Sub(download.lastActivity) > fileTransferIdleTimeout {
+			self.logger.Info("removing idle download", "downloadId", id)
+			delete(self.downloads, id)
+			self.removeDownloadFileLocked(download)
+		}
+	}
+}
+
+func (self *fileTransferService) createTempFile(pattern string) (*os.File, error) {
+	if err := os.MkdirAll(self.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create transfer directory: %w", err)
+	}
+	file, err := os.CreateTemp(self.dir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transfer file: %w", err)
+	}
+	return file, nil
+}
+
+func (self *fileUpload) status() *FileUploadStatus {
+	return &FileUploadStatus{
+		UploadId:     self.id,
+		Offset:       self.received,
+		SizeInBytes:  self.request.SizeInBytes,
+		MaxChunkSize: fileTransferMaxChunkSize,
+		Done:         self.done,
+	}
+}
+
+// fileTransferLimitWriter reserves the room of every write on the service
+// and fails once MO_FILE_TRANSFER_MAX_SIZE or MO_FILE_TRANSFER_MAX_DISK_SIZE
+// is reached.
+type fileTransferLimitWriter struct {
+	writer   io.Writer
+	service  *fileTransferService
+	written  int64
+	reserved int64
+}
+
+func (self *fileTransferLimitWriter) Write(p []byte) (int, error) {
+	if self.written+int64(len(p)) > self.service.maxSize {
+		return 0, fmt.Errorf("the download exceeds MO_FILE_TRANSFER_MAX_SIZE")
+	}
+	if err := self.service.reserve(int64(len(p))); err != nil {
+		return 0, err
+	}
+	self.reserved += int64(len(p))
+	n, err := self.writer.Write(p)
+	self.written += int64(n)
+	return n, err
+}
+
+func removeTempFile(file *os.File) {
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"mogenius-operator/src/dtos"
+	"mogenius-operator/src/services"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/utils"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFileTransferTestService(t *testing.T, maxSize int64) *fileTransferService {
+	return &fileTransferService{
+		logger:         slog.New(slog.DiscardHandler),
+		maxSize:        maxSize,
+		maxOpenPerUser: 10,
+		maxOpen:        10,
+		maxDiskSize:    1000,
+		dir:            t.TempDir(),
+		uploads:        map[string]*fileUpload{},
+		downloads:      map[string]*fileDownload{},
+	}
+}
+
+func fileTransferTestChunk(uploadId string, offset int64, data string) FileUploadChunkRequest {
+	return FileUploadChunkRequest{UploadId: uploadId, Offset: offset, Data: []byte(data), Sha256: sha256Hex([]byte(data))}
+}
+
+func TestFileUpload(t *testing.T) {
+	service := newFileTransferTestService(t, 100)
+	var uploadedContent string
+	var uploadedRequest services.FilesUploadRequest
+	service.uploaded = func(tempZipFileSrc string, request services.FilesUploadRequest) error {
+		content, err := os.ReadFile(tempZipFileSrc)
+		require.NoError(t, err)
+		uploadedContent = string(content)
+		uploadedRequest = request
+		return nil
+	}
+	user := structs.User{Email: "dev@example.com"}
+	file := dtos.PersistentFileRequestDto{VolumeNamespace: "shop", VolumeName: "data", Path: "/uploads"}
+
+	status, err := service.StartUpload(user, FileUploadStartRequest{File: file, SizeInBytes: 10, Sha256: sha256Hex([]byte("0123456789"))})
+	require.NoError(t, err)
+	id := status.UploadId
+
+	status, err = service.UploadChunk(user, fileTransferTestChunk(id, 0, "0123"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), status.Offset)
+	status, err = service.UploadChunk(user, fileTransferTestChunk(id, 0, "0123"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), status.Offset, "a resent chunk is acknowledged again")
+
+	_, err = service.UploadChunk(user, fileTransferTestChunk(id, 8, "89"))
+	assert.Equal(t, http.StatusConflict, utils.HttpStatusForError(err), "chunks continue at the reported offset")
+	_, err = service.UploadChunk(user, FileUploadChunkRequest{UploadId: id, Offset: 4, Data: []byte("4567"), Sha256: sha256Hex([]byte("corrupt"))})
+	assert.Error(t, err)
+	_, err = service.UploadChunk(user, fileTransferTestChunk(id, 4, "456789abc"))
+	assert.Error(t, err, "chunks cannot exceed the announced size")
+	_, err = service.UploadChunk(structs.User{Email: "other@example.com"}, fileTransferTestChunk(id, 4, "4567"))
+	assert.Equal(t, http.StatusNotFound, utils.HttpStatusForError(err), "uploads belong to their user")
+
+	// resuming after a reconnect
+	status, err = service.UploadStatus(user, id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), status.Offset)
+
+	_, err = service.FinishUpload(user, id)
+	assert.Error(t, err, "incomplete uploads cannot be finished")
+	_, err = service.UploadChunk(user, fileTransferTestChunk(id, 4, "456789"))
+	require.NoError(t, err)
+	status, err = service.FinishUpload(user, id)
+	require.NoError(t, err)
+	assert.True(t, status.Done)
+	assert.Equal(t, "0123456789", uploadedContent)
+	assert.Equal(t, file, uploadedRequest.File)
+	assert.Equal(t, id, uploadedRequest.Id)
+	assert.Empty(t, service.uploads)
+	entries, err := os.ReadDir(service.dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the temporary file is removed")
+}
+
+func TestFileUploadRejected(t *testing.T) {
+	service := newFileTransferTestService(t, 10)
+	service.uploaded = func(string, services.FilesUploadRequest) error {
+		t.Fatal("a rejected upload is not extracted")
+		return nil
+	}
+	user := structs.User{Email: "dev@example.com"}
+	file := dtos.PersistentFileRequestDto{VolumeName: "data", Path: "/"}
+
+	_, err := service.StartUpload(user, FileUploadStartRequest{File: file, SizeInBytes: 11})
+	assert.Error(t, err, "uploads are limited to MO_FILE_TRANSFER_MAX_SIZE")
+	_, err = service.StartUpload(user, FileUploadStartRequest{File: dtos.PersistentFileRequestDto{VolumeName: "data", Path: "/../etc"}, SizeInBytes: 1})
+	assert.Error(t, err)
+
+	status, err := service.StartUpload(user, FileUploadStartRequest{File: file, SizeInBytes: 2, Sha256: sha256Hex([]byte("ab"))})
+	require.NoError(t, err)
+	_, err = service.UploadChunk(user, fileTransferTestChunk(status.UploadId, 0, "xy"))
+	require.NoError(t, err)
+	_, err = service.FinishUpload(user, status.UploadId)
+	assert.Error(t, err, "the sha256 of the whole upload is checked")
+	assert.Empty(t, service.uploads)
+
+	status, err = service.StartUpload(user, FileUploadStartRequest{File: file, SizeInBytes: 2})
+	require.NoError(t, err)
+	service.removeIdle(time.Now().Add(fileTransferIdleTimeout + time.Minute))
+	_, err = service.UploadChunk(user, fileTransferTestChunk(status.UploadId, 0, "ab"))
+	assert.Equal(t, http.StatusNotFound, utils.HttpStatusForError(err), "idle uploads are removed")
+}
+
+func TestFileDownload(t *testing.T) {
+	service := newFileTransferTestService(t, 11)
+	content := "hello world"
+	service.info = func(file dtos.PersistentFileRequestDto) (dtos.PersistentFileDto, error) {
+		return dtos.PersistentFileDto{Name: file.Path, Type: "file", SizeInBytes: int64(len(content))}, nil
+	}
+	service.sha256 = func(ctx context.Context, file dtos.PersistentFileRequestDto) (string, error) {
+		return sha256Hex([]byte(content)), nil
+	}
+	service.readRange = func(ctx context.Context, file dtos.PersistentFileRequestDto, offset int64, length int) ([]byte, error) {
+		return []byte(content[offset:min(int(offset)+length, len(content))]), nil
+	}
+	user := structs.User{Email: "dev@example.com"}
+
+	status, err := service.StartDownload(context.Background(), user, FileDownloadStartRequest{File: dtos.PersistentFileRequestDto{Path: "greeting.txt"}})
+	require.NoError(t, err)
+	assert.Equal(t, "greeting.txt", status.FileName)
+	assert.Equal(t, int64(11), status.SizeInBytes)
+	assert.Equal(t, sha256Hex([]byte(content)), status.Sha256)
+	assert.Equal(t, int64(0), service.staged, "files are read from the volume, not staged")
+
+	chunk, err := service.DownloadChunk(context.Background(), user, FileDownloadChunkRequest{DownloadId: status.DownloadId, Offset: 6, Length: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "world", string(chunk.Data))
+	assert.Equal(t, sha256Hex([]byte("world")), chunk.Sha256)
+	assert.True(t, chunk.Eof)
+	chunk, err = service.DownloadChunk(context.Background(), user, FileDownloadChunkRequest{DownloadId: status.DownloadId, Offset: 0, Length: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(chunk.Data), "chunks are read in any order")
+	assert.False(t, chunk.Eof)
+
+	content = "hello"
+	_, err = service.DownloadChunk(context.Background(), user, FileDownloadChunkRequest{DownloadId: status.DownloadId, Offset: 0})
+	assert.Equal(t, http.StatusConflict, utils.HttpStatusForError(err), "a file that changed is reported")
+
+	_, err = service.DownloadChunk(context.Background(), structs.User{Email: "other@example.com"}, FileDownloadChunkRequest{DownloadId: status.DownloadId})
+	assert.Equal(t, http.StatusNotFound, utils.HttpStatusForError(err))
+	require.NoError(t, service.FinishDownload(user, status.DownloadId))
+	_, err = service.DownloadChunk(context.Background(), user, FileDownloadChunkRequest{DownloadId: status.DownloadId})
+	assert.Error(t, err)
+
+	content = "hello world!"
+	_, err = service.StartDownload(context.Background(), user, FileDownloadStartRequest{File: dtos.PersistentFileRequestDto{Path: "greeting.txt"}})
+	assert.Error(t, err, "downloads are limited to MO_FILE_TRANSFER_MAX_SIZE")
+}
+
+func TestFileDownloadDirectory(t *testing.T) {
+	service := newFileTransferTestService(t, 11)
+	content := "archive"
+	service.info = func(file dtos.PersistentFileRequestDto) (dtos.PersistentFileDto, error) {
+		return dtos.PersistentFileDto{Name: file.Path, Type: "directory"}, nil
+	}
+	service.downloadTo = func(ctx context.Context, file dtos.PersistentFileRequestDto, open func(filename string) (io.Writer, error)) (string, error) {
+		w, err := open(file.Path + ".tar.gz")
+		if err != nil {
+			return "", err
+		}
+		_, err = io.WriteString(w, content)
+		return file.Path + ".tar.gz", err
+	}
+	user := structs.User{Email: "dev@example.com"}
+
+	status, err := service.StartDownload(context.Background(), user, FileDownloadStartRequest{File: dtos.PersistentFileRequestDto{Path: "site"}})
+	require.NoError(t, err)
+	assert.Equal(t, "site.tar.gz", status.FileName)
+	assert.Equal(t, sha256Hex([]byte(content)), status.Sha256)
+	assert.Equal(t, int64(7), service.staged)
+	chunk, err := service.DownloadChunk(context.Background(), user, FileDownloadChunkRequest{DownloadId: status.DownloadId, Offset: 0})
+	require.NoError(t, err)
+	assert.Equal(t, content, string(chunk.Data))
+	require.NoError(t, service.FinishDownload(user, status.DownloadId))
+	assert.Equal(t, int64(0), service.staged)
+
+	content = "a larger archive"
+	_, err = service.StartDownload(context.Background(), user, FileDownloadStartRequest{File: dtos.PersistentFileRequestDto{Path: "site"}})
+	assert.Error(t, err, "downloads are limited to MO_FILE_TRANSFER_MAX_SIZE")
+	assert.Equal(t, int64(0), service.staged)
+	entries, err := os.ReadDir(service.dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestFileTransferLimits(t *testing.T) {
+	service := newFileTransferTestService(t, 100)
+	service.maxOpenPerUser = 2
+	service.maxOpen = 3
+	service.maxDiskSize = 150
+	file := dtos.PersistentFileRequestDto{VolumeName: "data", Path: "/"}
+	jane := structs.User{Email: "jane@example.com"}
+	john := structs.User{Email: "john@example.com"}
+
+	first, err := service.StartUpload(jane, FileUploadStartRequest{File: file, SizeInBytes: 100})
+	require.NoError(t, err)
+	_, err = service.StartUpload(john, FileUploadStartRequest{File: file, SizeInBytes: 60})
+	assert.Equal(t, http.StatusTooManyRequests, utils.HttpStatusForError(err), "uploads are limited to MO_FILE_TRANSFER_MAX_DISK_SIZE")
+	_, err = service.StartUpload(jane, FileUploadStartRequest{File: file, SizeInBytes: 10})
+	require.NoError(t, err)
+	_, err = service.StartUpload(jane, FileUploadStartRequest{File: file, SizeInBytes: 10})
+	assert.Equal(t, http.StatusTooManyRequests, utils.HttpStatusForError(err), "transfers are limited per user")
+	_, err = service.StartUpload(john, FileUploadStartRequest{File: file, SizeInBytes: 10})
+	require.NoError(t, err)
+	_, err = service.StartUpload(john, FileUploadStartRequest{File: file, SizeInBytes: 10})
+	assert.Equal(t, http.StatusTooManyRequests, utils.HttpStatusForError(err), "transfers are limited in total")
+
+	require.NoError(t, service.AbortUpload(jane, first.UploadId))
+	assert.Equal(t, int64(20), service.staged, "aborted uploads free their room")
+	_, err = service.StartUpload(john, FileUploadStartRequest{File: file, SizeInBytes: 100})
+	assert.NoError(t, err)
+}
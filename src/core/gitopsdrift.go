@@ -0,0 +1,511 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/kubernetes"
+	"mogenius-operator/src/shutdown"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/utils"
+	"mogenius-operator/src/valkeyclient"
+	"mogenius-operator/src/websocket"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	DB_GITOPS_DRIFT_BUCKET_NAME    = "gitops-drift"
+	DB_GITOPS_DRIFT_ENTRIES_NAME   = "entries"
+	DB_GITOPS_DRIFT_BASELINES_NAME = "baselines"
+)
+
+// gitOpsDriftEntryTTL outlives a few missed scans (e.g. during a leader
+// change); entries of resolved drift are deleted right away.
+const gitOpsDriftEntryTTL = 24 * time.Hour
+
+const (
+	GitOpsToolArgoCD = "argocd"
+	GitOpsToolFlux   = "flux"
+)
+
+const (
+	// The desired state is the kubectl.kubernetes.io/last-applied-configuration
+	// annotation written by a client-side apply (ArgoCD's default).
+	GitOpsDriftBaselineLastApplied = "lastApplied"
+	// The desired state is the live object as it was when the source reported
+	// its current revision for the first time (server-side apply, Flux).
+	GitOpsDriftBaselineRevision = "revisionSnapshot"
+)
+
+const (
+	argoCdTrackingIdAnnotation = "argocd.argoproj.io/tracking-id"
+	argoCdInstanceLabel        = "app.kubernetes.io/instance"
+	fluxKustomizeNameLabel     = "kustomize.toolkit.fluxcd.io/name"
+	fluxKustomizeNsLabel       = "kustomize.toolkit.fluxcd.io/namespace"
+	lastAppliedAnnotation      = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+var argoCdApplicationResource = utils.ResourceDescriptor{
+	Kind:       "Application",
+	Plural:     "applications",
+	ApiVersion: "argoproj.io/v1alpha1",
+	Namespaced: true,
+}
+
+// gitOpsDriftSkippedKinds are never applied from Git, or change constantly
+// without anybody editing them.
+var gitOpsDriftSkippedKinds = []string{
+	// the watcher strips their last-applied annotation, and baselines must
+	// not copy secret values into Valkey
+	"Secret",
+	"Pod",
+	"ReplicaSet",
+	"ControllerRevision",
+	"Event",
+	"Endpoints",
+	"EndpointSlice",
+	"Lease",
+	"PodMetrics",
+	"NodeMetrics",
+}
+
+// gitOpsDriftIgnoredAnnotations are set by controllers on objects they own.
+var gitOpsDriftIgnoredAnnotations = []string{
+	lastAppliedAnnotation,
+	"deployment.kubernetes.io/revision",
+}
+
+// GitOpsDriftDetector compares resources applied by ArgoCD or Flux with their
+// desired state and records every difference as a drift entry. Entries are
+// kept in Valkey and pushed to the event server when they appear, change or
+// disappear.
+type GitOpsDriftDetector interface {
+	Run()
+	Link(apiService Api, leaderElector LeaderElector)
+	ListDrift(workspaceName string) ([]GitOpsDriftEntry, error)
+}
+
+// GitOpsDriftSource is the ArgoCD Application or Flux Kustomization a resource
+// was applied by.
+type GitOpsDriftSource struct {
+	Tool      string `json:"tool"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Revision is the Git revision the source applied last.
+	Revision string `json:"revision,omitempty"`
+}
+
+type GitOpsDriftEntry struct {
+	Uid        string            `json:"uid"`
+	ApiVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Namespace  string            `json:"namespace"`
+	Name       string            `json:"name"`
+	Source     GitOpsDriftSource `json:"source"`
+	// Baseline is "lastApplied" or "revisionSnapshot".
+	Baseline string `json:"baseline"`
+	// Diff is a unified diff from the desired to the live state.
+	Diff       string    `json:"diff"`
+	DetectedAt time.Time `json:"detectedAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+type GitOpsDriftEvent struct {
+	// Type is "detected", "changed" or "resolved".
+	Type  string           `json:"type"`
+	Entry GitOpsDriftEntry `json:"entry"`
+}
+
+// gitOpsDriftBaseline is the snapshot a resource is compared with when there
+// is no last-applied annotation.
+type gitOpsDriftBaseline struct {
+	Revision string         `json:"revision"`
+	Object   map[string]any `json:"object"`
+}
+
+type gitOpsDriftDetector struct {
+	logger        *slog.Logger
+	config        cfg.ConfigModule
+	valkey        valkeyclient.ValkeyClient
+	eventsClient  websocket.WebsocketClient
+	apiService    Api
+	leaderElector LeaderElector
+}
+
+func NewGitOpsDriftDetector(logger *slog.Logger, configModule cfg.ConfigModule, valkey valkeyclient.ValkeyClient, eventsClient websocket.WebsocketClient) GitOpsDriftDetector {
+	self := &gitOpsDriftDetector{}
+	self.logger = logger
+	self.config = configModule
+	self.valkey = valkey
+	self.eventsClient = eventsClient
+
+	return self
+}
+
+func (self *gitOpsDriftDetector) Link(apiService Api, leaderElector LeaderElector) {
+	assert.Assert(apiService != nil)
+	assert.Assert(leaderElector != nil)
+
+	self.apiService = apiService
+	self.leaderElector = leaderElector
+}
+
+func (self *gitOpsDriftDetector) Run() {
+	assert.Assert(self.apiService != nil)
+	assert.Assert(self.leaderElector != nil)
+
+	interval, err := time.ParseDuration(self.config.Get("MO_GITOPS_DRIFT_INTERVAL"))
+	assert.Assert(err == nil, err)
+	if interval <= 0 {
+		self.logger.Debug("gitops drift detection is disabled, MO_GITOPS_DRIFT_INTERVAL is 0")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown.Add(cancel)
+
+	go func() {
+		for sleepCtx(ctx, interval) {
+			if !self.leaderElector.IsLeading() {
+				continue
+			}
+			if err := self.scan(time.Now()); err != nil {
+				self.logger.Error("gitops drift scan failed", "error", err)
+			}
+		}
+	}()
+}
+
+func (self *gitOpsDriftDetector) ListDrift(workspaceName string) ([]GitOpsDriftEntry, error) {
+	entries, err := self.loadEntries()
+	if err != nil {
+		return []GitOpsDriftEntry{}, err
+	}
+	result := make([]GitOpsDriftEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry)
+	}
+
+	if workspaceName != "" {
+		namespaces, err := self.apiService.GetWorkspaceNamespaces(workspaceName)
+		if err != nil {
+			return []GitOpsDriftEntry{}, err
+		}
+		result = slices.DeleteFunc(result, func(entry GitOpsDriftEntry) bool {
+			return !slices.Contains(namespaces, entry.Namespace)
+		})
+	}
+
+	slices.SortFunc(result, func(a, b GitOpsDriftEntry) int {
+		return strings.Compare(a.Namespace+"/"+a.Kind+"/"+a.Name, b.Namespace+"/"+b.Kind+"/"+b.Name)
+	})
+	return result, nil
+}
+
+// scan checks every Git-managed resource in the store once.
+func (self *gitOpsDriftDetector) scan(now time.Time) error {
+	resources, err := kubernetes.GetAvailableResources()
+	if err != nil {
+		return err
+	}
+	sources := newGitOpsSourceIndex(
+		store.GetResourceByKindAndNamespace(self.valkey, argoCdApplicationResource.ApiVersion, argoCdApplicationResource.Kind, "", self.logger),
+		store.GetResourceByKindAndNamespace(self.valkey, utils.KustomizationResource.ApiVersion, utils.KustomizationResource.Kind, "", self.logger),
+	)
+	previous, err := self.loadEntries()
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]struct{}{}
+	drifted := map[string]struct{}{}
+	for _, resource := range resources {
+		if slices.Contains(gitOpsDriftSkippedKinds, resource.Kind) {
+			continue
+		}
+		for _, live := range store.GetResourceByKindAndNamespace(self.valkey, resource.ApiVersion, resource.Kind, "", self.logger) {
+			source, ok := sources.sourceOf(&live)
+			if !ok || live.GetUID() == "" {
+				continue
+			}
+			uid := string(live.GetUID())
+			seen[uid] = struct{}{}
+
+			entry, err := self.checkDrift(resource, &live, source)
+			if err != nil {
+				self.logger.Warn("failed to check gitops drift", "kind", resource.Kind, "namespace", live.GetNamespace(), "name", live.GetName(), "error", err)
+				continue
+			}
+			if entry == nil {
+				continue
+			}
+			drifted[uid] = struct{}{}
+
+			eventType := "detected"
+			entry.DetectedAt = now
+			if existing, ok := previous[uid]; ok {
+				entry.DetectedAt = existing.DetectedAt
+				eventType = ""
+				if existing.Diff != entry.Diff {
+					eventType = "changed"
+				}
+			}
+			entry.LastSeenAt = now
+			if err := self.valkey.SetObject(entry, gitOpsDriftEntryTTL, DB_GITOPS_DRIFT_BUCKET_NAME, DB_GITOPS_DRIFT_ENTRIES_NAME, uid); err != nil {
+				return err
+			}
+			if eventType != "" {
+				self.sendEvent(eventType, *entry)
+			}
+		}
+	}
+
+	for uid, entry := range previous {
+		if _, ok := drifted[uid]; ok {
+			continue
+		}
+		if err := self.valkey.DeleteSingle(DB_GITOPS_DRIFT_BUCKET_NAME, DB_GITOPS_DRIFT_ENTRIES_NAME, uid); err != nil {
+			return err
+		}
+		self.sendEvent("resolved", entry)
+	}
+
+	return self.pruneBaselines(seen)
+}
+
+// checkDrift returns the drift entry of live, or nil when it matches its
+// desired state or no desired state is known yet.
+func (self *gitOpsDriftDetector) checkDrift(resource utils.ResourceDescriptor, live *unstructured.Unstructured, source GitOpsDriftSource) (*GitOpsDriftEntry, error) {
+	desired, baseline, err := self.desiredState(live, source)
+	if err != nil || desired == nil {
+		return nil, err
+	}
+	diff, err := gitOpsDriftDiff(desired, live)
+	if err != nil || diff == "" {
+		return nil, err
+	}
+	return &GitOpsDriftEntry{
+		Uid:        string(live.GetUID()),
+		ApiVersion: resource.ApiVersion,
+		Kind:       resource.Kind,
+		Namespace:  live.GetNamespace(),
+		Name:       live.GetName(),
+		Source:     source,
+		Baseline:   baseline,
+		Diff:       diff,
+	}, nil
+}
+
+// desiredState prefers the last-applied annotation. Without it, the live
+// object becomes the baseline whenever its source applies a new revision, so
+// any later difference within the same revision is an out-of-band edit.
+func (self *gitOpsDriftDetector) desiredState(live *unstructured.Unstructured, source GitOpsDriftSource) (*unstructured.Unstructured, string, error) {
+	if lastApplied := live.GetAnnotations()[lastAppliedAnnotation]; lastApplied != "" {
+		desired := &unstructured.Unstructured{}
+		if err := json.Unmarshal([]byte(lastApplied), &desired.Object); err != nil {
+			return nil, "", fmt.Errorf("invalid %s annotation: %w", lastAppliedAnnotation, err)
+		}
+		return desired, GitOpsDriftBaselineLastApplied, nil
+	}
+
+	uid := string(live.GetUID())
+	baseline, err := valkeyclient.GetObjectForKey[gitOpsDriftBaseline](self.valkey, DB_GITOPS_DRIFT_BUCKET_NAME, DB_GITOPS_DRIFT_BASELINES_NAME, uid)
+	if err == nil && baseline != nil && baseline.Revision == source.Revision {
+		return &unstructured.Unstructured{Object: baseline.Object}, GitOpsDriftBaselineRevision, nil
+	}
+
+	snapshot := sanitizeGitOpsObject(live)
+	err = self.valkey.SetObject(gitOpsDriftBaseline{Revision: source.Revision, Object: snapshot.Object}, 0, DB_GITOPS_DRIFT_BUCKET_NAME, DB_GITOPS_DRIFT_BASELINES_NAME, uid)
+	return nil, "", err
+}
+
+// pruneBaselines drops the baselines of resources that no longer exist.
+func (self *gitOpsDriftDetector) pruneBaselines(seen map[string]struct{}) error {
+	prefix := DB_GITOPS_DRIFT_BUCKET_NAME + ":" + DB_GITOPS_DRIFT_BASELINES_NAME + ":"
+	keys, err := self.valkey.Keys(prefix + "*")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if _, ok := seen[strings.TrimPrefix(key, prefix)]; ok {
+			continue
+		}
+		if err := self.valkey.DeleteSingle(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *gitOpsDriftDetector) loadEntries() (map[string]GitOpsDriftEntry, error) {
+	entries, err := valkeyclient.GetObjectsByPrefix[GitOpsDriftEntry](self.valkey, valkeyclient.ORDER_NONE, DB_GITOPS_DRIFT_BUCKET_NAME, DB_GITOPS_DRIFT_ENTRIES_NAME, "*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gitops drift entries: %w", err)
+	}
+	result := make(map[string]GitOpsDriftEntry, len(entries))
+	for _, entry := range entries {
+		result[entry.Uid] = entry
+	}
+	return result, nil
+}
+
+func (self *gitOpsDriftDetector) sendEvent(eventType string, entry GitOpsDriftEntry) {
+	datagram := structs.Datagram{
+		Id:        utils.NanoId(),
+		Pattern:   "GitOpsDriftEvent",
+		Payload:   GitOpsDriftEvent{Type: eventType, Entry: entry},
+		CreatedAt: time.Now(),
+	}
+	if err := self.eventsClient.WriteJSON(datagram); err != nil {
+		self.logger.Error("failed to push gitops drift event", "error", err, "kind", entry.Kind, "namespace", entry.Namespace, "name", entry.Name)
+	}
+}
+
+// gitOpsSourceIndex resolves the ArgoCD Application or Flux Kustomization a
+// resource belongs to from its tracking annotation or labels.
+type gitOpsSourceIndex struct {
+	// applications is keyed by "<namespace>/<name>" and by "<name>", since
+	// tracking ids only carry the namespace for apps outside ArgoCD's own.
+	applications   map[string]GitOpsDriftSource
+	kustomizations map[string]GitOpsDriftSource
+}
+
+func newGitOpsSourceIndex(applications []unstructured.Unstructured, kustomizations []unstructured.Unstructured) *gitOpsSourceIndex {
+	index := &gitOpsSourceIndex{
+		applications:   map[string]GitOpsDriftSource{},
+		kustomizations: map[string]GitOpsDriftSource{},
+	}
+	for _, application := range applications {
+		revision, _, _ := unstructured.NestedString(application.Object, "status", "sync", "revision")
+		if revision == "" {
+			revisions, _, _ := unstructured.NestedStringSlice(application.Object, "status", "sync", "revisions")
+			revision = strings.Join(revisions, ",")
+		}
+		source := GitOpsDriftSource{
+			Tool:      GitOpsToolArgoCD,
+			Kind:      argoCdApplicationResource.Kind,
+			Namespace: application.GetNamespace(),
+			Name:      application.GetName(),
+			Revision:  revision,
+		}
+		index.applications[application.GetNamespace()+"/"+application.GetName()] = source
+		index.applications[application.GetName()] = source
+	}
+	for _, kustomization := range kustomizations {
+		revision, _, _ := unstructured.NestedString(kustomization.Object, "status", "lastAppliedRevision")
+		index.kustomizations[kustomization.GetNamespace()+"/"+kustomization.GetName()] = GitOpsDriftSource{
+			Tool:      GitOpsToolFlux,
+			Kind:      utils.KustomizationResource.Kind,
+			Namespace: kustomization.GetNamespace(),
+			Name:      kustomization.GetName(),
+			Revision:  revision,
+		}
+	}
+	return index
+}
+
+func (self *gitOpsSourceIndex) sourceOf(obj *unstructured.Unstructured) (GitOpsDriftSource, bool) {
+	labels := obj.GetLabels()
+	if name := labels[fluxKustomizeNameLabel]; name != "" {
+		source, ok := self.kustomizations[labels[fluxKustomizeNsLabel]+"/"+name]
+		return source, ok
+	}
+
+	// annotation tracking: "<app>:<group>/<kind>:<namespace>/<name>", where
+	// <app> is "<namespace>_<name>" for apps in any namespace
+	if trackingId := obj.GetAnnotations()[argoCdTrackingIdAnnotation]; trackingId != "" {
+		app, _, _ := strings.Cut(trackingId, ":")
+		if namespace, name, ok := strings.Cut(app, "_"); ok {
+			source, ok := self.applications[namespace+"/"+name]
+			return source, ok
+		}
+		source, ok := self.applications[app]
+		return source, ok
+	}
+
+	// Label tracking shares its label with Helm charts, so it only counts
+	// when an Application of that name exists.
+	if name := labels[argoCdInstanceLabel]; name != "" {
+		source, ok := self.applications[name]
+		return source, ok
+	}
+	return GitOpsDriftSource{}, false
+}
+
+// gitOpsDriftDiff returns a unified diff from desired to live, or "" when
+// they match. Fields desired does not set are ignored, as the API server and
+// controllers fill them in.
+func gitOpsDriftDiff(desired *unstructured.Unstructured, live *unstructured.Unstructured) (string, error) {
+	desired = sanitizeGitOpsObject(desired)
+	live = sanitizeGitOpsObject(live)
+	pruneToDesired(live.Object, desired.Object)
+
+	a, err := yaml.Marshal(desired.Object)
+	if err != nil {
+		return "", err
+	}
+	b, err := yaml.Marshal(live.Object)
+	if err != nil {
+		return "", err
+	}
+	if string(a) == string(b) {
+		return "", nil
+	}
+	return store.Diff(desired, live)
+}
+
+func sanitizeGitOpsObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	obj.SetManagedFields(nil)
+	for _, field := range []string{"status", "metadata.generation", "metadata.resourceVersion", "metadata.uid", "metadata.creationTimestamp", "metadata.selfLink", "metadata.managedFields"} {
+		unstructured.RemoveNestedField(obj.Object, strings.Split(field, ".")...)
+	}
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		for _, annotation := range gitOpsDriftIgnoredAnnotations {
+			delete(annotations, annotation)
+		}
+		obj.SetAnnotations(annotations)
+	}
+	return obj
+}
+
+// pruneToDesired drops everything from live that desired does not set at
+// all. Lists of equal length are pruned element by element, which covers
+// defaulted fields of containers, ports and the like.
+func pruneToDesired(live map[string]any, desired map[string]any) {
+	for key, value := range live {
+		desiredValue, ok := desired[key]
+		if !ok {
+			delete(live, key)
+			continue
+		}
+		pruneValueToDesired(value, desiredValue)
+	}
+}
+
+func pruneValueToDesired(live any, desired any) {
+	switch liveValue := live.(type) {
+	case map[string]any:
+		if desiredMap, ok := desired.(map[string]any); ok {
+			pruneToDesired(liveValue, desiredMap)
+		}
+	case []any:
+		desiredList, ok := desired.([]any)
+		if !ok || len(desiredList) != len(liveValue) {
+			return
+		}
+		for i := range liveValue {
+			pruneValueToDesired(liveValue[i], desiredList[i])
+		}
+	}
+}
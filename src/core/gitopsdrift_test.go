@@ -0,0 +1,83 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGitOpsSourceIndex(t *testing.T) {
+	application := unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "shop", "namespace": "argocd"},
+		"status":   map[string]any{"sync": map[string]any{"revision": "abc123"}},
+	}}
+	kustomization := unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "apps", "namespace": "flux-system"},
+		"status":   map[string]any{"lastAppliedRevision": "main@sha1:def456"},
+	}}
+	index := newGitOpsSourceIndex([]unstructured.Unstructured{application}, []unstructured.Unstructured{kustomization})
+
+	obj := func(labels map[string]any, annotations map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"metadata": map[string]any{"name": "web", "namespace": "shop", "labels": labels, "annotations": annotations},
+		}}
+	}
+
+	source, ok := index.sourceOf(obj(nil, map[string]any{argoCdTrackingIdAnnotation: "shop:apps/Deployment:shop/web"}))
+	require.True(t, ok)
+	assert.Equal(t, GitOpsDriftSource{Tool: GitOpsToolArgoCD, Kind: "Application", Namespace: "argocd", Name: "shop", Revision: "abc123"}, source)
+
+	_, ok = index.sourceOf(obj(nil, map[string]any{argoCdTrackingIdAnnotation: "argocd_shop:apps/Deployment:shop/web"}))
+	assert.True(t, ok)
+
+	source, ok = index.sourceOf(obj(map[string]any{fluxKustomizeNameLabel: "apps", fluxKustomizeNsLabel: "flux-system"}, nil))
+	require.True(t, ok)
+	assert.Equal(t, GitOpsToolFlux, source.Tool)
+	assert.Equal(t, "main@sha1:def456", source.Revision)
+
+	// a Helm release sharing the instance label is not Git-managed
+	_, ok = index.sourceOf(obj(map[string]any{argoCdInstanceLabel: "redis"}, nil))
+	assert.False(t, ok)
+	_, ok = index.sourceOf(obj(map[string]any{argoCdInstanceLabel: "shop"}, nil))
+	assert.True(t, ok)
+}
+
+func TestGitOpsDriftDiff(t *testing.T) {
+	desired := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "web", "namespace": "shop"},
+		"spec": map[string]any{
+			"replicas": int64(2),
+			"template": map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "web", "image": "nginx:1.27"},
+			}}},
+		},
+	}}
+	live := desired.DeepCopy()
+	live.SetUID("1234")
+	live.SetResourceVersion("42")
+	live.SetAnnotations(map[string]string{"deployment.kubernetes.io/revision": "3"})
+	_ = unstructured.SetNestedField(live.Object, map[string]any{"readyReplicas": int64(2)}, "status")
+	_ = unstructured.SetNestedField(live.Object, int64(10), "spec", "revisionHistoryLimit")
+	containers, _, _ := unstructured.NestedSlice(live.Object, "spec", "template", "spec", "containers")
+	containers[0].(map[string]any)["imagePullPolicy"] = "IfNotPresent"
+	_ = unstructured.SetNestedSlice(live.Object, containers, "spec", "template", "spec", "containers")
+
+	// server defaults and status are not drift
+	diff, err := gitOpsDriftDiff(desired, live)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	containers[0].(map[string]any)["image"] = "nginx:1.28"
+	_ = unstructured.SetNestedSlice(live.Object, containers, "spec", "template", "spec", "containers")
+	_ = unstructured.SetNestedField(live.Object, int64(5), "spec", "replicas")
+
+	diff, err = gitOpsDriftDiff(desired, live)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "-  replicas: 2\n+  replicas: 5\n")
+	assert.Contains(t, diff, "+      - image: nginx:1.28\n")
+	assert.NotContains(t, diff, "imagePullPolicy")
+}
@@ -0,0 +1,347 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/gitops"
+	"mogenius-operator/src/k8sclient"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/utils"
+	"mogenius-operator/src/valkeyclient"
+	"regexp"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// gitOpsWriteSecretKey is the key of the write credential when the secret
+// reference names none, the same default the ExternalSecrets use.
+const gitOpsWriteSecretKey = "token"
+
+// gitOpsWriteTimeout bounds the provider calls of a single write-back.
+const gitOpsWriteTimeout = 2 * time.Minute
+
+// GitOpsWriter sends edits of resources applied by ArgoCD or Flux to the
+// repository they come from, since the engine would revert a change made in
+// the cluster. Repositories are writable when spec.gitOps.repositories of a
+// PlatformConfig lists them with write credentials.
+type GitOpsWriter interface {
+	// WriteBack returns nil without an error when the resource is not
+	// Git-managed or its repository is not writable; the caller then updates
+	// the cluster as usual.
+	WriteBack(datagram structs.Datagram, live *unstructured.Unstructured, updated *unstructured.Unstructured) (*gitops.WriteBackResult, error)
+}
+
+// gitOpsOrigin is where a GitOps source reads its manifests from.
+type gitOpsOrigin struct {
+	url      string
+	dir      string
+	revision string
+}
+
+type gitOpsWriter struct {
+	logger         *slog.Logger
+	config         cfg.ConfigModule
+	valkey         valkeyclient.ValkeyClient
+	clientProvider k8sclient.K8sClientProvider
+}
+
+func NewGitOpsWriter(logger *slog.Logger, configModule cfg.ConfigModule, valkey valkeyclient.ValkeyClient, clientProvider k8sclient.K8sClientProvider) GitOpsWriter {
+	self := &gitOpsWriter{}
+	self.logger = logger
+	self.config = configModule
+	self.valkey = valkey
+	self.clientProvider = clientProvider
+
+	return self
+}
+
+func (self *gitOpsWriter) WriteBack(datagram structs.Datagram, live *unstructured.Unstructured, updated *unstructured.Unstructured) (*gitops.WriteBackResult, error) {
+	// Resources the cluster does not know yet cannot be in Git either, and
+	// Secret values must never be committed in plain text.
+	if live == nil || live.GetKind() == "Secret" {
+		return nil, nil
+	}
+	sources := newGitOpsSourceIndex(
+		store.GetResourceByKindAndNamespace(self.valkey, argoCdApplicationResource.ApiVersion, argoCdApplicationResource.Kind, "", self.logger),
+		store.GetResourceByKindAndNamespace(self.valkey, utils.KustomizationResource.ApiVersion, utils.KustomizationResource.Kind, "", self.logger),
+	)
+	source, ok := sources.sourceOf(live)
+	if !ok {
+		return nil, nil
+	}
+
+	origins, err := self.originsOf(source)
+	if err != nil {
+		return nil, err
+	}
+	repositories := self.repositoryConfigs()
+	for _, origin := range origins {
+		repository, ok := repositories[normalizeGitOpsRepositoryUrl(origin.url)]
+		if !ok {
+			continue
+		}
+		if repository.Write != nil && repository.Write.Enabled != nil && !*repository.Write.Enabled {
+			return nil, nil
+		}
+		ctx, cancel := context.WithTimeout(datagram.Context(), gitOpsWriteTimeout)
+		defer cancel()
+		return self.writeBack(ctx, datagram, repository, origin, live, updated)
+	}
+	return nil, nil
+}
+
+func (self *gitOpsWriter) writeBack(ctx context.Context, datagram structs.Datagram, repository v1alpha1.GitOpsRepositoryConfig, origin gitOpsOrigin, live *unstructured.Unstructured, updated *unstructured.Unstructured) (*gitops.WriteBackResult, error) {
+	write := repository.Write
+	if write == nil {
+		write = &v1alpha1.GitOpsWriteConfig{}
+	}
+	token, ok, err := self.writeToken(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	if !ok && !gitops.IsLocalRepository(repository.URL) {
+		self.logger.Debug("gitops repository has no write credentials, updating the cluster", "repository", repository.Name)
+		return nil, nil
+	}
+
+	repo, err := gitops.NewRepository(repository.URL, write.Provider, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	mode := write.Mode
+	if mode == "" {
+		mode = gitops.WriteBackModePullRequest
+	}
+	branch := origin.revision
+	if branch == "HEAD" {
+		branch = ""
+	}
+	requestedBy := datagram.User.Email
+	if requestedBy == "" {
+		requestedBy = datagram.Username
+	}
+
+	result, err := gitops.WriteBack(ctx, repo, gitops.WriteBackRequest{
+		Dir:     origin.dir,
+		Branch:  branch,
+		Mode:    mode,
+		Live:    live,
+		Updated: updated,
+		Author: gitops.CommitAuthor{
+			Name:  self.config.Get("MO_GIT_USER_NAME"),
+			Email: self.config.Get("MO_GIT_USER_EMAIL"),
+		},
+		RequestedBy: requestedBy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gitops write-back to repository %q failed: %w", repository.Name, err)
+	}
+	self.logger.Info("wrote change back to gitops repository", "repository", repository.Name, "mode", result.Mode, "file", result.File, "commit", result.CommitSha, "pullRequest", result.PullRequestUrl)
+	return result, nil
+}
+
+// originsOf returns the repositories an ArgoCD Application (one per source)
+// or a Flux Kustomization reads from. Helm chart sources carry no manifests
+// to edit and are left out. Sources pinned to a tag or commit cannot take new
+// commits, so nothing is returned for them.
+func (self *gitOpsWriter) originsOf(source GitOpsDriftSource) ([]gitOpsOrigin, error) {
+	switch source.Tool {
+	case GitOpsToolArgoCD:
+		application, err := store.GetResource(self.valkey, argoCdApplicationResource.ApiVersion, argoCdApplicationResource.Kind, source.Namespace, source.Name, self.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Application %s/%s: %w", source.Namespace, source.Name, err)
+		}
+		if application == nil {
+			return nil, nil
+		}
+		specs := []any{}
+		if single, ok, _ := unstructured.NestedMap(application.Object, "spec", "source"); ok {
+			specs = append(specs, single)
+		}
+		if multiple, ok, _ := unstructured.NestedSlice(application.Object, "spec", "sources"); ok {
+			specs = append(specs, multiple...)
+		}
+		origins := []gitOpsOrigin{}
+		for _, spec := range specs {
+			spec, ok := spec.(map[string]any)
+			if !ok || spec["chart"] != nil {
+				continue
+			}
+			url, _, _ := unstructured.NestedString(spec, "repoURL")
+			dir, _, _ := unstructured.NestedString(spec, "path")
+			revision, _, _ := unstructured.NestedString(spec, "targetRevision")
+			branch, ok := argoCdRevisionBranch(revision)
+			if !ok {
+				return nil, nil
+			}
+			origins = append(origins, gitOpsOrigin{url: url, dir: dir, revision: branch})
+		}
+		return origins, nil
+	case GitOpsToolFlux:
+		kustomization, err := store.GetResource(self.valkey, utils.KustomizationResource.ApiVersion, utils.KustomizationResource.Kind, source.Namespace, source.Name, self.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Kustomization %s/%s: %w", source.Namespace, source.Name, err)
+		}
+		if kustomization == nil {
+			return nil, nil
+		}
+		kind, _, _ := unstructured.NestedString(kustomization.Object, "spec", "sourceRef", "kind")
+		if kind != utils.GitRepositoryResource.Kind {
+			return nil, nil
+		}
+		name, _, _ := unstructured.NestedString(kustomization.Object, "spec", "sourceRef", "name")
+		namespace, _, _ := unstructured.NestedString(kustomization.Object, "spec", "sourceRef", "namespace")
+		if namespace == "" {
+			namespace = source.Namespace
+		}
+		gitRepository, err := store.GetResource(self.valkey, utils.GitRepositoryResource.ApiVersion, utils.GitRepositoryResource.Kind, namespace, name, self.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get GitRepository %s/%s: %w", namespace, name, err)
+		}
+		if gitRepository == nil {
+			return nil, nil
+		}
+		// tags and pinned commits cannot take new commits
+		branch, _, _ := unstructured.NestedString(gitRepository.Object, "spec", "ref", "branch")
+		if branch == "" {
+			if ref, _, _ := unstructured.NestedMap(gitRepository.Object, "spec", "ref"); len(ref) > 0 {
+				return nil, nil
+			}
+		}
+		url, _, _ := unstructured.NestedString(gitRepository.Object, "spec", "url")
+		dir, _, _ := unstructured.NestedString(kustomization.Object, "spec", "path")
+		return []gitOpsOrigin{{url: url, dir: dir, revision: branch}}, nil
+	}
+	return nil, nil
+}
+
+var (
+	gitCommitShaPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+	// versionPattern matches tags like "v1.2" or "1.2.3-rc.1" and semver
+	// constraints like "^1.2", "~1.2", ">=1.0 <2.0" or "1.x".
+	versionPattern = regexp.MustCompile(`^[\^~<>=!\s]*v?[0-9]+(\.([0-9]+|[xX*]))*([-+][0-9A-Za-z.-]+)?([\s,|<>=!^~].*)?$`)
+)
+
+// argoCdRevisionBranch returns the branch an ArgoCD targetRevision follows;
+// empty means the default branch. Tags, semver constraints and commit SHAs
+// are no branches. ArgoCD resolves other names against the remote, so a tag
+// without a version number cannot be told apart; committing to it fails at
+// the provider.
+func argoCdRevisionBranch(revision string) (string, bool) {
+	if revision == "" || revision == "HEAD" {
+		return "", true
+	}
+	if branch, ok := strings.CutPrefix(revision, "refs/heads/"); ok {
+		return branch, branch != ""
+	}
+	if strings.HasPrefix(revision, "refs/") || strings.ContainsAny(revision, "*^~<>= ") {
+		return "", false
+	}
+	if gitCommitShaPattern.MatchString(revision) || versionPattern.MatchString(revision) {
+		return "", false
+	}
+	return revision, true
+}
+
+// repositoryConfigs indexes the repositories of all PlatformConfigs by
+// their normalized URL.
+func (self *gitOpsWriter) repositoryConfigs() map[string]v1alpha1.GitOpsRepositoryConfig {
+	result := map[string]v1alpha1.GitOpsRepositoryConfig{}
+	for _, obj := range store.GetResourceByKindAndNamespace(self.valkey, utils.PlatformConfigResource.ApiVersion, utils.PlatformConfigResource.Kind, "", self.logger) {
+		var platformConfig v1alpha1.PlatformConfig
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &platformConfig); err != nil {
+			self.logger.Warn("failed to parse PlatformConfig", "name", obj.GetName(), "error", err)
+			continue
+		}
+		if platformConfig.Spec.GitOps == nil {
+			continue
+		}
+		for _, repository := range platformConfig.Spec.GitOps.Repositories {
+			result[normalizeGitOpsRepositoryUrl(repository.URL)] = repository
+		}
+	}
+	return result
+}
+
+// writeToken reads the write credential from the Secret in the operator's
+// namespace. ExternalSecrets are synced by the reconciler into the Secret
+// "<repository>-write".
+func (self *gitOpsWriter) writeToken(ctx context.Context, repository v1alpha1.GitOpsRepositoryConfig) (string, bool, error) {
+	if repository.Write == nil || repository.Write.SecretRef == nil {
+		return "", false, nil
+	}
+	var name, key string
+	switch ref := repository.Write.SecretRef; {
+	case ref.Secret != nil:
+		name, key = ref.Secret.Name, ref.Secret.Key
+	case ref.ExternalSecret != nil:
+		name, key = repository.Name, ref.ExternalSecret.Key
+		if name == "" {
+			name = gitops.RepositorySecretName(repository.URL)
+		}
+		name += "-write"
+	default:
+		return "", false, nil
+	}
+	if key == "" {
+		key = gitOpsWriteSecretKey
+	}
+
+	secret, err := self.clientProvider.K8sClientSet().CoreV1().Secrets(self.config.Get("MO_OWN_NAMESPACE")).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read write credentials of repository %q: %w", repository.Name, err)
+	}
+	token, ok := secret.Data[key]
+	if !ok || len(token) == 0 {
+		return "", false, fmt.Errorf("secret %q has no key %q with write credentials of repository %q", name, key, repository.Name)
+	}
+	return strings.TrimSpace(string(token)), true, nil
+}
+
+// WorkloadUpdateResult is the response of update/workload. It marshals as
+// the updated object, so existing consumers keep working, plus
+// "gitOpsWriteBack" when the change went to Git instead of the cluster. The
+// object is then the one submitted, not yet applied.
+type WorkloadUpdateResult struct {
+	Object          *unstructured.Unstructured `json:"-"`
+	GitOpsWriteBack *gitops.WriteBackResult    `json:"gitOpsWriteBack,omitempty"`
+}
+
+func (self WorkloadUpdateResult) MarshalJSON() ([]byte, error) {
+	result := map[string]any{}
+	if self.Object != nil {
+		maps.Copy(result, self.Object.Object)
+	}
+	if self.GitOpsWriteBack != nil {
+		result["gitOpsWriteBack"] = self.GitOpsWriteBack
+	}
+	return json.Marshal(result)
+}
+
+// normalizeGitOpsRepositoryUrl lets "https://host/org/repo.git" and
+// "git@host:org/repo" name the same repository.
+func normalizeGitOpsRepositoryUrl(url string) string {
+	url = strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git"))
+	if _, rest, ok := strings.Cut(url, "://"); ok {
+		url = rest
+	} else {
+		url = strings.Replace(url, ":", "/", 1)
+	}
+	host, path, _ := strings.Cut(url, "/")
+	if _, rest, ok := strings.Cut(host, "@"); ok {
+		host = rest
+	}
+	// SSH and HTTPS ports differ for the same repository
+	host, _, _ = strings.Cut(host, ":")
+	return host + "/" + path
+}
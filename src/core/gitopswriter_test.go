@@ -0,0 +1,74 @@
+package core
+
+import (
+	"encoding/json"
+	"mogenius-operator/src/gitops"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNormalizeGitOpsRepositoryUrl(t *testing.T) {
+	for _, url := range []string{
+		"https://github.com/Mogenius/shop.git",
+		"https://github.com/mogenius/shop/",
+		"git@github.com:mogenius/shop.git",
+		"ssh://git@github.com:22/mogenius/shop",
+	} {
+		assert.Equal(t, "github.com/mogenius/shop", normalizeGitOpsRepositoryUrl(url), url)
+	}
+	assert.Equal(t, "/srv/git/shop", normalizeGitOpsRepositoryUrl("file:///srv/git/shop.git"))
+}
+
+func TestArgoCdRevisionBranch(t *testing.T) {
+	for revision, branch := range map[string]string{
+		"":                     "",
+		"HEAD":                 "",
+		"main":                 "main",
+		"release/2026-10":      "release/2026-10",
+		"refs/heads/env/prod":  "env/prod",
+		"feature/v2-dashboard": "feature/v2-dashboard",
+	} {
+		got, ok := argoCdRevisionBranch(revision)
+		assert.True(t, ok, revision)
+		assert.Equal(t, branch, got, revision)
+	}
+	for _, revision := range []string{
+		"v1.2.3",
+		"1.2.3-rc.1",
+		"v2",
+		"1.x",
+		"^1.2",
+		"~1.2.0",
+		">=1.0.0 <2.0.0",
+		"*",
+		"refs/tags/v1.2.3",
+		"3f2c9a1",
+		"3f2c9a1e8b7d6c5f4a3b2c1d0e9f8a7b6c5d4e3f",
+	} {
+		_, ok := argoCdRevisionBranch(revision)
+		assert.False(t, ok, revision)
+	}
+}
+
+func TestWorkloadUpdateResultJson(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]any{"kind": "Deployment", "metadata": map[string]any{"name": "web"}}}
+
+	data, err := json.Marshal(&WorkloadUpdateResult{Object: obj})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"kind":"Deployment","metadata":{"name":"web"}}`, string(data))
+
+	data, err = json.Marshal(&WorkloadUpdateResult{Object: obj, GitOpsWriteBack: &gitops.WriteBackResult{
+		Mode:           gitops.WriteBackModePullRequest,
+		Branch:         "mogenius/deployment-web-1",
+		File:           "apps/web.yaml",
+		CommitSha:      "abc",
+		PullRequestUrl: "https://github.com/org/shop/pull/1",
+	}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"kind":"Deployment","metadata":{"name":"web"},"gitOpsWriteBack":{"mode":"PULL_REQUEST","branch":"mogenius/deployment-web-1","file":"apps/web.yaml","commitSha":"abc","pullRequestUrl":"https://github.com/org/shop/pull/1"}}`, string(data))
+	// the object itself is not modified
+	assert.NotContains(t, obj.Object, "gitOpsWriteBack")
+}
@@ -39,7 +39,7 @@ func (self *httpService) httpSocketApi(w http.ResponseWriter, r *http.Request) {
 	}
 	self.logger.Info("http request for pattern", "datagram", datagram)
 
-	result := self.socketapi.ExecuteCommandRequest(datagram)
+	result := self.socketapi.ExecuteCommandRequest(datagram.WithContext(r.Context()))
 	datagram.Payload = result
 
 	w.WriteHeader(http.StatusOK)
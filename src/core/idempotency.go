@@ -0,0 +1,205 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mogenius-operator/src/assert"
+	"mogenius-operator/src/structs"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyValkeyPrefix = "idempotency"
+	// idempotencyMaxKeyLength bounds the keys clients choose; UUIDs and
+	// similar need far less.
+	idempotencyMaxKeyLength = 255
+	// idempotencyLease is how long the reservation of a running request
+	// outlives its last renewal, so keys of requests that died with their
+	// operator pod are released after minutes instead of MO_IDEMPOTENCY_TTL.
+	idempotencyLease = 2 * time.Minute
+)
+
+// idempotencyEntry is what Valkey keeps per idempotency key.
+type idempotencyEntry struct {
+	// Request is the hash of the pattern, workspace and payload the key was
+	// first used with.
+	Request string `json:"request"`
+	// Response is the answer of the first request; empty while it runs.
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+type idempotencyErrorResponse struct {
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"statusCode"`
+}
+
+// isIdempotent reports whether a request goes through executeIdempotent:
+// it carries an idempotency key, the pattern changes something, and keys are
+// kept at all (MO_IDEMPOTENCY_TTL is not 0).
+func (self *socketApi) isIdempotent(datagram structs.Datagram, config PatternConfig) bool {
+	return datagram.IdempotencyKey != "" && config.RequiredRole.rank() > PatternRoleViewer.rank() && self.idempotencyTtl() > 0
+}
+
+func (self *socketApi) idempotencyTtl() time.Duration {
+	ttl, err := time.ParseDuration(self.config.Get("MO_IDEMPOTENCY_TTL"))
+	assert.Assert(err == nil, err)
+	return ttl
+}
+
+// executeIdempotent runs a request at most once per idempotency key and
+// user. The first request reserves the key; a repeat with the same pattern,
+// workspace and payload gets the stored response of the first instead of
+// running again, a repeat with a different request is rejected, and a
+// repeat while the first still runs is answered with 409.
+//
+// run has to return only once the handler has returned (runPatternHandler
+// waits for it), so the reservation is held as long as the handler runs. A
+// handler that finishes after its request was cancelled stores its real
+// result like any other; only the keys of requests that never ran or whose
+// handler gave up on the cancellation are released, so they can be retried.
+//
+// The reservation is held with a short lease that is renewed while the
+// handler runs; only the stored response is kept for MO_IDEMPOTENCY_TTL.
+//
+// The last return value reports whether the handler ran; the outcome is only
+// set if it did.
+func (self *socketApi) executeIdempotent(datagram structs.Datagram, run func() (any, string)) (any, string, bool) {
+	if len(datagram.IdempotencyKey) > idempotencyMaxKeyLength {
+		return idempotencyErrorResponse{"error", fmt.Sprintf("idempotencyKey must not be longer than %d characters", idempotencyMaxKeyLength), http.StatusBadRequest}, "", false
+	}
+	request, err := idempotencyRequestHash(datagram)
+	if err != nil {
+		return idempotencyErrorResponse{"error", err.Error(), http.StatusBadRequest}, "", false
+	}
+	key := idempotencyValkeyKey(datagram)
+	ttl := self.idempotencyTtl()
+	lease := min(idempotencyLease, ttl)
+
+	reservation, err := json.Marshal(idempotencyEntry{Request: request})
+	assert.Assert(err == nil, err)
+	reserved, err := self.valkeyClient.SetIfNotExists(string(reservation), lease, idempotencyValkeyPrefix, key)
+	if err != nil {
+		return idempotencyErrorResponse{"error", fmt.Sprintf("failed to check the idempotencyKey: %s", err.Error()), http.StatusServiceUnavailable}, "", false
+	}
+	if !reserved {
+		return self.idempotentReplay(datagram, key, request), "", false
+	}
+
+	stopRenewing := self.renewIdempotencyLease(datagram, key, string(reservation), lease)
+	result, outcome := run()
+	stopRenewing()
+	if _, aborted := result.(abortedPatternResult); aborted {
+		if err := self.valkeyClient.DeleteSingle(idempotencyValkeyPrefix, key); err != nil {
+			self.logger.Error("failed to release idempotency key", "pattern", datagram.Pattern, "error", err)
+		}
+		return result, outcome, true
+	}
+
+	response, err := json.Marshal(result)
+	if err == nil {
+		var entry []byte
+		entry, err = json.Marshal(idempotencyEntry{Request: request, Response: response})
+		if err == nil {
+			err = self.valkeyClient.Set(string(entry), ttl, idempotencyValkeyPrefix, key)
+		}
+	}
+	if err != nil {
+		// The reservation stays, so repeats are answered as still running
+		// rather than executed a second time.
+		self.logger.Error("failed to store idempotent response", "pattern", datagram.Pattern, "error", err)
+	}
+	return result, outcome, true
+}
+
+// renewIdempotencyLease extends the reservation of a running request every
+// half lease until the returned function is called. The function returns
+// once no renewal is in progress, so it cannot overwrite the final entry.
+func (self *socketApi) renewIdempotencyLease(datagram structs.Datagram, key string, reservation string, lease time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lease / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := self.valkeyClient.Set(reservation, lease, idempotencyValkeyPrefix, key); err != nil {
+					self.logger.Error("failed to renew idempotency key", "pattern", datagram.Pattern, "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// idempotentReplay answers a repeated idempotency key.
+func (self *socketApi) idempotentReplay(datagram structs.Datagram, key string, request string) any {
+	value, err := self.valkeyClient.Get(idempotencyValkeyPrefix, key)
+	if err != nil {
+		return idempotencyErrorResponse{"error", fmt.Sprintf("failed to check the idempotencyKey: %s", err.Error()), http.StatusServiceUnavailable}
+	}
+	var entry idempotencyEntry
+	if value == "" || json.Unmarshal([]byte(value), &entry) != nil {
+		// expired or released since the reservation failed
+		return idempotencyErrorResponse{"error", "the idempotencyKey was released, retry the request", http.StatusConflict}
+	}
+	if entry.Request != request {
+		self.logger.Warn("idempotency key reused for a different request", "pattern", datagram.Pattern, "user", datagram.User.Email)
+		return idempotencyErrorResponse{"error", "the idempotencyKey was already used for a different request", http.StatusUnprocessableEntity}
+	}
+	if len(entry.Response) == 0 {
+		return idempotencyErrorResponse{"error", "a request with this idempotencyKey is still running", http.StatusConflict}
+	}
+	self.logger.Info("replaying response of idempotent request", "pattern", datagram.Pattern, "user", datagram.User.Email)
+	return entry.Response
+}
+
+// idempotencyValkeyKey scopes the key to the user, so users cannot read each
+// other's responses by guessing keys.
+func idempotencyValkeyKey(datagram structs.Datagram) string {
+	sum := sha256.Sum256([]byte(datagram.User.Email + "\x00" + datagram.IdempotencyKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotencyRequestHash identifies a request independent of the formatting
+// and key order of its payload.
+func idempotencyRequestHash(datagram structs.Datagram) (string, error) {
+	var payload []byte
+	if raw, ok := datagram.Payload.(json.RawMessage); ok {
+		payload = raw
+	} else {
+		var err error
+		payload, err = json.Marshal(datagram.Payload)
+		if err != nil {
+			return "", err
+		}
+	}
+	var canonical any
+	if len(payload) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+		if err := decoder.Decode(&canonical); err != nil {
+			return "", fmt.Errorf("invalid payload: %w", err)
+		}
+	}
+	normalized, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(datagram.Pattern + "\x00" + datagram.Workspace + "\x00"))
+	hash.Write(normalized)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	cfg "mogenius-operator/src/config"
+	moMetrics "mogenius-operator/src/metrics"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/valkeyclient"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeIdempotencyValkey struct {
+	valkeyclient.ValkeyClient
+	lock        sync.Mutex
+	values      map[string]string
+	expirations []time.Duration
+}
+
+func (self *fakeIdempotencyValkey) SetIfNotExists(value string, expiration time.Duration, keys ...string) (bool, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	key := strings.Join(keys, ":")
+	if _, ok := self.values[key]; ok {
+		return false, nil
+	}
+	self.values[key] = value
+	self.expirations = append(self.expirations, expiration)
+	return true, nil
+}
+
+func (self *fakeIdempotencyValkey) Set(value string, expiration time.Duration, keys ...string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.values[strings.Join(keys, ":")] = value
+	self.expirations = append(self.expirations, expiration)
+	return nil
+}
+
+func (self *fakeIdempotencyValkey) Get(keys ...string) (string, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.values[strings.Join(keys, ":")], nil
+}
+
+func (self *fakeIdempotencyValkey) DeleteSingle(keys ...string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.values, strings.Join(keys, ":"))
+	return nil
+}
+
+type fakeIdempotencyConfig struct {
+	cfg.ConfigModule
+}
+
+func (self fakeIdempotencyConfig) Get(key string) string {
+	return "24h"
+}
+
+func TestExecuteIdempotent(t *testing.T) {
+	valkey := &fakeIdempotencyValkey{values: map[string]string{}}
+	self := &socketApi{logger: slog.New(slog.DiscardHandler), config: fakeIdempotencyConfig{}, valkeyClient: valkey}
+	runs := 0
+	run := func() (any, string) {
+		runs++
+		return map[string]any{"status": "success", "data": runs}, moMetrics.PatternOutcomeCompleted
+	}
+	datagram := structs.Datagram{
+		Pattern:        "create/workspace",
+		User:           structs.User{Email: "dev@example.com"},
+		IdempotencyKey: "8b1f",
+		Payload:        json.RawMessage(`{"name": "shop", "displayName": "Shop"}`),
+	}
+	require.True(t, self.isIdempotent(datagram, PatternConfig{RequiredRole: PatternRoleEditor}))
+	assert.False(t, self.isIdempotent(datagram, PatternConfig{RequiredRole: PatternRoleViewer}), "reads run every time")
+
+	result, outcome, ran := self.executeIdempotent(datagram, run)
+	require.True(t, ran)
+	assert.Equal(t, moMetrics.PatternOutcomeCompleted, outcome)
+	assert.Equal(t, map[string]any{"status": "success", "data": 1}, result)
+	assert.Equal(t, []time.Duration{idempotencyLease, 24 * time.Hour}, valkey.expirations, "only the response is kept for MO_IDEMPOTENCY_TTL")
+
+	datagram.Payload = json.RawMessage(`{"displayName":"Shop","name":"shop"}`)
+	result, _, ran = self.executeIdempotent(datagram, run)
+	assert.False(t, ran, "a resent datagram does not run again")
+	assert.JSONEq(t, `{"status":"success","data":1}`, string(result.(json.RawMessage)))
+
+	other := datagram
+	other.User = structs.User{Email: "other@example.com"}
+	_, _, ran = self.executeIdempotent(other, run)
+	assert.True(t, ran, "keys are scoped to the user")
+
+	datagram.Payload = json.RawMessage(`{"name":"blog"}`)
+	result, _, ran = self.executeIdempotent(datagram, run)
+	assert.False(t, ran)
+	assert.Equal(t, http.StatusUnprocessableEntity, result.(idempotencyErrorResponse).StatusCode, "a different payload is rejected")
+	assert.Equal(t, 2, runs)
+}
+
+func TestExecuteIdempotentRunningAndAborted(t *testing.T) {
+	valkey := &fakeIdempotencyValkey{values: map[string]string{}}
+	self := &socketApi{logger: slog.New(slog.DiscardHandler), config: fakeIdempotencyConfig{}, valkeyClient: valkey}
+	datagram := structs.Datagram{Pattern: "cluster/helm-chart-install", IdempotencyKey: "k", Payload: map[string]any{"release": "api"}}
+
+	_, _, ran := self.executeIdempotent(datagram, func() (any, string) {
+		result, _, ranAgain := self.executeIdempotent(datagram, nil)
+		assert.False(t, ranAgain)
+		assert.Equal(t, http.StatusConflict, result.(idempotencyErrorResponse).StatusCode, "the first request still runs")
+		aborted := abortedPatternResponse(context.Canceled)
+		return aborted, aborted.outcome()
+	})
+	require.True(t, ran)
+	assert.Empty(t, valkey.values, "aborted requests release their key")
+
+	_, outcome, ran := self.executeIdempotent(datagram, func() (any, string) {
+		return "installed", moMetrics.PatternOutcomeCompleted
+	})
+	assert.True(t, ran, "and can be retried")
+	assert.Equal(t, moMetrics.PatternOutcomeCompleted, outcome)
+}
+
+func TestExecuteIdempotentCancelledHandlerCompletes(t *testing.T) {
+	valkey := &fakeIdempotencyValkey{values: map[string]string{}}
+	self := &socketApi{logger: slog.New(slog.DiscardHandler), config: fakeIdempotencyConfig{}, valkeyClient: valkey}
+	ctx, cancel := context.WithCancel(context.Background())
+	datagram := structs.Datagram{Pattern: "cluster/helm-chart-install", IdempotencyKey: "k", Payload: map[string]any{"release": "api"}}.WithContext(ctx)
+
+	// the handler ignores the cancellation and installs anyway
+	handler := PatternHandler{Callback: func(structs.Datagram) any {
+		cancel()
+		result, _, ranAgain := self.executeIdempotent(datagram, nil)
+		assert.False(t, ranAgain)
+		assert.Equal(t, http.StatusConflict, result.(idempotencyErrorResponse).StatusCode, "the key stays reserved while the handler runs")
+		return map[string]any{"status": "success", "data": "installed"}
+	}}
+	_, outcome, ran := self.executeIdempotent(datagram, func() (any, string) {
+		return runPatternHandler(handler, datagram)
+	})
+	require.True(t, ran)
+	assert.Equal(t, moMetrics.PatternOutcomeCompleted, outcome)
+
+	result, _, ran := self.executeIdempotent(datagram.WithContext(context.Background()), nil)
+	assert.False(t, ran, "the install is not repeated")
+	assert.JSONEq(t, `{"status":"success","data":"installed"}`, string(result.(json.RawMessage)))
+}
+
+func TestRenewIdempotencyLease(t *testing.T) {
+	valkey := &fakeIdempotencyValkey{values: map[string]string{}}
+	self := &socketApi{logger: slog.New(slog.DiscardHandler), valkeyClient: valkey}
+
+	stop := self.renewIdempotencyLease(structs.Datagram{}, "k", `{"request":"r"}`, 20*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		valkey.lock.Lock()
+		defer valkey.lock.Unlock()
+		return len(valkey.expirations) >= 2
+	}, time.Second, 5*time.Millisecond, "the reservation is renewed while the handler runs")
+	stop()
+
+	valkey.lock.Lock()
+	renewals := len(valkey.expirations)
+	valkey.lock.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, valkey.expirations, renewals, "and no longer once it returned")
+	assert.Equal(t, `{"request":"r"}`, valkey.values["idempotency:k"])
+}
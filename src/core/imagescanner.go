@@ -0,0 +1,497 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mogenius-operator/src/ai"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/security"
+	"mogenius-operator/src/shutdown"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/valkeyclient"
+	"mogenius-operator/src/vulndb"
+	"slices"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	DB_SECURITY_IMAGES_NAME = "images"
+	// DB_SECURITY_SEEN_VULNERABILITIES_NAME holds, per workload, when each
+	// vulnerability was first detected in it.
+	DB_SECURITY_SEEN_VULNERABILITIES_NAME = "seen-vulnerabilities"
+	// DB_SECURITY_VULNERABILITY_BASELINE_NAME is set once the first scan
+	// with a database has recorded the vulnerabilities already running.
+	DB_SECURITY_VULNERABILITY_BASELINE_NAME = "vulnerability-baseline"
+)
+
+// imageInventoryTTL outlives a few missed scans; images no longer running
+// are removed by the next scan.
+const imageInventoryTTL = 24 * time.Hour
+
+// seenVulnerabilitiesTTL is how long a workload that stopped running keeps
+// its known vulnerabilities; redeployed later, they are reported again.
+const seenVulnerabilitiesTTL = 7 * 24 * time.Hour
+
+// ImageScanner keeps an inventory of the container images running in the
+// cluster and matches the packages of their SBOMs against an offline
+// vulnerability database. Vulnerabilities found in a workload for the first
+// time are passed to the agents' vulnerability triggers; the first scan with
+// a database only records what is already running.
+type ImageScanner interface {
+	Run()
+	Link(apiService Api, leaderElector LeaderElector)
+	// ListImages returns the images without their vulnerabilities.
+	ListImages(request ImagesRequest) ([]security.Image, error)
+	// ListVulnerabilities returns the images with vulnerabilities matching
+	// the request.
+	ListVulnerabilities(request ImagesRequest) ([]security.Image, error)
+}
+
+type ImagesRequest struct {
+	// WorkspaceName limits the images to the workloads in the namespaces of
+	// the workspace.
+	WorkspaceName string `json:"workspaceName,omitempty"`
+	// Namespaces limits the images to the workloads in these namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Image filters by image key, digest or reference.
+	Image string `json:"image,omitempty"`
+	// MinSeverity drops vulnerabilities below "critical", "high", "medium"
+	// or "low".
+	MinSeverity string `json:"minSeverity,omitempty"`
+	// Vulnerability filters by advisory id or alias, e.g. "CVE-2024-3094".
+	Vulnerability string `json:"vulnerability,omitempty"`
+}
+
+type imageScanner struct {
+	logger            *slog.Logger
+	config            cfg.ConfigModule
+	valkey            valkeyclient.ValkeyClient
+	ownerCacheService store.OwnerCacheService
+	aiManager         ai.AiManager
+	apiService        Api
+	leaderElector     LeaderElector
+}
+
+func NewImageScanner(logger *slog.Logger, configModule cfg.ConfigModule, valkey valkeyclient.ValkeyClient, ownerCacheService store.OwnerCacheService, aiManager ai.AiManager) ImageScanner {
+	self := &imageScanner{}
+	self.logger = logger
+	self.config = configModule
+	self.valkey = valkey
+	self.ownerCacheService = ownerCacheService
+	self.aiManager = aiManager
+
+	return self
+}
+
+func (self *imageScanner) Link(apiService Api, leaderElector LeaderElector) {
+	assert.Assert(apiService != nil)
+	assert.Assert(leaderElector != nil)
+
+	self.apiService = apiService
+	self.leaderElector = leaderElector
+}
+
+func (self *imageScanner) Run() {
+	assert.Assert(self.apiService != nil)
+	assert.Assert(self.leaderElector != nil)
+
+	interval, err := time.ParseDuration(self.config.Get("MO_IMAGE_SCAN_INTERVAL"))
+	assert.Assert(err == nil, err)
+	if interval <= 0 {
+		self.logger.Debug("image scan is disabled, MO_IMAGE_SCAN_INTERVAL is 0")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown.Add(cancel)
+
+	go func() {
+		for sleepCtx(ctx, interval) {
+			if !self.leaderElector.IsLeading() {
+				continue
+			}
+			if err := self.scan(ctx, time.Now()); err != nil {
+				self.logger.Error("image scan failed", "error", err)
+			}
+		}
+	}()
+}
+
+// scan rebuilds the inventory from the running pods and, with a database
+// configured, matches the images that have an SBOM.
+func (self *imageScanner) scan(ctx context.Context, now time.Time) error {
+	previous, err := self.loadImages()
+	if err != nil {
+		return err
+	}
+	images := runningImages(store.GetPods("*"), func(namespace, name string) (string, string) {
+		controller := self.ownerCacheService.ControllerForPod(namespace, name)
+		if controller == nil {
+			return "Pod", name
+		}
+		return controller.Kind, controller.ResourceName
+	})
+
+	sboms, err := vulndb.LoadSboms(self.config.Get("MO_IMAGE_SBOM_PATH"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	var db vulndb.Database
+	if source := self.config.Get("MO_VULN_DB_SOURCE"); source != "" {
+		db, err = vulndb.Load(ctx, source, self.config.Get("MO_VULN_DB_CACHE_PATH"))
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	baselined, err := self.valkey.Exists(DB_SECURITY_BUCKET_NAME, DB_SECURITY_VULNERABILITY_BASELINE_NAME)
+	if err != nil {
+		return err
+	}
+	seen := map[string]map[string]time.Time{}
+	seenOf := func(workload string) map[string]time.Time {
+		known, ok := seen[workload]
+		if !ok {
+			stored, err := valkeyclient.GetObjectForKey[map[string]time.Time](self.valkey, DB_SECURITY_BUCKET_NAME, DB_SECURITY_SEEN_VULNERABILITIES_NAME, workload)
+			if err == nil && stored != nil {
+				known = *stored
+			} else {
+				known = map[string]time.Time{}
+			}
+			seen[workload] = known
+		}
+		return known
+	}
+
+	matcher := newImageMatcher(db)
+	news := []security.Image{}
+	for _, image := range images {
+		image.FirstSeenAt = now
+		image.ScannedAt = now
+		old, seen := previous[image.Key]
+		if seen {
+			image.FirstSeenAt = old.FirstSeenAt
+		}
+		if sbom := sbomOfImage(sboms, image); sbom != nil {
+			image.Sbom = sbom.File
+			image.Packages = len(sbom.Packages)
+			if db != nil {
+				image.Vulnerabilities, err = matcher.match(sbom.Packages, now)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		found, ok := newVulnerabilities(image, seenOf)
+		image.Summarize()
+		if ok {
+			found.Summary, found.Severity = image.Summary, image.Severity
+			news = append(news, found)
+		}
+		if err := self.valkey.SetObject(*image, imageInventoryTTL, DB_SECURITY_BUCKET_NAME, DB_SECURITY_IMAGES_NAME, image.Key); err != nil {
+			return err
+		}
+	}
+
+	for key := range previous {
+		if _, ok := images[key]; ok {
+			continue
+		}
+		if err := self.valkey.DeleteSingle(DB_SECURITY_BUCKET_NAME, DB_SECURITY_IMAGES_NAME, key); err != nil {
+			return err
+		}
+	}
+	for workload, known := range seen {
+		if len(known) == 0 {
+			continue
+		}
+		if err := self.valkey.SetObject(known, seenVulnerabilitiesTTL, DB_SECURITY_BUCKET_NAME, DB_SECURITY_SEEN_VULNERABILITIES_NAME, workload); err != nil {
+			return err
+		}
+	}
+	if db != nil && !baselined {
+		// everything running before the database was configured would count
+		// as new and flood the agents
+		if err := self.valkey.Set(now.UTC().Format(time.RFC3339), 0, DB_SECURITY_BUCKET_NAME, DB_SECURITY_VULNERABILITY_BASELINE_NAME); err != nil {
+			return err
+		}
+		self.logger.Info("recorded the vulnerability baseline", "images", len(images), "imagesWithVulnerabilities", len(news))
+		news = nil
+	}
+	if len(news) > 0 {
+		self.aiManager.ProcessImageVulnerabilities(news)
+	}
+	self.logger.Debug("image scan finished", "images", len(images), "imagesWithNewVulnerabilities", len(news), "duration", time.Since(now))
+	return nil
+}
+
+func (self *imageScanner) loadImages() (map[string]security.Image, error) {
+	entries, err := valkeyclient.GetObjectsByPrefix[security.Image](self.valkey, valkeyclient.ORDER_NONE, DB_SECURITY_BUCKET_NAME, DB_SECURITY_IMAGES_NAME, "*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image inventory: %w", err)
+	}
+	result := make(map[string]security.Image, len(entries))
+	for _, entry := range entries {
+		result[entry.Key] = entry
+	}
+	return result, nil
+}
+
+func (self *imageScanner) ListImages(request ImagesRequest) ([]security.Image, error) {
+	images, err := self.scopedImages(request)
+	if err != nil {
+		return []security.Image{}, err
+	}
+	for i := range images {
+		images[i].Vulnerabilities = nil
+	}
+	return images, nil
+}
+
+func (self *imageScanner) ListVulnerabilities(request ImagesRequest) ([]security.Image, error) {
+	images, err := self.scopedImages(request)
+	if err != nil {
+		return []security.Image{}, err
+	}
+	return filterImageVulnerabilities(images, request), nil
+}
+
+func (self *imageScanner) scopedImages(request ImagesRequest) ([]security.Image, error) {
+	entries, err := self.loadImages()
+	if err != nil {
+		return nil, err
+	}
+	var namespaces []string
+	if request.WorkspaceName != "" {
+		namespaces, err = self.apiService.GetWorkspaceNamespaces(request.WorkspaceName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filterImages(entries, request.WorkspaceName != "", namespaces, request), nil
+}
+
+// runningImages collects the images of the started containers of all pods
+// that did not terminate, keyed by digest. controllerOf names the workload
+// owning a pod.
+func runningImages(pods []v1.Pod, controllerOf func(namespace, name string) (string, string)) map[string]*security.Image {
+	images := map[string]*security.Image{}
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		statuses := slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses, pod.Status.EphemeralContainerStatuses)
+		var kind, name string
+		for _, status := range statuses {
+			if status.ImageID == "" {
+				continue
+			}
+			if kind == "" {
+				kind, name = controllerOf(pod.Namespace, pod.Name)
+			}
+			digest := imageDigest(status.ImageID)
+			key := cmp.Or(digest, status.Image)
+			image, ok := images[key]
+			if !ok {
+				image = &security.Image{Key: key, Image: status.Image, Digest: digest, Workloads: []security.ImageWorkload{}}
+				images[key] = image
+			}
+			index := slices.IndexFunc(image.Workloads, func(workload security.ImageWorkload) bool {
+				return workload.Namespace == pod.Namespace && workload.Kind == kind && workload.Name == name
+			})
+			if index < 0 {
+				image.Workloads = append(image.Workloads, security.ImageWorkload{Namespace: pod.Namespace, Kind: kind, Name: name, Containers: []string{}})
+				index = len(image.Workloads) - 1
+			}
+			if !slices.Contains(image.Workloads[index].Containers, status.Name) {
+				image.Workloads[index].Containers = append(image.Workloads[index].Containers, status.Name)
+			}
+		}
+	}
+	return images
+}
+
+// imageDigest extracts the digest of a container status imageID such as
+// "docker.io/library/nginx@sha256:..." or "docker-pullable://nginx@sha256:...".
+func imageDigest(imageID string) string {
+	if _, digest, ok := strings.Cut(imageID, "@"); ok {
+		return digest
+	}
+	if strings.HasPrefix(imageID, "sha256:") {
+		return imageID
+	}
+	return ""
+}
+
+// sbomOfImage finds the SBOM of an image by its digest, falling back to the
+// image reference.
+func sbomOfImage(sboms []vulndb.Sbom, image *security.Image) *vulndb.Sbom {
+	if image.Digest != "" {
+		for i := range sboms {
+			if slices.Contains(sboms[i].Digests, image.Digest) {
+				return &sboms[i]
+			}
+		}
+	}
+	for i := range sboms {
+		if sboms[i].Image != "" && sboms[i].Image == image.Image {
+			return &sboms[i]
+		}
+	}
+	return nil
+}
+
+// imageMatcher matches packages against the database, remembering the
+// result per package since images share most of their base layers.
+type imageMatcher struct {
+	db      vulndb.Database
+	matched map[vulndb.Package][]vulndb.Vulnerability
+}
+
+func newImageMatcher(db vulndb.Database) *imageMatcher {
+	return &imageMatcher{db: db, matched: map[vulndb.Package][]vulndb.Vulnerability{}}
+}
+
+func (self *imageMatcher) match(packages []vulndb.Package, now time.Time) ([]security.ImageVulnerability, error) {
+	result := []security.ImageVulnerability{}
+	for _, pkg := range packages {
+		vulnerabilities, ok := self.matched[pkg]
+		if !ok {
+			var err error
+			vulnerabilities, err = self.db.Match(pkg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to match %s %s: %w", pkg.Name, pkg.Version, err)
+			}
+			self.matched[pkg] = vulnerabilities
+		}
+		for _, vulnerability := range vulnerabilities {
+			if slices.ContainsFunc(result, func(v security.ImageVulnerability) bool {
+				return v.Id == vulnerability.Id && v.Package == pkg.Name
+			}) {
+				continue
+			}
+			result = append(result, security.ImageVulnerability{
+				Id:           vulnerability.Id,
+				Aliases:      vulnerability.Aliases,
+				Summary:      vulnerability.Summary,
+				Severity:     vulnerability.Severity,
+				Ecosystem:    pkg.Ecosystem,
+				Package:      pkg.Name,
+				Version:      pkg.Version,
+				FixedVersion: vulnerability.FixedVersion,
+				DetectedAt:   now,
+			})
+		}
+	}
+	security.SortVulnerabilities(result)
+	return result, nil
+}
+
+// newVulnerabilities gives the vulnerabilities of image the time they were
+// first detected in one of its workloads and records them in seenOf, keyed by
+// workload and advisory id. It returns the image reduced to the workloads and
+// vulnerabilities seen there for the first time, and false when there are
+// none, so a new digest of a workload does not report its known
+// vulnerabilities again.
+func newVulnerabilities(image *security.Image, seenOf func(workload string) map[string]time.Time) (security.Image, bool) {
+	found := *image
+	found.Workloads = []security.ImageWorkload{}
+	found.Vulnerabilities = []security.ImageVulnerability{}
+	if len(image.Vulnerabilities) == 0 {
+		return found, false
+	}
+
+	for i, vulnerability := range image.Vulnerabilities {
+		for _, workload := range image.Workloads {
+			if detectedAt, ok := seenOf(imageWorkloadKey(workload))[vulnerability.Id]; ok && detectedAt.Before(image.Vulnerabilities[i].DetectedAt) {
+				image.Vulnerabilities[i].DetectedAt = detectedAt
+			}
+		}
+	}
+
+	fresh := map[string]bool{}
+	for _, workload := range image.Workloads {
+		known := seenOf(imageWorkloadKey(workload))
+		isNew := false
+		for _, vulnerability := range image.Vulnerabilities {
+			if _, ok := known[vulnerability.Id]; !ok {
+				known[vulnerability.Id] = vulnerability.DetectedAt
+				fresh[vulnerability.Id] = true
+				isNew = true
+			}
+		}
+		if isNew {
+			found.Workloads = append(found.Workloads, workload)
+		}
+	}
+	for _, vulnerability := range image.Vulnerabilities {
+		if fresh[vulnerability.Id] {
+			found.Vulnerabilities = append(found.Vulnerabilities, vulnerability)
+		}
+	}
+	return found, len(found.Vulnerabilities) > 0
+}
+
+func imageWorkloadKey(workload security.ImageWorkload) string {
+	return workload.Namespace + ":" + workload.Kind + ":" + workload.Name
+}
+
+// filterImages applies the scope and image filters; scoped to a workspace,
+// only the workloads in its namespaces are kept. Images are sorted worst
+// first.
+func filterImages(entries map[string]security.Image, scoped bool, workspaceNamespaces []string, request ImagesRequest) []security.Image {
+	result := []security.Image{}
+	for _, image := range entries {
+		if request.Image != "" && request.Image != image.Key && request.Image != image.Digest && request.Image != image.Image {
+			continue
+		}
+		image.Workloads = slices.DeleteFunc(slices.Clone(image.Workloads), func(workload security.ImageWorkload) bool {
+			return (scoped && !slices.Contains(workspaceNamespaces, workload.Namespace)) ||
+				(len(request.Namespaces) > 0 && !slices.Contains(request.Namespaces, workload.Namespace))
+		})
+		if len(image.Workloads) == 0 {
+			continue
+		}
+		result = append(result, image)
+	}
+	sortImages(result)
+	return result
+}
+
+func sortImages(images []security.Image) {
+	slices.SortFunc(images, func(a, b security.Image) int {
+		if rank := security.SeverityRank(b.Severity) - security.SeverityRank(a.Severity); rank != 0 {
+			return rank
+		}
+		return strings.Compare(a.Image, b.Image)
+	})
+}
+
+// filterImageVulnerabilities drops the vulnerabilities below the request's
+// severity or not matching its advisory and the images without any left.
+func filterImageVulnerabilities(images []security.Image, request ImagesRequest) []security.Image {
+	minRank := security.SeverityRank(request.MinSeverity)
+	result := []security.Image{}
+	for _, image := range images {
+		image.Vulnerabilities = slices.DeleteFunc(slices.Clone(image.Vulnerabilities), func(vulnerability security.ImageVulnerability) bool {
+			return security.SeverityRank(vulnerability.Severity) < minRank ||
+				(request.Vulnerability != "" && vulnerability.Id != request.Vulnerability && !slices.Contains(vulnerability.Aliases, request.Vulnerability))
+		})
+		if len(image.Vulnerabilities) == 0 {
+			continue
+		}
+		image.Summarize()
+		result = append(result, image)
+	}
+	sortImages(result)
+	return result
+}
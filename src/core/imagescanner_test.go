@@ -0,0 +1,151 @@
+package core
+
+import (
+	"mogenius-operator/src/security"
+	"mogenius-operator/src/vulndb"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testImageDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestRunningImages(t *testing.T) {
+	pod := func(namespace, name string, phase v1.PodPhase, statuses ...v1.ContainerStatus) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Status:     v1.PodStatus{Phase: phase, ContainerStatuses: statuses},
+		}
+	}
+	api := v1.ContainerStatus{Name: "api", Image: "registry.example.com/shop/api:1.2", ImageID: "registry.example.com/shop/api@" + testImageDigest}
+	pods := []v1.Pod{
+		pod("shop", "api-1", v1.PodRunning, api),
+		pod("shop", "api-2", v1.PodRunning, api),
+		pod("blog", "api-1", v1.PodRunning, api, v1.ContainerStatus{Name: "proxy", Image: "envoy:1.30", ImageID: "sha256:feed"}),
+		pod("blog", "pulling", v1.PodPending, v1.ContainerStatus{Name: "web", Image: "nginx:1.25"}),
+		pod("blog", "done", v1.PodSucceeded, v1.ContainerStatus{Name: "job", Image: "busybox", ImageID: "docker.io/library/busybox@sha256:beef"}),
+	}
+	controllerOf := func(namespace, name string) (string, string) {
+		return "Deployment", "api"
+	}
+
+	images := runningImages(pods, controllerOf)
+	require.Len(t, images, 2)
+	image := images[testImageDigest]
+	require.NotNil(t, image)
+	assert.Equal(t, "registry.example.com/shop/api:1.2", image.Image)
+	assert.Equal(t, []security.ImageWorkload{
+		{Namespace: "shop", Kind: "Deployment", Name: "api", Containers: []string{"api"}},
+		{Namespace: "blog", Kind: "Deployment", Name: "api", Containers: []string{"api"}},
+	}, image.Workloads)
+	assert.Equal(t, []string{"blog", "shop"}, image.Namespaces())
+	assert.Equal(t, "sha256:feed", images["sha256:feed"].Digest)
+}
+
+type testVulnDb map[string][]vulndb.Vulnerability
+
+func (self testVulnDb) Match(pkg vulndb.Package) ([]vulndb.Vulnerability, error) {
+	return self[pkg.Name+"@"+pkg.Version], nil
+}
+
+func (self testVulnDb) Close() error {
+	return nil
+}
+
+func TestImageMatching(t *testing.T) {
+	db := testVulnDb{
+		"openssl@3.0.11": {{Id: "CVE-2024-0001", Severity: security.SeverityCritical, FixedVersion: "3.0.13"}},
+		"zlib@1.2.13":    {{Id: "CVE-2024-0002", Severity: security.SeverityMedium}},
+	}
+	sboms := []vulndb.Sbom{
+		{File: "other.json", Image: "nginx:1.25"},
+		{File: "api.json", Digests: []string{testImageDigest}, Packages: []vulndb.Package{
+			{Ecosystem: "debian:12", Name: "zlib", Version: "1.2.13"},
+			{Ecosystem: "debian:12", Name: "openssl", Version: "3.0.11"},
+		}},
+	}
+	image := &security.Image{Key: testImageDigest, Image: "registry.example.com/shop/api:1.2", Digest: testImageDigest}
+	sbom := sbomOfImage(sboms, image)
+	require.NotNil(t, sbom)
+	assert.Equal(t, "api.json", sbom.File)
+	assert.Equal(t, "other.json", sbomOfImage(sboms, &security.Image{Image: "nginx:1.25"}).File)
+	assert.Nil(t, sbomOfImage(sboms, &security.Image{Image: "nginx:1.26"}))
+
+	first := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	previous, err := newImageMatcher(db).match(sbom.Packages, first)
+	require.NoError(t, err)
+	require.Len(t, previous, 2)
+	assert.Equal(t, "CVE-2024-0001", previous[0].Id, "worst first")
+	previous = previous[1:]
+
+	api := security.ImageWorkload{Namespace: "shop", Kind: "Deployment", Name: "api"}
+	worker := security.ImageWorkload{Namespace: "shop", Kind: "Deployment", Name: "worker"}
+	seen := map[string]map[string]time.Time{imageWorkloadKey(api): {previous[0].Id: first}}
+	seenOf := func(workload string) map[string]time.Time {
+		if seen[workload] == nil {
+			seen[workload] = map[string]time.Time{}
+		}
+		return seen[workload]
+	}
+
+	current, err := newImageMatcher(db).match(sbom.Packages, first.Add(time.Hour))
+	require.NoError(t, err)
+	image.Workloads = []security.ImageWorkload{api}
+	image.Vulnerabilities = current
+	found, ok := newVulnerabilities(image, seenOf)
+	require.True(t, ok)
+	require.Len(t, found.Vulnerabilities, 1)
+	assert.Equal(t, "CVE-2024-0001", found.Vulnerabilities[0].Id)
+	assert.Equal(t, []security.ImageWorkload{api}, found.Workloads)
+	assert.Equal(t, first, current[1].DetectedAt, "known vulnerabilities keep their detection time")
+
+	// a new digest of the same workload reports nothing again
+	rolledOut := &security.Image{Key: "sha256:other", Workloads: []security.ImageWorkload{api}}
+	rolledOut.Vulnerabilities, err = newImageMatcher(db).match(sbom.Packages, first.Add(2*time.Hour))
+	require.NoError(t, err)
+	_, ok = newVulnerabilities(rolledOut, seenOf)
+	assert.False(t, ok)
+	assert.Equal(t, first.Add(time.Hour), rolledOut.Vulnerabilities[0].DetectedAt)
+
+	// another workload running the image is new to both
+	rolledOut.Workloads = append(rolledOut.Workloads, worker)
+	found, ok = newVulnerabilities(rolledOut, seenOf)
+	require.True(t, ok)
+	assert.Equal(t, []security.ImageWorkload{worker}, found.Workloads)
+	assert.Len(t, found.Vulnerabilities, 2)
+}
+
+func TestFilterImages(t *testing.T) {
+	entries := map[string]security.Image{
+		"a": {Key: "a", Image: "api:1", Severity: security.SeverityHigh,
+			Workloads: []security.ImageWorkload{{Namespace: "shop", Name: "api"}, {Namespace: "blog", Name: "api"}},
+			Vulnerabilities: []security.ImageVulnerability{
+				{Id: "GHSA-1", Aliases: []string{"CVE-2024-0001"}, Severity: security.SeverityHigh},
+				{Id: "CVE-2024-0002", Severity: security.SeverityLow},
+			},
+		},
+		"b": {Key: "b", Image: "web:1", Workloads: []security.ImageWorkload{{Namespace: "blog", Name: "web"}}},
+	}
+
+	images := filterImages(entries, true, []string{"shop"}, ImagesRequest{})
+	require.Len(t, images, 1)
+	assert.Equal(t, []security.ImageWorkload{{Namespace: "shop", Name: "api"}}, images[0].Workloads)
+	assert.Len(t, entries["a"].Workloads, 2, "stored entries are not modified")
+	assert.Empty(t, filterImages(entries, true, nil, ImagesRequest{}))
+	assert.Equal(t, "a", filterImages(entries, false, nil, ImagesRequest{})[0].Key, "worst first")
+	assert.Len(t, filterImages(entries, false, nil, ImagesRequest{Image: "web:1"}), 1)
+
+	all := filterImages(entries, false, nil, ImagesRequest{})
+	vulnerable := filterImageVulnerabilities(all, ImagesRequest{Vulnerability: "CVE-2024-0001"})
+	require.Len(t, vulnerable, 1)
+	assert.Equal(t, "GHSA-1", vulnerable[0].Vulnerabilities[0].Id, "matched by alias")
+	assert.Equal(t, map[string]int{security.SeverityHigh: 1}, vulnerable[0].Summary)
+
+	vulnerable = filterImageVulnerabilities(all, ImagesRequest{MinSeverity: security.SeverityMedium})
+	require.Len(t, vulnerable, 1)
+	assert.Len(t, vulnerable[0].Vulnerabilities, 1)
+}
@@ -0,0 +1,263 @@
+package core
+
+import (
+	"fmt"
+	"maps"
+	"mogenius-operator/src/iomonitor"
+	"mogenius-operator/src/podstatscollector"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/valkeyclient"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	DB_STATS_IO_NAME                       = "io"
+	DB_STATS_IO_STATS_BUCKET_NAME          = "io-stats"
+	DB_STATS_EPHEMERAL_STORAGE_BUCKET_NAME = "ephemeral-storage"
+)
+
+// PodIoUsage is the block I/O of one pod since the previous sample.
+type PodIoUsage struct {
+	Pod        string `json:"pod"`
+	ReadBytes  uint64 `json:"readBytes"`
+	WriteBytes uint64 `json:"writeBytes"`
+	// ReadOps and WriteOps are only known on cgroup v2 hosts.
+	ReadOps  uint64 `json:"readOps"`
+	WriteOps uint64 `json:"writeOps"`
+}
+
+// WorkloadIoStats is one sample of the pods of a workload on one node.
+type WorkloadIoStats struct {
+	Node      string       `json:"node"`
+	Pods      []PodIoUsage `json:"pods"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+type PodEphemeralStorage struct {
+	Pod     string                       `json:"pod"`
+	Node    string                       `json:"node"`
+	Storage podstatscollector.FileSystem `json:"storage"`
+}
+
+// WorkloadEphemeralStorage is one sample of the pods of a workload across
+// all nodes, taken from the kubelet stats summary.
+type WorkloadEphemeralStorage struct {
+	Pods      []PodEphemeralStorage `json:"pods"`
+	CreatedAt time.Time             `json:"createdAt"`
+}
+
+// WorkspaceIoChartEntry extends the generic chart entry (Value is read plus
+// written bytes, Pods the top 5 by it) with the split and the ephemeral
+// storage in use.
+type WorkspaceIoChartEntry struct {
+	GenericChartEntry
+	ReadBytes             float64 `json:"readBytes"`
+	WriteBytes            float64 `json:"writeBytes"`
+	EphemeralStorageBytes float64 `json:"ephemeralStorageBytes"`
+}
+
+type ioCounters struct {
+	read, write, readOps, writeOps uint64
+}
+
+func (self *valkeyStatsDb) AddNodeIoProcessMetricsToDb(nodeName string, data any) error {
+	return self.publishAndSet(data, DB_STATS_LIVE_BUCKET_NAME, DB_STATS_IO_NAME, DB_STATS_PROCESSES_NAME, nodeName)
+}
+
+// AddPodIoStatsToDb stores the I/O of every pod since the previous call.
+// The monitor reports cumulative counters per container; containers seen for
+// the first time only provide the baseline.
+func (self *valkeyStatsDb) AddPodIoStatsToDb(nodeName string, stats []iomonitor.PodIoStats) error {
+	self.lastPodIoCountersLock.Lock()
+	lastCounters := self.lastPodIoCounters
+	currentCounters := make(map[string]ioCounters, len(stats))
+	type podKey struct{ namespace, pod string }
+	usage := map[podKey]*PodIoUsage{}
+	for _, stat := range stats {
+		current := ioCounters{}
+		current.read, current.write = stat.ReadWriteBytes()
+		if stat.Cgroup != nil {
+			current.readOps, current.writeOps = stat.Cgroup.ReadOps, stat.Cgroup.WriteOps
+		}
+		currentCounters[stat.ContainerId] = current
+
+		last, ok := lastCounters[stat.ContainerId]
+		if !ok {
+			continue
+		}
+		key := podKey{stat.Namespace, stat.Name}
+		entry, ok := usage[key]
+		if !ok {
+			entry = &PodIoUsage{Pod: stat.Name}
+			usage[key] = entry
+		}
+		// per-process counters shrink when a process exits
+		entry.ReadBytes += current.read - min(last.read, current.read)
+		entry.WriteBytes += current.write - min(last.write, current.write)
+		entry.ReadOps += current.readOps - min(last.readOps, current.readOps)
+		entry.WriteOps += current.writeOps - min(last.writeOps, current.writeOps)
+	}
+	self.lastPodIoCounters = currentCounters
+	self.lastPodIoCountersLock.Unlock()
+
+	type workloadKey struct{ namespace, name string }
+	byWorkload := map[workloadKey][]PodIoUsage{}
+	for key, entry := range usage {
+		controller := self.ownerCacheService.ControllerForPod(key.namespace, key.pod)
+		if controller == nil {
+			self.logger.Debug("No controller found for pod", "podName", key.pod, "namespace", key.namespace)
+			continue
+		}
+		workload := workloadKey{key.namespace, controller.ResourceName}
+		byWorkload[workload] = append(byWorkload[workload], *entry)
+	}
+
+	now := time.Now()
+	for workload, pods := range byWorkload {
+		slices.SortFunc(pods, func(a, b PodIoUsage) int { return strings.Compare(a.Pod, b.Pod) })
+		err := self.valkey.StoreSortedListEntry(
+			WorkloadIoStats{Node: nodeName, Pods: pods, CreatedAt: now},
+			now.Truncate(time.Minute).Unix(),
+			DB_STATS_IO_STATS_BUCKET_NAME, workload.namespace, workload.name, nodeName,
+		)
+		if err != nil {
+			return fmt.Errorf("error adding io stats for %s/%s: %w", workload.namespace, workload.name, err)
+		}
+	}
+	return nil
+}
+
+// AddPodEphemeralStorageToDb stores the ephemeral storage usage of all pods
+// from the kubelet stats summaries, grouped by workload.
+func (self *valkeyStatsDb) AddPodEphemeralStorageToDb(nodemetrics []podstatscollector.NodeMetrics) error {
+	type workloadKey struct{ namespace, name string }
+	byWorkload := map[workloadKey][]PodEphemeralStorage{}
+	for _, nodeMetric := range nodemetrics {
+		for _, pod := range nodeMetric.Pods {
+			controller := self.ownerCacheService.ControllerForPod(pod.PodRef.Namespace, pod.PodRef.Name)
+			if controller == nil {
+				continue
+			}
+			key := workloadKey{pod.PodRef.Namespace, controller.ResourceName}
+			byWorkload[key] = append(byWorkload[key], PodEphemeralStorage{
+				Pod:     pod.PodRef.Name,
+				Node:    nodeMetric.Node.NodeName,
+				Storage: pod.EphemeralStorage,
+			})
+		}
+	}
+
+	now := time.Now()
+	for workload, pods := range byWorkload {
+		err := self.valkey.StoreSortedListEntry(
+			WorkloadEphemeralStorage{Pods: pods, CreatedAt: now},
+			now.Truncate(time.Minute).Unix(),
+			DB_STATS_EPHEMERAL_STORAGE_BUCKET_NAME, workload.namespace, workload.name,
+		)
+		if err != nil {
+			return fmt.Errorf("error adding ephemeral storage stats for %s/%s: %w", workload.namespace, workload.name, err)
+		}
+	}
+	return nil
+}
+
+func (self *valkeyStatsDb) GetWorkspaceStatsIoUtilization(timeOffsetInMinutes int, resources []unstructured.Unstructured) ([]WorkspaceIoChartEntry, error) {
+	// Clamp to valid range
+	if timeOffsetInMinutes < 5 {
+		timeOffsetInMinutes = 5
+	}
+	if timeOffsetInMinutes > 60*24*7 {
+		timeOffsetInMinutes = 60 * 24 * 7 // 7 days
+	}
+
+	nodes := store.GetNodes()
+	ioSamples := []WorkloadIoStats{}
+	storageSamples := []WorkloadEphemeralStorage{}
+	var resultMutex sync.Mutex
+	wg := sync.WaitGroup{}
+	for _, controller := range resources {
+		ns, name := controller.GetNamespace(), controller.GetName()
+		for _, node := range nodes {
+			wg.Go(func() {
+				values, err := valkeyclient.GetObjectsFromSortedListWithDuration[WorkloadIoStats](
+					self.valkey,
+					int64(timeOffsetInMinutes),
+					DB_STATS_IO_STATS_BUCKET_NAME, ns, name, node.Name,
+				)
+				if err != nil {
+					self.logger.Error("failed to fetch io stats from valkey", "namespace", ns, "name", name, "node", node.Name, "error", err)
+					return
+				}
+				resultMutex.Lock()
+				ioSamples = append(ioSamples, values...)
+				resultMutex.Unlock()
+			})
+		}
+		wg.Go(func() {
+			values, err := valkeyclient.GetObjectsFromSortedListWithDuration[WorkloadEphemeralStorage](
+				self.valkey,
+				int64(timeOffsetInMinutes),
+				DB_STATS_EPHEMERAL_STORAGE_BUCKET_NAME, ns, name,
+			)
+			if err != nil {
+				self.logger.Error("failed to fetch ephemeral storage stats from valkey", "namespace", ns, "name", name, "error", err)
+				return
+			}
+			resultMutex.Lock()
+			storageSamples = append(storageSamples, values...)
+			resultMutex.Unlock()
+		})
+	}
+	wg.Wait()
+
+	return aggregateWorkspaceIo(ioSamples, storageSamples), nil
+}
+
+// aggregateWorkspaceIo sums the samples of all workloads and nodes per minute.
+func aggregateWorkspaceIo(ioSamples []WorkloadIoStats, storageSamples []WorkloadEphemeralStorage) []WorkspaceIoChartEntry {
+	result := map[time.Time]*WorkspaceIoChartEntry{}
+	entryFor := func(createdAt time.Time) *WorkspaceIoChartEntry {
+		minute := createdAt.Truncate(time.Minute)
+		entry, ok := result[minute]
+		if !ok {
+			entry = &WorkspaceIoChartEntry{GenericChartEntry: GenericChartEntry{Time: minute, Pods: map[string]float64{}}}
+			result[minute] = entry
+		}
+		return entry
+	}
+
+	for _, sample := range ioSamples {
+		if sample.CreatedAt.IsZero() {
+			continue
+		}
+		entry := entryFor(sample.CreatedAt)
+		for _, pod := range sample.Pods {
+			value := float64(pod.ReadBytes + pod.WriteBytes)
+			entry.ReadBytes += float64(pod.ReadBytes)
+			entry.WriteBytes += float64(pod.WriteBytes)
+			entry.Value += value
+			entry.Pods = updateTop5Pods(entry.Pods, value, pod.Pod)
+		}
+	}
+	for _, sample := range storageSamples {
+		if sample.CreatedAt.IsZero() {
+			continue
+		}
+		entry := entryFor(sample.CreatedAt)
+		for _, pod := range sample.Pods {
+			entry.EphemeralStorageBytes += float64(pod.Storage.UsedBytes)
+		}
+	}
+
+	times := slices.SortedFunc(maps.Keys(result), time.Time.Compare)
+	sortedEntries := make([]WorkspaceIoChartEntry, 0, len(times))
+	for _, minute := range times {
+		sortedEntries = append(sortedEntries, *result[minute])
+	}
+	return sortedEntries
+}
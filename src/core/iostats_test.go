@@ -0,0 +1,46 @@
+package core
+
+import (
+	"mogenius-operator/src/podstatscollector"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateWorkspaceIo(t *testing.T) {
+	minute := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	ioSamples := []WorkloadIoStats{
+		{Node: "worker-1", CreatedAt: minute.Add(5 * time.Second), Pods: []PodIoUsage{
+			{Pod: "db-0", ReadBytes: 1000, WriteBytes: 4000},
+			{Pod: "api-0", ReadBytes: 10, WriteBytes: 0},
+		}},
+		// another node in the same minute adds up
+		{Node: "worker-2", CreatedAt: minute.Add(40 * time.Second), Pods: []PodIoUsage{
+			{Pod: "db-1", ReadBytes: 0, WriteBytes: 500},
+		}},
+		{Node: "worker-1", CreatedAt: minute.Add(time.Minute), Pods: []PodIoUsage{
+			{Pod: "db-0", ReadBytes: 200, WriteBytes: 0},
+		}},
+		{Node: "worker-1"},
+	}
+	storageSamples := []WorkloadEphemeralStorage{
+		{CreatedAt: minute.Add(30 * time.Second), Pods: []PodEphemeralStorage{
+			{Pod: "db-0", Node: "worker-1", Storage: podstatscollector.FileSystem{UsedBytes: 1 << 20}},
+			{Pod: "db-1", Node: "worker-2", Storage: podstatscollector.FileSystem{UsedBytes: 1 << 10}},
+		}},
+	}
+
+	assert.Equal(t, []WorkspaceIoChartEntry{
+		{
+			GenericChartEntry:     GenericChartEntry{Time: minute, Value: 5510, Pods: map[string]float64{"db-0": 5000, "api-0": 10, "db-1": 500}},
+			ReadBytes:             1010,
+			WriteBytes:            4500,
+			EphemeralStorageBytes: 1<<20 + 1<<10,
+		},
+		{
+			GenericChartEntry: GenericChartEntry{Time: minute.Add(time.Minute), Value: 200, Pods: map[string]float64{"db-0": 200}},
+			ReadBytes:         200,
+		},
+	}, aggregateWorkspaceIo(ioSamples, storageSamples))
+}
@@ -193,7 +193,7 @@ func (self *moKubernetes) CreateOrUpdateResourceTemplateConfigmap() error {
 	ownNamespace := self.config.Get("MO_OWN_NAMESPACE")
 	_, err = self.CreateUnstructuredResource(utils.ConfigMapResource.ApiVersion, utils.ConfigMapResource.Plural, new(ownNamespace), string(updatedYaml))
 	if apierrors.IsAlreadyExists(err) {
-		_, err = kubernetes.UpdateUnstructuredResource(utils.ConfigMapResource.ApiVersion, utils.ConfigMapResource.Plural, utils.ConfigMapResource.Namespaced, string(updatedYaml))
+		_, err = kubernetes.UpdateUnstructuredResource(context.Background(), utils.ConfigMapResource.ApiVersion, utils.ConfigMapResource.Plural, utils.ConfigMapResource.Namespaced, string(updatedYaml))
 		if err != nil {
 			self.logger.Error("Resource template configmap failed to update", "error", err)
 			return err
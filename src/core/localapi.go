@@ -0,0 +1,286 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/k8sclient"
+	"mogenius-operator/src/shutdown"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"encoding/json"
+
+	"github.com/patrickmn/go-cache"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// localApiMaxBodyBytes caps request bodies. Pattern payloads are small JSON
+// documents; anything bigger is a mistake or an attack.
+const localApiMaxBodyBytes = 16 << 20
+
+// localApiIdentityTTL is how long a successful TokenReview is trusted before
+// the token is reviewed again. Short enough that a revoked ServiceAccount or a
+// deleted User loses access quickly, long enough that scripted callers do not
+// cost one TokenReview per request.
+const localApiIdentityTTL = time.Minute
+
+// LocalApiService exposes every registered socket pattern as
+// `POST /api/v1/<pattern>` for in-cluster callers, without the mogenius
+// platform in the loop.
+//
+// Callers authenticate with a Kubernetes bearer token (usually a projected
+// ServiceAccount token). The token is verified through a TokenReview and the
+// resulting identity has to be mapped to a mogenius `User` through its
+// `spec.subject`; tokens of identities without a `User` are rejected. Bodies
+// are validated against the pattern's generated `RequestSchema` before the
+// pattern runs, and the response is the same `{status,data,statusCode}`
+// envelope the WebSocket API returns.
+type LocalApiService interface {
+	Run()
+	Link(socketapi SocketApi)
+}
+
+type localApiService struct {
+	logger         *slog.Logger
+	config         cfg.ConfigModule
+	clientProvider k8sclient.K8sClientProvider
+	socketapi      SocketApi
+
+	identities *cache.Cache
+}
+
+func NewLocalApiService(logger *slog.Logger, configModule cfg.ConfigModule, clientProvider k8sclient.K8sClientProvider) LocalApiService {
+	self := &localApiService{}
+	self.logger = logger
+	self.config = configModule
+	self.clientProvider = clientProvider
+	self.identities = cache.New(localApiIdentityTTL, 5*time.Minute)
+
+	return self
+}
+
+func (self *localApiService) Link(socketapi SocketApi) {
+	assert.Assert(socketapi != nil)
+
+	self.socketapi = socketapi
+}
+
+func (self *localApiService) Run() {
+	assert.Assert(self.socketapi != nil)
+
+	enabled, _ := self.config.TryGetBool("MO_LOCAL_API_ENABLED")
+	if !enabled {
+		self.logger.Debug("local api is disabled")
+		return
+	}
+
+	addr := self.config.Get("MO_LOCAL_API_ADDR")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/{pattern...}", self.handlePattern)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+	}
+
+	shutdown.Add(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			self.logger.Warn("local api graceful shutdown failed", "error", err)
+		}
+	})
+
+	self.logger.Info("starting local API server", "addr", addr)
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			self.logger.Error("failed to start local api server", "error", err)
+		}
+	}()
+}
+
+func (self *localApiService) handlePattern(w http.ResponseWriter, r *http.Request) {
+	pattern := r.PathValue("pattern")
+
+	user, status, err := self.authenticate(r)
+	if err != nil {
+		self.logger.Warn("local api request rejected", "pattern", pattern, "remoteAddr", r.RemoteAddr, "error", err)
+		self.writeError(w, status, err)
+		return
+	}
+
+	config, ok := self.socketapi.PatternConfig(pattern)
+	if !ok {
+		self.writeError(w, http.StatusNotFound, fmt.Errorf("no handler for pattern '%s' found", pattern))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, localApiMaxBodyBytes))
+	if err != nil {
+		if _, tooLarge := errors.AsType[*http.MaxBytesError](err); tooLarge {
+			self.writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", localApiMaxBodyBytes))
+			return
+		}
+		self.writeError(w, http.StatusBadRequest, fmt.Errorf("failed to read request body: %s", err.Error()))
+		return
+	}
+
+	if err := config.RequestSchema.Validate(body); err != nil {
+		self.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	datagram := structs.CreateEmptyDatagram()
+	datagram.Pattern = pattern
+	datagram.User = *user
+	datagram.Username = user.Email
+	datagram.Workspace = r.Header.Get("X-Mogenius-Workspace")
+	if len(body) > 0 {
+		datagram.Payload = json.RawMessage(body)
+	}
+
+	self.logger.Info("local api request for pattern", "pattern", pattern, "user", user.Email, "workspace", datagram.Workspace)
+
+	// the handler is aborted when the caller hangs up
+	result := self.socketapi.ExecuteCommandRequest(datagram.WithContext(r.Context()))
+	data, err := json.Marshal(result)
+	if err != nil {
+		self.writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to encode response: %s", err.Error()))
+		return
+	}
+
+	var envelope struct {
+		StatusCode int `json:"statusCode"`
+	}
+	_ = json.Unmarshal(data, &envelope)
+	httpStatus := http.StatusOK
+	if envelope.StatusCode >= 400 && envelope.StatusCode <= 599 {
+		httpStatus = envelope.StatusCode
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	if _, err := w.Write(data); err != nil {
+		self.logger.Error("failed to write local api response", "pattern", pattern, "error", err)
+	}
+}
+
+// authenticate resolves the bearer token of the request to the mogenius user
+// it belongs to. The returned status is the HTTP status to answer with when
+// the error is non-nil.
+func (self *localApiService) authenticate(r *http.Request) (*structs.User, int, error) {
+	token, ok := bearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		return nil, http.StatusUnauthorized, fmt.Errorf("missing bearer token")
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	cacheKey := hex.EncodeToString(sum[:])
+	if cached, found := self.identities.Get(cacheKey); found {
+		return cached.(*structs.User), 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	review, err := self.clientProvider.K8sClientSet().AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, http.StatusServiceUnavailable, fmt.Errorf("token review failed: %s", err.Error())
+	}
+	if !review.Status.Authenticated {
+		return nil, http.StatusUnauthorized, fmt.Errorf("token is not authenticated: %s", review.Status.Error)
+	}
+
+	users, err := store.GetAllUsers(self.config.Get("MO_OWN_NAMESPACE"))
+	if err != nil {
+		return nil, http.StatusServiceUnavailable, fmt.Errorf("failed to list users: %s", err.Error())
+	}
+	moUser := userForIdentity(users, review.Status.User.Username, review.Status.User.Groups)
+	if moUser == nil {
+		return nil, http.StatusForbidden, fmt.Errorf("no mogenius User is mapped to identity '%s'", review.Status.User.Username)
+	}
+
+	user := &structs.User{
+		FirstName: moUser.Spec.FirstName,
+		LastName:  moUser.Spec.LastName,
+		Email:     moUser.Spec.Email,
+		Source:    "local-api",
+	}
+	self.identities.SetDefault(cacheKey, user)
+
+	return user, 0, nil
+}
+
+func (self *localApiService) writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encodeErr := json.NewEncoder(w).Encode(struct {
+		Status     string `json:"status"`
+		Message    string `json:"message"`
+		StatusCode int    `json:"statusCode"`
+	}{
+		Status:     "error",
+		Message:    err.Error(),
+		StatusCode: status,
+	})
+	if encodeErr != nil {
+		self.logger.Error("failed to write local api error response", "error", encodeErr)
+	}
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// userForIdentity finds the mogenius User whose `spec.subject` matches an
+// authenticated Kubernetes identity. Direct User/ServiceAccount subjects win
+// over Group subjects so a personal mapping is never shadowed by a team one.
+func userForIdentity(users []v1alpha1.User, username string, groups []string) *v1alpha1.User {
+	var groupMatch *v1alpha1.User
+	for i := range users {
+		subject := users[i].Spec.Subject
+		if subject == nil || subject.Name == "" {
+			continue
+		}
+		switch subject.Kind {
+		case rbacv1.UserKind:
+			if subject.Name == username {
+				return &users[i]
+			}
+		case rbacv1.ServiceAccountKind:
+			if subject.Namespace != "" && "system:serviceaccount:"+subject.Namespace+":"+subject.Name == username {
+				return &users[i]
+			}
+		case rbacv1.GroupKind:
+			if groupMatch == nil && slices.Contains(groups, subject.Name) {
+				groupMatch = &users[i]
+			}
+		}
+	}
+	return groupMatch
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"testing"
+
+	"mogenius-operator/src/crds/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeUserWithSubject(name string, subject *rbacv1.Subject) v1alpha1.User {
+	return v1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1alpha1.NewUserSpec("", "", name+"@example.com", subject),
+	}
+}
+
+func TestUserForIdentity_ServiceAccount(t *testing.T) {
+	users := []v1alpha1.User{
+		makeUserWithSubject("nobody", nil),
+		makeUserWithSubject("ci", &rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci", Namespace: "tools"}),
+	}
+
+	user := userForIdentity(users, "system:serviceaccount:tools:ci", nil)
+	assert.NotNil(t, user)
+	assert.Equal(t, "ci", user.Name)
+
+	assert.Nil(t, userForIdentity(users, "system:serviceaccount:other:ci", nil))
+}
+
+func TestUserForIdentity_DirectMatchBeatsGroup(t *testing.T) {
+	users := []v1alpha1.User{
+		makeUserWithSubject("ops", &rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "operations"}),
+		makeUserWithSubject("jane", &rbacv1.Subject{Kind: rbacv1.UserKind, Name: "jane"}),
+	}
+
+	user := userForIdentity(users, "jane", []string{"operations"})
+	assert.NotNil(t, user)
+	assert.Equal(t, "jane", user.Name)
+
+	user = userForIdentity(users, "john", []string{"operations"})
+	assert.NotNil(t, user)
+	assert.Equal(t, "ops", user.Name)
+
+	assert.Nil(t, userForIdentity(users, "john", []string{"developers"}))
+}
+
+func TestBearerToken(t *testing.T) {
+	token, ok := bearerToken("Bearer abc.def")
+	assert.True(t, ok)
+	assert.Equal(t, "abc.def", token)
+
+	token, ok = bearerToken("bearer   xyz ")
+	assert.True(t, ok)
+	assert.Equal(t, "xyz", token)
+
+	_, ok = bearerToken("Basic abc")
+	assert.False(t, ok)
+
+	_, ok = bearerToken("Bearer ")
+	assert.False(t, ok)
+
+	_, ok = bearerToken("")
+	assert.False(t, ok)
+}
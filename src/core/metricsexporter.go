@@ -0,0 +1,274 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/cpumonitor"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/networkmonitor"
+	"mogenius-operator/src/rammonitor"
+	"mogenius-operator/src/shutdown"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/utils"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// workspaceLabelCacheTTL is how long the namespace → workspace mapping used
+// for the workspace label is reused before the Workspaces are listed again.
+const workspaceLabelCacheTTL = time.Minute
+
+var podSeriesLabels = []string{"node", "namespace", "pod", "workload_kind", "workload", "workspace"}
+
+var (
+	nodeCpuUsageDesc = prometheus.NewDesc(
+		"mogenius_node_cpu_usage_ratio",
+		"Share of the node's CPU time spent per mode (user, system, idle) during the last second.",
+		[]string{"node", "mode"}, nil,
+	)
+	nodeMemoryTotalDesc = prometheus.NewDesc(
+		"mogenius_node_memory_total_bytes",
+		"Total memory of the node.",
+		[]string{"node"}, nil,
+	)
+	nodeMemoryUsedDesc = prometheus.NewDesc(
+		"mogenius_node_memory_used_bytes",
+		"Memory in use on the node.",
+		[]string{"node"}, nil,
+	)
+	podCpuSecondsDesc = prometheus.NewDesc(
+		"mogenius_pod_cpu_seconds_total",
+		"CPU time consumed by the running processes of the pod.",
+		podSeriesLabels, nil,
+	)
+	podMemoryResidentDesc = prometheus.NewDesc(
+		"mogenius_pod_memory_resident_bytes",
+		"Resident memory of the running processes of the pod.",
+		podSeriesLabels, nil,
+	)
+	podNetworkReceiveBytesDesc = prometheus.NewDesc(
+		"mogenius_pod_network_receive_bytes_total",
+		"Bytes received by the pod since it was registered by the traffic collector.",
+		podSeriesLabels, nil,
+	)
+	podNetworkTransmitBytesDesc = prometheus.NewDesc(
+		"mogenius_pod_network_transmit_bytes_total",
+		"Bytes transmitted by the pod since it was registered by the traffic collector.",
+		podSeriesLabels, nil,
+	)
+	podNetworkReceivePacketsDesc = prometheus.NewDesc(
+		"mogenius_pod_network_receive_packets_total",
+		"Packets received by the pod since it was registered by the traffic collector.",
+		podSeriesLabels, nil,
+	)
+	podNetworkTransmitPacketsDesc = prometheus.NewDesc(
+		"mogenius_pod_network_transmit_packets_total",
+		"Packets transmitted by the pod since it was registered by the traffic collector.",
+		podSeriesLabels, nil,
+	)
+)
+
+// MetricsExporter publishes the node and pod series the nodemetrics
+// DaemonSet collects (CPU, RAM, traffic) to Prometheus: as an OpenMetrics
+// /metrics endpoint and, optionally, through remote-write. Series are built
+// from the monitors at scrape time; nothing is read back from Valkey.
+type MetricsExporter interface {
+	Run()
+}
+
+type metricsExporter struct {
+	logger            *slog.Logger
+	config            cfg.ConfigModule
+	nodeName          string
+	cpuMonitor        cpumonitor.CpuMonitor
+	ramMonitor        rammonitor.RamMonitor
+	networkMonitor    networkmonitor.NetworkMonitor
+	ownerCacheService store.OwnerCacheService
+	registry          *prometheus.Registry
+
+	// listWorkspaces is a variable so tests can run without a store.
+	listWorkspaces     func() ([]v1alpha1.Workspace, error)
+	workspaceCacheLock sync.Mutex
+	workspaceCache     map[string]string
+	workspaceCacheAt   time.Time
+}
+
+func NewMetricsExporter(
+	logger *slog.Logger,
+	config cfg.ConfigModule,
+	cpuMonitor cpumonitor.CpuMonitor,
+	ramMonitor rammonitor.RamMonitor,
+	networkMonitor networkmonitor.NetworkMonitor,
+	ownerCacheService store.OwnerCacheService,
+) MetricsExporter {
+	self := &metricsExporter{}
+
+	self.logger = logger
+	self.config = config
+	self.cpuMonitor = cpuMonitor
+	self.ramMonitor = ramMonitor
+	self.networkMonitor = networkMonitor
+	self.ownerCacheService = ownerCacheService
+	self.listWorkspaces = func() ([]v1alpha1.Workspace, error) {
+		return store.GetAllWorkspaces(config.Get("MO_OWN_NAMESPACE"))
+	}
+	self.registry = prometheus.NewRegistry()
+	self.registry.MustRegister(self)
+
+	return self
+}
+
+func (self *metricsExporter) Run() {
+	assert.Assert(self.logger != nil)
+	assert.Assert(self.config != nil)
+
+	self.nodeName = self.config.Get("OWN_NODE_NAME")
+	assert.Assert(self.nodeName != "", "OWN_NODE_NAME has to be defined and non-empty", self.nodeName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown.Add(cancel)
+
+	if addr := self.config.Get("MO_METRICS_EXPORTER_ADDR"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", promhttp.HandlerFor(self.registry, promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+			ErrorLog:          slog.NewLogLogger(self.logger.Handler(), slog.LevelError),
+		}))
+		server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		shutdown.Add(func() { _ = server.Close() })
+		go func() {
+			self.logger.Info("serving node metrics", "addr", addr, "path", "/metrics")
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				self.logger.Error("node metrics endpoint stopped", "addr", addr, "error", err)
+			}
+		}()
+	}
+
+	if remoteWriteUrl := self.config.Get("MO_METRICS_REMOTE_WRITE_URL"); remoteWriteUrl != "" {
+		client, err := newRemoteWriteClient(self.logger.With("scope", "remote-write"), remoteWriteUrl, self.config.Get("MO_METRICS_REMOTE_WRITE_BEARER_TOKEN"))
+		if err != nil {
+			self.logger.Error("remote-write is disabled", "error", err)
+			return
+		}
+		interval, err := time.ParseDuration(self.config.Get("MO_METRICS_REMOTE_WRITE_INTERVAL"))
+		assert.Assert(err == nil, "config validation should not let an invalid duration pass", err)
+		go func() {
+			for sleepCtx(ctx, interval) {
+				families, err := self.registry.Gather()
+				if err != nil {
+					self.logger.Warn("failed to gather node metrics", "error", err)
+				}
+				if err := client.flush(ctx, remoteWriteSeriesFromFamilies(families, time.Now())); err != nil {
+					self.logger.Warn("failed to remote-write node metrics, keeping them for the next attempt", "error", err)
+				}
+			}
+		}()
+	}
+}
+
+// Describe sends nothing: the exporter is an unchecked collector because the
+// set of pods changes between scrapes.
+func (self *metricsExporter) Describe(chan<- *prometheus.Desc) {}
+
+func (self *metricsExporter) Collect(ch chan<- prometheus.Metric) {
+	cpu := self.cpuMonitor.CpuUsageGlobal()
+	ch <- prometheus.MustNewConstMetric(nodeCpuUsageDesc, prometheus.GaugeValue, cpu.User/100, self.nodeName, "user")
+	ch <- prometheus.MustNewConstMetric(nodeCpuUsageDesc, prometheus.GaugeValue, cpu.System/100, self.nodeName, "system")
+	ch <- prometheus.MustNewConstMetric(nodeCpuUsageDesc, prometheus.GaugeValue, cpu.Idle/100, self.nodeName, "idle")
+
+	ram := self.ramMonitor.RamUsageGlobal()
+	ch <- prometheus.MustNewConstMetric(nodeMemoryTotalDesc, prometheus.GaugeValue, ram.TotalKb*1024, self.nodeName)
+	ch <- prometheus.MustNewConstMetric(nodeMemoryUsedDesc, prometheus.GaugeValue, ram.UsedKb*1024, self.nodeName)
+
+	workspaces := self.namespaceWorkspaces()
+
+	// the monitors report one entry per container, series are per pod
+	type podKey struct{ namespace, name string }
+	cpuSeconds := map[podKey]float64{}
+	for _, pod := range self.cpuMonitor.CpuUsageProcesses() {
+		for _, pid := range pod.Pids {
+			cpuSeconds[podKey{pod.Namespace, pod.Name}] += float64(pid.UserTime+pid.SysTime) / 1000
+		}
+	}
+	residentBytes := map[podKey]float64{}
+	pageSize := float64(os.Getpagesize())
+	for _, pod := range self.ramMonitor.RamUsageProcesses() {
+		for _, pid := range pod.Pids {
+			residentBytes[podKey{pod.Namespace, pod.Name}] += float64(pid.MemResident) * pageSize
+		}
+	}
+	for key, value := range cpuSeconds {
+		ch <- prometheus.MustNewConstMetric(podCpuSecondsDesc, prometheus.CounterValue, value, self.podLabelValues(key.namespace, key.name, workspaces)...)
+	}
+	for key, value := range residentBytes {
+		ch <- prometheus.MustNewConstMetric(podMemoryResidentDesc, prometheus.GaugeValue, value, self.podLabelValues(key.namespace, key.name, workspaces)...)
+	}
+
+	seen := map[podKey]bool{}
+	for _, stat := range self.networkMonitor.GetPodNetworkUsage() {
+		key := podKey{stat.Namespace, stat.Pod}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		labels := self.podLabelValues(stat.Namespace, stat.Pod, workspaces)
+		ch <- prometheus.MustNewConstMetric(podNetworkReceiveBytesDesc, prometheus.CounterValue, float64(stat.ReceivedBytes), labels...)
+		ch <- prometheus.MustNewConstMetric(podNetworkTransmitBytesDesc, prometheus.CounterValue, float64(stat.TransmitBytes), labels...)
+		ch <- prometheus.MustNewConstMetric(podNetworkReceivePacketsDesc, prometheus.CounterValue, float64(stat.ReceivedPackets), labels...)
+		ch <- prometheus.MustNewConstMetric(podNetworkTransmitPacketsDesc, prometheus.CounterValue, float64(stat.TransmitPackets), labels...)
+	}
+}
+
+// podLabelValues returns the values of podSeriesLabels. Pods without a known
+// controller are their own workload.
+func (self *metricsExporter) podLabelValues(namespace string, pod string, workspaces map[string]string) []string {
+	controller := self.ownerCacheService.ControllerForPod(namespace, pod)
+	if controller == nil {
+		controller = &utils.WorkloadSingleRequest{ResourceDescriptor: utils.PodResource, ResourceName: pod, Namespace: namespace}
+	}
+	return []string{self.nodeName, namespace, pod, controller.Kind, controller.ResourceName, workspaces[namespace]}
+}
+
+// namespaceWorkspaces maps namespaces to the workspace they belong to. A
+// namespace in several workspaces gets the alphabetically first one.
+func (self *metricsExporter) namespaceWorkspaces() map[string]string {
+	self.workspaceCacheLock.Lock()
+	defer self.workspaceCacheLock.Unlock()
+
+	if self.workspaceCache != nil && time.Since(self.workspaceCacheAt) < workspaceLabelCacheTTL {
+		return self.workspaceCache
+	}
+	workspaces, err := self.listWorkspaces()
+	if err != nil {
+		self.logger.Warn("failed to list workspaces for the workspace label", "error", err)
+		if self.workspaceCache != nil {
+			return self.workspaceCache
+		}
+		return map[string]string{}
+	}
+	slices.SortFunc(workspaces, func(a, b v1alpha1.Workspace) int { return strings.Compare(a.Name, b.Name) })
+	mapping := map[string]string{}
+	for _, workspace := range workspaces {
+		for _, resource := range workspace.Spec.Resources {
+			if resource.Type != "namespace" {
+				continue
+			}
+			if _, ok := mapping[resource.Id]; !ok {
+				mapping[resource.Id] = workspace.Name
+			}
+		}
+	}
+	self.workspaceCache = mapping
+	self.workspaceCacheAt = time.Now()
+	return mapping
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"log/slog"
+	"mogenius-operator/src/cpumonitor"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/networkmonitor"
+	"mogenius-operator/src/prompb"
+	"mogenius-operator/src/rammonitor"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/utils"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeCpuMonitor struct{}
+
+func (fakeCpuMonitor) CpuUsageGlobal() cpumonitor.CpuMetrics {
+	return cpumonitor.CpuMetrics{User: 20, System: 5, Idle: 75}
+}
+
+func (fakeCpuMonitor) CpuUsageProcesses() []cpumonitor.PodCpuStats {
+	return []cpumonitor.PodCpuStats{
+		{Name: "api-7d9f-x1", Namespace: "shop", Pids: []cpumonitor.CpuUsagePodPid{{UserTime: 1500, SysTime: 500}}},
+		// a second container of the same pod
+		{Name: "api-7d9f-x1", Namespace: "shop", Pids: []cpumonitor.CpuUsagePodPid{{UserTime: 1000}}},
+	}
+}
+
+type fakeRamMonitor struct{}
+
+func (fakeRamMonitor) RamUsageGlobal() rammonitor.RamMetrics {
+	return rammonitor.RamMetrics{TotalKb: 1024, UsedKb: 512}
+}
+
+func (fakeRamMonitor) RamUsageProcesses() []rammonitor.PodRamStats {
+	return []rammonitor.PodRamStats{
+		{Name: "api-7d9f-x1", Namespace: "shop", Pids: []rammonitor.RamUsagePodPid{{MemResident: 2}}},
+	}
+}
+
+type fakeNetworkMonitor struct {
+	networkmonitor.NetworkMonitor
+}
+
+func (fakeNetworkMonitor) GetPodNetworkUsage() []networkmonitor.PodNetworkStats {
+	return []networkmonitor.PodNetworkStats{
+		{Pod: "debug", Namespace: "tools", ReceivedBytes: 10, TransmitBytes: 20, ReceivedPackets: 1, TransmitPackets: 2},
+	}
+}
+
+type fakeOwnerCacheService struct {
+	store.OwnerCacheService
+}
+
+func (fakeOwnerCacheService) ControllerForPod(namespace string, podName string) *utils.WorkloadSingleRequest {
+	if podName != "api-7d9f-x1" {
+		return nil
+	}
+	return &utils.WorkloadSingleRequest{ResourceDescriptor: utils.DeploymentResource, Namespace: namespace, ResourceName: "api"}
+}
+
+func TestMetricsExporterCollect(t *testing.T) {
+	self := NewMetricsExporter(slog.New(slog.DiscardHandler), nil, fakeCpuMonitor{}, fakeRamMonitor{}, fakeNetworkMonitor{}, fakeOwnerCacheService{}).(*metricsExporter)
+	self.nodeName = "worker-1"
+	self.listWorkspaces = func() ([]v1alpha1.Workspace, error) {
+		return []v1alpha1.Workspace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "webshop"}, Spec: v1alpha1.WorkspaceSpec{Resources: []v1alpha1.WorkspaceResourceIdentifier{{Id: "shop", Type: "namespace"}}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "all"}, Spec: v1alpha1.WorkspaceSpec{Resources: []v1alpha1.WorkspaceResourceIdentifier{{Id: "shop", Type: "namespace"}, {Id: "tools", Type: "helm"}}}},
+		}, nil
+	}
+
+	families, err := self.registry.Gather()
+	require.NoError(t, err)
+	samples := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := []string{}
+			for _, pair := range metric.GetLabel() {
+				labels = append(labels, pair.GetName()+"="+pair.GetValue())
+			}
+			samples[family.GetName()+"{"+strings.Join(labels, ",")+"}"] = metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+		}
+	}
+
+	apiLabels := "{namespace=shop,node=worker-1,pod=api-7d9f-x1,workload=api,workload_kind=Deployment,workspace=all}"
+	debugLabels := "{namespace=tools,node=worker-1,pod=debug,workload=debug,workload_kind=Pod,workspace=}"
+	assert.Equal(t, map[string]float64{
+		"mogenius_node_cpu_usage_ratio{mode=idle,node=worker-1}":    0.75,
+		"mogenius_node_cpu_usage_ratio{mode=system,node=worker-1}":  0.05,
+		"mogenius_node_cpu_usage_ratio{mode=user,node=worker-1}":    0.2,
+		"mogenius_node_memory_total_bytes{node=worker-1}":           1024 * 1024,
+		"mogenius_node_memory_used_bytes{node=worker-1}":            512 * 1024,
+		"mogenius_pod_cpu_seconds_total" + apiLabels:                3,
+		"mogenius_pod_memory_resident_bytes" + apiLabels:            float64(2 * os.Getpagesize()),
+		"mogenius_pod_network_receive_bytes_total" + debugLabels:    10,
+		"mogenius_pod_network_transmit_bytes_total" + debugLabels:   20,
+		"mogenius_pod_network_receive_packets_total" + debugLabels:  1,
+		"mogenius_pod_network_transmit_packets_total" + debugLabels: 2,
+	}, samples)
+
+	series := remoteWriteSeriesFromFamilies(families, time.Unix(1790000000, 0))
+	assert.Len(t, series, 3+2+1+1+4)
+	assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "mogenius_node_memory_total_bytes"}, {Name: "node", Value: "worker-1"}}, series[3].Labels)
+	assert.Equal(t, []prompb.Sample{{Value: 1024 * 1024, Timestamp: 1790000000000}}, series[3].Samples)
+}
@@ -8,6 +8,7 @@ import (
 	"mogenius-operator/src/assert"
 	"mogenius-operator/src/config"
 	"mogenius-operator/src/cpumonitor"
+	"mogenius-operator/src/iomonitor"
 	"mogenius-operator/src/k8sclient"
 	"mogenius-operator/src/networkmonitor"
 	"mogenius-operator/src/rammonitor"
@@ -51,6 +52,7 @@ type nodeMetricsCollector struct {
 	cpuMonitor     cpumonitor.CpuMonitor
 	ramMonitor     rammonitor.RamMonitor
 	networkMonitor networkmonitor.NetworkMonitor
+	ioMonitor      iomonitor.IoMonitor
 }
 
 func NewNodeMetricsCollector(
@@ -60,6 +62,7 @@ func NewNodeMetricsCollector(
 	cpuMonitor cpumonitor.CpuMonitor,
 	ramMonitor rammonitor.RamMonitor,
 	networkMonitor networkmonitor.NetworkMonitor,
+	ioMonitor iomonitor.IoMonitor,
 ) NodeMetricsCollector {
 	self := &nodeMetricsCollector{}
 
@@ -70,6 +73,7 @@ func NewNodeMetricsCollector(
 	self.cpuMonitor = cpuMonitor
 	self.ramMonitor = ramMonitor
 	self.networkMonitor = networkMonitor
+	self.ioMonitor = ioMonitor
 
 	return self
 }
@@ -159,6 +163,7 @@ func (self *nodeMetricsCollector) Run() {
 	assert.Assert(self.cpuMonitor != nil)
 	assert.Assert(self.ramMonitor != nil)
 	assert.Assert(self.networkMonitor != nil)
+	assert.Assert(self.ioMonitor != nil)
 
 	nodeName := self.config.Get("OWN_NODE_NAME")
 	assert.Assert(nodeName != "", "OWN_NODE_NAME has to be defined and non-empty", nodeName)
@@ -188,7 +193,7 @@ func (self *nodeMetricsCollector) Run() {
 		go func() {
 			for {
 				metrics := self.networkMonitor.GetPodNetworkUsage()
-				self.statsDb.AddInterfaceStatsToDb(metrics)
+				self.statsDb.AddInterfaceStatsToDb(nodeName, metrics)
 				if !sleepCtx(ctx, 60*time.Second) {
 					return
 				}
@@ -207,6 +212,18 @@ func (self *nodeMetricsCollector) Run() {
 				}
 			}
 		}()
+		go func() {
+			for {
+				flows := self.networkMonitor.GetPodNetworkFlows()
+				err := self.statsDb.AddPodNetworkFlowsToDb(nodeName, flows)
+				if err != nil {
+					self.logger.Error("failed to add pod network flows", "error", err)
+				}
+				if !sleepCtx(ctx, 60*time.Second) {
+					return
+				}
+			}
+		}()
 		go func() {
 			// offset: 200ms
 			if !sleepCtx(ctx, 200*time.Millisecond) {
@@ -292,4 +309,34 @@ func (self *nodeMetricsCollector) Run() {
 			}
 		}
 	}()
+
+	// disk io
+	go func() {
+		// offset: 100ms
+		if !sleepCtx(ctx, 100*time.Millisecond) {
+			return
+		}
+		for {
+			metrics := self.ioMonitor.IoUsageProcesses()
+			err := self.statsDb.AddNodeIoProcessMetricsToDb(nodeName, metrics)
+			if err != nil {
+				self.logger.Error("failed to add node io proc metrics", "error", err)
+			}
+			if !sleepCtx(ctx, 1*time.Second) {
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			metrics := self.ioMonitor.IoUsageProcesses()
+			err := self.statsDb.AddPodIoStatsToDb(nodeName, metrics)
+			if err != nil {
+				self.logger.Error("failed to add pod io stats", "error", err)
+			}
+			if !sleepCtx(ctx, 60*time.Second) {
+				return
+			}
+		}
+	}()
 }
@@ -0,0 +1,444 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mogenius-operator/src/ai"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/k8sclient"
+	"mogenius-operator/src/notifications"
+	"mogenius-operator/src/shutdown"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/utils"
+	"mogenius-operator/src/valkeyclient"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	DB_NOTIFICATIONS_BUCKET_NAME     = "notifications"
+	DB_NOTIFICATIONS_DELIVERIES_NAME = "deliveries"
+	DB_NOTIFICATIONS_SENT_NAME       = "sent"
+	DB_NOTIFICATIONS_ALERTS_NAME     = "alerts"
+)
+
+const (
+	notificationDeliveryTTL = 7 * 24 * time.Hour
+	// notificationSentTTL dedupes repeated reports of the same AI task state.
+	notificationSentTTL = 24 * time.Hour
+	// notificationAlertTTL outlives a few missed polls, e.g. during a leader
+	// change, so an alert firing on is not reported again.
+	notificationAlertTTL = time.Hour
+	// notificationDeliveryTimeout bounds all attempts of one delivery.
+	notificationDeliveryTimeout = 10 * time.Minute
+
+	notificationDeliveriesDefaultLimit = 100
+	notificationDeliveriesMaxLimit     = 1000
+)
+
+// NotificationService delivers AI task results, Alertmanager alerts and failed
+// jobs to the NotificationChannels whose routes match, and keeps a delivery
+// log in Valkey.
+type NotificationService interface {
+	Run()
+	Link(apiService Api, leaderElector LeaderElector)
+	Notify(notification notifications.Notification)
+	NotifyJobFailed(job *structs.Job)
+	NotifyAiTask(task ai.AiTask)
+	ListDeliveries(request NotificationDeliveriesRequest) ([]notifications.Delivery, error)
+	SendTestNotification(channelName string) (*notifications.Delivery, error)
+}
+
+type NotificationDeliveriesRequest struct {
+	// Channel filters by NotificationChannel name.
+	Channel string `json:"channel,omitempty"`
+	// WorkspaceName filters by the workspaces of the notification.
+	WorkspaceName string `json:"workspaceName,omitempty"`
+	// Status filters by "delivered", "failed" or "rateLimited".
+	Status string `json:"status,omitempty"`
+	// Limit of the newest deliveries returned, 100 by default.
+	Limit int `json:"limit,omitempty"`
+}
+
+// notificationAlert is the last known state of a firing alert.
+type notificationAlert struct {
+	Notification notifications.Notification `json:"notification"`
+}
+
+type notificationService struct {
+	logger         *slog.Logger
+	config         cfg.ConfigModule
+	valkey         valkeyclient.ValkeyClient
+	clientProvider k8sclient.K8sClientProvider
+	alertmanager   AlertmanagerService
+	dispatcher     *notifications.Dispatcher
+	ctx            context.Context
+	apiService     Api
+	leaderElector  LeaderElector
+}
+
+func NewNotificationService(logger *slog.Logger, configModule cfg.ConfigModule, valkey valkeyclient.ValkeyClient, clientProvider k8sclient.K8sClientProvider, alertmanager AlertmanagerService) NotificationService {
+	self := &notificationService{}
+	self.logger = logger
+	self.config = configModule
+	self.valkey = valkey
+	self.clientProvider = clientProvider
+	self.alertmanager = alertmanager
+	self.dispatcher = notifications.NewDispatcher(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown.Add(cancel)
+	self.ctx = ctx
+
+	return self
+}
+
+func (self *notificationService) Link(apiService Api, leaderElector LeaderElector) {
+	assert.Assert(apiService != nil)
+	assert.Assert(leaderElector != nil)
+
+	self.apiService = apiService
+	self.leaderElector = leaderElector
+}
+
+func (self *notificationService) Run() {
+	assert.Assert(self.apiService != nil)
+	assert.Assert(self.leaderElector != nil)
+
+	interval, err := time.ParseDuration(self.config.Get("MO_NOTIFICATION_ALERT_INTERVAL"))
+	assert.Assert(err == nil, err)
+	if interval <= 0 {
+		self.logger.Debug("alert notifications are disabled, MO_NOTIFICATION_ALERT_INTERVAL is 0")
+		return
+	}
+
+	go func() {
+		for sleepCtx(self.ctx, interval) {
+			if !self.leaderElector.IsLeading() {
+				continue
+			}
+			if err := self.pollAlerts(); err != nil {
+				self.logger.Debug("failed to poll alerts for notifications", "error", err)
+			}
+		}
+	}()
+}
+
+// Notify delivers the notification in the background.
+func (self *notificationService) Notify(notification notifications.Notification) {
+	if notification.Id == "" {
+		notification.Id = utils.NanoId()
+	}
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+	go self.deliver(notification)
+}
+
+func (self *notificationService) NotifyJobFailed(job *structs.Job) {
+	message := job.Message
+	for _, cmd := range job.Commands {
+		if cmd.State != structs.JobStateSucceeded && cmd.Message != "" && cmd.Message != message {
+			message += "\n" + cmd.Message
+		}
+	}
+	self.Notify(notifications.Notification{
+		Source:    v1alpha1.NotificationSourceJob,
+		Severity:  v1alpha1.NotificationSeverityWarning,
+		Title:     "Job failed: " + job.Title,
+		Message:   message,
+		Namespace: job.NamespaceName,
+		Labels:    map[string]string{"jobId": job.Id, "controller": job.ControllerName},
+	})
+}
+
+func (self *notificationService) NotifyAiTask(task ai.AiTask) {
+	notification, ok := aiTaskNotification(task)
+	if !ok {
+		return
+	}
+	// tasks are reported on every update and by every replica, notify once
+	// per state
+	sentKey := []string{DB_NOTIFICATIONS_BUCKET_NAME, DB_NOTIFICATIONS_SENT_NAME, task.ID, string(task.State)}
+	first, err := self.valkey.SetIfNotExists(time.Now().Format(time.RFC3339), notificationSentTTL, sentKey...)
+	if err != nil {
+		self.logger.Warn("failed to record AI task notification", "task", task.ID, "error", err)
+		return
+	}
+	if !first {
+		return
+	}
+	if notification.Namespace == "" {
+		notification.Workspaces = self.workspacesOfNamespaces(task.ScopeNamespaces...)
+	}
+	self.Notify(notification)
+}
+
+// aiTaskNotification maps the states a user has to know about; everything
+// else (pending, in progress, ...) is not notified.
+func aiTaskNotification(task ai.AiTask) (notifications.Notification, bool) {
+	subject := task.AgentRef
+	if subject == "" {
+		subject = "AI task"
+	}
+	if resource := task.ReferencingResource; resource.ResourceName != "" {
+		subject += fmt.Sprintf(" on %s %s/%s", resource.Kind, resource.Namespace, resource.ResourceName)
+	}
+
+	notification := notifications.Notification{
+		Source:    v1alpha1.NotificationSourceAiTask,
+		Namespace: task.ReferencingResource.Namespace,
+		Agent:     task.AgentRef,
+		Labels:    map[string]string{"taskId": task.ID, "state": string(task.State)},
+	}
+	switch task.State {
+	case ai.AI_TASK_STATE_PROPOSED:
+		notification.Severity = v1alpha1.NotificationSeverityWarning
+		notification.Title = "Approval required: " + subject
+		tools := []string{}
+		if task.Response != nil {
+			for _, request := range task.Response.ToolRequests {
+				tools = append(tools, request.Name)
+			}
+		}
+		notification.Message = "The agent proposes to run: " + strings.Join(tools, ", ")
+	case ai.AI_TASK_STATE_COMPLETED:
+		notification.Severity = v1alpha1.NotificationSeverityInfo
+		notification.Title = "AI task completed: " + subject
+	case ai.AI_TASK_STATE_EXECUTED:
+		notification.Severity = v1alpha1.NotificationSeverityInfo
+		notification.Title = "Approved action executed: " + subject
+		notification.Message = task.ExecutionResult
+	case ai.AI_TASK_STATE_FAILED, ai.AI_TASK_STATE_EXECUTION_FAILED:
+		notification.Severity = v1alpha1.NotificationSeverityWarning
+		notification.Title = "AI task failed: " + subject
+		notification.Message = task.Error
+	default:
+		return notification, false
+	}
+	return notification, true
+}
+
+func alertNotification(alert Alert) notifications.Notification {
+	name := alert.Labels["alertname"]
+	title := name
+	if target := alertTarget(alert.Labels); target != "" {
+		title += " " + target
+	}
+	message := alert.Annotations["summary"]
+	if description := alert.Annotations["description"]; description != "" {
+		message = strings.TrimSpace(message + "\n" + description)
+	}
+	return notifications.Notification{
+		Source:    v1alpha1.NotificationSourceAlert,
+		Severity:  notifications.NormalizeSeverity(alert.Labels["severity"]),
+		Title:     title,
+		Message:   message,
+		Namespace: alert.Labels["namespace"],
+		Url:       alert.GeneratorURL,
+		Labels:    alert.Labels,
+		CreatedAt: alert.StartsAt,
+	}
+}
+
+func alertTarget(labels map[string]string) string {
+	for _, key := range []string{"pod", "deployment", "statefulset", "daemonset", "job_name", "service", "persistentvolumeclaim", "node", "instance"} {
+		if value := labels[key]; value != "" {
+			if namespace := labels["namespace"]; namespace != "" && key != "node" && key != "instance" {
+				return namespace + "/" + value
+			}
+			return value
+		}
+	}
+	return labels["namespace"]
+}
+
+// pollAlerts notifies alerts that started firing and, with severity info,
+// those that stopped.
+func (self *notificationService) pollAlerts() error {
+	alerts, err := self.alertmanager.GetAlerts()
+	if err != nil {
+		return err
+	}
+	previous, err := valkeyclient.GetKeyedObjectsForKeys[notificationAlert](self.valkey, self.alertKeys())
+	if err != nil {
+		return err
+	}
+	known := map[string]notificationAlert{}
+	for _, entry := range previous {
+		known[entry.Key[strings.LastIndex(entry.Key, ":")+1:]] = entry.Object
+	}
+
+	firing := map[string]struct{}{}
+	for _, alert := range alerts {
+		if alert.Status.State != "active" || alert.Fingerprint == "" {
+			continue
+		}
+		firing[alert.Fingerprint] = struct{}{}
+		notification := alertNotification(alert)
+		if err := self.valkey.SetObject(notificationAlert{Notification: notification}, notificationAlertTTL, DB_NOTIFICATIONS_BUCKET_NAME, DB_NOTIFICATIONS_ALERTS_NAME, alert.Fingerprint); err != nil {
+			return err
+		}
+		if _, ok := known[alert.Fingerprint]; !ok {
+			notification.CreatedAt = time.Now()
+			self.Notify(notification)
+		}
+	}
+
+	for fingerprint, entry := range known {
+		if _, ok := firing[fingerprint]; ok {
+			continue
+		}
+		if err := self.valkey.DeleteSingle(DB_NOTIFICATIONS_BUCKET_NAME, DB_NOTIFICATIONS_ALERTS_NAME, fingerprint); err != nil {
+			return err
+		}
+		resolved := entry.Notification
+		resolved.Id = ""
+		resolved.Title = "Resolved: " + resolved.Title
+		resolved.Severity = v1alpha1.NotificationSeverityInfo
+		resolved.CreatedAt = time.Now()
+		self.Notify(resolved)
+	}
+	return nil
+}
+
+func (self *notificationService) alertKeys() []string {
+	keys, err := self.valkey.Keys(strings.Join([]string{DB_NOTIFICATIONS_BUCKET_NAME, DB_NOTIFICATIONS_ALERTS_NAME, "*"}, ":"))
+	if err != nil {
+		self.logger.Warn("failed to list known alerts", "error", err)
+		return []string{}
+	}
+	return keys
+}
+
+func (self *notificationService) deliver(notification notifications.Notification) {
+	ownNamespace := self.config.Get("MO_OWN_NAMESPACE")
+	channels, err := store.GetAllNotificationChannels(ownNamespace)
+	if err != nil || len(channels) == 0 {
+		return
+	}
+	if notification.Namespace != "" && len(notification.Workspaces) == 0 {
+		notification.Workspaces = self.workspacesOfNamespaces(notification.Namespace)
+	}
+
+	for _, channel := range channels {
+		if channel.Spec.Disabled || !notifications.Matches(channel.Spec, notification) {
+			continue
+		}
+		go func() {
+			delivery := self.deliverToChannel(channel, notification)
+			if delivery.Status == notifications.DeliveryStatusFailed {
+				self.logger.Warn("failed to deliver notification", "channel", channel.Name, "title", notification.Title, "attempts", delivery.Attempts, "error", delivery.Error)
+			}
+		}()
+	}
+}
+
+func (self *notificationService) deliverToChannel(channel v1alpha1.NotificationChannel, notification notifications.Notification) notifications.Delivery {
+	ctx, cancel := context.WithTimeout(self.ctx, notificationDeliveryTimeout)
+	defer cancel()
+
+	var delivery notifications.Delivery
+	resolved, err := notifications.ResolveChannel(channel, func(name string) (*corev1.Secret, error) {
+		if secret := store.GetSecret(channel.Namespace, name); secret != nil {
+			return secret, nil
+		}
+		return self.clientProvider.K8sClientSet().CoreV1().Secrets(channel.Namespace).Get(ctx, name, metav1.GetOptions{})
+	})
+	if err != nil {
+		now := time.Now()
+		delivery = notifications.Delivery{
+			Id:           utils.NanoId(),
+			Channel:      channel.Name,
+			ChannelType:  channel.Spec.Type,
+			Notification: notification,
+			Status:       notifications.DeliveryStatusFailed,
+			Error:        err.Error(),
+			CreatedAt:    now,
+			FinishedAt:   now,
+		}
+	} else {
+		delivery = self.dispatcher.Deliver(ctx, resolved, notification)
+	}
+
+	if err := self.valkey.SetObject(delivery, notificationDeliveryTTL, DB_NOTIFICATIONS_BUCKET_NAME, DB_NOTIFICATIONS_DELIVERIES_NAME, delivery.Id); err != nil {
+		self.logger.Error("failed to store notification delivery", "channel", channel.Name, "error", err)
+	}
+	return delivery
+}
+
+// workspacesOfNamespaces returns the workspaces containing any of the
+// namespaces.
+func (self *notificationService) workspacesOfNamespaces(namespaces ...string) []string {
+	if len(namespaces) == 0 {
+		return nil
+	}
+	workspaces, err := self.apiService.GetAllWorkspaces()
+	if err != nil {
+		self.logger.Warn("failed to resolve workspaces for notification", "namespaces", namespaces, "error", err)
+		return nil
+	}
+	result := []string{}
+	for _, workspace := range workspaces {
+		if slices.ContainsFunc(workspace.Resources, func(resource v1alpha1.WorkspaceResourceIdentifier) bool {
+			return resource.Type == "namespace" && slices.Contains(namespaces, resource.Id)
+		}) {
+			result = append(result, workspace.Name)
+		}
+	}
+	return result
+}
+
+func (self *notificationService) ListDeliveries(request NotificationDeliveriesRequest) ([]notifications.Delivery, error) {
+	deliveries, err := valkeyclient.GetObjectsByPrefix[notifications.Delivery](self.valkey, valkeyclient.ORDER_NONE, DB_NOTIFICATIONS_BUCKET_NAME, DB_NOTIFICATIONS_DELIVERIES_NAME, "*")
+	if err != nil {
+		return []notifications.Delivery{}, err
+	}
+	deliveries = slices.DeleteFunc(deliveries, func(delivery notifications.Delivery) bool {
+		return (request.Channel != "" && delivery.Channel != request.Channel) ||
+			(request.Status != "" && delivery.Status != request.Status) ||
+			(request.WorkspaceName != "" && !slices.Contains(delivery.Notification.Workspaces, request.WorkspaceName))
+	})
+	slices.SortFunc(deliveries, func(a, b notifications.Delivery) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	limit := request.Limit
+	if limit <= 0 {
+		limit = notificationDeliveriesDefaultLimit
+	}
+	limit = min(limit, notificationDeliveriesMaxLimit)
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// SendTestNotification delivers a test message to one channel, ignoring its
+// routes, and waits for the result.
+func (self *notificationService) SendTestNotification(channelName string) (*notifications.Delivery, error) {
+	channel, err := store.GetNotificationChannel(self.config.Get("MO_OWN_NAMESPACE"), channelName)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, fmt.Errorf("notification channel %q not found", channelName)
+	}
+	delivery := self.deliverToChannel(*channel, notifications.Notification{
+		Id:        utils.NanoId(),
+		Source:    "test",
+		Severity:  v1alpha1.NotificationSeverityInfo,
+		Title:     "Test notification",
+		Message:   fmt.Sprintf("The NotificationChannel %q of cluster %q works.", channelName, self.config.Get("MO_CLUSTER_NAME")),
+		CreatedAt: time.Now(),
+	})
+	return &delivery, nil
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"mogenius-operator/src/ai"
+	"mogenius-operator/src/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAiTaskNotification(t *testing.T) {
+	task := ai.AiTask{
+		ID:       "t1",
+		AgentRef: "triage",
+		State:    ai.AI_TASK_STATE_PROPOSED,
+		ReferencingResource: utils.WorkloadSingleRequest{
+			ResourceDescriptor: utils.ResourceDescriptor{Kind: "Deployment"},
+			Namespace:          "shop",
+			ResourceName:       "web",
+		},
+		Response: &ai.AiResponse{ToolRequests: []ai.ToolRequest{{Name: "scale_workload"}}},
+	}
+	notification, ok := aiTaskNotification(task)
+	assert.True(t, ok)
+	assert.Equal(t, "aiTask", notification.Source)
+	assert.Equal(t, "warning", notification.Severity)
+	assert.Equal(t, "Approval required: triage on Deployment shop/web", notification.Title)
+	assert.Equal(t, "The agent proposes to run: scale_workload", notification.Message)
+	assert.Equal(t, "shop", notification.Namespace)
+	assert.Equal(t, "triage", notification.Agent)
+
+	task.State = ai.AI_TASK_STATE_FAILED
+	task.Error = "model unavailable"
+	notification, ok = aiTaskNotification(task)
+	assert.True(t, ok)
+	assert.Equal(t, "AI task failed: triage on Deployment shop/web", notification.Title)
+	assert.Equal(t, "model unavailable", notification.Message)
+
+	task.State = ai.AI_TASK_STATE_IN_PROGRESS
+	_, ok = aiTaskNotification(task)
+	assert.False(t, ok)
+}
+
+func TestAlertNotification(t *testing.T) {
+	notification := alertNotification(Alert{
+		Labels:       map[string]string{"alertname": "KubePodCrashLooping", "namespace": "shop", "pod": "web-1", "severity": "critical"},
+		Annotations:  map[string]string{"summary": "Pod is crash looping.", "description": "Pod shop/web-1 restarted 5 times."},
+		GeneratorURL: "http://prometheus/graph",
+	})
+	assert.Equal(t, "alert", notification.Source)
+	assert.Equal(t, "critical", notification.Severity)
+	assert.Equal(t, "KubePodCrashLooping shop/web-1", notification.Title)
+	assert.Equal(t, "Pod is crash looping.\nPod shop/web-1 restarted 5 times.", notification.Message)
+	assert.Equal(t, "shop", notification.Namespace)
+	assert.Equal(t, "http://prometheus/graph", notification.Url)
+
+	notification = alertNotification(Alert{Labels: map[string]string{"alertname": "NodeNotReady", "node": "worker-1"}})
+	assert.Equal(t, "warning", notification.Severity)
+	assert.Equal(t, "NodeNotReady worker-1", notification.Title)
+}
@@ -95,6 +95,11 @@ func (self *podStatsCollector) Run() {
 					continue
 				}
 
+				err = self.statsDb.AddPodEphemeralStorageToDb(nodemetrics)
+				if err != nil {
+					self.logger.Warn("failed to store pod ephemeral storage stats", "error", err)
+				}
+
 				nodesResult := self.nodeStats(nodemetrics)
 				err = self.statsDb.AddNodeStatsToDb(nodesResult)
 				if err != nil {
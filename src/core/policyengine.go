@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/policy"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// PolicyEngine evaluates the Policies of the operator's namespace before a
+// pattern or an AI tool writes a change. Both checks return every violation
+// found, audit ones included, so callers can report them; enforced
+// violations also reject the change with a policy.DeniedError. Audit
+// violations are logged.
+type PolicyEngine interface {
+	// Check evaluates one resource; oldObject is nil on creates.
+	Check(request policy.Request, object, oldObject *unstructured.Unstructured) ([]policy.Violation, error)
+	// CheckManifest evaluates every resource of a multi-document manifest,
+	// e.g. a rendered Helm chart. Resources without a namespace are placed in
+	// namespace.
+	CheckManifest(request policy.Request, namespace string, manifest string) ([]policy.Violation, error)
+}
+
+type policyEngine struct {
+	logger    *slog.Logger
+	config    cfg.ConfigModule
+	evaluator *policy.Evaluator
+}
+
+func NewPolicyEngine(logger *slog.Logger, configModule cfg.ConfigModule) PolicyEngine {
+	self := &policyEngine{}
+	self.logger = logger
+	self.config = configModule
+	self.evaluator = policy.NewEvaluator()
+
+	return self
+}
+
+func (self *policyEngine) Check(request policy.Request, object, oldObject *unstructured.Unstructured) ([]policy.Violation, error) {
+	if object == nil {
+		return nil, nil
+	}
+	policies, err := self.policies()
+	if err != nil || len(policies) == 0 {
+		return nil, err
+	}
+	violations := self.evaluate(policies, request, object, oldObject)
+	return violations, policy.Denied(violations)
+}
+
+func (self *policyEngine) CheckManifest(request policy.Request, namespace string, manifest string) ([]policy.Violation, error) {
+	policies, err := self.policies()
+	if err != nil || len(policies) == 0 {
+		return nil, err
+	}
+	objects, err := manifestObjects(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest for policy check: %w", err)
+	}
+	violations := []policy.Violation{}
+	for _, object := range objects {
+		if object.GetNamespace() == "" {
+			object.SetNamespace(namespace)
+		}
+		violations = append(violations, self.evaluate(policies, request, object, nil)...)
+	}
+	return violations, policy.Denied(violations)
+}
+
+// policyRequest describes the change the pattern of datagram is about to write.
+func policyRequest(datagram structs.Datagram, operation string) policy.Request {
+	return policy.Request{Operation: operation, Source: datagram.Pattern, User: datagram.User.Email}
+}
+
+// checkPolicies evaluates the Policies for a resource the pattern of datagram
+// is about to write and records the violations for its response.
+func checkPolicies(engine PolicyEngine, datagram structs.Datagram, operation string, object, oldObject *unstructured.Unstructured) error {
+	violations, err := engine.Check(policyRequest(datagram, operation), object, oldObject)
+	policy.Record(datagram.Context(), violations)
+	return err
+}
+
+// manifestPolicyCheck is the helm ManifestCheck of an install or upgrade the
+// pattern of datagram runs; the violations are recorded for its response.
+func manifestPolicyCheck(engine PolicyEngine, datagram structs.Datagram, operation string, namespace string) func(manifest string) error {
+	return func(manifest string) error {
+		violations, err := engine.CheckManifest(policyRequest(datagram, operation), namespace, manifest)
+		policy.Record(datagram.Context(), violations)
+		return err
+	}
+}
+
+func (self *policyEngine) policies() ([]v1alpha1.Policy, error) {
+	policies, err := store.GetAllPolicies(self.config.Get("MO_OWN_NAMESPACE"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policies: %w", err)
+	}
+	return policies, nil
+}
+
+func (self *policyEngine) evaluate(policies []v1alpha1.Policy, request policy.Request, object, oldObject *unstructured.Unstructured) []policy.Violation {
+	if request.Namespace == "" {
+		request.Namespace = object.GetNamespace()
+	}
+	if request.Namespace != "" && len(request.Workspaces) == 0 {
+		request.Workspaces = self.workspacesOfNamespace(request.Namespace)
+	}
+	var old map[string]any
+	if oldObject != nil {
+		old = oldObject.Object
+	}
+
+	violations := self.evaluator.Evaluate(policies, request, object.Object, old)
+	for _, violation := range violations {
+		if violation.Mode == v1alpha1.PolicyModeAudit {
+			self.logger.Warn("policy violation (audit)", "policy", violation.Policy, "source", request.Source, "user", request.User, "kind", violation.Kind, "namespace", violation.Namespace, "name", violation.Name, "message", violation.Message)
+		}
+	}
+	return violations
+}
+
+func (self *policyEngine) workspacesOfNamespace(namespace string) []string {
+	workspaces, err := store.GetAllWorkspaces(self.config.Get("MO_OWN_NAMESPACE"))
+	if err != nil {
+		return nil
+	}
+	result := []string{}
+	for _, workspace := range workspaces {
+		if slices.Contains(workspaceNamespaces(workspace.Spec), namespace) {
+			result = append(result, workspace.Name)
+		}
+	}
+	return result
+}
+
+// manifestObjects splits a multi-document YAML manifest into its resources,
+// skipping empty documents.
+func manifestObjects(manifest string) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewBufferString(manifest), 4096)
+	objects := []*unstructured.Unstructured{}
+	for {
+		object := map[string]any{}
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, err
+		}
+		if len(object) == 0 {
+			continue
+		}
+		objects = append(objects, &unstructured.Unstructured{Object: object})
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/policy"
+	"mogenius-operator/src/structs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestManifestObjects(t *testing.T) {
+	objects, err := manifestObjects(`---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+---
+---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: shop
+`)
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	assert.Equal(t, "Service", objects[0].GetKind())
+	assert.Empty(t, objects[0].GetNamespace())
+	assert.Equal(t, "shop", objects[1].GetNamespace())
+
+	_, err = manifestObjects("kind: [")
+	assert.Error(t, err)
+}
+
+// fakePolicyEngine reports violations for every resource.
+type fakePolicyEngine struct {
+	PolicyEngine
+	violations []policy.Violation
+}
+
+func (self *fakePolicyEngine) Check(request policy.Request, object, oldObject *unstructured.Unstructured) ([]policy.Violation, error) {
+	return self.violations, policy.Denied(self.violations)
+}
+
+func TestPatternResponsePolicyViolations(t *testing.T) {
+	engine := &fakePolicyEngine{}
+	self := &socketApi{logger: slog.New(slog.DiscardHandler), patternHandler: map[string]PatternHandler{}}
+	RegisterPatternHandler(PatternHandle{self, "test/write"}, PatternConfig{}, func(datagram structs.Datagram, request Void) (string, error) {
+		if err := checkPolicies(engine, datagram, v1alpha1.PolicyOperationCreate, &unstructured.Unstructured{}, nil); err != nil {
+			return "", err
+		}
+		return "written", nil
+	})
+	respond := func() string {
+		payload, err := json.Marshal(self.patternHandler["test/write"].Callback(structs.Datagram{}))
+		require.NoError(t, err)
+		return string(payload)
+	}
+
+	assert.JSONEq(t, `{"status":"success","message":"written","data":""}`, respond())
+
+	engine.violations = []policy.Violation{{Policy: "labels", Mode: v1alpha1.PolicyModeAudit, Kind: "Deployment", Name: "api", Message: "needs a team label"}}
+	assert.JSONEq(t, `{"status":"success","message":"written","data":"","violations":[
+		{"policy":"labels","mode":"audit","kind":"Deployment","name":"api","expression":"","message":"needs a team label"}
+	]}`, respond(), "audit violations are reported on success")
+
+	engine.violations = append(engine.violations, policy.Violation{Policy: "limits", Mode: v1alpha1.PolicyModeEnforce, Kind: "Deployment", Name: "api", Message: "needs limits"})
+	var response struct {
+		Status     string             `json:"status"`
+		Violations []policy.Violation `json:"violations"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(respond()), &response))
+	assert.Equal(t, "error", response.Status)
+	assert.Equal(t, engine.violations, response.Violations)
+}
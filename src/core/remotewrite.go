@@ -0,0 +1,204 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mogenius-operator/src/prompb"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	remoteWriteBatchSize = 500
+	// remoteWriteMaxPendingBatches bounds what is kept while the endpoint is
+	// unreachable; the oldest batches are dropped first.
+	remoteWriteMaxPendingBatches = 200
+	remoteWriteMaxAttempts       = 5
+	remoteWriteInitialBackoff    = 500 * time.Millisecond
+	remoteWriteMaxBackoff        = 30 * time.Second
+)
+
+// remoteWriteClient pushes gathered series to a Prometheus remote-write
+// (protocol 1.0) endpoint in batches. Batches that fail with a retryable
+// error are retried with exponential backoff and otherwise kept for the next
+// flush.
+type remoteWriteClient struct {
+	logger      *slog.Logger
+	url         string
+	username    string
+	password    string
+	bearerToken string
+	httpClient  *http.Client
+
+	initialBackoff time.Duration
+	pending        [][]prompb.TimeSeries
+}
+
+func newRemoteWriteClient(logger *slog.Logger, rawUrl string, bearerToken string) (*remoteWriteClient, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote-write url: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid remote-write url %q: scheme must be http or https", parsed.Redacted())
+	}
+	self := &remoteWriteClient{
+		logger:         logger,
+		bearerToken:    bearerToken,
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		initialBackoff: remoteWriteInitialBackoff,
+	}
+	if parsed.User != nil {
+		self.username = parsed.User.Username()
+		self.password, _ = parsed.User.Password()
+		parsed.User = nil
+	}
+	self.url = parsed.String()
+	return self, nil
+}
+
+// flush queues series and sends everything pending. It returns the first
+// error of a batch that is kept for the next flush.
+func (self *remoteWriteClient) flush(ctx context.Context, series []prompb.TimeSeries) error {
+	for batch := range slices.Chunk(series, remoteWriteBatchSize) {
+		self.pending = append(self.pending, batch)
+	}
+	if dropped := len(self.pending) - remoteWriteMaxPendingBatches; dropped > 0 {
+		self.logger.Warn("dropping remote-write batches, the endpoint is not keeping up", "batches", dropped)
+		self.pending = self.pending[dropped:]
+	}
+
+	for len(self.pending) > 0 {
+		err := self.sendWithRetry(ctx, self.pending[0])
+		if err != nil {
+			if remoteErr, ok := errors.AsType[*remoteWriteError](err); ok && !remoteErr.retry {
+				self.logger.Error("remote-write endpoint rejected a batch, dropping it", "series", len(self.pending[0]), "error", err)
+				self.pending = self.pending[1:]
+				continue
+			}
+			return err
+		}
+		self.pending = self.pending[1:]
+	}
+	return nil
+}
+
+type remoteWriteError struct {
+	retry bool
+	msg   string
+}
+
+func (self *remoteWriteError) Error() string { return self.msg }
+
+func (self *remoteWriteClient) sendWithRetry(ctx context.Context, batch []prompb.TimeSeries) error {
+	body, err := encodeRemoteWriteRequest(batch)
+	if err != nil {
+		return &remoteWriteError{retry: false, msg: err.Error()}
+	}
+	backoff := self.initialBackoff
+	for attempt := 1; attempt <= remoteWriteMaxAttempts; attempt++ {
+		err = self.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if remoteErr, ok := errors.AsType[*remoteWriteError](err); ok && !remoteErr.retry {
+			return err
+		}
+		if attempt == remoteWriteMaxAttempts {
+			break
+		}
+		self.logger.Debug("remote-write failed, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, remoteWriteMaxBackoff)
+	}
+	return err
+}
+
+func (self *remoteWriteClient) send(ctx context.Context, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, self.url, bytes.NewReader(body))
+	if err != nil {
+		return &remoteWriteError{retry: false, msg: err.Error()}
+	}
+	request.Header.Set("Content-Encoding", "snappy")
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	request.Header.Set("User-Agent", "mogenius-operator")
+	request.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if self.bearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+self.bearerToken)
+	} else if self.username != "" {
+		request.SetBasicAuth(self.username, self.password)
+	}
+
+	response, err := self.httpClient.Do(request)
+	if err != nil {
+		return &remoteWriteError{retry: true, msg: err.Error()}
+	}
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, response.Body)
+		return nil
+	}
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+	return &remoteWriteError{
+		// 5xx and 429 are retried as the spec requires, other 4xx never succeed
+		retry: response.StatusCode/100 == 5 || response.StatusCode == http.StatusTooManyRequests,
+		msg:   fmt.Sprintf("remote-write returned %s: %s", response.Status, strings.TrimSpace(string(message))),
+	}
+}
+
+// remoteWriteSeriesFromFamilies flattens gathered gauges, counters and
+// untyped metrics into one-sample series taken at timestamp. Labels are
+// sorted by name and include __name__.
+func remoteWriteSeriesFromFamilies(families []*dto.MetricFamily, timestamp time.Time) []prompb.TimeSeries {
+	series := []prompb.TimeSeries{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			var value float64
+			switch family.GetType() {
+			case dto.MetricType_GAUGE:
+				value = metric.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				value = metric.GetCounter().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = metric.GetUntyped().GetValue()
+			default:
+				continue
+			}
+			labels := []prompb.Label{{Name: "__name__", Value: family.GetName()}}
+			for _, pair := range metric.GetLabel() {
+				labels = append(labels, prompb.Label{Name: pair.GetName(), Value: pair.GetValue()})
+			}
+			slices.SortFunc(labels, func(a, b prompb.Label) int { return strings.Compare(a.Name, b.Name) })
+			series = append(series, prompb.TimeSeries{
+				Labels:  labels,
+				Samples: []prompb.Sample{{Value: value, Timestamp: timestamp.UnixMilli()}},
+			})
+		}
+	}
+	return series
+}
+
+// encodeRemoteWriteRequest encodes series as the snappy-compressed
+// prompb.WriteRequest body of a remote-write request.
+func encodeRemoteWriteRequest(series []prompb.TimeSeries) ([]byte, error) {
+	request := prompb.WriteRequest{Timeseries: series}
+	data, err := request.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode remote-write request: %w", err)
+	}
+	return snappy.Encode(nil, data), nil
+}
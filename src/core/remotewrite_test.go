@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"mogenius-operator/src/prompb"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeRemoteWriteRequest is the inverse of encodeRemoteWriteRequest.
+func decodeRemoteWriteRequest(t *testing.T, body []byte) []prompb.TimeSeries {
+	data, err := snappy.Decode(nil, body)
+	require.NoError(t, err)
+	var request prompb.WriteRequest
+	require.NoError(t, request.Unmarshal(data))
+	return request.Timeseries
+}
+
+func testRemoteWriteSeries(count int) []prompb.TimeSeries {
+	series := make([]prompb.TimeSeries, 0, count)
+	for i := range count {
+		series = append(series, prompb.TimeSeries{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "mogenius_pod_cpu_seconds_total"},
+				{Name: "pod", Value: "api-" + string(rune('a'+i%26))},
+			},
+			Samples: []prompb.Sample{{Value: float64(i) + 0.5, Timestamp: 1790000000000 + int64(i)}},
+		})
+	}
+	return series
+}
+
+func TestEncodeRemoteWriteRequest(t *testing.T) {
+	series := testRemoteWriteSeries(3)
+	body, err := encodeRemoteWriteRequest(series)
+	require.NoError(t, err)
+	assert.Equal(t, series, decodeRemoteWriteRequest(t, body))
+}
+
+func TestRemoteWriteClientRetriesAndDrops(t *testing.T) {
+	var requests atomic.Int32
+	received := []prompb.TimeSeries{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := requests.Add(1)
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "0.1.0", r.Header.Get("X-Prometheus-Remote-Write-Version"))
+		username, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "secret", password)
+		switch attempt {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			body, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			received = append(received, decodeRemoteWriteRequest(t, body)...)
+		}
+	}))
+	defer server.Close()
+
+	client, err := newRemoteWriteClient(slog.New(slog.DiscardHandler), "http://user:secret@"+server.Listener.Addr().String()+"/api/v1/write", "")
+	require.NoError(t, err)
+	client.initialBackoff = time.Millisecond
+
+	// the first batch is retried after the 503 and dropped on the 400, the
+	// second one goes through
+	series := testRemoteWriteSeries(remoteWriteBatchSize + 10)
+	require.NoError(t, client.flush(context.Background(), series))
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Equal(t, series[remoteWriteBatchSize:], received)
+	assert.Empty(t, client.pending)
+}
+
+func TestRemoteWriteClientKeepsBatchesWhileUnavailable(t *testing.T) {
+	var available atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	client, err := newRemoteWriteClient(slog.New(slog.DiscardHandler), server.URL, "token")
+	require.NoError(t, err)
+	client.initialBackoff = time.Millisecond
+
+	assert.Error(t, client.flush(context.Background(), testRemoteWriteSeries(3)))
+	assert.Len(t, client.pending, 1)
+
+	available.Store(true)
+	require.NoError(t, client.flush(context.Background(), testRemoteWriteSeries(3)))
+	assert.Empty(t, client.pending)
+}
+
+func TestNewRemoteWriteClientRejectsScheme(t *testing.T) {
+	_, err := newRemoteWriteClient(slog.New(slog.DiscardHandler), "ftp://example.com/write", "")
+	assert.Error(t, err)
+}
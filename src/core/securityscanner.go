@@ -0,0 +1,219 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/security"
+	"mogenius-operator/src/shutdown"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/valkeyclient"
+	"mogenius-operator/src/version"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DB_SECURITY_BUCKET_NAME   = "security"
+	DB_SECURITY_FINDINGS_NAME = "findings"
+)
+
+// securityFindingsTTL outlives a few missed scans (e.g. during a leader
+// change); findings of resources that got fixed or deleted are removed by
+// the next scan.
+const securityFindingsTTL = 24 * time.Hour
+
+// SecurityScanner periodically checks the resources in the store for
+// insecure settings and keeps the findings of every resource in Valkey.
+type SecurityScanner interface {
+	Run()
+	Link(apiService Api, leaderElector LeaderElector)
+	ListFindings(request SecurityFindingsRequest) ([]security.ResourceFindings, error)
+	Sarif(request SecurityFindingsRequest) (security.SarifLog, error)
+}
+
+type SecurityFindingsRequest struct {
+	// WorkspaceName limits the findings to the namespaces of the workspace;
+	// cluster-scoped resources are only listed without workspace.
+	WorkspaceName string `json:"workspaceName,omitempty"`
+	// Namespaces limits the findings to these namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// MinSeverity drops findings below "critical", "high", "medium" or "low".
+	MinSeverity string `json:"minSeverity,omitempty"`
+	// Rule filters by rule id, e.g. "privileged-container".
+	Rule string `json:"rule,omitempty"`
+}
+
+type securityScanner struct {
+	logger        *slog.Logger
+	config        cfg.ConfigModule
+	valkey        valkeyclient.ValkeyClient
+	apiService    Api
+	leaderElector LeaderElector
+}
+
+func NewSecurityScanner(logger *slog.Logger, configModule cfg.ConfigModule, valkey valkeyclient.ValkeyClient) SecurityScanner {
+	self := &securityScanner{}
+	self.logger = logger
+	self.config = configModule
+	self.valkey = valkey
+
+	return self
+}
+
+func (self *securityScanner) Link(apiService Api, leaderElector LeaderElector) {
+	assert.Assert(apiService != nil)
+	assert.Assert(leaderElector != nil)
+
+	self.apiService = apiService
+	self.leaderElector = leaderElector
+}
+
+func (self *securityScanner) Run() {
+	assert.Assert(self.apiService != nil)
+	assert.Assert(self.leaderElector != nil)
+
+	interval, err := time.ParseDuration(self.config.Get("MO_SECURITY_SCAN_INTERVAL"))
+	assert.Assert(err == nil, err)
+	if interval <= 0 {
+		self.logger.Debug("security scan is disabled, MO_SECURITY_SCAN_INTERVAL is 0")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown.Add(cancel)
+
+	go func() {
+		for sleepCtx(ctx, interval) {
+			if !self.leaderElector.IsLeading() {
+				continue
+			}
+			if err := self.scan(time.Now()); err != nil {
+				self.logger.Error("security scan failed", "error", err)
+			}
+		}
+	}()
+}
+
+// scan checks every resource of the scanned kinds in the store once.
+func (self *securityScanner) scan(now time.Time) error {
+	previous, err := self.loadFindings()
+	if err != nil {
+		return err
+	}
+
+	found := map[string]struct{}{}
+	for _, kind := range security.ScannedKinds {
+		for _, obj := range store.GetResourceByKindAndNamespace(self.valkey, kind.ApiVersion, kind.Kind, "", self.logger) {
+			uid := string(obj.GetUID())
+			if uid == "" {
+				continue
+			}
+			findings := security.Scan(&obj)
+			if len(findings) == 0 {
+				continue
+			}
+			found[uid] = struct{}{}
+
+			entry := security.ResourceFindings{
+				Uid:        uid,
+				ApiVersion: kind.ApiVersion,
+				Kind:       kind.Kind,
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+				Findings:   findings,
+				Severity:   security.WorstSeverity(findings),
+				DetectedAt: now,
+				ScannedAt:  now,
+			}
+			if existing, ok := previous[uid]; ok {
+				entry.DetectedAt = existing.DetectedAt
+			}
+			if err := self.valkey.SetObject(entry, securityFindingsTTL, DB_SECURITY_BUCKET_NAME, DB_SECURITY_FINDINGS_NAME, uid); err != nil {
+				return err
+			}
+		}
+	}
+
+	for uid := range previous {
+		if _, ok := found[uid]; ok {
+			continue
+		}
+		if err := self.valkey.DeleteSingle(DB_SECURITY_BUCKET_NAME, DB_SECURITY_FINDINGS_NAME, uid); err != nil {
+			return err
+		}
+	}
+	self.logger.Debug("security scan finished", "resourcesWithFindings", len(found), "duration", time.Since(now))
+	return nil
+}
+
+func (self *securityScanner) loadFindings() (map[string]security.ResourceFindings, error) {
+	entries, err := valkeyclient.GetObjectsByPrefix[security.ResourceFindings](self.valkey, valkeyclient.ORDER_NONE, DB_SECURITY_BUCKET_NAME, DB_SECURITY_FINDINGS_NAME, "*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load security findings: %w", err)
+	}
+	result := make(map[string]security.ResourceFindings, len(entries))
+	for _, entry := range entries {
+		result[entry.Uid] = entry
+	}
+	return result, nil
+}
+
+func (self *securityScanner) ListFindings(request SecurityFindingsRequest) ([]security.ResourceFindings, error) {
+	entries, err := self.loadFindings()
+	if err != nil {
+		return []security.ResourceFindings{}, err
+	}
+
+	var namespaces []string
+	if request.WorkspaceName != "" {
+		namespaces, err = self.apiService.GetWorkspaceNamespaces(request.WorkspaceName)
+		if err != nil {
+			return []security.ResourceFindings{}, err
+		}
+	}
+	return filterSecurityFindings(entries, request.WorkspaceName != "", namespaces, request), nil
+}
+
+func (self *securityScanner) Sarif(request SecurityFindingsRequest) (security.SarifLog, error) {
+	entries, err := self.ListFindings(request)
+	if err != nil {
+		return security.SarifLog{}, err
+	}
+	return security.Sarif(entries, version.Ver), nil
+}
+
+// filterSecurityFindings applies the request filters; scoped to a workspace,
+// only its namespaces are kept and cluster-scoped resources are left out.
+// Entries are sorted worst first.
+func filterSecurityFindings(entries map[string]security.ResourceFindings, scoped bool, workspaceNamespaces []string, request SecurityFindingsRequest) []security.ResourceFindings {
+	minRank := security.SeverityRank(request.MinSeverity)
+	result := []security.ResourceFindings{}
+	for _, entry := range entries {
+		if scoped && !slices.Contains(workspaceNamespaces, entry.Namespace) {
+			continue
+		}
+		if len(request.Namespaces) > 0 && !slices.Contains(request.Namespaces, entry.Namespace) {
+			continue
+		}
+		entry.Findings = slices.DeleteFunc(slices.Clone(entry.Findings), func(finding security.Finding) bool {
+			return security.SeverityRank(finding.Severity) < minRank || (request.Rule != "" && finding.Rule != request.Rule)
+		})
+		if len(entry.Findings) == 0 {
+			continue
+		}
+		entry.Severity = security.WorstSeverity(entry.Findings)
+		result = append(result, entry)
+	}
+
+	slices.SortFunc(result, func(a, b security.ResourceFindings) int {
+		if rank := security.SeverityRank(b.Severity) - security.SeverityRank(a.Severity); rank != 0 {
+			return rank
+		}
+		return strings.Compare(a.Namespace+"/"+a.Kind+"/"+a.Name, b.Namespace+"/"+b.Kind+"/"+b.Name)
+	})
+	return result
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"mogenius-operator/src/security"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterSecurityFindings(t *testing.T) {
+	entries := map[string]security.ResourceFindings{
+		"1": {Uid: "1", Kind: "ClusterRoleBinding", Name: "ci-admin", Severity: security.SeverityHigh, Findings: []security.Finding{
+			{Rule: security.RuleClusterAdminBinding, Severity: security.SeverityHigh},
+		}},
+		"2": {Uid: "2", Kind: "Deployment", Namespace: "shop", Name: "api", Severity: security.SeverityCritical, Findings: []security.Finding{
+			{Rule: security.RulePrivilegedContainer, Severity: security.SeverityCritical},
+			{Rule: security.RuleImageWithoutDigest, Severity: security.SeverityLow},
+		}},
+		"3": {Uid: "3", Kind: "Deployment", Namespace: "blog", Name: "web", Severity: security.SeverityLow, Findings: []security.Finding{
+			{Rule: security.RuleImageWithoutDigest, Severity: security.SeverityLow},
+		}},
+	}
+	uids := func(result []security.ResourceFindings) []string {
+		ids := []string{}
+		for _, entry := range result {
+			ids = append(ids, entry.Uid)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"2", "1", "3"}, uids(filterSecurityFindings(entries, false, nil, SecurityFindingsRequest{})))
+	// workspaces never see cluster-scoped resources, even without namespaces
+	assert.Equal(t, []string{"2"}, uids(filterSecurityFindings(entries, true, []string{"shop"}, SecurityFindingsRequest{})))
+	assert.Empty(t, filterSecurityFindings(entries, true, nil, SecurityFindingsRequest{}))
+
+	result := filterSecurityFindings(entries, false, nil, SecurityFindingsRequest{Rule: security.RuleImageWithoutDigest})
+	assert.Equal(t, []string{"3", "2"}, uids(result), "equal severities sort by namespace")
+	assert.Equal(t, security.SeverityLow, result[1].Severity, "the severity follows the remaining findings")
+	assert.Len(t, entries["2"].Findings, 2, "stored entries are not modified")
+
+	assert.Equal(t, []string{"2", "1"}, uids(filterSecurityFindings(entries, false, nil, SecurityFindingsRequest{MinSeverity: security.SeverityHigh})))
+	assert.Equal(t, []string{"3"}, uids(filterSecurityFindings(entries, false, nil, SecurityFindingsRequest{Namespaces: []string{"blog"}})))
+}
@@ -2,20 +2,27 @@ package core
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"mogenius-operator/src/ai"
 	argocd "mogenius-operator/src/argocd"
 	"mogenius-operator/src/assert"
+	"mogenius-operator/src/backup"
 	"mogenius-operator/src/config"
 	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/deprecations"
 	"mogenius-operator/src/dtos"
 	"mogenius-operator/src/flux"
 	"mogenius-operator/src/helm"
 	"mogenius-operator/src/kubernetes"
 	moMetrics "mogenius-operator/src/metrics"
 	"mogenius-operator/src/networkmonitor"
+	"mogenius-operator/src/notifications"
+	"mogenius-operator/src/policy"
 	"mogenius-operator/src/schema"
+	"mogenius-operator/src/security"
 	"mogenius-operator/src/services"
 	"mogenius-operator/src/shutdown"
 	"mogenius-operator/src/store"
@@ -25,6 +32,7 @@ import (
 	"mogenius-operator/src/version"
 	"mogenius-operator/src/websocket"
 	"mogenius-operator/src/xterm"
+	"net/http"
 	"os"
 	"os/exec"
 	"reflect"
@@ -38,6 +46,7 @@ import (
 	release "helm.sh/helm/v4/pkg/release/v1"
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -77,6 +86,24 @@ const compressionThreshold = 1024
 // or risk an unbounded backlog of goroutines waiting on the K8s API.
 const messageWorkerCount = 50
 
+// cancelPattern is the control datagram that aborts an in-flight request.
+// Its payload `{"id": "..."}` names the Datagram.Id of the request. It is
+// handled by the read loops themselves, so it is not held up by busy workers.
+const cancelPattern = "cancel"
+
+// uploadStartConfig authorizes starting an upload, by files/upload/start as
+// well as by the legacy `files/upload` datagram. Uploads write to the volume
+// although their names carry no mutating token.
+var uploadStartConfig = PatternConfig{RequiredRole: PatternRoleEditor, Scope: PatternScopeWorkspace}
+
+// inflightRequest is a request read from a job client that has not been
+// answered yet.
+type inflightRequest struct {
+	user   string
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
 type SocketApi interface {
 	Link(
 		httpService HttpService,
@@ -87,6 +114,17 @@ type SocketApi interface {
 		sealedSecret SealedSecretManager,
 		aiApi AiApi,
 		aiWebsocketConnection ai.AiWebsocketConnection,
+		costEngine CostEngine,
+		gitOpsDriftDetector GitOpsDriftDetector,
+		gitOpsWriter GitOpsWriter,
+		notificationService NotificationService,
+		workloadRecommender WorkloadRecommender,
+		policyEngine PolicyEngine,
+		securityScanner SecurityScanner,
+		imageScanner ImageScanner,
+		upgradeReadinessChecker UpgradeReadinessChecker,
+		watchSubscriptionService WatchSubscriptionService,
+		fileTransferService FileTransferService,
 	)
 	Run()
 	Status() SocketApiStatus
@@ -104,6 +142,7 @@ type SocketApi interface {
 		client websocket.WebsocketClient,
 	)
 	PatternConfigs() map[string]PatternConfig
+	PatternConfig(pattern string) (PatternConfig, bool)
 	NormalizePatternName(pattern string) string
 	AssertPatternsUnique()
 	LoadRequest(datagram *structs.Datagram, data any) error
@@ -143,18 +182,32 @@ type socketApi struct {
 	logLevelMo bool
 
 	// the patternHandler should only be edited on startup
-	patternHandlerLock    sync.RWMutex
-	patternHandler        map[string]PatternHandler
-	httpService           HttpService
-	xtermService          XtermService
-	apiService            Api
-	moKubernetes          MoKubernetes
-	sealedSecret          SealedSecretManager
-	argocd                argocd.Argocd
-	flux                  flux.Flux
-	alertmanager          AlertmanagerService
-	aiApi                 AiApi
-	aiWebsocketConnection ai.AiWebsocketConnection
+	patternHandlerLock       sync.RWMutex
+	patternHandler           map[string]PatternHandler
+	inflightLock             sync.Mutex
+	inflight                 map[string]*inflightRequest
+	httpService              HttpService
+	xtermService             XtermService
+	apiService               Api
+	moKubernetes             MoKubernetes
+	sealedSecret             SealedSecretManager
+	argocd                   argocd.Argocd
+	flux                     flux.Flux
+	alertmanager             AlertmanagerService
+	aiApi                    AiApi
+	aiWebsocketConnection    ai.AiWebsocketConnection
+	costEngine               CostEngine
+	gitOpsDriftDetector      GitOpsDriftDetector
+	gitOpsWriter             GitOpsWriter
+	notificationService      NotificationService
+	workloadRecommender      WorkloadRecommender
+	policyEngine             PolicyEngine
+	securityScanner          SecurityScanner
+	imageScanner             ImageScanner
+	upgradeReadinessChecker  UpgradeReadinessChecker
+	watchSubscriptionService WatchSubscriptionService
+	fileTransferService      FileTransferService
+	authorizer               *patternAuthorizer
 }
 
 type PatternHandler struct {
@@ -167,6 +220,11 @@ type PatternConfig struct {
 	Deprecated        bool   `json:"deprecated,omitempty"`
 	DeprecatedMessage string `json:"deprecatedMessage,omitempty"`
 	NeedsUser         bool   `json:"needsUser,omitempty"`
+	// Minimum Grant role the caller needs. Derived from the pattern name
+	// (see defaultPatternRole) when left empty.
+	RequiredRole PatternRole `json:"requiredRole,omitempty"`
+	// Which grants can authorize the pattern. Defaults to PatternScopeWorkspace.
+	Scope PatternScope `json:"scope,omitempty"`
 	// @readonly: do not set this manually
 	LegacyResponseLayout bool `json:"legacyResponseLayout,omitempty"`
 	// @readonly: do not set this manually
@@ -193,12 +251,14 @@ func NewSocketApi(
 	self.eventsClient = eventsClient
 	self.logger = logger
 	self.patternHandler = map[string]PatternHandler{}
+	self.inflight = map[string]*inflightRequest{}
 	self.valkeyClient = valkeyClient
 	self.status = NewSocketApiStatus()
 	self.statusLock = sync.RWMutex{}
 	self.argocd = argocd
 	self.flux = flux
 	self.alertmanager = alertmanager
+	self.authorizer = newPatternAuthorizer(logger, configModule)
 
 	self.loadpatternlogger()
 	self.registerPatterns()
@@ -215,6 +275,17 @@ func (self *socketApi) Link(
 	sealedSecret SealedSecretManager,
 	aiApi AiApi,
 	aiWebsocketConnection ai.AiWebsocketConnection,
+	costEngine CostEngine,
+	gitOpsDriftDetector GitOpsDriftDetector,
+	gitOpsWriter GitOpsWriter,
+	notificationService NotificationService,
+	workloadRecommender WorkloadRecommender,
+	policyEngine PolicyEngine,
+	securityScanner SecurityScanner,
+	imageScanner ImageScanner,
+	upgradeReadinessChecker UpgradeReadinessChecker,
+	watchSubscriptionService WatchSubscriptionService,
+	fileTransferService FileTransferService,
 ) {
 	assert.Assert(apiService != nil)
 	assert.Assert(httpService != nil)
@@ -222,6 +293,17 @@ func (self *socketApi) Link(
 	assert.Assert(dbstatsModule != nil)
 	assert.Assert(moKubernetes != nil)
 	assert.Assert(aiApi != nil)
+	assert.Assert(costEngine != nil)
+	assert.Assert(gitOpsDriftDetector != nil)
+	assert.Assert(gitOpsWriter != nil)
+	assert.Assert(notificationService != nil)
+	assert.Assert(workloadRecommender != nil)
+	assert.Assert(policyEngine != nil)
+	assert.Assert(securityScanner != nil)
+	assert.Assert(imageScanner != nil)
+	assert.Assert(upgradeReadinessChecker != nil)
+	assert.Assert(watchSubscriptionService != nil)
+	assert.Assert(fileTransferService != nil)
 
 	self.apiService = apiService
 	self.httpService = httpService
@@ -231,6 +313,17 @@ func (self *socketApi) Link(
 	self.sealedSecret = sealedSecret
 	self.aiApi = aiApi
 	self.aiWebsocketConnection = aiWebsocketConnection
+	self.costEngine = costEngine
+	self.gitOpsDriftDetector = gitOpsDriftDetector
+	self.gitOpsWriter = gitOpsWriter
+	self.notificationService = notificationService
+	self.workloadRecommender = workloadRecommender
+	self.policyEngine = policyEngine
+	self.securityScanner = securityScanner
+	self.imageScanner = imageScanner
+	self.upgradeReadinessChecker = upgradeReadinessChecker
+	self.watchSubscriptionService = watchSubscriptionService
+	self.fileTransferService = fileTransferService
 }
 
 func (self *socketApi) Run() {
@@ -311,25 +404,36 @@ func registerPatternHandlerInternal[RequestType any, ResponseType any](
 		// omitted by older operators -- consumers must keep their fallback.
 		StatusCode int          `json:"statusCode,omitempty"`
 		Data       ResponseType `json:"data"`
+		// Violations lists what the Policies found in the change: the reason
+		// of a rejection, or audit-only findings of a change that was written.
+		Violations []policy.Violation `json:"violations,omitempty"`
 	}
 
-	buildResponse := func(result any, err error) Result {
+	// violations are the ones recorded while the handler ran; audit ones
+	// are reported on success, too
+	buildResponse := func(result any, err error, violations []policy.Violation) Result {
 		if err != nil {
+			if len(violations) == 0 {
+				violations = policy.ViolationsFromError(err)
+			}
 			return Result{
 				Status:     "error",
 				Message:    err.Error(),
 				StatusCode: utils.HttpStatusForError(err),
+				Violations: violations,
 			}
 		}
 		if str, ok := result.(string); ok {
 			return Result{
-				Status:  "success",
-				Message: str,
+				Status:     "success",
+				Message:    str,
+				Violations: violations,
 			}
 		}
 		return Result{
-			Status: "success",
-			Data:   result.(ResponseType),
+			Status:     "success",
+			Data:       result.(ResponseType),
+			Violations: violations,
 		}
 	}
 
@@ -351,7 +455,7 @@ func registerPatternHandlerInternal[RequestType any, ResponseType any](
 			err := handle.SocketApi.LoadRequest(&datagram, &data)
 			if err != nil {
 				handle.SocketApi.GetLogger().Error("Error while loading request", "datagram", datagram, "error", err)
-				return buildResponse(nil, err)
+				return buildResponse(nil, err, nil)
 			}
 		}
 
@@ -359,13 +463,19 @@ func registerPatternHandlerInternal[RequestType any, ResponseType any](
 			err := handle.SocketApi.LoadRequest(&datagram, &data)
 			if err != nil {
 				handle.SocketApi.GetLogger().Error("Error while loading request", "datagram", datagram, "error", err)
-				return buildResponse(nil, err)
+				return buildResponse(nil, err, nil)
 			}
 		}
 
-		result, err := callback(datagram, data)
+		ctx, recorder := policy.WithRecorder(datagram.Context())
+		result, err := callback(datagram.WithContext(ctx), data)
+		if ctxErr := datagram.Context().Err(); err != nil && ctxErr != nil && errors.Is(err, ctxErr) {
+			// the handler gave up because the request was cancelled or
+			// reached its deadline
+			return abortedPatternResponse(ctxErr)
+		}
 
-		return buildResponse(result, err)
+		return buildResponse(result, err, recorder.Violations())
 	}, client)
 }
 
@@ -386,6 +496,14 @@ func (self *socketApi) AddPatternHandlerForClient(
 	assert.Assert(config.RequestSchema != nil, "config.RequestSchema has to be set")
 	assert.Assert(config.ResponseSchema != nil, "config.ResponseSchema has to be set")
 
+	if config.RequiredRole == "" {
+		config.RequiredRole = defaultPatternRole(pattern)
+	}
+	assert.Assert(config.RequiredRole.rank() > 0, "unknown required role", pattern, config.RequiredRole)
+	if config.Scope == "" {
+		config.Scope = PatternScopeWorkspace
+	}
+
 	self.patternHandlerLock.Lock()
 	defer self.patternHandlerLock.Unlock()
 
@@ -409,6 +527,14 @@ func (self *socketApi) PatternConfigs() map[string]PatternConfig {
 	return patterns
 }
 
+func (self *socketApi) PatternConfig(pattern string) (PatternConfig, bool) {
+	self.patternHandlerLock.RLock()
+	defer self.patternHandlerLock.RUnlock()
+
+	handler, ok := self.patternHandler[pattern]
+	return handler.Config, ok
+}
+
 func (self *socketApi) GetLogger() *slog.Logger {
 	return self.logger
 }
@@ -482,7 +608,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "UpgradeK8sManager"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (*structs.Job, error) {
 				job, err := self.upgradeK8sManager(request.Command)
 				return store.AddToAuditLog(datagram, self.logger, job, err, nil, nil)
@@ -492,7 +618,7 @@ func (self *socketApi) registerPatterns() {
 
 	RegisterPatternHandler(
 		PatternHandle{self, "cluster/force-reconnect"},
-		PatternConfig{},
+		PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 		func(datagram structs.Datagram, request Void) (bool, error) {
 			return kubernetes.ClusterForceReconnect(), nil
 		},
@@ -500,7 +626,7 @@ func (self *socketApi) registerPatterns() {
 
 	RegisterPatternHandler(
 		PatternHandle{self, "cluster/force-disconnect"},
-		PatternConfig{},
+		PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 		func(datagram structs.Datagram, request Void) (bool, error) {
 			return kubernetes.ClusterForceDisconnect(), nil
 		},
@@ -514,7 +640,7 @@ func (self *socketApi) registerPatterns() {
 		}
 		RegisterPatternHandler(
 			PatternHandle{self, "cluster/clear-valkey-cache"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				return self.valkeyClient.ClearNonEssentialKeys(request.IncludeTraffic, request.IncludePodStats, request.IncludeNodeStats)
 			},
@@ -597,6 +723,242 @@ func (self *socketApi) registerPatterns() {
 			},
 		)
 
+		RegisterPatternHandler(
+			PatternHandle{self, "stats/workspace-io-utilization"},
+			PatternConfig{},
+			func(datagram structs.Datagram, request Request) ([]WorkspaceIoChartEntry, error) {
+				resources, err := self.apiService.GetWorkspaceControllers(request.WorkspaceName)
+				if err != nil {
+					return nil, err
+				}
+				return self.dbstats.GetWorkspaceStatsIoUtilization(request.TimeOffsetMinutes, resources)
+			},
+		)
+
+	}
+
+	{
+		type Request struct {
+			// Exactly one of Namespace and WorkspaceName is required.
+			Namespace         string `json:"namespace"`
+			WorkspaceName     string `json:"workspaceName"`
+			TimeOffsetMinutes int    `json:"timeOffsetMinutes"`
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "stats/traffic/flow-graph"},
+			PatternConfig{},
+			func(datagram structs.Datagram, request Request) (*TrafficFlowGraph, error) {
+				if (request.Namespace == "") == (request.WorkspaceName == "") {
+					return nil, fmt.Errorf("exactly one of namespace and workspaceName is required")
+				}
+				namespaces := []string{request.Namespace}
+				if request.WorkspaceName != "" {
+					var err error
+					namespaces, err = self.apiService.GetWorkspaceNamespaces(request.WorkspaceName)
+					if err != nil {
+						return nil, err
+					}
+				}
+				return self.dbstats.GetTrafficFlowGraph(namespaces, request.TimeOffsetMinutes)
+			},
+		)
+	}
+
+	{
+		type Request struct {
+			WorkspaceName string `json:"workspaceName" validate:"required"`
+			CostQuery
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "stats/cost/workspace"},
+			PatternConfig{},
+			func(datagram structs.Datagram, request Request) (*CostReport, error) {
+				return self.costEngine.GetWorkspaceCost(request.WorkspaceName, request.CostQuery)
+			},
+		)
+	}
+
+	{
+		type Request struct {
+			Namespace string `json:"namespace" validate:"required"`
+			CostQuery
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "stats/cost/namespace"},
+			PatternConfig{},
+			func(datagram structs.Datagram, request Request) (*CostReport, error) {
+				return self.costEngine.GetNamespaceCost(request.Namespace, request.CostQuery)
+			},
+		)
+	}
+
+	{
+		type Request struct {
+			Namespace string `json:"namespace" validate:"required"`
+			Kind      string `json:"kind" validate:"required"`
+			Name      string `json:"name" validate:"required"`
+			CostQuery
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "stats/cost/workload"},
+			PatternConfig{},
+			func(datagram structs.Datagram, request Request) (*CostReport, error) {
+				return self.costEngine.GetWorkloadCost(request.Namespace, request.Kind, request.Name, request.CostQuery)
+			},
+		)
+	}
+
+	{
+		type Request struct {
+			WorkspaceName string `json:"workspaceName"`
+			WorkloadRecommendationQuery
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "get/workload-recommendations"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request Request) (*WorkloadRecommendationReport, error) {
+				workspaceName := request.WorkspaceName
+				if workspaceName == "" {
+					workspaceName = datagram.Workspace
+				}
+				if workspaceName == "" {
+					return nil, fmt.Errorf("workspaceName is required")
+				}
+				return self.workloadRecommender.GetWorkloadRecommendations(workspaceName, request.WorkloadRecommendationQuery)
+			},
+		)
+	}
+
+	{
+		type Request struct {
+			WorkspaceName string `json:"workspaceName"`
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "gitops/drift/list"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request Request) ([]GitOpsDriftEntry, error) {
+				workspaceName := request.WorkspaceName
+				if workspaceName == "" {
+					workspaceName = datagram.Workspace
+				}
+				return self.gitOpsDriftDetector.ListDrift(workspaceName)
+			},
+		)
+	}
+
+	{
+		RegisterPatternHandler(
+			PatternHandle{self, "security/findings/list"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request SecurityFindingsRequest) ([]security.ResourceFindings, error) {
+				if request.WorkspaceName == "" {
+					request.WorkspaceName = datagram.Workspace
+				}
+				return self.securityScanner.ListFindings(request)
+			},
+		)
+
+		RegisterPatternHandler(
+			PatternHandle{self, "security/findings/sarif"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request SecurityFindingsRequest) (security.SarifLog, error) {
+				if request.WorkspaceName == "" {
+					request.WorkspaceName = datagram.Workspace
+				}
+				return self.securityScanner.Sarif(request)
+			},
+		)
+
+		RegisterPatternHandler(
+			PatternHandle{self, "security/images/list"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request ImagesRequest) ([]security.Image, error) {
+				if request.WorkspaceName == "" {
+					request.WorkspaceName = datagram.Workspace
+				}
+				return self.imageScanner.ListImages(request)
+			},
+		)
+
+		RegisterPatternHandler(
+			PatternHandle{self, "security/images/vulnerabilities"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request ImagesRequest) ([]security.Image, error) {
+				if request.WorkspaceName == "" {
+					request.WorkspaceName = datagram.Workspace
+				}
+				return self.imageScanner.ListVulnerabilities(request)
+			},
+		)
+	}
+
+	RegisterPatternHandler(
+		PatternHandle{self, "cluster/upgrade-readiness"},
+		PatternConfig{Scope: PatternScopeDatagramWorkspace},
+		func(datagram structs.Datagram, request UpgradeReadinessRequest) (*deprecations.Report, error) {
+			if request.WorkspaceName == "" {
+				request.WorkspaceName = datagram.Workspace
+			}
+			return self.upgradeReadinessChecker.GetUpgradeReadiness(request)
+		},
+	)
+
+	{
+		RegisterPatternHandler(
+			PatternHandle{self, "watch/subscribe"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request WatchSubscribeRequest) (*WatchSubscribeResponse, error) {
+				if request.WorkspaceName == "" {
+					request.WorkspaceName = datagram.Workspace
+				}
+				return self.watchSubscriptionService.Subscribe(datagram.User, request)
+			},
+		)
+
+		type Request struct {
+			SubscriptionId string `json:"subscriptionId" validate:"required"`
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "watch/unsubscribe"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request Request) (Void, error) {
+				return nil, self.watchSubscriptionService.Unsubscribe(datagram.User, request.SubscriptionId)
+			},
+		)
+	}
+
+	{
+		RegisterPatternHandler(
+			PatternHandle{self, "notifications/deliveries/list"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request NotificationDeliveriesRequest) ([]notifications.Delivery, error) {
+				if request.WorkspaceName == "" {
+					request.WorkspaceName = datagram.Workspace
+				}
+				return self.notificationService.ListDeliveries(request)
+			},
+		)
+	}
+
+	{
+		type Request struct {
+			Name string `json:"name" validate:"required"`
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "notifications/channel/test"},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
+			func(datagram structs.Datagram, request Request) (*notifications.Delivery, error) {
+				return self.notificationService.SendTestNotification(request.Name)
+			},
+		)
 	}
 
 	// stats/pod/all-for-namespace — full per-pod CPU/memory snapshots for
@@ -793,7 +1155,7 @@ func (self *socketApi) registerPatterns() {
 			PatternHandle{self, "files/download"},
 			PatternConfig{},
 			func(datagram structs.Datagram, request Request) (services.FilesDownloadResponse, error) {
-				return services.Download(request.File, request.PostTo)
+				return services.Download(datagram.Context(), request.File, request.PostTo)
 			},
 		)
 	}
@@ -807,6 +1169,85 @@ func (self *socketApi) registerPatterns() {
 		},
 	)
 
+	{
+		// after the start only the caller's own upload id is sent
+		uploadConfig := PatternConfig{RequiredRole: uploadStartConfig.RequiredRole, Scope: PatternScopeDatagramWorkspace}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "files/upload/start"},
+			uploadStartConfig,
+			func(datagram structs.Datagram, request FileUploadStartRequest) (*FileUploadStatus, error) {
+				return self.fileTransferService.StartUpload(datagram.User, request)
+			},
+		)
+
+		RegisterPatternHandler(
+			PatternHandle{self, "files/upload/chunk"},
+			uploadConfig,
+			func(datagram structs.Datagram, request FileUploadChunkRequest) (*FileUploadStatus, error) {
+				return self.fileTransferService.UploadChunk(datagram.User, request)
+			},
+		)
+
+		type Request struct {
+			UploadId string `json:"uploadId" validate:"required"`
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "files/upload/status"},
+			uploadConfig,
+			func(datagram structs.Datagram, request Request) (*FileUploadStatus, error) {
+				return self.fileTransferService.UploadStatus(datagram.User, request.UploadId)
+			},
+		)
+
+		RegisterPatternHandler(
+			PatternHandle{self, "files/upload/finish"},
+			uploadConfig,
+			func(datagram structs.Datagram, request Request) (*FileUploadStatus, error) {
+				return self.fileTransferService.FinishUpload(datagram.User, request.UploadId)
+			},
+		)
+
+		RegisterPatternHandler(
+			PatternHandle{self, "files/upload/abort"},
+			uploadConfig,
+			func(datagram structs.Datagram, request Request) (Void, error) {
+				return nil, self.fileTransferService.AbortUpload(datagram.User, request.UploadId)
+			},
+		)
+	}
+
+	{
+		RegisterPatternHandler(
+			PatternHandle{self, "files/download/start"},
+			PatternConfig{},
+			func(datagram structs.Datagram, request FileDownloadStartRequest) (*FileDownloadStatus, error) {
+				return self.fileTransferService.StartDownload(datagram.Context(), datagram.User, request)
+			},
+		)
+
+		RegisterPatternHandler(
+			PatternHandle{self, "files/download/chunk"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request FileDownloadChunkRequest) (*FileDownloadChunk, error) {
+				return self.fileTransferService.DownloadChunk(datagram.Context(), datagram.User, request)
+			},
+		)
+
+		type Request struct {
+			DownloadId string `json:"downloadId" validate:"required"`
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "files/download/finish"},
+			PatternConfig{Scope: PatternScopeDatagramWorkspace},
+			func(datagram structs.Datagram, request Request) (Void, error) {
+				return nil, self.fileTransferService.FinishDownload(datagram.User, request.DownloadId)
+			},
+		)
+	}
+
 	RegisterPatternHandler(
 		PatternHandle{self, "prometheus/query"},
 		PatternConfig{},
@@ -971,7 +1412,7 @@ func (self *socketApi) registerPatterns() {
 
 	RegisterPatternHandler(
 		PatternHandle{self, "cluster/helm-repo-add"},
-		PatternConfig{},
+		PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 		func(datagram structs.Datagram, request helm.HelmRepoAddRequest) (string, error) {
 			result, err := helm.HelmRepoAdd(request)
 			return store.AddToAuditLog(datagram, self.logger, result, err, nil, nil)
@@ -980,7 +1421,7 @@ func (self *socketApi) registerPatterns() {
 
 	RegisterPatternHandler(
 		PatternHandle{self, "cluster/helm-repo-patch"},
-		PatternConfig{},
+		PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 		func(datagram structs.Datagram, request helm.HelmRepoPatchRequest) (string, error) {
 			result, err := helm.HelmRepoPatch(request)
 			return store.AddToAuditLog(datagram, self.logger, result, err, nil, nil)
@@ -1024,7 +1465,8 @@ func (self *socketApi) registerPatterns() {
 		PatternHandle{self, "cluster/helm-chart-install"},
 		PatternConfig{},
 		func(datagram structs.Datagram, request helm.HelmChartInstallUpgradeRequest) (string, error) {
-			res, err := helm.HelmChartInstall(request)
+			request.ManifestCheck = manifestPolicyCheck(self.policyEngine, datagram, v1alpha1.PolicyOperationCreate, request.Namespace)
+			res, err := helm.HelmChartInstall(datagram.Context(), request)
 			return store.AddToAuditLog(datagram, self.logger, res, err, nil, nil)
 		},
 	)
@@ -1033,7 +1475,8 @@ func (self *socketApi) registerPatterns() {
 		PatternHandle{self, "cluster/helm-chart-install-oci"},
 		PatternConfig{},
 		func(datagram structs.Datagram, request helm.HelmChartOciInstallUpgradeRequest) (string, error) {
-			res, err := helm.HelmOciInstall(request)
+			request.ManifestCheck = manifestPolicyCheck(self.policyEngine, datagram, v1alpha1.PolicyOperationCreate, request.Namespace)
+			res, err := helm.HelmOciInstall(datagram.Context(), request)
 			return store.AddToAuditLog(datagram, self.logger, res, err, nil, nil)
 		},
 	)
@@ -1066,7 +1509,8 @@ func (self *socketApi) registerPatterns() {
 		PatternHandle{self, "cluster/helm-release-upgrade"},
 		PatternConfig{},
 		func(datagram structs.Datagram, request helm.HelmChartInstallUpgradeRequest) (string, error) {
-			res, err := helm.HelmReleaseUpgrade(request)
+			request.ManifestCheck = manifestPolicyCheck(self.policyEngine, datagram, v1alpha1.PolicyOperationUpdate, request.Namespace)
+			res, err := helm.HelmReleaseUpgrade(datagram.Context(), request)
 			return store.AddToAuditLog(datagram, self.logger, res, err, nil, nil)
 		},
 	)
@@ -1075,7 +1519,7 @@ func (self *socketApi) registerPatterns() {
 		PatternHandle{self, "cluster/helm-release-uninstall"},
 		PatternConfig{},
 		func(datagram structs.Datagram, request helm.HelmReleaseUninstallRequest) (string, error) {
-			res, err := helm.HelmReleaseUninstall(request)
+			res, err := helm.HelmReleaseUninstall(datagram.Context(), request)
 			return store.AddToAuditLog(datagram, self.logger, res, err, nil, nil)
 		},
 	)
@@ -1176,7 +1620,7 @@ func (self *socketApi) registerPatterns() {
 		PatternHandle{self, "cluster/helm-release-rollback"},
 		PatternConfig{},
 		func(datagram structs.Datagram, request helm.HelmReleaseRollbackRequest) (string, error) {
-			result, err := helm.HelmReleaseRollback(request)
+			result, err := helm.HelmReleaseRollback(datagram.Context(), request)
 			return store.AddToAuditLog(datagram, self.logger, result, err, nil, nil)
 		},
 	)
@@ -1208,7 +1652,7 @@ func (self *socketApi) registerPatterns() {
 
 	RegisterPatternHandler(
 		PatternHandle{self, "cluster/argo-cd-create-api-token"},
-		PatternConfig{},
+		PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 		func(datagram structs.Datagram, request argocd.ArgoCdCreateApiTokenRequest) (bool, error) {
 			result, err := self.argocd.ArgoCdCreateApiToken(request)
 			return store.AddToAuditLog(datagram, self.logger, result, err, nil, nil)
@@ -1310,7 +1754,7 @@ func (self *socketApi) registerPatterns() {
 
 	RegisterPatternHandler(
 		PatternHandle{self, "service/port-forward-connection-request"},
-		PatternConfig{},
+		PatternConfig{RequiredRole: PatternRoleEditor},
 		func(datagram structs.Datagram, request xterm.PortForwardConnectionRequest) (Void, error) {
 			go xterm.PortForwardStreamConnection(request)
 			// Same treatment as exec-sh below: interactive cluster access
@@ -1325,7 +1769,7 @@ func (self *socketApi) registerPatterns() {
 
 	RegisterPatternHandler(
 		PatternHandle{self, "service/exec-sh-connection-request"},
-		PatternConfig{},
+		PatternConfig{RequiredRole: PatternRoleEditor},
 		func(datagram structs.Datagram, request xterm.PodCmdConnectionRequest) (Void, error) {
 			go self.execShConnection(request)
 			_, err := store.AddToAuditLog(datagram, self.logger, any(nil), nil, nil, nil)
@@ -1421,6 +1865,13 @@ func (self *socketApi) registerPatterns() {
 		PatternHandle{self, "create/new-workload"},
 		PatternConfig{},
 		func(datagram structs.Datagram, request utils.WorkloadChangeRequest) (*unstructured.Unstructured, error) {
+			var obj *unstructured.Unstructured
+			if err := yaml.Unmarshal([]byte(request.YamlData), &obj); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal YAML data: %w", err)
+			}
+			if err := checkPolicies(self.policyEngine, datagram, v1alpha1.PolicyOperationCreate, obj, nil); err != nil {
+				return store.AddToAuditLog(datagram, self.logger, obj, err, nil, obj)
+			}
 			createdRes, err := kubernetes.CreateUnstructuredResource(request.ApiVersion, request.Plural, request.Namespaced, request.YamlData)
 			return store.AddToAuditLog(datagram, self.logger, createdRes, err, nil, createdRes)
 		},
@@ -1446,15 +1897,25 @@ func (self *socketApi) registerPatterns() {
 	RegisterPatternHandler(
 		PatternHandle{self, "update/workload"},
 		PatternConfig{},
-		func(datagram structs.Datagram, request utils.WorkloadChangeRequest) (*unstructured.Unstructured, error) {
+		func(datagram structs.Datagram, request utils.WorkloadChangeRequest) (*WorkloadUpdateResult, error) {
 			var updatedObj *unstructured.Unstructured
 			err := yaml.Unmarshal([]byte(request.YamlData), &updatedObj)
 			if err != nil {
 				return nil, fmt.Errorf("failed to unmarshal YAML data: %w", err)
 			}
 			oldObj, _ := kubernetes.GetUnstructuredResourceFromStore(request.ApiVersion, request.Kind, updatedObj.GetNamespace(), updatedObj.GetName())
-			updatedRes, err := kubernetes.UpdateUnstructuredResource(request.ApiVersion, request.Plural, request.Namespaced, request.YamlData)
-			return store.AddToAuditLog(datagram, self.logger, updatedRes, err, oldObj, updatedRes)
+			if err := checkPolicies(self.policyEngine, datagram, v1alpha1.PolicyOperationUpdate, updatedObj, oldObj); err != nil {
+				return store.AddToAuditLog(datagram, self.logger, &WorkloadUpdateResult{Object: updatedObj}, err, oldObj, updatedObj)
+			}
+
+			// ArgoCD and Flux would revert a change made in the cluster
+			writeBack, err := self.gitOpsWriter.WriteBack(datagram, oldObj, updatedObj)
+			if err != nil || writeBack != nil {
+				return store.AddToAuditLog(datagram, self.logger, &WorkloadUpdateResult{Object: updatedObj, GitOpsWriteBack: writeBack}, err, oldObj, updatedObj)
+			}
+
+			updatedRes, err := kubernetes.UpdateUnstructuredResource(datagram.Context(), request.ApiVersion, request.Plural, request.Namespaced, request.YamlData)
+			return store.AddToAuditLog(datagram, self.logger, &WorkloadUpdateResult{Object: updatedRes}, err, oldObj, updatedRes)
 		},
 	)
 
@@ -1605,13 +2066,15 @@ func (self *socketApi) registerPatterns() {
 			DisplayName  string                                 `json:"displayName"`
 			Resources    []v1alpha1.WorkspaceResourceIdentifier `json:"resources" validate:"required"`
 			DashboardRef string                                 `json:"dashboardRef"`
+			Quota        *v1alpha1.WorkspaceQuota               `json:"quota"`
 		}
 
 		RegisterPatternHandler(
 			PatternHandle{self, "create/workspace"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				spec := v1alpha1.NewWorkspaceSpec(request.DisplayName, request.Resources, request.DashboardRef)
+				spec.Quota = request.Quota
 				res, err := self.apiService.CreateWorkspace(request.Name, spec)
 				var created *unstructured.Unstructured
 				if err == nil {
@@ -1644,7 +2107,7 @@ func (self *socketApi) registerPatterns() {
 				if err != nil || workspace == nil {
 					return nil, err
 				}
-				result := NewGetWorkspaceResult(workspace.Name, workspace.CreationTimestamp, workspace.Spec.Resources, workspace.Spec.DashboardRef)
+				result := NewGetWorkspaceResult(workspace.Name, workspace.CreationTimestamp, workspace.Spec.Resources, workspace.Spec.DashboardRef, workspace.Spec.Quota, workspace.Status.Conditions)
 				return &result, nil
 			},
 		)
@@ -1665,7 +2128,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "workspace/clean-up"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin},
 			func(datagram structs.Datagram, request Request) (CleanUpResult, error) {
 				result, err := self.moKubernetes.CleanUp(
 					self.apiService,
@@ -1689,26 +2152,30 @@ func (self *socketApi) registerPatterns() {
 	}
 
 	{
-		// DisplayName and DashboardRef are pointers to tell "field absent" apart
-		// from "explicitly cleared": callers send partial updates, and an absent
-		// field must keep its current value instead of wiping it.
+		// DisplayName, DashboardRef and Quota are pointers to tell "field absent"
+		// apart from "explicitly cleared": callers send partial updates, and an
+		// absent field must keep its current value instead of wiping it. An empty
+		// quota object removes the quota.
 		type Request struct {
 			Name         string                                 `json:"name" validate:"required"`
 			DisplayName  *string                                `json:"displayName"`
 			Resources    []v1alpha1.WorkspaceResourceIdentifier `json:"resources" validate:"required"`
 			DashboardRef *string                                `json:"dashboardRef"`
+			Quota        *v1alpha1.WorkspaceQuota               `json:"quota"`
 		}
 
 		RegisterPatternHandler(
 			PatternHandle{self, "update/workspace"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				oldWorkspace, _ := store.GetWorkspace(self.config.Get("MO_OWN_NAMESPACE"), request.Name)
 				displayName := ""
 				dashboardRef := ""
+				var quota *v1alpha1.WorkspaceQuota
 				if oldWorkspace != nil {
 					displayName = oldWorkspace.Spec.Name
 					dashboardRef = oldWorkspace.Spec.DashboardRef
+					quota = oldWorkspace.Spec.Quota
 				}
 				if request.DisplayName != nil {
 					displayName = *request.DisplayName
@@ -1716,7 +2183,14 @@ func (self *socketApi) registerPatterns() {
 				if request.DashboardRef != nil {
 					dashboardRef = *request.DashboardRef
 				}
+				if request.Quota != nil {
+					quota = request.Quota
+					if equality.Semantic.DeepEqual(*quota, v1alpha1.WorkspaceQuota{}) {
+						quota = nil
+					}
+				}
 				spec := v1alpha1.NewWorkspaceSpec(displayName, request.Resources, dashboardRef)
+				spec.Quota = quota
 				res, err := self.apiService.UpdateWorkspace(request.Name, spec)
 				var oldObj, newObj *unstructured.Unstructured
 				if oldWorkspace != nil {
@@ -1737,7 +2211,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "delete/workspace"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				oldWorkspace, _ := store.GetWorkspace(self.config.Get("MO_OWN_NAMESPACE"), request.Name)
 				res, err := self.apiService.DeleteWorkspace(request.Name)
@@ -1771,7 +2245,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "create/user"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				spec := v1alpha1.NewUserSpec(request.FirstName, request.LastName, request.Email, request.Subject)
 				res, err := self.apiService.CreateUser(request.Name, spec)
@@ -1812,7 +2286,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "update/user"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				spec := v1alpha1.NewUserSpec(request.FirstName, request.LastName, request.Email, request.Subject)
 				oldUser, _ := self.apiService.GetUser(request.Name)
@@ -1836,7 +2310,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "delete/user"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				oldUser, _ := self.apiService.GetUser(request.Name)
 				res, err := self.apiService.DeleteUser(request.Name)
@@ -1872,7 +2346,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "create/grant"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				spec := v1alpha1.NewGrantSpec(request.Grantee, request.TargetType, request.TargetName, request.Role)
 				res, err := self.apiService.CreateGrant(request.Name, spec)
@@ -1913,7 +2387,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "update/grant"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				spec := v1alpha1.NewGrantSpec(request.Grantee, request.TargetType, request.TargetName, request.Role)
 				oldGrant, _ := self.apiService.GetGrant(request.Name)
@@ -1937,7 +2411,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "delete/grant"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				oldGrant, _ := self.apiService.GetGrant(request.Name)
 				res, err := self.apiService.DeleteGrant(request.Name)
@@ -1946,6 +2420,99 @@ func (self *socketApi) registerPatterns() {
 		)
 	}
 
+	{
+		type Request struct {
+			Workspace *string `json:"workspace"`
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "get/workspace-backups"},
+			PatternConfig{RequiredRole: PatternRoleViewer, Scope: PatternScopeCluster},
+			func(datagram structs.Datagram, request Request) ([]v1alpha1.WorkspaceBackup, error) {
+				return self.apiService.GetAllWorkspaceBackups(request.Workspace)
+			},
+		)
+	}
+
+	{
+		type Request struct {
+			Name                    string                 `json:"name" validate:"required"`
+			Workspace               string                 `json:"workspace" validate:"required"`
+			Storage                 v1alpha1.BackupStorage `json:"storage"`
+			SnapshotVolumes         bool                   `json:"snapshotVolumes"`
+			VolumeSnapshotClassName string                 `json:"volumeSnapshotClassName"`
+			ExcludedKinds           []string               `json:"excludedKinds"`
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "create/workspace-backup"},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
+			func(datagram structs.Datagram, request Request) (string, error) {
+				spec := v1alpha1.WorkspaceBackupSpec{
+					Workspace:               request.Workspace,
+					Storage:                 request.Storage,
+					SnapshotVolumes:         request.SnapshotVolumes,
+					VolumeSnapshotClassName: request.VolumeSnapshotClassName,
+					ExcludedKinds:           request.ExcludedKinds,
+				}
+				res, err := self.apiService.CreateWorkspaceBackup(request.Name, spec)
+				var created *unstructured.Unstructured
+				if err == nil {
+					created = crdToAuditObject(&v1alpha1.WorkspaceBackup{
+						ObjectMeta: metav1.ObjectMeta{Name: request.Name},
+						Spec:       spec,
+					}, "WorkspaceBackup", request.Name)
+				}
+				return store.AddToAuditLog(datagram, self.logger, res, err, nil, created)
+			},
+		)
+	}
+
+	{
+		type Request struct {
+			Name string `json:"name" validate:"required"`
+		}
+
+		RegisterPatternHandler(
+			PatternHandle{self, "delete/workspace-backup"},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
+			func(datagram structs.Datagram, request Request) (string, error) {
+				oldBackup, _ := store.GetWorkspaceBackup(self.config.Get("MO_OWN_NAMESPACE"), request.Name)
+				res, err := self.apiService.DeleteWorkspaceBackup(request.Name)
+				return store.AddToAuditLog(datagram, self.logger, res, err, crdToAuditObject(oldBackup, "WorkspaceBackup", request.Name), nil)
+			},
+		)
+	}
+
+	{
+		type Request struct {
+			Name              string            `json:"name" validate:"required"`
+			NamespaceMapping  map[string]string `json:"namespaceMapping"`
+			DryRun            bool              `json:"dryRun"`
+			Overwrite         bool              `json:"overwrite"`
+			RestoreVolumeData bool              `json:"restoreVolumeData"`
+		}
+
+		// Restores may create namespaces and overwrite resources outside the
+		// workspace the backup was taken from, hence the cluster scope.
+		RegisterPatternHandler(
+			PatternHandle{self, "workspace/backup-restore"},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
+			func(datagram structs.Datagram, request Request) (*backup.RestoreResult, error) {
+				result, err := self.apiService.RestoreWorkspaceBackup(datagram.Context(), request.Name, backup.RestoreOptions{
+					NamespaceMapping:  request.NamespaceMapping,
+					DryRun:            request.DryRun,
+					Overwrite:         request.Overwrite,
+					RestoreVolumeData: request.RestoreVolumeData,
+				})
+				if request.DryRun {
+					return result, err
+				}
+				return store.AddToAuditLog(datagram, self.logger, result, err, nil, nil)
+			},
+		)
+	}
+
 	{
 		type Request struct {
 			WorkspaceName      string                      `json:"workspaceName"`
@@ -2015,7 +2582,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "aiManager/inject-prompt-config"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(_ structs.Datagram, request Request) (Void, error) {
 				// Filters in the payload are tolerated for backward
 				// compatibility but no longer drive any task creation —
@@ -2164,7 +2731,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "create/aimodel"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				request.Spec = ai.NormalizeAiModelSpec(request.Spec)
 				var res string
@@ -2190,7 +2757,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "update/aimodel"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				request.Spec = ai.NormalizeAiModelSpec(request.Spec)
 				oldModel, _ := store.GetAiModel(self.config.Get("MO_OWN_NAMESPACE"), request.Name)
@@ -2221,7 +2788,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "delete/aimodel"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				oldModel, _ := store.GetAiModel(self.config.Get("MO_OWN_NAMESPACE"), request.Name)
 				res, err := self.apiService.DeleteAiModel(request.Name)
@@ -2361,7 +2928,7 @@ func (self *socketApi) registerPatterns() {
 
 		RegisterPatternHandler(
 			PatternHandle{self, "reset/aimodel-usage"},
-			PatternConfig{NeedsUser: true},
+			PatternConfig{NeedsUser: true, RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Request) (string, error) {
 				// GitOps-native: request the reset by bumping the model's
 				// reset-usage annotation; the AiModel reconciler performs the
@@ -2375,7 +2942,7 @@ func (self *socketApi) registerPatterns() {
 	{
 		RegisterPatternHandler(
 			PatternHandle{self, "aiManager/delete-all-data"},
-			PatternConfig{},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
 			func(datagram structs.Datagram, request Void) (Void, error) {
 				err := self.aiApi.DeleteAllAiData()
 				return store.AddToAuditLog[Void](datagram, self.logger, nil, err, nil, nil)
@@ -2424,6 +2991,42 @@ func (self *socketApi) registerPatterns() {
 		)
 	}
 
+	{
+		type Request struct {
+			RunId string `json:"runId" validate:"required"`
+		}
+
+		// The replayable bundle of a finished run: initial conversation,
+		// every model response and every full tool result.
+		RegisterPatternHandler(
+			PatternHandle{self, "aiManager/export/run"},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
+			func(datagram structs.Datagram, request Request) (*ai.AiRunBundle, error) {
+				return self.aiApi.ExportRun(request.RunId)
+			},
+		)
+	}
+
+	{
+		type Request struct {
+			Bundle                 ai.AiRunBundle        `json:"bundle"`
+			Options                ai.AiRunReplayOptions `json:"options"`
+			UseCurrentSystemPrompt bool                  `json:"useCurrentSystemPrompt"`
+		}
+
+		// Replays an exported bundle without calling a model or touching the
+		// cluster, e.g. to check a new system prompt against recorded runs.
+		RegisterPatternHandler(
+			PatternHandle{self, "aiManager/replay/run"},
+			PatternConfig{RequiredRole: PatternRoleAdmin, Scope: PatternScopeCluster},
+			func(datagram structs.Datagram, request Request) (*ai.AiRunReplayReport, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+				return self.aiApi.ReplayRun(ctx, request.Bundle, request.Options, request.UseCurrentSystemPrompt)
+			},
+		)
+	}
+
 	{
 		RegisterPatternHandler(
 			PatternHandle{self, "aiManager/detail/tasks"},
@@ -2484,7 +3087,7 @@ func (self *socketApi) registerPatterns() {
 		PatternHandle{self, "storage/create-volume"},
 		PatternConfig{},
 		func(datagram structs.Datagram, request services.NfsVolumeRequest) (bool, error) {
-			res := services.CreateMogeniusNfsVolume(self.eventsClient, request)
+			res := services.CreateMogeniusNfsVolume(self.eventsClient, self.notificationService.NotifyJobFailed, request)
 			var resErr error
 			if !res.Success {
 				resErr = fmt.Errorf("%s", res.Error)
@@ -2505,7 +3108,7 @@ func (self *socketApi) registerPatterns() {
 		PatternHandle{self, "storage/delete-volume"},
 		PatternConfig{},
 		func(datagram structs.Datagram, request services.NfsVolumeRequest) (bool, error) {
-			res := services.DeleteMogeniusNfsVolume(self.eventsClient, request)
+			res := services.DeleteMogeniusNfsVolume(self.eventsClient, self.notificationService.NotifyJobFailed, request)
 			var resErr error
 			if !res.Success {
 				resErr = fmt.Errorf("%s", res.Error)
@@ -2651,6 +3254,15 @@ func (self *socketApi) registerPatterns() {
 		},
 	)
 
+	RegisterPatternHandler(
+		PatternHandle{self, "live-stream/pod-io"},
+		PatternConfig{},
+		func(datagram structs.Datagram, request xterm.WsConnectionRequest) (Void, error) {
+			go self.xtermService.LiveStreamConnection(request, datagram, self.httpService, self.valkeyClient, []string{request.PodName})
+			return nil, nil
+		},
+	)
+
 	RegisterPatternHandler(
 		PatternHandle{self, "live-stream/workspace-cpu"},
 		PatternConfig{},
@@ -2915,9 +3527,14 @@ func (self *socketApi) startClientReadLoop(client websocket.WebsocketClient) {
 
 			datagram.DisplayReceiveSummary(self.logger)
 
+			if datagram.Pattern == cancelPattern {
+				self.cancelRequest(client, datagram)
+				continue
+			}
+
 			// Blocks when all workers are busy. This backpressures the read
 			// loop instead of spawning unbounded goroutines.
-			jobs <- datagram
+			jobs <- self.startRequest(datagram)
 		}
 		self.logger.Debug("client messagehandler finished as the websocket client was terminated")
 	}()
@@ -2937,6 +3554,7 @@ func (self *socketApi) dispatchClientMessages(client websocket.WebsocketClient,
 		} else {
 			self.sendNoHandlerError(client, datagram, true)
 		}
+		self.finishRequest(datagram)
 	}
 }
 
@@ -2990,6 +3608,8 @@ func (self *socketApi) startJobClientReadLoop() {
 			if len(message) == 0 {
 				continue
 			}
+			// Deprecated: the START_UPLOAD/END_UPLOAD frames allow one upload at a
+			// time per connection; use files/upload/start instead.
 			if bytes.HasPrefix(message, []byte("######START_UPLOAD######;")) {
 				preparedFileName = new(fmt.Sprintf("/tmp/%s.zip", utils.NanoId()))
 				openFile, err = os.OpenFile(*preparedFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
@@ -3048,16 +3668,28 @@ func (self *socketApi) startJobClientReadLoop() {
 
 			// TODO: refactor! @bene
 			if datagram.Pattern == "files/upload" {
-				preparedFileRequest = self.executeBinaryRequestUpload(datagram)
-
 				var ack = structs.CreateDatagramAck("ack:files/upload:datagram", datagram.Id)
+				if err := self.authorizer.Authorize(datagram, uploadStartConfig); err != nil {
+					self.logger.Warn("pattern request denied", "pattern", datagram.Pattern, "user", datagram.User.Email, "workspace", datagram.Workspace, "error", err)
+					// without a prepared request the following upload frames
+					// are discarded at END_UPLOAD
+					preparedFileRequest = nil
+					ack.Err = err.Error()
+				} else {
+					preparedFileRequest = self.executeBinaryRequestUpload(datagram)
+				}
 				go self.JobServerSendData(self.jobClients[0], ack)
 				continue
 			}
 
+			if datagram.Pattern == cancelPattern {
+				self.cancelRequest(self.jobClients[0], datagram)
+				continue
+			}
+
 			// Blocks when all workers are busy (backpressures the read loop
 			// instead of spawning unbounded goroutines).
-			jobs <- datagram
+			jobs <- self.startRequest(datagram)
 		}
 		self.logger.Debug("api messagehandler finished as the websocket client was terminated")
 	}()
@@ -3074,7 +3706,93 @@ func (self *socketApi) dispatchJobClientMessages(jobs <-chan structs.Datagram) {
 		} else {
 			self.sendNoHandlerError(client, datagram, false)
 		}
+		self.finishRequest(datagram)
+	}
+}
+
+// startRequest gives a datagram read from a job client the context its
+// handler runs with and tracks it until finishRequest, so a `cancel`
+// datagram can abort it while it waits for a worker or runs. The deadline
+// of `timeoutMs` counts from here.
+func (self *socketApi) startRequest(datagram structs.Datagram) structs.Datagram {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if datagram.TimeoutMs > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(datagram.TimeoutMs)*time.Millisecond)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	self.inflightLock.Lock()
+	defer self.inflightLock.Unlock()
+	if _, exists := self.inflight[datagram.Id]; exists {
+		self.logger.Warn("request id is already in flight, the earlier request can no longer be cancelled", "pattern", datagram.Pattern, "id", datagram.Id)
+	}
+	self.inflight[datagram.Id] = &inflightRequest{user: datagram.User.Email, ctx: ctx, cancel: cancel}
+
+	return datagram.WithContext(ctx)
+}
+
+// finishRequest releases the context of a request once it is answered.
+func (self *socketApi) finishRequest(datagram structs.Datagram) {
+	self.inflightLock.Lock()
+	defer self.inflightLock.Unlock()
+	request, ok := self.inflight[datagram.Id]
+	if !ok || request.ctx != datagram.Context() {
+		return
+	}
+	request.cancel()
+	delete(self.inflight, datagram.Id)
+}
+
+// cancelRequest handles a `cancel` datagram. Only the user who sent a
+// request can cancel it. The answer tells whether a request was cancelled;
+// the cancelled request itself is answered with statusCode 499 once its
+// handler gives up, or with its result if the handler ignores the context.
+func (self *socketApi) cancelRequest(client websocket.WebsocketClient, datagram structs.Datagram) {
+	var request struct {
+		Id string `json:"id" validate:"required"`
+	}
+	err := self.LoadRequest(&datagram, &request)
+	statusCode := utils.HttpStatusForError(err)
+	cancelled := false
+	if err == nil {
+		self.inflightLock.Lock()
+		inflight, ok := self.inflight[request.Id]
+		switch {
+		case !ok:
+		case !strings.EqualFold(inflight.user, datagram.User.Email):
+			err = fmt.Errorf("request '%s' belongs to another user", request.Id)
+			statusCode = http.StatusForbidden
+		default:
+			inflight.cancel()
+			cancelled = true
+		}
+		self.inflightLock.Unlock()
+	}
+	if cancelled {
+		self.logger.Info("cancelled pattern request", "id", request.Id, "user", datagram.User.Email)
 	}
+
+	type Data struct {
+		Cancelled bool `json:"cancelled"`
+	}
+	type Result struct {
+		Status     string `json:"status"`
+		Message    string `json:"message,omitempty"`
+		StatusCode int    `json:"statusCode,omitempty"`
+		Data       Data   `json:"data"`
+	}
+	result := Result{Status: "success", Data: Data{Cancelled: cancelled}}
+	if err != nil {
+		result = Result{Status: "error", Message: err.Error(), StatusCode: statusCode}
+	}
+	go self.JobServerSendData(client, structs.Datagram{
+		Id:        datagram.Id,
+		Pattern:   datagram.Pattern,
+		Payload:   result,
+		CreatedAt: datagram.CreatedAt,
+	})
 }
 
 // clientName returns a human-readable label for the given client, used in logs.
@@ -3279,9 +3997,36 @@ func (self *socketApi) JobServerSendData(jobClient websocket.WebsocketClient, da
 
 func (self *socketApi) ExecuteCommandRequest(datagram structs.Datagram) any {
 	if patternHandler, ok := self.patternHandler[datagram.Pattern]; ok {
+		if err := self.authorizer.Authorize(datagram, patternHandler.Config); err != nil {
+			self.logger.Warn("pattern request denied", "pattern", datagram.Pattern, "user", datagram.User.Email, "workspace", datagram.Workspace, "error", err)
+			return struct {
+				Status     string `json:"status"`
+				Message    string `json:"message"`
+				StatusCode int    `json:"statusCode"`
+			}{
+				Status:     "error",
+				Message:    err.Error(),
+				StatusCode: utils.HttpStatusForError(err),
+			}
+		}
 		start := time.Now()
-		result := patternHandler.Callback(datagram)
-		moMetrics.ObservePatternDuration(datagram.Pattern, time.Since(start).Seconds())
+		var result any
+		var outcome string
+		if self.isIdempotent(datagram, patternHandler.Config) {
+			var ran bool
+			result, outcome, ran = self.executeIdempotent(datagram, func() (any, string) {
+				return runPatternHandler(patternHandler, datagram)
+			})
+			if !ran {
+				return result
+			}
+		} else {
+			result, outcome = runPatternHandler(patternHandler, datagram)
+		}
+		moMetrics.ObservePatternDuration(datagram.Pattern, outcome, time.Since(start).Seconds())
+		if outcome != moMetrics.PatternOutcomeCompleted {
+			self.logger.Info("pattern request aborted", "pattern", datagram.Pattern, "id", datagram.Id, "outcome", outcome)
+		}
 		return result
 	}
 
@@ -3294,8 +4039,48 @@ func (self *socketApi) ExecuteCommandRequest(datagram structs.Datagram) any {
 	}
 }
 
+// runPatternHandler runs the handler on the calling worker, which stays busy
+// until the handler returns, also after the request was cancelled or reached
+// its deadline. The request only counts as aborted if that happened before a
+// worker picked it up, or if the handler gave up because its context ended.
+// Handlers that ignore the context complete, and their result is sent.
+func runPatternHandler(handler PatternHandler, datagram structs.Datagram) (any, string) {
+	if err := datagram.Context().Err(); err != nil {
+		// cancelled or expired while waiting for a worker
+		aborted := abortedPatternResponse(err)
+		return aborted, aborted.outcome()
+	}
+	result := handler.Callback(datagram)
+	if aborted, ok := result.(abortedPatternResult); ok {
+		return aborted, aborted.outcome()
+	}
+	return result, moMetrics.PatternOutcomeCompleted
+}
+
+// abortedPatternResult is the answer to a request that was cancelled
+// (statusCode 499) or reached its deadline (statusCode 504).
+type abortedPatternResult struct {
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"statusCode"`
+}
+
+func abortedPatternResponse(err error) abortedPatternResult {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return abortedPatternResult{Status: "error", Message: "request reached its deadline", StatusCode: http.StatusGatewayTimeout}
+	}
+	return abortedPatternResult{Status: "error", Message: "request was cancelled", StatusCode: utils.StatusClientClosedRequest}
+}
+
+func (self abortedPatternResult) outcome() string {
+	if self.StatusCode == http.StatusGatewayTimeout {
+		return moMetrics.PatternOutcomeTimeout
+	}
+	return moMetrics.PatternOutcomeCancelled
+}
+
 func (self *socketApi) upgradeK8sManager(command string) (*structs.Job, error) {
-	job := structs.CreateJob(self.eventsClient, "Upgrade mogenius platform", "UPGRADE", "", "", self.logger)
+	job := structs.CreateJob(self.eventsClient, "Upgrade mogenius platform", "UPGRADE", "", "", self.logger, self.notificationService.NotifyJobFailed)
 	job.Start(self.eventsClient)
 
 	autoUpgradeEnabled, _ := self.config.TryGetBool("MO_ENABLE_AUTO_UPGRADE")
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	moMetrics "mogenius-operator/src/metrics"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/websocket"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCancelClient records the answers to cancel datagrams.
+type fakeCancelClient struct {
+	websocket.WebsocketClient
+	sent chan []byte
+}
+
+func (self *fakeCancelClient) WriteRaw(data []byte) error {
+	self.sent <- data
+	return nil
+}
+
+func TestRunPatternHandler(t *testing.T) {
+	self := &socketApi{logger: slog.New(slog.DiscardHandler), patternHandler: map[string]PatternHandler{}}
+	RegisterPatternHandler(PatternHandle{self, "test/honours-context"}, PatternConfig{}, func(datagram structs.Datagram, request Void) (string, error) {
+		<-datagram.Context().Done()
+		return "", fmt.Errorf("waiting: %w", datagram.Context().Err())
+	})
+	handler := self.patternHandler["test/honours-context"]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	result, outcome := runPatternHandler(handler, structs.Datagram{}.WithContext(ctx))
+	assert.Equal(t, moMetrics.PatternOutcomeCancelled, outcome)
+	payload, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"error","message":"request was cancelled","statusCode":499}`, string(payload))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	result, outcome = runPatternHandler(handler, structs.Datagram{}.WithContext(ctx))
+	assert.Equal(t, moMetrics.PatternOutcomeTimeout, outcome)
+	payload, err = json.Marshal(result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"error","message":"request reached its deadline","statusCode":504}`, string(payload))
+
+	ran := false
+	_, outcome = runPatternHandler(PatternHandler{Callback: func(structs.Datagram) any {
+		ran = true
+		return nil
+	}}, structs.Datagram{}.WithContext(ctx))
+	assert.Equal(t, moMetrics.PatternOutcomeTimeout, outcome)
+	assert.False(t, ran, "requests that expired while queued do not run")
+
+	// handlers that ignore the context are waited for and their result counts
+	ctx, cancel = context.WithCancel(context.Background())
+	result, outcome = runPatternHandler(PatternHandler{Callback: func(structs.Datagram) any {
+		cancel()
+		return "done"
+	}}, structs.Datagram{}.WithContext(ctx))
+	assert.Equal(t, moMetrics.PatternOutcomeCompleted, outcome)
+	assert.Equal(t, "done", result)
+
+	result, outcome = runPatternHandler(PatternHandler{Callback: func(structs.Datagram) any {
+		return "done"
+	}}, structs.Datagram{})
+	assert.Equal(t, moMetrics.PatternOutcomeCompleted, outcome)
+	assert.Equal(t, "done", result)
+}
+
+func TestCancelRequest(t *testing.T) {
+	self := &socketApi{logger: slog.New(slog.DiscardHandler), inflight: map[string]*inflightRequest{}}
+	client := &fakeCancelClient{sent: make(chan []byte, 1)}
+	owner := structs.User{Email: "dev@example.com"}
+
+	cancelDatagram := func(user structs.User, id string) map[string]any {
+		self.cancelRequest(client, structs.Datagram{Id: "c1", Pattern: cancelPattern, User: user, Payload: map[string]any{"id": id}})
+		var answer struct {
+			Pattern string         `json:"pattern"`
+			Payload map[string]any `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(<-client.sent, &answer))
+		assert.Equal(t, cancelPattern, answer.Pattern)
+		return answer.Payload
+	}
+
+	request := self.startRequest(structs.Datagram{Id: "r1", Pattern: "files/download", User: owner, TimeoutMs: 60000})
+	deadline, ok := request.Context().Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	answer := cancelDatagram(structs.User{Email: "other@example.com"}, "r1")
+	assert.Equal(t, "error", answer["status"])
+	assert.Equal(t, float64(http.StatusForbidden), answer["statusCode"])
+	assert.NoError(t, request.Context().Err(), "only the sender cancels a request")
+
+	answer = cancelDatagram(owner, "r1")
+	assert.Equal(t, map[string]any{"cancelled": true}, answer["data"])
+	assert.ErrorIs(t, request.Context().Err(), context.Canceled)
+
+	self.finishRequest(request)
+	assert.Empty(t, self.inflight)
+	answer = cancelDatagram(owner, "r1")
+	assert.Equal(t, map[string]any{"cancelled": false}, answer["data"], "answered requests are no longer in flight")
+}
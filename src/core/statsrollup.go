@@ -0,0 +1,366 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/networkmonitor"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/valkeyclient"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Rollup streams hold one structs.StatsRollupEntry per window as
+// `<bucket>:<tier>:<series...>`, e.g. `pod-stats-rollup:15m:<ns>:<controller>`.
+const (
+	DB_STATS_POD_STATS_ROLLUP_BUCKET_NAME  = "pod-stats-rollup"
+	DB_STATS_TRAFFIC_ROLLUP_BUCKET_NAME    = "traffic-stats-rollup"
+	DB_STATS_NODE_STATS_ROLLUP_BUCKET_NAME = "node-stats-rollup"
+)
+
+// statsMaxChartPoints bounds the entries a stats query reads per series: the
+// finest resolution that answers the query with at most this many entries is
+// used (1440 = one day of raw per-minute samples).
+const statsMaxChartPoints = 1440
+
+// statsMaxTimeOffsetMinutes is the longest window the workspace charts serve.
+const statsMaxTimeOffsetMinutes = 60 * 24 * 30 // 30 days
+
+// statsRollupTier is one downsampled resolution with its own retention.
+type statsRollupTier struct {
+	name       string
+	resolution time.Duration
+	retention  time.Duration
+}
+
+// statsRollupTiersFromConfig returns the rollup tiers, finest first.
+func statsRollupTiersFromConfig(config cfg.ConfigModule) []statsRollupTier {
+	tiers := []statsRollupTier{
+		{name: "1m", resolution: time.Minute},
+		{name: "15m", resolution: 15 * time.Minute},
+		{name: "1h", resolution: time.Hour},
+	}
+	for i := range tiers {
+		key := "MO_STATS_ROLLUP_RETENTION_" + strings.ToUpper(tiers[i].name)
+		retention, err := time.ParseDuration(config.Get(key))
+		assert.Assert(err == nil, "config validation should not let an invalid duration pass", key, err)
+		tiers[i].retention = retention
+	}
+	return tiers
+}
+
+// selectStatsRollupTier picks the source of a query over the last offset:
+// the raw per-minute streams (nil) while they still cover the offset within
+// statsMaxChartPoints, otherwise the finest tier that does. Offsets beyond
+// every tier get the coarsest one, which returns what it still has.
+func selectStatsRollupTier(offset time.Duration, rawRetention time.Duration, tiers []statsRollupTier) *statsRollupTier {
+	if offset <= rawRetention && offset <= statsMaxChartPoints*time.Minute {
+		return nil
+	}
+	for i := range tiers {
+		if offset <= tiers[i].retention && offset <= statsMaxChartPoints*tiers[i].resolution {
+			return &tiers[i]
+		}
+	}
+	return &tiers[len(tiers)-1]
+}
+
+func (self *valkeyStatsDb) statsRollupTierFor(timeOffsetMinutes int64) *statsRollupTier {
+	return selectStatsRollupTier(time.Duration(timeOffsetMinutes)*time.Minute, rawStatsRetention(valkeyclient.MAX_RETENTION_TIME, valkeyclient.MAX_RETENTION_SIZE), self.rollups.tiers)
+}
+
+// rawStatsRetention is how far back the per-minute raw streams reach: they
+// are trimmed by age and by entry count, whichever cuts first.
+func rawStatsRetention(retention time.Duration, maxEntries int64) time.Duration {
+	return min(retention, time.Duration(maxEntries)*time.Minute)
+}
+
+type statsRollupWindow struct {
+	tier    statsRollupTier
+	keys    []string
+	start   time.Time
+	samples map[string][]float64
+}
+
+// statsRollups accumulates the samples of every series per tier and hands a
+// window to store once it has passed. The open windows live in memory only:
+// a restart loses their samples, so the first window after it is partial.
+type statsRollups struct {
+	lock    sync.Mutex
+	tiers   []statsRollupTier
+	windows map[string]*statsRollupWindow
+	due     []*statsRollupWindow
+	store   func(entry structs.StatsRollupEntry, tier statsRollupTier, keys []string) error
+}
+
+func newStatsRollups(tiers []statsRollupTier, store func(entry structs.StatsRollupEntry, tier statsRollupTier, keys []string) error) *statsRollups {
+	return &statsRollups{tiers: tiers, windows: map[string]*statsRollupWindow{}, store: store}
+}
+
+// add records one sample of a series, e.g. the summed usage of all pods of a
+// workload at one point in time.
+func (self *statsRollups) add(bucket string, series []string, at time.Time, values map[string]float64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	for _, tier := range self.tiers {
+		keys := slices.Concat([]string{bucket, tier.name}, series)
+		id := strings.Join(keys, ":")
+		start := at.Truncate(tier.resolution)
+		window, ok := self.windows[id]
+		if ok && !window.start.Equal(start) {
+			self.due = append(self.due, window)
+			ok = false
+		}
+		if !ok {
+			window = &statsRollupWindow{tier: tier, keys: keys, start: start, samples: map[string][]float64{}}
+			self.windows[id] = window
+		}
+		for metric, value := range values {
+			window.samples[metric] = append(window.samples[metric], value)
+		}
+	}
+}
+
+// flush stores every window that ended before now.
+func (self *statsRollups) flush(now time.Time) error {
+	self.lock.Lock()
+	due := self.due
+	self.due = nil
+	for id, window := range self.windows {
+		if !window.start.Add(window.tier.resolution).After(now) {
+			due = append(due, window)
+			delete(self.windows, id)
+		}
+	}
+	self.lock.Unlock()
+
+	// stream ids must grow per key
+	slices.SortFunc(due, func(a, b *statsRollupWindow) int { return a.start.Compare(b.start) })
+	var errs []error
+	for _, window := range due {
+		entry := structs.StatsRollupEntry{Start: window.start, Metrics: make(map[string]structs.StatsRollup, len(window.samples))}
+		for metric, samples := range window.samples {
+			entry.Metrics[metric] = structs.NewStatsRollup(samples)
+		}
+		if err := self.store(entry, window.tier, window.keys); err != nil {
+			errs = append(errs, fmt.Errorf("error storing %s rollup: %w", strings.Join(window.keys, ":"), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (self *valkeyStatsDb) storeStatsRollup(entry structs.StatsRollupEntry, tier statsRollupTier, keys []string) error {
+	return self.valkey.StoreSortedListEntryWithRetention(entry, entry.Start.UnixMilli(), tier.retention, keys...)
+}
+
+func (self *valkeyStatsDb) flushStatsRollups() {
+	if err := self.rollups.flush(time.Now()); err != nil {
+		self.logger.Error("failed to store stats rollups", "error", err)
+	}
+}
+
+func (self *valkeyStatsDb) getStatsRollupEntries(tier *statsRollupTier, timeOffsetMinutes int64, bucket string, series ...string) ([]structs.StatsRollupEntry, error) {
+	return valkeyclient.GetObjectsFromSortedListWithDuration[structs.StatsRollupEntry](
+		self.valkey,
+		timeOffsetMinutes,
+		slices.Concat([]string{bucket, tier.name}, series)...,
+	)
+}
+
+// addPodStatsRollups records one sample per workload: the sum over all of its
+// containers. The raw stream only keeps the first container per minute.
+func (self *valkeyStatsDb) addPodStatsRollups(stats []structs.PodStats) {
+	type workloadKey struct{ namespace, name string }
+	byWorkload := map[workloadKey][]structs.PodStats{}
+	for _, stat := range stats {
+		controller := self.ownerCacheService.ControllerForPod(stat.Namespace, stat.PodName)
+		if controller == nil {
+			continue
+		}
+		key := workloadKey{stat.Namespace, controller.ResourceName}
+		byWorkload[key] = append(byWorkload[key], stat)
+	}
+	now := time.Now()
+	for key, workloadStats := range byWorkload {
+		self.rollups.add(DB_STATS_POD_STATS_ROLLUP_BUCKET_NAME, []string{key.namespace, key.name}, now, podStatsRollupSample(workloadStats))
+	}
+	self.flushStatsRollups()
+}
+
+// podStatsRollupSample sums the containers of one workload into one sample.
+// "pods" counts the replicas the sum spans, so readers can derive the usage
+// of a single replica.
+func podStatsRollupSample(stats []structs.PodStats) map[string]float64 {
+	sample := map[string]float64{}
+	pods := map[string]bool{}
+	for _, stat := range stats {
+		pods[stat.PodName] = true
+		sample["cpu"] += float64(stat.Cpu)
+		sample["cpuLimit"] += float64(stat.CpuLimit)
+		sample["memory"] += float64(stat.Memory)
+		sample["memoryLimit"] += float64(stat.MemoryLimit)
+		sample["ephemeralStorage"] += float64(stat.EphemeralStorage)
+		sample["ephemeralStorageLimit"] += float64(stat.EphemeralStorageLimit)
+	}
+	sample["pods"] = float64(len(pods))
+	return sample
+}
+
+// podStatsRollupPerReplica divides the workload sums of a pod stats rollup by
+// the window's average replica count. Raw entries cover a single pod, so this
+// keeps the raw and the rollup tiers on the same scale; "pods" stays as is.
+func podStatsRollupPerReplica(entry structs.StatsRollupEntry) structs.StatsRollupEntry {
+	pods := entry.Metrics["pods"].Avg
+	if pods <= 0 {
+		return entry
+	}
+	result := structs.StatsRollupEntry{Start: entry.Start, Metrics: make(map[string]structs.StatsRollup, len(entry.Metrics))}
+	for metric, rollup := range entry.Metrics {
+		if metric != "pods" {
+			rollup.Min /= pods
+			rollup.Avg /= pods
+			rollup.Max /= pods
+			rollup.P95 /= pods
+		}
+		result.Metrics[metric] = rollup
+	}
+	return result
+}
+
+// podStatsFromRollup turns a pod stats rollup into one PodStats entry carrying
+// the window averages of one replica of the workload.
+func podStatsFromRollup(entry structs.StatsRollupEntry, namespace string, workload string) structs.PodStats {
+	entry = podStatsRollupPerReplica(entry)
+	return structs.PodStats{
+		Namespace:             namespace,
+		PodName:               workload,
+		Cpu:                   int64(entry.Metrics["cpu"].Avg),
+		CpuLimit:              int64(entry.Metrics["cpuLimit"].Avg),
+		Memory:                int64(entry.Metrics["memory"].Avg),
+		MemoryLimit:           int64(entry.Metrics["memoryLimit"].Avg),
+		EphemeralStorage:      int64(entry.Metrics["ephemeralStorage"].Avg),
+		EphemeralStorageLimit: int64(entry.Metrics["ephemeralStorageLimit"].Avg),
+		CreatedAt:             entry.Start,
+		Rollup:                entry.Metrics,
+	}
+}
+
+// trafficStatsFromRollups sums the per-node traffic rollups of a workload per
+// window. The counters are per-minute averages, like the raw deltas.
+func trafficStatsFromRollups(entries []structs.StatsRollupEntry, namespace string, workload string) []networkmonitor.PodNetworkStats {
+	byWindow := map[time.Time]*networkmonitor.PodNetworkStats{}
+	for _, entry := range entries {
+		stat, ok := byWindow[entry.Start]
+		if !ok {
+			stat = &networkmonitor.PodNetworkStats{Pod: workload, Namespace: namespace, CreatedAt: entry.Start}
+			byWindow[entry.Start] = stat
+		}
+		stat.ReceivedBytes += uint64(entry.Metrics["receivedBytes"].Avg)
+		stat.TransmitBytes += uint64(entry.Metrics["transmitBytes"].Avg)
+		stat.ReceivedPackets += uint64(entry.Metrics["receivedPackets"].Avg)
+		stat.TransmitPackets += uint64(entry.Metrics["transmitPackets"].Avg)
+	}
+	result := make([]networkmonitor.PodNetworkStats, 0, len(byWindow))
+	for _, start := range slices.SortedFunc(maps.Keys(byWindow), time.Time.Compare) {
+		result = append(result, *byWindow[start])
+	}
+	return result
+}
+
+// rollupSeriesEntries are the rollups of one series of a controller.
+type rollupSeriesEntries struct {
+	controller string
+	entries    []structs.StatsRollupEntry
+}
+
+// getWorkspaceRollupChart sums the window averages of metrics over all
+// controllers and, for per-node series, all nodes. Pod stats count one replica
+// per controller, like the raw charts. Pods holds the top 5
+// controllers since rollups carry no pod detail.
+func (self *valkeyStatsDb) getWorkspaceRollupChart(
+	tier *statsRollupTier,
+	timeOffsetInMinutes int,
+	resources []unstructured.Unstructured,
+	bucket string,
+	perNode bool,
+	metrics ...string,
+) []GenericChartEntry {
+	var nodes []string
+	if perNode {
+		for _, node := range store.GetNodes() {
+			nodes = append(nodes, node.Name)
+		}
+	}
+
+	var collected []rollupSeriesEntries
+	var resultMutex sync.Mutex
+	wg := sync.WaitGroup{}
+	for _, controller := range resources {
+		ns, name := controller.GetNamespace(), controller.GetName()
+		series := [][]string{{ns, name}}
+		if perNode {
+			series = series[:0]
+			for _, node := range nodes {
+				series = append(series, []string{ns, name, node})
+			}
+		}
+		for _, s := range series {
+			wg.Go(func() {
+				entries, err := self.getStatsRollupEntries(tier, int64(timeOffsetInMinutes), bucket, s...)
+				if err != nil {
+					self.logger.Error("failed to fetch stats rollups from valkey", "bucket", bucket, "tier", tier.name, "series", s, "error", err)
+					return
+				}
+				if bucket == DB_STATS_POD_STATS_ROLLUP_BUCKET_NAME {
+					for i := range entries {
+						entries[i] = podStatsRollupPerReplica(entries[i])
+					}
+				}
+				resultMutex.Lock()
+				collected = append(collected, rollupSeriesEntries{name, entries})
+				resultMutex.Unlock()
+			})
+		}
+	}
+	wg.Wait()
+
+	return aggregateRollupChart(collected, metrics)
+}
+
+// aggregateRollupChart sums the metrics per window; a controller's per-node
+// series add up before it competes for the top 5.
+func aggregateRollupChart(collected []rollupSeriesEntries, metrics []string) []GenericChartEntry {
+	byWindow := map[time.Time]map[string]float64{}
+	for _, series := range collected {
+		for _, entry := range series.entries {
+			controllers, ok := byWindow[entry.Start]
+			if !ok {
+				controllers = map[string]float64{}
+				byWindow[entry.Start] = controllers
+			}
+			for _, metric := range metrics {
+				controllers[series.controller] += entry.Metrics[metric].Avg
+			}
+		}
+	}
+
+	result := make([]GenericChartEntry, 0, len(byWindow))
+	for _, start := range slices.SortedFunc(maps.Keys(byWindow), time.Time.Compare) {
+		chartEntry := GenericChartEntry{Time: start, Pods: map[string]float64{}}
+		for controller, value := range byWindow[start] {
+			chartEntry.Value += value
+			chartEntry.Pods = updateTop5Pods(chartEntry.Pods, value, controller)
+		}
+		result = append(result, chartEntry)
+	}
+	return result
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"mogenius-operator/src/structs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStatsRollup(t *testing.T) {
+	samples := make([]float64, 0, 20)
+	for i := 20; i >= 1; i-- {
+		samples = append(samples, float64(i))
+	}
+	assert.Equal(t, structs.StatsRollup{Min: 1, Avg: 10.5, Max: 20, P95: 19, Count: 20}, structs.NewStatsRollup(samples))
+	assert.Equal(t, structs.StatsRollup{Min: 7, Avg: 7, Max: 7, P95: 7, Count: 1}, structs.NewStatsRollup([]float64{7}))
+	assert.Equal(t, structs.StatsRollup{}, structs.NewStatsRollup(nil))
+}
+
+func TestSelectStatsRollupTier(t *testing.T) {
+	tiers := []statsRollupTier{
+		{name: "1m", resolution: time.Minute, retention: 48 * time.Hour},
+		{name: "15m", resolution: 15 * time.Minute, retention: 14 * 24 * time.Hour},
+		{name: "1h", resolution: time.Hour, retention: 45 * 24 * time.Hour},
+	}
+	tierName := func(offset time.Duration, rawRetention time.Duration) string {
+		tier := selectStatsRollupTier(offset, rawRetention, tiers)
+		if tier == nil {
+			return "raw"
+		}
+		return tier.name
+	}
+
+	assert.Equal(t, "raw", tierName(time.Hour, 24*time.Hour))
+	assert.Equal(t, "raw", tierName(24*time.Hour, 24*time.Hour))
+	// raw retention lowered to save memory: the compact 1m tier takes over
+	assert.Equal(t, "1m", tierName(12*time.Hour, 6*time.Hour))
+	assert.Equal(t, "15m", tierName(2*24*time.Hour, 24*time.Hour))
+	assert.Equal(t, "15m", tierName(14*24*time.Hour, 24*time.Hour))
+	assert.Equal(t, "1h", tierName(30*24*time.Hour, 24*time.Hour))
+	// beyond every retention the coarsest tier serves what it has
+	assert.Equal(t, "1h", tierName(90*24*time.Hour, 24*time.Hour))
+
+	// MO_STATS_RETENTION_MAX_ENTRIES cuts the raw streams before their age does
+	assert.Equal(t, 6*time.Hour, rawStatsRetention(24*time.Hour, 360))
+	assert.Equal(t, 24*time.Hour, rawStatsRetention(24*time.Hour, 10800))
+	assert.Equal(t, "1m", tierName(12*time.Hour, rawStatsRetention(24*time.Hour, 360)))
+}
+
+func TestStatsRollupsFlushClosedWindows(t *testing.T) {
+	type stored struct {
+		key   string
+		entry structs.StatsRollupEntry
+	}
+	var written []stored
+	rollups := newStatsRollups(
+		[]statsRollupTier{
+			{name: "1m", resolution: time.Minute},
+			{name: "15m", resolution: 15 * time.Minute},
+		},
+		func(entry structs.StatsRollupEntry, tier statsRollupTier, keys []string) error {
+			written = append(written, stored{strings.Join(keys, ":"), entry})
+			return nil
+		},
+	)
+
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 16 {
+		at := start.Add(time.Duration(i)*time.Minute + 5*time.Second)
+		rollups.add(DB_STATS_POD_STATS_ROLLUP_BUCKET_NAME, []string{"prod", "api"}, at, map[string]float64{"cpu": float64(100 * (i + 1))})
+		require.NoError(t, rollups.flush(at))
+	}
+
+	var oneMinute, fifteenMinutes []stored
+	for _, s := range written {
+		switch {
+		case strings.HasPrefix(s.key, "pod-stats-rollup:1m:prod:api"):
+			oneMinute = append(oneMinute, s)
+		case strings.HasPrefix(s.key, "pod-stats-rollup:15m:prod:api"):
+			fifteenMinutes = append(fifteenMinutes, s)
+		}
+	}
+	// the window of the last sample is still open
+	require.Len(t, oneMinute, 15)
+	assert.Equal(t, start, oneMinute[0].entry.Start)
+	assert.Equal(t, structs.StatsRollup{Min: 100, Avg: 100, Max: 100, P95: 100, Count: 1}, oneMinute[0].entry.Metrics["cpu"])
+
+	require.Len(t, fifteenMinutes, 1)
+	assert.Equal(t, start, fifteenMinutes[0].entry.Start)
+	assert.Equal(t, structs.StatsRollup{Min: 100, Avg: 800, Max: 1500, P95: 1500, Count: 15}, fifteenMinutes[0].entry.Metrics["cpu"])
+
+	// a series that stops reporting is flushed once its window passed
+	written = nil
+	require.NoError(t, rollups.flush(start.Add(time.Hour)))
+	assert.Len(t, written, 2)
+}
+
+func TestAggregateRollupChart(t *testing.T) {
+	window := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	entry := func(start time.Time, received, transmitted float64) structs.StatsRollupEntry {
+		return structs.StatsRollupEntry{Start: start, Metrics: map[string]structs.StatsRollup{
+			"receivedBytes": {Avg: received},
+			"transmitBytes": {Avg: transmitted},
+		}}
+	}
+	chart := aggregateRollupChart([]rollupSeriesEntries{
+		{controller: "api", entries: []structs.StatsRollupEntry{entry(window, 100, 50), entry(window.Add(time.Hour), 10, 0)}},
+		// the same controller on another node adds up before the top 5
+		{controller: "api", entries: []structs.StatsRollupEntry{entry(window, 20, 30)}},
+		{controller: "db", entries: []structs.StatsRollupEntry{entry(window, 1, 1)}},
+	}, []string{"receivedBytes", "transmitBytes"})
+
+	require.Len(t, chart, 2)
+	assert.Equal(t, window, chart[0].Time)
+	assert.Equal(t, 202.0, chart[0].Value)
+	assert.Equal(t, map[string]float64{"api": 200, "db": 2}, chart[0].Pods)
+	assert.Equal(t, 10.0, chart[1].Value)
+}
+
+func TestTrafficStatsFromRollups(t *testing.T) {
+	window := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	stats := trafficStatsFromRollups([]structs.StatsRollupEntry{
+		{Start: window.Add(15 * time.Minute), Metrics: map[string]structs.StatsRollup{"receivedBytes": {Avg: 5}}},
+		{Start: window, Metrics: map[string]structs.StatsRollup{"receivedBytes": {Avg: 100}, "transmitPackets": {Avg: 2}}},
+		{Start: window, Metrics: map[string]structs.StatsRollup{"receivedBytes": {Avg: 50}}},
+	}, "prod", "api")
+
+	require.Len(t, stats, 2)
+	assert.Equal(t, window, stats[0].CreatedAt)
+	assert.Equal(t, uint64(150), stats[0].ReceivedBytes)
+	assert.Equal(t, uint64(2), stats[0].TransmitPackets)
+	assert.Equal(t, "api", stats[0].Pod)
+	assert.Equal(t, uint64(5), stats[1].ReceivedBytes)
+}
+
+func TestPodStatsRollupRoundTrip(t *testing.T) {
+	sample := podStatsRollupSample([]structs.PodStats{
+		{PodName: "api-0", Cpu: 100, Memory: 1 << 20, CpuLimit: 500},
+		{PodName: "api-1", Cpu: 300, Memory: 3 << 20, CpuLimit: 500},
+	})
+	assert.Equal(t, 400.0, sample["cpu"])
+	assert.Equal(t, 1000.0, sample["cpuLimit"])
+	assert.Equal(t, 2.0, sample["pods"])
+
+	window := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	metrics := map[string]structs.StatsRollup{}
+	for metric, value := range sample {
+		metrics[metric] = structs.NewStatsRollup([]float64{value})
+	}
+	stat := podStatsFromRollup(structs.StatsRollupEntry{Start: window, Metrics: metrics}, "prod", "api")
+	assert.Equal(t, int64(200), stat.Cpu, "one replica, like a raw entry")
+	assert.Equal(t, int64(2<<20), stat.Memory)
+	assert.Equal(t, int64(500), stat.CpuLimit)
+	assert.Equal(t, 2.0, stat.Rollup["pods"].Avg)
+	assert.Equal(t, "api", stat.PodName)
+	assert.Equal(t, "prod", stat.Namespace)
+	assert.Equal(t, window, stat.CreatedAt)
+	assert.NotNil(t, stat.Rollup)
+}
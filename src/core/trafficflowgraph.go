@@ -0,0 +1,279 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"mogenius-operator/src/networkmonitor"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/utils"
+	"mogenius-operator/src/valkeyclient"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const DB_STATS_TRAFFIC_FLOWS_BUCKET_NAME = "traffic-flows"
+
+const (
+	TrafficFlowNodeWorkload = "workload"
+	TrafficFlowNodeService  = "service"
+	TrafficFlowNodeNode     = "node"
+	TrafficFlowNodeExternal = "external"
+)
+
+// TrafficFlowGraph is the service dependency map of a namespace or workspace:
+// workloads as nodes, observed TCP flows between them (and to services,
+// cluster nodes and external addresses) as edges.
+type TrafficFlowGraph struct {
+	Nodes []TrafficFlowGraphNode `json:"nodes"`
+	Edges []TrafficFlowGraphEdge `json:"edges"`
+}
+
+type TrafficFlowGraphNode struct {
+	// Id is "<kind>/<namespace>/<name>", "Node/<name>" or "External/<ip>".
+	Id        string `json:"id"`
+	Type      string `json:"type"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+type TrafficFlowGraphEdge struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Port     uint16 `json:"port"`
+	Protocol string `json:"protocol"`
+	// Connections is the highest number of concurrently open connections
+	// seen in one minute of the queried window.
+	Connections uint64 `json:"connections"`
+	// TransmitBytes and ReceivedBytes (seen from the source) are the highest
+	// conntrack counters seen in one minute; 0 without conntrack accounting.
+	TransmitBytes uint64    `json:"transmitBytes"`
+	ReceivedBytes uint64    `json:"receivedBytes"`
+	LastSeen      time.Time `json:"lastSeen"`
+}
+
+func (self *valkeyStatsDb) AddPodNetworkFlowsToDb(nodeName string, flows []networkmonitor.PodNetworkFlow) error {
+	byNamespace := map[string][]networkmonitor.PodNetworkFlow{}
+	for _, flow := range flows {
+		namespace := flow.Namespace
+		// Namespace is redundant in the entry (it's already part of the stream key).
+		flow.Namespace = ""
+		byNamespace[namespace] = append(byNamespace[namespace], flow)
+	}
+	now := time.Now()
+	for namespace, namespaceFlows := range byNamespace {
+		err := self.valkey.StoreSortedListEntry(
+			networkmonitor.PodNetworkFlows{Node: nodeName, Flows: namespaceFlows, CreatedAt: now},
+			now.Truncate(time.Minute).Unix(),
+			DB_STATS_TRAFFIC_FLOWS_BUCKET_NAME, namespace, nodeName,
+		)
+		if err != nil {
+			return fmt.Errorf("error adding traffic flows for namespace %s: %w", namespace, err)
+		}
+	}
+	return nil
+}
+
+func (self *valkeyStatsDb) GetTrafficFlowGraph(namespaces []string, timeOffsetMinutes int) (*TrafficFlowGraph, error) {
+	// Clamp to valid range
+	if timeOffsetMinutes <= 0 {
+		timeOffsetMinutes = 15
+	}
+	timeOffsetMinutes = min(timeOffsetMinutes, 60*24)
+
+	nodes := store.GetNodes()
+	samples := map[string][]networkmonitor.PodNetworkFlows{}
+	var samplesMutex sync.Mutex
+	wg := sync.WaitGroup{}
+	for _, namespace := range namespaces {
+		for _, node := range nodes {
+			wg.Go(func() {
+				values, err := valkeyclient.GetObjectsFromSortedListWithDuration[networkmonitor.PodNetworkFlows](
+					self.valkey,
+					int64(timeOffsetMinutes),
+					DB_STATS_TRAFFIC_FLOWS_BUCKET_NAME, namespace, node.Name,
+				)
+				if err != nil {
+					self.logger.Error("failed to fetch traffic flows from valkey", "namespace", namespace, "node", node.Name, "error", err)
+					return
+				}
+				samplesMutex.Lock()
+				samples[namespace] = append(samples[namespace], values...)
+				samplesMutex.Unlock()
+			})
+		}
+	}
+	wg.Wait()
+
+	endpoints := newTrafficEndpointIndex(store.GetPods("*"), store.GetServices("*", "*"), nodes, self.workloadForPod)
+	return buildTrafficFlowGraph(samples, endpoints, self.workloadForPod), nil
+}
+
+func (self *valkeyStatsDb) workloadForPod(namespace string, podName string) TrafficFlowGraphNode {
+	controller := self.ownerCacheService.ControllerForPod(namespace, podName)
+	if controller == nil {
+		controller = &utils.WorkloadSingleRequest{
+			ResourceDescriptor: utils.PodResource,
+			ResourceName:       podName,
+			Namespace:          namespace,
+		}
+	}
+	return TrafficFlowGraphNode{
+		Id:        controller.Kind + "/" + namespace + "/" + controller.ResourceName,
+		Type:      TrafficFlowNodeWorkload,
+		Kind:      controller.Kind,
+		Namespace: namespace,
+		Name:      controller.ResourceName,
+	}
+}
+
+// trafficEndpointIndex resolves a peer IP to the graph node it belongs to.
+type trafficEndpointIndex map[string]TrafficFlowGraphNode
+
+func newTrafficEndpointIndex(
+	pods []v1.Pod,
+	services []v1.Service,
+	nodes []v1.Node,
+	workloadForPod func(namespace string, podName string) TrafficFlowGraphNode,
+) trafficEndpointIndex {
+	index := trafficEndpointIndex{}
+	for _, pod := range pods {
+		// host network pods share the node address, which resolves to the node
+		if pod.Spec.HostNetwork {
+			continue
+		}
+		for _, podIp := range pod.Status.PodIPs {
+			index[podIp.IP] = workloadForPod(pod.Namespace, pod.Name)
+		}
+	}
+	for _, service := range services {
+		for _, clusterIp := range service.Spec.ClusterIPs {
+			if clusterIp == "" || clusterIp == v1.ClusterIPNone {
+				continue
+			}
+			index[clusterIp] = TrafficFlowGraphNode{
+				Id:        utils.ServiceResource.Kind + "/" + service.Namespace + "/" + service.Name,
+				Type:      TrafficFlowNodeService,
+				Kind:      utils.ServiceResource.Kind,
+				Namespace: service.Namespace,
+				Name:      service.Name,
+			}
+		}
+	}
+	for _, node := range nodes {
+		for _, address := range node.Status.Addresses {
+			if address.Type != v1.NodeInternalIP && address.Type != v1.NodeExternalIP {
+				continue
+			}
+			index[address.Address] = TrafficFlowGraphNode{
+				Id:   "Node/" + node.Name,
+				Type: TrafficFlowNodeNode,
+				Kind: "Node",
+				Name: node.Name,
+			}
+		}
+	}
+	return index
+}
+
+func (self trafficEndpointIndex) resolve(ip string) TrafficFlowGraphNode {
+	if node, ok := self[ip]; ok {
+		return node
+	}
+	return TrafficFlowGraphNode{Id: "External/" + ip, Type: TrafficFlowNodeExternal, Name: ip}
+}
+
+// buildTrafficFlowGraph turns the flow samples of the queried namespaces into
+// a graph. Egress flows always become edges. Ingress flows only do when the
+// peer is not a workload of the queried namespaces: those connections are
+// already known from the peer's own egress flows.
+func buildTrafficFlowGraph(
+	samples map[string][]networkmonitor.PodNetworkFlows,
+	endpoints trafficEndpointIndex,
+	workloadForPod func(namespace string, podName string) TrafficFlowGraphNode,
+) *TrafficFlowGraph {
+	type edgeKey struct {
+		source, target string
+		port           uint16
+		protocol       string
+	}
+	type edgeMinute struct {
+		edgeKey
+		minute time.Time
+	}
+	nodes := map[string]TrafficFlowGraphNode{}
+	perMinute := map[edgeMinute]*TrafficFlowGraphEdge{}
+
+	for namespace, namespaceSamples := range samples {
+		for _, sample := range namespaceSamples {
+			minute := sample.CreatedAt.Truncate(time.Minute)
+			for _, flow := range sample.Flows {
+				workload := workloadForPod(namespace, flow.Pod)
+				peer := endpoints.resolve(flow.PeerIp)
+				source, target := workload, peer
+				tx, rx := flow.TransmitBytes, flow.ReceivedBytes
+				if flow.Direction == networkmonitor.FlowDirectionIngress {
+					if peer.Type == TrafficFlowNodeWorkload && samples[peer.Namespace] != nil {
+						continue
+					}
+					source, target = peer, workload
+					tx, rx = rx, tx
+				}
+				nodes[source.Id] = source
+				nodes[target.Id] = target
+
+				key := edgeMinute{edgeKey{source.Id, target.Id, flow.Port, flow.Protocol}, minute}
+				edge, ok := perMinute[key]
+				if !ok {
+					edge = &TrafficFlowGraphEdge{Source: source.Id, Target: target.Id, Port: flow.Port, Protocol: flow.Protocol, LastSeen: sample.CreatedAt}
+					perMinute[key] = edge
+				}
+				edge.Connections += flow.Connections
+				edge.TransmitBytes += tx
+				edge.ReceivedBytes += rx
+				if sample.CreatedAt.After(edge.LastSeen) {
+					edge.LastSeen = sample.CreatedAt
+				}
+			}
+		}
+	}
+
+	edges := map[edgeKey]*TrafficFlowGraphEdge{}
+	for key, minuteEdge := range perMinute {
+		edge, ok := edges[key.edgeKey]
+		if !ok {
+			edges[key.edgeKey] = minuteEdge
+			continue
+		}
+		edge.Connections = max(edge.Connections, minuteEdge.Connections)
+		edge.TransmitBytes = max(edge.TransmitBytes, minuteEdge.TransmitBytes)
+		edge.ReceivedBytes = max(edge.ReceivedBytes, minuteEdge.ReceivedBytes)
+		if minuteEdge.LastSeen.After(edge.LastSeen) {
+			edge.LastSeen = minuteEdge.LastSeen
+		}
+	}
+
+	graph := &TrafficFlowGraph{
+		Nodes: make([]TrafficFlowGraphNode, 0, len(nodes)),
+		Edges: make([]TrafficFlowGraphEdge, 0, len(edges)),
+	}
+	for _, id := range slices.Sorted(maps.Keys(nodes)) {
+		graph.Nodes = append(graph.Nodes, nodes[id])
+	}
+	for _, edge := range edges {
+		graph.Edges = append(graph.Edges, *edge)
+	}
+	slices.SortFunc(graph.Edges, func(a, b TrafficFlowGraphEdge) int {
+		return cmp.Or(
+			strings.Compare(a.Source, b.Source),
+			strings.Compare(a.Target, b.Target),
+			cmp.Compare(a.Port, b.Port),
+		)
+	})
+	return graph
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"mogenius-operator/src/networkmonitor"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testWorkloadForPod(namespace string, podName string) TrafficFlowGraphNode {
+	name := podName[:len(podName)-2] // "api-0" -> "api"
+	return TrafficFlowGraphNode{Id: "Deployment/" + namespace + "/" + name, Type: TrafficFlowNodeWorkload, Kind: "Deployment", Namespace: namespace, Name: name}
+}
+
+func TestBuildTrafficFlowGraph(t *testing.T) {
+	endpoints := newTrafficEndpointIndex(
+		[]v1.Pod{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db-0"}, Status: v1.PodStatus{PodIPs: []v1.PodIP{{IP: "10.0.0.9"}}}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api-0"}, Status: v1.PodStatus{PodIPs: []v1.PodIP{{IP: "10.0.0.5"}}}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "ingress", Name: "lb-0"}, Status: v1.PodStatus{PodIPs: []v1.PodIP{{IP: "10.0.0.2"}}}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "proxy-0"}, Spec: v1.PodSpec{HostNetwork: true}, Status: v1.PodStatus{PodIPs: []v1.PodIP{{IP: "192.168.1.10"}}}},
+		},
+		[]v1.Service{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db"}, Spec: v1.ServiceSpec{ClusterIPs: []string{"10.96.0.20"}}},
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db-headless"}, Spec: v1.ServiceSpec{ClusterIPs: []string{v1.ClusterIPNone}}},
+		},
+		[]v1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}, Status: v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.1.10"}}}},
+		},
+		testWorkloadForPod,
+	)
+
+	minute := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	samples := map[string][]networkmonitor.PodNetworkFlows{
+		"shop": {
+			{Node: "worker-1", CreatedAt: minute, Flows: []networkmonitor.PodNetworkFlow{
+				{Pod: "api-0", Direction: networkmonitor.FlowDirectionEgress, PeerIp: "10.96.0.20", Port: 5432, Protocol: "tcp", Connections: 3, TransmitBytes: 100, ReceivedBytes: 900},
+				{Pod: "api-0", Direction: networkmonitor.FlowDirectionEgress, PeerIp: "1.2.3.4", Port: 443, Protocol: "tcp", Connections: 1},
+				// seen from the ingress namespace, which is not queried
+				{Pod: "api-0", Direction: networkmonitor.FlowDirectionIngress, PeerIp: "10.0.0.2", Port: 8080, Protocol: "tcp", Connections: 2, TransmitBytes: 50, ReceivedBytes: 10},
+				// the api's own egress to the db pod already covers this
+				{Pod: "db-0", Direction: networkmonitor.FlowDirectionIngress, PeerIp: "10.0.0.5", Port: 5432, Protocol: "tcp", Connections: 3},
+				{Pod: "db-0", Direction: networkmonitor.FlowDirectionIngress, PeerIp: "192.168.1.10", Port: 5432, Protocol: "tcp", Connections: 1},
+			}},
+			// a second replica on another node in the same minute adds up,
+			{Node: "worker-2", CreatedAt: minute.Add(20 * time.Second), Flows: []networkmonitor.PodNetworkFlow{
+				{Pod: "api-1", Direction: networkmonitor.FlowDirectionEgress, PeerIp: "10.96.0.20", Port: 5432, Protocol: "tcp", Connections: 2},
+			}},
+			// while later minutes only raise the peak
+			{Node: "worker-1", CreatedAt: minute.Add(time.Minute), Flows: []networkmonitor.PodNetworkFlow{
+				{Pod: "api-0", Direction: networkmonitor.FlowDirectionEgress, PeerIp: "10.96.0.20", Port: 5432, Protocol: "tcp", Connections: 1},
+			}},
+		},
+	}
+
+	graph := buildTrafficFlowGraph(samples, endpoints, testWorkloadForPod)
+
+	ids := []string{}
+	for _, node := range graph.Nodes {
+		ids = append(ids, node.Id)
+	}
+	assert.Equal(t, []string{"Deployment/ingress/lb", "Deployment/shop/api", "Deployment/shop/db", "External/1.2.3.4", "Node/worker-1", "Service/shop/db"}, ids)
+
+	assert.Equal(t, []TrafficFlowGraphEdge{
+		{Source: "Deployment/ingress/lb", Target: "Deployment/shop/api", Port: 8080, Protocol: "tcp", Connections: 2, TransmitBytes: 10, ReceivedBytes: 50, LastSeen: minute},
+		{Source: "Deployment/shop/api", Target: "External/1.2.3.4", Port: 443, Protocol: "tcp", Connections: 1, LastSeen: minute},
+		{Source: "Deployment/shop/api", Target: "Service/shop/db", Port: 5432, Protocol: "tcp", Connections: 5, TransmitBytes: 100, ReceivedBytes: 900, LastSeen: minute.Add(time.Minute)},
+		{Source: "Node/worker-1", Target: "Deployment/shop/db", Port: 5432, Protocol: "tcp", Connections: 1, LastSeen: minute},
+	}, graph.Edges)
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"fmt"
+	"log/slog"
+	"mogenius-operator/src/assert"
+	"mogenius-operator/src/deprecations"
+	"mogenius-operator/src/helm"
+	"mogenius-operator/src/kubernetes"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/utils"
+	"mogenius-operator/src/valkeyclient"
+	"slices"
+)
+
+// upgradeReadinessMaxMinors caps how far ahead the report looks; the
+// deprecation table does not know removals further out anyway.
+const upgradeReadinessMaxMinors = 5
+
+// UpgradeReadinessChecker reports the objects that use Kubernetes APIs
+// deprecated or removed in the next minor versions: live objects, the
+// manifests of Helm releases and the inventories of ArgoCD Applications and
+// Flux Kustomizations.
+type UpgradeReadinessChecker interface {
+	Link(apiService Api)
+	GetUpgradeReadiness(request UpgradeReadinessRequest) (*deprecations.Report, error)
+}
+
+type UpgradeReadinessRequest struct {
+	// WorkspaceName limits the report to the namespaces of the workspace;
+	// cluster-scoped objects are only listed without workspace, except for
+	// those of Helm releases in the workspace.
+	WorkspaceName string `json:"workspaceName,omitempty"`
+	// Minors is the number of minor versions to upgrade by, 1 by default.
+	Minors int `json:"minors,omitempty"`
+}
+
+type upgradeReadinessChecker struct {
+	logger     *slog.Logger
+	valkey     valkeyclient.ValkeyClient
+	apiService Api
+}
+
+func NewUpgradeReadinessChecker(logger *slog.Logger, valkey valkeyclient.ValkeyClient) UpgradeReadinessChecker {
+	self := &upgradeReadinessChecker{}
+	self.logger = logger
+	self.valkey = valkey
+
+	return self
+}
+
+func (self *upgradeReadinessChecker) Link(apiService Api) {
+	assert.Assert(apiService != nil)
+
+	self.apiService = apiService
+}
+
+func (self *upgradeReadinessChecker) GetUpgradeReadiness(request UpgradeReadinessRequest) (*deprecations.Report, error) {
+	assert.Assert(self.apiService != nil)
+
+	if request.Minors == 0 {
+		request.Minors = 1
+	}
+	if request.Minors < 0 || request.Minors > upgradeReadinessMaxMinors {
+		return nil, fmt.Errorf("minors must be between 1 and %d", upgradeReadinessMaxMinors)
+	}
+	gitVersion, err := kubernetes.GetKubernetesVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the Kubernetes version: %w", err)
+	}
+	current, err := deprecations.ParseMinor(gitVersion)
+	if err != nil {
+		return nil, err
+	}
+	target := current + request.Minors
+
+	var namespaces []string
+	if request.WorkspaceName != "" {
+		namespaces, err = self.apiService.GetWorkspaceNamespaces(request.WorkspaceName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index := deprecations.NewIndex(store.GetResourceByKindAndNamespace(self.valkey, utils.CustomResourceDefinitionResource.ApiVersion, utils.CustomResourceDefinitionResource.Kind, "", self.logger))
+	report := &deprecations.Report{
+		ClusterVersion: deprecations.FormatMinor(current),
+		TargetVersion:  deprecations.FormatMinor(target),
+		Findings:       []deprecations.Finding{},
+	}
+	check := func(object deprecations.Object, source string, owner *deprecations.Owner) {
+		finding, ok := index.Check(object, source, owner, current, target)
+		if !ok {
+			return
+		}
+		if request.WorkspaceName != "" && !slices.Contains(namespaces, finding.ScopeNamespace()) {
+			return
+		}
+		report.Findings = append(report.Findings, finding)
+	}
+
+	if err := self.checkCluster(index, check); err != nil {
+		return nil, err
+	}
+	self.checkHelmReleases(report, check)
+	self.checkGitOps(check)
+
+	report.Summarize()
+	return report, nil
+}
+
+type upgradeReadinessCheck func(object deprecations.Object, source string, owner *deprecations.Owner)
+
+// checkCluster reports the live objects of kinds the cluster only serves in
+// a deprecated apiVersion.
+func (self *upgradeReadinessChecker) checkCluster(index *deprecations.Index, check upgradeReadinessCheck) error {
+	resources, err := kubernetes.GetAvailableResources()
+	if err != nil {
+		return err
+	}
+	for _, resource := range resources {
+		if _, ok := index.Lookup(resource.ApiVersion, resource.Kind); !ok {
+			continue
+		}
+		for _, obj := range store.GetResourceByKindAndNamespace(self.valkey, resource.ApiVersion, resource.Kind, "", self.logger) {
+			check(deprecations.Object{ApiVersion: resource.ApiVersion, Kind: resource.Kind, Namespace: obj.GetNamespace(), Name: obj.GetName()}, deprecations.SourceCluster, nil)
+		}
+	}
+	return nil
+}
+
+// checkHelmReleases reports the objects of the current revision of every
+// Helm release. Releases that fail to load are listed as errors of the
+// report.
+func (self *upgradeReadinessChecker) checkHelmReleases(report *deprecations.Report, check upgradeReadinessCheck) {
+	releases, err := helm.HelmReleaseList(helm.HelmReleaseListRequest{})
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to list Helm releases: %s", err.Error()))
+		return
+	}
+	for _, release := range releases {
+		manifest, err := helm.HelmReleaseGet(helm.HelmReleaseGetRequest{Namespace: release.Namespace, Release: release.Name, GetFormat: structs.HelmGetManifest})
+		if err == nil {
+			var objects []deprecations.Object
+			objects, err = deprecations.ParseManifest(manifest)
+			owner := &deprecations.Owner{Kind: "Release", Namespace: release.Namespace, Name: release.Name}
+			for _, object := range objects {
+				check(object, deprecations.SourceHelm, owner)
+			}
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("Helm release %s/%s: %s", release.Namespace, release.Name, err.Error()))
+		}
+	}
+}
+
+// checkGitOps reports the objects in the inventories of ArgoCD Applications
+// and Flux Kustomizations, which carry the apiVersion as written in Git.
+func (self *upgradeReadinessChecker) checkGitOps(check upgradeReadinessCheck) {
+	for _, application := range store.GetResourceByKindAndNamespace(self.valkey, argoCdApplicationResource.ApiVersion, argoCdApplicationResource.Kind, "", self.logger) {
+		owner := &deprecations.Owner{Kind: argoCdApplicationResource.Kind, Namespace: application.GetNamespace(), Name: application.GetName()}
+		for _, object := range deprecations.ArgoCdObjects(&application) {
+			check(object, deprecations.SourceGitOps, owner)
+		}
+	}
+	for _, kustomization := range store.GetResourceByKindAndNamespace(self.valkey, utils.KustomizationResource.ApiVersion, utils.KustomizationResource.Kind, "", self.logger) {
+		owner := &deprecations.Owner{Kind: utils.KustomizationResource.Kind, Namespace: kustomization.GetNamespace(), Name: kustomization.GetName()}
+		for _, object := range deprecations.FluxObjects(&kustomization) {
+			check(object, deprecations.SourceGitOps, owner)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/kubernetes"
+	"mogenius-operator/src/shutdown"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/utils"
+	"mogenius-operator/src/valkeyclient"
+	"mogenius-operator/src/websocket"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	WatchEventAdded    = "ADDED"
+	WatchEventModified = "MODIFIED"
+	WatchEventDeleted  = "DELETED"
+	// WatchEventBookmark carries no object, only the resourceVersion to
+	// resume from, so idle subscriptions keep a bookmark inside the history.
+	WatchEventBookmark = "BOOKMARK"
+	// WatchEventError ends a subscription that fell behind the history; the
+	// client has to list again and subscribe without resourceVersion.
+	WatchEventError = "ERROR"
+)
+
+const (
+	// watchSubscriptionTTL is how long a subscription lives without being
+	// renewed by subscribing again with its id.
+	watchSubscriptionTTL = 10 * time.Minute
+	// watchBookmarkInterval is how often subscriptions get a bookmark.
+	watchBookmarkInterval = time.Minute
+)
+
+// WatchSubscriptionService delivers the changes the watcher sees to clients
+// that subscribed with a filter. Changes are kept in a bounded in-memory
+// history; their position is the resourceVersion clients resume from after
+// a reconnect. The resourceVersion is opaque: it names the operator process
+// and the position in its history, not the resourceVersion of an object.
+//
+// Events are pushed as `watch/event` datagrams over the events connection.
+type WatchSubscriptionService interface {
+	Run()
+	Link(apiService Api)
+	// Subscribe creates a subscription, or replaces the subscription with
+	// the request's id. Clients renew their subscription this way before it
+	// expires and resume it after a reconnect by passing the last
+	// resourceVersion they received.
+	Subscribe(user structs.User, request WatchSubscribeRequest) (*WatchSubscribeResponse, error)
+	Unsubscribe(user structs.User, subscriptionId string) error
+	// OnResourceEvent records a change seen by the watcher: "add", "update"
+	// or "delete".
+	OnResourceEvent(eventType string, resource utils.ResourceDescriptor, obj *unstructured.Unstructured)
+}
+
+type WatchSubscribeRequest struct {
+	// SubscriptionId renews or resumes a subscription; empty creates one.
+	SubscriptionId string `json:"subscriptionId,omitempty"`
+	// ApiVersion limits the events to this apiVersion of Kind; empty
+	// matches any.
+	ApiVersion    string `json:"apiVersion,omitempty"`
+	Kind          string `json:"kind" validate:"required"`
+	Namespace     string `json:"namespace,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// WorkspaceName limits the events to the namespaces of the workspace;
+	// cluster-scoped objects are left out.
+	WorkspaceName string `json:"workspaceName,omitempty"`
+	// ResourceVersion is the last resourceVersion received; the events after
+	// it are replayed. Empty starts with the next change.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// SendInitialEvents starts with an ADDED event for every matching object
+	// in the store. Only without ResourceVersion.
+	SendInitialEvents bool `json:"sendInitialEvents,omitempty"`
+}
+
+type WatchSubscribeResponse struct {
+	SubscriptionId string `json:"subscriptionId"`
+	// ResourceVersion is the current position of the history.
+	ResourceVersion string    `json:"resourceVersion"`
+	ExpiresAt       time.Time `json:"expiresAt"`
+}
+
+type WatchEvent struct {
+	SubscriptionId  string                     `json:"subscriptionId"`
+	Type            string                     `json:"type"`
+	ResourceVersion string                     `json:"resourceVersion"`
+	Object          *unstructured.Unstructured `json:"object,omitempty"`
+	// Message explains ERROR events.
+	Message string `json:"message,omitempty"`
+}
+
+type watchHistoryEntry struct {
+	seq       uint64
+	eventType string
+	resource  utils.ResourceDescriptor
+	obj       *unstructured.Unstructured
+}
+
+type watchSubscription struct {
+	id         string
+	user       structs.User
+	workspace  string
+	apiVersion string
+	kind       string
+	namespace  string
+	selector   labels.Selector
+	// namespaces of the workspace; nil without workspace
+	namespaces []string
+	// next is the seq of the next history entry to deliver
+	next        uint64
+	initial     []unstructured.Unstructured
+	bookmarkDue bool
+	expiresAt   time.Time
+}
+
+type watchSubscriptionService struct {
+	logger       *slog.Logger
+	config       cfg.ConfigModule
+	valkey       valkeyclient.ValkeyClient
+	eventsClient websocket.WebsocketClient
+	apiService   Api
+
+	// epoch names this process in resourceVersions, so versions of an
+	// earlier process are recognized as too old
+	epoch string
+
+	lock          sync.Mutex
+	history       []watchHistoryEntry
+	seq           uint64
+	subscriptions map[string]*watchSubscription
+	wake          chan struct{}
+}
+
+func NewWatchSubscriptionService(logger *slog.Logger, configModule cfg.ConfigModule, valkey valkeyclient.ValkeyClient, eventsClient websocket.WebsocketClient) WatchSubscriptionService {
+	historySize, err := strconv.Atoi(configModule.Get("MO_WATCH_HISTORY_SIZE"))
+	assert.Assert(err == nil, "MO_WATCH_HISTORY_SIZE must be a valid integer", err)
+
+	self := &watchSubscriptionService{}
+	self.logger = logger
+	self.config = configModule
+	self.valkey = valkey
+	self.eventsClient = eventsClient
+	self.epoch = utils.NanoId()
+	self.history = make([]watchHistoryEntry, max(historySize, 1))
+	self.subscriptions = map[string]*watchSubscription{}
+	self.wake = make(chan struct{}, 1)
+
+	return self
+}
+
+func (self *watchSubscriptionService) Link(apiService Api) {
+	assert.Assert(apiService != nil)
+
+	self.apiService = apiService
+}
+
+func (self *watchSubscriptionService) Run() {
+	assert.Assert(self.apiService != nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdown.Add(cancel)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-self.wake:
+				self.dispatch()
+			}
+		}
+	}()
+
+	go func() {
+		for sleepCtx(ctx, watchBookmarkInterval) {
+			self.tick(time.Now())
+		}
+	}()
+}
+
+func (self *watchSubscriptionService) Subscribe(user structs.User, request WatchSubscribeRequest) (*WatchSubscribeResponse, error) {
+	selector, err := labels.Parse(request.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid labelSelector: %w", err)
+	}
+	if request.ResourceVersion != "" && request.SendInitialEvents {
+		return nil, fmt.Errorf("sendInitialEvents cannot be combined with resourceVersion")
+	}
+	subscription := &watchSubscription{
+		id:         request.SubscriptionId,
+		user:       user,
+		workspace:  request.WorkspaceName,
+		apiVersion: request.ApiVersion,
+		kind:       request.Kind,
+		namespace:  request.Namespace,
+		selector:   selector,
+	}
+	if subscription.id == "" {
+		subscription.id = utils.NanoId()
+	}
+	if request.WorkspaceName != "" {
+		subscription.namespaces, err = self.apiService.GetWorkspaceNamespaces(request.WorkspaceName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The position is taken before the store is read, so changes during the
+	// read are replayed after the initial events rather than lost.
+	self.lock.Lock()
+	subscription.next = self.seq + 1
+	self.lock.Unlock()
+	if request.SendInitialEvents {
+		subscription.initial, err = self.initialObjects(subscription)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if existing, ok := self.subscriptions[subscription.id]; ok && !strings.EqualFold(existing.user.Email, user.Email) {
+		return nil, fmt.Errorf("subscription %q belongs to another user", subscription.id)
+	}
+	if request.ResourceVersion != "" {
+		seq, err := self.parseResourceVersion(request.ResourceVersion)
+		if err != nil {
+			return nil, err
+		}
+		subscription.next = seq + 1
+	}
+	subscription.expiresAt = time.Now().Add(watchSubscriptionTTL)
+	self.subscriptions[subscription.id] = subscription
+	self.signal()
+
+	return &WatchSubscribeResponse{
+		SubscriptionId:  subscription.id,
+		ResourceVersion: self.resourceVersion(self.seq),
+		ExpiresAt:       subscription.expiresAt,
+	}, nil
+}
+
+func (self *watchSubscriptionService) Unsubscribe(user structs.User, subscriptionId string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	subscription, ok := self.subscriptions[subscriptionId]
+	if !ok {
+		return fmt.Errorf("subscription %q not found", subscriptionId)
+	}
+	if !strings.EqualFold(subscription.user.Email, user.Email) {
+		return fmt.Errorf("subscription %q belongs to another user", subscriptionId)
+	}
+	delete(self.subscriptions, subscriptionId)
+	return nil
+}
+
+func (self *watchSubscriptionService) OnResourceEvent(eventType string, resource utils.ResourceDescriptor, obj *unstructured.Unstructured) {
+	var watchEventType string
+	switch eventType {
+	case "add":
+		watchEventType = WatchEventAdded
+	case "update":
+		watchEventType = WatchEventModified
+	case "delete":
+		watchEventType = WatchEventDeleted
+	default:
+		return
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	self.seq++
+	self.history[self.seq%uint64(len(self.history))] = watchHistoryEntry{seq: self.seq, eventType: watchEventType, resource: resource, obj: obj}
+	if len(self.subscriptions) > 0 {
+		self.signal()
+	}
+}
+
+// signal wakes the dispatcher; the caller holds the lock.
+func (self *watchSubscriptionService) signal() {
+	select {
+	case self.wake <- struct{}{}:
+	default:
+	}
+}
+
+// tick expires subscriptions that were not renewed, refreshes the
+// workspace namespaces and schedules a bookmark for every subscription.
+func (self *watchSubscriptionService) tick(now time.Time) {
+	self.lock.Lock()
+	workspaces := map[string][]string{}
+	for id, subscription := range self.subscriptions {
+		if now.After(subscription.expiresAt) {
+			self.logger.Debug("watch subscription expired", "subscriptionId", id, "user", subscription.user.Email)
+			delete(self.subscriptions, id)
+			continue
+		}
+		subscription.bookmarkDue = true
+		if subscription.workspace != "" {
+			workspaces[subscription.workspace] = nil
+		}
+	}
+	self.signal()
+	self.lock.Unlock()
+
+	for workspace := range workspaces {
+		namespaces, err := self.apiService.GetWorkspaceNamespaces(workspace)
+		if err != nil {
+			self.logger.Warn("failed to refresh workspace namespaces of watch subscriptions", "workspace", workspace, "error", err)
+			continue
+		}
+		workspaces[workspace] = namespaces
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, subscription := range self.subscriptions {
+		if namespaces := workspaces[subscription.workspace]; namespaces != nil {
+			subscription.namespaces = namespaces
+		}
+	}
+}
+
+// dispatch collects the pending events of every subscription under the
+// lock and sends them afterwards. There is a single dispatcher, so events
+// leave in history order.
+func (self *watchSubscriptionService) dispatch() {
+	type delivery struct {
+		subscription *watchSubscription
+		events       []WatchEvent
+	}
+
+	self.lock.Lock()
+	deliveries := []delivery{}
+	oldest := self.oldestSeq()
+	for id, subscription := range self.subscriptions {
+		events := []WatchEvent{}
+		if subscription.next < oldest {
+			events = append(events, WatchEvent{
+				SubscriptionId:  id,
+				Type:            WatchEventError,
+				ResourceVersion: self.resourceVersion(subscription.next - 1),
+				Message:         "the subscription fell behind the watch history, list again and subscribe without resourceVersion",
+			})
+			delete(self.subscriptions, id)
+			deliveries = append(deliveries, delivery{subscription, events})
+			continue
+		}
+		for i := range subscription.initial {
+			events = append(events, WatchEvent{SubscriptionId: id, Type: WatchEventAdded, ResourceVersion: self.resourceVersion(subscription.next - 1), Object: redactWatchObject(&subscription.initial[i])})
+		}
+		subscription.initial = nil
+		for ; subscription.next <= self.seq; subscription.next++ {
+			entry := self.history[subscription.next%uint64(len(self.history))]
+			if !subscription.matches(entry.resource, entry.obj) {
+				continue
+			}
+			events = append(events, WatchEvent{SubscriptionId: id, Type: entry.eventType, ResourceVersion: self.resourceVersion(entry.seq), Object: redactWatchObject(entry.obj)})
+		}
+		if subscription.bookmarkDue {
+			events = append(events, WatchEvent{SubscriptionId: id, Type: WatchEventBookmark, ResourceVersion: self.resourceVersion(self.seq)})
+			subscription.bookmarkDue = false
+		}
+		if len(events) > 0 {
+			deliveries = append(deliveries, delivery{subscription, events})
+		}
+	}
+	self.lock.Unlock()
+
+	for _, delivery := range deliveries {
+		for _, event := range delivery.events {
+			datagram := structs.Datagram{
+				Id:        utils.NanoId(),
+				Pattern:   "watch/event",
+				Payload:   event,
+				CreatedAt: time.Now(),
+				User:      delivery.subscription.user,
+				Workspace: delivery.subscription.workspace,
+			}
+			if err := self.eventsClient.WriteJSON(datagram); err != nil {
+				self.logger.Error("failed to push watch event", "subscriptionId", event.SubscriptionId, "error", err)
+			}
+		}
+	}
+}
+
+// initialObjects reads the objects matching the subscription from the store.
+func (self *watchSubscriptionService) initialObjects(subscription *watchSubscription) ([]unstructured.Unstructured, error) {
+	resources, err := kubernetes.GetAvailableResources()
+	if err != nil {
+		return nil, err
+	}
+	result := []unstructured.Unstructured{}
+	for _, resource := range resources {
+		if resource.Kind != subscription.kind || (subscription.apiVersion != "" && resource.ApiVersion != subscription.apiVersion) {
+			continue
+		}
+		for _, obj := range store.GetResourceByKindAndNamespace(self.valkey, resource.ApiVersion, resource.Kind, subscription.namespace, self.logger) {
+			if subscription.matches(resource, &obj) {
+				result = append(result, obj)
+			}
+		}
+	}
+	return result, nil
+}
+
+// oldestSeq is the seq of the oldest entry still in the history; the caller
+// holds the lock.
+func (self *watchSubscriptionService) oldestSeq() uint64 {
+	size := uint64(len(self.history))
+	if self.seq < size {
+		return 1
+	}
+	return self.seq - size + 1
+}
+
+func (self *watchSubscriptionService) resourceVersion(seq uint64) string {
+	return self.epoch + "." + strconv.FormatUint(seq, 10)
+}
+
+// parseResourceVersion returns the seq of a resourceVersion the history can
+// resume from; the caller holds the lock.
+func (self *watchSubscriptionService) parseResourceVersion(resourceVersion string) (uint64, error) {
+	epoch, position, ok := strings.Cut(resourceVersion, ".")
+	seq, err := strconv.ParseUint(position, 10, 64)
+	if !ok || err != nil {
+		return 0, fmt.Errorf("invalid resourceVersion %q", resourceVersion)
+	}
+	if epoch != self.epoch || seq+1 < self.oldestSeq() || seq > self.seq {
+		return 0, fmt.Errorf("resourceVersion %q is too old, list again and subscribe without resourceVersion", resourceVersion)
+	}
+	return seq, nil
+}
+
+func (self *watchSubscription) matches(resource utils.ResourceDescriptor, obj *unstructured.Unstructured) bool {
+	if resource.Kind != self.kind || (self.apiVersion != "" && resource.ApiVersion != self.apiVersion) {
+		return false
+	}
+	if self.namespace != "" && obj.GetNamespace() != self.namespace {
+		return false
+	}
+	if self.workspace != "" && !slices.Contains(self.namespaces, obj.GetNamespace()) {
+		return false
+	}
+	return self.selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+// redactWatchObject drops the values of Secrets; subscribers learn that a
+// Secret changed, not its content.
+func redactWatchObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if obj.GetKind() != "Secret" || obj.GetAPIVersion() != "v1" {
+		return obj
+	}
+	redacted := obj.DeepCopy()
+	unstructured.RemoveNestedField(redacted.Object, "data")
+	unstructured.RemoveNestedField(redacted.Object, "stringData")
+	return redacted
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"log/slog"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/utils"
+	"mogenius-operator/src/websocket"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// fakeWatchEventClient records the datagrams pushed to the event server.
+type fakeWatchEventClient struct {
+	websocket.WebsocketClient
+	datagrams []structs.Datagram
+}
+
+func (self *fakeWatchEventClient) WriteJSON(data any) error {
+	self.datagrams = append(self.datagrams, data.(structs.Datagram))
+	return nil
+}
+
+func (self *fakeWatchEventClient) events() []WatchEvent {
+	result := []WatchEvent{}
+	for _, datagram := range self.datagrams {
+		result = append(result, datagram.Payload.(WatchEvent))
+	}
+	self.datagrams = nil
+	return result
+}
+
+func newWatchTestService(historySize int) (*watchSubscriptionService, *fakeWatchEventClient) {
+	client := &fakeWatchEventClient{}
+	return &watchSubscriptionService{
+		logger:        slog.New(slog.DiscardHandler),
+		eventsClient:  client,
+		epoch:         "test",
+		history:       make([]watchHistoryEntry, historySize),
+		subscriptions: map[string]*watchSubscription{},
+		wake:          make(chan struct{}, 1),
+	}, client
+}
+
+func watchTestObject(kind, namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{"apiVersion": "v1", "kind": kind}}
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(labels)
+	return obj
+}
+
+var (
+	watchTestConfigMaps = utils.ResourceDescriptor{ApiVersion: "v1", Kind: "ConfigMap"}
+	watchTestSecrets    = utils.ResourceDescriptor{ApiVersion: "v1", Kind: "Secret"}
+)
+
+func TestWatchSubscriptionFilter(t *testing.T) {
+	service, client := newWatchTestService(10)
+	user := structs.User{Email: "dev@example.com"}
+
+	response, err := service.Subscribe(user, WatchSubscribeRequest{Kind: "ConfigMap", Namespace: "shop", LabelSelector: "app=api"})
+	require.NoError(t, err)
+	assert.Equal(t, "test.0", response.ResourceVersion)
+
+	service.OnResourceEvent("add", watchTestConfigMaps, watchTestObject("ConfigMap", "shop", "api", map[string]string{"app": "api"}))
+	service.OnResourceEvent("add", watchTestConfigMaps, watchTestObject("ConfigMap", "shop", "web", map[string]string{"app": "web"}))
+	service.OnResourceEvent("add", watchTestConfigMaps, watchTestObject("ConfigMap", "blog", "api", map[string]string{"app": "api"}))
+	service.OnResourceEvent("update", watchTestSecrets, watchTestObject("Secret", "shop", "api", map[string]string{"app": "api"}))
+	service.OnResourceEvent("delete", watchTestConfigMaps, watchTestObject("ConfigMap", "shop", "api", map[string]string{"app": "api"}))
+	service.dispatch()
+
+	events := client.events()
+	require.Len(t, events, 2)
+	assert.Equal(t, WatchEventAdded, events[0].Type)
+	assert.Equal(t, "test.1", events[0].ResourceVersion)
+	assert.Equal(t, WatchEventDeleted, events[1].Type)
+	assert.Equal(t, "test.5", events[1].ResourceVersion)
+	assert.Equal(t, response.SubscriptionId, events[1].SubscriptionId)
+
+	_, err = service.Subscribe(structs.User{Email: "other@example.com"}, WatchSubscribeRequest{SubscriptionId: response.SubscriptionId, Kind: "ConfigMap"})
+	assert.Error(t, err, "subscriptions belong to their user")
+	assert.Error(t, service.Unsubscribe(structs.User{Email: "other@example.com"}, response.SubscriptionId))
+	require.NoError(t, service.Unsubscribe(user, response.SubscriptionId))
+	assert.Empty(t, service.subscriptions)
+}
+
+func TestWatchSubscriptionResume(t *testing.T) {
+	service, client := newWatchTestService(3)
+	user := structs.User{Email: "dev@example.com"}
+
+	for _, name := range []string{"a", "b", "c"} {
+		service.OnResourceEvent("add", watchTestConfigMaps, watchTestObject("ConfigMap", "shop", name, nil))
+	}
+	response, err := service.Subscribe(user, WatchSubscribeRequest{SubscriptionId: "sub", Kind: "ConfigMap", ResourceVersion: "test.1"})
+	require.NoError(t, err)
+	assert.Equal(t, "test.3", response.ResourceVersion)
+	service.dispatch()
+	events := client.events()
+	require.Len(t, events, 2, "the events after the resourceVersion are replayed")
+	assert.Equal(t, "b", events[0].Object.GetName())
+	assert.Equal(t, "c", events[1].Object.GetName())
+
+	service.OnResourceEvent("add", watchTestConfigMaps, watchTestObject("ConfigMap", "shop", "d", nil))
+	_, err = service.Subscribe(user, WatchSubscribeRequest{SubscriptionId: "sub", Kind: "ConfigMap", ResourceVersion: "test.0"})
+	assert.Error(t, err, "the event after test.0 left the history")
+	_, err = service.Subscribe(user, WatchSubscribeRequest{SubscriptionId: "sub", Kind: "ConfigMap", ResourceVersion: "earlier.3"})
+	assert.Error(t, err, "resourceVersions of another process are too old")
+	_, err = service.Subscribe(user, WatchSubscribeRequest{SubscriptionId: "sub", Kind: "ConfigMap", ResourceVersion: "3"})
+	assert.Error(t, err)
+
+	// a subscriber the history overtakes ends with an ERROR event
+	for _, name := range []string{"e", "f", "g", "h"} {
+		service.OnResourceEvent("add", watchTestConfigMaps, watchTestObject("ConfigMap", "shop", name, nil))
+	}
+	service.dispatch()
+	events = client.events()
+	require.Len(t, events, 1)
+	assert.Equal(t, WatchEventError, events[0].Type)
+	assert.Empty(t, service.subscriptions)
+}
+
+func TestWatchSubscriptionBookmark(t *testing.T) {
+	service, client := newWatchTestService(10)
+	response, err := service.Subscribe(structs.User{Email: "dev@example.com"}, WatchSubscribeRequest{Kind: "Secret"})
+	require.NoError(t, err)
+	service.OnResourceEvent("update", watchTestSecrets, &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"data":       map[string]any{"password": "c2VjcmV0"},
+	}})
+	service.OnResourceEvent("add", watchTestConfigMaps, watchTestObject("ConfigMap", "shop", "api", nil))
+
+	service.tick(response.ExpiresAt.Add(-1))
+	service.dispatch()
+	events := client.events()
+	require.Len(t, events, 2)
+	assert.Equal(t, WatchEventModified, events[0].Type)
+	assert.NotContains(t, events[0].Object.Object, "data", "Secret values are not sent")
+	assert.Equal(t, WatchEventBookmark, events[1].Type)
+	assert.Equal(t, "test.2", events[1].ResourceVersion, "bookmarks carry the latest position")
+
+	service.tick(response.ExpiresAt.Add(1))
+	assert.Empty(t, service.subscriptions, "subscriptions expire unless renewed")
+}
@@ -0,0 +1,400 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math"
+	"mogenius-operator/src/assert"
+	cfg "mogenius-operator/src/config"
+	"mogenius-operator/src/kubernetes"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/structs"
+	"mogenius-operator/src/valkeyclient"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// Requests get headroom on top of the p95 usage, limits on top of the
+	// peak usage.
+	recommendationRequestHeadroom = 1.15
+	recommendationCpuLimitFactor  = 1.5
+	recommendationMemLimitFactor  = 1.3
+
+	recommendationMinCpuMillicores = 10
+	recommendationMinMemoryBytes   = 32 << 20
+	recommendationCpuStep          = 5
+	recommendationMemoryStep       = 1 << 20
+
+	// recommendationMinCoverage is the share of the window that needs usage
+	// samples before a workload gets a recommendation.
+	recommendationMinCoverage = 0.25
+
+	recommendationHoursPerMonth = 730
+)
+
+// WorkloadRecommender proposes CPU and memory requests and limits per
+// controller from the usage recorded in the pod stats rollups. Requests
+// follow the p95 usage, limits the peak usage, each with some headroom.
+type WorkloadRecommender interface {
+	Link(apiService Api)
+	GetWorkloadRecommendations(workspaceName string, query WorkloadRecommendationQuery) (*WorkloadRecommendationReport, error)
+}
+
+type WorkloadRecommendationQuery struct {
+	// Window is the usage history to look at as Go duration (e.g. "336h").
+	// Defaults to MO_RECOMMENDATION_WINDOW.
+	Window string `json:"window"`
+	// Vpa adds a VerticalPodAutoscaler (updateMode "Off") carrying the
+	// recommendation to every entry.
+	Vpa bool `json:"vpa"`
+}
+
+type WorkloadRecommendationReport struct {
+	Window string `json:"window"`
+	// Currency is only set when MO_COST_NODE_PRICES is configured.
+	Currency            string                   `json:"currency,omitempty"`
+	TotalMonthlySavings float64                  `json:"totalMonthlySavings"`
+	Recommendations     []WorkloadRecommendation `json:"recommendations"`
+}
+
+// ResourceUsage is the usage of one replica: millicores for CPU, bytes for
+// memory.
+type ResourceUsage struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	Max float64 `json:"max"`
+}
+
+// ResourceProposal holds the requests and limits of one replica, summed over
+// its containers. A zero value means unset.
+type ResourceProposal struct {
+	CpuRequestMillicores int64 `json:"cpuRequestMillicores"`
+	CpuLimitMillicores   int64 `json:"cpuLimitMillicores"`
+	MemoryRequestBytes   int64 `json:"memoryRequestBytes"`
+	MemoryLimitBytes     int64 `json:"memoryLimitBytes"`
+}
+
+type WorkloadRecommendation struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Replicas  int    `json:"replicas"`
+	// Coverage is the share of the window backed by usage samples. Below 25%
+	// the workload keeps its current resources and InsufficientData is set.
+	Coverage         float64          `json:"coverage"`
+	InsufficientData bool             `json:"insufficientData,omitempty"`
+	CpuUsage         ResourceUsage    `json:"cpuUsage"`
+	MemoryUsage      ResourceUsage    `json:"memoryUsage"`
+	Current          ResourceProposal `json:"current"`
+	Recommended      ResourceProposal `json:"recommended"`
+	// The savings cover all replicas; negative values mean the workload is
+	// under-provisioned and the recommendation raises its requests.
+	CpuRequestSavingsMillicores int64   `json:"cpuRequestSavingsMillicores"`
+	MemoryRequestSavingsBytes   int64   `json:"memoryRequestSavingsBytes"`
+	MonthlySavings              float64 `json:"monthlySavings"`
+	// Vpa is only set when requested.
+	Vpa *unstructured.Unstructured `json:"vpa,omitempty"`
+}
+
+type workloadRecommender struct {
+	logger            *slog.Logger
+	config            cfg.ConfigModule
+	valkey            valkeyclient.ValkeyClient
+	ownerCacheService store.OwnerCacheService
+	apiService        Api
+}
+
+func NewWorkloadRecommender(logger *slog.Logger, configModule cfg.ConfigModule, valkey valkeyclient.ValkeyClient, ownerCacheService store.OwnerCacheService) WorkloadRecommender {
+	self := &workloadRecommender{}
+	self.logger = logger
+	self.config = configModule
+	self.valkey = valkey
+	self.ownerCacheService = ownerCacheService
+
+	return self
+}
+
+func (self *workloadRecommender) Link(apiService Api) {
+	assert.Assert(apiService != nil)
+
+	self.apiService = apiService
+}
+
+func (self *workloadRecommender) GetWorkloadRecommendations(workspaceName string, query WorkloadRecommendationQuery) (*WorkloadRecommendationReport, error) {
+	assert.Assert(self.apiService != nil)
+
+	window, err := time.ParseDuration(cmp.Or(query.Window, self.config.Get("MO_RECOMMENDATION_WINDOW")))
+	if err != nil {
+		return nil, fmt.Errorf("window needs to be a Go duration (e.g. 168h): %s", err.Error())
+	}
+	tiers := statsRollupTiersFromConfig(self.config)
+	if window < time.Hour || window > tiers[len(tiers)-1].retention {
+		return nil, fmt.Errorf("window must be between 1h and %s", tiers[len(tiers)-1].retention)
+	}
+	// rawRetention 0: the raw streams only keep one container per minute
+	tier := selectStatsRollupTier(window, 0, tiers)
+
+	controllers, err := self.apiService.GetWorkspaceControllers(workspaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	cpuRate, memoryRate, currency := self.resourceRates()
+	report := &WorkloadRecommendationReport{Window: window.String(), Currency: currency, Recommendations: []WorkloadRecommendation{}}
+	podsByNamespace := map[string][]v1.Pod{}
+	for _, controller := range controllers {
+		namespace, kind, name := controller.GetNamespace(), controller.GetKind(), controller.GetName()
+		if _, ok := podsByNamespace[namespace]; !ok {
+			podsByNamespace[namespace] = store.GetPods(namespace)
+		}
+		pods := self.controllerPods(podsByNamespace[namespace], kind, name)
+		if len(pods) == 0 {
+			continue
+		}
+
+		entries, err := valkeyclient.GetObjectsFromSortedListWithDuration[structs.StatsRollupEntry](
+			self.valkey,
+			int64(window/time.Minute),
+			DB_STATS_POD_STATS_ROLLUP_BUCKET_NAME, tier.name, namespace, name,
+		)
+		if err != nil {
+			self.logger.Error("failed to read pod stats rollups for recommendation", "namespace", namespace, "name", name, "error", err)
+			continue
+		}
+
+		recommendation := recommendWorkloadResources(entries, int(window/tier.resolution), pods)
+		recommendation.Namespace, recommendation.Kind, recommendation.Name = namespace, kind, name
+		recommendation.MonthlySavings = recommendationMonthlySavings(recommendation, cpuRate, memoryRate)
+		if query.Vpa {
+			recommendation.Vpa = workloadRecommendationVpa(recommendation, controller.GetAPIVersion(), pods[0])
+		}
+		report.TotalMonthlySavings += recommendation.MonthlySavings
+		report.Recommendations = append(report.Recommendations, recommendation)
+	}
+
+	slices.SortFunc(report.Recommendations, func(a, b WorkloadRecommendation) int {
+		if c := cmp.Compare(b.MonthlySavings, a.MonthlySavings); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(b.CpuRequestSavingsMillicores, a.CpuRequestSavingsMillicores); c != 0 {
+			return c
+		}
+		return strings.Compare(costWorkloadKey(a.Namespace, a.Kind, a.Name), costWorkloadKey(b.Namespace, b.Kind, b.Name))
+	})
+	return report, nil
+}
+
+func (self *workloadRecommender) controllerPods(pods []v1.Pod, kind string, name string) []v1.Pod {
+	result := []v1.Pod{}
+	for _, pod := range pods {
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		controller := self.ownerCacheService.ControllerForPod(pod.Namespace, pod.Name)
+		if controller != nil && controller.Kind == kind && controller.ResourceName == name {
+			result = append(result, pod)
+		}
+	}
+	return result
+}
+
+// resourceRates returns the price of one core hour and one GiB hour averaged
+// over all priced nodes, or zeros without MO_COST_NODE_PRICES.
+func (self *workloadRecommender) resourceRates() (float64, float64, string) {
+	prices, err := ParseNodePriceTable(self.config.Get("MO_COST_NODE_PRICES"))
+	assert.Assert(err == nil, err)
+	if len(prices) == 0 {
+		return 0, 0, ""
+	}
+	cpuShare, err := strconv.ParseFloat(self.config.Get("MO_COST_CPU_SHARE"), 64)
+	assert.Assert(err == nil, err)
+
+	cpuRate, memoryRate := clusterResourceRates(store.GetNodes(), prices, cpuShare)
+	return cpuRate, memoryRate, self.config.Get("MO_COST_CURRENCY")
+}
+
+func clusterResourceRates(nodes []v1.Node, prices NodePriceTable, cpuShare float64) (float64, float64) {
+	price, cores, memoryGiB := 0.0, 0.0, 0.0
+	for _, node := range nodes {
+		hourlyPrice, ok := prices.PriceFor(node)
+		if !ok {
+			continue
+		}
+		price += hourlyPrice
+		cores += float64(node.Status.Capacity.Cpu().MilliValue()) / 1000
+		memoryGiB += float64(node.Status.Capacity.Memory().Value()) / costBytesPerGiB
+	}
+	if cores <= 0 || memoryGiB <= 0 {
+		return 0, 0
+	}
+	return price * cpuShare / cores, price * (1 - cpuShare) / memoryGiB
+}
+
+// recommendWorkloadResources derives the per replica usage from the rollup
+// windows of a workload and proposes its resources. The rollups hold the sum
+// over all replicas, so the usage of one replica assumes an even spread.
+func recommendWorkloadResources(entries []structs.StatsRollupEntry, expectedWindows int, pods []v1.Pod) WorkloadRecommendation {
+	recommendation := WorkloadRecommendation{Replicas: len(pods)}
+	cpuRequest, cpuLimit := kubernetes.SumCpuResources(pods)
+	memoryRequest, memoryLimit := kubernetes.SumMemoryResources(pods)
+	replicas := float64(len(pods))
+	recommendation.Current = ResourceProposal{
+		CpuRequestMillicores: int64(math.Round(cpuRequest * 1000 / replicas)),
+		CpuLimitMillicores:   int64(math.Round(cpuLimit * 1000 / replicas)),
+		MemoryRequestBytes:   int64(math.Round(float64(memoryRequest) / replicas)),
+		MemoryLimitBytes:     int64(math.Round(float64(memoryLimit) / replicas)),
+	}
+
+	var cpuAvg, cpuP95, memoryAvg, memoryP95 []float64
+	for _, entry := range entries {
+		// entries written before the replica count was recorded fall back to
+		// the current replicas
+		pods := entry.Metrics["pods"].Avg
+		if pods <= 0 {
+			pods = replicas
+		}
+		cpu, memory := entry.Metrics["cpu"], entry.Metrics["memory"]
+		cpuAvg = append(cpuAvg, cpu.Avg/pods)
+		cpuP95 = append(cpuP95, cpu.P95/pods)
+		memoryAvg = append(memoryAvg, memory.Avg/pods)
+		memoryP95 = append(memoryP95, memory.P95/pods)
+		recommendation.CpuUsage.Max = max(recommendation.CpuUsage.Max, cpu.Max/pods)
+		recommendation.MemoryUsage.Max = max(recommendation.MemoryUsage.Max, memory.Max/pods)
+	}
+	recommendation.CpuUsage.P50 = nearestRank(cpuAvg, 0.5)
+	recommendation.CpuUsage.P95 = nearestRank(cpuP95, 0.95)
+	recommendation.MemoryUsage.P50 = nearestRank(memoryAvg, 0.5)
+	recommendation.MemoryUsage.P95 = nearestRank(memoryP95, 0.95)
+	if expectedWindows > 0 {
+		recommendation.Coverage = min(1, float64(len(entries))/float64(expectedWindows))
+	}
+
+	if recommendation.Coverage < recommendationMinCoverage {
+		recommendation.InsufficientData = true
+		recommendation.Recommended = recommendation.Current
+		return recommendation
+	}
+
+	recommended := ResourceProposal{
+		CpuRequestMillicores: roundUp(max(recommendation.CpuUsage.P95*recommendationRequestHeadroom, recommendationMinCpuMillicores), recommendationCpuStep),
+		MemoryRequestBytes:   roundUp(max(recommendation.MemoryUsage.P95*recommendationRequestHeadroom, recommendationMinMemoryBytes), recommendationMemoryStep),
+	}
+	// CPU limits only throttle; keep them off for workloads that run without.
+	if recommendation.Current.CpuLimitMillicores > 0 {
+		recommended.CpuLimitMillicores = max(recommended.CpuRequestMillicores, roundUp(recommendation.CpuUsage.Max*recommendationCpuLimitFactor, recommendationCpuStep))
+	}
+	recommended.MemoryLimitBytes = max(recommended.MemoryRequestBytes, roundUp(recommendation.MemoryUsage.Max*recommendationMemLimitFactor, recommendationMemoryStep))
+	recommendation.Recommended = recommended
+
+	recommendation.CpuRequestSavingsMillicores = (recommendation.Current.CpuRequestMillicores - recommended.CpuRequestMillicores) * int64(len(pods))
+	recommendation.MemoryRequestSavingsBytes = (recommendation.Current.MemoryRequestBytes - recommended.MemoryRequestBytes) * int64(len(pods))
+	return recommendation
+}
+
+// recommendationMonthlySavings prices the request savings: the cost engine
+// charges at least the requests, so lower requests lower the cost.
+func recommendationMonthlySavings(recommendation WorkloadRecommendation, cpuRate float64, memoryRate float64) float64 {
+	cores := float64(recommendation.CpuRequestSavingsMillicores) / 1000
+	gib := float64(recommendation.MemoryRequestSavingsBytes) / costBytesPerGiB
+	return (cores*cpuRate + gib*memoryRate) * recommendationHoursPerMonth
+}
+
+// workloadRecommendationVpa exports the recommendation as VerticalPodAutoscaler
+// that only recommends. The per replica values are split over the containers
+// in proportion to their current requests, or evenly without requests.
+func workloadRecommendationVpa(recommendation WorkloadRecommendation, apiVersion string, pod v1.Pod) *unstructured.Unstructured {
+	containers := pod.Spec.Containers
+	cpuShares := make([]float64, len(containers))
+	memoryShares := make([]float64, len(containers))
+	for i, container := range containers {
+		cpuShares[i] = float64(container.Resources.Requests.Cpu().MilliValue())
+		memoryShares[i] = float64(container.Resources.Requests.Memory().Value())
+	}
+	cpuShares, memoryShares = normalizeShares(cpuShares), normalizeShares(memoryShares)
+
+	containerRecommendations := []any{}
+	for i, container := range containers {
+		cpu := func(millicores float64) string {
+			return resource.NewMilliQuantity(int64(math.Ceil(millicores*cpuShares[i])), resource.DecimalSI).String()
+		}
+		memory := func(bytes float64) string {
+			return resource.NewQuantity(int64(math.Ceil(bytes*memoryShares[i])), resource.BinarySI).String()
+		}
+		containerRecommendations = append(containerRecommendations, map[string]any{
+			"containerName": container.Name,
+			"target": map[string]any{
+				"cpu":    cpu(float64(recommendation.Recommended.CpuRequestMillicores)),
+				"memory": memory(float64(recommendation.Recommended.MemoryRequestBytes)),
+			},
+			"lowerBound": map[string]any{
+				"cpu":    cpu(recommendation.CpuUsage.P50),
+				"memory": memory(recommendation.MemoryUsage.P50),
+			},
+			"upperBound": map[string]any{
+				"cpu":    cpu(max(float64(recommendation.Recommended.CpuLimitMillicores), recommendation.CpuUsage.Max)),
+				"memory": memory(float64(recommendation.Recommended.MemoryLimitBytes)),
+			},
+			"uncappedTarget": map[string]any{
+				"cpu":    cpu(recommendation.CpuUsage.P95),
+				"memory": memory(recommendation.MemoryUsage.P95),
+			},
+		})
+	}
+
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "autoscaling.k8s.io/v1",
+		"kind":       "VerticalPodAutoscaler",
+		"metadata": map[string]any{
+			"name":      recommendation.Name,
+			"namespace": recommendation.Namespace,
+		},
+		"spec": map[string]any{
+			"targetRef": map[string]any{
+				"apiVersion": apiVersion,
+				"kind":       recommendation.Kind,
+				"name":       recommendation.Name,
+			},
+			"updatePolicy": map[string]any{"updateMode": "Off"},
+		},
+		"status": map[string]any{
+			"recommendation": map[string]any{"containerRecommendations": containerRecommendations},
+		},
+	}}
+}
+
+func normalizeShares(shares []float64) []float64 {
+	total := 0.0
+	for _, share := range shares {
+		total += share
+	}
+	for i := range shares {
+		if total > 0 {
+			shares[i] /= total
+		} else {
+			shares[i] = 1 / float64(len(shares))
+		}
+	}
+	return shares
+}
+
+// nearestRank returns the quantile of samples using the nearest-rank method.
+func nearestRank(samples []float64, quantile float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := slices.Sorted(slices.Values(samples))
+	rank := max(0, int(math.Ceil(quantile*float64(len(sorted))))-1)
+	return sorted[rank]
+}
+
+func roundUp(value float64, step int64) int64 {
+	return int64(math.Ceil(value/float64(step))) * step
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"mogenius-operator/src/structs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func recommenderTestPods(replicas int) []v1.Pod {
+	container := func(name string, cpu string, memory string, limits v1.ResourceList) v1.Container {
+		return v1.Container{Name: name, Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)},
+			Limits:   limits,
+		}}
+	}
+	pods := []v1.Pod{}
+	for range replicas {
+		pods = append(pods, v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop"},
+			Spec: v1.PodSpec{Containers: []v1.Container{
+				container("app", "1500m", "1536Mi", v1.ResourceList{v1.ResourceCPU: resource.MustParse("4"), v1.ResourceMemory: resource.MustParse("4Gi")}),
+				container("sidecar", "500m", "512Mi", nil),
+			}},
+			Status: v1.PodStatus{Phase: v1.PodRunning},
+		})
+	}
+	return pods
+}
+
+func recommenderTestEntries(windows int) []structs.StatsRollupEntry {
+	start := time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)
+	entries := []structs.StatsRollupEntry{}
+	for i := range windows {
+		// sums over both replicas
+		entry := structs.StatsRollupEntry{Start: start.Add(time.Duration(i) * 15 * time.Minute), Metrics: map[string]structs.StatsRollup{
+			"pods":   {Avg: 2},
+			"cpu":    {Avg: 200, P95: 300, Max: 400},
+			"memory": {Avg: 1024 << 20, P95: 1200 << 20, Max: 1400 << 20},
+		}}
+		if i == 3 {
+			entry.Metrics["cpu"] = structs.StatsRollup{Avg: 200, P95: 300, Max: 1000}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRecommendWorkloadResources(t *testing.T) {
+	recommendation := recommendWorkloadResources(recommenderTestEntries(96), 96, recommenderTestPods(2))
+
+	assert.Equal(t, 2, recommendation.Replicas)
+	assert.Equal(t, 1.0, recommendation.Coverage)
+	assert.False(t, recommendation.InsufficientData)
+	assert.Equal(t, ResourceProposal{
+		CpuRequestMillicores: 2000,
+		CpuLimitMillicores:   4000,
+		MemoryRequestBytes:   2 << 30,
+		MemoryLimitBytes:     4 << 30,
+	}, recommendation.Current)
+	assert.Equal(t, ResourceUsage{P50: 100, P95: 150, Max: 500}, recommendation.CpuUsage)
+	assert.Equal(t, float64(600<<20), recommendation.MemoryUsage.P95)
+
+	// p95 150m plus headroom, rounded up to 5m; the limit follows the peak
+	assert.Equal(t, int64(175), recommendation.Recommended.CpuRequestMillicores)
+	assert.Equal(t, int64(750), recommendation.Recommended.CpuLimitMillicores)
+	assert.InDelta(t, 690<<20, recommendation.Recommended.MemoryRequestBytes, 1<<20)
+	assert.InDelta(t, 910<<20, recommendation.Recommended.MemoryLimitBytes, 1<<20)
+	assert.Equal(t, int64((2000-175)*2), recommendation.CpuRequestSavingsMillicores)
+	assert.Positive(t, recommendation.MemoryRequestSavingsBytes)
+}
+
+func TestRecommendWorkloadResourcesInsufficientData(t *testing.T) {
+	entries := recommenderTestEntries(10)
+	// entries without a replica count use the current replicas
+	delete(entries[0].Metrics, "pods")
+
+	recommendation := recommendWorkloadResources(entries, 96, recommenderTestPods(2))
+	assert.True(t, recommendation.InsufficientData)
+	assert.Equal(t, recommendation.Current, recommendation.Recommended)
+	assert.Zero(t, recommendation.CpuRequestSavingsMillicores)
+	assert.Equal(t, 100.0, recommendation.CpuUsage.P50)
+}
+
+func TestRecommendationMonthlySavings(t *testing.T) {
+	nodes := []v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{v1.LabelInstanceTypeStable: "m5.large"}},
+			Status: v1.NodeStatus{Capacity: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("2"),
+				v1.ResourceMemory: resource.MustParse("8Gi"),
+			}},
+		},
+		// unpriced nodes do not dilute the rates
+		{Status: v1.NodeStatus{Capacity: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("64"),
+			v1.ResourceMemory: resource.MustParse("256Gi"),
+		}}},
+	}
+	prices, err := ParseNodePriceTable("m5.large=0.1")
+	require.NoError(t, err)
+
+	cpuRate, memoryRate := clusterResourceRates(nodes, prices, 0.5)
+	assert.InDelta(t, 0.025, cpuRate, 1e-9)
+	assert.InDelta(t, 0.00625, memoryRate, 1e-9)
+
+	savings := recommendationMonthlySavings(WorkloadRecommendation{
+		CpuRequestSavingsMillicores: 2000,
+		MemoryRequestSavingsBytes:   -4 << 30,
+	}, cpuRate, memoryRate)
+	assert.InDelta(t, (2*0.025-4*0.00625)*730, savings, 1e-9)
+}
+
+func TestWorkloadRecommendationVpa(t *testing.T) {
+	pods := recommenderTestPods(2)
+	recommendation := recommendWorkloadResources(recommenderTestEntries(96), 96, pods)
+	recommendation.Namespace, recommendation.Kind, recommendation.Name = "shop", "Deployment", "api"
+
+	vpa := workloadRecommendationVpa(recommendation, "apps/v1", pods[0])
+	assert.Equal(t, "VerticalPodAutoscaler", vpa.GetKind())
+	mode, _, _ := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode")
+	assert.Equal(t, "Off", mode)
+	targetKind, _, _ := unstructured.NestedString(vpa.Object, "spec", "targetRef", "kind")
+	assert.Equal(t, "Deployment", targetKind)
+
+	containers, _, _ := unstructured.NestedSlice(vpa.Object, "status", "recommendation", "containerRecommendations")
+	require.Len(t, containers, 2)
+	// 175m split 3:1 like the current requests
+	app, _, _ := unstructured.NestedString(containers[0].(map[string]any), "target", "cpu")
+	sidecar, _, _ := unstructured.NestedString(containers[1].(map[string]any), "target", "cpu")
+	assert.Equal(t, "132m", app)
+	assert.Equal(t, "44m", sidecar)
+}
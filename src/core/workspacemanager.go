@@ -1,8 +1,11 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"log/slog"
+	"mogenius-operator/src/backup"
 	cfg "mogenius-operator/src/config"
 	"mogenius-operator/src/crds"
 	"mogenius-operator/src/crds/v1alpha1"
@@ -48,6 +51,12 @@ type WorkspaceManager interface {
 	CreateMcpServer(name string, spec v1alpha1.McpServerSpec) (*v1alpha1.McpServer, error)
 	UpdateMcpServer(name string, spec v1alpha1.McpServerSpec) (*v1alpha1.McpServer, error)
 	DeleteMcpServer(name string) error
+
+	GetAllWorkspaceBackups() ([]v1alpha1.WorkspaceBackup, error)
+	GetWorkspaceBackup(name string) (*v1alpha1.WorkspaceBackup, error)
+	CreateWorkspaceBackup(name string, spec v1alpha1.WorkspaceBackupSpec) (*v1alpha1.WorkspaceBackup, error)
+	DeleteWorkspaceBackup(name string) error
+	RestoreWorkspaceBackup(ctx context.Context, name string, options backup.RestoreOptions) (*backup.RestoreResult, error)
 }
 
 type workspaceManager struct {
@@ -295,6 +304,59 @@ func (self *workspaceManager) DeleteMcpServer(name string) error {
 	return self.mogeniusClientSet.MogeniusV1alpha1.DeleteMcpServer(self.namespace, name)
 }
 
+func (self *workspaceManager) GetAllWorkspaceBackups() ([]v1alpha1.WorkspaceBackup, error) {
+	self.namespaceLock.RLock()
+	defer self.namespaceLock.RUnlock()
+	return self.mogeniusClientSet.MogeniusV1alpha1.ListWorkspaceBackups(self.namespace)
+}
+
+func (self *workspaceManager) GetWorkspaceBackup(name string) (*v1alpha1.WorkspaceBackup, error) {
+	self.namespaceLock.RLock()
+	defer self.namespaceLock.RUnlock()
+	return self.mogeniusClientSet.MogeniusV1alpha1.GetWorkspaceBackup(self.namespace, name)
+}
+
+func (self *workspaceManager) CreateWorkspaceBackup(name string, spec v1alpha1.WorkspaceBackupSpec) (*v1alpha1.WorkspaceBackup, error) {
+	self.namespaceLock.RLock()
+	defer self.namespaceLock.RUnlock()
+	return self.mogeniusClientSet.MogeniusV1alpha1.CreateWorkspaceBackup(self.namespace, name, nil, spec)
+}
+
+func (self *workspaceManager) DeleteWorkspaceBackup(name string) error {
+	self.namespaceLock.RLock()
+	defer self.namespaceLock.RUnlock()
+	return self.mogeniusClientSet.MogeniusV1alpha1.DeleteWorkspaceBackup(self.namespace, name)
+}
+
+// RestoreWorkspaceBackup loads the archive of a completed backup and applies
+// it to the cluster. The export itself runs in the reconciler; the restore is
+// triggered explicitly and reports its result synchronously; cancelling ctx
+// stops it between resources.
+func (self *workspaceManager) RestoreWorkspaceBackup(ctx context.Context, name string, options backup.RestoreOptions) (*backup.RestoreResult, error) {
+	workspaceBackup, err := self.GetWorkspaceBackup(name)
+	if err != nil {
+		return nil, err
+	}
+	if workspaceBackup.Status.Phase != v1alpha1.WorkspaceBackupPhaseCompleted || workspaceBackup.Status.Archive == "" {
+		return nil, fmt.Errorf("WorkspaceBackup %q has not completed (phase %q)", name, workspaceBackup.Status.Phase)
+	}
+
+	storage, err := backup.NewStorage(ctx, self.clientProvider.K8sClientSet(), workspaceBackup.Namespace, workspaceBackup.Spec.Storage, self.config.Get("MO_BACKUP_PATH"))
+	if err != nil {
+		return nil, err
+	}
+	data, err := storage.Get(ctx, workspaceBackup.Status.Archive)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := backup.ReadArchive(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", storage.Describe(workspaceBackup.Status.Archive), err)
+	}
+
+	return backup.Restore(ctx, self.clientProvider.DynamicClient(), archive, options)
+}
+
 // mogeniusAiModelCrdOps adapts the MogeniusV1alpha1 client to the minimal
 // aiModelCrdOps surface the managed-secret orchestration works against.
 type mogeniusAiModelCrdOps struct {
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"mogenius-operator/src/assert"
+	"mogenius-operator/src/reconciler"
+)
+
+// workspaceUtilizationWindowMinutes is the smallest window the workspace stats
+// accept. Only the newest entry is used, a longer window would only cost reads.
+const workspaceUtilizationWindowMinutes = 5
+
+type workspaceUtilizationProvider struct {
+	api     Api
+	dbstats ValkeyStatsDb
+}
+
+// NewWorkspaceUtilizationProvider lets the reconciler report the live CPU and
+// memory use of a workspace next to its quota, from the same pod stats the
+// `stats/workspace-*-utilization` patterns serve.
+func NewWorkspaceUtilizationProvider(api Api, dbstats ValkeyStatsDb) reconciler.WorkspaceUtilizationProvider {
+	assert.Assert(api != nil)
+	assert.Assert(dbstats != nil)
+
+	return &workspaceUtilizationProvider{api: api, dbstats: dbstats}
+}
+
+func (self *workspaceUtilizationProvider) GetWorkspaceUtilization(workspaceName string) (reconciler.WorkspaceUtilization, error) {
+	result := reconciler.WorkspaceUtilization{}
+
+	controllers, err := self.api.GetWorkspaceControllers(workspaceName)
+	if err != nil {
+		return result, err
+	}
+
+	cpu, err := self.dbstats.GetWorkspaceStatsCpuUtilization(workspaceUtilizationWindowMinutes, controllers)
+	if err != nil {
+		return result, err
+	}
+	if len(cpu) > 0 {
+		result.CpuMillicores = cpu[len(cpu)-1].Value
+	}
+
+	memory, err := self.dbstats.GetWorkspaceStatsMemoryUtilization(workspaceUtilizationWindowMinutes, controllers)
+	if err != nil {
+		return result, err
+	}
+	if len(memory) > 0 {
+		result.MemoryBytes = memory[len(memory)-1].Value
+	}
+
+	return result, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"mogenius-operator/src/cpumonitor"
+	"mogenius-operator/src/iomonitor"
 	"mogenius-operator/src/networkmonitor"
 	"mogenius-operator/src/rammonitor"
 	"mogenius-operator/src/structs"
@@ -65,6 +66,8 @@ func (self *xtermService) LiveStreamConnection(conReq xterm.WsConnectionRequest,
 		valkeyKey = strings.Join([]string{DB_STATS_LIVE_BUCKET_NAME, "memory", "proc", conReq.NodeName}, ":")
 	case "live-stream/pod-cpu", "live-stream/workspace-cpu":
 		valkeyKey = strings.Join([]string{DB_STATS_LIVE_BUCKET_NAME, "cpu", "proc", conReq.NodeName}, ":")
+	case "live-stream/pod-io":
+		valkeyKey = strings.Join([]string{DB_STATS_LIVE_BUCKET_NAME, "io", "proc", conReq.NodeName}, ":")
 	default:
 		logger.Error("Unsupported pattern for LiveStreamConnection", "pattern", datagram.Pattern)
 		return
@@ -147,6 +150,16 @@ func (self *xtermService) LiveStreamConnection(conReq xterm.WsConnectionRequest,
 			entry = slices.DeleteFunc(data, func(s cpumonitor.PodCpuStats) bool {
 				return !podNameSet[s.Name]
 			})
+		case "live-stream/pod-io":
+			data := []iomonitor.PodIoStats{}
+			err := json.Unmarshal([]byte(msg.Message), &data)
+			if err != nil {
+				logger.Error("Unmarshal", "error", err)
+				return
+			}
+			entry = slices.DeleteFunc(data, func(s iomonitor.PodIoStats) bool {
+				return !podNameSet[s.Name]
+			})
 		case "live-stream/pod-traffic", "live-stream/workspace-traffic":
 			data := []networkmonitor.PodNetworkStats{}
 			err := json.Unmarshal([]byte(msg.Message), &data)
@@ -546,6 +546,110 @@ func (self *MogeniusV1alpha1) DeleteMcpServer(namespace string, name string) err
 	return nil
 }
 
+// ╭──────────────────────────╮
+// │ Client: WorkspaceBackups │
+// ╰──────────────────────────╯
+
+func (self *MogeniusV1alpha1) ListWorkspaceBackups(namespace string) ([]mov1alpha1.WorkspaceBackup, error) {
+	backups, err := store.GetAllWorkspaceBackups(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	if backups == nil {
+		return []mov1alpha1.WorkspaceBackup{}, nil
+	}
+	return backups, nil
+}
+
+func (self *MogeniusV1alpha1) GetWorkspaceBackup(namespace string, name string) (*mov1alpha1.WorkspaceBackup, error) {
+	result, err := store.GetWorkspaceBackup(namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	if result == nil {
+		return nil, fmt.Errorf("store: workspacebackup %s/%s not found", namespace, name)
+	}
+	result.TypeMeta = metav1.TypeMeta{
+		Kind:       "WorkspaceBackup",
+		APIVersion: "mogenius.com/v1alpha1",
+	}
+	return result, nil
+}
+
+// CreateWorkspaceBackup creates a backup; labels let a BackupSchedule find the
+// backups it created.
+func (self *MogeniusV1alpha1) CreateWorkspaceBackup(namespace string, name string, labels map[string]string, spec mov1alpha1.WorkspaceBackupSpec) (*mov1alpha1.WorkspaceBackup, error) {
+	res := &mov1alpha1.WorkspaceBackup{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "WorkspaceBackup",
+			APIVersion: "mogenius.com/v1alpha1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: spec,
+	}
+	result := &mov1alpha1.WorkspaceBackup{}
+	err := self.restClient.Post().Namespace(namespace).Resource("workspacebackups").Body(res).Do(context.Background()).Into(result)
+	if err != nil {
+		return nil, fmt.Errorf("RESTClient: %w", err)
+	}
+	return result, nil
+}
+
+// UpdateWorkspaceBackupStatus writes only the status subresource of the given WorkspaceBackup.
+func (self *MogeniusV1alpha1) UpdateWorkspaceBackupStatus(backup *mov1alpha1.WorkspaceBackup) (*mov1alpha1.WorkspaceBackup, error) {
+	backup.TypeMeta = metav1.TypeMeta{
+		Kind:       "WorkspaceBackup",
+		APIVersion: "mogenius.com/v1alpha1",
+	}
+	result := &mov1alpha1.WorkspaceBackup{}
+	err := self.restClient.Put().Namespace(backup.Namespace).Resource("workspacebackups").Name(backup.Name).SubResource("status").Body(backup).Do(context.Background()).Into(result)
+	if err != nil {
+		return nil, fmt.Errorf("RESTClient: %w", err)
+	}
+	return result, nil
+}
+
+func (self *MogeniusV1alpha1) DeleteWorkspaceBackup(namespace string, name string) error {
+	err := self.restClient.Delete().Namespace(namespace).Resource("workspacebackups").Name(name).Do(context.Background()).Error()
+	if err != nil {
+		return fmt.Errorf("RESTClient: %w", err)
+	}
+	return nil
+}
+
+// ╭─────────────────────────╮
+// │ Client: BackupSchedules │
+// ╰─────────────────────────╯
+
+func (self *MogeniusV1alpha1) ListBackupSchedules(namespace string) ([]mov1alpha1.BackupSchedule, error) {
+	schedules, err := store.GetAllBackupSchedules(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	if schedules == nil {
+		return []mov1alpha1.BackupSchedule{}, nil
+	}
+	return schedules, nil
+}
+
+// UpdateBackupScheduleStatus writes only the status subresource of the given BackupSchedule.
+func (self *MogeniusV1alpha1) UpdateBackupScheduleStatus(schedule *mov1alpha1.BackupSchedule) (*mov1alpha1.BackupSchedule, error) {
+	schedule.TypeMeta = metav1.TypeMeta{
+		Kind:       "BackupSchedule",
+		APIVersion: "mogenius.com/v1alpha1",
+	}
+	result := &mov1alpha1.BackupSchedule{}
+	err := self.restClient.Put().Namespace(schedule.Namespace).Resource("backupschedules").Name(schedule.Name).SubResource("status").Body(schedule).Do(context.Background()).Into(result)
+	if err != nil {
+		return nil, fmt.Errorf("RESTClient: %w", err)
+	}
+	return result, nil
+}
+
 // ╭────────────────────╮
 // │ Client: Workspaces │
 // ╰────────────────────╯
@@ -622,13 +726,19 @@ func (self *MogeniusV1alpha1) UpdateWorkspace(namespace string, name string, spe
 			"name":         spec.Name,
 			"resources":    spec.Resources,
 			"dashboardRef": spec.DashboardRef,
+			// null removes the quota. A set quota never takes this path, see
+			// below.
+			"quota": spec.Quota,
 		},
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(spec.Resources) > 0 {
+	// A merge patch merges a quota into the stored one key by key, so a limit
+	// or default dropped from the new quota would survive the update. Setting
+	// a quota therefore replaces the whole spec.
+	if len(spec.Resources) > 0 && spec.Quota == nil {
 		result := &mov1alpha1.Workspace{}
 		err = self.restClient.Patch(types.MergePatchType).Namespace(namespace).Resource("workspaces").Name(name).Body(patchBytes).Do(context.Background()).Into(result)
 		if err != nil {
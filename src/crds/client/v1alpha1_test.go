@@ -24,6 +24,7 @@ func TestWorkspaceSpecFieldsCoveredByUpdateWorkspacePatch(t *testing.T) {
 		"name":         true,
 		"resources":    true,
 		"dashboardRef": true,
+		"quota":        true,
 	}
 
 	specType := reflect.TypeFor[mov1alpha1.WorkspaceSpec]()
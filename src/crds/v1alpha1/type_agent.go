@@ -25,9 +25,10 @@ type AgentList struct {
 }
 
 // A mogenius `Agent` resource defines an AI agent that observes a scoped set
-// of namespaces (read-only) and proposes tasks which a user must approve or
-// reject before anything is executed. Agents are only processed in the
-// operator's own namespace (MO_OWN_NAMESPACE).
+// of namespaces and proposes tasks which a user must approve or reject before
+// anything is executed. Mutating tool calls wait for human approval unless a
+// tool policy says otherwise. Agents are only processed in the operator's own
+// namespace (MO_OWN_NAMESPACE).
 //
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:resource:scope=Namespaced,shortName=aiagent,categories=mogenius
@@ -65,8 +66,8 @@ type AgentSpec struct {
 	// Disabled agents neither trigger nor process tasks.
 	Enabled bool `json:"enabled"`
 
-	// What the agent is allowed to see. Agents are always read-only; the
-	// scope additionally restricts reads to the resolved namespaces.
+	// What the agent is allowed to see and change. Reads and approved
+	// mutations are both restricted to the resolved namespaces.
 	Scope AgentScope `json:"scope"`
 
 	Triggers AgentTriggers `json:"triggers,omitempty"`
@@ -93,8 +94,9 @@ type AgentSpec struct {
 
 // AgentTools groups all tool configuration for an agent.
 type AgentTools struct {
-	// Builtin controls which built-in read-only tool groups are enabled.
-	// When unset, all built-in tool groups are available.
+	// Builtin controls which built-in tool groups are enabled and how their
+	// tool calls are approved. When unset, all built-in tool groups are
+	// available with the default policies.
 	Builtin *AgentBuiltinTools `json:"builtin,omitempty"`
 
 	// McpServerRefs lists names of McpServer CRs (same namespace) whose tools
@@ -104,16 +106,28 @@ type AgentTools struct {
 	McpServerRefs []string `json:"mcpServerRefs,omitempty"`
 }
 
-// AgentBuiltinTools toggles individual built-in read-only tool groups.
+// AgentBuiltinTools toggles individual built-in tool groups.
 // Omitting a field keeps the tool group enabled (defaults to true).
 type AgentBuiltinTools struct {
-	// Kubernetes enables the built-in read-only Kubernetes tools.
+	// Kubernetes enables the built-in Kubernetes tools.
 	// +kubebuilder:default=true
 	Kubernetes bool `json:"kubernetes,omitempty"`
 
-	// Helm enables the built-in read-only Helm tools.
+	// Helm enables the built-in Helm tools.
 	// +kubebuilder:default=true
 	Helm bool `json:"helm,omitempty"`
+
+	// ToolPolicies overrides the execution policy of individual built-in
+	// tools (e.g. "update_kubernetes_resource", "helm_release_rollback"),
+	// using the same model as McpServer toolPolicies. Unlisted read-only tools
+	// are autoApprove and unlisted mutating tools are needsApprove: the call
+	// becomes a proposal that runs with the approving user's permissions.
+	// autoApprove on a mutating tool executes it with editor permissions
+	// inside the agent's scope, without human review. The helm_repo_* tools
+	// change cluster-wide repositories the scope cannot restrict, so they
+	// cannot be autoApprove; neither can the Kubernetes write tools of an agent
+	// whose scope lists "*". Cluster-scoped resources are never written.
+	ToolPolicies []MCPToolPolicy `json:"toolPolicies,omitempty"`
 }
 
 // AgentScope restricts an agent's visibility. At least one of WorkspaceRef or
@@ -141,6 +155,16 @@ type AgentTriggers struct {
 	// When set, the agent runs a whole-scope analysis whenever a matching
 	// cluster resource changes (rate limited by MinInterval).
 	OnChange *AgentChangeTrigger `json:"onChange,omitempty"`
+
+	// When set, the agent runs a whole-scope analysis whenever a workload in
+	// its scope deviates from its metric baseline (rate limited by
+	// MinInterval).
+	OnMetric *AgentMetricTrigger `json:"onMetric,omitempty"`
+
+	// When set, the agent runs a whole-scope analysis whenever a newly known
+	// vulnerability affects an image running in its scope (rate limited by
+	// MinInterval).
+	OnVulnerability *AgentVulnerabilityTrigger `json:"onVulnerability,omitempty"`
 }
 
 // AgentChangeTrigger runs the agent when resources in its scope change. There
@@ -163,6 +187,47 @@ type AgentChangeTrigger struct {
 	MinInterval metav1.Duration `json:"minInterval,omitempty"`
 }
 
+// AgentMetricTrigger runs the agent when a workload metric leaves its learned
+// baseline. Baselines are exponentially weighted moving averages (and
+// variances) of the per-minute pod and traffic stats, kept per workload and
+// metric; a sample is anomalous when it and the sample before it deviate by
+// more than the sensitivity's z-score in the same direction.
+type AgentMetricTrigger struct {
+	// Metrics watched for anomalies: any of "cpu", "memory", "traffic".
+	// Empty means all of them.
+	// +kubebuilder:validation:items:Enum=cpu;memory;traffic
+	Metrics []string `json:"metrics,omitempty"`
+
+	// Sensitivity of the detector: "low" (z-score 4), "medium" (3) or "high"
+	// (2). Higher sensitivity reports smaller deviations. Defaults to medium.
+	// +kubebuilder:validation:Enum=low;medium;high
+	// +optional
+	Sensitivity string `json:"sensitivity,omitempty"`
+
+	// Minimum time between metric-triggered runs of this agent — the cooldown
+	// that keeps a long-lasting anomaly from starting many runs. Defaults to
+	// 6h when unset.
+	// +optional
+	MinInterval metav1.Duration `json:"minInterval,omitempty"`
+}
+
+// AgentVulnerabilityTrigger runs the agent when the image scan finds a
+// vulnerability that was not known before in an image running in the agent's
+// scope — a new advisory in the vulnerability database or a newly deployed
+// image.
+type AgentVulnerabilityTrigger struct {
+	// Lowest severity that triggers a run: "critical" or "high". Defaults to
+	// critical.
+	// +kubebuilder:validation:Enum=critical;high
+	// +optional
+	MinSeverity string `json:"minSeverity,omitempty"`
+
+	// Minimum time between vulnerability-triggered runs of this agent.
+	// Defaults to 6h when unset.
+	// +optional
+	MinInterval metav1.Duration `json:"minInterval,omitempty"`
+}
+
 type AgentStatus struct {
 	// Conditions describe the validation state of the agent; the "Ready"
 	// condition reports whether the operator accepts and processes it.
@@ -55,6 +55,7 @@ type GrantSpec struct {
 	// type of grant:
 	//
 	// - "workspace"
+	// - "cluster" (the role applies to every workspace and to cluster-wide operations; targetName is ignored)
 	TargetType string `json:"targetType,omitempty"`
 
 	// to which specific resource is the grant applied:
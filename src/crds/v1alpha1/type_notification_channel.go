@@ -0,0 +1,170 @@
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// ╭────────────────────────────╮
+// │ CRD: NotificationChannel   │
+// ╰────────────────────────────╯
+
+const (
+	NotificationChannelTypeSlack   = "slack"
+	NotificationChannelTypeTeams   = "teams"
+	NotificationChannelTypeWebhook = "webhook"
+	NotificationChannelTypeSmtp    = "smtp"
+)
+
+const (
+	NotificationSourceAiTask = "aiTask"
+	NotificationSourceAlert  = "alert"
+	NotificationSourceJob    = "job"
+)
+
+const (
+	NotificationSeverityInfo     = "info"
+	NotificationSeverityWarning  = "warning"
+	NotificationSeverityCritical = "critical"
+)
+
+// NotificationChannelConditionReady reports whether the channel is valid and
+// its secrets resolve.
+const NotificationChannelConditionReady = "Ready"
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type NotificationChannelList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	metav1.ListMeta `json:"metadata"`
+
+	Items []NotificationChannel `json:"items"`
+}
+
+// A mogenius NotificationChannel delivers AI task results, Alertmanager alerts
+// and failed jobs to Slack, Microsoft Teams, a generic webhook or an email
+// address. Routes select which notifications reach the channel; every delivery
+// is recorded in the delivery log.
+// NotificationChannels are only processed in the operator's own namespace (MO_OWN_NAMESPACE).
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced,shortName=notifychannel,categories=mogenius
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+// +kubebuilder:printcolumn:name="Disabled",type=boolean,JSONPath=`.spec.disabled`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type NotificationChannel struct {
+	metav1.TypeMeta `json:",inline"`
+
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec NotificationChannelSpec `json:"spec"`
+
+	Status NotificationChannelStatus `json:"status,omitempty"`
+}
+
+type NotificationChannelSpec struct {
+	// Type of the channel.
+	// +kubebuilder:validation:Enum=slack;teams;webhook;smtp
+	Type string `json:"type"`
+
+	// Disabled stops deliveries without deleting the channel.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Url of the Slack or Teams incoming webhook, or of the generic webhook.
+	// Incoming webhook URLs are credentials; prefer urlSecretRef for them.
+	Url string `json:"url,omitempty"`
+
+	// UrlSecretRef reads the URL from a Secret in the same namespace. The key
+	// defaults to "url".
+	UrlSecretRef *SecretReference `json:"urlSecretRef,omitempty"`
+
+	// Headers added to every request of a webhook channel.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Smtp configures the mail server of smtp channels.
+	Smtp *NotificationSmtpConfig `json:"smtp,omitempty"`
+
+	// Routes select the notifications delivered to this channel. A
+	// notification is delivered when any route matches; without routes every
+	// notification is delivered.
+	Routes []NotificationRoute `json:"routes,omitempty"`
+
+	// Template is a Go text/template rendering the message text from the
+	// notification (fields Source, Severity, Title, Message, Namespace,
+	// Workspaces, Agent, Url, Labels and CreatedAt). Defaults to the title
+	// followed by the message.
+	Template string `json:"template,omitempty"`
+
+	// MaxPerHour caps the deliveries of this channel; notifications beyond
+	// the cap are recorded as rate limited and not sent.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=60
+	MaxPerHour int `json:"maxPerHour,omitempty"`
+
+	// MaxAttempts per notification. Failed attempts are retried with
+	// exponential backoff.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	// +kubebuilder:default=5
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+}
+
+type NotificationSmtpConfig struct {
+	// Host of the mail server.
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// Port of the mail server.
+	// +kubebuilder:default=587
+	Port int `json:"port,omitempty"`
+
+	// Tls selects how the connection is secured: "starttls" upgrades a plain
+	// connection, "tls" connects with TLS right away and "none" sends in
+	// plain text.
+	// +kubebuilder:validation:Enum=starttls;tls;none
+	// +kubebuilder:default="starttls"
+	Tls string `json:"tls,omitempty"`
+
+	// From address of the mails.
+	// +kubebuilder:validation:MinLength=1
+	From string `json:"from"`
+
+	// To lists the recipients.
+	// +kubebuilder:validation:MinItems=1
+	To []string `json:"to"`
+
+	// Username for PLAIN authentication; no authentication when empty.
+	Username string `json:"username,omitempty"`
+
+	// PasswordSecretRef reads the password from a Secret in the same
+	// namespace. The key defaults to "password".
+	PasswordSecretRef *SecretReference `json:"passwordSecretRef,omitempty"`
+}
+
+// NotificationRoute matches a notification when every field that is set
+// matches.
+type NotificationRoute struct {
+	// Sources of the notification.
+	// +kubebuilder:validation:items:Enum=aiTask;alert;job
+	Sources []string `json:"sources,omitempty"`
+
+	// Workspaces whose namespaces the notification concerns.
+	Workspaces []string `json:"workspaces,omitempty"`
+
+	// MinSeverity is the lowest severity delivered.
+	// +kubebuilder:validation:Enum=info;warning;critical
+	MinSeverity string `json:"minSeverity,omitempty"`
+
+	// Agents whose AI tasks are delivered.
+	Agents []string `json:"agents,omitempty"`
+
+	// Patterns are globs (e.g. "KubePod*") matched against the notification
+	// title.
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+type NotificationChannelStatus struct {
+	// Conditions report the state of the channel; "Ready" indicates a valid
+	// spec with resolvable secrets.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
@@ -71,17 +71,16 @@ type GitOpsRepositoryConfig struct {
 	Revision       string          `json:"revision,omitempty"`
 	ExternalSecret *ExternalSecret `json:"externalSecret,omitempty"`
 	// Write configures how edits made in the platform reach this repository.
-	// Read by the mogenius API, not by this operator: declaring it changes
-	// nothing in the cluster on its own.
+	// With write credentials, the operator commits edits of resources applied
+	// from it to Git instead of changing the cluster.
 	// +optional
 	Write *GitOpsWriteConfig `json:"write,omitempty"`
 }
 
 // GitOpsWriteConfig overrides how the platform commits to a GitOps repository.
 //
-// Every field is optional. The credential Secret is what actually enables
-// editing, so an absent write block still allows it — this only overrides the
-// defaults the API would otherwise derive from the cluster.
+// Every field is optional, but without secretRef there is no credential to
+// push with and edits keep going to the cluster.
 type GitOpsWriteConfig struct {
 	// Enabled is false to refuse edits for this repository even when a
 	// credential exists. Absent means enabled.
@@ -0,0 +1,120 @@
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// ╭────────────────────────────╮
+// │ CRD: Policy                │
+// ╰────────────────────────────╯
+
+const (
+	PolicyModeEnforce = "enforce"
+	PolicyModeAudit   = "audit"
+)
+
+const (
+	PolicyFailurePolicyFail   = "Fail"
+	PolicyFailurePolicyIgnore = "Ignore"
+)
+
+const (
+	PolicyOperationCreate = "CREATE"
+	PolicyOperationUpdate = "UPDATE"
+)
+
+// PolicyConditionReady reports whether every expression of the policy
+// compiles.
+const PolicyConditionReady = "Ready"
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type PolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Policy `json:"items"`
+}
+
+// A mogenius Policy validates changes the operator is about to write, before
+// they reach the API server: workload creates and updates, Helm chart installs
+// and the resources AI agents create. Validations are CEL expressions, much
+// like a ValidatingAdmissionPolicy, evaluated against `object` (the resource
+// to write), `oldObject` (the live resource on updates, null on creates) and
+// `request` (operation, source, user, namespace and workspaces).
+// Policies are only processed in the operator's own namespace (MO_OWN_NAMESPACE).
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced,categories=mogenius
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`
+// +kubebuilder:printcolumn:name="Disabled",type=boolean,JSONPath=`.spec.disabled`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type Policy struct {
+	metav1.TypeMeta `json:",inline"`
+
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec PolicySpec `json:"spec"`
+
+	Status PolicyStatus `json:"status,omitempty"`
+}
+
+type PolicySpec struct {
+	// Disabled stops evaluating the policy without deleting it.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Mode "enforce" rejects changes with violations, "audit" lets them pass
+	// and only reports the violations in the response of the pattern or AI
+	// tool that wrote the change.
+	// +kubebuilder:validation:Enum=enforce;audit
+	// +kubebuilder:default="enforce"
+	Mode string `json:"mode,omitempty"`
+
+	// FailurePolicy decides how an expression that fails to evaluate (e.g.
+	// a missing field without has()) counts: "Fail" as violation, "Ignore" as
+	// passed.
+	// +kubebuilder:validation:Enum=Fail;Ignore
+	// +kubebuilder:default="Fail"
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+
+	// Match selects the resources the policy applies to.
+	Match PolicyMatch `json:"match,omitempty"`
+
+	// Validations must all evaluate to true for a change to pass.
+	// +kubebuilder:validation:MinItems=1
+	Validations []PolicyValidation `json:"validations"`
+}
+
+// PolicyMatch matches a resource when every field that is set matches.
+type PolicyMatch struct {
+	// Kinds of the resource, e.g. "Deployment".
+	Kinds []string `json:"kinds,omitempty"`
+
+	// Namespaces the resource lives in.
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Workspaces whose namespaces the resource lives in.
+	Workspaces []string `json:"workspaces,omitempty"`
+
+	// Operations the policy checks; both when empty.
+	// +kubebuilder:validation:items:Enum=CREATE;UPDATE
+	Operations []string `json:"operations,omitempty"`
+}
+
+type PolicyValidation struct {
+	// Expression is a CEL expression returning true when the resource is
+	// valid, e.g. `object.spec.template.spec.containers.all(c, !c.image.endsWith(':latest'))`.
+	// +kubebuilder:validation:MinLength=1
+	Expression string `json:"expression"`
+
+	// Message reported when the expression returns false. Defaults to the
+	// expression.
+	Message string `json:"message,omitempty"`
+}
+
+type PolicyStatus struct {
+	// Conditions report the state of the policy; "Ready" indicates that all
+	// expressions compile.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
@@ -1,6 +1,8 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -26,6 +28,7 @@ type WorkspaceList struct {
 // +kubebuilder:printcolumn:name="Dashboard",type=string,JSONPath=`.spec.dashboardRef`
 // +kubebuilder:printcolumn:name="Resources Valid",type=string,JSONPath=`.status.conditions[?(@.type=="ResourcesValid")].status`
 // +kubebuilder:printcolumn:name="Dashboard Valid",type=string,JSONPath=`.status.conditions[?(@.type=="DashboardRefValid")].status`
+// +kubebuilder:printcolumn:name="Within Budget",type=string,JSONPath=`.status.conditions[?(@.type=="QuotaWithinBudget")].status`
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type Workspace struct {
 	metav1.TypeMeta `json:",inline"`
@@ -47,6 +50,40 @@ type WorkspaceSpec struct {
 	// dashboard shows. When empty, the dashboard falls back to the built-in
 	// default (Deployments, StatefulSets, DaemonSets).
 	DashboardRef string `json:"dashboardRef,omitempty"`
+
+	// Quota optionally limits what the workloads of the workspace may request.
+	// The operator reconciles it into a ResourceQuota and a LimitRange in every
+	// namespace of the workspace (resources of type "namespace" and "helm").
+	Quota *WorkspaceQuota `json:"quota,omitempty"`
+}
+
+// WorkspaceQuota is the budget of a whole workspace. Kubernetes quotas are
+// per namespace, so every limit is split evenly across the namespaces of the
+// workspace; the remainder goes to the first namespaces in alphabetical order.
+type WorkspaceQuota struct {
+	// CPU caps the sum of CPU requests (`requests.cpu`).
+	CPU *resource.Quantity `json:"cpu,omitempty"`
+
+	// Memory caps the sum of memory requests (`requests.memory`).
+	Memory *resource.Quantity `json:"memory,omitempty"`
+
+	// Storage caps the sum of PersistentVolumeClaim requests (`requests.storage`).
+	Storage *resource.Quantity `json:"storage,omitempty"`
+
+	// Pods caps the number of non-terminal pods.
+	Pods *int64 `json:"pods,omitempty"`
+
+	// LoadBalancers caps the number of services of type LoadBalancer.
+	LoadBalancers *int64 `json:"loadBalancers,omitempty"`
+
+	// DefaultRequest is applied to containers without requests. Once CPU or
+	// memory is capped, pods without requests are rejected by the quota, so
+	// the operator falls back to 100m CPU and 128Mi memory, capped at
+	// DefaultLimit, when unset.
+	DefaultRequest corev1.ResourceList `json:"defaultRequest,omitempty"`
+
+	// DefaultLimit is applied to containers without limits.
+	DefaultLimit corev1.ResourceList `json:"defaultLimit,omitempty"`
 }
 
 func NewWorkspaceSpec(displayName string, resources []WorkspaceResourceIdentifier, dashboardRef string) WorkspaceSpec {
@@ -82,6 +119,14 @@ const WorkspaceConditionResourcesValid = "ResourcesValid"
 // condition; the K8s API server cannot enforce cross-object references itself.
 const WorkspaceConditionDashboardRefValid = "DashboardRefValid"
 
+// WorkspaceConditionQuotaApplied reports whether the ResourceQuota and
+// LimitRange derived from spec.quota exist in every namespace of the workspace.
+const WorkspaceConditionQuotaApplied = "QuotaApplied"
+
+// WorkspaceConditionQuotaWithinBudget reports the aggregated usage of all
+// namespaces against spec.quota. It turns False once any limit is exhausted.
+const WorkspaceConditionQuotaWithinBudget = "QuotaWithinBudget"
+
 type WorkspaceStatus struct {
 	// Conditions reports the results of the reconciler's integrity checks.
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
@@ -0,0 +1,248 @@
+package v1alpha1
+
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+// ╭───────────────────────╮
+// │ CRD: WorkspaceBackup  │
+// ╰───────────────────────╯
+
+// WorkspaceBackupPhase is the lifecycle state of a WorkspaceBackup.
+type WorkspaceBackupPhase string
+
+const (
+	WorkspaceBackupPhaseRunning   WorkspaceBackupPhase = "Running"
+	WorkspaceBackupPhaseCompleted WorkspaceBackupPhase = "Completed"
+	WorkspaceBackupPhaseFailed    WorkspaceBackupPhase = "Failed"
+)
+
+const (
+	// WorkspaceBackupConditionCompleted reports whether the archive (and the
+	// requested volume snapshots) were written.
+	WorkspaceBackupConditionCompleted = "Completed"
+
+	// BackupScheduleConditionReady reports whether the schedule is valid and
+	// creating backups.
+	BackupScheduleConditionReady = "Ready"
+
+	// BackupScheduleLabel is set on every WorkspaceBackup a BackupSchedule
+	// created and drives the retention of that schedule.
+	BackupScheduleLabel = "mogenius.com/backup-schedule"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type WorkspaceBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	metav1.ListMeta `json:"metadata"`
+
+	Items []WorkspaceBackup `json:"items"`
+}
+
+// A mogenius WorkspaceBackup exports every resource in the namespaces of a
+// Workspace into a versioned tarball and optionally snapshots the data of its
+// PersistentVolumeClaims. A backup runs once; deleting the resource also removes
+// the archive and the volume snapshots it created.
+// WorkspaceBackups are only processed in the operator's own namespace (MO_OWN_NAMESPACE).
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced,shortName=wsbackup,categories=mogenius
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Workspace",type=string,JSONPath=`.spec.workspace`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Resources",type=integer,JSONPath=`.status.resourceCount`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type WorkspaceBackup struct {
+	metav1.TypeMeta `json:",inline"`
+
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec WorkspaceBackupSpec `json:"spec"`
+
+	Status WorkspaceBackupStatus `json:"status,omitempty"`
+}
+
+type WorkspaceBackupSpec struct {
+	// Name of the Workspace (in the same namespace) to back up.
+	// +kubebuilder:validation:MinLength=1
+	Workspace string `json:"workspace"`
+
+	// Where the archive is written to.
+	Storage BackupStorage `json:"storage"`
+
+	// SnapshotVolumes creates a CSI VolumeSnapshot of every bound
+	// PersistentVolumeClaim in the workspace namespaces.
+	SnapshotVolumes bool `json:"snapshotVolumes,omitempty"`
+
+	// VolumeSnapshotClassName used for the snapshots. The cluster default class
+	// is used when empty.
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+
+	// ExcludedKinds lists resource kinds (e.g. "Secret") left out of the archive.
+	ExcludedKinds []string `json:"excludedKinds,omitempty"`
+}
+
+// BackupStorage selects the backup target. Exactly one of PVC or S3 must be set.
+type BackupStorage struct {
+	// PVC writes the archive to the backup volume mounted into the operator
+	// (MO_BACKUP_PATH, see the chart value features.backups.persistence).
+	PVC *BackupPVCStorage `json:"pvc,omitempty"`
+
+	// S3 uploads the archive to an S3-compatible object store (AWS S3, MinIO, ...).
+	S3 *BackupS3Storage `json:"s3,omitempty"`
+}
+
+type BackupPVCStorage struct {
+	// Directory below the backup volume. Must not leave the volume.
+	Path string `json:"path,omitempty"`
+}
+
+type BackupS3Storage struct {
+	// Endpoint URL of the object store, e.g. "https://s3.eu-central-1.amazonaws.com"
+	// or "http://minio.minio.svc:9000". Buckets are addressed path-style.
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+
+	// Bucket the archive is uploaded to. It must exist.
+	// +kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+
+	// Region used to sign requests.
+	// +kubebuilder:default="us-east-1"
+	Region string `json:"region,omitempty"`
+
+	// Prefix prepended to every object key.
+	Prefix string `json:"prefix,omitempty"`
+
+	// Secret in the same namespace holding the AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY keys.
+	// +kubebuilder:validation:MinLength=1
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// BackupVolumeSnapshot records a VolumeSnapshot taken for a backup.
+type BackupVolumeSnapshot struct {
+	// Namespace of the claim and the snapshot.
+	Namespace string `json:"namespace"`
+
+	// PersistentVolumeClaim the snapshot was taken from.
+	PersistentVolumeClaim string `json:"persistentVolumeClaim"`
+
+	// Name of the VolumeSnapshot.
+	Name string `json:"name"`
+}
+
+type WorkspaceBackupStatus struct {
+	// Conditions report the state of the backup; "Completed" is true once the
+	// archive was written.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Phase of the backup.
+	// +kubebuilder:validation:Enum=Running;Completed;Failed
+	// +optional
+	Phase WorkspaceBackupPhase `json:"phase,omitempty"`
+
+	// StartedAt is when the operator started the backup.
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// CompletedAt is when the backup completed or failed.
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+
+	// Archive is the object key of the tarball within the configured storage.
+	// +optional
+	Archive string `json:"archive,omitempty"`
+
+	// FormatVersion of the archive layout.
+	// +optional
+	FormatVersion int `json:"formatVersion,omitempty"`
+
+	// SizeBytes is the size of the compressed archive.
+	// +optional
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+
+	// Namespaces that were backed up.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// ResourceCount is the number of resources in the archive.
+	// +optional
+	ResourceCount int `json:"resourceCount,omitempty"`
+
+	// VolumeSnapshots taken for this backup.
+	// +optional
+	VolumeSnapshots []BackupVolumeSnapshot `json:"volumeSnapshots,omitempty"`
+}
+
+// ╭──────────────────────╮
+// │ CRD: BackupSchedule  │
+// ╰──────────────────────╯
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type BackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	metav1.ListMeta `json:"metadata"`
+
+	Items []BackupSchedule `json:"items"`
+}
+
+// A mogenius BackupSchedule creates WorkspaceBackups from a template on a cron
+// schedule and deletes the oldest finished ones beyond spec.keepLast.
+// BackupSchedules are only processed in the operator's own namespace (MO_OWN_NAMESPACE).
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Namespaced,shortName=backupschedule,categories=mogenius
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Workspace",type=string,JSONPath=`.spec.template.workspace`
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Suspended",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="Last Backup",type=string,JSONPath=`.status.lastBackup`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type BackupSchedule struct {
+	metav1.TypeMeta `json:",inline"`
+
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec BackupScheduleSpec `json:"spec"`
+
+	Status BackupScheduleStatus `json:"status,omitempty"`
+}
+
+type BackupScheduleSpec struct {
+	// Schedule in standard cron format (e.g. "0 3 * * *"), evaluated in UTC.
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// Template of the WorkspaceBackups created by this schedule.
+	Template WorkspaceBackupSpec `json:"template"`
+
+	// KeepLast is the number of finished backups kept; older ones are deleted
+	// together with their archives.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	KeepLast int `json:"keepLast,omitempty"`
+
+	// Suspend stops creating new backups without deleting existing ones.
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+type BackupScheduleStatus struct {
+	// Conditions report the state of the schedule; "Ready" indicates a valid
+	// schedule.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastScheduleTime is when the last backup was created.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// NextScheduleTime is when the next backup is due.
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// LastBackup is the name of the most recently created WorkspaceBackup.
+	// +optional
+	LastBackup string `json:"lastBackup,omitempty"`
+}
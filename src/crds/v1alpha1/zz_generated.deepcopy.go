@@ -5,6 +5,7 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -41,6 +42,11 @@ func (in *Agent) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AgentBuiltinTools) DeepCopyInto(out *AgentBuiltinTools) {
 	*out = *in
+	if in.ToolPolicies != nil {
+		in, out := &in.ToolPolicies, &out.ToolPolicies
+		*out = make([]MCPToolPolicy, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentBuiltinTools.
@@ -111,6 +117,27 @@ func (in *AgentList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentMetricTrigger) DeepCopyInto(out *AgentMetricTrigger) {
+	*out = *in
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.MinInterval = in.MinInterval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentMetricTrigger.
+func (in *AgentMetricTrigger) DeepCopy() *AgentMetricTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentMetricTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AgentScope) DeepCopyInto(out *AgentScope) {
 	*out = *in
@@ -187,7 +214,7 @@ func (in *AgentTools) DeepCopyInto(out *AgentTools) {
 	if in.Builtin != nil {
 		in, out := &in.Builtin, &out.Builtin
 		*out = new(AgentBuiltinTools)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.McpServerRefs != nil {
 		in, out := &in.McpServerRefs, &out.McpServerRefs
@@ -214,6 +241,16 @@ func (in *AgentTriggers) DeepCopyInto(out *AgentTriggers) {
 		*out = new(AgentChangeTrigger)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.OnMetric != nil {
+		in, out := &in.OnMetric, &out.OnMetric
+		*out = new(AgentMetricTrigger)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OnVulnerability != nil {
+		in, out := &in.OnVulnerability, &out.OnVulnerability
+		*out = new(AgentVulnerabilityTrigger)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentTriggers.
@@ -226,6 +263,22 @@ func (in *AgentTriggers) DeepCopy() *AgentTriggers {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentVulnerabilityTrigger) DeepCopyInto(out *AgentVulnerabilityTrigger) {
+	*out = *in
+	out.MinInterval = in.MinInterval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentVulnerabilityTrigger.
+func (in *AgentVulnerabilityTrigger) DeepCopy() *AgentVulnerabilityTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentVulnerabilityTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AiModel) DeepCopyInto(out *AiModel) {
 	*out = *in
@@ -392,6 +445,181 @@ func (in *ArgoCDInstallConfig) DeepCopy() *ArgoCDInstallConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupPVCStorage) DeepCopyInto(out *BackupPVCStorage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupPVCStorage.
+func (in *BackupPVCStorage) DeepCopy() *BackupPVCStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupPVCStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupS3Storage) DeepCopyInto(out *BackupS3Storage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupS3Storage.
+func (in *BackupS3Storage) DeepCopy() *BackupS3Storage {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupS3Storage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupSchedule) DeepCopyInto(out *BackupSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupSchedule.
+func (in *BackupSchedule) DeepCopy() *BackupSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BackupSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupScheduleList) DeepCopyInto(out *BackupScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]BackupSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupScheduleList.
+func (in *BackupScheduleList) DeepCopy() *BackupScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BackupScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupScheduleSpec) DeepCopyInto(out *BackupScheduleSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupScheduleSpec.
+func (in *BackupScheduleSpec) DeepCopy() *BackupScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupScheduleStatus) DeepCopyInto(out *BackupScheduleStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextScheduleTime != nil {
+		in, out := &in.NextScheduleTime, &out.NextScheduleTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupScheduleStatus.
+func (in *BackupScheduleStatus) DeepCopy() *BackupScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupStorage) DeepCopyInto(out *BackupStorage) {
+	*out = *in
+	if in.PVC != nil {
+		in, out := &in.PVC, &out.PVC
+		*out = new(BackupPVCStorage)
+		**out = **in
+	}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(BackupS3Storage)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStorage.
+func (in *BackupStorage) DeepCopy() *BackupStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupVolumeSnapshot) DeepCopyInto(out *BackupVolumeSnapshot) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupVolumeSnapshot.
+func (in *BackupVolumeSnapshot) DeepCopy() *BackupVolumeSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupVolumeSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertManagerConfig) DeepCopyInto(out *CertManagerConfig) {
 	*out = *in
@@ -1151,7 +1379,7 @@ func (in *McpServerStatus) DeepCopy() *McpServerStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PlatformConfig) DeepCopyInto(out *PlatformConfig) {
+func (in *NotificationChannel) DeepCopyInto(out *NotificationChannel) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -1159,18 +1387,18 @@ func (in *PlatformConfig) DeepCopyInto(out *PlatformConfig) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformConfig.
-func (in *PlatformConfig) DeepCopy() *PlatformConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationChannel.
+func (in *NotificationChannel) DeepCopy() *NotificationChannel {
 	if in == nil {
 		return nil
 	}
-	out := new(PlatformConfig)
+	out := new(NotificationChannel)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *PlatformConfig) DeepCopyObject() runtime.Object {
+func (in *NotificationChannel) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -1178,31 +1406,31 @@ func (in *PlatformConfig) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PlatformConfigList) DeepCopyInto(out *PlatformConfigList) {
+func (in *NotificationChannelList) DeepCopyInto(out *NotificationChannelList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]PlatformConfig, len(*in))
+		*out = make([]NotificationChannel, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformConfigList.
-func (in *PlatformConfigList) DeepCopy() *PlatformConfigList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationChannelList.
+func (in *NotificationChannelList) DeepCopy() *NotificationChannelList {
 	if in == nil {
 		return nil
 	}
-	out := new(PlatformConfigList)
+	out := new(NotificationChannelList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *PlatformConfigList) DeepCopyObject() runtime.Object {
+func (in *NotificationChannelList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -1210,82 +1438,46 @@ func (in *PlatformConfigList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PlatformConfigPatchReference) DeepCopyInto(out *PlatformConfigPatchReference) {
-	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformConfigPatchReference.
-func (in *PlatformConfigPatchReference) DeepCopy() *PlatformConfigPatchReference {
-	if in == nil {
-		return nil
-	}
-	out := new(PlatformConfigPatchReference)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PlatformConfigSpec) DeepCopyInto(out *PlatformConfigSpec) {
+func (in *NotificationChannelSpec) DeepCopyInto(out *NotificationChannelSpec) {
 	*out = *in
-	if in.GitOps != nil {
-		in, out := &in.GitOps, &out.GitOps
-		*out = new(GitOpsConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.CertManager != nil {
-		in, out := &in.CertManager, &out.CertManager
-		*out = new(CertManagerConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Traefik != nil {
-		in, out := &in.Traefik, &out.Traefik
-		*out = new(TraefikConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.ExternalDNS != nil {
-		in, out := &in.ExternalDNS, &out.ExternalDNS
-		*out = new(ExternalDNSConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.KubePrometheusStack != nil {
-		in, out := &in.KubePrometheusStack, &out.KubePrometheusStack
-		*out = new(KubePrometheusStackConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Loki != nil {
-		in, out := &in.Loki, &out.Loki
-		*out = new(LokiConfig)
-		(*in).DeepCopyInto(*out)
+	if in.UrlSecretRef != nil {
+		in, out := &in.UrlSecretRef, &out.UrlSecretRef
+		*out = new(SecretReference)
+		**out = **in
 	}
-	if in.Alloy != nil {
-		in, out := &in.Alloy, &out.Alloy
-		*out = new(AlloyConfig)
-		(*in).DeepCopyInto(*out)
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
-	if in.RenovateOperator != nil {
-		in, out := &in.RenovateOperator, &out.RenovateOperator
-		*out = new(RenovateOperatorConfig)
+	if in.Smtp != nil {
+		in, out := &in.Smtp, &out.Smtp
+		*out = new(NotificationSmtpConfig)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.ExternalSecretsOperator != nil {
-		in, out := &in.ExternalSecretsOperator, &out.ExternalSecretsOperator
-		*out = new(ExternalSecretsOperatorConfig)
-		(*in).DeepCopyInto(*out)
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]NotificationRoute, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformConfigSpec.
-func (in *PlatformConfigSpec) DeepCopy() *PlatformConfigSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationChannelSpec.
+func (in *NotificationChannelSpec) DeepCopy() *NotificationChannelSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PlatformConfigSpec)
+	out := new(NotificationChannelSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PlatformConfigStatus) DeepCopyInto(out *PlatformConfigStatus) {
+func (in *NotificationChannelStatus) DeepCopyInto(out *NotificationChannelStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -1294,25 +1486,241 @@ func (in *PlatformConfigStatus) DeepCopyInto(out *PlatformConfigStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.GitOpsStatus != nil {
-		in, out := &in.GitOpsStatus, &out.GitOpsStatus
-		*out = new(GitOpsStatus)
-		(*in).DeepCopyInto(*out)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformConfigStatus.
-func (in *PlatformConfigStatus) DeepCopy() *PlatformConfigStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationChannelStatus.
+func (in *NotificationChannelStatus) DeepCopy() *NotificationChannelStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PlatformConfigStatus)
+	out := new(NotificationChannelStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PlatformPatch) DeepCopyInto(out *PlatformPatch) {
+func (in *NotificationRoute) DeepCopyInto(out *NotificationRoute) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Workspaces != nil {
+		in, out := &in.Workspaces, &out.Workspaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Agents != nil {
+		in, out := &in.Agents, &out.Agents
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Patterns != nil {
+		in, out := &in.Patterns, &out.Patterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationRoute.
+func (in *NotificationRoute) DeepCopy() *NotificationRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationSmtpConfig) DeepCopyInto(out *NotificationSmtpConfig) {
+	*out = *in
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(SecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationSmtpConfig.
+func (in *NotificationSmtpConfig) DeepCopy() *NotificationSmtpConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationSmtpConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlatformConfig) DeepCopyInto(out *PlatformConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformConfig.
+func (in *PlatformConfig) DeepCopy() *PlatformConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PlatformConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PlatformConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlatformConfigList) DeepCopyInto(out *PlatformConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PlatformConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformConfigList.
+func (in *PlatformConfigList) DeepCopy() *PlatformConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(PlatformConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PlatformConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlatformConfigPatchReference) DeepCopyInto(out *PlatformConfigPatchReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformConfigPatchReference.
+func (in *PlatformConfigPatchReference) DeepCopy() *PlatformConfigPatchReference {
+	if in == nil {
+		return nil
+	}
+	out := new(PlatformConfigPatchReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlatformConfigSpec) DeepCopyInto(out *PlatformConfigSpec) {
+	*out = *in
+	if in.GitOps != nil {
+		in, out := &in.GitOps, &out.GitOps
+		*out = new(GitOpsConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CertManager != nil {
+		in, out := &in.CertManager, &out.CertManager
+		*out = new(CertManagerConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Traefik != nil {
+		in, out := &in.Traefik, &out.Traefik
+		*out = new(TraefikConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExternalDNS != nil {
+		in, out := &in.ExternalDNS, &out.ExternalDNS
+		*out = new(ExternalDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KubePrometheusStack != nil {
+		in, out := &in.KubePrometheusStack, &out.KubePrometheusStack
+		*out = new(KubePrometheusStackConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Loki != nil {
+		in, out := &in.Loki, &out.Loki
+		*out = new(LokiConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Alloy != nil {
+		in, out := &in.Alloy, &out.Alloy
+		*out = new(AlloyConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RenovateOperator != nil {
+		in, out := &in.RenovateOperator, &out.RenovateOperator
+		*out = new(RenovateOperatorConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExternalSecretsOperator != nil {
+		in, out := &in.ExternalSecretsOperator, &out.ExternalSecretsOperator
+		*out = new(ExternalSecretsOperatorConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformConfigSpec.
+func (in *PlatformConfigSpec) DeepCopy() *PlatformConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PlatformConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlatformConfigStatus) DeepCopyInto(out *PlatformConfigStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.GitOpsStatus != nil {
+		in, out := &in.GitOpsStatus, &out.GitOpsStatus
+		*out = new(GitOpsStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformConfigStatus.
+func (in *PlatformConfigStatus) DeepCopy() *PlatformConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PlatformConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlatformPatch) DeepCopyInto(out *PlatformPatch) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -1412,6 +1820,158 @@ func (in *PlatformPatchStatus) DeepCopy() *PlatformPatchStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Policy) DeepCopyInto(out *Policy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policy.
+func (in *Policy) DeepCopy() *Policy {
+	if in == nil {
+		return nil
+	}
+	out := new(Policy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Policy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyList) DeepCopyInto(out *PolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Policy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyList.
+func (in *PolicyList) DeepCopy() *PolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyMatch) DeepCopyInto(out *PolicyMatch) {
+	*out = *in
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Workspaces != nil {
+		in, out := &in.Workspaces, &out.Workspaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Operations != nil {
+		in, out := &in.Operations, &out.Operations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyMatch.
+func (in *PolicyMatch) DeepCopy() *PolicyMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicySpec) DeepCopyInto(out *PolicySpec) {
+	*out = *in
+	in.Match.DeepCopyInto(&out.Match)
+	if in.Validations != nil {
+		in, out := &in.Validations, &out.Validations
+		*out = make([]PolicyValidation, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicySpec.
+func (in *PolicySpec) DeepCopy() *PolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyStatus) DeepCopyInto(out *PolicyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyStatus.
+func (in *PolicyStatus) DeepCopy() *PolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyValidation) DeepCopyInto(out *PolicyValidation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyValidation.
+func (in *PolicyValidation) DeepCopy() *PolicyValidation {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyValidation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RenovateJobConfig) DeepCopyInto(out *RenovateJobConfig) {
 	*out = *in
@@ -1871,6 +2431,126 @@ func (in *Workspace) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkspaceBackup) DeepCopyInto(out *WorkspaceBackup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceBackup.
+func (in *WorkspaceBackup) DeepCopy() *WorkspaceBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkspaceBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WorkspaceBackup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkspaceBackupList) DeepCopyInto(out *WorkspaceBackupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]WorkspaceBackup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceBackupList.
+func (in *WorkspaceBackupList) DeepCopy() *WorkspaceBackupList {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkspaceBackupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WorkspaceBackupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkspaceBackupSpec) DeepCopyInto(out *WorkspaceBackupSpec) {
+	*out = *in
+	in.Storage.DeepCopyInto(&out.Storage)
+	if in.ExcludedKinds != nil {
+		in, out := &in.ExcludedKinds, &out.ExcludedKinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceBackupSpec.
+func (in *WorkspaceBackupSpec) DeepCopy() *WorkspaceBackupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkspaceBackupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkspaceBackupStatus) DeepCopyInto(out *WorkspaceBackupStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.VolumeSnapshots != nil {
+		in, out := &in.VolumeSnapshots, &out.VolumeSnapshots
+		*out = make([]BackupVolumeSnapshot, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceBackupStatus.
+func (in *WorkspaceBackupStatus) DeepCopy() *WorkspaceBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkspaceBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkspaceDashboard) DeepCopyInto(out *WorkspaceDashboard) {
 	*out = *in
@@ -2011,6 +2691,60 @@ func (in *WorkspaceList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkspaceQuota) DeepCopyInto(out *WorkspaceQuota) {
+	*out = *in
+	if in.CPU != nil {
+		in, out := &in.CPU, &out.CPU
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Memory != nil {
+		in, out := &in.Memory, &out.Memory
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = new(int64)
+		**out = **in
+	}
+	if in.LoadBalancers != nil {
+		in, out := &in.LoadBalancers, &out.LoadBalancers
+		*out = new(int64)
+		**out = **in
+	}
+	if in.DefaultRequest != nil {
+		in, out := &in.DefaultRequest, &out.DefaultRequest
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.DefaultLimit != nil {
+		in, out := &in.DefaultLimit, &out.DefaultLimit
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceQuota.
+func (in *WorkspaceQuota) DeepCopy() *WorkspaceQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkspaceQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkspaceResourceIdentifier) DeepCopyInto(out *WorkspaceResourceIdentifier) {
 	*out = *in
@@ -2034,6 +2768,11 @@ func (in *WorkspaceSpec) DeepCopyInto(out *WorkspaceSpec) {
 		*out = make([]WorkspaceResourceIdentifier, len(*in))
 		copy(*out, *in)
 	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = new(WorkspaceQuota)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceSpec.
@@ -0,0 +1,313 @@
+// Package deprecations knows the Kubernetes APIs that are deprecated or
+// removed and checks objects — live, from Helm release manifests or from
+// GitOps inventories — against the version a cluster is upgraded to.
+package deprecations
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Api is a deprecated apiVersion of a kind. Versions are Kubernetes 1.x minor
+// versions; RemovedIn is 0 while no removal is scheduled.
+type Api struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Replacement is the apiVersion to migrate to, empty when the kind is
+	// gone without replacement (PodSecurityPolicy).
+	Replacement  string `json:"replacement,omitempty"`
+	DeprecatedIn int    `json:"deprecatedIn"`
+	RemovedIn    int    `json:"removedIn,omitempty"`
+	// Unserved marks CRD versions the cluster no longer serves.
+	Unserved bool `json:"unserved,omitempty"`
+}
+
+// builtinApis follows the Kubernetes deprecated API migration guide; alpha
+// APIs are left out.
+var builtinApis = []Api{
+	// removed in 1.16
+	{ApiVersion: "extensions/v1beta1", Kind: "DaemonSet", Replacement: "apps/v1", DeprecatedIn: 9, RemovedIn: 16},
+	{ApiVersion: "extensions/v1beta1", Kind: "Deployment", Replacement: "apps/v1", DeprecatedIn: 9, RemovedIn: 16},
+	{ApiVersion: "extensions/v1beta1", Kind: "ReplicaSet", Replacement: "apps/v1", DeprecatedIn: 9, RemovedIn: 16},
+	{ApiVersion: "extensions/v1beta1", Kind: "NetworkPolicy", Replacement: "networking.k8s.io/v1", DeprecatedIn: 9, RemovedIn: 16},
+	{ApiVersion: "extensions/v1beta1", Kind: "PodSecurityPolicy", Replacement: "policy/v1beta1", DeprecatedIn: 10, RemovedIn: 16},
+	{ApiVersion: "apps/v1beta1", Kind: "Deployment", Replacement: "apps/v1", DeprecatedIn: 9, RemovedIn: 16},
+	{ApiVersion: "apps/v1beta1", Kind: "StatefulSet", Replacement: "apps/v1", DeprecatedIn: 9, RemovedIn: 16},
+	{ApiVersion: "apps/v1beta2", Kind: "DaemonSet", Replacement: "apps/v1", DeprecatedIn: 9, RemovedIn: 16},
+	{ApiVersion: "apps/v1beta2", Kind: "Deployment", Replacement: "apps/v1", DeprecatedIn: 9, RemovedIn: 16},
+	{ApiVersion: "apps/v1beta2", Kind: "ReplicaSet", Replacement: "apps/v1", DeprecatedIn: 9, RemovedIn: 16},
+	{ApiVersion: "apps/v1beta2", Kind: "StatefulSet", Replacement: "apps/v1", DeprecatedIn: 9, RemovedIn: 16},
+	// removed in 1.22
+	{ApiVersion: "admissionregistration.k8s.io/v1beta1", Kind: "MutatingWebhookConfiguration", Replacement: "admissionregistration.k8s.io/v1", DeprecatedIn: 16, RemovedIn: 22},
+	{ApiVersion: "admissionregistration.k8s.io/v1beta1", Kind: "ValidatingWebhookConfiguration", Replacement: "admissionregistration.k8s.io/v1", DeprecatedIn: 16, RemovedIn: 22},
+	{ApiVersion: "apiextensions.k8s.io/v1beta1", Kind: "CustomResourceDefinition", Replacement: "apiextensions.k8s.io/v1", DeprecatedIn: 16, RemovedIn: 22},
+	{ApiVersion: "apiregistration.k8s.io/v1beta1", Kind: "APIService", Replacement: "apiregistration.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "authentication.k8s.io/v1beta1", Kind: "TokenReview", Replacement: "authentication.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "authorization.k8s.io/v1beta1", Kind: "LocalSubjectAccessReview", Replacement: "authorization.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "authorization.k8s.io/v1beta1", Kind: "SelfSubjectAccessReview", Replacement: "authorization.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "authorization.k8s.io/v1beta1", Kind: "SubjectAccessReview", Replacement: "authorization.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "certificates.k8s.io/v1beta1", Kind: "CertificateSigningRequest", Replacement: "certificates.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "coordination.k8s.io/v1beta1", Kind: "Lease", Replacement: "coordination.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "extensions/v1beta1", Kind: "Ingress", Replacement: "networking.k8s.io/v1", DeprecatedIn: 14, RemovedIn: 22},
+	{ApiVersion: "networking.k8s.io/v1beta1", Kind: "Ingress", Replacement: "networking.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "networking.k8s.io/v1beta1", Kind: "IngressClass", Replacement: "networking.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRole", Replacement: "rbac.authorization.k8s.io/v1", DeprecatedIn: 17, RemovedIn: 22},
+	{ApiVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "ClusterRoleBinding", Replacement: "rbac.authorization.k8s.io/v1", DeprecatedIn: 17, RemovedIn: 22},
+	{ApiVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "Role", Replacement: "rbac.authorization.k8s.io/v1", DeprecatedIn: 17, RemovedIn: 22},
+	{ApiVersion: "rbac.authorization.k8s.io/v1beta1", Kind: "RoleBinding", Replacement: "rbac.authorization.k8s.io/v1", DeprecatedIn: 17, RemovedIn: 22},
+	{ApiVersion: "scheduling.k8s.io/v1beta1", Kind: "PriorityClass", Replacement: "scheduling.k8s.io/v1", DeprecatedIn: 14, RemovedIn: 22},
+	{ApiVersion: "storage.k8s.io/v1beta1", Kind: "CSIDriver", Replacement: "storage.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "storage.k8s.io/v1beta1", Kind: "CSINode", Replacement: "storage.k8s.io/v1", DeprecatedIn: 17, RemovedIn: 22},
+	{ApiVersion: "storage.k8s.io/v1beta1", Kind: "StorageClass", Replacement: "storage.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	{ApiVersion: "storage.k8s.io/v1beta1", Kind: "VolumeAttachment", Replacement: "storage.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 22},
+	// removed in 1.25
+	{ApiVersion: "batch/v1beta1", Kind: "CronJob", Replacement: "batch/v1", DeprecatedIn: 21, RemovedIn: 25},
+	{ApiVersion: "discovery.k8s.io/v1beta1", Kind: "EndpointSlice", Replacement: "discovery.k8s.io/v1", DeprecatedIn: 21, RemovedIn: 25},
+	{ApiVersion: "events.k8s.io/v1beta1", Kind: "Event", Replacement: "events.k8s.io/v1", DeprecatedIn: 19, RemovedIn: 25},
+	{ApiVersion: "autoscaling/v2beta1", Kind: "HorizontalPodAutoscaler", Replacement: "autoscaling/v2", DeprecatedIn: 23, RemovedIn: 25},
+	{ApiVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", Replacement: "policy/v1", DeprecatedIn: 21, RemovedIn: 25},
+	{ApiVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: 21, RemovedIn: 25},
+	{ApiVersion: "node.k8s.io/v1beta1", Kind: "RuntimeClass", Replacement: "node.k8s.io/v1", DeprecatedIn: 20, RemovedIn: 25},
+	// removed in 1.26
+	{ApiVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "FlowSchema", Replacement: "flowcontrol.apiserver.k8s.io/v1", DeprecatedIn: 23, RemovedIn: 26},
+	{ApiVersion: "flowcontrol.apiserver.k8s.io/v1beta1", Kind: "PriorityLevelConfiguration", Replacement: "flowcontrol.apiserver.k8s.io/v1", DeprecatedIn: 23, RemovedIn: 26},
+	{ApiVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", Replacement: "autoscaling/v2", DeprecatedIn: 23, RemovedIn: 26},
+	// removed in 1.27
+	{ApiVersion: "storage.k8s.io/v1beta1", Kind: "CSIStorageCapacity", Replacement: "storage.k8s.io/v1", DeprecatedIn: 24, RemovedIn: 27},
+	// removed in 1.29
+	{ApiVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "FlowSchema", Replacement: "flowcontrol.apiserver.k8s.io/v1", DeprecatedIn: 26, RemovedIn: 29},
+	{ApiVersion: "flowcontrol.apiserver.k8s.io/v1beta2", Kind: "PriorityLevelConfiguration", Replacement: "flowcontrol.apiserver.k8s.io/v1", DeprecatedIn: 26, RemovedIn: 29},
+	// removed in 1.32
+	{ApiVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "FlowSchema", Replacement: "flowcontrol.apiserver.k8s.io/v1", DeprecatedIn: 29, RemovedIn: 32},
+	{ApiVersion: "flowcontrol.apiserver.k8s.io/v1beta3", Kind: "PriorityLevelConfiguration", Replacement: "flowcontrol.apiserver.k8s.io/v1", DeprecatedIn: 29, RemovedIn: 32},
+	// deprecated, no removal scheduled
+	{ApiVersion: "v1", Kind: "Endpoints", Replacement: "discovery.k8s.io/v1", DeprecatedIn: 33},
+}
+
+// Index looks up the deprecation of an apiVersion and kind: the built-in
+// APIs and the versions CRDs mark as deprecated or stop serving.
+type Index struct {
+	apis map[string]Api
+}
+
+// NewIndex builds the index from the built-in APIs and the given
+// CustomResourceDefinitions.
+func NewIndex(crds []unstructured.Unstructured) *Index {
+	index := &Index{apis: map[string]Api{}}
+	for _, api := range builtinApis {
+		index.apis[api.ApiVersion+"/"+api.Kind] = api
+	}
+	for _, crd := range crds {
+		for _, api := range crdApis(&crd) {
+			index.apis[api.ApiVersion+"/"+api.Kind] = api
+		}
+	}
+	return index
+}
+
+func (self *Index) Lookup(apiVersion string, kind string) (Api, bool) {
+	api, ok := self.apis[apiVersion+"/"+kind]
+	return api, ok
+}
+
+// crdApis returns the deprecated and unserved versions of a CRD, replaced
+// by its storage version. CRDs carry no schedule, so they count as
+// deprecated now.
+func crdApis(crd *unstructured.Unstructured) []Api {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if group == "" || kind == "" {
+		return nil
+	}
+
+	storage := ""
+	for _, version := range versions {
+		if v, ok := version.(map[string]any); ok && v["storage"] == true {
+			storage, _ = v["name"].(string)
+		}
+	}
+	result := []Api{}
+	for _, version := range versions {
+		v, ok := version.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := v["name"].(string)
+		unserved := v["served"] == false
+		if name == "" || name == storage || (v["deprecated"] != true && !unserved) {
+			continue
+		}
+		api := Api{ApiVersion: group + "/" + name, Kind: kind, Unserved: unserved}
+		if storage != "" {
+			api.Replacement = group + "/" + storage
+		}
+		result = append(result, api)
+	}
+	return result
+}
+
+var gitVersionPattern = regexp.MustCompile(`^v?1\.(\d+)`)
+
+// ParseMinor returns the minor version of a Kubernetes git version such as
+// "v1.31.2-eks-7f9249a".
+func ParseMinor(gitVersion string) (int, error) {
+	match := gitVersionPattern.FindStringSubmatch(gitVersion)
+	if match == nil {
+		return 0, fmt.Errorf("unsupported Kubernetes version %q", gitVersion)
+	}
+	return strconv.Atoi(match[1])
+}
+
+// FormatMinor formats a minor version as "1.<minor>".
+func FormatMinor(minor int) string {
+	return "1." + strconv.Itoa(minor)
+}
+
+// Status of a finding relative to the current and the target version.
+const (
+	// StatusRemoved APIs are no longer served by the cluster; Helm releases
+	// and GitOps sources still using them fail to upgrade or sync.
+	StatusRemoved = "removed"
+	// StatusBlocking APIs are removed by the target version.
+	StatusBlocking = "blocking"
+	// StatusDeprecated APIs are still served by the target version.
+	StatusDeprecated = "deprecated"
+)
+
+// Status returns the status of the API for an upgrade from current to
+// target, or "" while it is not deprecated yet by the target.
+func (self Api) Status(current int, target int) string {
+	switch {
+	case self.Unserved || (self.RemovedIn > 0 && self.RemovedIn <= current):
+		return StatusRemoved
+	case self.RemovedIn > 0 && self.RemovedIn <= target:
+		return StatusBlocking
+	case self.DeprecatedIn <= target:
+		return StatusDeprecated
+	}
+	return ""
+}
+
+func statusRank(status string) int {
+	switch status {
+	case StatusRemoved:
+		return 3
+	case StatusBlocking:
+		return 2
+	case StatusDeprecated:
+		return 1
+	}
+	return 0
+}
+
+// Sources of the objects in a report.
+const (
+	// SourceCluster objects are live objects only served in a deprecated
+	// apiVersion.
+	SourceCluster = "cluster"
+	// SourceHelm objects come from the manifest of a Helm release.
+	SourceHelm = "helm"
+	// SourceGitOps objects come from the inventory of an ArgoCD Application
+	// or Flux Kustomization, i.e. the apiVersion in Git.
+	SourceGitOps = "gitops"
+)
+
+// Object is an object as referenced by a manifest or inventory.
+type Object struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// Owner is the Helm release ("Release"), ArgoCD Application or Flux
+// Kustomization an object comes from.
+type Owner struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+type Finding struct {
+	Object
+	Source      string `json:"source"`
+	Owner       *Owner `json:"owner,omitempty"`
+	Status      string `json:"status"`
+	Replacement string `json:"replacement,omitempty"`
+	// DeprecatedIn and RemovedIn are "1.<minor>", empty when unknown or not
+	// scheduled.
+	DeprecatedIn string `json:"deprecatedIn,omitempty"`
+	RemovedIn    string `json:"removedIn,omitempty"`
+}
+
+// ScopeNamespace is the namespace a finding belongs to: the release
+// namespace for Helm, otherwise the object's namespace (empty for
+// cluster-scoped objects).
+func (self Finding) ScopeNamespace() string {
+	if self.Source == SourceHelm && self.Owner != nil {
+		return self.Owner.Namespace
+	}
+	return self.Namespace
+}
+
+// Check returns the finding of an object using a deprecated API for an
+// upgrade from current to target.
+func (self *Index) Check(object Object, source string, owner *Owner, current int, target int) (Finding, bool) {
+	api, ok := self.Lookup(object.ApiVersion, object.Kind)
+	if !ok {
+		return Finding{}, false
+	}
+	status := api.Status(current, target)
+	if status == "" {
+		return Finding{}, false
+	}
+	finding := Finding{Object: object, Source: source, Owner: owner, Status: status, Replacement: api.Replacement}
+	if api.DeprecatedIn > 0 {
+		finding.DeprecatedIn = FormatMinor(api.DeprecatedIn)
+	}
+	if api.RemovedIn > 0 {
+		finding.RemovedIn = FormatMinor(api.RemovedIn)
+	}
+	return finding, true
+}
+
+type Report struct {
+	ClusterVersion string `json:"clusterVersion"`
+	TargetVersion  string `json:"targetVersion"`
+	// Ready is false while an object uses an API that is removed by the
+	// target version.
+	Ready bool `json:"ready"`
+	// Summary counts the findings per status.
+	Summary  map[string]int `json:"summary"`
+	Findings []Finding      `json:"findings"`
+	// Errors lists the sources that could not be checked, e.g. Helm
+	// releases whose manifest failed to load.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Summarize sorts the findings worst first and sets Ready and Summary.
+func (self *Report) Summarize() {
+	slices.SortFunc(self.Findings, func(a, b Finding) int {
+		if rank := statusRank(b.Status) - statusRank(a.Status); rank != 0 {
+			return rank
+		}
+		if a.Source != b.Source {
+			return strings.Compare(a.Source, b.Source)
+		}
+		return strings.Compare(a.Namespace+"/"+a.Kind+"/"+a.Name, b.Namespace+"/"+b.Kind+"/"+b.Name)
+	})
+	self.Summary = map[string]int{}
+	self.Ready = true
+	for _, finding := range self.Findings {
+		self.Summary[finding.Status]++
+		if finding.Status != StatusDeprecated {
+			self.Ready = false
+		}
+	}
+}
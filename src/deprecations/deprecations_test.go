@@ -0,0 +1,133 @@
+package deprecations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseMinor(t *testing.T) {
+	for version, want := range map[string]int{
+		"v1.31.2":             31,
+		"v1.29.8-eks-a737599": 29,
+		"1.30":                30,
+	} {
+		minor, err := ParseMinor(version)
+		require.NoError(t, err, version)
+		assert.Equal(t, want, minor, version)
+	}
+	_, err := ParseMinor("v2.0.0")
+	assert.Error(t, err)
+}
+
+func TestApiStatus(t *testing.T) {
+	api := Api{DeprecatedIn: 29, RemovedIn: 32}
+	assert.Equal(t, "", api.Status(27, 28))
+	assert.Equal(t, StatusDeprecated, api.Status(28, 29))
+	assert.Equal(t, StatusBlocking, api.Status(30, 32))
+	assert.Equal(t, StatusRemoved, api.Status(32, 33))
+	assert.Equal(t, StatusDeprecated, Api{DeprecatedIn: 33}.Status(33, 38), "no removal scheduled")
+	assert.Equal(t, StatusRemoved, Api{Unserved: true}.Status(30, 31))
+}
+
+func TestIndexCheck(t *testing.T) {
+	crd := unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"group": "example.com",
+			"names": map[string]any{"kind": "Widget"},
+			"versions": []any{
+				map[string]any{"name": "v1alpha1", "served": false, "storage": false},
+				map[string]any{"name": "v1beta1", "served": true, "storage": false, "deprecated": true},
+				map[string]any{"name": "v1", "served": true, "storage": true},
+			},
+		},
+	}}
+	index := NewIndex([]unstructured.Unstructured{crd})
+	owner := &Owner{Kind: "Release", Namespace: "shop", Name: "api"}
+
+	finding, ok := index.Check(Object{ApiVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", Name: "api"}, SourceHelm, owner, 25, 26)
+	require.True(t, ok)
+	assert.Equal(t, StatusBlocking, finding.Status)
+	assert.Equal(t, "autoscaling/v2", finding.Replacement)
+	assert.Equal(t, "1.23", finding.DeprecatedIn)
+	assert.Equal(t, "1.26", finding.RemovedIn)
+	assert.Equal(t, "shop", finding.ScopeNamespace(), "Helm findings belong to the release namespace")
+
+	_, ok = index.Check(Object{ApiVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler"}, SourceHelm, owner, 25, 26)
+	assert.False(t, ok)
+
+	finding, ok = index.Check(Object{ApiVersion: "example.com/v1beta1", Kind: "Widget"}, SourceGitOps, nil, 30, 31)
+	require.True(t, ok)
+	assert.Equal(t, StatusDeprecated, finding.Status)
+	assert.Equal(t, "example.com/v1", finding.Replacement)
+
+	finding, ok = index.Check(Object{ApiVersion: "example.com/v1alpha1", Kind: "Widget"}, SourceGitOps, nil, 30, 31)
+	require.True(t, ok)
+	assert.Equal(t, StatusRemoved, finding.Status)
+}
+
+func TestParseManifest(t *testing.T) {
+	manifest := `---
+# Source: api/templates/hpa.yaml
+apiVersion: autoscaling/v2beta2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: api
+---
+# Source: api/templates/empty.yaml
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: api-reader
+`
+	objects, err := ParseManifest(manifest)
+	require.NoError(t, err)
+	assert.Equal(t, []Object{
+		{ApiVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", Name: "api"},
+		{ApiVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "api-reader"},
+	}, objects)
+}
+
+func TestGitOpsObjects(t *testing.T) {
+	application := &unstructured.Unstructured{Object: map[string]any{
+		"status": map[string]any{"resources": []any{
+			map[string]any{"group": "batch", "version": "v1beta1", "kind": "CronJob", "namespace": "shop", "name": "cleanup"},
+			map[string]any{"version": "v1", "kind": "Service", "namespace": "shop", "name": "api"},
+		}},
+	}}
+	assert.Equal(t, []Object{
+		{ApiVersion: "batch/v1beta1", Kind: "CronJob", Namespace: "shop", Name: "cleanup"},
+		{ApiVersion: "v1", Kind: "Service", Namespace: "shop", Name: "api"},
+	}, ArgoCdObjects(application))
+
+	kustomization := &unstructured.Unstructured{Object: map[string]any{
+		"status": map[string]any{"inventory": map[string]any{"entries": []any{
+			map[string]any{"id": "shop_api_policy_PodDisruptionBudget", "v": "v1beta1"},
+			map[string]any{"id": "_reader_rbac.authorization.k8s.io_ClusterRole", "v": "v1"},
+			map[string]any{"id": "shop_config__ConfigMap", "v": "v1"},
+		}}},
+	}}
+	assert.Equal(t, []Object{
+		{ApiVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", Namespace: "shop", Name: "api"},
+		{ApiVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "reader"},
+		{ApiVersion: "v1", Kind: "ConfigMap", Namespace: "shop", Name: "config"},
+	}, FluxObjects(kustomization))
+}
+
+func TestReportSummarize(t *testing.T) {
+	report := Report{Findings: []Finding{
+		{Object: Object{Kind: "Endpoints", Name: "a"}, Source: SourceCluster, Status: StatusDeprecated},
+		{Object: Object{Kind: "CronJob", Name: "b"}, Source: SourceHelm, Status: StatusBlocking},
+	}}
+	report.Summarize()
+	assert.False(t, report.Ready)
+	assert.Equal(t, "b", report.Findings[0].Name, "worst first")
+	assert.Equal(t, map[string]int{StatusBlocking: 1, StatusDeprecated: 1}, report.Summary)
+
+	report.Findings = report.Findings[1:]
+	report.Summarize()
+	assert.True(t, report.Ready, "deprecated APIs do not block the upgrade")
+}
@@ -0,0 +1,101 @@
+package deprecations
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// ParseManifest returns the objects of a multi-document YAML manifest such
+// as the one of a Helm release. Documents without kind are skipped; objects
+// without namespace keep it empty, as Helm fills it in on install.
+func ParseManifest(manifest string) ([]Object, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(manifest)))
+	result := []Object{}
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		var header struct {
+			ApiVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal(document, &header); err != nil {
+			return result, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if header.Kind == "" {
+			continue
+		}
+		result = append(result, Object{
+			ApiVersion: header.ApiVersion,
+			Kind:       header.Kind,
+			Namespace:  header.Metadata.Namespace,
+			Name:       header.Metadata.Name,
+		})
+	}
+}
+
+// ArgoCdObjects returns the resources of an ArgoCD Application as listed in
+// its status, carrying the apiVersion of the desired state in Git.
+func ArgoCdObjects(application *unstructured.Unstructured) []Object {
+	resources, _, _ := unstructured.NestedSlice(application.Object, "status", "resources")
+	result := []Object{}
+	for _, resource := range resources {
+		r, ok := resource.(map[string]any)
+		if !ok {
+			continue
+		}
+		group, _ := r["group"].(string)
+		version, _ := r["version"].(string)
+		kind, _ := r["kind"].(string)
+		namespace, _ := r["namespace"].(string)
+		name, _ := r["name"].(string)
+		if version == "" || kind == "" {
+			continue
+		}
+		result = append(result, Object{ApiVersion: groupVersion(group, version), Kind: kind, Namespace: namespace, Name: name})
+	}
+	return result
+}
+
+// FluxObjects returns the resources of a Flux Kustomization from its
+// inventory, whose ids are "<namespace>_<name>_<group>_<kind>".
+func FluxObjects(kustomization *unstructured.Unstructured) []Object {
+	entries, _, _ := unstructured.NestedSlice(kustomization.Object, "status", "inventory", "entries")
+	result := []Object{}
+	for _, entry := range entries {
+		e, ok := entry.(map[string]any)
+		if !ok {
+			continue
+		}
+		id, _ := e["id"].(string)
+		version, _ := e["v"].(string)
+		parts := strings.Split(id, "_")
+		if len(parts) != 4 || version == "" {
+			continue
+		}
+		result = append(result, Object{ApiVersion: groupVersion(parts[2], version), Kind: parts[3], Namespace: parts[0], Name: parts[1]})
+	}
+	return result
+}
+
+func groupVersion(group string, version string) string {
+	if group == "" {
+		return version
+	}
+	return group + "/" + version
+}
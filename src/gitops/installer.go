@@ -3,6 +3,8 @@ package gitops
 import (
 	"context"
 	"mogenius-operator/src/k8sclient"
+	"regexp"
+	"strings"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -76,6 +78,23 @@ func NewGitOpsInstaller(engine, namespace string, clientProvider k8sclient.K8sCl
 	}
 }
 
+var repoNameSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
+
+// RepositorySecretName derives a stable, DNS-safe Kubernetes resource name from a repository URL.
+func RepositorySecretName(url string) string {
+	s := strings.ToLower(url)
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git@"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	s = repoNameSanitizer.ReplaceAllString(s, "-")
+	s = strings.Trim(s, "-")
+	// "mo-repo-" prefix is 8 chars; Kubernetes names are max 63 chars.
+	if len(s) > 55 {
+		s = s[:55]
+	}
+	return "mo-repo-" + s
+}
+
 func defaultLabels(component string) map[string]string {
 	return map[string]string{
 		"app.kubernetes.io/managed-by": "mogenius-operator",
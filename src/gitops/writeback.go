@@ -0,0 +1,362 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// Write-back modes. These match GitOpsWriteConfig.mode of the PlatformConfig.
+const (
+	WriteBackModeDirectCommit = "DIRECT_COMMIT"
+	WriteBackModePullRequest  = "PULL_REQUEST"
+)
+
+// ErrManifestNotFound is returned when no YAML file below the source path
+// declares the resource.
+var ErrManifestNotFound = errors.New("manifest not found in repository")
+
+// ErrManifestUnchanged is returned when the change is already in Git.
+var ErrManifestUnchanged = errors.New("manifest in repository already matches")
+
+// Repository is the minimal set of operations write-back needs from a Git
+// hosting provider or a local repository.
+type Repository interface {
+	DefaultBranch(ctx context.Context) (string, error)
+	// ListFiles returns the paths of all files below dir on branch.
+	ListFiles(ctx context.Context, branch string, dir string) ([]string, error)
+	ReadFile(ctx context.Context, branch string, filePath string) (*RepositoryFile, error)
+	// CreateBranch creates branch pointing at the head of base.
+	CreateBranch(ctx context.Context, base string, branch string) error
+	// CommitFile replaces the content of file on branch and returns the SHA
+	// of the new commit.
+	CommitFile(ctx context.Context, branch string, file *RepositoryFile, message string, author CommitAuthor) (string, error)
+	// OpenPullRequest opens a pull or merge request from head into base and
+	// returns its web URL.
+	OpenPullRequest(ctx context.Context, base string, head string, title string, body string) (string, error)
+}
+
+type RepositoryFile struct {
+	Path    string
+	Content []byte
+	// Sha identifies the blob that was read, so providers can reject a
+	// commit when the file changed in between.
+	Sha string
+}
+
+type CommitAuthor struct {
+	Name  string
+	Email string
+}
+
+type WriteBackRequest struct {
+	// Dir is the directory of the repository the GitOps source applies.
+	Dir string
+	// Branch is the branch the GitOps source follows; empty means the
+	// default branch of the repository.
+	Branch string
+	// Mode is WriteBackModeDirectCommit or WriteBackModePullRequest.
+	Mode string
+	// Live is the object as it is in the cluster and Updated the object the
+	// user submitted. Only their difference is applied to the file, so
+	// server-populated fields never end up in Git.
+	Live    *unstructured.Unstructured
+	Updated *unstructured.Unstructured
+	Author  CommitAuthor
+	// RequestedBy is mentioned in the commit message and pull request.
+	RequestedBy string
+}
+
+type WriteBackResult struct {
+	// Mode is "DIRECT_COMMIT" or "PULL_REQUEST".
+	Mode string `json:"mode"`
+	// Branch received the commit: the followed branch for direct commits,
+	// the head branch of the pull request otherwise.
+	Branch         string `json:"branch"`
+	File           string `json:"file"`
+	CommitSha      string `json:"commitSha"`
+	PullRequestUrl string `json:"pullRequestUrl,omitempty"`
+}
+
+// WriteBack applies the change from request.Live to request.Updated to the
+// manifest of the resource in repo and commits it to the followed branch or
+// proposes it in a pull request.
+func WriteBack(ctx context.Context, repo Repository, request WriteBackRequest) (*WriteBackResult, error) {
+	base := request.Branch
+	if base == "" {
+		var err error
+		base, err = repo.DefaultBranch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get default branch: %w", err)
+		}
+	}
+
+	file, content, err := findAndPatchManifest(ctx, repo, base, request)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(file.Content, content) {
+		return nil, ErrManifestUnchanged
+	}
+	file.Content = content
+
+	kind := request.Updated.GetKind()
+	name := request.Updated.GetName()
+	if namespace := request.Updated.GetNamespace(); namespace != "" {
+		name = namespace + "/" + name
+	}
+	title := fmt.Sprintf("Update %s %s", kind, name)
+	message := title
+	if request.RequestedBy != "" {
+		message += "\n\nRequested by " + request.RequestedBy + " via mogenius."
+	}
+
+	result := &WriteBackResult{Mode: request.Mode, File: file.Path}
+	switch request.Mode {
+	case WriteBackModeDirectCommit:
+		result.Branch = base
+		result.CommitSha, err = repo.CommitFile(ctx, base, file, message, request.Author)
+		if err != nil {
+			return nil, fmt.Errorf("failed to commit %s to %s: %w", file.Path, base, err)
+		}
+	case WriteBackModePullRequest:
+		result.Branch = writeBackBranchName(request.Updated, time.Now())
+		if err := repo.CreateBranch(ctx, base, result.Branch); err != nil {
+			return nil, fmt.Errorf("failed to create branch %s: %w", result.Branch, err)
+		}
+		result.CommitSha, err = repo.CommitFile(ctx, result.Branch, file, message, request.Author)
+		if err != nil {
+			return nil, fmt.Errorf("failed to commit %s to %s: %w", file.Path, result.Branch, err)
+		}
+		body := fmt.Sprintf("Updates `%s` in `%s`.", name, file.Path)
+		if request.RequestedBy != "" {
+			body += "\n\nRequested by " + request.RequestedBy + " via mogenius."
+		}
+		result.PullRequestUrl, err = repo.OpenPullRequest(ctx, base, result.Branch, title, body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open pull request from %s: %w", result.Branch, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown write-back mode %q", request.Mode)
+	}
+	return result, nil
+}
+
+// findAndPatchManifest returns the first YAML file below request.Dir that
+// declares the resource, along with its patched content.
+func findAndPatchManifest(ctx context.Context, repo Repository, branch string, request WriteBackRequest) (*RepositoryFile, []byte, error) {
+	files, err := repo.ListFiles(ctx, branch, request.Dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list %q on %s: %w", request.Dir, branch, err)
+	}
+	for _, filePath := range files {
+		if ext := path.Ext(filePath); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		file, err := repo.ReadFile(ctx, branch, filePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+		}
+		content, found, err := PatchManifest(file.Content, request.Live, request.Updated)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to patch %s: %w", filePath, err)
+		}
+		if found {
+			return file, content, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: %s %s/%s below %q", ErrManifestNotFound, request.Updated.GetKind(), request.Updated.GetNamespace(), request.Updated.GetName(), request.Dir)
+}
+
+var yamlDocumentSeparator = regexp.MustCompile(`(?m)^---[ \t]*(#.*)?$`)
+
+// PatchManifest applies the change from live to updated to the document of
+// content that declares the resource. The other documents of the file stay
+// byte for byte as they are; the patched one is re-serialized, which sorts
+// its keys and drops its comments. found is false when no document matches.
+func PatchManifest(content []byte, live *unstructured.Unstructured, updated *unstructured.Unstructured) (patched []byte, found bool, err error) {
+	separators := yamlDocumentSeparator.FindAllIndex(content, -1)
+	start := 0
+	for i := 0; i <= len(separators); i++ {
+		end := len(content)
+		if i < len(separators) {
+			end = separators[i][0]
+		}
+		document := content[start:end]
+		next := end
+		if i < len(separators) {
+			next = separators[i][1]
+		}
+
+		desired := map[string]any{}
+		if err := yaml.Unmarshal(document, &desired); err == nil && manifestMatches(desired, updated) {
+			result, err := patchDocument(desired, live, updated)
+			if err != nil {
+				return nil, true, err
+			}
+			if i > 0 && !bytes.HasPrefix(result, []byte("\n")) {
+				result = append([]byte("\n"), result...)
+			}
+			if end < len(content) && !bytes.HasSuffix(result, []byte("\n")) {
+				result = append(result, '\n')
+			}
+			patched = append(append(append([]byte{}, content[:start]...), result...), content[end:]...)
+			return patched, true, nil
+		}
+		start = next
+	}
+	return content, false, nil
+}
+
+// manifestMatches compares kind, API group, name and - when the manifest
+// sets it - namespace. Manifests often leave the namespace to the GitOps
+// source.
+func manifestMatches(manifest map[string]any, obj *unstructured.Unstructured) bool {
+	doc := unstructured.Unstructured{Object: manifest}
+	if doc.GetKind() != obj.GetKind() || doc.GetName() != obj.GetName() {
+		return false
+	}
+	if doc.GroupVersionKind().Group != obj.GroupVersionKind().Group {
+		return false
+	}
+	return doc.GetNamespace() == "" || doc.GetNamespace() == obj.GetNamespace()
+}
+
+// writeBackServerFields are never part of a change the user made.
+var writeBackServerFields = [][]string{
+	{"status"},
+	{"metadata", "uid"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "managedFields"},
+	{"metadata", "selfLink"},
+}
+
+// patchDocument uses a strategic merge patch for built-in kinds, so list
+// entries like containers are patched by name, and a JSON merge patch for
+// everything else.
+func patchDocument(desired map[string]any, live *unstructured.Unstructured, updated *unstructured.Unstructured) ([]byte, error) {
+	before := map[string]any{}
+	if live != nil {
+		before = live.DeepCopy().Object
+	}
+	after := updated.DeepCopy().Object
+	for _, field := range writeBackServerFields {
+		unstructured.RemoveNestedField(before, field...)
+		unstructured.RemoveNestedField(after, field...)
+	}
+
+	beforeJson, err := json.Marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	afterJson, err := json.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	desiredJson, err := json.Marshal(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	var resultJson []byte
+	if dataStruct, err := scheme.Scheme.New(updated.GroupVersionKind()); err == nil {
+		patch, err := strategicpatch.CreateTwoWayMergePatch(beforeJson, afterJson, dataStruct)
+		if err != nil {
+			return nil, err
+		}
+		resultJson, err = strategicpatch.StrategicMergePatch(desiredJson, patch, dataStruct)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		result := map[string]any{}
+		if err := json.Unmarshal(desiredJson, &result); err != nil {
+			return nil, err
+		}
+		applyMergePatch(result, createMergePatch(before, after))
+		resultJson, err = json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return yaml.JSONToYAML(resultJson)
+}
+
+// createMergePatch returns the RFC 7386 merge patch from before to after.
+func createMergePatch(before map[string]any, after map[string]any) map[string]any {
+	patch := map[string]any{}
+	for key, beforeValue := range before {
+		afterValue, ok := after[key]
+		if !ok {
+			patch[key] = nil
+			continue
+		}
+		beforeMap, beforeIsMap := beforeValue.(map[string]any)
+		afterMap, afterIsMap := afterValue.(map[string]any)
+		if beforeIsMap && afterIsMap {
+			if nested := createMergePatch(beforeMap, afterMap); len(nested) > 0 {
+				patch[key] = nested
+			}
+			continue
+		}
+		if !jsonEqual(beforeValue, afterValue) {
+			patch[key] = afterValue
+		}
+	}
+	for key, afterValue := range after {
+		if _, ok := before[key]; !ok {
+			patch[key] = afterValue
+		}
+	}
+	return patch
+}
+
+func applyMergePatch(target map[string]any, patch map[string]any) {
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		patchMap, ok := value.(map[string]any)
+		if !ok {
+			target[key] = value
+			continue
+		}
+		targetMap, ok := target[key].(map[string]any)
+		if !ok {
+			targetMap = map[string]any{}
+			target[key] = targetMap
+		}
+		applyMergePatch(targetMap, patchMap)
+	}
+}
+
+func jsonEqual(a any, b any) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(x, y)
+}
+
+var branchNameSanitizer = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func writeBackBranchName(obj *unstructured.Unstructured, now time.Time) string {
+	name := strings.ToLower(obj.GetKind() + "-" + obj.GetName())
+	name = strings.Trim(branchNameSanitizer.ReplaceAllString(name, "-"), "-")
+	if len(name) > 60 {
+		name = name[:60]
+	}
+	return fmt.Sprintf("mogenius/%s-%d", name, now.Unix())
+}
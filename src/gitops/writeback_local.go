@@ -0,0 +1,139 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// localRepository writes to a repository on the local filesystem with git
+// plumbing commands, so bare repositories work without a checkout. The
+// operator image ships no git binary; this serves repositories mounted into
+// development setups and tests.
+type localRepository struct {
+	dir string
+}
+
+func (self *localRepository) git(ctx context.Context, env []string, stdin []byte, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir", self.gitDir()}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// gitDir resolves working copies to their .git directory.
+func (self *localRepository) gitDir() string {
+	if info, err := os.Stat(filepath.Join(self.dir, ".git")); err == nil && info.IsDir() {
+		return filepath.Join(self.dir, ".git")
+	}
+	return self.dir
+}
+
+func (self *localRepository) DefaultBranch(ctx context.Context) (string, error) {
+	return self.git(ctx, nil, nil, "symbolic-ref", "--short", "HEAD")
+}
+
+func (self *localRepository) ListFiles(ctx context.Context, branch string, dir string) ([]string, error) {
+	args := []string{"ls-tree", "-r", "--name-only", "refs/heads/" + branch}
+	if dir := cleanRepositoryDir(dir); dir != "" {
+		args = append(args, "--", dir)
+	}
+	output, err := self.git(ctx, nil, nil, args...)
+	if err != nil || output == "" {
+		return []string{}, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+func (self *localRepository) ReadFile(ctx context.Context, branch string, filePath string) (*RepositoryFile, error) {
+	sha, err := self.git(ctx, nil, nil, "rev-parse", "refs/heads/"+branch+":"+filePath)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "git", "--git-dir", self.gitDir(), "cat-file", "blob", sha)
+	content, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return &RepositoryFile{Path: filePath, Content: content, Sha: sha}, nil
+}
+
+func (self *localRepository) CreateBranch(ctx context.Context, base string, branch string) error {
+	sha, err := self.git(ctx, nil, nil, "rev-parse", "refs/heads/"+base)
+	if err != nil {
+		return err
+	}
+	// the empty old value refuses to overwrite an existing branch
+	_, err = self.git(ctx, nil, nil, "update-ref", "refs/heads/"+branch, sha, "")
+	return err
+}
+
+// CommitFile builds the commit in a temporary index and moves the branch
+// only if nobody else moved it meanwhile.
+func (self *localRepository) CommitFile(ctx context.Context, branch string, file *RepositoryFile, message string, author CommitAuthor) (string, error) {
+	parent, err := self.git(ctx, nil, nil, "rev-parse", "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	current, err := self.git(ctx, nil, nil, "rev-parse", parent+":"+file.Path)
+	if err != nil {
+		return "", err
+	}
+	if file.Sha != "" && current != file.Sha {
+		return "", errors.New("the file changed on the branch since it was read")
+	}
+
+	index, err := os.CreateTemp("", "mo-gitops-index-*")
+	if err != nil {
+		return "", err
+	}
+	_ = index.Close()
+	defer os.Remove(index.Name())
+	env := []string{
+		"GIT_INDEX_FILE=" + index.Name(),
+		"GIT_AUTHOR_NAME=" + author.Name,
+		"GIT_AUTHOR_EMAIL=" + author.Email,
+		"GIT_COMMITTER_NAME=" + author.Name,
+		"GIT_COMMITTER_EMAIL=" + author.Email,
+	}
+
+	if _, err := self.git(ctx, env, nil, "read-tree", parent); err != nil {
+		return "", err
+	}
+	blob, err := self.git(ctx, env, file.Content, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return "", err
+	}
+	if _, err := self.git(ctx, env, nil, "update-index", "--cacheinfo", "100644,"+blob+","+file.Path); err != nil {
+		return "", err
+	}
+	tree, err := self.git(ctx, env, nil, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	commit, err := self.git(ctx, env, []byte(message), "commit-tree", tree, "-p", parent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := self.git(ctx, env, nil, "update-ref", "refs/heads/"+branch, commit, parent); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+func (self *localRepository) OpenPullRequest(ctx context.Context, base string, head string, title string, body string) (string, error) {
+	return "", errors.New("local repositories have no pull requests, use mode DIRECT_COMMIT")
+}
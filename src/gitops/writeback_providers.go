@@ -0,0 +1,431 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Git hosting providers. These match GitOpsWriteConfig.provider of the
+// PlatformConfig.
+const (
+	ProviderGitHub = "GIT_HUB"
+	ProviderGitLab = "GIT_LAB"
+	ProviderGitea  = "GITEA"
+)
+
+// NewRepository returns the Repository behind repoUrl. Local repositories
+// (file:// URLs and absolute paths) are written with the git binary, all
+// others through the REST API of provider, which is derived from the host
+// when empty.
+func NewRepository(repoUrl string, provider string, token string, httpClient *http.Client) (Repository, error) {
+	if dir, ok := localRepositoryPath(repoUrl); ok {
+		return &localRepository{dir: dir}, nil
+	}
+
+	remote, err := parseRepositoryUrl(repoUrl)
+	if err != nil {
+		return nil, err
+	}
+	if provider == "" {
+		provider, err = providerFromHost(remote.Host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	owner, name := path.Split(remote.Path)
+	owner = strings.Trim(owner, "/")
+	switch provider {
+	case ProviderGitHub:
+		apiUrl := remote.Scheme + "://" + remote.Host + "/api/v3"
+		if remote.Host == "github.com" {
+			apiUrl = "https://api.github.com"
+		}
+		return &githubRepository{
+			api:   &restClient{baseUrl: apiUrl, headers: map[string]string{"Authorization": "Bearer " + token, "Accept": "application/vnd.github+json"}, http: httpClient},
+			owner: owner,
+			name:  name,
+		}, nil
+	case ProviderGitea:
+		return &githubRepository{
+			api:   &restClient{baseUrl: remote.Scheme + "://" + remote.Host + "/api/v1", headers: map[string]string{"Authorization": "token " + token}, http: httpClient},
+			owner: owner,
+			name:  name,
+			gitea: true,
+		}, nil
+	case ProviderGitLab:
+		return &gitlabRepository{
+			api:     &restClient{baseUrl: remote.Scheme + "://" + remote.Host + "/api/v4", headers: map[string]string{"PRIVATE-TOKEN": token}, http: httpClient},
+			project: url.PathEscape(remote.Path),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported git provider %q, expected %s, %s or %s", provider, ProviderGitHub, ProviderGitLab, ProviderGitea)
+	}
+}
+
+// IsLocalRepository reports whether repoUrl points to the local filesystem,
+// which needs no credentials.
+func IsLocalRepository(repoUrl string) bool {
+	_, ok := localRepositoryPath(repoUrl)
+	return ok
+}
+
+func localRepositoryPath(repoUrl string) (string, bool) {
+	if dir, ok := strings.CutPrefix(repoUrl, "file://"); ok {
+		return dir, true
+	}
+	return repoUrl, filepath.IsAbs(repoUrl)
+}
+
+// parseRepositoryUrl accepts https, ssh and scp-like ("git@host:owner/repo")
+// URLs and returns the web URL of the repository with the path lacking
+// ".git" and surrounding slashes.
+func parseRepositoryUrl(repoUrl string) (*url.URL, error) {
+	raw := repoUrl
+	if !strings.Contains(raw, "://") {
+		userHost, repoPath, ok := strings.Cut(raw, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid repository url %q", repoUrl)
+		}
+		raw = "ssh://" + userHost + "/" + repoPath
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository url %q: %w", repoUrl, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		// the SSH port says nothing about the web port
+		parsed.Scheme = "https"
+		parsed.Host = parsed.Hostname()
+	}
+	parsed.User = nil
+	parsed.Path = strings.Trim(strings.TrimSuffix(strings.TrimSuffix(parsed.Path, "/"), ".git"), "/")
+	if parsed.Host == "" || !strings.Contains(parsed.Path, "/") {
+		return nil, fmt.Errorf("invalid repository url %q: expected <host>/<owner>/<repository>", repoUrl)
+	}
+	return parsed, nil
+}
+
+func providerFromHost(host string) (string, error) {
+	switch {
+	case strings.Contains(host, "github"):
+		return ProviderGitHub, nil
+	case strings.Contains(host, "gitlab"):
+		return ProviderGitLab, nil
+	case strings.Contains(host, "gitea"), strings.Contains(host, "forgejo"), strings.Contains(host, "codeberg"):
+		return ProviderGitea, nil
+	}
+	return "", fmt.Errorf("cannot derive the git provider from host %q, set write.provider", host)
+}
+
+func cleanRepositoryDir(dir string) string {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	return dir
+}
+
+type restClient struct {
+	baseUrl string
+	headers map[string]string
+	http    *http.Client
+}
+
+// do sends body as JSON and decodes the response into result when given.
+func (self *restClient) do(ctx context.Context, method string, endpoint string, query url.Values, body any, result any) (http.Header, error) {
+	target := self.baseUrl + endpoint
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range self.headers {
+		request.Header.Set(key, value)
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := self.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message := strings.TrimSpace(string(data))
+		if len(message) > 512 {
+			message = message[:512]
+		}
+		return nil, fmt.Errorf("%s %s: %s: %s", method, endpoint, response.Status, message)
+	}
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return nil, fmt.Errorf("%s %s: invalid response: %w", method, endpoint, err)
+		}
+	}
+	return response.Header, nil
+}
+
+func escapePath(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// githubRepository talks to the GitHub REST API. Gitea mirrors the parts
+// used here, except for creating branches and paging trees.
+type githubRepository struct {
+	api   *restClient
+	owner string
+	name  string
+	gitea bool
+}
+
+func (self *githubRepository) endpoint(format string, args ...any) string {
+	return "/repos/" + url.PathEscape(self.owner) + "/" + url.PathEscape(self.name) + fmt.Sprintf(format, args...)
+}
+
+func (self *githubRepository) DefaultBranch(ctx context.Context) (string, error) {
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	_, err := self.api.do(ctx, http.MethodGet, self.endpoint(""), nil, nil, &repo)
+	return repo.DefaultBranch, err
+}
+
+func (self *githubRepository) ListFiles(ctx context.Context, branch string, dir string) ([]string, error) {
+	prefix := cleanRepositoryDir(dir)
+	if prefix != "" {
+		prefix += "/"
+	}
+	files := []string{}
+	for page := 1; ; page++ {
+		var tree struct {
+			Tree []struct {
+				Path string `json:"path"`
+				Type string `json:"type"`
+			} `json:"tree"`
+			Truncated bool `json:"truncated"`
+		}
+		query := url.Values{"recursive": {"true"}}
+		if self.gitea {
+			query.Set("page", strconv.Itoa(page))
+		}
+		if _, err := self.api.do(ctx, http.MethodGet, self.endpoint("/git/trees/%s", url.PathEscape(branch)), query, nil, &tree); err != nil {
+			return nil, err
+		}
+		for _, entry := range tree.Tree {
+			if entry.Type == "blob" && strings.HasPrefix(entry.Path, prefix) {
+				files = append(files, entry.Path)
+			}
+		}
+		// GitHub cuts off huge trees instead of paging them
+		if !self.gitea || !tree.Truncated || len(tree.Tree) == 0 {
+			return files, nil
+		}
+	}
+}
+
+func (self *githubRepository) ReadFile(ctx context.Context, branch string, filePath string) (*RepositoryFile, error) {
+	var file struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+		Sha      string `json:"sha"`
+	}
+	_, err := self.api.do(ctx, http.MethodGet, self.endpoint("/contents/%s", escapePath(filePath)), url.Values{"ref": {branch}}, nil, &file)
+	if err != nil {
+		return nil, err
+	}
+	if file.Encoding != "base64" {
+		return nil, fmt.Errorf("unexpected encoding %q of %s", file.Encoding, filePath)
+	}
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+	if err != nil {
+		return nil, err
+	}
+	return &RepositoryFile{Path: filePath, Content: content, Sha: file.Sha}, nil
+}
+
+func (self *githubRepository) CreateBranch(ctx context.Context, base string, branch string) error {
+	if self.gitea {
+		_, err := self.api.do(ctx, http.MethodPost, self.endpoint("/branches"), nil, map[string]string{
+			"new_branch_name": branch,
+			"old_branch_name": base,
+		}, nil)
+		return err
+	}
+
+	var ref struct {
+		Object struct {
+			Sha string `json:"sha"`
+		} `json:"object"`
+	}
+	if _, err := self.api.do(ctx, http.MethodGet, self.endpoint("/git/ref/heads/%s", escapePath(base)), nil, nil, &ref); err != nil {
+		return err
+	}
+	_, err := self.api.do(ctx, http.MethodPost, self.endpoint("/git/refs"), nil, map[string]string{
+		"ref": "refs/heads/" + branch,
+		"sha": ref.Object.Sha,
+	}, nil)
+	return err
+}
+
+func (self *githubRepository) CommitFile(ctx context.Context, branch string, file *RepositoryFile, message string, author CommitAuthor) (string, error) {
+	identity := map[string]string{"name": author.Name, "email": author.Email}
+	var response struct {
+		Commit struct {
+			Sha string `json:"sha"`
+		} `json:"commit"`
+	}
+	_, err := self.api.do(ctx, http.MethodPut, self.endpoint("/contents/%s", escapePath(file.Path)), nil, map[string]any{
+		"message":   message,
+		"content":   base64.StdEncoding.EncodeToString(file.Content),
+		"sha":       file.Sha,
+		"branch":    branch,
+		"author":    identity,
+		"committer": identity,
+	}, &response)
+	return response.Commit.Sha, err
+}
+
+func (self *githubRepository) OpenPullRequest(ctx context.Context, base string, head string, title string, body string) (string, error) {
+	var pull struct {
+		HtmlUrl string `json:"html_url"`
+	}
+	_, err := self.api.do(ctx, http.MethodPost, self.endpoint("/pulls"), nil, map[string]string{
+		"title": title,
+		"head":  head,
+		"base":  base,
+		"body":  body,
+	}, &pull)
+	return pull.HtmlUrl, err
+}
+
+// gitlabRepository talks to the GitLab REST API. project is the URL-escaped
+// path of the project, which GitLab accepts in place of its id.
+type gitlabRepository struct {
+	api     *restClient
+	project string
+}
+
+func (self *gitlabRepository) DefaultBranch(ctx context.Context) (string, error) {
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	_, err := self.api.do(ctx, http.MethodGet, "/projects/"+self.project, nil, nil, &project)
+	return project.DefaultBranch, err
+}
+
+func (self *gitlabRepository) ListFiles(ctx context.Context, branch string, dir string) ([]string, error) {
+	files := []string{}
+	page := "1"
+	for page != "" {
+		var tree []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+		}
+		query := url.Values{"ref": {branch}, "recursive": {"true"}, "per_page": {"100"}, "page": {page}}
+		if dir := cleanRepositoryDir(dir); dir != "" {
+			query.Set("path", dir)
+		}
+		header, err := self.api.do(ctx, http.MethodGet, "/projects/"+self.project+"/repository/tree", query, nil, &tree)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range tree {
+			if entry.Type == "blob" {
+				files = append(files, entry.Path)
+			}
+		}
+		page = header.Get("X-Next-Page")
+	}
+	return files, nil
+}
+
+func (self *gitlabRepository) ReadFile(ctx context.Context, branch string, filePath string) (*RepositoryFile, error) {
+	var file struct {
+		Content      string `json:"content"`
+		Encoding     string `json:"encoding"`
+		LastCommitId string `json:"last_commit_id"`
+	}
+	_, err := self.api.do(ctx, http.MethodGet, "/projects/"+self.project+"/repository/files/"+url.PathEscape(filePath), url.Values{"ref": {branch}}, nil, &file)
+	if err != nil {
+		return nil, err
+	}
+	if file.Encoding != "base64" {
+		return nil, fmt.Errorf("unexpected encoding %q of %s", file.Encoding, filePath)
+	}
+	content, err := base64.StdEncoding.DecodeString(file.Content)
+	if err != nil {
+		return nil, err
+	}
+	// GitLab rejects the commit when the file changed after last_commit_id
+	return &RepositoryFile{Path: filePath, Content: content, Sha: file.LastCommitId}, nil
+}
+
+func (self *gitlabRepository) CreateBranch(ctx context.Context, base string, branch string) error {
+	_, err := self.api.do(ctx, http.MethodPost, "/projects/"+self.project+"/repository/branches", url.Values{"branch": {branch}, "ref": {base}}, nil, nil)
+	return err
+}
+
+func (self *gitlabRepository) CommitFile(ctx context.Context, branch string, file *RepositoryFile, message string, author CommitAuthor) (string, error) {
+	var commit struct {
+		Id string `json:"id"`
+	}
+	_, err := self.api.do(ctx, http.MethodPost, "/projects/"+self.project+"/repository/commits", nil, map[string]any{
+		"branch":         branch,
+		"commit_message": message,
+		"author_name":    author.Name,
+		"author_email":   author.Email,
+		"actions": []map[string]string{{
+			"action":         "update",
+			"file_path":      file.Path,
+			"content":        base64.StdEncoding.EncodeToString(file.Content),
+			"encoding":       "base64",
+			"last_commit_id": file.Sha,
+		}},
+	}, &commit)
+	return commit.Id, err
+}
+
+func (self *gitlabRepository) OpenPullRequest(ctx context.Context, base string, head string, title string, body string) (string, error) {
+	var mergeRequest struct {
+		WebUrl string `json:"web_url"`
+	}
+	_, err := self.api.do(ctx, http.MethodPost, "/projects/"+self.project+"/merge_requests", nil, map[string]any{
+		"source_branch":        head,
+		"target_branch":        base,
+		"title":                title,
+		"description":          body,
+		"remove_source_branch": true,
+	}, &mergeRequest)
+	return mergeRequest.WebUrl, err
+}
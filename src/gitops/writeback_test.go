@@ -0,0 +1,284 @@
+package gitops
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const testManifests = `# shop workloads
+apiVersion: v1
+kind: Service
+metadata:
+  name: web # public
+spec:
+  ports:
+    - port: 80
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.27
+        - name: sidecar
+          image: envoy:1.30
+`
+
+func testWorkloads(t *testing.T) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	t.Helper()
+	live := &unstructured.Unstructured{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+  uid: "1234"
+  resourceVersion: "42"
+spec:
+  replicas: 2
+  revisionHistoryLimit: 10
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.27
+          imagePullPolicy: IfNotPresent
+        - name: sidecar
+          image: envoy:1.30
+          imagePullPolicy: IfNotPresent
+status:
+  readyReplicas: 2
+`), &live.Object))
+	updated := live.DeepCopy()
+	containers, _, _ := unstructured.NestedSlice(updated.Object, "spec", "template", "spec", "containers")
+	containers[0].(map[string]any)["image"] = "nginx:1.28"
+	require.NoError(t, unstructured.SetNestedSlice(updated.Object, containers, "spec", "template", "spec", "containers"))
+	require.NoError(t, unstructured.SetNestedField(updated.Object, int64(3), "spec", "replicas"))
+	require.NoError(t, unstructured.SetNestedField(updated.Object, int64(1), "status", "readyReplicas"))
+	return live, updated
+}
+
+func TestPatchManifest(t *testing.T) {
+	live, updated := testWorkloads(t)
+
+	patched, found, err := PatchManifest([]byte(testManifests), live, updated)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	service, deployment, ok := strings.Cut(string(patched), "\n---\n")
+	require.True(t, ok)
+	// other documents keep their comments and layout
+	assert.Equal(t, strings.Split(testManifests, "\n---\n")[0], service)
+
+	var result map[string]any
+	require.NoError(t, yaml.Unmarshal([]byte(deployment), &result))
+	expected := map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "web"},
+		"spec": map[string]any{
+			"replicas": float64(3),
+			"template": map[string]any{"spec": map[string]any{"containers": []any{
+				map[string]any{"name": "web", "image": "nginx:1.28"},
+				map[string]any{"name": "sidecar", "image": "envoy:1.30"},
+			}}},
+		},
+	}
+	assert.Equal(t, expected, result)
+
+	other := updated.DeepCopy()
+	other.SetName("api")
+	_, found, err = PatchManifest([]byte(testManifests), live, other)
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestPatchManifestCustomResource(t *testing.T) {
+	live := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]any{"name": "w", "namespace": "shop", "uid": "1"},
+		"spec":       map[string]any{"size": int64(1), "color": "red", "defaulted": true},
+	}}
+	updated := live.DeepCopy()
+	_ = unstructured.SetNestedField(updated.Object, int64(2), "spec", "size")
+	unstructured.RemoveNestedField(updated.Object, "spec", "color")
+
+	patched, found, err := PatchManifest([]byte("apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n  namespace: shop\nspec:\n  size: 1\n  color: red\n"), live, updated)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n  namespace: shop\nspec:\n  size: 2\n", string(patched))
+}
+
+func TestParseRepositoryUrl(t *testing.T) {
+	for input, expected := range map[string]string{
+		"https://github.com/mogenius/shop.git":        "https://github.com/mogenius/shop",
+		"git@gitlab.com:group/sub/shop.git":           "https://gitlab.com/group/sub/shop",
+		"ssh://git@gitea.example.com:2222/org/shop":   "https://gitea.example.com/org/shop",
+		"http://user:pw@127.0.0.1:3000/org/shop.git/": "http://127.0.0.1:3000/org/shop",
+	} {
+		parsed, err := parseRepositoryUrl(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, parsed.String(), input)
+	}
+	_, err := parseRepositoryUrl("https://github.com/shop")
+	assert.Error(t, err)
+}
+
+func TestWriteBackLocalBareRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+		return strings.TrimSpace(string(output))
+	}
+	work := filepath.Join(dir, "work")
+	bare := filepath.Join(dir, "shop.git")
+	require.NoError(t, os.MkdirAll(filepath.Join(work, "apps", "shop"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(work, "apps", "shop", "web.yaml"), []byte(testManifests), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(work, "README.md"), []byte("shop\n"), 0o644))
+	run(work, "init", "--quiet", "--initial-branch", "main")
+	run(work, "add", "-A")
+	run(work, "commit", "--quiet", "-m", "initial")
+	run(dir, "clone", "--quiet", "--bare", work, bare)
+
+	repo, err := NewRepository("file://"+bare, "", "", nil)
+	require.NoError(t, err)
+	live, updated := testWorkloads(t)
+	request := WriteBackRequest{
+		Dir:         "./apps/shop",
+		Mode:        WriteBackModeDirectCommit,
+		Live:        live,
+		Updated:     updated,
+		Author:      CommitAuthor{Name: "mogenius git-user", Email: "git@mogenius.com"},
+		RequestedBy: "jane@example.com",
+	}
+	result, err := WriteBack(context.Background(), repo, request)
+	require.NoError(t, err)
+	assert.Equal(t, "main", result.Branch)
+	assert.Equal(t, "apps/shop/web.yaml", result.File)
+	assert.Equal(t, result.CommitSha, run(bare, "rev-parse", "main"))
+	assert.Contains(t, run(bare, "show", "main:apps/shop/web.yaml"), "image: nginx:1.28")
+	assert.Equal(t, "mogenius git-user <git@mogenius.com>", run(bare, "log", "-1", "--format=%an <%ae>", "main"))
+
+	// a second identical write has nothing to commit
+	_, err = WriteBack(context.Background(), repo, request)
+	assert.ErrorIs(t, err, ErrManifestUnchanged)
+}
+
+// fakeGitHub serves the subset of the GitHub API write-back uses from memory.
+type fakeGitHub struct {
+	mu       sync.Mutex
+	branches map[string]map[string]string
+	pulls    []map[string]string
+}
+
+func (self *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	reply := func(v any) { _ = json.NewEncoder(w).Encode(v) }
+	path := strings.TrimPrefix(r.URL.Path, "/api/v3/repos/org/shop")
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		reply(map[string]any{"default_branch": "main"})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/git/trees/"):
+		tree := []map[string]string{}
+		for file := range self.branches[strings.TrimPrefix(path, "/git/trees/")] {
+			tree = append(tree, map[string]string{"path": file, "type": "blob"})
+		}
+		reply(map[string]any{"tree": tree})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/contents/"):
+		content, ok := self.branches[r.URL.Query().Get("ref")][strings.TrimPrefix(path, "/contents/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reply(map[string]any{"content": base64.StdEncoding.EncodeToString([]byte(content)), "encoding": "base64", "sha": "blob-1"})
+	case r.Method == http.MethodGet && path == "/git/ref/heads/main":
+		reply(map[string]any{"object": map[string]any{"sha": "commit-1"}})
+	case r.Method == http.MethodPost && path == "/git/refs":
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		files := map[string]string{}
+		for file, content := range self.branches["main"] {
+			files[file] = content
+		}
+		self.branches[strings.TrimPrefix(body["ref"], "refs/heads/")] = files
+		w.WriteHeader(http.StatusCreated)
+		reply(body)
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/contents/"):
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		content, _ := base64.StdEncoding.DecodeString(body["content"].(string))
+		self.branches[body["branch"].(string)][strings.TrimPrefix(path, "/contents/")] = string(content)
+		reply(map[string]any{"commit": map[string]any{"sha": "commit-2"}})
+	case r.Method == http.MethodPost && path == "/pulls":
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		self.pulls = append(self.pulls, body)
+		w.WriteHeader(http.StatusCreated)
+		reply(map[string]any{"html_url": "https://github.example.com/org/shop/pull/1"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestWriteBackPullRequest(t *testing.T) {
+	provider := &fakeGitHub{branches: map[string]map[string]string{"main": {"apps/web.yaml": testManifests}}}
+	server := httptest.NewServer(provider)
+	defer server.Close()
+
+	repo, err := NewRepository(server.URL+"/org/shop.git", ProviderGitHub, "secret", server.Client())
+	require.NoError(t, err)
+	live, updated := testWorkloads(t)
+	result, err := WriteBack(context.Background(), repo, WriteBackRequest{
+		Dir:     "apps",
+		Mode:    WriteBackModePullRequest,
+		Live:    live,
+		Updated: updated,
+		Author:  CommitAuthor{Name: "mogenius git-user", Email: "git@mogenius.com"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.example.com/org/shop/pull/1", result.PullRequestUrl)
+	assert.Equal(t, "commit-2", result.CommitSha)
+	assert.True(t, strings.HasPrefix(result.Branch, "mogenius/deployment-web-"), result.Branch)
+
+	// main stays untouched until the pull request is merged
+	assert.Equal(t, testManifests, provider.branches["main"]["apps/web.yaml"])
+	assert.Contains(t, provider.branches[result.Branch]["apps/web.yaml"], "image: nginx:1.28")
+	require.Len(t, provider.pulls, 1)
+	assert.Equal(t, "main", provider.pulls[0]["base"])
+	assert.Equal(t, result.Branch, provider.pulls[0]["head"])
+	assert.Equal(t, "Update Deployment shop/web", provider.pulls[0]["title"])
+}
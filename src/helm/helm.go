@@ -260,6 +260,9 @@ type HelmChartInstallUpgradeRequest struct {
 	AuthHost string `json:"authHost,omitempty"`
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
+	// ManifestCheck, when set, validates the rendered manifest before the
+	// release is installed or upgraded; an error aborts it.
+	ManifestCheck func(manifest string) error `json:"-"`
 }
 
 type HelmChartOciInstallUpgradeRequest struct {
@@ -275,6 +278,9 @@ type HelmChartOciInstallUpgradeRequest struct {
 	AuthHost string `json:"authHost,omitempty"` // e.g., "registry-1.docker.io"
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
+	// ManifestCheck, when set, validates the rendered manifest before the
+	// release is installed; an error aborts the install.
+	ManifestCheck func(manifest string) error `json:"-"`
 }
 
 type HelmChartShowRequest struct {
@@ -432,7 +438,7 @@ func CreateHelmChart(helmReleaseName string, helmRepoName string, helmRepoUrl st
 		return addResult, fmt.Errorf("failed to add repository: %s", err.Error())
 	}
 
-	return HelmChartInstall(data)
+	return HelmChartInstall(context.Background(), data)
 }
 
 // Only for internal usage
@@ -441,7 +447,7 @@ func DeleteHelmChart(helmReleaseName string, namespace string) (string, error) {
 		Namespace: namespace,
 		Release:   helmReleaseName,
 	}
-	return HelmReleaseUninstall(data)
+	return HelmReleaseUninstall(context.Background(), data)
 }
 
 func HelmStatus(namespace string, releaseName string) releasecommon.Status {
@@ -940,7 +946,9 @@ func HelmChartVersion(data HelmChartVersionRequest) ([]HelmChartInfo, error) {
 	return allCharts, nil
 }
 
-func HelmOciInstall(data HelmChartOciInstallUpgradeRequest) (result string, err error) {
+// HelmOciInstall installs a chart from an OCI registry. Cancelling ctx aborts
+// the install and rolls back what it created.
+func HelmOciInstall(ctx context.Context, data HelmChartOciInstallUpgradeRequest) (result string, err error) {
 	defer invalidateReleaseListCache() // the release set changed
 	// Start each attempt with a clean log: drop entries from previous
 	// install/upgrade attempts of the same release so the user only sees the
@@ -1034,7 +1042,11 @@ func HelmOciInstall(data HelmChartOciInstallUpgradeRequest) (result string, err
 		return "", err
 	}
 
-	rel, err := install.Run(chartRequested, valuesMap)
+	if err := checkRenderedManifest(data.ManifestCheck, actionConfig, chartRequested, valuesMap, data.Release, data.Namespace, data.Version); err != nil {
+		return "", err
+	}
+
+	rel, err := install.RunWithContext(ctx, chartRequested, valuesMap)
 	if err != nil {
 		helmLogger.Error("HelmOCIInstall Run",
 			"releaseName", data.Release,
@@ -1126,7 +1138,9 @@ func newRegistryClient(settings *cli.EnvSettings, plainHTTP bool) (*registry.Cli
 	return registryClient, nil
 }
 
-func HelmChartInstall(data HelmChartInstallUpgradeRequest) (result string, err error) {
+// HelmChartInstall installs a chart from a repository. Cancelling ctx aborts
+// the install and rolls back what it created.
+func HelmChartInstall(ctx context.Context, data HelmChartInstallUpgradeRequest) (result string, err error) {
 	defer invalidateReleaseListCache() // the release set changed
 	// Start each attempt with a clean log so the user only sees the current
 	// run, not entries from previous install attempts of the same release.
@@ -1208,6 +1222,10 @@ func HelmChartInstall(data HelmChartInstallUpgradeRequest) (result string, err e
 		return "", err
 	}
 
+	if err := checkRenderedManifest(data.ManifestCheck, actionConfig, chartRequested, valuesMap, data.Release, data.Namespace, data.Version); err != nil {
+		return "", err
+	}
+
 	// Ownership preflight: detect orphaned cluster-scoped resources left over
 	// from a previous incomplete uninstall. Adopt them via TakeOwnership when
 	// safe, abort when a foreign Helm release already owns them.
@@ -1228,7 +1246,7 @@ func HelmChartInstall(data HelmChartInstallUpgradeRequest) (result string, err e
 	}
 
 	helmLogger.Info("Installing chart ...", "releaseName", data.Release, "namespace", data.Namespace)
-	re, err := install.Run(chartRequested, valuesMap)
+	re, err := install.RunWithContext(ctx, chartRequested, valuesMap)
 	if err != nil {
 		helmLogger.Error("HelmInstall Run",
 			"releaseName", data.Release,
@@ -1257,7 +1275,8 @@ func HelmChartInstall(data HelmChartInstallUpgradeRequest) (result string, err e
 	return installStatus(re), nil
 }
 
-func HelmReleaseUpgrade(data HelmChartInstallUpgradeRequest) (result string, err error) {
+// HelmReleaseUpgrade upgrades a release. Cancelling ctx aborts the upgrade.
+func HelmReleaseUpgrade(ctx context.Context, data HelmChartInstallUpgradeRequest) (result string, err error) {
 	defer invalidateReleaseListCache() // the release set changed
 	// Start each attempt with a clean log so the user only sees the current
 	// run, not entries from previous upgrade attempts of the same release.
@@ -1355,6 +1374,10 @@ func HelmReleaseUpgrade(data HelmChartInstallUpgradeRequest) (result string, err
 		return "", err
 	}
 
+	if err := checkRenderedManifest(data.ManifestCheck, actionConfig, chartRequested, valuesMap, data.Release, data.Namespace, data.Version); err != nil {
+		return "", err
+	}
+
 	// Ownership preflight: detect orphaned cluster-scoped resources left over
 	// from a previous incomplete uninstall. Adopt them via TakeOwnership when
 	// safe, abort when a foreign Helm release already owns them.
@@ -1375,7 +1398,7 @@ func HelmReleaseUpgrade(data HelmChartInstallUpgradeRequest) (result string, err
 	}
 
 	helmLogger.Info("Upgrading chart ...", "releaseName", data.Release, "namespace", data.Namespace)
-	re, err := upgrade.Run(data.Release, chartRequested, valuesMap)
+	re, err := upgrade.RunWithContext(ctx, data.Release, chartRequested, valuesMap)
 	if err != nil {
 		helmLogger.Error("HelmUpgrade Run failed",
 			"releaseName", data.Release,
@@ -1402,7 +1425,9 @@ func HelmReleaseUpgrade(data HelmChartInstallUpgradeRequest) (result string, err
 	return installStatus(re), nil
 }
 
-func HelmReleaseUninstall(data HelmReleaseUninstallRequest) (result string, err error) {
+// HelmReleaseUninstall uninstalls a release. Cancelling ctx stops waiting for
+// its resources to be deleted.
+func HelmReleaseUninstall(ctx context.Context, data HelmReleaseUninstallRequest) (result string, err error) {
 	defer invalidateReleaseListCache() // the release set changed
 	defer func() {
 		if err != nil {
@@ -1427,6 +1452,7 @@ func HelmReleaseUninstall(data HelmReleaseUninstallRequest) (result string, err
 	uninstall := action.NewUninstall(actionConfig)
 	uninstall.DryRun = data.DryRun
 	uninstall.WaitStrategy = kube.StatusWatcherStrategy
+	uninstall.WaitOptions = []kube.WaitOption{kube.WithWaitContext(ctx)}
 	_, err = uninstall.Run(data.Release)
 	if err != nil {
 		helmLogger.Error("HelmUninstall Run",
@@ -2037,7 +2063,9 @@ func HelmReleaseHistory(data HelmReleaseHistoryRequest) ([]release.Release, erro
 	return releases, nil
 }
 
-func HelmReleaseRollback(data HelmReleaseRollbackRequest) (string, error) {
+// HelmReleaseRollback rolls a release back to a revision. Cancelling ctx stops
+// waiting for the rolled back resources to become ready.
+func HelmReleaseRollback(ctx context.Context, data HelmReleaseRollbackRequest) (string, error) {
 	defer invalidateReleaseListCache() // the release set changed
 
 	settings := NewCli()
@@ -2052,6 +2080,7 @@ func HelmReleaseRollback(data HelmReleaseRollbackRequest) (string, error) {
 	rollback := action.NewRollback(actionConfig)
 	rollback.ServerSideApply = "auto"
 	rollback.WaitStrategy = kube.StatusWatcherStrategy
+	rollback.WaitOptions = []kube.WaitOption{kube.WithWaitContext(ctx)}
 	rollback.Version = data.Revision
 	err := rollback.Run(data.Release)
 	if err != nil {
@@ -92,38 +92,9 @@ func RunOwnershipPreflight(
 	values map[string]any,
 	release, namespace, version string,
 ) (*PreflightResult, error) {
-	// install.Run with DryRunStrategy=DryRunClient enters Helm v4's mock block
-	// (pkg/action/install.go:329-344) and reassigns cfg.KubeClient to a
-	// PrintingKubeClient and cfg.Releases to an in-memory driver. Because cfg
-	// is a pointer, that poisons the caller's actionConfig for the subsequent
-	// real Install/Upgrade. Save and restore those two fields around the
-	// dry-run so the mocks stay scoped to the preflight render.
-	savedKubeClient := actionConfig.KubeClient
-	savedReleases := actionConfig.Releases
-	defer func() {
-		actionConfig.KubeClient = savedKubeClient
-		actionConfig.Releases = savedReleases
-	}()
-
-	dryRun := action.NewInstall(actionConfig)
-	dryRun.ReleaseName = release
-	dryRun.Namespace = namespace
-	dryRun.Version = version
-	dryRun.DryRunStrategy = action.DryRunClient
-
-	rel, err := dryRun.Run(chartRequested, values)
-	// Restore the real client/storage before we use them again below for the
-	// scan. The deferred restore stays as a safety net for panic/early-return.
-	actionConfig.KubeClient = savedKubeClient
-	actionConfig.Releases = savedReleases
-
+	manifest, err := renderManifest(actionConfig, chartRequested, values, release, namespace, version)
 	if err != nil {
-		return nil, fmt.Errorf("preflight render chart: %w", err)
-	}
-
-	manifest, err := manifestFromRelease(rel)
-	if err != nil {
-		return nil, fmt.Errorf("preflight read manifest: %w", err)
+		return nil, fmt.Errorf("preflight %w", err)
 	}
 
 	resources, err := actionConfig.KubeClient.Build(strings.NewReader(manifest), false)
@@ -156,6 +127,71 @@ func RunOwnershipPreflight(
 	return result, nil
 }
 
+// renderManifest renders the chart client-side, without touching the cluster,
+// and returns the manifest the release would install.
+func renderManifest(
+	actionConfig *action.Configuration,
+	chartRequested chart.Charter,
+	values map[string]any,
+	release, namespace, version string,
+) (string, error) {
+	// install.Run with DryRunStrategy=DryRunClient enters Helm v4's mock block
+	// (pkg/action/install.go:329-344) and reassigns cfg.KubeClient to a
+	// PrintingKubeClient and cfg.Releases to an in-memory driver. Because cfg
+	// is a pointer, that poisons the caller's actionConfig for the subsequent
+	// real Install/Upgrade. Save and restore those two fields around the
+	// dry-run so the mocks stay scoped to the render.
+	savedKubeClient := actionConfig.KubeClient
+	savedReleases := actionConfig.Releases
+	defer func() {
+		actionConfig.KubeClient = savedKubeClient
+		actionConfig.Releases = savedReleases
+	}()
+
+	dryRun := action.NewInstall(actionConfig)
+	dryRun.ReleaseName = release
+	dryRun.Namespace = namespace
+	dryRun.Version = version
+	dryRun.DryRunStrategy = action.DryRunClient
+
+	rel, err := dryRun.Run(chartRequested, values)
+	if err != nil {
+		return "", fmt.Errorf("render chart: %w", err)
+	}
+	manifest, err := manifestFromRelease(rel)
+	if err != nil {
+		return "", fmt.Errorf("read manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// checkRenderedManifest renders the chart and passes the manifest to check;
+// a nil check is skipped. An error aborts the install or upgrade.
+func checkRenderedManifest(
+	check func(manifest string) error,
+	actionConfig *action.Configuration,
+	chartRequested chart.Charter,
+	values map[string]any,
+	release, namespace, version string,
+) error {
+	if check == nil {
+		return nil
+	}
+	manifest, err := renderManifest(actionConfig, chartRequested, values, release, namespace, version)
+	if err != nil {
+		return err
+	}
+	if err := check(manifest); err != nil {
+		helmLogger.Warn("Helm release rejected by manifest check",
+			"releaseName", release,
+			"namespace", namespace,
+			"error", err.Error(),
+		)
+		return err
+	}
+	return nil
+}
+
 // manifestFromRelease extracts the rendered YAML manifest from the Releaser
 // interface returned by action.Install.Run. Helm v4 hides the concrete type
 // behind an empty interface; the concrete *release.Release carries a Manifest
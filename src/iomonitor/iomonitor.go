@@ -0,0 +1,359 @@
+package iomonitor
+
+import (
+	"bufio"
+	"io/fs"
+	"log/slog"
+	"mogenius-operator/src/config"
+	"mogenius-operator/src/containerenumerator"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type IoMonitor interface {
+	IoUsageProcesses() []PodIoStats
+}
+
+type ioMonitor struct {
+	logger              *slog.Logger
+	config              config.ConfigModule
+	procPath            string
+	cgroupPath          string
+	containerEnumerator containerenumerator.ContainerEnumerator
+
+	running atomic.Bool
+
+	ioUsageProcessesTx chan struct{}
+	ioUsageProcessesRx chan map[containerenumerator.ContainerId]containerIo
+}
+
+func NewIoMonitor(logger *slog.Logger, config config.ConfigModule, containerEnumerator containerenumerator.ContainerEnumerator) IoMonitor {
+	self := &ioMonitor{}
+
+	self.logger = logger
+	self.config = config
+	self.containerEnumerator = containerEnumerator
+	self.procPath = config.Get("MO_HOST_PROC_PATH")
+	self.cgroupPath = config.Get("MO_HOST_CGROUP_PATH")
+	self.running = atomic.Bool{}
+
+	self.ioUsageProcessesTx = make(chan struct{})
+	self.ioUsageProcessesRx = make(chan map[containerenumerator.ContainerId]containerIo)
+
+	return self
+}
+
+type containerIo struct {
+	pids   []ProcPidIo
+	cgroup *CgroupIoStat
+}
+
+func (self *ioMonitor) startCollector() {
+	wasRunning := self.running.Swap(true)
+	if wasRunning {
+		return
+	}
+	if runtime.GOOS != "linux" {
+		// Still serve the request channel (with zero values) — without a
+		// reader the first IoUsageProcesses() call would block forever.
+		go func() {
+			for range self.ioUsageProcessesTx {
+				self.ioUsageProcessesRx <- nil
+			}
+		}()
+		return
+	}
+	go func() {
+		// cgroup directories only change when containers start or stop, the
+		// tree is walked far less often than the counters are read
+		cgroupIndexUpdater := time.NewTicker(10 * time.Second)
+		defer cgroupIndexUpdater.Stop()
+
+		processMetricsUpdater := time.NewTicker(1 * time.Second)
+		defer processMetricsUpdater.Stop()
+
+		cgroupIndex := self.indexContainerCgroups()
+		processMetrics := self.collectProcessMetrics(cgroupIndex)
+
+		for {
+			select {
+			case <-cgroupIndexUpdater.C:
+				cgroupIndex = self.indexContainerCgroups()
+			case <-processMetricsUpdater.C:
+				processMetrics = self.collectProcessMetrics(cgroupIndex)
+			case <-self.ioUsageProcessesTx:
+				self.ioUsageProcessesRx <- processMetrics
+			}
+		}
+	}()
+}
+
+// indexContainerCgroups maps container ids to their cgroup directory below
+// cgroupPath. Reading the path from `/proc/$pid/cgroup` does not work here:
+// inside the DaemonSet's cgroup namespace other containers' paths are shown
+// relative to our own cgroup. The directory names carry the container id for
+// every supported runtime, so the same regexes as for `/proc/$pid/cgroup`
+// apply.
+func (self *ioMonitor) indexContainerCgroups() map[containerenumerator.ContainerId]string {
+	index := map[containerenumerator.ContainerId]string{}
+	err := filepath.WalkDir(self.cgroupPath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			// unreadable subtrees are skipped, not fatal
+			return nil
+		}
+		containerId, err := self.containerEnumerator.GetContainerIdFromCgroupWithPid(strings.TrimPrefix(path, self.cgroupPath))
+		if err != nil {
+			return nil
+		}
+		index[containerId] = path
+		return nil
+	})
+	if err != nil {
+		self.logger.Warn("failed to index container cgroups", "path", self.cgroupPath, "error", err)
+	}
+	return index
+}
+
+func (self *ioMonitor) collectProcessMetrics(cgroupIndex map[containerenumerator.ContainerId]string) map[containerenumerator.ContainerId]containerIo {
+	data := make(map[containerenumerator.ContainerId]containerIo)
+	containers := self.containerEnumerator.GetProcessesWithContainerIds()
+
+	for containerId, pids := range containers {
+		entry := containerIo{pids: []ProcPidIo{}}
+		for _, pid := range pids {
+			info, err := getIoUsageInfo(self.procPath, pid)
+			if err != nil {
+				continue
+			}
+			entry.pids = append(entry.pids, info)
+		}
+		if cgroupDir, ok := cgroupIndex[containerId]; ok {
+			stat, err := getCgroupIoStat(cgroupDir)
+			if err == nil {
+				entry.cgroup = &stat
+			}
+		}
+		data[containerId] = entry
+	}
+
+	return data
+}
+
+func (self *ioMonitor) IoUsageProcesses() []PodIoStats {
+	self.startCollector()
+
+	self.ioUsageProcessesTx <- struct{}{}
+	data := <-self.ioUsageProcessesRx
+
+	pods := self.containerEnumerator.GetPodsWithContainerIds()
+
+	stats := []PodIoStats{}
+	for _, pod := range pods {
+		for containerId := range pod.Containers {
+			containerIo, ok := data[containerId]
+			if !ok {
+				continue
+			}
+			stats = append(stats, containerIoToPodIoStats(pod, containerId, containerIo))
+		}
+	}
+
+	return stats
+}
+
+func containerIoToPodIoStats(pod containerenumerator.PodInfo, containerId containerenumerator.ContainerId, stats containerIo) PodIoStats {
+	data := PodIoStats{}
+	data.Name = pod.Name
+	data.Namespace = pod.Namespace
+	data.StartTime = pod.StartTime
+	data.ContainerId = containerId
+	data.Cgroup = stats.cgroup
+	for _, stat := range stats.pids {
+		pidData := IoUsagePodPid{}
+		pidData.Pid = stat.Pid
+		pidData.ReadChars = stat.Rchar
+		pidData.WriteChars = stat.Wchar
+		pidData.ReadBytes = stat.ReadBytes
+		pidData.WriteBytes = stat.WriteBytes
+		pidData.CancelledWriteBytes = stat.CancelledWriteBytes
+		data.Pids = append(data.Pids, pidData)
+	}
+	return data
+}
+
+// PodIoStats holds the cumulative I/O counters of one container of a pod.
+type PodIoStats struct {
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	StartTime   string `json:"start_time"`
+	ContainerId string `json:"container_id"`
+	// Cgroup is nil when the container has no cgroup v2 io.stat (cgroup v1
+	// hosts). It also counts I/O of processes which already exited.
+	Cgroup *CgroupIoStat   `json:"cgroup,omitempty"`
+	Pids   []IoUsagePodPid `json:"pids"`
+}
+
+// ReadWriteBytes returns the bytes the container read from and wrote to
+// block devices, preferring the cgroup counters.
+func (self PodIoStats) ReadWriteBytes() (read uint64, write uint64) {
+	if self.Cgroup != nil {
+		return self.Cgroup.ReadBytes, self.Cgroup.WriteBytes
+	}
+	for _, pid := range self.Pids {
+		read += pid.ReadBytes
+		write += pid.WriteBytes - min(pid.CancelledWriteBytes, pid.WriteBytes)
+	}
+	return read, write
+}
+
+type IoUsagePodPid struct {
+	Pid                 uint64 `json:"pid"`
+	ReadChars           uint64 `json:"read_chars"`
+	WriteChars          uint64 `json:"write_chars"`
+	ReadBytes           uint64 `json:"read_bytes"`
+	WriteBytes          uint64 `json:"write_bytes"`
+	CancelledWriteBytes uint64 `json:"cancelled_write_bytes"`
+}
+
+type ProcPidIo struct {
+	Pid uint64 `json:"pid"`
+	// characters read (includes tty and page cache hits)
+	Rchar uint64 `json:"rchar"`
+	// characters written (includes tty and page cache)
+	Wchar uint64 `json:"wchar"`
+	// read syscalls
+	Syscr uint64 `json:"syscr"`
+	// write syscalls
+	Syscw uint64 `json:"syscw"`
+	// bytes fetched from the storage layer
+	ReadBytes uint64 `json:"read_bytes"`
+	// bytes sent to the storage layer
+	WriteBytes uint64 `json:"write_bytes"`
+	// bytes of truncated dirty page cache which never got written
+	CancelledWriteBytes uint64 `json:"cancelled_write_bytes"`
+}
+
+// CgroupIoStat is the sum of cgroup v2 io.stat over all devices.
+type CgroupIoStat struct {
+	ReadBytes    uint64 `json:"read_bytes"`
+	WriteBytes   uint64 `json:"write_bytes"`
+	ReadOps      uint64 `json:"read_ops"`
+	WriteOps     uint64 `json:"write_ops"`
+	DiscardBytes uint64 `json:"discard_bytes"`
+	DiscardOps   uint64 `json:"discard_ops"`
+}
+
+// read and parse `$procPath/$pid/io` to read process I/O counters from the kernel
+func getIoUsageInfo(procPath string, pid uint64) (ProcPidIo, error) {
+	data, err := os.ReadFile(filepath.Join(procPath, strconv.FormatUint(pid, 10), "io"))
+	if err != nil {
+		return ProcPidIo{}, err
+	}
+	return parseProcPidIo(pid, string(data)), nil
+}
+
+func parseProcPidIo(pid uint64, data string) ProcPidIo {
+	// File Format of `/proc/$pid/io`
+	// ================================
+	//
+	// ```
+	// rchar: 323934931
+	// wchar: 323929600
+	// syscr: 632687
+	// syscw: 632675
+	// read_bytes: 0
+	// write_bytes: 323932160
+	// cancelled_write_bytes: 0
+	// ```
+	info := ProcPidIo{Pid: pid}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "rchar":
+			info.Rchar = parsed
+		case "wchar":
+			info.Wchar = parsed
+		case "syscr":
+			info.Syscr = parsed
+		case "syscw":
+			info.Syscw = parsed
+		case "read_bytes":
+			info.ReadBytes = parsed
+		case "write_bytes":
+			info.WriteBytes = parsed
+		case "cancelled_write_bytes":
+			info.CancelledWriteBytes = parsed
+		}
+	}
+	return info
+}
+
+// read and parse `$cgroupDir/io.stat` (cgroup v2 only)
+func getCgroupIoStat(cgroupDir string) (CgroupIoStat, error) {
+	data, err := os.ReadFile(filepath.Join(cgroupDir, "io.stat"))
+	if err != nil {
+		return CgroupIoStat{}, err
+	}
+	return parseCgroupIoStat(string(data)), nil
+}
+
+func parseCgroupIoStat(data string) CgroupIoStat {
+	// File Format of `io.stat`
+	// ========================
+	//
+	// ```
+	// 8:16 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+	// 8:0 rbytes=90430464 wbytes=299008000 rios=8950 wios=1252 dbytes=50331648 dios=3021
+	// ```
+	//
+	// Parsing Rules
+	// =============
+	//
+	// - one line per device, counters of all devices are summed up
+	// - unknown keys (e.g. from io.cost) are ignored
+	stat := CgroupIoStat{}
+	for line := range strings.Lines(data) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			parsed, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				stat.ReadBytes += parsed
+			case "wbytes":
+				stat.WriteBytes += parsed
+			case "rios":
+				stat.ReadOps += parsed
+			case "wios":
+				stat.WriteOps += parsed
+			case "dbytes":
+				stat.DiscardBytes += parsed
+			case "dios":
+				stat.DiscardOps += parsed
+			}
+		}
+	}
+	return stat
+}
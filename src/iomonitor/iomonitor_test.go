@@ -0,0 +1,51 @@
+package iomonitor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProcPidIo(t *testing.T) {
+	info := parseProcPidIo(42, "rchar: 323934931\nwchar: 323929600\nsyscr: 632687\nsyscw: 632675\nread_bytes: 4096\nwrite_bytes: 323932160\ncancelled_write_bytes: 1024\n")
+	assert.Equal(t, ProcPidIo{
+		Pid:                 42,
+		Rchar:               323934931,
+		Wchar:               323929600,
+		Syscr:               632687,
+		Syscw:               632675,
+		ReadBytes:           4096,
+		WriteBytes:          323932160,
+		CancelledWriteBytes: 1024,
+	}, info)
+}
+
+func TestParseCgroupIoStat(t *testing.T) {
+	stat := parseCgroupIoStat("8:16 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0\n" +
+		"8:0 rbytes=90430464 wbytes=299008000 rios=8950 wios=1252 dbytes=50331648 dios=3021 cost.usage=12\n" +
+		"\n")
+	assert.Equal(t, CgroupIoStat{
+		ReadBytes:    1459200 + 90430464,
+		WriteBytes:   314773504 + 299008000,
+		ReadOps:      192 + 8950,
+		WriteOps:     353 + 1252,
+		DiscardBytes: 50331648,
+		DiscardOps:   3021,
+	}, stat)
+}
+
+func TestPodIoStatsReadWriteBytes(t *testing.T) {
+	stats := PodIoStats{Pids: []IoUsagePodPid{
+		{ReadBytes: 100, WriteBytes: 1000, CancelledWriteBytes: 200},
+		{ReadBytes: 50, WriteBytes: 10, CancelledWriteBytes: 20},
+	}}
+	read, write := stats.ReadWriteBytes()
+	assert.Equal(t, uint64(150), read)
+	assert.Equal(t, uint64(800), write)
+
+	// the cgroup also covers processes which already exited
+	stats.Cgroup = &CgroupIoStat{ReadBytes: 4096, WriteBytes: 8192}
+	read, write = stats.ReadWriteBytes()
+	assert.Equal(t, uint64(4096), read)
+	assert.Equal(t, uint64(8192), write)
+}
@@ -47,3 +47,13 @@ func GetSecret(namespace, name string) (*coreV1.Secret, error) {
 	return clientset.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
 }
 
+
+// GetKubernetesVersion returns the git version of the API server, e.g.
+// "v1.31.2-eks-7f9249a".
+func GetKubernetesVersion() (string, error) {
+	info, err := clientProvider.K8sClientSet().Discovery().ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	return info.GitVersion, nil
+}
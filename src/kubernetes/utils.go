@@ -110,20 +110,21 @@ func ExecInNfsPod(volumeNamespace, volumeName string, command []string, stdin io
 		return "", fmt.Errorf("NFS server pod not found for %s/%s", volumeNamespace, volumeName)
 	}
 	var stdout bytes.Buffer
-	err := execInNfsPodStream(volumeNamespace, podNames[0], command, stdin, &stdout)
+	err := execInNfsPodStream(context.Background(), volumeNamespace, podNames[0], command, stdin, &stdout)
 	return stdout.String(), err
 }
 
-// ExecInNfsPodToWriter streams exec stdout directly into the provided writer. stdin may be nil.
-func ExecInNfsPodToWriter(volumeNamespace, volumeName string, command []string, stdin io.Reader, stdout io.Writer) error {
+// ExecInNfsPodToWriter streams exec stdout directly into the provided writer
+// until the command exits or ctx is cancelled. stdin may be nil.
+func ExecInNfsPodToWriter(ctx context.Context, volumeNamespace, volumeName string, command []string, stdin io.Reader, stdout io.Writer) error {
 	podNames := AllPodNamesForLabel(volumeNamespace, "app", fmt.Sprintf("%s-%s", utils.NFS_POD_PREFIX, volumeName))
 	if len(podNames) == 0 {
 		return fmt.Errorf("NFS server pod not found for %s/%s", volumeNamespace, volumeName)
 	}
-	return execInNfsPodStream(volumeNamespace, podNames[0], command, stdin, stdout)
+	return execInNfsPodStream(ctx, volumeNamespace, podNames[0], command, stdin, stdout)
 }
 
-func execInNfsPodStream(namespace, podName string, command []string, stdin io.Reader, stdout io.Writer) error {
+func execInNfsPodStream(ctx context.Context, namespace, podName string, command []string, stdin io.Reader, stdout io.Writer) error {
 	clientset := clientProvider.K8sClientSet()
 	restConfig := clientProvider.ClientConfig()
 
@@ -158,7 +159,7 @@ func execInNfsPodStream(namespace, podName string, command []string, stdin io.Re
 		opts.Stdin = stdin
 	}
 
-	err = executor.StreamWithContext(context.Background(), opts)
+	err = executor.StreamWithContext(ctx, opts)
 	if err != nil {
 		return fmt.Errorf("%w: %s", err, stderr.String())
 	}
@@ -59,7 +59,12 @@ var (
 	lastWatchCheckStart time.Time
 )
 
-func WatchStoreResources(wm watcher.WatcherModule, aiManager ai.AiManager, eventClient websocket.WebsocketClient) error {
+// ResourceEventHandler is called for every change the watcher forwards to the
+// event server: "add", "update" or "delete". It runs on the informer
+// goroutine, so implementations must not block.
+type ResourceEventHandler func(eventType string, resource utils.ResourceDescriptor, obj *unstructured.Unstructured)
+
+func WatchStoreResources(wm watcher.WatcherModule, aiManager ai.AiManager, eventClient websocket.WebsocketClient, onResourceEvent ResourceEventHandler) error {
 	start := time.Now()
 
 	// function should not be called more often than every 5 seconds
@@ -110,7 +115,7 @@ func WatchStoreResources(wm watcher.WatcherModule, aiManager ai.AiManager, event
 
 		err := wm.Watch(res, func(resource utils.ResourceDescriptor, obj *unstructured.Unstructured) {
 			setStoreIfNeeded(resource.ApiVersion, obj.GetName(), resource.Kind, obj.GetNamespace(), obj)
-			handleCRDAddition(wm, aiManager, eventClient, resource)
+			handleCRDAddition(wm, aiManager, eventClient, onResourceEvent, resource)
 			aiManager.ProcessObject(obj, "add", res)
 
 			// suppress the add events for the first 10 seconds (because all resources are added initially)
@@ -118,6 +123,7 @@ func WatchStoreResources(wm watcher.WatcherModule, aiManager ai.AiManager, event
 				return
 			}
 			sendEventServerEvent(eventClient, res.ApiVersion, resource.Kind, obj.GetName(), "add", obj)
+			onResourceEvent("add", res, obj)
 		}, func(resource utils.ResourceDescriptor, oldObj, newObj *unstructured.Unstructured) {
 			// Always refresh the Valkey entry so the TTL stays alive.
 			// SharedInformer resync delivers UpdateFunc every
@@ -135,6 +141,7 @@ func WatchStoreResources(wm watcher.WatcherModule, aiManager ai.AiManager, event
 				return
 			}
 			sendEventServerEvent(eventClient, resource.ApiVersion, resource.Kind, newObj.GetName(), "update", newObj)
+			onResourceEvent("update", resource, newObj)
 			aiManager.ProcessObject(newObj, "update", res)
 		}, func(resource utils.ResourceDescriptor, obj *unstructured.Unstructured) {
 			deleteFromStoreIfNeeded(resource.ApiVersion, obj.GetName(), resource.Kind, obj.GetNamespace(), obj)
@@ -142,6 +149,7 @@ func WatchStoreResources(wm watcher.WatcherModule, aiManager ai.AiManager, event
 				store.ClearOwnerCachePodEntry(obj.GetNamespace(), obj.GetName())
 			}
 			sendEventServerEvent(eventClient, resource.ApiVersion, resource.Kind, obj.GetName(), "delete", obj)
+			onResourceEvent("delete", resource, obj)
 			handleCRDDeletion(wm, resource, obj)
 			aiManager.ProcessObject(obj, "delete", res)
 		})
@@ -173,7 +181,7 @@ var (
 
 // no matter how many CRD addition events we get in a short time frame
 // this method will debounce them and only execute the logic once after 3 seconds
-func handleCRDAddition(wm watcher.WatcherModule, aiManager ai.AiManager, eventClient websocket.WebsocketClient, resource utils.ResourceDescriptor) {
+func handleCRDAddition(wm watcher.WatcherModule, aiManager ai.AiManager, eventClient websocket.WebsocketClient, onResourceEvent ResourceEventHandler, resource utils.ResourceDescriptor) {
 	if resource.Kind == "CustomResourceDefinition" {
 		crdDebounceMutex.Lock()
 		defer crdDebounceMutex.Unlock()
@@ -194,7 +202,7 @@ func handleCRDAddition(wm watcher.WatcherModule, aiManager ai.AiManager, eventCl
 			}
 			currentlyWatchedResources := wm.ListWatchedResources()
 			if len(res) != len(currentlyWatchedResources) {
-				err := WatchStoreResources(wm, aiManager, eventClient)
+				err := WatchStoreResources(wm, aiManager, eventClient, onResourceEvent)
 				if err != nil {
 					k8sLogger.Error("Error watching store resources", "error", err)
 				}
@@ -425,7 +433,7 @@ func CreateUnstructuredResource(apiVersion string, plural string, namespaced boo
 	}
 }
 
-func UpdateUnstructuredResource(apiVersion string, plural string, namespaced bool, yamlData string) (*unstructured.Unstructured, error) {
+func UpdateUnstructuredResource(ctx context.Context, apiVersion string, plural string, namespaced bool, yamlData string) (*unstructured.Unstructured, error) {
 	dynamicClient := clientProvider.DynamicClient()
 	obj := &unstructured.Unstructured{}
 	err := yaml.Unmarshal([]byte(yamlData), obj)
@@ -434,10 +442,10 @@ func UpdateUnstructuredResource(apiVersion string, plural string, namespaced boo
 	}
 
 	if namespaced {
-		result, err := dynamicClient.Resource(CreateGroupVersionResource(apiVersion, plural)).Namespace(obj.GetNamespace()).Update(context.Background(), obj, metav1.UpdateOptions{})
+		result, err := dynamicClient.Resource(CreateGroupVersionResource(apiVersion, plural)).Namespace(obj.GetNamespace()).Update(ctx, obj, metav1.UpdateOptions{})
 		return removeManagedFields(result), err
 	} else {
-		result, err := dynamicClient.Resource(CreateGroupVersionResource(apiVersion, plural)).Update(context.Background(), obj, metav1.UpdateOptions{})
+		result, err := dynamicClient.Resource(CreateGroupVersionResource(apiVersion, plural)).Update(ctx, obj, metav1.UpdateOptions{})
 		return removeManagedFields(result), err
 	}
 }
@@ -8,10 +8,25 @@ import (
 var patternDuration = promauto.NewHistogramVec(
 	prometheus.HistogramOpts{
 		Name:    "mogenius_operator_pattern_duration_seconds",
-		Help:    "Duration of pattern handler executions.",
+		Help:    "Duration of pattern handler executions, by outcome (completed, cancelled, timeout).",
 		Buckets: prometheus.DefBuckets,
 	},
-	[]string{"path"},
+	[]string{"path", "outcome"},
+)
+
+// Outcomes of a pattern request as recorded by ObservePatternDuration.
+const (
+	PatternOutcomeCompleted = "completed"
+	PatternOutcomeCancelled = "cancelled"
+	PatternOutcomeTimeout   = "timeout"
+)
+
+var patternAuthorizationDenied = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "mogenius_operator_pattern_authorization_denied_total",
+		Help: "Pattern requests the caller's Grants do not allow, by pattern and MO_PATTERN_AUTHORIZATION mode (audit: only logged, enforce: rejected).",
+	},
+	[]string{"path", "mode"},
 )
 
 var websocketConnected = promauto.NewGaugeVec(
@@ -44,6 +59,28 @@ var auditLogEventsDropped = promauto.NewCounter(
 	},
 )
 
+var eventsOutboxDepth = promauto.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "mogenius_operator_events_outbox_depth",
+		Help: "Events waiting in the outbox for the events websocket to reconnect.",
+	},
+)
+
+var eventsOutboxDropped = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "mogenius_operator_events_outbox_dropped_total",
+		Help: "Events that never reached the event server, by reason (coalesced: replaced by a later state of the same job or resource, overflow: the outbox was full, error: Valkey failed).",
+	},
+	[]string{"reason"},
+)
+
+// Reasons for IncEventsOutboxDropped.
+const (
+	EventsOutboxDroppedCoalesced = "coalesced"
+	EventsOutboxDroppedOverflow  = "overflow"
+	EventsOutboxDroppedError     = "error"
+)
+
 func IncAuditLogWritten(source string) {
 	auditLogEntriesWritten.WithLabelValues(source).Inc()
 }
@@ -56,8 +93,20 @@ func IncAuditLogEventDropped() {
 	auditLogEventsDropped.Inc()
 }
 
-func ObservePatternDuration(pattern string, seconds float64) {
-	patternDuration.WithLabelValues(pattern).Observe(seconds)
+func SetEventsOutboxDepth(depth int) {
+	eventsOutboxDepth.Set(float64(depth))
+}
+
+func IncEventsOutboxDropped(reason string, count int) {
+	eventsOutboxDropped.WithLabelValues(reason).Add(float64(count))
+}
+
+func ObservePatternDuration(pattern string, outcome string, seconds float64) {
+	patternDuration.WithLabelValues(pattern, outcome).Observe(seconds)
+}
+
+func IncPatternAuthorizationDenied(pattern string, mode string) {
+	patternAuthorizationDenied.WithLabelValues(pattern, mode).Inc()
 }
 
 func SetWebsocketConnected(name string, connected bool) {
@@ -0,0 +1,369 @@
+package networkmonitor
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FlowDirection = string
+
+const (
+	// FlowDirectionEgress is a connection the pod opened to a peer.
+	FlowDirectionEgress FlowDirection = "egress"
+	// FlowDirectionIngress is a connection a peer opened to a port the pod
+	// listens on.
+	FlowDirectionIngress FlowDirection = "ingress"
+)
+
+// PodNetworkFlow aggregates the established TCP connections of one pod to one
+// peer address and server port at the time of sampling.
+type PodNetworkFlow struct {
+	Pod string `json:"pod"`
+	// Namespace is stripped before persisting, like PodNetworkStats.
+	Namespace string        `json:"namespace,omitempty"`
+	Direction FlowDirection `json:"direction"`
+	PeerIp    string        `json:"peerIp"`
+	// Port is the server port: the peer's port for egress, the pod's
+	// listening port for ingress.
+	Port        uint16 `json:"port"`
+	Protocol    string `json:"protocol"`
+	Connections uint64 `json:"connections"`
+	// TransmitBytes and ReceivedBytes are the conntrack counters of the open
+	// connections (seen from the pod). They stay 0 when conntrack accounting
+	// is not available on the node.
+	TransmitBytes uint64 `json:"transmitBytes"`
+	ReceivedBytes uint64 `json:"receivedBytes"`
+}
+
+// PodNetworkFlows is one sample of the flows of all pods of a namespace on a
+// node.
+type PodNetworkFlows struct {
+	Node      string           `json:"node"`
+	Flows     []PodNetworkFlow `json:"flows"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+const (
+	tcpStateEstablished = 0x01
+	tcpStateListen      = 0x0A
+)
+
+type tcpSocket struct {
+	Local  netip.AddrPort
+	Remote netip.AddrPort
+	State  uint8
+}
+
+// collectPodNetworkFlows reads the TCP sockets of every pod from the network
+// namespace of its first container process and groups them into flows.
+// Connections to loopback addresses are ignored, and so are hostNetwork pods:
+// the sockets of the host's network namespace belong to every process of the
+// node, not to the pod.
+func collectPodNetworkFlows(procPath string, pods []podProcess, conntrack *conntrackTable) []PodNetworkFlow {
+	flows := []PodNetworkFlow{}
+	hostNetNs := networkNamespace(procPath, 1)
+	for _, pod := range pods {
+		if hostNetNs != "" && networkNamespace(procPath, pod.Pid) == hostNetNs {
+			continue
+		}
+		sockets, err := readTcpSockets(procPath, pod.Pid)
+		if err != nil {
+			continue
+		}
+		flows = append(flows, podFlowsFromSockets(pod.Namespace, pod.Name, sockets, conntrack)...)
+	}
+	return flows
+}
+
+type podProcess struct {
+	Namespace string
+	Name      string
+	Pid       ProcessId
+}
+
+func podFlowsFromSockets(namespace string, pod string, sockets []tcpSocket, conntrack *conntrackTable) []PodNetworkFlow {
+	listening := map[uint16]bool{}
+	for _, socket := range sockets {
+		if socket.State == tcpStateListen {
+			listening[socket.Local.Port()] = true
+		}
+	}
+
+	type flowKey struct {
+		direction FlowDirection
+		peer      netip.Addr
+		port      uint16
+	}
+	byKey := map[flowKey]*PodNetworkFlow{}
+	for _, socket := range sockets {
+		if socket.State != tcpStateEstablished || socket.Remote.Addr().IsLoopback() {
+			continue
+		}
+		key := flowKey{direction: FlowDirectionEgress, peer: socket.Remote.Addr(), port: socket.Remote.Port()}
+		if listening[socket.Local.Port()] {
+			key = flowKey{direction: FlowDirectionIngress, peer: socket.Remote.Addr(), port: socket.Local.Port()}
+		}
+		flow, ok := byKey[key]
+		if !ok {
+			flow = &PodNetworkFlow{
+				Pod:       pod,
+				Namespace: namespace,
+				Direction: key.direction,
+				PeerIp:    key.peer.String(),
+				Port:      key.port,
+				Protocol:  "tcp",
+			}
+			byKey[key] = flow
+		}
+		flow.Connections++
+		tx, rx := conntrack.bytes(socket.Local, socket.Remote)
+		flow.TransmitBytes += tx
+		flow.ReceivedBytes += rx
+	}
+
+	flows := make([]PodNetworkFlow, 0, len(byKey))
+	for _, flow := range byKey {
+		flows = append(flows, *flow)
+	}
+	slices.SortFunc(flows, func(a, b PodNetworkFlow) int {
+		return cmp.Or(
+			strings.Compare(a.Direction, b.Direction),
+			strings.Compare(a.PeerIp, b.PeerIp),
+			cmp.Compare(a.Port, b.Port),
+		)
+	})
+	return flows
+}
+
+// readTcpSockets reads `$procPath/$pid/net/tcp` and `tcp6`. A missing tcp6
+// file (IPv6 disabled) is not an error.
+// networkNamespace identifies the network namespace of a process, e.g.
+// "net:[4026531840]"; "" when it cannot be read.
+func networkNamespace(procPath string, pid ProcessId) string {
+	link, err := os.Readlink(filepath.Join(procPath, strconv.FormatUint(pid, 10), "ns", "net"))
+	if err != nil {
+		return ""
+	}
+	return link
+}
+
+func readTcpSockets(procPath string, pid ProcessId) ([]tcpSocket, error) {
+	netPath := filepath.Join(procPath, strconv.FormatUint(pid, 10), "net")
+	sockets := []tcpSocket{}
+	for _, name := range []string{"tcp", "tcp6"} {
+		file, err := os.Open(filepath.Join(netPath, name))
+		if err != nil {
+			if name == "tcp6" && os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		parsed, err := parseProcNetTcp(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(netPath, name), err)
+		}
+		sockets = append(sockets, parsed...)
+	}
+	return sockets, nil
+}
+
+// parse the socket table format of `/proc/$pid/net/tcp{,6}`
+func parseProcNetTcp(r io.Reader) ([]tcpSocket, error) {
+	// File Format
+	// ===========
+	//
+	// ```
+	//   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+	//    0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12345 1 ...
+	// ```
+	//
+	// Addresses are hex encoded in host byte order per 32 bit word (4 words
+	// for tcp6), ports are hex encoded in network byte order. The first line
+	// is a header.
+	sockets := []tcpSocket{}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if lineNumber == 1 {
+			continue
+		}
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) < 4 {
+			continue
+		}
+		local, err := parseProcNetAddress(tokens[1])
+		if err != nil {
+			return nil, err
+		}
+		remote, err := parseProcNetAddress(tokens[2])
+		if err != nil {
+			return nil, err
+		}
+		state, err := strconv.ParseUint(tokens[3], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid socket state %q: %w", tokens[3], err)
+		}
+		sockets = append(sockets, tcpSocket{Local: local, Remote: remote, State: uint8(state)})
+	}
+	return sockets, scanner.Err()
+}
+
+func parseProcNetAddress(data string) (netip.AddrPort, error) {
+	host, port, ok := strings.Cut(data, ":")
+	if !ok {
+		return netip.AddrPort{}, fmt.Errorf("invalid socket address %q", data)
+	}
+	raw, err := hex.DecodeString(host)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return netip.AddrPort{}, fmt.Errorf("invalid socket address %q", data)
+	}
+	for i := 0; i < len(raw); i += 4 {
+		slices.Reverse(raw[i : i+4])
+	}
+	portNumber, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid socket port %q: %w", data, err)
+	}
+	addr, _ := netip.AddrFromSlice(raw)
+	return netip.AddrPortFrom(addr.Unmap(), uint16(portNumber)), nil
+}
+
+type conntrackTuple struct {
+	Src netip.AddrPort
+	Dst netip.AddrPort
+}
+
+type conntrackEntry struct {
+	OrigBytes  uint64
+	ReplyBytes uint64
+}
+
+// conntrackTable indexes the TCP entries of the host conntrack table by their
+// original and by their reply direction. A nil table knows no connections.
+type conntrackTable struct {
+	byOrig  map[conntrackTuple]conntrackEntry
+	byReply map[conntrackTuple]conntrackEntry
+}
+
+// bytes returns the bytes sent and received by the local end of a socket.
+// Outgoing connections match the original direction (with DNAT the pod still
+// sees the service IP, which is the original destination); incoming
+// connections match the reply direction.
+func (self *conntrackTable) bytes(local netip.AddrPort, remote netip.AddrPort) (tx uint64, rx uint64) {
+	if self == nil {
+		return 0, 0
+	}
+	tuple := conntrackTuple{Src: local, Dst: remote}
+	if entry, ok := self.byOrig[tuple]; ok {
+		return entry.OrigBytes, entry.ReplyBytes
+	}
+	if entry, ok := self.byReply[tuple]; ok {
+		return entry.ReplyBytes, entry.OrigBytes
+	}
+	return 0, 0
+}
+
+// readConntrack reads the conntrack table of the host network namespace
+// (`$procPath/1/net/nf_conntrack`). It returns nil when the table is not
+// available or carries no byte counters (nf_conntrack_acct disabled).
+func readConntrack(procPath string) *conntrackTable {
+	file, err := os.Open(filepath.Join(procPath, "1", "net", "nf_conntrack"))
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = file.Close() }()
+	return parseConntrack(file)
+}
+
+// parse the format of `/proc/net/nf_conntrack`
+func parseConntrack(r io.Reader) *conntrackTable {
+	// File Format
+	// ===========
+	//
+	// ```
+	// ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.5 dst=10.96.0.10 sport=43210 dport=80 packets=10 bytes=1234 src=10.0.1.7 dst=10.0.0.5 sport=8080 dport=43210 packets=8 bytes=999 [ASSURED] mark=0 use=2
+	// ```
+	//
+	// The first src/dst/sport/dport group is the original direction, the
+	// second the reply direction. `bytes=` only exists with accounting.
+	table := &conntrackTable{byOrig: map[conntrackTuple]conntrackEntry{}, byReply: map[conntrackTuple]conntrackEntry{}}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) < 3 || tokens[2] != "tcp" {
+			continue
+		}
+		values := map[string][]string{}
+		for _, token := range tokens {
+			key, value, ok := strings.Cut(token, "=")
+			if ok {
+				values[key] = append(values[key], value)
+			}
+		}
+		if len(values["bytes"]) < 2 {
+			continue
+		}
+		orig, origOk := conntrackTupleAt(values, 0)
+		reply, replyOk := conntrackTupleAt(values, 1)
+		if !origOk || !replyOk {
+			continue
+		}
+		origBytes, _ := strconv.ParseUint(values["bytes"][0], 10, 64)
+		replyBytes, _ := strconv.ParseUint(values["bytes"][1], 10, 64)
+		entry := conntrackEntry{OrigBytes: origBytes, ReplyBytes: replyBytes}
+		table.byOrig[orig] = entry
+		table.byReply[reply] = entry
+	}
+	if len(table.byOrig) == 0 {
+		return nil
+	}
+	return table
+}
+
+func conntrackTupleAt(values map[string][]string, idx int) (conntrackTuple, bool) {
+	for _, key := range []string{"src", "dst", "sport", "dport"} {
+		if len(values[key]) <= idx {
+			return conntrackTuple{}, false
+		}
+	}
+	src, srcErr := netip.ParseAddr(values["src"][idx])
+	dst, dstErr := netip.ParseAddr(values["dst"][idx])
+	sport, sportErr := strconv.ParseUint(values["sport"][idx], 10, 16)
+	dport, dportErr := strconv.ParseUint(values["dport"][idx], 10, 16)
+	if srcErr != nil || dstErr != nil || sportErr != nil || dportErr != nil {
+		return conntrackTuple{}, false
+	}
+	return conntrackTuple{
+		Src: netip.AddrPortFrom(src.Unmap(), uint16(sport)),
+		Dst: netip.AddrPortFrom(dst.Unmap(), uint16(dport)),
+	}, true
+}
+
+func (self *networkMonitor) GetPodNetworkFlows() []PodNetworkFlow {
+	podInfoList := self.cne.GetPodsWithContainerIds()
+	pods := make([]podProcess, 0, len(podInfoList))
+	for _, podInfo := range podInfoList {
+		// all containers of a pod share the network namespace, any process
+		// of any container works
+		containerPids := podInfo.ContainersWithFirstPid()
+		containerIds := slices.Sorted(maps.Keys(containerPids))
+		if len(containerIds) == 0 {
+			continue
+		}
+		pods = append(pods, podProcess{Namespace: podInfo.Namespace, Name: podInfo.Name, Pid: containerPids[containerIds[0]]})
+	}
+	return collectPodNetworkFlows(self.procFsMountPath, pods, readConntrack(self.procFsMountPath))
+}
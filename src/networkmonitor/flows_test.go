@@ -0,0 +1,96 @@
+package networkmonitor
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const procNetTcp = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0500000A:1F90 0700000A:D431 01 00000000:00000000 00:00000000 00000000     0        0 1002 1 0000000000000000 20 4 30 10 -1
+   2: 0500000A:A8C2 0A00600A:0050 01 00000000:00000000 00:00000000 00000000     0        0 1003 1 0000000000000000 20 4 30 10 -1
+   3: 0500000A:A8C4 0A00600A:0050 01 00000000:00000000 00:00000000 00000000     0        0 1004 1 0000000000000000 20 4 30 10 -1
+   4: 0100007F:A8C6 0100007F:1F91 01 00000000:00000000 00:00000000 00000000     0        0 1005 1 0000000000000000 20 4 30 10 -1
+`
+
+const procNetTcp6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0000000000000000FFFF00000500000A:B000 0000000000000000FFFF0000221AD85D:01BB 01 00000000:00000000 00:00000000 00000000     0        0 1006 1 0000000000000000 20 4 30 10 -1
+`
+
+func TestParseProcNetTcp(t *testing.T) {
+	sockets, err := parseProcNetTcp(strings.NewReader(procNetTcp))
+	require.NoError(t, err)
+	require.Len(t, sockets, 5)
+	assert.Equal(t, netip.MustParseAddrPort("0.0.0.0:8080"), sockets[0].Local)
+	assert.Equal(t, uint8(tcpStateListen), sockets[0].State)
+	assert.Equal(t, netip.MustParseAddrPort("10.0.0.5:8080"), sockets[1].Local)
+	assert.Equal(t, netip.MustParseAddrPort("10.0.0.7:54321"), sockets[1].Remote)
+	assert.Equal(t, netip.MustParseAddrPort("10.96.0.10:80"), sockets[2].Remote)
+
+	sockets6, err := parseProcNetTcp(strings.NewReader(procNetTcp6))
+	require.NoError(t, err)
+	require.Len(t, sockets6, 1)
+	// IPv4-mapped addresses are unmapped so they resolve like tcp entries.
+	assert.Equal(t, netip.MustParseAddrPort("10.0.0.5:45056"), sockets6[0].Local)
+	assert.Equal(t, netip.MustParseAddrPort("93.216.26.34:443"), sockets6[0].Remote)
+}
+
+func TestParseProcNetTcpInvalidAddress(t *testing.T) {
+	_, err := parseProcNetTcp(strings.NewReader("header\n 0: XYZ:1F90 00000000:0000 0A\n"))
+	assert.Error(t, err)
+}
+
+func TestPodFlowsFromSockets(t *testing.T) {
+	sockets, err := parseProcNetTcp(strings.NewReader(procNetTcp + strings.SplitN(procNetTcp6, "\n", 2)[1]))
+	require.NoError(t, err)
+
+	conntrack := parseConntrack(strings.NewReader(strings.Join([]string{
+		"ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.5 dst=10.96.0.10 sport=43202 dport=80 packets=10 bytes=1000 src=10.0.1.9 dst=10.0.0.5 sport=8080 dport=43202 packets=8 bytes=5000 [ASSURED] mark=0 use=2",
+		"ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.7 dst=10.0.0.5 sport=54321 dport=8080 packets=3 bytes=300 src=10.0.0.5 dst=10.0.0.7 sport=8080 dport=54321 packets=3 bytes=700 [ASSURED] mark=0 use=2",
+		"ipv4     2 udp      17 29 src=10.0.0.5 dst=10.96.0.53 sport=5353 dport=53 packets=1 bytes=60 src=10.0.1.2 dst=10.0.0.5 sport=53 dport=5353 packets=1 bytes=120 mark=0 use=2",
+	}, "\n")))
+	require.NotNil(t, conntrack)
+
+	flows := podFlowsFromSockets("default", "api-0", sockets, conntrack)
+	assert.Equal(t, []PodNetworkFlow{
+		{Pod: "api-0", Namespace: "default", Direction: FlowDirectionEgress, PeerIp: "10.96.0.10", Port: 80, Protocol: "tcp", Connections: 2, TransmitBytes: 1000, ReceivedBytes: 5000},
+		{Pod: "api-0", Namespace: "default", Direction: FlowDirectionEgress, PeerIp: "93.216.26.34", Port: 443, Protocol: "tcp", Connections: 1},
+		{Pod: "api-0", Namespace: "default", Direction: FlowDirectionIngress, PeerIp: "10.0.0.7", Port: 8080, Protocol: "tcp", Connections: 1, TransmitBytes: 700, ReceivedBytes: 300},
+	}, flows)
+}
+
+func TestCollectPodNetworkFlowsSkipsHostNetwork(t *testing.T) {
+	procPath := t.TempDir()
+	for pid, netNs := range map[string]string{"1": "net:[4026531840]", "100": "net:[4026532001]", "200": "net:[4026531840]"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(procPath, pid, "ns"), 0o755))
+		require.NoError(t, os.MkdirAll(filepath.Join(procPath, pid, "net"), 0o755))
+		require.NoError(t, os.Symlink(netNs, filepath.Join(procPath, pid, "ns", "net")))
+		require.NoError(t, os.WriteFile(filepath.Join(procPath, pid, "net", "tcp"), []byte(procNetTcp), 0o644))
+	}
+
+	flows := collectPodNetworkFlows(procPath, []podProcess{
+		{Namespace: "default", Name: "api-0", Pid: 100},
+		{Namespace: "kube-system", Name: "kube-proxy-x", Pid: 200},
+	}, nil)
+	require.NotEmpty(t, flows)
+	for _, flow := range flows {
+		assert.Equal(t, "api-0", flow.Pod, "hostNetwork pods see the sockets of the whole node")
+	}
+}
+
+func TestParseConntrackWithoutAccounting(t *testing.T) {
+	conntrack := parseConntrack(strings.NewReader(
+		"ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.5 dst=10.96.0.10 sport=43202 dport=80 src=10.0.1.9 dst=10.0.0.5 sport=8080 dport=43202 [ASSURED] mark=0 use=2\n",
+	))
+	assert.Nil(t, conntrack)
+
+	tx, rx := conntrack.bytes(netip.MustParseAddrPort("10.0.0.5:43202"), netip.MustParseAddrPort("10.96.0.10:80"))
+	assert.Zero(t, tx)
+	assert.Zero(t, rx)
+}
@@ -21,6 +21,9 @@ type NetworkMonitor interface {
 	Run()
 	Snoopy() SnoopyManager
 	GetPodNetworkUsage() []PodNetworkStats
+	// GetPodNetworkFlows samples the established TCP connections of all pods
+	// on this node, grouped by peer address and server port.
+	GetPodNetworkFlows() []PodNetworkFlow
 	// BTF is the format used by BPF modules to be loaded across most linux distributions.
 	// Without BTF support we are not able to use BPF modules to implement features. This
 	// means limited feature availability. In some cases we might implement slower
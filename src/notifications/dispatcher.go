@@ -0,0 +1,134 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"mogenius-operator/src/utils"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DeliveryStatusDelivered   = "delivered"
+	DeliveryStatusFailed      = "failed"
+	DeliveryStatusRateLimited = "rateLimited"
+)
+
+// Delivery records the outcome of sending one notification to one channel.
+type Delivery struct {
+	Id           string       `json:"id"`
+	Channel      string       `json:"channel"`
+	ChannelType  string       `json:"channelType"`
+	Notification Notification `json:"notification"`
+	// Status is "delivered", "failed" or "rateLimited".
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// Dispatcher renders notifications and sends them to channels, retrying
+// failed attempts with exponential backoff.
+type Dispatcher struct {
+	client     *http.Client
+	limiter    *rateLimiter
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func NewDispatcher(client *http.Client) *Dispatcher {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Dispatcher{
+		client:     client,
+		limiter:    &rateLimiter{windows: map[string]rateWindow{}},
+		backoff:    2 * time.Second,
+		maxBackoff: 2 * time.Minute,
+	}
+}
+
+// Deliver blocks until the notification was sent, all attempts failed or ctx
+// ends.
+func (self *Dispatcher) Deliver(ctx context.Context, channel Channel, n Notification) Delivery {
+	delivery := Delivery{
+		Id:           utils.NanoId(),
+		Channel:      channel.Name,
+		ChannelType:  channel.Spec.Type,
+		Notification: n,
+		CreatedAt:    time.Now(),
+	}
+	finish := func(status string, err error) Delivery {
+		delivery.Status = status
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		delivery.FinishedAt = time.Now()
+		return delivery
+	}
+
+	text, err := Render(channel.Spec.Template, n)
+	if err != nil {
+		return finish(DeliveryStatusFailed, err)
+	}
+	maxPerHour := channel.Spec.MaxPerHour
+	if maxPerHour <= 0 {
+		maxPerHour = DefaultMaxPerHour
+	}
+	if !self.limiter.allow(channel.Name, maxPerHour, delivery.CreatedAt) {
+		return finish(DeliveryStatusRateLimited, nil)
+	}
+	maxAttempts := channel.Spec.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	for {
+		delivery.Attempts++
+		err = send(ctx, self.client, channel, n, text)
+		if err == nil {
+			return finish(DeliveryStatusDelivered, nil)
+		}
+		if _, permanent := errors.AsType[*PermanentError](err); permanent || delivery.Attempts >= maxAttempts {
+			return finish(DeliveryStatusFailed, err)
+		}
+		delay := min(self.backoff<<(delivery.Attempts-1), self.maxBackoff)
+		if retryAfter, ok := errors.AsType[*retryAfterError](err); ok && retryAfter.delay > delay {
+			delay = min(retryAfter.delay, self.maxBackoff)
+		}
+		select {
+		case <-ctx.Done():
+			return finish(DeliveryStatusFailed, errors.Join(err, ctx.Err()))
+		case <-time.After(delay):
+		}
+	}
+}
+
+// rateLimiter counts deliveries per channel in fixed one hour windows.
+type rateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func (self *rateLimiter) allow(key string, limit int, now time.Time) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	window := self.windows[key]
+	if now.Sub(window.start) >= time.Hour {
+		window = rateWindow{start: now}
+	}
+	if window.count >= limit {
+		return false
+	}
+	window.count++
+	self.windows[key] = window
+	return true
+}
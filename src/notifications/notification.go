@@ -0,0 +1,244 @@
+package notifications
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mogenius-operator/src/crds/v1alpha1"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	DefaultMaxPerHour  = 60
+	DefaultMaxAttempts = 5
+	DefaultSmtpPort    = 587
+
+	// Secret keys used when a secret reference names none.
+	DefaultUrlSecretKey      = "url"
+	DefaultPasswordSecretKey = "password"
+
+	// DefaultTemplate renders the title followed by the message.
+	DefaultTemplate = "{{.Title}}{{if .Message}}\n{{.Message}}{{end}}"
+)
+
+const (
+	SmtpTlsStartTls = "starttls"
+	SmtpTlsTls      = "tls"
+	SmtpTlsNone     = "none"
+)
+
+// Notification is a single event delivered to the matching NotificationChannels.
+type Notification struct {
+	Id string `json:"id"`
+	// Source is "aiTask", "alert" or "job".
+	Source string `json:"source"`
+	// Severity is "info", "warning" or "critical".
+	Severity string `json:"severity"`
+	Title    string `json:"title"`
+	Message  string `json:"message,omitempty"`
+	// Namespace the notification concerns, empty for cluster-wide ones.
+	Namespace string `json:"namespace,omitempty"`
+	// Workspaces containing Namespace.
+	Workspaces []string `json:"workspaces,omitempty"`
+	// Agent is the Agent CR of an AI task.
+	Agent string `json:"agent,omitempty"`
+	// Url links to details, e.g. the generator of an alert.
+	Url       string            `json:"url,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// Channel is a NotificationChannel with its secret references resolved.
+type Channel struct {
+	Name string
+	Spec v1alpha1.NotificationChannelSpec
+	// Url resolved from spec.url or spec.urlSecretRef.
+	Url string
+	// SmtpPassword resolved from spec.smtp.passwordSecretRef.
+	SmtpPassword string
+}
+
+var severityRank = map[string]int{
+	v1alpha1.NotificationSeverityInfo:     0,
+	v1alpha1.NotificationSeverityWarning:  1,
+	v1alpha1.NotificationSeverityCritical: 2,
+}
+
+// NormalizeSeverity maps the severity labels used by alerting rules onto
+// info, warning and critical. Unknown values count as warning.
+func NormalizeSeverity(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "critical", "error", "page", "high":
+		return v1alpha1.NotificationSeverityCritical
+	case "info", "none", "low":
+		return v1alpha1.NotificationSeverityInfo
+	default:
+		return v1alpha1.NotificationSeverityWarning
+	}
+}
+
+// Matches reports whether a channel with the given spec receives n.
+func Matches(spec v1alpha1.NotificationChannelSpec, n Notification) bool {
+	if len(spec.Routes) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(spec.Routes, func(route v1alpha1.NotificationRoute) bool {
+		return routeMatches(route, n)
+	})
+}
+
+func routeMatches(route v1alpha1.NotificationRoute, n Notification) bool {
+	if len(route.Sources) > 0 && !slices.Contains(route.Sources, n.Source) {
+		return false
+	}
+	if len(route.Workspaces) > 0 && !slices.ContainsFunc(n.Workspaces, func(workspace string) bool {
+		return slices.Contains(route.Workspaces, workspace)
+	}) {
+		return false
+	}
+	if route.MinSeverity != "" && severityRank[n.Severity] < severityRank[route.MinSeverity] {
+		return false
+	}
+	if len(route.Agents) > 0 && !slices.Contains(route.Agents, n.Agent) {
+		return false
+	}
+	if len(route.Patterns) > 0 && !slices.ContainsFunc(route.Patterns, func(pattern string) bool {
+		return globToRegexp(pattern).MatchString(n.Title)
+	}) {
+		return false
+	}
+	return true
+}
+
+// globToRegexp supports "*" and "?". Unlike path.Match, "*" also matches "/",
+// which titles like "Deployment shop/web" contain.
+func globToRegexp(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+	quoted = strings.ReplaceAll(quoted, `\?`, ".")
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// Render executes the channel template, or DefaultTemplate when it is empty.
+func Render(text string, n Notification) (string, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("notification").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, n); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// ValidateSpec checks everything the CRD schema cannot express. Secret
+// references are resolved by the caller.
+func ValidateSpec(spec v1alpha1.NotificationChannelSpec) error {
+	var errs []error
+	switch spec.Type {
+	case v1alpha1.NotificationChannelTypeSlack, v1alpha1.NotificationChannelTypeTeams, v1alpha1.NotificationChannelTypeWebhook:
+		if spec.Url == "" && (spec.UrlSecretRef == nil || spec.UrlSecretRef.Name == "") {
+			errs = append(errs, fmt.Errorf("spec.url or spec.urlSecretRef is required for type %q", spec.Type))
+		}
+		if spec.Url != "" {
+			if err := validateUrl(spec.Url); err != nil {
+				errs = append(errs, fmt.Errorf("spec.url: %w", err))
+			}
+		}
+	case v1alpha1.NotificationChannelTypeSmtp:
+		if spec.Smtp == nil {
+			errs = append(errs, errors.New("spec.smtp is required for type \"smtp\""))
+			break
+		}
+		if spec.Smtp.Host == "" || spec.Smtp.From == "" || len(spec.Smtp.To) == 0 {
+			errs = append(errs, errors.New("spec.smtp.host, spec.smtp.from and spec.smtp.to are required"))
+		}
+		if !slices.Contains([]string{"", SmtpTlsStartTls, SmtpTlsTls, SmtpTlsNone}, spec.Smtp.Tls) {
+			errs = append(errs, fmt.Errorf("spec.smtp.tls %q is not one of starttls, tls or none", spec.Smtp.Tls))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("spec.type %q is not one of slack, teams, webhook or smtp", spec.Type))
+	}
+	if _, err := template.New("notification").Parse(spec.Template); err != nil {
+		errs = append(errs, fmt.Errorf("spec.template: %w", err))
+	}
+	for i, route := range spec.Routes {
+		for _, source := range route.Sources {
+			if !slices.Contains([]string{v1alpha1.NotificationSourceAiTask, v1alpha1.NotificationSourceAlert, v1alpha1.NotificationSourceJob}, source) {
+				errs = append(errs, fmt.Errorf("spec.routes[%d].sources: unknown source %q", i, source))
+			}
+		}
+		if _, ok := severityRank[route.MinSeverity]; route.MinSeverity != "" && !ok {
+			errs = append(errs, fmt.Errorf("spec.routes[%d].minSeverity: unknown severity %q", i, route.MinSeverity))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateUrl(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("must be an absolute http(s) URL")
+	}
+	return nil
+}
+
+// SecretGetter returns the named Secret of the channel's namespace.
+type SecretGetter func(name string) (*corev1.Secret, error)
+
+// ResolveChannel validates the channel and reads the secrets it references.
+func ResolveChannel(channel v1alpha1.NotificationChannel, getSecret SecretGetter) (Channel, error) {
+	resolved := Channel{Name: channel.Name, Spec: channel.Spec, Url: channel.Spec.Url}
+	if err := ValidateSpec(channel.Spec); err != nil {
+		return resolved, err
+	}
+
+	readSecret := func(field string, ref *v1alpha1.SecretReference, defaultKey string) (string, error) {
+		key := ref.Key
+		if key == "" {
+			key = defaultKey
+		}
+		secret, err := getSecret(ref.Name)
+		if err != nil {
+			return "", fmt.Errorf("%s: secret %q: %w", field, ref.Name, err)
+		}
+		value, ok := secret.Data[key]
+		if !ok || len(value) == 0 {
+			return "", fmt.Errorf("%s: secret %q has no data key %q", field, ref.Name, key)
+		}
+		return strings.TrimSpace(string(value)), nil
+	}
+
+	if ref := channel.Spec.UrlSecretRef; ref != nil && ref.Name != "" && channel.Spec.Type != v1alpha1.NotificationChannelTypeSmtp {
+		value, err := readSecret("spec.urlSecretRef", ref, DefaultUrlSecretKey)
+		if err != nil {
+			return resolved, err
+		}
+		if err := validateUrl(value); err != nil {
+			return resolved, fmt.Errorf("spec.urlSecretRef: %w", err)
+		}
+		resolved.Url = value
+	}
+	if smtp := channel.Spec.Smtp; smtp != nil && smtp.PasswordSecretRef != nil && smtp.PasswordSecretRef.Name != "" {
+		value, err := readSecret("spec.smtp.passwordSecretRef", smtp.PasswordSecretRef, DefaultPasswordSecretKey)
+		if err != nil {
+			return resolved, err
+		}
+		resolved.SmtpPassword = value
+	}
+	return resolved, nil
+}
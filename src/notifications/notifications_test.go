@@ -0,0 +1,322 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mogenius-operator/src/crds/v1alpha1"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func testNotification() Notification {
+	return Notification{
+		Id:         "n1",
+		Source:     v1alpha1.NotificationSourceAlert,
+		Severity:   v1alpha1.NotificationSeverityCritical,
+		Title:      "KubePodCrashLooping shop/web",
+		Message:    "Pod shop/web is crash looping.",
+		Namespace:  "shop",
+		Workspaces: []string{"shop"},
+		CreatedAt:  time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestMatches(t *testing.T) {
+	n := testNotification()
+	assert.True(t, Matches(v1alpha1.NotificationChannelSpec{}, n))
+
+	for name, test := range map[string]struct {
+		route    v1alpha1.NotificationRoute
+		expected bool
+	}{
+		"source":             {v1alpha1.NotificationRoute{Sources: []string{"alert"}}, true},
+		"other source":       {v1alpha1.NotificationRoute{Sources: []string{"job", "aiTask"}}, false},
+		"workspace":          {v1alpha1.NotificationRoute{Workspaces: []string{"other", "shop"}}, true},
+		"other workspace":    {v1alpha1.NotificationRoute{Workspaces: []string{"other"}}, false},
+		"severity":           {v1alpha1.NotificationRoute{MinSeverity: "warning"}, true},
+		"agent":              {v1alpha1.NotificationRoute{Agents: []string{"triage"}}, false},
+		"pattern":            {v1alpha1.NotificationRoute{Patterns: []string{"KubePod*"}}, true},
+		"pattern with slash": {v1alpha1.NotificationRoute{Patterns: []string{"*shop/w?b"}}, true},
+		"other pattern":      {v1alpha1.NotificationRoute{Patterns: []string{"Node*"}}, false},
+		"all fields":         {v1alpha1.NotificationRoute{Sources: []string{"alert"}, Workspaces: []string{"shop"}, MinSeverity: "critical", Patterns: []string{"*"}}, true},
+	} {
+		spec := v1alpha1.NotificationChannelSpec{Routes: []v1alpha1.NotificationRoute{test.route}}
+		assert.Equal(t, test.expected, Matches(spec, n), name)
+	}
+
+	n.Severity = v1alpha1.NotificationSeverityInfo
+	spec := v1alpha1.NotificationChannelSpec{Routes: []v1alpha1.NotificationRoute{
+		{MinSeverity: "warning"},
+		{Sources: []string{"job"}},
+	}}
+	assert.False(t, Matches(spec, n))
+}
+
+func TestRender(t *testing.T) {
+	n := testNotification()
+	text, err := Render("", n)
+	require.NoError(t, err)
+	assert.Equal(t, "KubePodCrashLooping shop/web\nPod shop/web is crash looping.", text)
+
+	text, err = Render(`{{.Severity}}: {{.Title}} ({{join .Workspaces ","}})`, n)
+	require.Error(t, err)
+	assert.Empty(t, text)
+
+	text, err = Render(`{{.Severity}}: {{.Title}} ({{index .Workspaces 0}})`, n)
+	require.NoError(t, err)
+	assert.Equal(t, "critical: KubePodCrashLooping shop/web (shop)", text)
+}
+
+func TestValidateSpec(t *testing.T) {
+	assert.NoError(t, ValidateSpec(v1alpha1.NotificationChannelSpec{Type: "slack", UrlSecretRef: &v1alpha1.SecretReference{Name: "slack"}}))
+	assert.ErrorContains(t, ValidateSpec(v1alpha1.NotificationChannelSpec{Type: "teams"}), "spec.url or spec.urlSecretRef")
+	assert.ErrorContains(t, ValidateSpec(v1alpha1.NotificationChannelSpec{Type: "webhook", Url: "ftp://example.com"}), "http(s)")
+	assert.ErrorContains(t, ValidateSpec(v1alpha1.NotificationChannelSpec{Type: "smtp"}), "spec.smtp is required")
+	assert.ErrorContains(t, ValidateSpec(v1alpha1.NotificationChannelSpec{Type: "webhook", Url: "http://sink", Template: "{{.Title"}), "spec.template")
+	assert.ErrorContains(t, ValidateSpec(v1alpha1.NotificationChannelSpec{Type: "webhook", Url: "http://sink", Routes: []v1alpha1.NotificationRoute{{MinSeverity: "fatal"}}}), "minSeverity")
+}
+
+func TestResolveChannel(t *testing.T) {
+	secrets := map[string]*corev1.Secret{
+		"slack": {Data: map[string][]byte{"url": []byte("https://hooks.slack.com/services/T/B/X\n")}},
+		"mail":  {Data: map[string][]byte{"smtp-password": []byte("secret")}},
+	}
+	getSecret := func(name string) (*corev1.Secret, error) {
+		if secret, ok := secrets[name]; ok {
+			return secret, nil
+		}
+		return nil, fmt.Errorf("secrets %q not found", name)
+	}
+
+	channel := v1alpha1.NotificationChannel{Spec: v1alpha1.NotificationChannelSpec{Type: "slack", UrlSecretRef: &v1alpha1.SecretReference{Name: "slack"}}}
+	channel.Name = "alerts"
+	resolved, err := ResolveChannel(channel, getSecret)
+	require.NoError(t, err)
+	assert.Equal(t, "alerts", resolved.Name)
+	assert.Equal(t, "https://hooks.slack.com/services/T/B/X", resolved.Url)
+
+	channel.Spec.UrlSecretRef.Key = "webhook"
+	_, err = ResolveChannel(channel, getSecret)
+	assert.ErrorContains(t, err, `secret "slack" has no data key "webhook"`)
+
+	channel.Spec = v1alpha1.NotificationChannelSpec{Type: "smtp", Smtp: &v1alpha1.NotificationSmtpConfig{
+		Host:              "mail.example.com",
+		From:              "operator@example.com",
+		To:                []string{"ops@example.com"},
+		Username:          "operator",
+		PasswordSecretRef: &v1alpha1.SecretReference{Name: "mail", Key: "smtp-password"},
+	}}
+	resolved, err = ResolveChannel(channel, getSecret)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", resolved.SmtpPassword)
+
+	channel.Spec.Smtp.PasswordSecretRef.Name = "missing"
+	_, err = ResolveChannel(channel, getSecret)
+	assert.ErrorContains(t, err, "spec.smtp.passwordSecretRef")
+}
+
+// httpSink records every request it receives and answers with the queued
+// status codes, then 200.
+type httpSink struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+}
+
+func (self *httpSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	self.requests = append(self.requests, r)
+	self.bodies = append(self.bodies, body)
+	if len(self.statuses) > 0 {
+		w.WriteHeader(self.statuses[0])
+		self.statuses = self.statuses[1:]
+	}
+}
+
+func (self *httpSink) lastBody(t *testing.T) map[string]any {
+	t.Helper()
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	require.NotEmpty(t, self.bodies)
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(self.bodies[len(self.bodies)-1], &body))
+	return body
+}
+
+func newTestDispatcher(client *http.Client) *Dispatcher {
+	dispatcher := NewDispatcher(client)
+	dispatcher.backoff = time.Millisecond
+	return dispatcher
+}
+
+func TestDeliverHttpChannels(t *testing.T) {
+	sink := &httpSink{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+	dispatcher := newTestDispatcher(server.Client())
+	n := testNotification()
+
+	delivery := dispatcher.Deliver(context.Background(), Channel{Name: "slack", Spec: v1alpha1.NotificationChannelSpec{Type: "slack"}, Url: server.URL}, n)
+	assert.Equal(t, DeliveryStatusDelivered, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, map[string]any{"text": "KubePodCrashLooping shop/web\nPod shop/web is crash looping."}, sink.lastBody(t))
+
+	delivery = dispatcher.Deliver(context.Background(), Channel{Name: "teams", Spec: v1alpha1.NotificationChannelSpec{Type: "teams"}, Url: server.URL}, n)
+	assert.Equal(t, DeliveryStatusDelivered, delivery.Status)
+	teams := sink.lastBody(t)
+	assert.Equal(t, "message", teams["type"])
+	card := teams["attachments"].([]any)[0].(map[string]any)["content"].(map[string]any)
+	assert.Equal(t, "AdaptiveCard", card["type"])
+	assert.Equal(t, "Attention", card["body"].([]any)[0].(map[string]any)["color"])
+
+	spec := v1alpha1.NotificationChannelSpec{Type: "webhook", Headers: map[string]string{"Authorization": "Bearer token"}, Template: "{{.Title}}"}
+	delivery = dispatcher.Deliver(context.Background(), Channel{Name: "hook", Spec: spec, Url: server.URL + "/hook"}, n)
+	assert.Equal(t, DeliveryStatusDelivered, delivery.Status)
+	webhook := sink.lastBody(t)
+	assert.Equal(t, "KubePodCrashLooping shop/web", webhook["text"])
+	assert.Equal(t, "alert", webhook["source"])
+	assert.Equal(t, []any{"shop"}, webhook["workspaces"])
+	assert.Equal(t, "Bearer token", sink.requests[2].Header.Get("Authorization"))
+	assert.Equal(t, "/hook", sink.requests[2].URL.Path)
+}
+
+func TestDeliverRetries(t *testing.T) {
+	sink := &httpSink{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+	dispatcher := newTestDispatcher(server.Client())
+	channel := Channel{Name: "slack", Spec: v1alpha1.NotificationChannelSpec{Type: "slack"}, Url: server.URL}
+
+	delivery := dispatcher.Deliver(context.Background(), channel, testNotification())
+	assert.Equal(t, DeliveryStatusDelivered, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+
+	// client errors are not retried
+	sink.statuses = []int{http.StatusNotFound}
+	delivery = dispatcher.Deliver(context.Background(), channel, testNotification())
+	assert.Equal(t, DeliveryStatusFailed, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Contains(t, delivery.Error, "404")
+
+	channel.Spec.MaxAttempts = 2
+	sink.statuses = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+	delivery = dispatcher.Deliver(context.Background(), channel, testNotification())
+	assert.Equal(t, DeliveryStatusFailed, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+}
+
+func TestDeliverRateLimit(t *testing.T) {
+	sink := &httpSink{}
+	server := httptest.NewServer(sink)
+	defer server.Close()
+	dispatcher := newTestDispatcher(server.Client())
+	channel := Channel{Name: "slack", Spec: v1alpha1.NotificationChannelSpec{Type: "slack", MaxPerHour: 2}, Url: server.URL}
+
+	var statuses []string
+	for range 3 {
+		statuses = append(statuses, dispatcher.Deliver(context.Background(), channel, testNotification()).Status)
+	}
+	assert.Equal(t, []string{DeliveryStatusDelivered, DeliveryStatusDelivered, DeliveryStatusRateLimited}, statuses)
+	assert.Len(t, sink.requests, 2)
+
+	// channels are limited independently
+	channel.Name = "other"
+	assert.Equal(t, DeliveryStatusDelivered, dispatcher.Deliver(context.Background(), channel, testNotification()).Status)
+}
+
+// smtpSink accepts one mail without TLS or authentication.
+func smtpSink(t *testing.T) (string, int, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	mails := make(chan string, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		_ = text.PrintfLine("220 localhost ESMTP")
+		var envelope []string
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command, _, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(command) {
+			case "EHLO", "HELO":
+				_ = text.PrintfLine("250-localhost")
+				_ = text.PrintfLine("250 8BITMIME")
+			case "MAIL", "RCPT":
+				envelope = append(envelope, line)
+				_ = text.PrintfLine("250 OK")
+			case "DATA":
+				_ = text.PrintfLine("354 go ahead")
+				data, _ := io.ReadAll(bufio.NewReader(text.DotReader()))
+				mails <- strings.Join(envelope, "\n") + "\n\n" + string(data)
+				_ = text.PrintfLine("250 OK")
+			case "QUIT":
+				_ = text.PrintfLine("221 bye")
+				return
+			default:
+				_ = text.PrintfLine("502 unknown")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return host, portNumber, mails
+}
+
+func TestDeliverSmtp(t *testing.T) {
+	host, port, mails := smtpSink(t)
+	dispatcher := newTestDispatcher(nil)
+	spec := v1alpha1.NotificationChannelSpec{Type: "smtp", Smtp: &v1alpha1.NotificationSmtpConfig{
+		Host: host,
+		Port: port,
+		Tls:  SmtpTlsNone,
+		From: "operator@example.com",
+		To:   []string{"ops@example.com", "dev@example.com"},
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	delivery := dispatcher.Deliver(ctx, Channel{Name: "mail", Spec: spec}, testNotification())
+	require.Equal(t, DeliveryStatusDelivered, delivery.Status, delivery.Error)
+	mail := <-mails
+	assert.Contains(t, mail, "MAIL FROM:<operator@example.com>")
+	assert.Contains(t, mail, "RCPT TO:<dev@example.com>")
+	assert.Contains(t, mail, "Subject: [critical] KubePodCrashLooping shop/web\n")
+	assert.Contains(t, mail, "To: ops@example.com, dev@example.com\n")
+	assert.Contains(t, mail, "\n\nKubePodCrashLooping shop/web\nPod shop/web is crash looping.\n")
+
+	// without STARTTLS support the default mode refuses to send in plain text
+	host, port, _ = smtpSink(t)
+	spec.Smtp.Host, spec.Smtp.Port, spec.Smtp.Tls = host, port, ""
+	delivery = dispatcher.Deliver(ctx, Channel{Name: "mail", Spec: spec}, testNotification())
+	assert.Equal(t, DeliveryStatusFailed, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Contains(t, delivery.Error, "STARTTLS")
+}
@@ -0,0 +1,216 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mogenius-operator/src/crds/v1alpha1"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PermanentError marks failures a retry cannot fix, e.g. a rejected request.
+type PermanentError struct {
+	Err error
+}
+
+func (self *PermanentError) Error() string { return self.Err.Error() }
+func (self *PermanentError) Unwrap() error { return self.Err }
+
+// retryAfterError carries the delay a rate limited endpoint asked for.
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (self *retryAfterError) Error() string { return self.err.Error() }
+func (self *retryAfterError) Unwrap() error { return self.err }
+
+func send(ctx context.Context, client *http.Client, channel Channel, n Notification, text string) error {
+	switch channel.Spec.Type {
+	case v1alpha1.NotificationChannelTypeSlack:
+		return postJson(ctx, client, channel.Url, nil, map[string]string{"text": text})
+	case v1alpha1.NotificationChannelTypeTeams:
+		return postJson(ctx, client, channel.Url, nil, teamsMessage(n, text))
+	case v1alpha1.NotificationChannelTypeWebhook:
+		return postJson(ctx, client, channel.Url, channel.Spec.Headers, struct {
+			Notification
+			Text string `json:"text"`
+		}{n, text})
+	case v1alpha1.NotificationChannelTypeSmtp:
+		return sendMail(ctx, channel, n, text)
+	default:
+		return &PermanentError{fmt.Errorf("unknown channel type %q", channel.Spec.Type)}
+	}
+}
+
+// teamsMessage is an Adaptive Card, which both Teams workflow webhooks and
+// the older incoming webhook connectors accept.
+func teamsMessage(n Notification, text string) map[string]any {
+	color := "Default"
+	switch n.Severity {
+	case v1alpha1.NotificationSeverityCritical:
+		color = "Attention"
+	case v1alpha1.NotificationSeverityWarning:
+		color = "Warning"
+	}
+	body := []map[string]any{
+		{"type": "TextBlock", "text": n.Title, "weight": "Bolder", "size": "Medium", "color": color, "wrap": true},
+		{"type": "TextBlock", "text": text, "wrap": true},
+	}
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if n.Url != "" {
+		card["actions"] = []map[string]any{{"type": "Action.OpenUrl", "title": "Details", "url": n.Url}}
+	}
+	return map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
+
+func postJson(ctx context.Context, client *http.Client, url string, headers map[string]string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return &PermanentError{err}
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{err}
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, response.Body)
+		return nil
+	}
+
+	detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+	err = fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(detail)))
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests:
+		seconds, _ := strconv.Atoi(response.Header.Get("Retry-After"))
+		return &retryAfterError{err, time.Duration(seconds) * time.Second}
+	case response.StatusCode == http.StatusRequestTimeout || response.StatusCode >= 500:
+		return err
+	default:
+		return &PermanentError{err}
+	}
+}
+
+func sendMail(ctx context.Context, channel Channel, n Notification, text string) error {
+	config := channel.Spec.Smtp
+	if config == nil {
+		return &PermanentError{errors.New("spec.smtp is missing")}
+	}
+	port := config.Port
+	if port == 0 {
+		port = DefaultSmtpPort
+	}
+	address := net.JoinHostPort(config.Host, strconv.Itoa(port))
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if config.Tls == SmtpTlsTls {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: config.Host}}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if config.Tls == "" || config.Tls == SmtpTlsStartTls {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return &PermanentError{errors.New("the mail server does not offer STARTTLS, set spec.smtp.tls to \"tls\" or \"none\"")}
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: config.Host}); err != nil {
+			return err
+		}
+	}
+	if config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", config.Username, channel.SmtpPassword, config.Host)); err != nil {
+			return smtpError(err)
+		}
+	}
+	if err := client.Mail(config.From); err != nil {
+		return smtpError(err)
+	}
+	for _, to := range config.To {
+		if err := client.Rcpt(to); err != nil {
+			return smtpError(err)
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return smtpError(err)
+	}
+	if _, err := writer.Write(mailMessage(config, n, text)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return smtpError(err)
+	}
+	return client.Quit()
+}
+
+// smtpError treats 5xx replies as permanent.
+func smtpError(err error) error {
+	if reply, ok := errors.AsType[*textproto.Error](err); ok && reply.Code >= 500 {
+		return &PermanentError{err}
+	}
+	return err
+}
+
+func mailMessage(config *v1alpha1.NotificationSmtpConfig, n Notification, text string) []byte {
+	var buf bytes.Buffer
+	header := func(key string, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+	header("From", config.From)
+	header("To", strings.Join(config.To, ", "))
+	// the encoding also keeps line breaks of titles out of the header
+	header("Subject", mime.QEncoding.Encode("utf-8", fmt.Sprintf("[%s] %s", n.Severity, n.Title)))
+	header("Date", n.CreatedAt.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "8bit")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
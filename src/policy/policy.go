@@ -0,0 +1,272 @@
+package policy
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"mogenius-operator/src/crds/v1alpha1"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// costLimit bounds the work a single expression may do, so a policy looping
+// over huge lists cannot stall a pattern.
+const costLimit = 1_000_000
+
+// Request describes the change a policy is evaluated for. It is available to
+// expressions as `request`.
+type Request struct {
+	// Operation is "CREATE" or "UPDATE".
+	Operation string `json:"operation"`
+	// Source is the path writing the change: the pattern (e.g.
+	// "update/workload") or "ai/<tool>".
+	Source     string   `json:"source"`
+	User       string   `json:"user,omitempty"`
+	Namespace  string   `json:"namespace,omitempty"`
+	Workspaces []string `json:"workspaces,omitempty"`
+}
+
+func (self Request) celValue() map[string]any {
+	return map[string]any{
+		"operation":  self.Operation,
+		"source":     self.Source,
+		"user":       self.User,
+		"namespace":  self.Namespace,
+		"workspaces": self.Workspaces,
+	}
+}
+
+// Violation is one failed validation of one policy for one resource.
+type Violation struct {
+	Policy string `json:"policy"`
+	// Mode of the policy: "enforce" violations reject the change, "audit"
+	// violations are only recorded.
+	Mode       string `json:"mode"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Message    string `json:"message"`
+}
+
+func (self Violation) String() string {
+	return fmt.Sprintf("%s %s/%s violates policy %q: %s", self.Kind, self.Namespace, self.Name, self.Policy, self.Message)
+}
+
+// DeniedError rejects a change violating at least one enforced policy. It
+// carries every violation found, audit ones included.
+type DeniedError struct {
+	Violations []Violation
+}
+
+func (self *DeniedError) Error() string {
+	lines := []string{"rejected by policy:"}
+	for _, violation := range self.Violations {
+		if violation.Mode == v1alpha1.PolicyModeEnforce {
+			lines = append(lines, "  - "+violation.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Recorder collects the violations found while one request is handled, audit
+// ones included, so its response can report them.
+type Recorder struct {
+	lock       sync.Mutex
+	violations []Violation
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns a context carrying a new Recorder.
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	recorder := &Recorder{}
+	return context.WithValue(ctx, recorderKey{}, recorder), recorder
+}
+
+// Record adds violations to the Recorder of ctx, if it carries one.
+func Record(ctx context.Context, violations []Violation) {
+	recorder, ok := ctx.Value(recorderKey{}).(*Recorder)
+	if !ok || len(violations) == 0 {
+		return
+	}
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.violations = append(recorder.violations, violations...)
+}
+
+// Violations returns what was recorded so far.
+func (self *Recorder) Violations() []Violation {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return slices.Clone(self.violations)
+}
+
+// Denied returns a DeniedError when any violation is enforced, nil otherwise.
+func Denied(violations []Violation) error {
+	if slices.ContainsFunc(violations, func(violation Violation) bool { return violation.Mode == v1alpha1.PolicyModeEnforce }) {
+		return &DeniedError{Violations: violations}
+	}
+	return nil
+}
+
+// ViolationsFromError returns the violations of a DeniedError in err's chain.
+func ViolationsFromError(err error) []Violation {
+	if denied, ok := errors.AsType[*DeniedError](err); ok {
+		return denied.Violations
+	}
+	return nil
+}
+
+var celEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("oldObject", cel.DynType),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		ext.Strings(),
+		ext.Lists(),
+		ext.Sets(),
+	)
+})
+
+// Compile compiles the validations of a policy; every expression has to
+// return a bool.
+func Compile(spec v1alpha1.PolicySpec) ([]cel.Program, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+	programs := make([]cel.Program, 0, len(spec.Validations))
+	for i, validation := range spec.Validations {
+		ast, issues := env.Compile(validation.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("validations[%d]: %s", i, issues.Err())
+		}
+		if !ast.OutputType().IsExactType(cel.BoolType) && !ast.OutputType().IsExactType(cel.DynType) {
+			return nil, fmt.Errorf("validations[%d]: expression returns %s, expected bool", i, ast.OutputType())
+		}
+		program, err := env.Program(ast, cel.CostLimit(costLimit))
+		if err != nil {
+			return nil, fmt.Errorf("validations[%d]: %s", i, err)
+		}
+		programs = append(programs, program)
+	}
+	return programs, nil
+}
+
+type compiledPolicy struct {
+	uid        types.UID
+	generation int64
+	programs   []cel.Program
+	err        error
+}
+
+// Evaluator keeps the compiled programs of every policy until its
+// generation changes.
+type Evaluator struct {
+	lock     sync.Mutex
+	compiled map[string]compiledPolicy
+}
+
+func NewEvaluator() *Evaluator {
+	return &Evaluator{compiled: map[string]compiledPolicy{}}
+}
+
+func (self *Evaluator) programs(policy v1alpha1.Policy) ([]cel.Program, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	key := policy.Namespace + "/" + policy.Name
+	if compiled, ok := self.compiled[key]; ok && compiled.uid == policy.UID && compiled.generation == policy.Generation {
+		return compiled.programs, compiled.err
+	}
+	programs, err := Compile(policy.Spec)
+	self.compiled[key] = compiledPolicy{uid: policy.UID, generation: policy.Generation, programs: programs, err: err}
+	return programs, err
+}
+
+// Evaluate checks object against every enabled policy matching it. oldObject
+// is nil on creates.
+func (self *Evaluator) Evaluate(policies []v1alpha1.Policy, request Request, object map[string]any, oldObject map[string]any) []Violation {
+	kind, _ := object["kind"].(string)
+	metadata, _ := object["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+
+	violations := []Violation{}
+	for _, policy := range policies {
+		if policy.Spec.Disabled || !Matches(policy.Spec.Match, kind, request) {
+			continue
+		}
+		mode := policy.Spec.Mode
+		if mode == "" {
+			mode = v1alpha1.PolicyModeEnforce
+		}
+		ignoreErrors := policy.Spec.FailurePolicy == v1alpha1.PolicyFailurePolicyIgnore
+		violation := func(expression string, message string) Violation {
+			return Violation{
+				Policy:     policy.Name,
+				Mode:       mode,
+				Kind:       kind,
+				Namespace:  request.Namespace,
+				Name:       name,
+				Expression: expression,
+				Message:    message,
+			}
+		}
+
+		programs, err := self.programs(policy)
+		if err != nil {
+			if !ignoreErrors {
+				violations = append(violations, violation("", "policy does not compile: "+err.Error()))
+			}
+			continue
+		}
+
+		activation := map[string]any{"object": object, "oldObject": oldObject, "request": request.celValue()}
+		for i, program := range programs {
+			validation := policy.Spec.Validations[i]
+			result, _, err := program.Eval(activation)
+			if err != nil {
+				if !ignoreErrors {
+					violations = append(violations, violation(validation.Expression, "expression failed: "+err.Error()))
+				}
+				continue
+			}
+			passed, ok := result.Value().(bool)
+			if !ok {
+				if !ignoreErrors {
+					violations = append(violations, violation(validation.Expression, fmt.Sprintf("expression returned %v instead of a bool", result.Value())))
+				}
+				continue
+			}
+			if !passed {
+				violations = append(violations, violation(validation.Expression, cmp.Or(validation.Message, validation.Expression)))
+			}
+		}
+	}
+	return violations
+}
+
+// Matches reports whether a policy applies to a resource of kind written by
+// request.
+func Matches(match v1alpha1.PolicyMatch, kind string, request Request) bool {
+	if len(match.Kinds) > 0 && !slices.Contains(match.Kinds, kind) {
+		return false
+	}
+	if len(match.Namespaces) > 0 && !slices.Contains(match.Namespaces, request.Namespace) {
+		return false
+	}
+	if len(match.Workspaces) > 0 && !slices.ContainsFunc(match.Workspaces, func(workspace string) bool { return slices.Contains(request.Workspaces, workspace) }) {
+		return false
+	}
+	if len(match.Operations) > 0 && !slices.Contains(match.Operations, request.Operation) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"context"
+	"mogenius-operator/src/crds/v1alpha1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func testPolicy(name string, spec v1alpha1.PolicySpec) v1alpha1.Policy {
+	return v1alpha1.Policy{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "mogenius", Generation: 1}, Spec: spec}
+}
+
+func testObject(t *testing.T, manifest string) map[string]any {
+	object := map[string]any{}
+	require.NoError(t, yaml.Unmarshal([]byte(manifest), &object))
+	return object
+}
+
+const testDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: shop
+spec:
+  template:
+    spec:
+      containers:
+      - name: api
+        image: registry.example.com/api:latest
+      volumes:
+      - name: host
+        hostPath:
+          path: /var/run
+`
+
+func TestEvaluate(t *testing.T) {
+	policies := []v1alpha1.Policy{
+		testPolicy("no-latest", v1alpha1.PolicySpec{
+			Validations: []v1alpha1.PolicyValidation{{
+				Expression: "object.spec.template.spec.containers.all(c, !c.image.endsWith(':latest'))",
+				Message:    "images must not use the latest tag",
+			}},
+		}),
+		testPolicy("limits-in-prod", v1alpha1.PolicySpec{
+			Mode:  v1alpha1.PolicyModeAudit,
+			Match: v1alpha1.PolicyMatch{Kinds: []string{"Deployment"}, Workspaces: []string{"prod"}},
+			Validations: []v1alpha1.PolicyValidation{{
+				Expression: "object.spec.template.spec.containers.all(c, has(c.resources) && has(c.resources.limits))",
+			}},
+		}),
+		testPolicy("no-hostpath", v1alpha1.PolicySpec{
+			Match: v1alpha1.PolicyMatch{Operations: []string{v1alpha1.PolicyOperationCreate}},
+			Validations: []v1alpha1.PolicyValidation{{
+				Expression: "!has(object.spec.template.spec.volumes) || object.spec.template.spec.volumes.all(v, !has(v.hostPath))",
+				Message:    "hostPath volumes are not allowed",
+			}},
+		}),
+		testPolicy("disabled", v1alpha1.PolicySpec{
+			Disabled:    true,
+			Validations: []v1alpha1.PolicyValidation{{Expression: "false"}},
+		}),
+	}
+	evaluator := NewEvaluator()
+	object := testObject(t, testDeployment)
+
+	request := Request{Operation: v1alpha1.PolicyOperationCreate, Source: "create/new-workload", Namespace: "shop", Workspaces: []string{"prod"}}
+	violations := evaluator.Evaluate(policies, request, object, nil)
+	require.Len(t, violations, 3)
+	assert.Equal(t, Violation{
+		Policy:     "no-latest",
+		Mode:       v1alpha1.PolicyModeEnforce,
+		Kind:       "Deployment",
+		Namespace:  "shop",
+		Name:       "api",
+		Expression: "object.spec.template.spec.containers.all(c, !c.image.endsWith(':latest'))",
+		Message:    "images must not use the latest tag",
+	}, violations[0])
+	assert.Equal(t, v1alpha1.PolicyModeAudit, violations[1].Mode)
+	assert.Equal(t, violations[1].Expression, violations[1].Message, "the expression is the default message")
+	assert.Equal(t, "hostPath volumes are not allowed", violations[2].Message)
+
+	err := Denied(violations)
+	require.Error(t, err)
+	assert.Len(t, ViolationsFromError(err), 3)
+	assert.NotContains(t, err.Error(), "limits-in-prod", "audit violations do not reject")
+
+	// updates outside the workspace only hit the tag policy
+	request = Request{Operation: v1alpha1.PolicyOperationUpdate, Namespace: "shop"}
+	violations = evaluator.Evaluate(policies, request, object, object)
+	require.Len(t, violations, 1)
+	assert.Equal(t, "no-latest", violations[0].Policy)
+
+	// audit violations alone let the change pass
+	assert.NoError(t, Denied([]Violation{{Policy: "limits-in-prod", Mode: v1alpha1.PolicyModeAudit}}))
+}
+
+func TestEvaluateFailurePolicy(t *testing.T) {
+	object := testObject(t, testDeployment)
+	request := Request{Operation: v1alpha1.PolicyOperationCreate, Namespace: "shop"}
+	spec := v1alpha1.PolicySpec{Validations: []v1alpha1.PolicyValidation{{Expression: "object.spec.replicas > 1"}}}
+
+	violations := NewEvaluator().Evaluate([]v1alpha1.Policy{testPolicy("replicas", spec)}, request, object, nil)
+	require.Len(t, violations, 1)
+	assert.Contains(t, violations[0].Message, "expression failed")
+
+	spec.FailurePolicy = v1alpha1.PolicyFailurePolicyIgnore
+	assert.Empty(t, NewEvaluator().Evaluate([]v1alpha1.Policy{testPolicy("replicas", spec)}, request, object, nil))
+}
+
+func TestCompile(t *testing.T) {
+	_, err := Compile(v1alpha1.PolicySpec{Validations: []v1alpha1.PolicyValidation{
+		{Expression: "request.operation == 'CREATE' || oldObject.spec == object.spec"},
+	}})
+	assert.NoError(t, err)
+
+	_, err = Compile(v1alpha1.PolicySpec{Validations: []v1alpha1.PolicyValidation{{Expression: "true"}, {Expression: "object.spec.("}}})
+	assert.ErrorContains(t, err, "validations[1]")
+
+	_, err = Compile(v1alpha1.PolicySpec{Validations: []v1alpha1.PolicyValidation{{Expression: "'a string'"}}})
+	assert.ErrorContains(t, err, "expected bool")
+
+	// a policy that does not compile counts as violation
+	violations := NewEvaluator().Evaluate(
+		[]v1alpha1.Policy{testPolicy("broken", v1alpha1.PolicySpec{Validations: []v1alpha1.PolicyValidation{{Expression: "object.("}}})},
+		Request{}, map[string]any{"kind": "ConfigMap"}, nil,
+	)
+	require.Len(t, violations, 1)
+	assert.Contains(t, violations[0].Message, "does not compile")
+}
+
+func TestRecorder(t *testing.T) {
+	audit := Violation{Policy: "labels", Mode: v1alpha1.PolicyModeAudit}
+	enforce := Violation{Policy: "limits", Mode: v1alpha1.PolicyModeEnforce}
+
+	// without a recorder nothing is kept
+	Record(context.Background(), []Violation{audit})
+
+	ctx, recorder := WithRecorder(context.Background())
+	Record(ctx, []Violation{audit})
+	Record(ctx, nil)
+	Record(ctx, []Violation{enforce})
+	assert.Equal(t, []Violation{audit, enforce}, recorder.Violations())
+
+	assert.NoError(t, Denied([]Violation{audit}), "audit violations do not reject the change")
+	assert.Equal(t, []Violation{audit, enforce}, ViolationsFromError(Denied([]Violation{audit, enforce})))
+}
@@ -0,0 +1,195 @@
+// Package prompb holds the messages of the Prometheus remote-write protocol
+// 1.0 (prometheus/prompb/remote.proto and types.proto) the operator sends.
+// Only WriteRequest with its series, labels and samples is covered; metadata,
+// exemplars and histograms are never written. The wire format matches what
+// the generated gogo code of Prometheus produces, zero values left out.
+package prompb
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+type TimeSeries struct {
+	// Labels are sorted by name and include "__name__".
+	Labels  []Label
+	Samples []Sample
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Value float64
+	// Timestamp is in milliseconds since the epoch.
+	Timestamp int64
+}
+
+// Field numbers of remote.proto and types.proto.
+const (
+	writeRequestTimeseries protowire.Number = 1
+	timeSeriesLabels       protowire.Number = 1
+	timeSeriesSamples      protowire.Number = 2
+	labelName              protowire.Number = 1
+	labelValue             protowire.Number = 2
+	sampleValue            protowire.Number = 1
+	sampleTimestamp        protowire.Number = 2
+)
+
+func (self *WriteRequest) Marshal() ([]byte, error) {
+	var data []byte
+	for _, series := range self.Timeseries {
+		data = protowire.AppendTag(data, writeRequestTimeseries, protowire.BytesType)
+		data = protowire.AppendBytes(data, series.marshal())
+	}
+	return data, nil
+}
+
+func (self *TimeSeries) marshal() []byte {
+	var data []byte
+	for _, label := range self.Labels {
+		data = protowire.AppendTag(data, timeSeriesLabels, protowire.BytesType)
+		data = protowire.AppendBytes(data, label.marshal())
+	}
+	for _, sample := range self.Samples {
+		data = protowire.AppendTag(data, timeSeriesSamples, protowire.BytesType)
+		data = protowire.AppendBytes(data, sample.marshal())
+	}
+	return data
+}
+
+func (self *Label) marshal() []byte {
+	var data []byte
+	if self.Name != "" {
+		data = protowire.AppendTag(data, labelName, protowire.BytesType)
+		data = protowire.AppendString(data, self.Name)
+	}
+	if self.Value != "" {
+		data = protowire.AppendTag(data, labelValue, protowire.BytesType)
+		data = protowire.AppendString(data, self.Value)
+	}
+	return data
+}
+
+func (self *Sample) marshal() []byte {
+	var data []byte
+	if self.Value != 0 {
+		data = protowire.AppendTag(data, sampleValue, protowire.Fixed64Type)
+		data = protowire.AppendFixed64(data, math.Float64bits(self.Value))
+	}
+	if self.Timestamp != 0 {
+		data = protowire.AppendTag(data, sampleTimestamp, protowire.VarintType)
+		data = protowire.AppendVarint(data, uint64(self.Timestamp))
+	}
+	return data
+}
+
+// Unmarshal decodes a WriteRequest. Fields it does not know are skipped.
+func (self *WriteRequest) Unmarshal(data []byte) error {
+	self.Timeseries = nil
+	return eachField(data, func(number protowire.Number, wireType protowire.Type, value []byte) error {
+		if number == writeRequestTimeseries && wireType == protowire.BytesType {
+			var series TimeSeries
+			if err := series.unmarshal(value); err != nil {
+				return err
+			}
+			self.Timeseries = append(self.Timeseries, series)
+		}
+		return nil
+	})
+}
+
+func (self *TimeSeries) unmarshal(data []byte) error {
+	return eachField(data, func(number protowire.Number, wireType protowire.Type, value []byte) error {
+		if wireType != protowire.BytesType {
+			return nil
+		}
+		switch number {
+		case timeSeriesLabels:
+			var label Label
+			if err := label.unmarshal(value); err != nil {
+				return err
+			}
+			self.Labels = append(self.Labels, label)
+		case timeSeriesSamples:
+			var sample Sample
+			if err := sample.unmarshal(value); err != nil {
+				return err
+			}
+			self.Samples = append(self.Samples, sample)
+		}
+		return nil
+	})
+}
+
+func (self *Label) unmarshal(data []byte) error {
+	return eachField(data, func(number protowire.Number, wireType protowire.Type, value []byte) error {
+		if wireType != protowire.BytesType {
+			return nil
+		}
+		switch number {
+		case labelName:
+			self.Name = string(value)
+		case labelValue:
+			self.Value = string(value)
+		}
+		return nil
+	})
+}
+
+func (self *Sample) unmarshal(data []byte) error {
+	return eachField(data, func(number protowire.Number, wireType protowire.Type, value []byte) error {
+		switch {
+		case number == sampleValue && wireType == protowire.Fixed64Type:
+			bits, n := protowire.ConsumeFixed64(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			self.Value = math.Float64frombits(bits)
+		case number == sampleTimestamp && wireType == protowire.VarintType:
+			timestamp, n := protowire.ConsumeVarint(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			self.Timestamp = int64(timestamp)
+		}
+		return nil
+	})
+}
+
+// eachField calls fn for every field of a message. Length-delimited values
+// are passed without their length prefix, all others as encoded.
+func eachField(data []byte, fn func(number protowire.Number, wireType protowire.Type, value []byte) error) error {
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("invalid field tag: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+		size := protowire.ConsumeFieldValue(number, wireType, data)
+		if size < 0 {
+			return fmt.Errorf("invalid field %d: %w", number, protowire.ParseError(size))
+		}
+		value := data[:size]
+		if wireType == protowire.BytesType {
+			var m int
+			value, m = protowire.ConsumeBytes(value)
+			if m < 0 {
+				return fmt.Errorf("invalid field %d: %w", number, protowire.ParseError(m))
+			}
+		}
+		if err := fn(number, wireType, value); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package prompb
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRequestMarshal(t *testing.T) {
+	request := WriteRequest{Timeseries: []TimeSeries{
+		{Labels: []Label{{Name: "__name__", Value: "up"}, {Name: "node", Value: "worker-1"}}, Samples: []Sample{{Value: 1, Timestamp: 1790000000000}}},
+		{Labels: []Label{{Name: "__name__", Value: "temp"}}, Samples: []Sample{{Value: -0.5, Timestamp: -1}, {Value: 0, Timestamp: 0}, {Value: math.Inf(1), Timestamp: 2}}},
+		{},
+	}}
+
+	data, err := request.Marshal()
+	require.NoError(t, err)
+	// encoded by the generated code of github.com/prometheus/prometheus/prompb
+	assert.Equal(t, "0a340a0e0a085f5f6e616d655f5f120275700a100a046e6f64651208776f726b65722d31121009000000000000f03f1080d8c1a28c340a370a100a085f5f6e616d655f5f120474656d70121409000000000000e0bf10ffffffffffffffffff011200120b09000000000000f07f10020a00", hex.EncodeToString(data))
+
+	var decoded WriteRequest
+	require.NoError(t, decoded.Unmarshal(data))
+	require.Len(t, decoded.Timeseries, 3)
+	assert.Equal(t, request.Timeseries[:2], decoded.Timeseries[:2])
+	assert.Empty(t, decoded.Timeseries[2].Labels)
+
+	assert.Error(t, decoded.Unmarshal(data[:len(data)-3]), "truncated requests are rejected")
+}
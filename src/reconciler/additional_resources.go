@@ -3,27 +3,8 @@ package reconciler
 import (
 	"mogenius-operator/src/crds/v1alpha1"
 	"mogenius-operator/src/utils"
-	"regexp"
-	"strings"
 )
 
-var repoNameSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
-
-// repositorySecretName derives a stable, DNS-safe Kubernetes resource name from a repository URL.
-func repositorySecretName(url string) string {
-	s := strings.ToLower(url)
-	for _, prefix := range []string{"https://", "http://", "ssh://", "git@"} {
-		s = strings.TrimPrefix(s, prefix)
-	}
-	s = repoNameSanitizer.ReplaceAllString(s, "-")
-	s = strings.Trim(s, "-")
-	// "mo-repo-" prefix is 8 chars; Kubernetes names are max 63 chars.
-	if len(s) > 55 {
-		s = s[:55]
-	}
-	return "mo-repo-" + s
-}
-
 func externalSecretResource(name, namespace string, es v1alpha1.ExternalSecret, targetLabels map[string]string, extraData map[string]string) map[string]any {
 	key := "token"
 
@@ -0,0 +1,217 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/utils"
+	"slices"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// defaultBackupScheduleKeepLast applies when spec.keepLast was not defaulted
+// by the API server (e.g. objects created before the default existed).
+const defaultBackupScheduleKeepLast = 7
+
+// reconcileBackupSchedules creates the WorkspaceBackup that is due, prunes old
+// ones and arms a timer for the next run, since the background sweep alone
+// would delay a backup by up to its interval.
+func (d *reconcilerModule) reconcileBackupSchedules(ctx context.Context, obj *unstructured.Unstructured, op operation) []ReconcileResult {
+	key := obj.GetNamespace() + "/" + obj.GetName()
+	if op == deleteOperation {
+		d.stopBackupScheduleTimer(key)
+		return nil
+	}
+
+	var schedule v1alpha1.BackupSchedule
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &schedule); err != nil {
+		return []ReconcileResult{{Err: fmt.Errorf("failed to parse BackupSchedule: %w", err)}}
+	}
+
+	var results []ReconcileResult
+	status := schedule.Status.DeepCopy()
+	condition := metav1.Condition{
+		Type:               v1alpha1.BackupScheduleConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Scheduled",
+		ObservedGeneration: schedule.Generation,
+	}
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	switch {
+	case err != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "InvalidSchedule"
+		condition.Message = fmt.Sprintf("spec.schedule %q is invalid: %v", schedule.Spec.Schedule, err)
+		status.NextScheduleTime = nil
+		d.stopBackupScheduleTimer(key)
+	case schedule.Spec.Suspend:
+		condition.Reason = "Suspended"
+		condition.Message = "Schedule is suspended"
+		status.NextScheduleTime = nil
+		d.stopBackupScheduleTimer(key)
+	default:
+		now := time.Now().UTC()
+		next := nextBackupScheduleTime(cronSchedule, schedule.CreationTimestamp.Time, status.LastScheduleTime, now)
+		if !next.After(now) {
+			name, err := d.createScheduledBackup(&schedule, now)
+			if err != nil {
+				results = append(results, ReconcileResult{Err: fmt.Errorf("BackupSchedule %q failed to create a backup: %w", schedule.Name, err)})
+				condition.Status = metav1.ConditionFalse
+				condition.Reason = "CreateFailed"
+				condition.Message = err.Error()
+			} else {
+				status.LastScheduleTime = &metav1.Time{Time: now}
+				status.LastBackup = name
+			}
+			// missed runs collapse into this one
+			next = cronSchedule.Next(now)
+		}
+		status.NextScheduleTime = &metav1.Time{Time: next}
+		if condition.Status == metav1.ConditionTrue {
+			condition.Message = fmt.Sprintf("Next backup at %s", next.Format(time.RFC3339))
+		}
+		d.armBackupScheduleTimer(key, next)
+
+		if err := d.pruneScheduledBackups(&schedule); err != nil {
+			results = append(results, ReconcileResult{Err: fmt.Errorf("BackupSchedule %q failed to prune backups: %w", schedule.Name, err), IsWarning: true})
+		}
+	}
+
+	apimeta.SetStatusCondition(&status.Conditions, condition)
+	if !equalBackupScheduleStatus(&schedule.Status, status) {
+		schedule.Status = *status
+		if _, err := d.clientProvider.MogeniusClientSet().MogeniusV1alpha1.UpdateBackupScheduleStatus(&schedule); err != nil {
+			results = append(results, ReconcileResult{Err: fmt.Errorf("failed to update status of BackupSchedule %q: %w", schedule.Name, err)})
+		}
+	}
+	if condition.Status == metav1.ConditionFalse && len(results) == 0 {
+		results = append(results, ReconcileResult{Err: fmt.Errorf("BackupSchedule %q is not ready: %s: %s", schedule.Name, condition.Reason, condition.Message), IsWarning: true})
+	}
+	return results
+}
+
+// nextBackupScheduleTime returns the first run after the last one, or after
+// the creation of the schedule when it never ran.
+func nextBackupScheduleTime(schedule cron.Schedule, created time.Time, lastScheduled *metav1.Time, now time.Time) time.Time {
+	last := created
+	if lastScheduled != nil {
+		last = lastScheduled.Time
+	}
+	if last.IsZero() {
+		last = now
+	}
+	return schedule.Next(last.UTC())
+}
+
+func (d *reconcilerModule) createScheduledBackup(schedule *v1alpha1.BackupSchedule, now time.Time) (string, error) {
+	name := fmt.Sprintf("%s-%s", schedule.Name, now.Format("20060102-150405"))
+	labels := map[string]string{v1alpha1.BackupScheduleLabel: schedule.Name}
+	_, err := d.clientProvider.MogeniusClientSet().MogeniusV1alpha1.CreateWorkspaceBackup(schedule.Namespace, name, labels, schedule.Spec.Template)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", err
+	}
+	return name, nil
+}
+
+// pruneScheduledBackups deletes the oldest finished backups of the schedule
+// beyond spec.keepLast. Running backups are never touched.
+func (d *reconcilerModule) pruneScheduledBackups(schedule *v1alpha1.BackupSchedule) error {
+	backups, err := store.GetAllWorkspaceBackups(schedule.Namespace)
+	if err != nil {
+		return err
+	}
+	keepLast := schedule.Spec.KeepLast
+	if keepLast < 1 {
+		keepLast = defaultBackupScheduleKeepLast
+	}
+
+	for _, name := range scheduledBackupsToPrune(backups, schedule.Name, keepLast) {
+		if err := d.clientProvider.MogeniusClientSet().MogeniusV1alpha1.DeleteWorkspaceBackup(schedule.Namespace, name); err != nil {
+			return fmt.Errorf("delete WorkspaceBackup %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func scheduledBackupsToPrune(backups []v1alpha1.WorkspaceBackup, scheduleName string, keepLast int) []string {
+	finished := []v1alpha1.WorkspaceBackup{}
+	for _, workspaceBackup := range backups {
+		if workspaceBackup.Labels[v1alpha1.BackupScheduleLabel] != scheduleName {
+			continue
+		}
+		switch workspaceBackup.Status.Phase {
+		case v1alpha1.WorkspaceBackupPhaseCompleted, v1alpha1.WorkspaceBackupPhaseFailed:
+			finished = append(finished, workspaceBackup)
+		}
+	}
+	if len(finished) <= keepLast {
+		return nil
+	}
+
+	// newest first
+	slices.SortFunc(finished, func(a, b v1alpha1.WorkspaceBackup) int {
+		return b.CreationTimestamp.Compare(a.CreationTimestamp.Time)
+	})
+	names := []string{}
+	for _, workspaceBackup := range finished[keepLast:] {
+		names = append(names, workspaceBackup.Name)
+	}
+	return names
+}
+
+func equalBackupScheduleStatus(a *v1alpha1.BackupScheduleStatus, b *v1alpha1.BackupScheduleStatus) bool {
+	timeEqual := func(x, y *metav1.Time) bool {
+		if x == nil || y == nil {
+			return x == y
+		}
+		return x.Unix() == y.Unix()
+	}
+	if a.LastBackup != b.LastBackup || !timeEqual(a.LastScheduleTime, b.LastScheduleTime) || !timeEqual(a.NextScheduleTime, b.NextScheduleTime) {
+		return false
+	}
+	if len(a.Conditions) != len(b.Conditions) {
+		return false
+	}
+	for i := range a.Conditions {
+		x, y := a.Conditions[i], b.Conditions[i]
+		if x.Type != y.Type || x.Status != y.Status || x.Reason != y.Reason || x.Message != y.Message || x.ObservedGeneration != y.ObservedGeneration {
+			return false
+		}
+	}
+	return true
+}
+
+// armBackupScheduleTimer requeues the schedule when its next run is due,
+// replacing a timer armed by an earlier reconcile.
+func (d *reconcilerModule) armBackupScheduleTimer(key string, next time.Time) {
+	d.backupScheduleTimersMu.Lock()
+	defer d.backupScheduleTimersMu.Unlock()
+
+	if timer, ok := d.backupScheduleTimers[key]; ok {
+		timer.Stop()
+	}
+	d.backupScheduleTimers[key] = time.AfterFunc(time.Until(next), func() {
+		d.requeue(utils.BackupScheduleResource, func(obj *unstructured.Unstructured) bool {
+			return obj.GetNamespace()+"/"+obj.GetName() == key
+		})
+	})
+}
+
+func (d *reconcilerModule) stopBackupScheduleTimer(key string) {
+	d.backupScheduleTimersMu.Lock()
+	defer d.backupScheduleTimersMu.Unlock()
+
+	if timer, ok := d.backupScheduleTimers[key]; ok {
+		timer.Stop()
+		delete(d.backupScheduleTimers, key)
+	}
+}
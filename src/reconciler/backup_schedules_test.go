@@ -0,0 +1,59 @@
+package reconciler
+
+import (
+	"mogenius-operator/src/crds/v1alpha1"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNextBackupScheduleTime(t *testing.T) {
+	schedule, err := cron.ParseStandard("0 2 * * *")
+	require.NoError(t, err)
+	created := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	// never ran: first run after creation, already overdue
+	assert.Equal(t, time.Date(2026, 3, 2, 2, 0, 0, 0, time.UTC), nextBackupScheduleTime(schedule, created, nil, now))
+
+	last := &metav1.Time{Time: time.Date(2026, 3, 3, 2, 0, 0, 0, time.UTC)}
+	assert.Equal(t, time.Date(2026, 3, 4, 2, 0, 0, 0, time.UTC), nextBackupScheduleTime(schedule, created, last, now))
+}
+
+func TestScheduledBackupsToPrune(t *testing.T) {
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	backup := func(name string, schedule string, phase v1alpha1.WorkspaceBackupPhase, age int) v1alpha1.WorkspaceBackup {
+		return v1alpha1.WorkspaceBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Labels:            map[string]string{v1alpha1.BackupScheduleLabel: schedule},
+				CreationTimestamp: metav1.Time{Time: base.Add(-time.Duration(age) * time.Hour)},
+			},
+			Status: v1alpha1.WorkspaceBackupStatus{Phase: phase},
+		}
+	}
+	backups := []v1alpha1.WorkspaceBackup{
+		backup("nightly-1", "nightly", v1alpha1.WorkspaceBackupPhaseCompleted, 1),
+		backup("nightly-4", "nightly", v1alpha1.WorkspaceBackupPhaseCompleted, 4),
+		backup("nightly-2", "nightly", v1alpha1.WorkspaceBackupPhaseFailed, 2),
+		backup("nightly-3", "nightly", v1alpha1.WorkspaceBackupPhaseCompleted, 3),
+		backup("nightly-5", "nightly", v1alpha1.WorkspaceBackupPhaseRunning, 5),
+		backup("weekly-9", "weekly", v1alpha1.WorkspaceBackupPhaseCompleted, 9),
+	}
+
+	assert.Equal(t, []string{"nightly-3", "nightly-4"}, scheduledBackupsToPrune(backups, "nightly", 2))
+	assert.Nil(t, scheduledBackupsToPrune(backups, "nightly", 4))
+}
+
+func TestVolumeSnapshotName(t *testing.T) {
+	assert.Equal(t, "nightly-data", volumeSnapshotName("nightly", "data"))
+
+	long := volumeSnapshotName(strings.Repeat("b", 200), strings.Repeat("c", 100))
+	assert.Len(t, long, 253)
+	assert.NotEqual(t, long, volumeSnapshotName(strings.Repeat("b", 200), strings.Repeat("c", 101)))
+}
@@ -7,6 +7,7 @@ import (
 	"mogenius-operator/src/k8sclient"
 	"mogenius-operator/src/utils"
 	"mogenius-operator/src/valkeyclient"
+	"sync"
 	"time"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -21,10 +22,20 @@ type reconcilerModule struct {
 	crdChecker     *crdChecker
 	// aiManager enqueues agent runs when a run-request annotation appears.
 	aiManager ai.AiManager
+	// workspaceUtilization adds live CPU/memory use to the workspace quota
+	// status. Optional.
+	workspaceUtilization WorkspaceUtilizationProvider
 
 	// requeue re-reconciles cached objects matching the predicate; wired in
 	// Build because the reconciler owning the object caches is created there.
 	requeue func(resource utils.ResourceDescriptor, match func(*unstructured.Unstructured) bool)
+
+	// runningBackups holds the keys of WorkspaceBackups being exported, so a
+	// resync during a long export does not start a second one.
+	runningBackups sync.Map
+	// backupScheduleTimers requeue each BackupSchedule when its next run is due.
+	backupScheduleTimersMu sync.Mutex
+	backupScheduleTimers   map[string]*time.Timer
 }
 
 type reconcilerFactory struct {
@@ -37,7 +48,7 @@ type ReconcilerFactory interface {
 	Build() Reconciler
 }
 
-func NewReconcilerFactory(logger *slog.Logger, clientProvider k8sclient.K8sClientProvider, configModule config.ConfigModule, valkeyClient valkeyclient.ValkeyClient, aiManager ai.AiManager) ReconcilerFactory {
+func NewReconcilerFactory(logger *slog.Logger, clientProvider k8sclient.K8sClientProvider, configModule config.ConfigModule, valkeyClient valkeyclient.ValkeyClient, aiManager ai.AiManager, workspaceUtilization WorkspaceUtilizationProvider) ReconcilerFactory {
 	factory := &reconcilerFactory{
 		module: &reconcilerModule{
 			logger:         logger,
@@ -46,6 +57,9 @@ func NewReconcilerFactory(logger *slog.Logger, clientProvider k8sclient.K8sClien
 			valkeyClient:   valkeyClient,
 			crdChecker:     newCRDChecker(clientProvider),
 			aiManager:      aiManager,
+
+			workspaceUtilization: workspaceUtilization,
+			backupScheduleTimers: map[string]*time.Timer{},
 		},
 		// Background full-sweep interval. Watcher informers already do a
 		// 30-minute resync (utils.ResourceResyncTime) which redelivers every
@@ -60,11 +74,15 @@ func NewReconcilerFactory(logger *slog.Logger, clientProvider k8sclient.K8sClien
 
 	factory.WithReconciler(utils.WorkspaceResource, factory.module.reconcileWorkspaces, NamespaceFilter(ownNamespace))
 	factory.WithReconciler(utils.WorkspaceDashboardResource, factory.module.reconcileWorkspaceDashboards, NamespaceFilter(ownNamespace))
+	factory.WithReconciler(utils.WorkspaceBackupResource, factory.module.reconcileWorkspaceBackups, NamespaceFilter(ownNamespace))
+	factory.WithReconciler(utils.BackupScheduleResource, factory.module.reconcileBackupSchedules, NamespaceFilter(ownNamespace))
 	// Cluster-scoped, hence no namespace filter.
 	factory.WithReconciler(utils.UIConfigResource, factory.module.reconcileUIConfigs)
 	factory.WithReconciler(utils.AgentResource, factory.module.reconcileAgents, NamespaceFilter(ownNamespace))
 	factory.WithReconciler(utils.AiModelResource, factory.module.reconcileAiModels, NamespaceFilter(ownNamespace))
 	factory.WithReconciler(utils.McpServerResource, factory.module.reconcileMcpServers, NamespaceFilter(ownNamespace))
+	factory.WithReconciler(utils.NotificationChannelResource, factory.module.reconcileNotificationChannels, NamespaceFilter(ownNamespace))
+	factory.WithReconciler(utils.PolicyResource, factory.module.reconcilePolicies, NamespaceFilter(ownNamespace))
 
 	// TODO: Remove gaurd when platform config is ready, and add other platform components as needed.
 	// Gated together with the platformconfigs CRD (see kubernetes.InitOrUpdateCrds).
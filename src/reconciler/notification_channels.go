@@ -0,0 +1,60 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/notifications"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/utils"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// reconcileNotificationChannels reports through the "Ready" condition whether
+// a channel can deliver. Deliveries resolve the channel again each time, so
+// the condition is informational only.
+func (d *reconcilerModule) reconcileNotificationChannels(ctx context.Context, obj *unstructured.Unstructured, op operation) []ReconcileResult {
+	if op == deleteOperation {
+		return nil
+	}
+
+	var channel v1alpha1.NotificationChannel
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &channel); err != nil {
+		return []ReconcileResult{{Err: fmt.Errorf("failed to parse NotificationChannel: %w", err)}}
+	}
+	results := []ReconcileResult{}
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.NotificationChannelConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Valid",
+		Message:            "spec is valid",
+		ObservedGeneration: channel.Generation,
+	}
+	_, err := notifications.ResolveChannel(channel, func(name string) (*corev1.Secret, error) {
+		// Store cache first, then a direct API read, as deliveries do.
+		if secret := store.GetSecret(channel.Namespace, name); secret != nil {
+			return secret, nil
+		}
+		return d.clientProvider.K8sClientSet().CoreV1().Secrets(channel.Namespace).Get(ctx, name, metav1.GetOptions{})
+	})
+	switch {
+	case err != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "InvalidSpec"
+		condition.Message = err.Error()
+		results = append(results, ReconcileResult{Err: fmt.Errorf("notification channel %q is not ready: %w", channel.Name, err), IsWarning: true})
+	case channel.Spec.Disabled:
+		condition.Reason = "Disabled"
+		condition.Message = "spec is valid; deliveries are disabled"
+	}
+
+	if err := d.setStatusConditions(ctx, utils.NotificationChannelResource, channel.Namespace, channel.Name, channel.Status.Conditions, condition); err != nil {
+		results = append(results, ReconcileResult{Err: fmt.Errorf("failed to update NotificationChannel status: %w", err), IsWarning: true})
+	}
+	return results
+}
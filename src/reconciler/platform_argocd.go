@@ -52,7 +52,7 @@ func (d *reconcilerModule) reconcileArgoCD(ctx context.Context, spec v1alpha1.Pl
 			for _, repo := range spec.GitOps.Repositories {
 				name := repo.Name
 				if name == "" {
-					name = repositorySecretName(repo.URL)
+					name = gitops.RepositorySecretName(repo.URL)
 				}
 
 				if repo.ExternalSecret != nil {
@@ -0,0 +1,52 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/policy"
+	"mogenius-operator/src/utils"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// reconcilePolicies reports through the "Ready" condition whether every
+// expression of a policy compiles. Policies are compiled again by the
+// evaluator, so the condition is informational only.
+func (d *reconcilerModule) reconcilePolicies(ctx context.Context, obj *unstructured.Unstructured, op operation) []ReconcileResult {
+	if op == deleteOperation {
+		return nil
+	}
+
+	var pol v1alpha1.Policy
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pol); err != nil {
+		return []ReconcileResult{{Err: fmt.Errorf("failed to parse Policy: %w", err)}}
+	}
+	results := []ReconcileResult{}
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.PolicyConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Valid",
+		Message:            "all expressions compile",
+		ObservedGeneration: pol.Generation,
+	}
+	_, err := policy.Compile(pol.Spec)
+	switch {
+	case err != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "InvalidExpression"
+		condition.Message = err.Error()
+		results = append(results, ReconcileResult{Err: fmt.Errorf("policy %q is not ready: %w", pol.Name, err), IsWarning: true})
+	case pol.Spec.Disabled:
+		condition.Reason = "Disabled"
+		condition.Message = "all expressions compile; the policy is disabled"
+	}
+
+	if err := d.setStatusConditions(ctx, utils.PolicyResource, pol.Namespace, pol.Name, pol.Status.Conditions, condition); err != nil {
+		results = append(results, ReconcileResult{Err: fmt.Errorf("failed to update Policy status: %w", err), IsWarning: true})
+	}
+	return results
+}
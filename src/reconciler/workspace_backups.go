@@ -0,0 +1,295 @@
+package reconciler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mogenius-operator/src/backup"
+	"mogenius-operator/src/crds/v1alpha1"
+	"mogenius-operator/src/kubernetes"
+	"mogenius-operator/src/store"
+	"mogenius-operator/src/utils"
+	"mogenius-operator/src/version"
+	"slices"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var volumeSnapshotGVR = schema.GroupVersionResource{
+	Group:    "snapshot.storage.k8s.io",
+	Version:  "v1",
+	Resource: "volumesnapshots",
+}
+
+// workspaceBackupLabel marks the VolumeSnapshots taken for a WorkspaceBackup.
+const workspaceBackupLabel = "mogenius.com/workspace-backup"
+
+// reconcileWorkspaceBackups runs every WorkspaceBackup exactly once. Finished
+// backups are left alone; deleting one removes its archive and snapshots.
+func (d *reconcilerModule) reconcileWorkspaceBackups(ctx context.Context, obj *unstructured.Unstructured, op operation) []ReconcileResult {
+	var workspaceBackup v1alpha1.WorkspaceBackup
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &workspaceBackup); err != nil {
+		return []ReconcileResult{{Err: fmt.Errorf("failed to parse WorkspaceBackup: %w", err)}}
+	}
+
+	if op == deleteOperation {
+		if err := d.deleteWorkspaceBackupData(ctx, &workspaceBackup); err != nil {
+			return []ReconcileResult{{Err: fmt.Errorf("failed to remove data of deleted WorkspaceBackup %q: %w", workspaceBackup.Name, err), IsWarning: true}}
+		}
+		return nil
+	}
+
+	switch workspaceBackup.Status.Phase {
+	case v1alpha1.WorkspaceBackupPhaseCompleted, v1alpha1.WorkspaceBackupPhaseFailed:
+		return nil
+	}
+
+	// A long backup can still be running when the background sweep comes
+	// around; a Running phase without an entry here means the operator was
+	// restarted mid-backup and the backup starts over.
+	key := workspaceBackup.Namespace + "/" + workspaceBackup.Name
+	if _, running := d.runningBackups.LoadOrStore(key, struct{}{}); running {
+		return nil
+	}
+	defer d.runningBackups.Delete(key)
+
+	return d.runWorkspaceBackup(ctx, &workspaceBackup)
+}
+
+func (d *reconcilerModule) runWorkspaceBackup(ctx context.Context, workspaceBackup *v1alpha1.WorkspaceBackup) []ReconcileResult {
+	client := d.clientProvider.MogeniusClientSet().MogeniusV1alpha1
+
+	workspaceBackup.Status = v1alpha1.WorkspaceBackupStatus{
+		Conditions: workspaceBackup.Status.Conditions,
+		Phase:      v1alpha1.WorkspaceBackupPhaseRunning,
+		StartedAt:  &metav1.Time{Time: time.Now()},
+	}
+	apimeta.SetStatusCondition(&workspaceBackup.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.WorkspaceBackupConditionCompleted,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Running",
+		Message:            "Backup is running",
+		ObservedGeneration: workspaceBackup.Generation,
+	})
+	updated, err := client.UpdateWorkspaceBackupStatus(workspaceBackup)
+	if err != nil {
+		return []ReconcileResult{{Err: fmt.Errorf("failed to update status of WorkspaceBackup %q: %w", workspaceBackup.Name, err)}}
+	}
+	workspaceBackup = updated
+
+	var results []ReconcileResult
+	condition := metav1.Condition{
+		Type:               v1alpha1.WorkspaceBackupConditionCompleted,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Completed",
+		ObservedGeneration: workspaceBackup.Generation,
+	}
+	location, err := d.exportWorkspaceBackup(ctx, workspaceBackup)
+	if err != nil {
+		workspaceBackup.Status.Phase = v1alpha1.WorkspaceBackupPhaseFailed
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Failed"
+		condition.Message = err.Error()
+		results = append(results, ReconcileResult{Err: fmt.Errorf("WorkspaceBackup %q failed: %w", workspaceBackup.Name, err)})
+	} else {
+		workspaceBackup.Status.Phase = v1alpha1.WorkspaceBackupPhaseCompleted
+		condition.Message = fmt.Sprintf("Backed up %d resources and %d volumes to %s", workspaceBackup.Status.ResourceCount, len(workspaceBackup.Status.VolumeSnapshots), location)
+	}
+	workspaceBackup.Status.CompletedAt = &metav1.Time{Time: time.Now()}
+	apimeta.SetStatusCondition(&workspaceBackup.Status.Conditions, condition)
+
+	if _, err := client.UpdateWorkspaceBackupStatus(workspaceBackup); err != nil {
+		results = append(results, ReconcileResult{Err: fmt.Errorf("failed to update status of WorkspaceBackup %q: %w", workspaceBackup.Name, err)})
+	}
+	return results
+}
+
+// exportWorkspaceBackup snapshots the volumes, writes the archive and records
+// the outcome in the status. It returns the location of the archive.
+func (d *reconcilerModule) exportWorkspaceBackup(ctx context.Context, workspaceBackup *v1alpha1.WorkspaceBackup) (string, error) {
+	workspace, err := store.GetWorkspace(workspaceBackup.Namespace, workspaceBackup.Spec.Workspace)
+	if err != nil {
+		return "", fmt.Errorf("look up Workspace %q: %w", workspaceBackup.Spec.Workspace, err)
+	}
+	if workspace == nil {
+		return "", fmt.Errorf("Workspace %q does not exist", workspaceBackup.Spec.Workspace)
+	}
+	namespaces := workspaceQuotaNamespaces(workspace.Spec)
+	if len(namespaces) == 0 {
+		return "", fmt.Errorf("Workspace %q has no namespaces", workspace.Name)
+	}
+
+	storage, err := backup.NewStorage(ctx, d.clientProvider.K8sClientSet(), workspaceBackup.Namespace, workspaceBackup.Spec.Storage, d.config.Get("MO_BACKUP_PATH"))
+	if err != nil {
+		return "", err
+	}
+
+	resources, claims, err := d.collectWorkspaceBackupResources(namespaces, workspaceBackup.Spec.ExcludedKinds)
+	if err != nil {
+		return "", err
+	}
+
+	var snapshots []v1alpha1.BackupVolumeSnapshot
+	if workspaceBackup.Spec.SnapshotVolumes {
+		snapshots, err = d.snapshotWorkspaceVolumes(ctx, workspaceBackup, claims)
+		// keep what was created so deleting the backup cleans it up
+		workspaceBackup.Status.VolumeSnapshots = snapshots
+		if err != nil {
+			return "", err
+		}
+	}
+
+	now := time.Now().UTC()
+	var archive bytes.Buffer
+	manifest := backup.Manifest{
+		Backup:          workspaceBackup.Name,
+		Workspace:       workspace.Name,
+		CreatedAt:       now,
+		OperatorVersion: version.Ver,
+		Namespaces:      namespaces,
+		VolumeSnapshots: snapshots,
+	}
+	if err := backup.WriteArchive(&archive, manifest, resources); err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("%s/%s-%s.tar.gz", workspace.Name, workspaceBackup.Name, now.Format("20060102T150405Z"))
+	if err := storage.Put(ctx, key, archive.Bytes()); err != nil {
+		return "", err
+	}
+
+	workspaceBackup.Status.Archive = key
+	workspaceBackup.Status.FormatVersion = backup.FormatVersion
+	workspaceBackup.Status.SizeBytes = int64(archive.Len())
+	workspaceBackup.Status.Namespaces = namespaces
+	workspaceBackup.Status.ResourceCount = len(resources)
+	return storage.Describe(key), nil
+}
+
+// collectWorkspaceBackupResources reads every exportable resource of the
+// namespaces from the store and returns them sorted, together with the bound
+// PersistentVolumeClaims as "<namespace>/<name>".
+func (d *reconcilerModule) collectWorkspaceBackupResources(namespaces []string, excludedKinds []string) ([]backup.Resource, []string, error) {
+	available, err := kubernetes.GetAvailableResources()
+	if err != nil {
+		return nil, nil, fmt.Errorf("discover resource kinds: %w", err)
+	}
+	descriptors := map[string]utils.ResourceDescriptor{}
+	namespaced := []utils.ResourceDescriptor{}
+	for _, descriptor := range available {
+		if descriptor.Namespaced {
+			namespaced = append(namespaced, descriptor)
+			descriptors[descriptor.ApiVersion+"/"+descriptor.Kind] = descriptor
+		}
+	}
+
+	resources := []backup.Resource{}
+	claims := []string{}
+	for _, namespace := range namespaces {
+		objects, err := store.GetResourcesByNamespaceAndKinds(d.valkeyClient, namespace, namespaced)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read resources of namespace %q: %w", namespace, err)
+		}
+		for _, object := range objects {
+			if !backup.Exportable(&object, excludedKinds) {
+				continue
+			}
+			descriptor, ok := descriptors[object.GetAPIVersion()+"/"+object.GetKind()]
+			if !ok {
+				continue
+			}
+			if object.GetKind() == "PersistentVolumeClaim" {
+				if phase, _, _ := unstructured.NestedString(object.Object, "status", "phase"); phase == "Bound" {
+					claims = append(claims, namespace+"/"+object.GetName())
+				}
+			}
+			resources = append(resources, backup.Resource{Descriptor: descriptor, Object: *backup.Sanitize(&object)})
+		}
+	}
+
+	slices.SortFunc(resources, func(a, b backup.Resource) int {
+		return strings.Compare(
+			a.Object.GetNamespace()+"/"+a.Object.GetKind()+"/"+a.Object.GetName(),
+			b.Object.GetNamespace()+"/"+b.Object.GetKind()+"/"+b.Object.GetName())
+	})
+	slices.Sort(claims)
+	return resources, claims, nil
+}
+
+func (d *reconcilerModule) snapshotWorkspaceVolumes(ctx context.Context, workspaceBackup *v1alpha1.WorkspaceBackup, claims []string) ([]v1alpha1.BackupVolumeSnapshot, error) {
+	snapshots := []v1alpha1.BackupVolumeSnapshot{}
+	for _, claim := range claims {
+		namespace, name, _ := strings.Cut(claim, "/")
+		snapshot := v1alpha1.BackupVolumeSnapshot{
+			Namespace:             namespace,
+			PersistentVolumeClaim: name,
+			Name:                  volumeSnapshotName(workspaceBackup.Name, name),
+		}
+
+		spec := map[string]any{
+			"source": map[string]any{"persistentVolumeClaimName": name},
+		}
+		if class := workspaceBackup.Spec.VolumeSnapshotClassName; class != "" {
+			spec["volumeSnapshotClassName"] = class
+		}
+		object := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshot",
+			"metadata": map[string]any{
+				"name":      snapshot.Name,
+				"namespace": namespace,
+				"labels": map[string]any{
+					workspaceQuotaManagedByLabelKey: workspaceQuotaManagedByLabelValue,
+					workspaceBackupLabel:            workspaceBackup.Name,
+				},
+			},
+			"spec": spec,
+		}}
+		_, err := d.clientProvider.DynamicClient().Resource(volumeSnapshotGVR).Namespace(namespace).Create(ctx, object, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			if apierrors.IsNotFound(err) {
+				return snapshots, fmt.Errorf("snapshot PersistentVolumeClaim %s: the VolumeSnapshot API is not installed (disable spec.snapshotVolumes)", claim)
+			}
+			return snapshots, fmt.Errorf("snapshot PersistentVolumeClaim %s: %w", claim, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// volumeSnapshotName keeps snapshot names within the 253 character limit.
+func volumeSnapshotName(backupName string, claimName string) string {
+	name := backupName + "-" + claimName
+	if len(name) <= 253 {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	return name[:244] + "-" + hex.EncodeToString(sum[:])[:8]
+}
+
+func (d *reconcilerModule) deleteWorkspaceBackupData(ctx context.Context, workspaceBackup *v1alpha1.WorkspaceBackup) error {
+	for _, snapshot := range workspaceBackup.Status.VolumeSnapshots {
+		err := d.clientProvider.DynamicClient().Resource(volumeSnapshotGVR).Namespace(snapshot.Namespace).Delete(ctx, snapshot.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete VolumeSnapshot %s/%s: %w", snapshot.Namespace, snapshot.Name, err)
+		}
+	}
+
+	if workspaceBackup.Status.Archive == "" {
+		return nil
+	}
+	storage, err := backup.NewStorage(ctx, d.clientProvider.K8sClientSet(), workspaceBackup.Namespace, workspaceBackup.Spec.Storage, d.config.Get("MO_BACKUP_PATH"))
+	if err != nil {
+		return err
+	}
+	return storage.Delete(ctx, workspaceBackup.Status.Archive)
+}
@@ -0,0 +1,423 @@
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"mogenius-operator/src/crds/v1alpha1"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	workspaceQuotaManagedByLabelKey   = "app.kubernetes.io/managed-by"
+	workspaceQuotaManagedByLabelValue = "mogenius-operator"
+	workspaceQuotaWorkspaceLabelKey   = "mogenius.com/workspace"
+)
+
+// WorkspaceUtilization is what the workloads of a workspace consume right now,
+// as opposed to what they request.
+type WorkspaceUtilization struct {
+	CpuMillicores float64
+	MemoryBytes   float64
+}
+
+// WorkspaceUtilizationProvider reads the current utilization of a workspace
+// from the collected pod stats. The reconciler only reports it next to the
+// quota usage, so a nil provider or a failing lookup is not an error.
+type WorkspaceUtilizationProvider interface {
+	GetWorkspaceUtilization(workspaceName string) (WorkspaceUtilization, error)
+}
+
+// workspaceQuotaObjectName is the name of the ResourceQuota and LimitRange the
+// operator owns for a workspace in each of its namespaces.
+func workspaceQuotaObjectName(workspaceName string) string {
+	return "mogenius-workspace-" + workspaceName
+}
+
+// workspaceQuotaNamespaces lists the namespaces a workspace quota applies to.
+// ArgoCD and Flux resources point at the namespace of their custom resource,
+// not at the one the workloads run in, so they are left out.
+func workspaceQuotaNamespaces(spec v1alpha1.WorkspaceSpec) []string {
+	namespaces := []string{}
+	for _, resource := range spec.Resources {
+		switch resource.Type {
+		case "namespace":
+			if resource.Id != "" {
+				namespaces = append(namespaces, resource.Id)
+			}
+		case "helm":
+			if resource.Namespace != "" {
+				namespaces = append(namespaces, resource.Namespace)
+			}
+		}
+	}
+	slices.Sort(namespaces)
+	return slices.Compact(namespaces)
+}
+
+// workspaceQuotaHard converts the workspace quota into the `hard` limits of a
+// ResourceQuota.
+func workspaceQuotaHard(quota *v1alpha1.WorkspaceQuota) corev1.ResourceList {
+	hard := corev1.ResourceList{}
+	if quota == nil {
+		return hard
+	}
+	if quota.CPU != nil {
+		hard[corev1.ResourceRequestsCPU] = quota.CPU.DeepCopy()
+	}
+	if quota.Memory != nil {
+		hard[corev1.ResourceRequestsMemory] = quota.Memory.DeepCopy()
+	}
+	if quota.Storage != nil {
+		hard[corev1.ResourceRequestsStorage] = quota.Storage.DeepCopy()
+	}
+	if quota.Pods != nil {
+		hard[corev1.ResourcePods] = *resource.NewQuantity(*quota.Pods, resource.DecimalSI)
+	}
+	if quota.LoadBalancers != nil {
+		hard[corev1.ResourceServicesLoadBalancers] = *resource.NewQuantity(*quota.LoadBalancers, resource.DecimalSI)
+	}
+	return hard
+}
+
+// splitWorkspaceQuota divides the workspace limits across its namespaces so
+// that the per-namespace quotas never add up to more than the workspace
+// budget. CPU is split in millicores, everything else in whole units; the
+// remainder goes to the first namespaces.
+func splitWorkspaceQuota(hard corev1.ResourceList, namespaces []string) map[string]corev1.ResourceList {
+	shares := make(map[string]corev1.ResourceList, len(namespaces))
+	for _, namespace := range namespaces {
+		shares[namespace] = corev1.ResourceList{}
+	}
+	count := int64(len(namespaces))
+	if count == 0 {
+		return shares
+	}
+
+	for name, total := range hard {
+		milli := name == corev1.ResourceRequestsCPU
+		value := total.Value()
+		if milli {
+			value = total.MilliValue()
+		}
+		base, remainder := value/count, value%count
+		for i, namespace := range namespaces {
+			share := base
+			if int64(i) < remainder {
+				share++
+			}
+			if milli {
+				shares[namespace][name] = *resource.NewMilliQuantity(share, resource.DecimalSI)
+			} else {
+				shares[namespace][name] = *resource.NewQuantity(share, total.Format)
+			}
+		}
+	}
+	return shares
+}
+
+// workspaceLimitRange returns the container defaults for a workspace quota, or
+// nil when neither CPU nor memory is capped and no defaults are configured.
+func workspaceLimitRange(quota *v1alpha1.WorkspaceQuota) *corev1.LimitRangeItem {
+	if quota == nil {
+		return nil
+	}
+	defaultRequest := corev1.ResourceList{}
+	if quota.CPU != nil {
+		defaultRequest[corev1.ResourceCPU] = resource.MustParse("100m")
+	}
+	if quota.Memory != nil {
+		defaultRequest[corev1.ResourceMemory] = resource.MustParse("128Mi")
+	}
+	// the API server rejects a LimitRange whose default request is above its
+	// default limit
+	for name, request := range defaultRequest {
+		if limit, ok := quota.DefaultLimit[name]; ok && limit.Cmp(request) < 0 {
+			defaultRequest[name] = limit.DeepCopy()
+		}
+	}
+	maps.Copy(defaultRequest, quota.DefaultRequest)
+	if len(defaultRequest) == 0 && len(quota.DefaultLimit) == 0 {
+		return nil
+	}
+
+	item := &corev1.LimitRangeItem{
+		Type:           corev1.LimitTypeContainer,
+		DefaultRequest: defaultRequest,
+	}
+	if len(quota.DefaultLimit) > 0 {
+		item.Default = quota.DefaultLimit.DeepCopy()
+	}
+	return item
+}
+
+func workspaceQuotaLabels(workspaceName string) map[string]string {
+	return map[string]string{
+		workspaceQuotaManagedByLabelKey: workspaceQuotaManagedByLabelValue,
+		workspaceQuotaWorkspaceLabelKey: workspaceName,
+	}
+}
+
+// reconcileWorkspaceQuota makes the ResourceQuota and LimitRange of every
+// namespace match spec.quota, removes the ones of namespaces that left the
+// workspace and reports the result as QuotaApplied and QuotaWithinBudget
+// conditions.
+func (d *reconcilerModule) reconcileWorkspaceQuota(ctx context.Context, workspace *v1alpha1.Workspace) ([]ReconcileResult, []metav1.Condition) {
+	appliedCondition := metav1.Condition{
+		Type:               v1alpha1.WorkspaceConditionQuotaApplied,
+		Status:             metav1.ConditionTrue,
+		Reason:             "NoQuota",
+		Message:            "Workspace defines no quota",
+		ObservedGeneration: workspace.Generation,
+	}
+	budgetCondition := metav1.Condition{
+		Type:               v1alpha1.WorkspaceConditionQuotaWithinBudget,
+		Status:             metav1.ConditionTrue,
+		Reason:             "NoQuota",
+		Message:            "Workspace defines no quota",
+		ObservedGeneration: workspace.Generation,
+	}
+
+	namespaces := []string{}
+	hard := workspaceQuotaHard(workspace.Spec.Quota)
+	limitRange := workspaceLimitRange(workspace.Spec.Quota)
+	if len(hard) > 0 || limitRange != nil {
+		namespaces = workspaceQuotaNamespaces(workspace.Spec)
+	}
+
+	results := []ReconcileResult{}
+	if err := d.deleteStaleWorkspaceQuotas(ctx, workspace.Name, namespaces); err != nil {
+		results = append(results, ReconcileResult{Err: fmt.Errorf("failed to remove stale quotas of workspace %q: %w", workspace.Name, err), IsWarning: true})
+	}
+	if len(hard) == 0 && limitRange == nil {
+		return results, []metav1.Condition{appliedCondition, budgetCondition}
+	}
+	if len(namespaces) == 0 {
+		appliedCondition.Reason = "NoNamespaces"
+		appliedCondition.Message = "Workspace has no namespace the quota could be applied to"
+		budgetCondition.Reason = "NoNamespaces"
+		budgetCondition.Message = appliedCondition.Message
+		return results, []metav1.Condition{appliedCondition, budgetCondition}
+	}
+
+	shares := splitWorkspaceQuota(hard, namespaces)
+	quotas := []corev1.ResourceQuota{}
+	failures := []string{}
+	for _, namespace := range namespaces {
+		quota, err := d.applyWorkspaceQuota(ctx, workspace.Name, namespace, shares[namespace], limitRange)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", namespace, err))
+			continue
+		}
+		if quota != nil {
+			quotas = append(quotas, *quota)
+		}
+	}
+
+	if len(failures) > 0 {
+		appliedCondition.Status = metav1.ConditionFalse
+		appliedCondition.Reason = "ApplyFailed"
+		appliedCondition.Message = strings.Join(failures, "; ")
+		results = append(results, ReconcileResult{Err: fmt.Errorf("failed to apply quota of workspace %q: %s", workspace.Name, appliedCondition.Message)})
+	} else {
+		appliedCondition.Reason = "Applied"
+		appliedCondition.Message = fmt.Sprintf("Quota applied to %d namespace(s): %s", len(namespaces), strings.Join(namespaces, ", "))
+	}
+
+	var utilization *WorkspaceUtilization
+	if d.workspaceUtilization != nil {
+		current, err := d.workspaceUtilization.GetWorkspaceUtilization(workspace.Name)
+		if err != nil {
+			d.logger.Debug("failed to read workspace utilization", "workspace", workspace.Name, "error", err)
+		} else {
+			utilization = &current
+		}
+	}
+	budgetCondition.Status, budgetCondition.Reason, budgetCondition.Message = workspaceBudget(hard, quotas, utilization)
+
+	return results, []metav1.Condition{appliedCondition, budgetCondition}
+}
+
+// applyWorkspaceQuota creates or updates the ResourceQuota and LimitRange of
+// one namespace and returns the current ResourceQuota including its usage.
+// Objects with the same name that the operator did not create are refused,
+// never overwritten.
+func (d *reconcilerModule) applyWorkspaceQuota(ctx context.Context, workspaceName string, namespace string, hard corev1.ResourceList, limitRange *corev1.LimitRangeItem) (*corev1.ResourceQuota, error) {
+	name := workspaceQuotaObjectName(workspaceName)
+	client := d.clientProvider.K8sClientSet().CoreV1()
+
+	var result *corev1.ResourceQuota
+	if len(hard) > 0 {
+		quotas := client.ResourceQuotas(namespace)
+		current, err := quotas.Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			result, err = quotas.Create(ctx, &corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: workspaceQuotaLabels(workspaceName)},
+				Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("create ResourceQuota: %w", err)
+			}
+		case err != nil:
+			return nil, fmt.Errorf("get ResourceQuota: %w", err)
+		case current.Labels[workspaceQuotaManagedByLabelKey] != workspaceQuotaManagedByLabelValue:
+			return nil, fmt.Errorf("ResourceQuota %q exists and is not managed by the operator", name)
+		case !resourceListsEqual(current.Spec.Hard, hard):
+			current.Spec.Hard = hard
+			result, err = quotas.Update(ctx, current, metav1.UpdateOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("update ResourceQuota: %w", err)
+			}
+		default:
+			result = current
+		}
+	} else if err := client.ResourceQuotas(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("delete ResourceQuota: %w", err)
+	}
+
+	limitRanges := client.LimitRanges(namespace)
+	if limitRange == nil {
+		if err := limitRanges.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("delete LimitRange: %w", err)
+		}
+		return result, nil
+	}
+	desired := corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{*limitRange}}
+	current, err := limitRanges.Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		_, err = limitRanges.Create(ctx, &corev1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: workspaceQuotaLabels(workspaceName)},
+			Spec:       desired,
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("create LimitRange: %w", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("get LimitRange: %w", err)
+	case current.Labels[workspaceQuotaManagedByLabelKey] != workspaceQuotaManagedByLabelValue:
+		return nil, fmt.Errorf("LimitRange %q exists and is not managed by the operator", name)
+	case len(current.Spec.Limits) != 1 ||
+		!resourceListsEqual(current.Spec.Limits[0].DefaultRequest, limitRange.DefaultRequest) ||
+		!resourceListsEqual(current.Spec.Limits[0].Default, limitRange.Default):
+		current.Spec = desired
+		if _, err := limitRanges.Update(ctx, current, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("update LimitRange: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// deleteStaleWorkspaceQuotas removes the quota objects of a workspace from
+// every namespace not in keep. An empty keep removes all of them, which is
+// what happens when the quota or the whole workspace is deleted.
+func (d *reconcilerModule) deleteStaleWorkspaceQuotas(ctx context.Context, workspaceName string, keep []string) error {
+	client := d.clientProvider.K8sClientSet().CoreV1()
+	selector := labels.SelectorFromSet(workspaceQuotaLabels(workspaceName)).String()
+
+	quotas, err := client.ResourceQuotas(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("list ResourceQuotas: %w", err)
+	}
+	for _, quota := range quotas.Items {
+		if slices.Contains(keep, quota.Namespace) {
+			continue
+		}
+		if err := client.ResourceQuotas(quota.Namespace).Delete(ctx, quota.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete ResourceQuota %s/%s: %w", quota.Namespace, quota.Name, err)
+		}
+	}
+
+	limitRanges, err := client.LimitRanges(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("list LimitRanges: %w", err)
+	}
+	for _, limitRange := range limitRanges.Items {
+		if slices.Contains(keep, limitRange.Namespace) {
+			continue
+		}
+		if err := client.LimitRanges(limitRange.Namespace).Delete(ctx, limitRange.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("delete LimitRange %s/%s: %w", limitRange.Namespace, limitRange.Name, err)
+		}
+	}
+	return nil
+}
+
+// workspaceBudget sums the usage of all namespace quotas and compares it with
+// the workspace limits. The message lists every limit as "used/hard" so the
+// dashboard can show the headroom without reading the quotas itself.
+func workspaceBudget(hard corev1.ResourceList, quotas []corev1.ResourceQuota, utilization *WorkspaceUtilization) (metav1.ConditionStatus, string, string) {
+	if len(hard) == 0 {
+		return metav1.ConditionTrue, "NoLimits", "Quota only sets container defaults"
+	}
+
+	used := corev1.ResourceList{}
+	reported := false
+	for _, quota := range quotas {
+		if quota.Status.Used != nil {
+			reported = true
+		}
+		for name, quantity := range quota.Status.Used {
+			sum := used[name]
+			sum.Add(quantity)
+			used[name] = sum
+		}
+	}
+
+	names := slices.Sorted(maps.Keys(hard))
+	parts := make([]string, 0, len(names))
+	exhausted := []string{}
+	for _, name := range names {
+		limit := hard[name]
+		usage := used[name]
+		part := fmt.Sprintf("%s %s/%s", name, usage.String(), limit.String())
+		if limit.Sign() > 0 {
+			part += fmt.Sprintf(" (%.0f%%)", usage.AsApproximateFloat64()*100/limit.AsApproximateFloat64())
+		}
+		if utilization != nil {
+			switch name {
+			case corev1.ResourceRequestsCPU:
+				part += fmt.Sprintf(", %s in use", resource.NewMilliQuantity(int64(utilization.CpuMillicores), resource.DecimalSI).String())
+			case corev1.ResourceRequestsMemory:
+				part += fmt.Sprintf(", %s in use", resource.NewQuantity(int64(utilization.MemoryBytes), resource.BinarySI).String())
+			}
+		}
+		parts = append(parts, part)
+		// A limit of 0 forbids the resource outright; that is a policy, not an
+		// exhausted budget.
+		if usage.Cmp(limit) > 0 || (limit.Sign() > 0 && usage.Cmp(limit) == 0) {
+			exhausted = append(exhausted, string(name))
+		}
+	}
+	message := strings.Join(parts, "; ")
+
+	switch {
+	case !reported:
+		return metav1.ConditionUnknown, "UsagePending", "Kubernetes has not reported quota usage yet; limits: " + message
+	case len(exhausted) > 0:
+		return metav1.ConditionFalse, "BudgetExhausted", fmt.Sprintf("Exhausted: %s; %s", strings.Join(exhausted, ", "), message)
+	default:
+		return metav1.ConditionTrue, "WithinBudget", message
+	}
+}
+
+func resourceListsEqual(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, quantity := range a {
+		other, ok := b[name]
+		if !ok || quantity.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
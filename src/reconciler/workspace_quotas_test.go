@@ -0,0 +1,112 @@
+package reconciler
+
+import (
+	"mogenius-operator/src/crds/v1alpha1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWorkspaceQuotaNamespaces(t *testing.T) {
+	spec := v1alpha1.NewWorkspaceSpec("Shop", []v1alpha1.WorkspaceResourceIdentifier{
+		{Id: "shop-prod", Type: "namespace"},
+		{Id: "redis", Type: "helm", Namespace: "shop-prod"},
+		{Id: "shop-dev", Type: "namespace"},
+		{Id: "Kustomization/shop", Type: "flux", Namespace: "flux-system"},
+		{Id: "shop", Type: "argocd", Namespace: "argocd"},
+	}, "")
+
+	assert.Equal(t, []string{"shop-dev", "shop-prod"}, workspaceQuotaNamespaces(spec))
+}
+
+func TestSplitWorkspaceQuota(t *testing.T) {
+	pods := int64(5)
+	loadBalancers := int64(1)
+	hard := workspaceQuotaHard(&v1alpha1.WorkspaceQuota{
+		CPU:           new(resource.MustParse("1")),
+		Memory:        new(resource.MustParse("3Gi")),
+		Pods:          &pods,
+		LoadBalancers: &loadBalancers,
+	})
+
+	shares := splitWorkspaceQuota(hard, []string{"a", "b", "c"})
+
+	cpuA := shares["a"][corev1.ResourceRequestsCPU]
+	cpuC := shares["c"][corev1.ResourceRequestsCPU]
+	assert.Equal(t, "334m", cpuA.String())
+	assert.Equal(t, "333m", cpuC.String())
+
+	memoryB := shares["b"][corev1.ResourceRequestsMemory]
+	assert.Equal(t, "1Gi", memoryB.String())
+
+	podsA := shares["a"][corev1.ResourcePods]
+	podsC := shares["c"][corev1.ResourcePods]
+	assert.Equal(t, int64(2), podsA.Value())
+	assert.Equal(t, int64(1), podsC.Value())
+
+	// the single load balancer goes to the first namespace, not to all three
+	lbA := shares["a"][corev1.ResourceServicesLoadBalancers]
+	lbB := shares["b"][corev1.ResourceServicesLoadBalancers]
+	assert.Equal(t, int64(1), lbA.Value())
+	assert.Equal(t, int64(0), lbB.Value())
+}
+
+func TestWorkspaceLimitRange(t *testing.T) {
+	assert.Nil(t, workspaceLimitRange(nil))
+
+	pods := int64(10)
+	assert.Nil(t, workspaceLimitRange(&v1alpha1.WorkspaceQuota{Pods: &pods}))
+
+	item := workspaceLimitRange(&v1alpha1.WorkspaceQuota{
+		CPU:            new(resource.MustParse("2")),
+		DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m")},
+	})
+	if assert.NotNil(t, item) {
+		cpu := item.DefaultRequest[corev1.ResourceCPU]
+		assert.Equal(t, "50m", cpu.String())
+		assert.Nil(t, item.Default)
+	}
+
+	// the fallback requests never exceed the default limits
+	item = workspaceLimitRange(&v1alpha1.WorkspaceQuota{
+		CPU:          new(resource.MustParse("2")),
+		Memory:       new(resource.MustParse("4Gi")),
+		DefaultLimit: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+	})
+	if assert.NotNil(t, item) {
+		cpu := item.DefaultRequest[corev1.ResourceCPU]
+		memory := item.DefaultRequest[corev1.ResourceMemory]
+		assert.Equal(t, "50m", cpu.String())
+		assert.Equal(t, "128Mi", memory.String())
+	}
+}
+
+func TestWorkspaceBudget(t *testing.T) {
+	hard := corev1.ResourceList{
+		corev1.ResourceRequestsCPU: resource.MustParse("2"),
+		corev1.ResourcePods:        resource.MustParse("4"),
+	}
+	quotaWithUsage := func(cpu string, pods string) corev1.ResourceQuota {
+		return corev1.ResourceQuota{Status: corev1.ResourceQuotaStatus{Used: corev1.ResourceList{
+			corev1.ResourceRequestsCPU: resource.MustParse(cpu),
+			corev1.ResourcePods:        resource.MustParse(pods),
+		}}}
+	}
+
+	status, reason, _ := workspaceBudget(hard, []corev1.ResourceQuota{{}}, nil)
+	assert.Equal(t, metav1.ConditionUnknown, status)
+	assert.Equal(t, "UsagePending", reason)
+
+	status, reason, message := workspaceBudget(hard, []corev1.ResourceQuota{quotaWithUsage("500m", "1"), quotaWithUsage("250m", "1")}, &WorkspaceUtilization{CpuMillicores: 120})
+	assert.Equal(t, metav1.ConditionTrue, status)
+	assert.Equal(t, "WithinBudget", reason)
+	assert.Equal(t, "pods 2/4 (50%); requests.cpu 750m/2 (38%), 120m in use", message)
+
+	status, reason, message = workspaceBudget(hard, []corev1.ResourceQuota{quotaWithUsage("1", "2"), quotaWithUsage("500m", "2")}, nil)
+	assert.Equal(t, metav1.ConditionFalse, status)
+	assert.Equal(t, "BudgetExhausted", reason)
+	assert.Contains(t, message, "Exhausted: pods")
+}
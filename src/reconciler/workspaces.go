@@ -18,6 +18,8 @@ func (d *reconcilerModule) reconcileWorkspaces(ctx context.Context, obj *unstruc
 
 	if op != deleteOperation {
 		results = append(results, d.verifyWorkspaceIntegrity(ctx, obj)...)
+	} else if err := d.deleteStaleWorkspaceQuotas(ctx, obj.GetName(), nil); err != nil {
+		results = append(results, ReconcileResult{Err: fmt.Errorf("failed to remove quotas of deleted workspace %q: %w", obj.GetName(), err), IsWarning: true})
 	}
 	return results
 }
@@ -137,7 +139,11 @@ func (d *reconcilerModule) verifyWorkspaceIntegrity(ctx context.Context, obj *un
 		resourcesCondition.Message = strings.Join(messages, "; ")
 	}
 
-	if err := d.setStatusConditions(ctx, utils.WorkspaceResource, workspace.Namespace, workspace.Name, workspace.Status.Conditions, resourcesCondition, dashboardRefCondition); err != nil {
+	quotaResults, quotaConditions := d.reconcileWorkspaceQuota(ctx, &workspace)
+	results = append(results, quotaResults...)
+
+	conditions := append([]metav1.Condition{resourcesCondition, dashboardRefCondition}, quotaConditions...)
+	if err := d.setStatusConditions(ctx, utils.WorkspaceResource, workspace.Namespace, workspace.Name, workspace.Status.Conditions, conditions...); err != nil {
 		results = append(results, ReconcileResult{Err: fmt.Errorf("failed to update Workspace status: %w", err), IsWarning: true})
 	}
 	return results
@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"encoding"
 	"fmt"
 	"mogenius-operator/src/assert"
 	"reflect"
@@ -45,6 +46,10 @@ type TypeInfo struct {
 	// Type == Map
 	KeyType   *TypeInfo `json:"keyType,omitempty"`
 	ValueType *TypeInfo `json:"valueType,omitempty"`
+
+	// The type brings its own JSON decoding (json.Unmarshaler or
+	// encoding.TextUnmarshaler), so its wire format is not described by Type.
+	CustomJson bool `json:"customJson,omitempty"`
 }
 
 func (self *TypeInfo) StructLayout(schema *Schema) (*StructLayout, error) {
@@ -117,6 +122,11 @@ func (self *Schema) Yaml() string {
 	return string(bytes)
 }
 
+var (
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
 func Generate(input any) *Schema {
 	schema := &Schema{}
 	schema.StructLayouts = map[string]StructLayout{}
@@ -156,6 +166,10 @@ func parseSchema(schema *Schema, input reflect.Type, depth int) (*TypeInfo, erro
 		typeInfo.Pointer = true
 	}
 
+	if reflect.PointerTo(input).Implements(jsonUnmarshalerType) || reflect.PointerTo(input).Implements(textUnmarshalerType) {
+		typeInfo.CustomJson = true
+	}
+
 	switch input.Kind() {
 	case reflect.Bool:
 		typeInfo.Type = SchemaTypeBoolean
@@ -4,6 +4,7 @@ import (
 	"mogenius-operator/src/assert"
 	"mogenius-operator/src/schema"
 	"testing"
+	"time"
 )
 
 func TestString(t *testing.T) {
@@ -205,3 +206,64 @@ func TestLookupAnonStruct(t *testing.T) {
 	assert.AssertT(t, layout.IsAnonymous(), "layout should know it is anonymous")
 	assert.AssertT(t, len(layout.Properties) == 1, "a single property should be found")
 }
+
+func TestValidateAcceptsMatchingPayload(t *testing.T) {
+	type Item struct {
+		Name     string `json:"name"`
+		Replicas int    `json:"replicas"`
+	}
+	s, err := schema.TryGenerate(struct {
+		Namespace string            `json:"namespace"`
+		Items     []Item            `json:"items"`
+		Labels    map[string]string `json:"labels"`
+		Data      []byte            `json:"data"`
+		Extra     any               `json:"extra"`
+	}{})
+	assert.AssertT(t, err == nil, err)
+
+	err = s.Validate([]byte(`{"namespace":"default","items":[{"name":"a","replicas":3}],"labels":{"app":"x"},"data":"aGVsbG8=","extra":[1,"two"],"unknown":true}`))
+	assert.AssertT(t, err == nil, err)
+
+	err = s.Validate([]byte(`{"Namespace":"default","items":null}`))
+	assert.AssertT(t, err == nil, err)
+
+	err = s.Validate(nil)
+	assert.AssertT(t, err == nil, err)
+}
+
+func TestValidateRejectsTypeMismatch(t *testing.T) {
+	type Item struct {
+		Name     string `json:"name"`
+		Replicas uint   `json:"replicas"`
+	}
+	s, err := schema.TryGenerate(struct {
+		Items []Item `json:"items"`
+	}{})
+	assert.AssertT(t, err == nil, err)
+
+	err = s.Validate([]byte(`{"items":[{"name":"a"},{"name":5}]}`))
+	assert.AssertT(t, err != nil)
+	assert.AssertT(t, err.Error() == "payload.items[1].name: expected string, got number", err)
+
+	err = s.Validate([]byte(`{"items":[{"replicas":1.5}]}`))
+	assert.AssertT(t, err != nil)
+
+	err = s.Validate([]byte(`{"items":[{"replicas":-1}]}`))
+	assert.AssertT(t, err != nil)
+
+	err = s.Validate([]byte(`{"items":{}}`))
+	assert.AssertT(t, err != nil)
+
+	err = s.Validate([]byte(`{"items":`))
+	assert.AssertT(t, err != nil)
+}
+
+func TestValidateSkipsCustomJsonTypes(t *testing.T) {
+	s, err := schema.TryGenerate(struct {
+		CreatedAt time.Time `json:"createdAt"`
+	}{})
+	assert.AssertT(t, err == nil, err)
+
+	err = s.Validate([]byte(`{"createdAt":"2024-01-01T00:00:00Z"}`))
+	assert.AssertT(t, err == nil, err)
+}
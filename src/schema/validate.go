@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"encoding/json"
+)
+
+// Validate checks a raw JSON document against the schema before it is decoded
+// into the typed request. It mirrors what encoding/json would accept: unknown
+// object keys are ignored, keys match case-insensitively, `null` is valid
+// everywhere and types with their own JSON decoding are not inspected. The
+// point is a readable error with a path ("payload.items[2].name: ...") instead
+// of the decoder's first complaint.
+func (self *Schema) Validate(data []byte) error {
+	if self == nil || self.TypeInfo == nil {
+		return nil
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("payload: invalid json: %s", err.Error())
+	}
+
+	return self.validateValue("payload", self.TypeInfo, value)
+}
+
+func (self *Schema) validateValue(path string, typeInfo *TypeInfo, value any) error {
+	if typeInfo == nil || value == nil || typeInfo.CustomJson {
+		return nil
+	}
+
+	switch typeInfo.Type {
+	case SchemaTypeAny, SchemaTypeFunction:
+		return nil
+	case SchemaTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return typeMismatch(path, "boolean", value)
+		}
+	case SchemaTypeString:
+		if _, ok := value.(string); !ok {
+			return typeMismatch(path, "string", value)
+		}
+	case SchemaTypeFloat:
+		if _, ok := value.(json.Number); !ok {
+			return typeMismatch(path, "number", value)
+		}
+	case SchemaTypeInteger, SchemaTypeUnsignedInteger:
+		number, ok := value.(json.Number)
+		if !ok {
+			return typeMismatch(path, "integer", value)
+		}
+		f, err := number.Float64()
+		if err != nil || f != math.Trunc(f) {
+			return fmt.Errorf("%s: expected integer, got %s", path, number.String())
+		}
+		if typeInfo.Type == SchemaTypeUnsignedInteger && f < 0 {
+			return fmt.Errorf("%s: expected unsigned integer, got %s", path, number.String())
+		}
+	case SchemaTypeArray:
+		// []byte travels as a base64 string
+		if _, ok := value.(string); ok && typeInfo.ElementType != nil && typeInfo.ElementType.Type == SchemaTypeUnsignedInteger {
+			return nil
+		}
+		items, ok := value.([]any)
+		if !ok {
+			return typeMismatch(path, "array", value)
+		}
+		for i, item := range items {
+			if err := self.validateValue(fmt.Sprintf("%s[%d]", path, i), typeInfo.ElementType, item); err != nil {
+				return err
+			}
+		}
+	case SchemaTypeMap:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return typeMismatch(path, "object", value)
+		}
+		for key, item := range object {
+			if err := self.validateValue(path+"."+key, typeInfo.ValueType, item); err != nil {
+				return err
+			}
+		}
+	case SchemaTypeStruct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return typeMismatch(path, "object", value)
+		}
+		layout, err := typeInfo.StructLayout(self)
+		if err != nil {
+			return nil
+		}
+		for key, item := range object {
+			property := layout.property(key)
+			if property == nil {
+				continue
+			}
+			if err := self.validateValue(path+"."+key, property, item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// property resolves a JSON key the way encoding/json does: exact match first,
+// then a case-insensitive one.
+func (self *StructLayout) property(key string) *TypeInfo {
+	if property, ok := self.Properties[key]; ok {
+		return property
+	}
+	for name, property := range self.Properties {
+		if strings.EqualFold(name, key) {
+			return property
+		}
+	}
+	return nil
+}
+
+func typeMismatch(path string, expected string, value any) error {
+	return fmt.Errorf("%s: expected %s, got %s", path, expected, jsonKind(value))
+}
+
+func jsonKind(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,85 @@
+package security
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Image is a container image running in the cluster with the workloads
+// using it and the known vulnerabilities of its packages.
+type Image struct {
+	// Key identifies the image: its digest, or the image reference when the
+	// runtime reported no digest.
+	Key    string `json:"key"`
+	Image  string `json:"image"`
+	Digest string `json:"digest,omitempty"`
+	// Workloads are the controllers (or bare pods) running the image.
+	Workloads []ImageWorkload `json:"workloads"`
+	// Sbom is the SBOM the packages come from; images without SBOM are not
+	// scanned.
+	Sbom     string `json:"sbom,omitempty"`
+	Packages int    `json:"packages"`
+	// Summary counts the vulnerabilities per severity.
+	Summary         map[string]int       `json:"summary"`
+	Severity        string               `json:"severity,omitempty"`
+	Vulnerabilities []ImageVulnerability `json:"vulnerabilities,omitempty"`
+	FirstSeenAt     time.Time            `json:"firstSeenAt"`
+	ScannedAt       time.Time            `json:"scannedAt"`
+}
+
+type ImageWorkload struct {
+	Namespace  string   `json:"namespace"`
+	Kind       string   `json:"kind"`
+	Name       string   `json:"name"`
+	Containers []string `json:"containers"`
+}
+
+// ImageVulnerability is an advisory affecting a package of an image.
+type ImageVulnerability struct {
+	Id           string    `json:"id"`
+	Aliases      []string  `json:"aliases,omitempty"`
+	Summary      string    `json:"summary,omitempty"`
+	Severity     string    `json:"severity"`
+	Ecosystem    string    `json:"ecosystem"`
+	Package      string    `json:"package"`
+	Version      string    `json:"version"`
+	FixedVersion string    `json:"fixedVersion,omitempty"`
+	DetectedAt   time.Time `json:"detectedAt"`
+}
+
+// Namespaces returns the sorted namespaces the image runs in.
+func (self Image) Namespaces() []string {
+	namespaces := []string{}
+	for _, workload := range self.Workloads {
+		namespaces = append(namespaces, workload.Namespace)
+	}
+	slices.Sort(namespaces)
+	return slices.Compact(namespaces)
+}
+
+// Summarize sets Summary and Severity from the vulnerabilities.
+func (self *Image) Summarize() {
+	self.Summary = map[string]int{}
+	self.Severity = ""
+	for _, vulnerability := range self.Vulnerabilities {
+		self.Summary[vulnerability.Severity]++
+		if SeverityRank(vulnerability.Severity) > SeverityRank(self.Severity) {
+			self.Severity = vulnerability.Severity
+		}
+	}
+}
+
+// SortVulnerabilities orders the vulnerabilities worst first, then by id and
+// package.
+func SortVulnerabilities(vulnerabilities []ImageVulnerability) {
+	slices.SortFunc(vulnerabilities, func(a, b ImageVulnerability) int {
+		if rank := SeverityRank(b.Severity) - SeverityRank(a.Severity); rank != 0 {
+			return rank
+		}
+		if a.Id != b.Id {
+			return strings.Compare(a.Id, b.Id)
+		}
+		return strings.Compare(a.Package, b.Package)
+	})
+}
@@ -0,0 +1,159 @@
+package security
+
+import "strings"
+
+// SARIF 2.1.0, reduced to the fields the findings fill in.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifTool    = "mogenius-operator"
+	sarifToolUri = "https://mogenius.com"
+)
+
+type SarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []SarifRun `json:"runs"`
+}
+
+type SarifRun struct {
+	Tool    SarifTool     `json:"tool"`
+	Results []SarifResult `json:"results"`
+}
+
+type SarifTool struct {
+	Driver SarifDriver `json:"driver"`
+}
+
+type SarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []SarifRule `json:"rules"`
+}
+
+type SarifRule struct {
+	Id                   string                 `json:"id"`
+	Name                 string                 `json:"name"`
+	ShortDescription     SarifMessage           `json:"shortDescription"`
+	FullDescription      SarifMessage           `json:"fullDescription"`
+	Help                 SarifMessage           `json:"help"`
+	DefaultConfiguration SarifRuleConfiguration `json:"defaultConfiguration"`
+	Properties           SarifRuleProperties    `json:"properties"`
+}
+
+type SarifRuleConfiguration struct {
+	Level string `json:"level"`
+}
+
+type SarifRuleProperties struct {
+	// SecuritySeverity is the CVSS-like score code scanning UIs sort by.
+	SecuritySeverity string   `json:"security-severity"`
+	Tags             []string `json:"tags"`
+}
+
+type SarifMessage struct {
+	Text string `json:"text"`
+}
+
+type SarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   SarifMessage    `json:"message"`
+	Locations []SarifLocation `json:"locations"`
+}
+
+type SarifLocation struct {
+	PhysicalLocation SarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []SarifLogicalLocation `json:"logicalLocations"`
+}
+
+type SarifPhysicalLocation struct {
+	ArtifactLocation SarifArtifactLocation `json:"artifactLocation"`
+}
+
+type SarifArtifactLocation struct {
+	Uri string `json:"uri"`
+}
+
+type SarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevel maps a severity to a SARIF level and a security-severity score.
+func sarifLevel(severity string) (string, string) {
+	switch severity {
+	case SeverityCritical:
+		return "error", "9.0"
+	case SeverityHigh:
+		return "error", "7.5"
+	case SeverityMedium:
+		return "warning", "5.0"
+	}
+	return "note", "2.0"
+}
+
+// Sarif renders the findings as a SARIF log with one result per finding.
+// Resources have no source file, so each result points to the resource path
+// "<namespace>/<kind>/<name>" ("_cluster" for cluster-scoped resources).
+func Sarif(resources []ResourceFindings, toolVersion string) SarifLog {
+	rules := make([]SarifRule, 0, len(Rules))
+	ruleIndex := map[string]int{}
+	for i, rule := range Rules {
+		level, score := sarifLevel(rule.Severity)
+		rules = append(rules, SarifRule{
+			Id:                   rule.Id,
+			Name:                 rule.Title,
+			ShortDescription:     SarifMessage{Text: rule.Title},
+			FullDescription:      SarifMessage{Text: rule.Description},
+			Help:                 SarifMessage{Text: rule.Remediation},
+			DefaultConfiguration: SarifRuleConfiguration{Level: level},
+			Properties:           SarifRuleProperties{SecuritySeverity: score, Tags: []string{"security", "kubernetes"}},
+		})
+		ruleIndex[rule.Id] = i
+	}
+
+	results := []SarifResult{}
+	for _, resource := range resources {
+		namespace := resource.Namespace
+		if namespace == "" {
+			namespace = "_cluster"
+		}
+		path := strings.Join([]string{namespace, resource.Kind, resource.Name}, "/")
+		for _, finding := range resource.Findings {
+			level, _ := sarifLevel(finding.Severity)
+			qualifiedName := path
+			if finding.Container != "" {
+				qualifiedName += "/" + finding.Container
+			}
+			results = append(results, SarifResult{
+				RuleId:    finding.Rule,
+				RuleIndex: ruleIndex[finding.Rule],
+				Level:     level,
+				Message:   SarifMessage{Text: finding.Message},
+				Locations: []SarifLocation{{
+					PhysicalLocation: SarifPhysicalLocation{ArtifactLocation: SarifArtifactLocation{Uri: path}},
+					LogicalLocations: []SarifLogicalLocation{{Name: resource.Name, FullyQualifiedName: qualifiedName, Kind: "resource"}},
+				}},
+			})
+		}
+	}
+
+	return SarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []SarifRun{{
+			Tool: SarifTool{Driver: SarifDriver{
+				Name:           sarifTool,
+				Version:        toolVersion,
+				InformationUri: sarifToolUri,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
@@ -0,0 +1,339 @@
+package security
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+// SeverityRank orders severities, higher is worse; unknown severities rank 0.
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 4
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 1
+	}
+	return 0
+}
+
+// Rule is one check of the scan.
+type Rule struct {
+	Id          string `json:"id"`
+	Title       string `json:"title"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	Remediation string `json:"remediation"`
+}
+
+const (
+	RulePrivilegedContainer = "privileged-container"
+	RuleRunAsNonRootMissing = "run-as-non-root-missing"
+	RuleHostNetwork         = "host-network"
+	RuleHostPid             = "host-pid"
+	RuleWritableRootFs      = "writable-root-filesystem"
+	RuleSecretEnv           = "secret-as-env"
+	RuleLoadBalancerOpen    = "loadbalancer-without-source-ranges"
+	RuleClusterAdminBinding = "cluster-admin-binding"
+	RuleImageWithoutDigest  = "image-without-digest"
+)
+
+const (
+	clusterAdminClusterRole = "cluster-admin"
+	systemMastersGroup      = "system:masters"
+	imageDigestSeparator    = "@sha256:"
+)
+
+// Rules lists every check of the scan, worst severity first.
+var Rules = []Rule{
+	{
+		Id:          RulePrivilegedContainer,
+		Title:       "Privileged container",
+		Severity:    SeverityCritical,
+		Description: "The container runs privileged and has full access to the host.",
+		Remediation: "Remove securityContext.privileged or set it to false; grant single capabilities instead.",
+	},
+	{
+		Id:          RuleHostNetwork,
+		Title:       "Host network",
+		Severity:    SeverityHigh,
+		Description: "The pod shares the network namespace of the node.",
+		Remediation: "Remove spec.hostNetwork and expose the workload through a Service.",
+	},
+	{
+		Id:          RuleHostPid,
+		Title:       "Host PID namespace",
+		Severity:    SeverityHigh,
+		Description: "The pod shares the process namespace of the node and can see every process on it.",
+		Remediation: "Remove spec.hostPID.",
+	},
+	{
+		Id:          RuleClusterAdminBinding,
+		Title:       "Binding to cluster-admin",
+		Severity:    SeverityHigh,
+		Description: "The binding grants the cluster-admin ClusterRole, i.e. unrestricted access.",
+		Remediation: "Bind a role limited to the resources the subjects need.",
+	},
+	{
+		Id:          RuleRunAsNonRootMissing,
+		Title:       "runAsNonRoot not set",
+		Severity:    SeverityMedium,
+		Description: "Neither the pod nor the container sets securityContext.runAsNonRoot, so the container may run as root.",
+		Remediation: "Set securityContext.runAsNonRoot: true on the pod or the container.",
+	},
+	{
+		Id:          RuleLoadBalancerOpen,
+		Title:       "LoadBalancer without source ranges",
+		Severity:    SeverityMedium,
+		Description: "The Service of type LoadBalancer accepts traffic from any address.",
+		Remediation: "Set spec.loadBalancerSourceRanges to the networks that need access.",
+	},
+	{
+		Id:          RuleSecretEnv,
+		Title:       "Secret exposed as environment variable",
+		Severity:    SeverityLow,
+		Description: "A Secret is passed as environment variable, where it leaks into crash dumps, child processes and logs.",
+		Remediation: "Mount the Secret as a volume and read it from a file.",
+	},
+	{
+		Id:          RuleWritableRootFs,
+		Title:       "Writable root filesystem",
+		Severity:    SeverityLow,
+		Description: "The container can write to its root filesystem.",
+		Remediation: "Set securityContext.readOnlyRootFilesystem: true and mount emptyDir volumes where writes are needed.",
+	},
+	{
+		Id:          RuleImageWithoutDigest,
+		Title:       "Image without digest",
+		Severity:    SeverityLow,
+		Description: "The image is referenced by tag only, so the running content can change without the manifest changing.",
+		Remediation: "Pin the image by digest (image@sha256:...).",
+	},
+}
+
+// RuleById returns the rule of id.
+func RuleById(id string) (Rule, bool) {
+	index := slices.IndexFunc(Rules, func(rule Rule) bool { return rule.Id == id })
+	if index < 0 {
+		return Rule{}, false
+	}
+	return Rules[index], true
+}
+
+// Finding is one failed rule of a resource.
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	// Container the finding applies to, if any.
+	Container string `json:"container,omitempty"`
+}
+
+// ResourceFindings are the findings of one resource from its last scan.
+type ResourceFindings struct {
+	Uid        string    `json:"uid"`
+	ApiVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	Findings   []Finding `json:"findings"`
+	// Severity is the worst severity of the findings.
+	Severity   string    `json:"severity"`
+	DetectedAt time.Time `json:"detectedAt"`
+	ScannedAt  time.Time `json:"scannedAt"`
+}
+
+// ScannedKind is a kind the scan reads from the store.
+type ScannedKind struct {
+	ApiVersion string
+	Kind       string
+}
+
+var ScannedKinds = []ScannedKind{
+	{"v1", "Pod"},
+	{"apps/v1", "Deployment"},
+	{"apps/v1", "StatefulSet"},
+	{"apps/v1", "DaemonSet"},
+	{"apps/v1", "ReplicaSet"},
+	{"batch/v1", "Job"},
+	{"batch/v1", "CronJob"},
+	{"v1", "Service"},
+	{"rbac.authorization.k8s.io/v1", "ClusterRoleBinding"},
+	{"rbac.authorization.k8s.io/v1", "RoleBinding"},
+}
+
+// Scan checks one resource and returns its findings, worst first. Pods,
+// ReplicaSets and Jobs created by a controller are skipped, their owner is
+// reported instead.
+func Scan(obj *unstructured.Unstructured) []Finding {
+	findings := []Finding{}
+	switch obj.GetKind() {
+	case "Pod", "ReplicaSet", "Job":
+		if len(obj.GetOwnerReferences()) > 0 {
+			return findings
+		}
+		findings = scanPodSpec(obj)
+	case "Deployment", "StatefulSet", "DaemonSet", "CronJob":
+		findings = scanPodSpec(obj)
+	case "Service":
+		findings = scanService(obj)
+	case "ClusterRoleBinding", "RoleBinding":
+		findings = scanBinding(obj)
+	}
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return SeverityRank(b.Severity) - SeverityRank(a.Severity)
+	})
+	return findings
+}
+
+// WorstSeverity returns the highest severity of findings, "" for none.
+func WorstSeverity(findings []Finding) string {
+	worst := ""
+	for _, finding := range findings {
+		if SeverityRank(finding.Severity) > SeverityRank(worst) {
+			worst = finding.Severity
+		}
+	}
+	return worst
+}
+
+func newFinding(ruleId string, container string, message string) Finding {
+	rule, _ := RuleById(ruleId)
+	return Finding{Rule: rule.Id, Severity: rule.Severity, Title: rule.Title, Message: message, Container: container}
+}
+
+// podSpecPath returns where the pod spec of a workload kind lives.
+func podSpecPath(kind string) []string {
+	switch kind {
+	case "Pod":
+		return []string{"spec"}
+	case "CronJob":
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	}
+	return []string{"spec", "template", "spec"}
+}
+
+func scanPodSpec(obj *unstructured.Unstructured) []Finding {
+	podSpec, found, _ := unstructured.NestedMap(obj.Object, podSpecPath(obj.GetKind())...)
+	if !found {
+		return []Finding{}
+	}
+	findings := []Finding{}
+	if hostNetwork, _, _ := unstructured.NestedBool(podSpec, "hostNetwork"); hostNetwork {
+		findings = append(findings, newFinding(RuleHostNetwork, "", "spec.hostNetwork is true"))
+	}
+	if hostPid, _, _ := unstructured.NestedBool(podSpec, "hostPID"); hostPid {
+		findings = append(findings, newFinding(RuleHostPid, "", "spec.hostPID is true"))
+	}
+	podRunAsNonRoot, _, _ := unstructured.NestedBool(podSpec, "securityContext", "runAsNonRoot")
+
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(podSpec, field)
+		for _, item := range containers {
+			container, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(container, "name")
+
+			if privileged, _, _ := unstructured.NestedBool(container, "securityContext", "privileged"); privileged {
+				findings = append(findings, newFinding(RulePrivilegedContainer, name, fmt.Sprintf("container %q runs privileged", name)))
+			}
+			runAsNonRoot, set, _ := unstructured.NestedBool(container, "securityContext", "runAsNonRoot")
+			if (set && !runAsNonRoot) || (!set && !podRunAsNonRoot) {
+				findings = append(findings, newFinding(RuleRunAsNonRootMissing, name, fmt.Sprintf("container %q may run as root", name)))
+			}
+			if readOnly, _, _ := unstructured.NestedBool(container, "securityContext", "readOnlyRootFilesystem"); !readOnly {
+				findings = append(findings, newFinding(RuleWritableRootFs, name, fmt.Sprintf("container %q has a writable root filesystem", name)))
+			}
+			if secrets := envSecrets(container); len(secrets) > 0 {
+				findings = append(findings, newFinding(RuleSecretEnv, name, fmt.Sprintf("container %q reads Secret %s from the environment", name, strings.Join(secrets, ", "))))
+			}
+			if image, _, _ := unstructured.NestedString(container, "image"); image != "" && !strings.Contains(image, imageDigestSeparator) {
+				findings = append(findings, newFinding(RuleImageWithoutDigest, name, fmt.Sprintf("container %q uses image %q without digest", name, image)))
+			}
+		}
+	}
+	return findings
+}
+
+// envSecrets lists the Secrets a container reads through env or envFrom.
+func envSecrets(container map[string]any) []string {
+	secrets := []string{}
+	env, _, _ := unstructured.NestedSlice(container, "env")
+	for _, item := range env {
+		if variable, ok := item.(map[string]any); ok {
+			if name, found, _ := unstructured.NestedString(variable, "valueFrom", "secretKeyRef", "name"); found {
+				secrets = append(secrets, fmt.Sprintf("%q", name))
+			}
+		}
+	}
+	envFrom, _, _ := unstructured.NestedSlice(container, "envFrom")
+	for _, item := range envFrom {
+		if source, ok := item.(map[string]any); ok {
+			if name, found, _ := unstructured.NestedString(source, "secretRef", "name"); found {
+				secrets = append(secrets, fmt.Sprintf("%q", name))
+			}
+		}
+	}
+	slices.Sort(secrets)
+	return slices.Compact(secrets)
+}
+
+func scanService(obj *unstructured.Unstructured) []Finding {
+	serviceType, _, _ := unstructured.NestedString(obj.Object, "spec", "type")
+	if serviceType != "LoadBalancer" {
+		return []Finding{}
+	}
+	ranges, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "loadBalancerSourceRanges")
+	if len(ranges) > 0 || obj.GetAnnotations()["service.beta.kubernetes.io/load-balancer-source-ranges"] != "" {
+		return []Finding{}
+	}
+	return []Finding{newFinding(RuleLoadBalancerOpen, "", "spec.loadBalancerSourceRanges is empty")}
+}
+
+// scanBinding reports bindings of cluster-admin. The bootstrap binding to
+// the system:masters group is expected and not reported.
+func scanBinding(obj *unstructured.Unstructured) []Finding {
+	roleKind, _, _ := unstructured.NestedString(obj.Object, "roleRef", "kind")
+	roleName, _, _ := unstructured.NestedString(obj.Object, "roleRef", "name")
+	if roleKind != "ClusterRole" || roleName != clusterAdminClusterRole {
+		return []Finding{}
+	}
+	subjects, _, _ := unstructured.NestedSlice(obj.Object, "subjects")
+	names := []string{}
+	for _, item := range subjects {
+		subject, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(subject, "kind")
+		name, _, _ := unstructured.NestedString(subject, "name")
+		if kind == "Group" && name == systemMastersGroup {
+			continue
+		}
+		if namespace, _, _ := unstructured.NestedString(subject, "namespace"); namespace != "" {
+			name = namespace + "/" + name
+		}
+		names = append(names, kind+" "+name)
+	}
+	if len(names) == 0 {
+		return []Finding{}
+	}
+	return []Finding{newFinding(RuleClusterAdminBinding, "", "cluster-admin is bound to "+strings.Join(names, ", "))}
+}
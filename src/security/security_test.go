@@ -0,0 +1,186 @@
+package security
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func testResource(t *testing.T, manifest string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	require.NoError(t, yaml.Unmarshal([]byte(manifest), &obj.Object))
+	return obj
+}
+
+func findingRules(findings []Finding) []string {
+	rules := []string{}
+	for _, finding := range findings {
+		rules = append(rules, finding.Rule)
+	}
+	return rules
+}
+
+func TestScanWorkload(t *testing.T) {
+	findings := Scan(testResource(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  namespace: shop
+spec:
+  template:
+    spec:
+      hostNetwork: true
+      containers:
+      - name: api
+        image: registry.example.com/api:1.2
+        securityContext:
+          privileged: true
+        env:
+        - name: PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: db
+              key: password
+        envFrom:
+        - secretRef:
+            name: api
+      - name: sidecar
+        image: registry.example.com/proxy@sha256:0123456789abcdef
+        securityContext:
+          runAsNonRoot: true
+          readOnlyRootFilesystem: true
+`))
+	assert.Equal(t, []string{
+		RulePrivilegedContainer,
+		RuleHostNetwork,
+		RuleRunAsNonRootMissing,
+		RuleWritableRootFs,
+		RuleSecretEnv,
+		RuleImageWithoutDigest,
+	}, findingRules(findings), "worst first, the hardened sidecar has none")
+	assert.Equal(t, SeverityCritical, WorstSeverity(findings))
+	assert.Equal(t, "api", findings[0].Container)
+	assert.Equal(t, `container "api" reads Secret "api", "db" from the environment`, findings[4].Message)
+
+	// the pod-level runAsNonRoot covers every container
+	findings = Scan(testResource(t, `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          hostPID: true
+          securityContext:
+            runAsNonRoot: true
+          containers:
+          - name: backup
+            image: backup@sha256:0123456789abcdef
+            securityContext:
+              readOnlyRootFilesystem: true
+`))
+	assert.Equal(t, []string{RuleHostPid}, findingRules(findings))
+
+	// pods of a controller are reported through the controller
+	assert.Empty(t, Scan(testResource(t, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: api-7d9f
+  ownerReferences:
+  - kind: ReplicaSet
+    name: api-7d9
+spec:
+  hostNetwork: true
+`)))
+}
+
+func TestScanServiceAndBindings(t *testing.T) {
+	findings := Scan(testResource(t, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: LoadBalancer
+`))
+	assert.Equal(t, []string{RuleLoadBalancerOpen}, findingRules(findings))
+
+	assert.Empty(t, Scan(testResource(t, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: LoadBalancer
+  loadBalancerSourceRanges: [10.0.0.0/8]
+`)))
+
+	findings = Scan(testResource(t, `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: ci-admin
+roleRef:
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+- kind: Group
+  name: system:masters
+- kind: ServiceAccount
+  name: ci
+  namespace: tools
+`))
+	require.Equal(t, []string{RuleClusterAdminBinding}, findingRules(findings))
+	assert.Equal(t, "cluster-admin is bound to ServiceAccount tools/ci", findings[0].Message)
+
+	// the bootstrap binding is expected
+	assert.Empty(t, Scan(testResource(t, `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: cluster-admin
+roleRef:
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+- kind: Group
+  name: system:masters
+`)))
+}
+
+func TestSarif(t *testing.T) {
+	log := Sarif([]ResourceFindings{
+		{Kind: "ClusterRoleBinding", Name: "ci-admin", Findings: []Finding{
+			{Rule: RuleClusterAdminBinding, Severity: SeverityHigh, Message: "cluster-admin is bound to ServiceAccount tools/ci"},
+		}},
+		{Kind: "Deployment", Namespace: "shop", Name: "api", Findings: []Finding{
+			{Rule: RuleImageWithoutDigest, Severity: SeverityLow, Message: "no digest", Container: "api"},
+		}},
+	}, "1.2.3")
+
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, "1.2.3", run.Tool.Driver.Version)
+	assert.Len(t, run.Tool.Driver.Rules, len(Rules))
+	require.Len(t, run.Results, 2)
+
+	assert.Equal(t, "error", run.Results[0].Level)
+	assert.Equal(t, "_cluster/ClusterRoleBinding/ci-admin", run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.Uri)
+	assert.Equal(t, RuleClusterAdminBinding, run.Tool.Driver.Rules[run.Results[0].RuleIndex].Id)
+	assert.Equal(t, "note", run.Results[1].Level)
+	assert.Equal(t, "shop/Deployment/api/api", run.Results[1].Locations[0].LogicalLocations[0].FullyQualifiedName)
+
+	data, err := json.Marshal(log)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"$schema":"https://json.schemastore.org/sarif-2.1.0.json"`)
+	assert.Contains(t, string(data), `"security-severity":"7.5"`)
+}
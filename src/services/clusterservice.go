@@ -12,9 +12,9 @@ const (
 	MogeniusHelmIndex = "https://helm.mogenius.com/public"
 )
 
-func CreateMogeniusNfsVolume(eventClient websocket.WebsocketClient, r NfsVolumeRequest) structs.DefaultResponse {
+func CreateMogeniusNfsVolume(eventClient websocket.WebsocketClient, onJobFailed func(job *structs.Job), r NfsVolumeRequest) structs.DefaultResponse {
 	var wg sync.WaitGroup
-	job := structs.CreateJob(eventClient, "Create mogenius nfs-volume.", r.NamespaceName, "", "", serviceLogger)
+	job := structs.CreateJob(eventClient, "Create mogenius nfs-volume.", r.NamespaceName, "", "", serviceLogger, onJobFailed)
 	job.Start(eventClient)
 	// FOR K8SMANAGER
 	mokubernetes.CreateMogeniusNfsServiceSync(eventClient, job, r.NamespaceName, r.VolumeName)
@@ -37,9 +37,9 @@ func CreateMogeniusNfsVolume(eventClient websocket.WebsocketClient, r NfsVolumeR
 	return job.DefaultReponse()
 }
 
-func DeleteMogeniusNfsVolume(eventClient websocket.WebsocketClient, r NfsVolumeRequest) structs.DefaultResponse {
+func DeleteMogeniusNfsVolume(eventClient websocket.WebsocketClient, onJobFailed func(job *structs.Job), r NfsVolumeRequest) structs.DefaultResponse {
 	var wg sync.WaitGroup
-	job := structs.CreateJob(eventClient, "Delete mogenius nfs-volume.", r.NamespaceName, "", "", serviceLogger)
+	job := structs.CreateJob(eventClient, "Delete mogenius nfs-volume.", r.NamespaceName, "", "", serviceLogger, onJobFailed)
 	job.Start(eventClient)
 	// FOR K8SMANAGER
 	wg.Go(func() {
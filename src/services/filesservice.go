@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"math"
@@ -72,23 +73,63 @@ func Info(r dtos.PersistentFileRequestDto) (dtos.PersistentFileDto, error) {
 	return parseStatLine("/exports", strings.TrimSpace(output))
 }
 
-func Download(pfile dtos.PersistentFileRequestDto, postTo string) (FilesDownloadResponse, error) {
+// Download archives the file or directory and posts it to postTo. Cancelling
+// ctx stops both the read from the NFS pod and the upload.
+func Download(ctx context.Context, pfile dtos.PersistentFileRequestDto, postTo string) (FilesDownloadResponse, error) {
 	result := FilesDownloadResponse{}
 
-	containerPath, err := resolveNfs(&pfile)
+	buf := new(bytes.Buffer)
+	multiPartWriter := multipart.NewWriter(buf)
+
+	filename, err := DownloadTo(ctx, pfile, func(filename string) (io.Writer, error) {
+		return multiPartWriter.CreateFormFile("file", filename)
+	})
 	if err != nil {
 		result.Error = err.Error()
 		return result, err
 	}
 
-	info, err := Info(pfile)
+	result.SizeInBytes = int64(buf.Len())
+	_ = multiPartWriter.Close()
+
+	serviceLogger.Debug("Uploading file", "size", result.SizeInBytes, "filename", filename, "postTo", postTo)
+	req, err := http.NewRequestWithContext(ctx, "POST", postTo, buf)
 	if err != nil {
 		result.Error = err.Error()
 		return result, err
 	}
+	req.Header = utils.HttpHeader("")
+	req.Header.Set("Content-Type", multiPartWriter.FormDataContentType())
 
-	buf := new(bytes.Buffer)
-	multiPartWriter := multipart.NewWriter(buf)
+	client := &http.Client{}
+	response, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		serviceLogger.Error("Error sending request", "status", response.Status)
+		result.Error = fmt.Sprintf("%s - '%s'.", postTo, response.Status)
+	}
+
+	return result, nil
+}
+
+// DownloadTo streams the file, or a tar.gz archive of the directory, into
+// the writer open returns for the name the download is offered under, and
+// returns that name.
+func DownloadTo(ctx context.Context, pfile dtos.PersistentFileRequestDto, open func(filename string) (io.Writer, error)) (string, error) {
+	containerPath, err := resolveNfs(&pfile)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := Info(pfile)
+	if err != nil {
+		return "", err
+	}
 
 	var filename string
 	if info.Type == "directory" {
@@ -97,56 +138,83 @@ func Download(pfile dtos.PersistentFileRequestDto, postTo string) (FilesDownload
 		filename = info.Name
 	}
 
-	w, err := multiPartWriter.CreateFormFile("file", filename)
+	w, err := open(filename)
 	if err != nil {
-		result.Error = err.Error()
-		return result, err
+		return "", err
 	}
 
 	if info.Type == "directory" {
 		err = mokubernetes.ExecInNfsPodToWriter(
-			pfile.VolumeNamespace, pfile.VolumeName,
+			ctx, pfile.VolumeNamespace, pfile.VolumeName,
 			[]string{"tar", "czf", "-", "-C", path.Dir(containerPath), path.Base(containerPath)},
 			nil, w,
 		)
 	} else {
 		err = mokubernetes.ExecInNfsPodToWriter(
-			pfile.VolumeNamespace, pfile.VolumeName,
+			ctx, pfile.VolumeNamespace, pfile.VolumeName,
 			[]string{"cat", containerPath},
 			nil, w,
 		)
 	}
-	if err != nil {
-		result.Error = err.Error()
-		return result, err
-	}
+	return filename, err
+}
 
-	result.SizeInBytes = int64(buf.Len())
-	_ = multiPartWriter.Close()
+// fileRangeBlockSize is the block size dd reads ranges of a file with; the
+// blocks around the range are trimmed after reading.
+const fileRangeBlockSize = 64 * 1024
 
-	serviceLogger.Debug("Uploading file", "size", result.SizeInBytes, "filename", filename, "postTo", postTo)
-	req, err := http.NewRequest("POST", postTo, buf)
+// ReadRange reads length bytes of a file starting at offset in the NFS pod,
+// so the file does not have to be copied to the operator. Fewer bytes are
+// returned at the end of the file.
+func ReadRange(ctx context.Context, pfile dtos.PersistentFileRequestDto, offset int64, length int) ([]byte, error) {
+	containerPath, err := resolveNfs(&pfile)
 	if err != nil {
-		result.Error = err.Error()
-		return result, err
+		return nil, err
+	}
+	if offset < 0 || length < 0 {
+		return nil, fmt.Errorf("offset and length must not be negative")
 	}
-	req.Header = utils.HttpHeader("")
-	req.Header.Set("Content-Type", multiPartWriter.FormDataContentType())
 
-	client := &http.Client{}
-	response, err := client.Do(req)
+	skip := offset / fileRangeBlockSize
+	count := (offset+int64(length)+fileRangeBlockSize-1)/fileRangeBlockSize - skip
+	var buf bytes.Buffer
+	err = mokubernetes.ExecInNfsPodToWriter(
+		ctx, pfile.VolumeNamespace, pfile.VolumeName,
+		[]string{"dd", "if=" + containerPath, "bs=" + strconv.Itoa(fileRangeBlockSize), "skip=" + strconv.FormatInt(skip, 10), "count=" + strconv.FormatInt(count, 10)},
+		nil, &buf,
+	)
 	if err != nil {
-		result.Error = err.Error()
-		return result, err
+		return nil, err
 	}
-	defer func() { _ = response.Body.Close() }()
 
-	if response.StatusCode < 200 || response.StatusCode > 299 {
-		serviceLogger.Error("Error sending request", "status", response.Status)
-		result.Error = fmt.Sprintf("%s - '%s'.", postTo, response.Status)
+	data := buf.Bytes()
+	start := int(offset - skip*fileRangeBlockSize)
+	if start >= len(data) {
+		return []byte{}, nil
+	}
+	data = data[start:]
+	if len(data) > length {
+		data = data[:length]
 	}
+	return data, nil
+}
 
-	return result, nil
+// Sha256 returns the hex encoded SHA-256 of a file, computed in the NFS pod.
+func Sha256(ctx context.Context, pfile dtos.PersistentFileRequestDto) (string, error) {
+	containerPath, err := resolveNfs(&pfile)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = mokubernetes.ExecInNfsPodToWriter(ctx, pfile.VolumeNamespace, pfile.VolumeName, []string{"sha256sum", containerPath}, nil, &buf)
+	if err != nil {
+		return "", err
+	}
+	sum, _, _ := strings.Cut(strings.TrimSpace(buf.String()), " ")
+	if len(sum) != 64 {
+		return "", fmt.Errorf("unexpected sha256sum output for %s", pfile.Path)
+	}
+	return sum, nil
 }
 
 func Uploaded(tempZipFileSrc string, fileReq FilesUploadRequest) error {
@@ -277,6 +345,13 @@ type FilesUploadRequest struct {
 
 // ── helpers ───────────────────────────────────────────────────────────────────
 
+// ValidatePath checks the path of a file request the way every file
+// operation does before touching the volume.
+func ValidatePath(file dtos.PersistentFileRequestDto) error {
+	_, err := resolveNfs(&file)
+	return err
+}
+
 // resolveNfs validates the request path and returns the absolute path inside the
 // NFS container (/exports/...).
 func resolveNfs(data *dtos.PersistentFileRequestDto) (string, error) {
@@ -1758,6 +1758,62 @@ func GetMcpServer(namespace string, name string) (*v1alpha1.McpServer, error) {
 	return tool, nil
 }
 
+func GetAllWorkspaceBackups(namespace string) ([]v1alpha1.WorkspaceBackup, error) {
+	backups, err := listResourcesByIndex[v1alpha1.WorkspaceBackup](
+		utils.WorkspaceBackupResource.ApiVersion, utils.WorkspaceBackupResource.Kind, namespace, "*")
+	if err != nil {
+		storeLogger().Error("failed to list workspace backups", "namespace", namespace, "error", err)
+		return nil, err
+	}
+	return backups, nil
+}
+
+func GetWorkspaceBackup(namespace string, name string) (*v1alpha1.WorkspaceBackup, error) {
+	backup, err := valkeyclient.GetObjectForKey[v1alpha1.WorkspaceBackup](valkeyClient, VALKEY_RESOURCE_PREFIX, utils.WorkspaceBackupResource.ApiVersion, utils.WorkspaceBackupResource.Kind, namespace, name)
+	if err != nil || backup == nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+func GetAllBackupSchedules(namespace string) ([]v1alpha1.BackupSchedule, error) {
+	schedules, err := listResourcesByIndex[v1alpha1.BackupSchedule](
+		utils.BackupScheduleResource.ApiVersion, utils.BackupScheduleResource.Kind, namespace, "*")
+	if err != nil {
+		storeLogger().Error("failed to list backup schedules", "namespace", namespace, "error", err)
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func GetAllNotificationChannels(namespace string) ([]v1alpha1.NotificationChannel, error) {
+	channels, err := listResourcesByIndex[v1alpha1.NotificationChannel](
+		utils.NotificationChannelResource.ApiVersion, utils.NotificationChannelResource.Kind, namespace, "*")
+	if err != nil {
+		storeLogger().Error("failed to list notification channels", "namespace", namespace, "error", err)
+		return nil, err
+	}
+	return channels, nil
+}
+
+func GetNotificationChannel(namespace string, name string) (*v1alpha1.NotificationChannel, error) {
+	channel, err := valkeyclient.GetObjectForKey[v1alpha1.NotificationChannel](valkeyClient, VALKEY_RESOURCE_PREFIX, utils.NotificationChannelResource.ApiVersion, utils.NotificationChannelResource.Kind, namespace, name)
+	if err != nil || channel == nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+func GetAllPolicies(namespace string) ([]v1alpha1.Policy, error) {
+	policies, err := listResourcesByIndex[v1alpha1.Policy](
+		utils.PolicyResource.ApiVersion, utils.PolicyResource.Kind, namespace, "*")
+	if err != nil {
+		storeLogger().Error("failed to list policies", "namespace", namespace, "error", err)
+		return nil, err
+	}
+	return policies, nil
+}
+
 func GetAllWorkspaces(namespace string) ([]v1alpha1.Workspace, error) {
 	workspaces, err := listResourcesByIndex[v1alpha1.Workspace](
 		utils.WorkspaceResource.ApiVersion, utils.WorkspaceResource.Kind, namespace, "*")
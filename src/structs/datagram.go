@@ -3,6 +3,7 @@ package structs
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"mogenius-operator/src/utils"
 	"time"
@@ -20,6 +21,43 @@ type Datagram struct {
 	User      User      `json:"user"`
 	Workspace string    `json:"workspace,omitempty"`
 	Zlib      bool      `json:"zlib,omitempty"`
+	// TimeoutMs is the deadline of the request in milliseconds, counted from
+	// its receipt. Requests that run longer are aborted; 0 waits forever.
+	TimeoutMs int64 `json:"timeoutMs,omitempty"`
+	// IdempotencyKey makes a mutating request run at most once: a resent
+	// datagram with the same key gets the response of the first.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+
+	ctx         context.Context
+	coalesceKey string
+}
+
+// Context returns the context of the request, which is cancelled when the
+// request is cancelled or reaches its deadline. Never nil.
+func (d Datagram) Context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// WithContext returns a copy of the datagram carrying ctx.
+func (d Datagram) WithContext(ctx context.Context) Datagram {
+	d.ctx = ctx
+	return d
+}
+
+// CoalesceKey identifies the job or resource whose state an event reports.
+// While the events client is disconnected only the latest queued event per
+// key is kept; events without a key are all kept.
+func (d Datagram) CoalesceKey() string {
+	return d.coalesceKey
+}
+
+// WithCoalesceKey returns a copy of the datagram carrying key.
+func (d Datagram) WithCoalesceKey(key string) Datagram {
+	d.coalesceKey = key
+	return d
 }
 
 type User struct {
@@ -45,7 +83,7 @@ func CreateDatagramNotificationFromJob(data *Job) Datagram {
 		Payload:   data,
 		CreatedAt: data.Started,
 	}
-	return datagram
+	return datagram.WithCoalesceKey("job:" + data.Id)
 }
 
 func CreateDatagramForClusterEvent(pattern, apiVersion, kind, name, eventType string, obj *unstructured.Unstructured) Datagram {
@@ -74,7 +112,7 @@ func CreateDatagramForClusterEvent(pattern, apiVersion, kind, name, eventType st
 		},
 		CreatedAt: time.Now(),
 	}
-	return datagram
+	return datagram.WithCoalesceKey(fmt.Sprintf("%s:%s/%s/%s/%s", pattern, apiVersion, kind, obj.GetNamespace(), obj.GetName()))
 }
 
 func CreateDatagramAck(pattern string, id string) Datagram {
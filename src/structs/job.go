@@ -30,9 +30,12 @@ type Job struct {
 	ContainerName  string       `json:"containerName,omitempty"`
 
 	logger *slog.Logger
+	// onFailed is called after the job finished in the failed state.
+	onFailed func(job *Job)
 }
 
-func CreateJob(eventClient websocket.WebsocketClient, title string, projectId string, namespace string, controllerName string, logger *slog.Logger) *Job {
+// CreateJob reports a new pending job. onFailed may be nil.
+func CreateJob(eventClient websocket.WebsocketClient, title string, projectId string, namespace string, controllerName string, logger *slog.Logger, onFailed func(job *Job)) *Job {
 	job := &Job{
 		Id:             utils.NanoId(),
 		ProjectId:      projectId,
@@ -45,6 +48,7 @@ func CreateJob(eventClient websocket.WebsocketClient, title string, projectId st
 		Started:        time.Now(),
 	}
 	job.logger = logger
+	job.onFailed = onFailed
 	ReportJobStateToServer(eventClient, job)
 	return job
 }
@@ -99,6 +103,9 @@ func (j *Job) Finish(eventClient websocket.WebsocketClient) {
 	j.Finished = time.Now()
 
 	ReportJobStateToServer(eventClient, j)
+	if cb := j.onFailed; cb != nil && j.State == JobStateFailed {
+		cb(j)
+	}
 }
 
 func (j *Job) AddCmd(eventClient websocket.WebsocketClient, cmd *Command) {
@@ -20,4 +20,10 @@ type PodStats struct {
 	EphemeralStorageLimit int64     `json:"ephemeralStorageLimit"`
 	StartTime             time.Time `json:"startTime"`
 	CreatedAt             time.Time `json:"createdAt"`
+	// Rollup is only set on entries read from a downsampled tier. One entry
+	// then covers one replica of the workload for one window: the usage and
+	// limit fields hold the window averages, Rollup their full summary keyed
+	// by the JSON field name ("cpu", "memory", ...) plus the replica count
+	// ("pods").
+	Rollup map[string]StatsRollup `json:"rollup,omitempty"`
 }
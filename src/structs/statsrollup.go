@@ -0,0 +1,43 @@
+package structs
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// StatsRollup summarizes the samples of one metric over one rollup window.
+type StatsRollup struct {
+	Min   float64 `json:"min"`
+	Avg   float64 `json:"avg"`
+	Max   float64 `json:"max"`
+	P95   float64 `json:"p95"`
+	Count int     `json:"count"`
+}
+
+// StatsRollupEntry summarizes one series over one window. Metrics are keyed
+// by the JSON field name of the raw entry ("cpu", "receivedBytes", ...).
+type StatsRollupEntry struct {
+	Start   time.Time              `json:"start"`
+	Metrics map[string]StatsRollup `json:"metrics"`
+}
+
+// NewStatsRollup summarizes samples; P95 uses the nearest-rank method.
+func NewStatsRollup(samples []float64) StatsRollup {
+	if len(samples) == 0 {
+		return StatsRollup{}
+	}
+	sorted := slices.Sorted(slices.Values(samples))
+	sum := 0.0
+	for _, sample := range sorted {
+		sum += sample
+	}
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return StatsRollup{
+		Min:   sorted[0],
+		Avg:   sum / float64(len(sorted)),
+		Max:   sorted[len(sorted)-1],
+		P95:   sorted[rank],
+		Count: len(sorted),
+	}
+}
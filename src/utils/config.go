@@ -182,6 +182,34 @@ var McpServerResource = ResourceDescriptor{
 	Namespaced: true,
 }
 
+var WorkspaceBackupResource = ResourceDescriptor{
+	Kind:       "WorkspaceBackup",
+	Plural:     "workspacebackups",
+	ApiVersion: "mogenius.com/v1alpha1",
+	Namespaced: true,
+}
+
+var BackupScheduleResource = ResourceDescriptor{
+	Kind:       "BackupSchedule",
+	Plural:     "backupschedules",
+	ApiVersion: "mogenius.com/v1alpha1",
+	Namespaced: true,
+}
+
+var NotificationChannelResource = ResourceDescriptor{
+	Kind:       "NotificationChannel",
+	Plural:     "notificationchannels",
+	ApiVersion: "mogenius.com/v1alpha1",
+	Namespaced: true,
+}
+
+var PolicyResource = ResourceDescriptor{
+	Kind:       "Policy",
+	Plural:     "policies",
+	ApiVersion: "mogenius.com/v1alpha1",
+	Namespaced: true,
+}
+
 var PlatformConfigResource = ResourceDescriptor{
 	Kind:       "PlatformConfig",
 	Plural:     "platformconfigs",
@@ -217,6 +245,13 @@ var ClusterRoleResource = ResourceDescriptor{
 	Namespaced: false,
 }
 
+var CustomResourceDefinitionResource = ResourceDescriptor{
+	Kind:       "CustomResourceDefinition",
+	Plural:     "customresourcedefinitions",
+	ApiVersion: "apiextensions.k8s.io/v1",
+	Namespaced: false,
+}
+
 var ExternalSecretResource = ResourceDescriptor{
 	Kind:       "ExternalSecret",
 	Plural:     "externalsecrets",
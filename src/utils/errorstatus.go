@@ -8,6 +8,10 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
+// StatusClientClosedRequest reports a request the caller cancelled before it
+// completed. Not part of net/http; the code is nginx's.
+const StatusClientClosedRequest = 499
+
 // HttpStatusForError reports which HTTP status an error deserves, so the socket
 // response can carry it instead of leaving the platform to guess from prose.
 //
@@ -26,6 +26,9 @@ type ValkeyClient interface {
 	Connect() error
 	Close()
 	Set(value string, expiration time.Duration, keys ...string) error
+	// SetIfNotExists sets the key only if it does not exist yet and reports
+	// whether it did.
+	SetIfNotExists(value string, expiration time.Duration, keys ...string) (bool, error)
 	SetObject(value any, expiration time.Duration, keys ...string) error
 	SetObjectWithAutoincrementLimit(value any, limit int64, ttl time.Duration, keys ...string) (string, error)
 	Get(keys ...string) (string, error)
@@ -35,6 +38,7 @@ type ValkeyClient interface {
 	DeleteFromSortedListWithNsAndReleaseName(namespace string, releaseName string, keys ...string) error
 
 	StoreSortedListEntry(data any, timestamp int64, keys ...string) error
+	StoreSortedListEntryWithRetention(data any, timestamp int64, retention time.Duration, keys ...string) error
 
 	ClearNonEssentialKeys(includeTraffic bool, includePodStats bool, includeNodestats bool) (string, error)
 
@@ -234,6 +238,27 @@ func (self *valkeyClient) Set(value string, expiration time.Duration, keys ...st
 	return nil
 }
 
+func (self *valkeyClient) SetIfNotExists(value string, expiration time.Duration, keys ...string) (bool, error) {
+	key := createKey(keys...)
+
+	cmd := self.valkeyClient.B().Set().Key(key).Value(value).Nx()
+	var err error
+	if expiration != time.Duration(0) {
+		err = self.valkeyClient.Do(self.ctx, cmd.Ex(expiration).Build()).Error()
+	} else {
+		err = self.valkeyClient.Do(self.ctx, cmd.Build()).Error()
+	}
+	if valkeyclient.IsValkeyNil(err) {
+		return false, nil
+	}
+	if err != nil {
+		self.logger.Error("Error setting value in Valkey", "key", key, "error", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (self *valkeyClient) SetObject(value any, expiration time.Duration, keys ...string) error {
 	key := createKey(keys...)
 
@@ -539,13 +564,13 @@ func (self *valkeyClient) ClearNonEssentialKeys(includeTraffic bool, includePodS
 	}
 
 	if includeTraffic {
-		prefixesToDelete = append(prefixesToDelete, "traffic-stats:*")
+		prefixesToDelete = append(prefixesToDelete, "traffic-stats:*", "traffic-stats-rollup:*")
 	}
 	if includePodStats {
-		prefixesToDelete = append(prefixesToDelete, "pod-stats:*")
+		prefixesToDelete = append(prefixesToDelete, "pod-stats:*", "pod-stats-rollup:*")
 	}
 	if includeNodestats {
-		prefixesToDelete = append(prefixesToDelete, "node-stats:*")
+		prefixesToDelete = append(prefixesToDelete, "node-stats:*", "node-stats-rollup:*")
 	}
 
 	self.logger.Info("Deleting non-essential keys from Valkey", "includeTraffic", includeTraffic, "includePodStats", includePodStats, "includeNodestats", includeNodestats)
@@ -1019,6 +1044,17 @@ func parseStreamMessages[T any](logger *slog.Logger, messages []valkeyclient.XRa
 }
 
 func (self *valkeyClient) StoreSortedListEntry(data any, timestamp int64, keys ...string) error {
+	return self.storeSortedListEntry(data, timestamp, MAX_RETENTION_TIME, MAX_RETENTION_SIZE, keys...)
+}
+
+// StoreSortedListEntryWithRetention is StoreSortedListEntry for streams with
+// their own retention window instead of MO_STATS_RETENTION_HOURS and without
+// an entry limit.
+func (self *valkeyClient) StoreSortedListEntryWithRetention(data any, timestamp int64, retention time.Duration, keys ...string) error {
+	return self.storeSortedListEntry(data, timestamp, retention, 0, keys...)
+}
+
+func (self *valkeyClient) storeSortedListEntry(data any, timestamp int64, retention time.Duration, maxEntries int64, keys ...string) error {
 	streamKey := createKey(keys...)
 
 	jsonData, err := json.Marshal(data)
@@ -1040,17 +1076,17 @@ func (self *valkeyClient) StoreSortedListEntry(data any, timestamp int64, keys .
 	cmds = append(cmds, self.valkeyClient.B().Xadd().Key(streamKey).Id(id).FieldValue().
 		FieldValue("data", string(jsonData)).
 		Build())
-	if MAX_RETENTION_TIME > 0 {
-		cutoffTime := time.Now().Add(-MAX_RETENTION_TIME)
+	if retention > 0 {
+		cutoffTime := time.Now().Add(-retention)
 		cutoffID := fmt.Sprintf("%d-0", cutoffTime.UnixMilli())
 		cmds = append(cmds, self.valkeyClient.B().Xtrim().Key(streamKey).Minid().Threshold(cutoffID).Build())
 	}
-	if MAX_RETENTION_SIZE > 0 {
-		cmds = append(cmds, self.valkeyClient.B().Xtrim().Key(streamKey).Maxlen().Threshold(fmt.Sprintf("%d", MAX_RETENTION_SIZE)).Build())
+	if maxEntries > 0 {
+		cmds = append(cmds, self.valkeyClient.B().Xtrim().Key(streamKey).Maxlen().Threshold(fmt.Sprintf("%d", maxEntries)).Build())
 	}
 	// Set TTL on the stream key so stale keys (e.g. for deleted pods) expire automatically.
 	// Active keys get their TTL refreshed on every write.
-	cmds = append(cmds, self.valkeyClient.B().Expire().Key(streamKey).Seconds(int64(MAX_RETENTION_TIME.Seconds())).Build())
+	cmds = append(cmds, self.valkeyClient.B().Expire().Key(streamKey).Seconds(int64(retention.Seconds())).Build())
 
 	results := self.valkeyClient.DoMulti(self.ctx, cmds...)
 
@@ -1147,6 +1183,36 @@ func GetLastObjectsFromSortedList[T any](store ValkeyClient, count int64, keys .
 	return parseStreamMessages[T](store.GetLogger(), messages)
 }
 
+// GetLastKeyedObjectsFromSortedLists is GetLastObjectsFromSortedList for many
+// streams in one round trip: the newest count entries of every key, newest
+// first. Streams that expired between discovery and fetch are skipped.
+func GetLastKeyedObjectsFromSortedLists[T any](store ValkeyClient, count int64, keyList []string) ([]KeyedObject[[]T], error) {
+	if len(keyList) == 0 {
+		return []KeyedObject[[]T]{}, nil
+	}
+
+	client := store.GetValkeyClient()
+	result := make([]KeyedObject[[]T], 0, len(keyList))
+
+	cmds := make([]valkeyclient.Completed, len(keyList))
+	for i, key := range keyList {
+		cmds[i] = client.B().Xrevrange().Key(key).End("+").Start("-").Count(count).Build()
+	}
+	for i, resp := range client.DoMulti(store.GetContext(), cmds...) {
+		messages, err := resp.AsXRange()
+		if err != nil || len(messages) == 0 {
+			continue
+		}
+		objects, err := parseStreamMessages[T](store.GetLogger(), messages)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, KeyedObject[[]T]{Key: keyList[i], Object: objects})
+	}
+
+	return result, nil
+}
+
 func createChannel(parts ...string) string {
 	return strings.Join(parts, ":") + ":channel"
 }
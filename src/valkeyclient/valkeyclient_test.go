@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/stretchr/testify/assert"
@@ -84,3 +85,20 @@ func TestDeleteMultipleOnlyDeletesMatchingPatterns(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []string{"resources:v1:Node::node-a"}, keys)
 }
+
+func TestGetLastKeyedObjectsFromSortedLists(t *testing.T) {
+	self, _ := newTestClient(t)
+
+	now := time.Now().Unix()
+	for i := range 3 {
+		assert.NoError(t, self.StoreSortedListEntryWithRetention(map[string]int{"n": i}, now+int64(i), time.Hour, "stats", "a"))
+	}
+	assert.NoError(t, self.StoreSortedListEntryWithRetention(map[string]int{"n": 7}, now, time.Hour, "stats", "b"))
+
+	result, err := GetLastKeyedObjectsFromSortedLists[map[string]int](self, 2, []string{"stats:a", "stats:missing", "stats:b"})
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyedObject[[]map[string]int]{
+		{Key: "stats:a", Object: []map[string]int{{"n": 2}, {"n": 1}}},
+		{Key: "stats:b", Object: []map[string]int{{"n": 7}}},
+	}, result)
+}
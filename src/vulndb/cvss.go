@@ -0,0 +1,72 @@
+package vulndb
+
+import (
+	"math"
+	"strings"
+)
+
+// cvss3Weights are the base metric weights of CVSS v3.0/v3.1.
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3BaseScore computes the base score of a CVSS v3 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H". Vectors it cannot parse
+// score 0.
+func cvss3BaseScore(vector string) float64 {
+	if !strings.HasPrefix(vector, "CVSS:3.") {
+		return 0
+	}
+	metrics := map[string]string{}
+	for part := range strings.SplitSeq(vector, "/") {
+		if key, value, ok := strings.Cut(part, ":"); ok {
+			metrics[key] = value
+		}
+	}
+	changed := metrics["S"] == "C"
+
+	values := map[string]float64{}
+	for metric, weights := range cvss3Weights {
+		weight, ok := weights[metrics[metric]]
+		if !ok {
+			return 0
+		}
+		values[metric] = weight
+	}
+	privileges, ok := map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}[metrics["PR"]]
+	if !ok || (metrics["S"] != "U" && !changed) {
+		return 0
+	}
+	if changed && metrics["PR"] != "N" {
+		privileges = map[string]float64{"L": 0.68, "H": 0.5}[metrics["PR"]]
+	}
+
+	iss := 1 - (1-values["C"])*(1-values["I"])*(1-values["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0
+	}
+	exploitability := 8.22 * values["AV"] * values["AC"] * privileges * values["UI"]
+	if changed {
+		return roundUp(min(1.08*(impact+exploitability), 10))
+	}
+	return roundUp(min(impact+exploitability, 10))
+}
+
+// roundUp is the "Roundup" of the CVSS v3.1 specification: the smallest
+// number with one decimal equal to or higher than value.
+func roundUp(value float64) float64 {
+	scaled := int64(math.Round(value * 100000))
+	if scaled%10000 == 0 {
+		return float64(scaled) / 100000
+	}
+	return float64(scaled/10000+1) / 10
+}
@@ -0,0 +1,180 @@
+package vulndb
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// OciScheme prefixes sources pulled from a registry, e.g.
+// "oci://mirror.local/aquasecurity/trivy-db:2".
+const OciScheme = "oci://"
+
+// layerTitleAnnotation names the file a layer holds, as set by oras push.
+const layerTitleAnnotation = "org.opencontainers.image.title"
+
+// Load opens the database of source: an OCI reference pulled into cacheDir, a
+// gzipped tarball (as the Trivy DB layer "db.tar.gz") extracted into
+// cacheDir, or a path Open accepts.
+func Load(ctx context.Context, source string, cacheDir string) (Database, error) {
+	path := source
+	if reference, ok := strings.CutPrefix(source, OciScheme); ok {
+		pulled, err := Pull(ctx, reference, cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		path = pulled
+	} else if isTarball(source) {
+		file, err := os.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open vulnerability database: %w", err)
+		}
+		defer file.Close()
+		path = filepath.Join(cacheDir, "local")
+		if err := extractTarball(file, path); err != nil {
+			return nil, err
+		}
+	}
+	return Open(path)
+}
+
+// Pull downloads the layers of the artifact at reference into a directory of
+// cacheDir named after the manifest digest and returns the directory. An
+// artifact already pulled is not downloaded again; the directories of other
+// digests are removed.
+func Pull(ctx context.Context, reference string, cacheDir string) (string, error) {
+	repository, err := remote.NewRepository(reference)
+	if err != nil {
+		return "", fmt.Errorf("invalid vulnerability database reference %q: %w", reference, err)
+	}
+	descriptor, reader, err := repository.FetchReference(ctx, reference)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch vulnerability database manifest %q: %w", reference, err)
+	}
+	var manifest ocispec.Manifest
+	err = json.NewDecoder(reader).Decode(&manifest)
+	reader.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to decode vulnerability database manifest %q: %w", reference, err)
+	}
+
+	dir := filepath.Join(cacheDir, descriptor.Digest.Encoded())
+	if _, err := os.Stat(dir); err == nil {
+		pruneCache(cacheDir, filepath.Base(dir))
+		return dir, nil
+	}
+	staging := dir + ".tmp"
+	if err := os.RemoveAll(staging); err != nil {
+		return "", err
+	}
+	for _, layer := range manifest.Layers {
+		if err := pullLayer(ctx, repository, layer, staging); err != nil {
+			os.RemoveAll(staging)
+			return "", err
+		}
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		return "", fmt.Errorf("failed to store vulnerability database: %w", err)
+	}
+	pruneCache(cacheDir, filepath.Base(dir))
+	return dir, nil
+}
+
+// pruneCache removes the databases pulled for other digests, and their
+// leftover staging directories, from cacheDir; each is a full copy of the
+// database. It is best effort: what it fails to remove is retried on the
+// next pull.
+func pruneCache(cacheDir string, keep string) {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == keep || !isDigestDir(strings.TrimSuffix(name, ".tmp")) {
+			continue
+		}
+		os.RemoveAll(filepath.Join(cacheDir, name))
+	}
+}
+
+// isDigestDir reports whether name is the encoded digest Pull names its
+// directories after, so other files in the cache are left alone.
+func isDigestDir(name string) bool {
+	if len(name) != 64 && len(name) != 128 {
+		return false
+	}
+	return strings.Trim(name, "0123456789abcdef") == ""
+}
+
+func pullLayer(ctx context.Context, repository *remote.Repository, layer ocispec.Descriptor, dir string) error {
+	reader, err := repository.Fetch(ctx, layer)
+	if err != nil {
+		return fmt.Errorf("failed to fetch layer %s: %w", layer.Digest, err)
+	}
+	defer reader.Close()
+
+	title := layer.Annotations[layerTitleAnnotation]
+	if strings.HasSuffix(layer.MediaType, "tar+gzip") || isTarball(title) {
+		return extractTarball(reader, dir)
+	}
+	if title == "" {
+		title = layer.Digest.Encoded()
+	}
+	return writeFile(filepath.Join(dir, filepath.Base(title)), reader)
+}
+
+func isTarball(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// extractTarball extracts the regular files of a gzipped tarball into dir,
+// flattening their paths.
+func extractTarball(reader io.Reader, dir string) error {
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read vulnerability database archive: %w", err)
+	}
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read vulnerability database archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := writeFile(filepath.Join(dir, filepath.Base(header.Name)), archive); err != nil {
+			return err
+		}
+	}
+}
+
+func writeFile(path string, reader io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
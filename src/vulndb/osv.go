@@ -0,0 +1,182 @@
+package vulndb
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// osvAdvisory is the part of the OSV schema the matching needs.
+// See https://ossf.github.io/osv-schema/
+type osvAdvisory struct {
+	Id               string        `json:"id"`
+	Aliases          []string      `json:"aliases"`
+	Summary          string        `json:"summary"`
+	Withdrawn        string        `json:"withdrawn"`
+	Severity         []osvSeverity `json:"severity"`
+	Affected         []osvAffected `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges   []osvRange `json:"ranges"`
+	Versions []string   `json:"versions"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+// osvEntry is an affected package of an advisory, indexed by package.
+type osvEntry struct {
+	vulnerability Vulnerability
+	affected      osvAffected
+}
+
+// osvDatabase holds OSV advisories in memory, indexed by ecosystem and
+// package name.
+type osvDatabase struct {
+	packages map[string][]osvEntry
+}
+
+func openOsv(path string) (Database, error) {
+	db := &osvDatabase{packages: map[string][]osvEntry{}}
+	err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".json":
+			return db.addFile(file)
+		case ".zip":
+			return db.addZip(file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OSV advisories: %w", err)
+	}
+	return db, nil
+}
+
+func (self *osvDatabase) addFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return self.add(path, data)
+}
+
+func (self *osvDatabase) addZip(path string) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	for _, file := range archive.File {
+		if !strings.HasSuffix(strings.ToLower(file.Name), ".json") {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+		if err := self.add(path+"/"+file.Name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *osvDatabase) add(name string, data []byte) error {
+	var advisory osvAdvisory
+	if err := json.Unmarshal(data, &advisory); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if advisory.Id == "" || advisory.Withdrawn != "" {
+		return nil
+	}
+	vulnerability := Vulnerability{
+		Id:       advisory.Id,
+		Aliases:  advisory.Aliases,
+		Summary:  advisory.Summary,
+		Severity: advisory.severity(),
+	}
+	for _, affected := range advisory.Affected {
+		ecosystem := NormalizeEcosystem(affected.Package.Ecosystem)
+		key := ecosystem + "/" + normalizeName(ecosystem, affected.Package.Name)
+		self.packages[key] = append(self.packages[key], osvEntry{vulnerability: vulnerability, affected: affected})
+	}
+	return nil
+}
+
+// severity prefers the severity of the source database (e.g. GHSA), then
+// the CVSS v3 score.
+func (self osvAdvisory) severity() string {
+	if severity := normalizeSeverity(self.DatabaseSpecific.Severity); severity != SeverityUnknown {
+		return severity
+	}
+	score := 0.0
+	for _, severity := range self.Severity {
+		if severity.Type == "CVSS_V3" {
+			score = max(score, cvss3BaseScore(severity.Score))
+		}
+	}
+	return scoreSeverity(score)
+}
+
+func (self *osvDatabase) Match(pkg Package) ([]Vulnerability, error) {
+	ecosystem := NormalizeEcosystem(pkg.Ecosystem)
+	result := []Vulnerability{}
+	for _, entry := range self.packages[ecosystem+"/"+normalizeName(ecosystem, pkg.Name)] {
+		affected, fixed := entry.affected.affects(ecosystem, pkg.Version)
+		if !affected || slices.ContainsFunc(result, func(v Vulnerability) bool { return v.Id == entry.vulnerability.Id }) {
+			continue
+		}
+		vulnerability := entry.vulnerability
+		vulnerability.FixedVersion = fixed
+		result = append(result, vulnerability)
+	}
+	return result, nil
+}
+
+func (self *osvDatabase) Close() error {
+	return nil
+}
+
+// affects checks the listed versions and the ECOSYSTEM and SEMVER ranges;
+// GIT ranges name commits and cannot match package versions.
+func (self osvAffected) affects(ecosystem string, version string) (bool, string) {
+	for _, r := range self.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+		if affected, fixed := affectedByEvents(ecosystem, version, r.Events); affected {
+			return true, fixed
+		}
+	}
+	return slices.Contains(self.Versions, version), ""
+}
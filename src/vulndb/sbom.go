@@ -0,0 +1,168 @@
+package vulndb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	sbomDigest     = regexp.MustCompile(`(?i)sha256(?::|%3a|-)([a-f0-9]{64})`)
+	sbomFileDigest = regexp.MustCompile(`(?i)[a-f0-9]{64}`)
+)
+
+// Sbom is the package list of an image from a CycloneDX JSON SBOM, e.g.
+// written by `syft <image> -o cyclonedx-json` or `trivy image -f cyclonedx`.
+type Sbom struct {
+	// File is the SBOM file name.
+	File string
+	// Digests are the image digests ("sha256:...") found in the file name and
+	// the SBOM's metadata component.
+	Digests []string
+	// Image is the name of the metadata component, usually the image
+	// reference the SBOM was created for.
+	Image    string
+	Packages []Package
+}
+
+type cycloneDxBom struct {
+	BomFormat string `json:"bomFormat"`
+	Metadata  struct {
+		Component json.RawMessage `json:"component"`
+	} `json:"metadata"`
+	Components []cycloneDxComponent `json:"components"`
+}
+
+type cycloneDxComponent struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Purl    string `json:"purl"`
+}
+
+// LoadSboms reads the CycloneDX JSON files below dir; files that are no
+// CycloneDX SBOM are skipped.
+func LoadSboms(dir string) ([]Sbom, error) {
+	sboms := []Sbom{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(strings.ToLower(path), ".json") {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sbom, err := ReadCycloneDx(entry.Name(), data)
+		if err != nil {
+			return nil
+		}
+		sboms = append(sboms, sbom)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SBOMs: %w", err)
+	}
+	return sboms, nil
+}
+
+// ReadCycloneDx parses a CycloneDX JSON SBOM. The distribution of OS packages
+// comes from their purl's "distro" qualifier or the SBOM's operating-system
+// component.
+func ReadCycloneDx(file string, data []byte) (Sbom, error) {
+	var bom cycloneDxBom
+	if err := json.Unmarshal(data, &bom); err != nil {
+		return Sbom{}, err
+	}
+	if bom.BomFormat != "CycloneDX" {
+		return Sbom{}, fmt.Errorf("%s is no CycloneDX SBOM", file)
+	}
+
+	sbom := Sbom{File: file}
+	for _, match := range sbomDigest.FindAllStringSubmatch(string(bom.Metadata.Component), -1) {
+		sbom.Digests = append(sbom.Digests, "sha256:"+strings.ToLower(match[1]))
+	}
+	for _, match := range sbomFileDigest.FindAllString(file, -1) {
+		sbom.Digests = append(sbom.Digests, "sha256:"+strings.ToLower(match))
+	}
+	slices.Sort(sbom.Digests)
+	sbom.Digests = slices.Compact(sbom.Digests)
+	var component cycloneDxComponent
+	if json.Unmarshal(bom.Metadata.Component, &component) == nil {
+		sbom.Image = component.Name
+	}
+
+	operatingSystem := ""
+	for _, component := range bom.Components {
+		if component.Type == "operating-system" {
+			operatingSystem = NormalizeEcosystem(component.Name + ":" + component.Version)
+		}
+	}
+	for _, component := range bom.Components {
+		if pkg, ok := ParsePurl(component.Purl, operatingSystem); ok {
+			sbom.Packages = append(sbom.Packages, pkg)
+		}
+	}
+	return sbom, nil
+}
+
+// ParsePurl maps a package URL to a Package. OS packages without "distro"
+// qualifier belong to operatingSystem; their source package ("upstream"
+// qualifier) is used, as distributions publish advisories for those.
+func ParsePurl(purl string, operatingSystem string) (Package, bool) {
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return Package{}, false
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, rawQualifiers, _ := strings.Cut(rest, "?")
+	qualifiers, _ := url.ParseQuery(rawQualifiers)
+	rest, version, _ := strings.Cut(rest, "@")
+	version, _ = url.PathUnescape(version)
+	purlType, path, ok := strings.Cut(rest, "/")
+	if !ok || version == "" {
+		return Package{}, false
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i], _ = url.PathUnescape(segment)
+	}
+	name := segments[len(segments)-1]
+	namespace := strings.Join(segments[:len(segments)-1], "/")
+
+	pkg := Package{Ecosystem: NormalizeEcosystem(purlType), Name: name, Version: version}
+	switch strings.ToLower(purlType) {
+	case "deb", "apk":
+		pkg.Ecosystem = operatingSystem
+		if distro := qualifiers.Get("distro"); distro != "" {
+			distroName, release, _ := strings.Cut(distro, "-")
+			pkg.Ecosystem = NormalizeEcosystem(distroName + ":" + release)
+		} else if operatingSystem == "" {
+			pkg.Ecosystem = NormalizeEcosystem(namespace)
+		}
+		if upstream := qualifiers.Get("upstream"); upstream != "" {
+			upstreamName, upstreamVersion, _ := strings.Cut(upstream, "@")
+			pkg.Name = upstreamName
+			if upstreamVersion != "" {
+				pkg.Version = upstreamVersion
+			}
+		}
+		if epoch := qualifiers.Get("epoch"); epoch != "" && !strings.Contains(pkg.Version, ":") {
+			pkg.Version = epoch + ":" + pkg.Version
+		}
+	case "npm", "golang", "composer":
+		if namespace != "" {
+			pkg.Name = namespace + "/" + name
+		}
+	case "maven":
+		if namespace != "" {
+			pkg.Name = namespace + ":" + name
+		}
+	}
+	return pkg, true
+}
@@ -0,0 +1,148 @@
+package vulndb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	trivyDbFile = "trivy.db"
+	// trivyVulnerabilityBucket holds the details of every vulnerability id.
+	trivyVulnerabilityBucket = "vulnerability"
+)
+
+// trivySeverities are the values of Trivy's VendorSeverity.
+var trivySeverities = []string{SeverityUnknown, "low", "medium", "high", "critical"}
+
+// trivyAdvisory is the value stored per package and vulnerability id; OS
+// advisories carry FixedVersion (empty when unfixed), language advisories
+// version constraints.
+type trivyAdvisory struct {
+	FixedVersion       string   `json:"FixedVersion"`
+	VulnerableVersions []string `json:"VulnerableVersions"`
+	PatchedVersions    []string `json:"PatchedVersions"`
+	UnaffectedVersions []string `json:"UnaffectedVersions"`
+}
+
+type trivyVulnerability struct {
+	Title          string         `json:"Title"`
+	Severity       string         `json:"Severity"`
+	VendorSeverity map[string]int `json:"VendorSeverity"`
+	CVSS           map[string]struct {
+		V3Vector string  `json:"V3Vector"`
+		V3Score  float64 `json:"V3Score"`
+	} `json:"CVSS"`
+}
+
+// trivyDatabase reads a Trivy DB (schema v2). Its top-level buckets are the
+// advisory sources ("debian 12", "npm::GitHub Security Advisory npm"), each
+// with one bucket per package mapping vulnerability ids to advisories.
+type trivyDatabase struct {
+	db *bolt.DB
+	// sources are the advisory buckets per ecosystem.
+	sources map[string][]string
+}
+
+func openTrivy(path string) (Database, error) {
+	db, err := bolt.Open(path, 0o444, &bolt.Options{ReadOnly: true, Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Trivy DB %s: %w", path, err)
+	}
+	self := &trivyDatabase{db: db, sources: map[string][]string{}}
+	err = db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			ecosystem := NormalizeEcosystem(string(name))
+			self.sources[ecosystem] = append(self.sources[ecosystem], string(name))
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read Trivy DB %s: %w", path, err)
+	}
+	return self, nil
+}
+
+func (self *trivyDatabase) Match(pkg Package) ([]Vulnerability, error) {
+	ecosystem := NormalizeEcosystem(pkg.Ecosystem)
+	name := normalizeName(ecosystem, pkg.Name)
+	result := []Vulnerability{}
+	err := self.db.View(func(tx *bolt.Tx) error {
+		details := tx.Bucket([]byte(trivyVulnerabilityBucket))
+		for _, source := range self.sources[ecosystem] {
+			bucket := tx.Bucket([]byte(source)).Bucket([]byte(name))
+			if bucket == nil {
+				continue
+			}
+			err := bucket.ForEach(func(id []byte, value []byte) error {
+				var advisory trivyAdvisory
+				if err := json.Unmarshal(value, &advisory); err != nil {
+					return fmt.Errorf("advisory %s of %s in %q: %w", id, name, source, err)
+				}
+				if !advisory.affects(ecosystem, pkg.Version) {
+					return nil
+				}
+				result = append(result, trivyVulnerabilityOf(string(id), advisory, details))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (self *trivyDatabase) Close() error {
+	return self.db.Close()
+}
+
+// affects follows Trivy: vulnerable version constraints decide when given,
+// otherwise every version neither patched nor unaffected is vulnerable.
+func (self trivyAdvisory) affects(ecosystem string, version string) bool {
+	if len(self.VulnerableVersions) > 0 {
+		return matchesConstraints(ecosystem, version, self.VulnerableVersions, false)
+	}
+	if len(self.PatchedVersions) > 0 || len(self.UnaffectedVersions) > 0 {
+		return !matchesConstraints(ecosystem, version, self.PatchedVersions, true) &&
+			!matchesConstraints(ecosystem, version, self.UnaffectedVersions, false)
+	}
+	return self.FixedVersion == "" || CompareVersions(ecosystem, version, self.FixedVersion) < 0
+}
+
+func trivyVulnerabilityOf(id string, advisory trivyAdvisory, details *bolt.Bucket) Vulnerability {
+	vulnerability := Vulnerability{Id: id, Severity: SeverityUnknown, FixedVersion: advisory.FixedVersion}
+	if vulnerability.FixedVersion == "" && len(advisory.PatchedVersions) > 0 {
+		_, fixed := splitOperator(strings.TrimSpace(advisory.PatchedVersions[0]))
+		vulnerability.FixedVersion = strings.TrimSpace(fixed)
+	}
+	if details == nil {
+		return vulnerability
+	}
+	var detail trivyVulnerability
+	if data := details.Get([]byte(id)); data == nil || json.Unmarshal(data, &detail) != nil {
+		return vulnerability
+	}
+	vulnerability.Summary = detail.Title
+	vulnerability.Severity = normalizeSeverity(detail.Severity)
+	if vulnerability.Severity == SeverityUnknown {
+		worst := 0
+		for _, severity := range detail.VendorSeverity {
+			worst = max(worst, min(severity, len(trivySeverities)-1))
+		}
+		vulnerability.Severity = trivySeverities[worst]
+	}
+	if vulnerability.Severity == SeverityUnknown {
+		score := 0.0
+		for _, cvss := range detail.CVSS {
+			score = max(score, cvss.V3Score, cvss3BaseScore(cvss.V3Vector))
+		}
+		vulnerability.Severity = scoreSeverity(score)
+	}
+	return vulnerability
+}
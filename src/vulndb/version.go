@@ -0,0 +1,257 @@
+package vulndb
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	pypiPreRelease     = regexp.MustCompile(`[.\-_]?(dev|alpha|a|beta|b|preview|pre|c|rc)(\d*)`)
+	rubygemsPreRelease = regexp.MustCompile(`\.([a-z])`)
+	alpinePreRelease   = regexp.MustCompile(`_(alpha|beta|pre|rc)`)
+)
+
+// CompareVersions compares two versions of an ecosystem and returns -1, 0 or
+// +1. Distribution versions follow the dpkg ordering (epoch, upstream version
+// and revision, "~" sorting before anything); versions of language ecosystems
+// are mapped onto it, so pre-releases sort before their release.
+func CompareVersions(ecosystem string, a string, b string) int {
+	return compareDpkg(comparable(ecosystem, a), comparable(ecosystem, b))
+}
+
+func comparable(ecosystem string, version string) string {
+	name, _, _ := strings.Cut(ecosystem, ":")
+	switch name {
+	case EcosystemDebian, EcosystemUbuntu:
+		return version
+	case EcosystemAlpine:
+		return alpinePreRelease.ReplaceAllString(version, "~$1")
+	case EcosystemPypi:
+		version, _, _ = strings.Cut(strings.ToLower(strings.TrimPrefix(version, "v")), "+")
+		return pypiPreRelease.ReplaceAllString(version, "~$1$2")
+	case EcosystemRubygems:
+		return rubygemsPreRelease.ReplaceAllString(strings.ToLower(version), "~$1")
+	}
+	// semver-like: "v1.2.3-rc.1+build" -> "1.2.3~rc.1"
+	version, _, _ = strings.Cut(strings.TrimPrefix(version, "v"), "+")
+	return strings.Replace(version, "-", "~", 1)
+}
+
+// compareDpkg implements the Debian version ordering.
+func compareDpkg(a string, b string) int {
+	aEpoch, aRest := splitEpoch(a)
+	bEpoch, bRest := splitEpoch(b)
+	if c := compareSegments(aEpoch, bEpoch); c != 0 {
+		return c
+	}
+	aUpstream, aRevision := splitRevision(aRest)
+	bUpstream, bRevision := splitRevision(bRest)
+	if c := compareSegments(aUpstream, bUpstream); c != 0 {
+		return c
+	}
+	return compareSegments(aRevision, bRevision)
+}
+
+func splitEpoch(version string) (string, string) {
+	epoch, rest, ok := strings.Cut(version, ":")
+	if !ok || epoch == "" || strings.Trim(epoch, "0123456789") != "" {
+		return "0", version
+	}
+	return epoch, rest
+}
+
+func splitRevision(version string) (string, string) {
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		return version[:i], version[i+1:]
+	}
+	return version, ""
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// order is the weight of a character in non-digit parts: "~" before the end
+// of the string, letters before other characters.
+func order(s string) int {
+	if s == "" {
+		return 0
+	}
+	c := s[0]
+	switch {
+	case isDigit(c):
+		return 0
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	}
+	return int(c) + 256
+}
+
+// compareSegments compares alternating non-digit and digit parts, the former
+// by character order, the latter numerically.
+func compareSegments(a string, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			if ac, bc := order(a), order(b); ac != bc {
+				return sign(ac - bc)
+			}
+			a, b = advance(a), advance(b)
+		}
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		firstDiff := 0
+		for a != "" && b != "" && isDigit(a[0]) && isDigit(b[0]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[0]) - int(b[0])
+			}
+			a, b = a[1:], b[1:]
+		}
+		if a != "" && isDigit(a[0]) {
+			return 1
+		}
+		if b != "" && isDigit(b[0]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+func advance(s string) string {
+	if s == "" {
+		return s
+	}
+	return s[1:]
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
+
+// matchesConstraints reports whether version satisfies any of the constraints.
+// A constraint is a list of comparisons joined by "," or spaces, alternatives
+// are separated by "||", e.g. ">=1.0.0, <1.2.3 || >=2.0.0 <2.0.5". A bare
+// version means "=" unless bareMeansAtLeast is set, as in patched versions.
+func matchesConstraints(ecosystem string, version string, constraints []string, bareMeansAtLeast bool) bool {
+	for _, constraint := range constraints {
+		for alternative := range strings.SplitSeq(constraint, "||") {
+			if matchesAll(ecosystem, version, alternative, bareMeansAtLeast) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesAll(ecosystem string, version string, constraint string, bareMeansAtLeast bool) bool {
+	fields := strings.Fields(strings.ReplaceAll(constraint, ",", " "))
+	if len(fields) == 0 {
+		return false
+	}
+	for i := 0; i < len(fields); i++ {
+		operator, operand := splitOperator(fields[i])
+		if operand == "" && i+1 < len(fields) {
+			i++
+			operand = fields[i]
+		}
+		if operator == "" {
+			operator = "="
+			if bareMeansAtLeast {
+				operator = ">="
+			}
+		}
+		c := CompareVersions(ecosystem, version, operand)
+		ok := map[string]bool{
+			"=":  c == 0,
+			"==": c == 0,
+			"!=": c != 0,
+			"<":  c < 0,
+			"<=": c <= 0,
+			">":  c > 0,
+			">=": c >= 0,
+		}[operator]
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func splitOperator(field string) (string, string) {
+	for _, operator := range []string{">=", "<=", "==", "!=", ">", "<", "="} {
+		if operand, ok := strings.CutPrefix(field, operator); ok {
+			return operator, operand
+		}
+	}
+	return "", field
+}
+
+// osvEvent is one event of an OSV range.
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+func (self osvEvent) version() string {
+	return self.Introduced + self.Fixed + self.LastAffected + self.Limit
+}
+
+// affectedByEvents evaluates the events of an OSV range for version and
+// returns whether it is affected and the version fixing it, if known.
+func affectedByEvents(ecosystem string, version string, events []osvEvent) (bool, string) {
+	events = slices.Clone(events)
+	slices.SortStableFunc(events, func(a, b osvEvent) int {
+		if a.Introduced == "0" || b.Introduced == "0" {
+			return sign(boolInt(b.Introduced == "0") - boolInt(a.Introduced == "0"))
+		}
+		return CompareVersions(ecosystem, a.version(), b.version())
+	})
+
+	affected := false
+	for _, event := range events {
+		switch {
+		case event.Introduced != "":
+			if event.Introduced == "0" || CompareVersions(ecosystem, version, event.Introduced) >= 0 {
+				affected = true
+			}
+		case event.Fixed != "":
+			if CompareVersions(ecosystem, version, event.Fixed) >= 0 {
+				affected = false
+			} else if affected {
+				return true, event.Fixed
+			}
+		case event.LastAffected != "":
+			if CompareVersions(ecosystem, version, event.LastAffected) > 0 {
+				affected = false
+			} else if affected {
+				return true, ""
+			}
+		case event.Limit != "":
+			if CompareVersions(ecosystem, version, event.Limit) >= 0 {
+				affected = false
+			} else if affected {
+				return true, ""
+			}
+		}
+	}
+	return affected, ""
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
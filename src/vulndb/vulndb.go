@@ -0,0 +1,162 @@
+// Package vulndb matches packages against an offline vulnerability database,
+// either a Trivy DB (bbolt) or a dump of OSV JSON advisories. Both can be read
+// from a local path or pulled as OCI artifact from a (mirror) registry, so the
+// matching works in air-gapped clusters.
+package vulndb
+
+import (
+	"fmt"
+	"mogenius-operator/src/security"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SeverityUnknown is reported for advisories without severity or CVSS score.
+const SeverityUnknown = "unknown"
+
+// Ecosystems as used by Package.Ecosystem. Distributions carry their release,
+// e.g. "debian:12", "ubuntu:22.04" or "alpine:3.19".
+const (
+	EcosystemDebian   = "debian"
+	EcosystemUbuntu   = "ubuntu"
+	EcosystemAlpine   = "alpine"
+	EcosystemNpm      = "npm"
+	EcosystemPypi     = "pypi"
+	EcosystemGo       = "go"
+	EcosystemMaven    = "maven"
+	EcosystemCargo    = "cargo"
+	EcosystemRubygems = "rubygems"
+	EcosystemNuget    = "nuget"
+	EcosystemComposer = "composer"
+)
+
+// Package is one installed package of an image.
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+}
+
+// Vulnerability is an advisory affecting a package version.
+type Vulnerability struct {
+	Id           string   `json:"id"`
+	Aliases      []string `json:"aliases,omitempty"`
+	Summary      string   `json:"summary,omitempty"`
+	Severity     string   `json:"severity"`
+	FixedVersion string   `json:"fixedVersion,omitempty"`
+}
+
+// Database looks up the vulnerabilities of packages.
+type Database interface {
+	Match(pkg Package) ([]Vulnerability, error)
+	Close() error
+}
+
+// Open opens the database at path: a Trivy DB file ("trivy.db"), a directory
+// containing one, or OSV advisories as directory of .json/.zip files, single
+// .json file or .zip archive.
+func Open(path string) (Database, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vulnerability database: %w", err)
+	}
+	if info.IsDir() {
+		trivyPath := filepath.Join(path, trivyDbFile)
+		if _, err := os.Stat(trivyPath); err == nil {
+			return openTrivy(trivyPath)
+		}
+		return openOsv(path)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".zip":
+		return openOsv(path)
+	}
+	return openTrivy(path)
+}
+
+// NormalizeEcosystem maps the ecosystem names of OSV ("Debian:12",
+// "Alpine:v3.19", "crates.io"), Trivy ("debian 12", "pip::GitHub Security
+// Advisory pip") and package URL types ("deb", "golang", "gem") to the
+// Ecosystem constants. Unknown ecosystems are returned lower case.
+func NormalizeEcosystem(ecosystem string) string {
+	ecosystem = strings.ToLower(strings.TrimSpace(ecosystem))
+	if prefix, _, ok := strings.Cut(ecosystem, "::"); ok {
+		ecosystem = prefix
+	}
+	name, release, _ := strings.Cut(strings.Replace(ecosystem, " ", ":", 1), ":")
+	switch name {
+	case EcosystemDebian, EcosystemUbuntu, EcosystemAlpine:
+		return distribution(name, release)
+	case "pip", "python":
+		return EcosystemPypi
+	case "golang":
+		return EcosystemGo
+	case "crates.io":
+		return EcosystemCargo
+	case "gem":
+		return EcosystemRubygems
+	case "packagist":
+		return EcosystemComposer
+	}
+	return ecosystem
+}
+
+// distribution joins a distribution and its release, keeping the parts of the
+// release advisories are published for: the major release of Debian, major
+// and minor of Alpine and Ubuntu.
+func distribution(name string, release string) string {
+	release = strings.TrimPrefix(release, "v")
+	release, _, _ = strings.Cut(release, ":") // Ubuntu:22.04:LTS
+	parts := strings.Split(release, ".")
+	switch name {
+	case EcosystemDebian:
+		parts = parts[:1]
+	case EcosystemAlpine, EcosystemUbuntu:
+		parts = parts[:min(len(parts), 2)]
+	}
+	release = strings.Join(parts, ".")
+	if release == "" {
+		return name
+	}
+	return name + ":" + release
+}
+
+// normalizeName maps package names to the form advisories use.
+func normalizeName(ecosystem string, name string) string {
+	if ecosystem == EcosystemPypi {
+		return strings.NewReplacer("_", "-", ".", "-").Replace(strings.ToLower(name))
+	}
+	return name
+}
+
+// normalizeSeverity maps the severities of GHSA ("MODERATE"), Trivy and OSV
+// to the security severities.
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case security.SeverityCritical:
+		return security.SeverityCritical
+	case security.SeverityHigh, "important":
+		return security.SeverityHigh
+	case security.SeverityMedium, "moderate":
+		return security.SeverityMedium
+	case security.SeverityLow, "negligible":
+		return security.SeverityLow
+	}
+	return SeverityUnknown
+}
+
+// scoreSeverity maps a CVSS score to its qualitative severity.
+func scoreSeverity(score float64) string {
+	switch {
+	case score >= 9:
+		return security.SeverityCritical
+	case score >= 7:
+		return security.SeverityHigh
+	case score >= 4:
+		return security.SeverityMedium
+	case score > 0:
+		return security.SeverityLow
+	}
+	return SeverityUnknown
+}
@@ -0,0 +1,232 @@
+package vulndb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		ecosystem string
+		a, b      string
+		want      int
+	}{
+		{"debian:12", "3.0.11-1~deb12u2", "3.0.11-1", -1},
+		{"debian:12", "1:2.0-1", "3.0-1", 1},
+		{"debian:12", "2.36-9+deb12u4", "2.36-9+deb12u10", -1},
+		{"alpine:3.19", "1.36.1-r5", "1.36.1-r15", -1},
+		{"alpine:3.19", "3.1.4_rc1-r0", "3.1.4-r0", -1},
+		{"npm", "4.17.20", "4.17.21", -1},
+		{"npm", "1.0.0-beta.2", "1.0.0", -1},
+		{"go", "v0.17.0", "0.17.0", 0},
+		{"go", "v1.10.0", "v1.9.9", 1},
+		{"pypi", "2.0.0rc1", "2.0.0", -1},
+		{"pypi", "2.0.0.post1", "2.0.0", 1},
+		{"rubygems", "7.1.0.beta1", "7.1.0", -1},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, CompareVersions(c.ecosystem, c.a, c.b), "%s %s vs %s", c.ecosystem, c.a, c.b)
+		assert.Equal(t, -c.want, CompareVersions(c.ecosystem, c.b, c.a), "%s %s vs %s", c.ecosystem, c.b, c.a)
+	}
+}
+
+func TestNormalizeEcosystem(t *testing.T) {
+	assert.Equal(t, "debian:12", NormalizeEcosystem("Debian:12"))
+	assert.Equal(t, "debian:12", NormalizeEcosystem("debian 12"))
+	assert.Equal(t, "alpine:3.19", NormalizeEcosystem("Alpine:v3.19"))
+	assert.Equal(t, "alpine:3.19", NormalizeEcosystem("alpine:3.19.1"))
+	assert.Equal(t, "ubuntu:22.04", NormalizeEcosystem("Ubuntu:22.04:LTS"))
+	assert.Equal(t, "pypi", NormalizeEcosystem("pip::GitHub Security Advisory pip"))
+	assert.Equal(t, "cargo", NormalizeEcosystem("crates.io"))
+	assert.Equal(t, "go", NormalizeEcosystem("golang"))
+}
+
+func TestMatchesConstraints(t *testing.T) {
+	vulnerable := []string{">=1.0.0, <1.2.3", ">= 2.0.0 < 2.0.5"}
+	assert.True(t, matchesConstraints("npm", "1.1.0", vulnerable, false))
+	assert.True(t, matchesConstraints("npm", "2.0.4", vulnerable, false))
+	assert.False(t, matchesConstraints("npm", "1.2.3", vulnerable, false))
+	assert.False(t, matchesConstraints("npm", "0.9.0", vulnerable, false))
+	assert.True(t, matchesConstraints("npm", "3.1.0", []string{"<1.0.0 || >=3.0.0"}, false))
+	assert.True(t, matchesConstraints("npm", "1.2.4", []string{"1.2.3"}, true))
+	assert.False(t, matchesConstraints("npm", "1.2.4", []string{"1.2.3"}, false))
+}
+
+func TestCvss3BaseScore(t *testing.T) {
+	assert.Equal(t, 9.8, cvss3BaseScore("CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"))
+	assert.Equal(t, 10.0, cvss3BaseScore("CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H"))
+	assert.Equal(t, 6.1, cvss3BaseScore("CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N"))
+	assert.Equal(t, 5.5, cvss3BaseScore("CVSS:3.0/AV:L/AC:L/PR:L/UI:N/S:U/C:N/I:N/A:H"))
+	assert.Equal(t, 0.0, cvss3BaseScore("CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N"))
+	assert.Equal(t, 0.0, cvss3BaseScore("AV:N/AC:L/Au:N/C:P/I:P/A:P"))
+}
+
+func writeJson(t *testing.T, path string, value any) {
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+func TestOsvDatabase(t *testing.T) {
+	dir := t.TempDir()
+	writeJson(t, filepath.Join(dir, "GHSA-1.json"), map[string]any{
+		"id":                "GHSA-1",
+		"aliases":           []string{"CVE-2021-23337"},
+		"summary":           "Command injection in lodash",
+		"database_specific": map[string]any{"severity": "HIGH"},
+		"affected": []any{map[string]any{
+			"package": map[string]any{"ecosystem": "npm", "name": "lodash"},
+			"ranges": []any{map[string]any{"type": "SEMVER", "events": []any{
+				map[string]any{"introduced": "0"}, map[string]any{"fixed": "4.17.21"},
+			}}},
+		}},
+	})
+	writeJson(t, filepath.Join(dir, "DSA-1.json"), map[string]any{
+		"id":       "DSA-1",
+		"severity": []any{map[string]any{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}},
+		"affected": []any{map[string]any{
+			"package": map[string]any{"ecosystem": "Debian:12", "name": "openssl"},
+			"ranges": []any{map[string]any{"type": "ECOSYSTEM", "events": []any{
+				map[string]any{"introduced": "0"}, map[string]any{"fixed": "3.0.13-1~deb12u1"},
+			}}},
+		}},
+	})
+	writeJson(t, filepath.Join(dir, "withdrawn.json"), map[string]any{
+		"id":        "GHSA-2",
+		"withdrawn": "2024-01-01T00:00:00Z",
+		"affected":  []any{map[string]any{"package": map[string]any{"ecosystem": "npm", "name": "lodash"}, "versions": []string{"4.17.20"}}},
+	})
+
+	db, err := Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	vulnerabilities, err := db.Match(Package{Ecosystem: "npm", Name: "lodash", Version: "4.17.20"})
+	require.NoError(t, err)
+	assert.Equal(t, []Vulnerability{{
+		Id:           "GHSA-1",
+		Aliases:      []string{"CVE-2021-23337"},
+		Summary:      "Command injection in lodash",
+		Severity:     "high",
+		FixedVersion: "4.17.21",
+	}}, vulnerabilities)
+
+	vulnerabilities, err = db.Match(Package{Ecosystem: "npm", Name: "lodash", Version: "4.17.21"})
+	require.NoError(t, err)
+	assert.Empty(t, vulnerabilities)
+
+	vulnerabilities, err = db.Match(Package{Ecosystem: "debian:12", Name: "openssl", Version: "3.0.11-1~deb12u2"})
+	require.NoError(t, err)
+	require.Len(t, vulnerabilities, 1)
+	assert.Equal(t, "critical", vulnerabilities[0].Severity, "severity from the CVSS vector")
+}
+
+func TestTrivyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), trivyDbFile)
+	db, err := bolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+	put := func(tx *bolt.Tx, source string, pkg string, id string, value any) {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(source))
+		require.NoError(t, err)
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(pkg))
+		require.NoError(t, err)
+		data, err := json.Marshal(value)
+		require.NoError(t, err)
+		require.NoError(t, bucket.Put([]byte(id), data))
+	}
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		put(tx, "debian 12", "openssl", "CVE-2024-0001", map[string]any{"FixedVersion": "3.0.13-1~deb12u1"})
+		put(tx, "debian 12", "openssl", "CVE-2024-0002", map[string]any{})
+		put(tx, "npm::GitHub Security Advisory npm", "lodash", "CVE-2021-23337", map[string]any{
+			"PatchedVersions":    []string{"4.17.21"},
+			"VulnerableVersions": []string{"<4.17.21"},
+		})
+		details, err := tx.CreateBucketIfNotExists([]byte(trivyVulnerabilityBucket))
+		require.NoError(t, err)
+		data, _ := json.Marshal(map[string]any{"Title": "openssl: flaw", "Severity": "CRITICAL"})
+		require.NoError(t, details.Put([]byte("CVE-2024-0001"), data))
+		data, _ = json.Marshal(map[string]any{"VendorSeverity": map[string]int{"debian": 1, "nvd": 2}})
+		return details.Put([]byte("CVE-2024-0002"), data)
+	}))
+	require.NoError(t, db.Close())
+
+	database, err := Open(filepath.Dir(path))
+	require.NoError(t, err)
+	defer database.Close()
+
+	vulnerabilities, err := database.Match(Package{Ecosystem: "debian:12", Name: "openssl", Version: "3.0.11-1~deb12u2"})
+	require.NoError(t, err)
+	assert.Equal(t, []Vulnerability{
+		{Id: "CVE-2024-0001", Summary: "openssl: flaw", Severity: "critical", FixedVersion: "3.0.13-1~deb12u1"},
+		{Id: "CVE-2024-0002", Severity: "medium"},
+	}, vulnerabilities)
+
+	vulnerabilities, err = database.Match(Package{Ecosystem: "debian:12", Name: "openssl", Version: "3.0.13-1~deb12u1"})
+	require.NoError(t, err)
+	assert.Len(t, vulnerabilities, 1, "unfixed vulnerabilities affect every version")
+
+	vulnerabilities, err = database.Match(Package{Ecosystem: "npm", Name: "lodash", Version: "4.17.15"})
+	require.NoError(t, err)
+	require.Len(t, vulnerabilities, 1)
+	assert.Equal(t, "4.17.21", vulnerabilities[0].FixedVersion)
+	assert.Equal(t, SeverityUnknown, vulnerabilities[0].Severity)
+}
+
+func TestReadCycloneDx(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	data, err := json.Marshal(map[string]any{
+		"bomFormat": "CycloneDX",
+		"metadata": map[string]any{"component": map[string]any{
+			"type": "container", "name": "registry.example.com/shop/api:1.2", "version": digest,
+		}},
+		"components": []any{
+			map[string]any{"type": "operating-system", "name": "debian", "version": "12.5"},
+			map[string]any{"type": "library", "name": "libssl3", "version": "3.0.11-1~deb12u2", "purl": "pkg:deb/debian/libssl3@3.0.11-1~deb12u2?arch=amd64&upstream=openssl"},
+			map[string]any{"type": "library", "name": "core", "version": "16.0.0", "purl": "pkg:npm/%40angular/core@16.0.0"},
+			map[string]any{"type": "library", "name": "jackson-databind", "purl": "pkg:maven/com.fasterxml.jackson.core/jackson-databind@2.15.0"},
+			map[string]any{"type": "library", "name": "busybox", "purl": "pkg:apk/alpine/busybox@1.36.1-r5?distro=alpine-3.19.1"},
+			map[string]any{"type": "file", "name": "/etc/passwd"},
+		},
+	})
+	require.NoError(t, err)
+
+	sbom, err := ReadCycloneDx("api.cdx.json", data)
+	require.NoError(t, err)
+	assert.Equal(t, []string{digest}, sbom.Digests)
+	assert.Equal(t, "registry.example.com/shop/api:1.2", sbom.Image)
+	assert.Equal(t, []Package{
+		{Ecosystem: "debian:12", Name: "openssl", Version: "3.0.11-1~deb12u2"},
+		{Ecosystem: "npm", Name: "@angular/core", Version: "16.0.0"},
+		{Ecosystem: "maven", Name: "com.fasterxml.jackson.core:jackson-databind", Version: "2.15.0"},
+		{Ecosystem: "alpine:3.19", Name: "busybox", Version: "1.36.1-r5"},
+	}, sbom.Packages)
+
+	_, err = ReadCycloneDx("spdx.json", []byte(`{"spdxVersion":"SPDX-2.3"}`))
+	assert.Error(t, err)
+}
+
+func TestPruneCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	current := strings.Repeat("a", 64)
+	old := strings.Repeat("b", 64)
+	for _, name := range []string{current, old, old + ".tmp", "local"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(cacheDir, name), 0o755))
+	}
+
+	pruneCache(cacheDir, current)
+
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{current, "local"}, names, "extracted tarballs are not pulled digests")
+}
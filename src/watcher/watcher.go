@@ -296,13 +296,17 @@ func (self *watcher) registerAndStartInformer(gvr schema.GroupVersionResource, r
 	if !self.informersConfigured[gvr] {
 		// Strip large metadata fields before caching to reduce in-process
 		// memory usage. managedFields (server-side apply tracking) and
-		// last-applied-configuration are never used by event handlers and
-		// can be several KB per object.
+		// last-applied-configuration can be several KB per object. The
+		// latter is kept on objects tracked by ArgoCD, where it is the
+		// desired state the GitOps drift detector compares with, except on
+		// Secrets, where it holds the secret values in plain text.
 		if err := resourceInformer.SetTransform(func(obj any) (any, error) {
 			if u, ok := obj.(*unstructured.Unstructured); ok {
 				u.SetManagedFields(nil)
 				annotations := u.GetAnnotations()
-				delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+				if gvr.Resource == "secrets" || !isArgoCdTracked(u) {
+					delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+				}
 				u.SetAnnotations(annotations)
 			}
 			return obj, nil
@@ -690,6 +694,16 @@ func (d *debouncer) trigger() {
 	d.timer = time.AfterFunc(d.delay, d.fn)
 }
 
+// isArgoCdTracked reports whether obj carries ArgoCD's tracking annotation.
+// The app.kubernetes.io/instance label of ArgoCD's label tracking mode is set
+// by most Helm charts as well, so it would keep the annotation on nearly
+// every object; the drift detector falls back to revision snapshots for
+// label-tracked objects instead.
+func isArgoCdTracked(obj *unstructured.Unstructured) bool {
+	_, ok := obj.GetAnnotations()["argocd.argoproj.io/tracking-id"]
+	return ok
+}
+
 func (self *watcher) createGroupVersionResource(apiVersion string, plural string) schema.GroupVersionResource {
 	gv, err := schema.ParseGroupVersion(apiVersion) // e.g., "apps/v1" or just "v1"
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	dynamicfake "k8s.io/client-go/dynamic/fake"
@@ -52,3 +53,14 @@ func TestFactoryForGVR_RoutesOtherResourcesToSharedFactory(t *testing.T) {
 func TestSecretWatchFieldSelectorExcludesHelmReleases(t *testing.T) {
 	assert.Equal(t, "type!=helm.sh/release.v1", secretWatchFieldSelector)
 }
+
+func TestIsArgoCdTracked(t *testing.T) {
+	tracked := &unstructured.Unstructured{}
+	tracked.SetAnnotations(map[string]string{"argocd.argoproj.io/tracking-id": "shop:apps/Deployment:shop/api"})
+	assert.True(t, isArgoCdTracked(tracked))
+
+	// set by Helm charts as well
+	helmChart := &unstructured.Unstructured{}
+	helmChart.SetLabels(map[string]string{"app.kubernetes.io/instance": "ingress-nginx"})
+	assert.False(t, isArgoCdTracked(helmChart))
+}
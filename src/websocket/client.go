@@ -67,6 +67,9 @@ type WebsocketClient interface {
 	// check if the WebsocketClient is done
 	IsTerminated() bool
 
+	// check if the internal websocket connection is currently established
+	IsConnected() bool
+
 	SetUrl(url url.URL) error
 	GetUrl() (url.URL, error)
 	SetHeader(header http.Header) error
@@ -192,6 +195,10 @@ func (self *websocketClient) IsTerminated() bool {
 	return self.terminated.Load()
 }
 
+func (self *websocketClient) IsConnected() bool {
+	return self.connected.Load()
+}
+
 func (self *websocketClient) WriteMessage(messageType int, data []byte) error {
 	select {
 	case <-self.ctx.Done():
@@ -298,6 +305,9 @@ type websocketClient struct {
 	// label for the websocket_connected prometheus metric; empty disables it
 	name string
 
+	// mirrors the websocket_connected metric for IsConnected
+	connected atomic.Bool
+
 	// needed for the reconnect method to know if it should attempt reconnecting
 	enableReconnecting atomic.Bool
 
@@ -479,7 +489,7 @@ func (self *websocketClient) startRuntime() {
 		case <-self.apiTerminateTx:
 			alreadyTerminated := self.terminated.Swap(true)
 			self.enableReconnecting.Store(false)
-			metrics.SetWebsocketConnected(self.name, false)
+			self.setConnected(false)
 			if !alreadyTerminated && isRunning {
 				err := self.sendCloseMessage()
 				if err != nil {
@@ -542,7 +552,7 @@ func (self *websocketClient) startRuntime() {
 			go self.startReadThread()
 			go self.startWriteThread()
 			self.enableReconnecting.Store(true)
-			metrics.SetWebsocketConnected(self.name, true)
+			self.setConnected(true)
 			isRunning = true
 			self.apiConnectRx <- err
 		case <-self.apiDisconnectTx:
@@ -564,13 +574,18 @@ func (self *websocketClient) startRuntime() {
 			self.shutdownWorkerThreads()
 			self.connection = nil
 			self.enableReconnecting.Store(false)
-			metrics.SetWebsocketConnected(self.name, false)
+			self.setConnected(false)
 			isRunning = false
 			self.apiDisconnectRx <- nil
 		}
 	}
 }
 
+func (self *websocketClient) setConnected(connected bool) {
+	self.connected.Store(connected)
+	metrics.SetWebsocketConnected(self.name, connected)
+}
+
 // worker thread to run reads on the internal websocket connection
 func (self *websocketClient) startReadThread() {
 	self.connection.SetCloseHandler(func(code int, text string) error {
@@ -1,6 +1,7 @@
 package helm_test
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"mogenius-operator/src/assert"
@@ -95,7 +96,7 @@ func installForTests(t *testing.T) error {
 		Values:    testValues,
 		DryRun:    testDryRun,
 	}
-	_, err = helm.HelmChartInstall(helmInstallData)
+	_, err = helm.HelmChartInstall(context.Background(), helmInstallData)
 	if err != nil {
 		t.Log(err)
 		return err